@@ -0,0 +1,164 @@
+package sarama
+
+// AclResourceType is the type of resource an ACL applies to.
+type AclResourceType int8
+
+const (
+	AclResourceUnknown AclResourceType = 0
+	// AclResourceAny matches resources of any type in filters.
+	AclResourceAny             AclResourceType = 1
+	AclResourceTopic           AclResourceType = 2
+	AclResourceGroup           AclResourceType = 3
+	AclResourceCluster         AclResourceType = 4
+	AclResourceTransactionalID AclResourceType = 5
+)
+
+// AclOperation is the operation an ACL allows or denies.
+type AclOperation int8
+
+const (
+	AclOperationUnknown AclOperation = 0
+	// AclOperationAny matches any operation in filters.
+	AclOperationAny AclOperation = 1
+	// AclOperationAll stands for every operation in ACLs.
+	AclOperationAll             AclOperation = 2
+	AclOperationRead            AclOperation = 3
+	AclOperationWrite           AclOperation = 4
+	AclOperationCreate          AclOperation = 5
+	AclOperationDelete          AclOperation = 6
+	AclOperationAlter           AclOperation = 7
+	AclOperationDescribe        AclOperation = 8
+	AclOperationClusterAction   AclOperation = 9
+	AclOperationDescribeConfigs AclOperation = 10
+	AclOperationAlterConfigs    AclOperation = 11
+	AclOperationIdempotentWrite AclOperation = 12
+)
+
+// AclPermissionType is whether an ACL allows or denies its operation.
+type AclPermissionType int8
+
+const (
+	AclPermissionUnknown AclPermissionType = 0
+	// AclPermissionAny matches both permission types in filters.
+	AclPermissionAny   AclPermissionType = 1
+	AclPermissionDeny  AclPermissionType = 2
+	AclPermissionAllow AclPermissionType = 3
+)
+
+// Resource names a resource ACLs apply to. The name of the cluster resource
+// is "kafka-cluster", and a name of "*" stands for every resource of the type.
+type Resource struct {
+	ResourceType AclResourceType
+	ResourceName string
+}
+
+// Acl allows or denies a principal, such as "User:alice", an operation from a
+// host, which is "*" for any host.
+type Acl struct {
+	Principal      string
+	Host           string
+	Operation      AclOperation
+	PermissionType AclPermissionType
+}
+
+// AclFilter matches ACLs and their resources. Nil names, principals and hosts
+// match any, as do the Any types, operation and permission type.
+type AclFilter struct {
+	ResourceType   AclResourceType
+	ResourceName   *string
+	Principal      *string
+	Host           *string
+	Operation      AclOperation
+	PermissionType AclPermissionType
+}
+
+func (r *Resource) encode(pe packetEncoder) error {
+	pe.putInt8(int8(r.ResourceType))
+	return pe.putString(r.ResourceName)
+}
+
+func (r *Resource) decode(pd packetDecoder) (err error) {
+	t, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	r.ResourceType = AclResourceType(t)
+	r.ResourceName, err = pd.getString()
+	return err
+}
+
+func (a *Acl) encode(pe packetEncoder) error {
+	if err := pe.putString(a.Principal); err != nil {
+		return err
+	}
+	if err := pe.putString(a.Host); err != nil {
+		return err
+	}
+	pe.putInt8(int8(a.Operation))
+	pe.putInt8(int8(a.PermissionType))
+	return nil
+}
+
+func (a *Acl) decode(pd packetDecoder) (err error) {
+	if a.Principal, err = pd.getString(); err != nil {
+		return err
+	}
+	if a.Host, err = pd.getString(); err != nil {
+		return err
+	}
+	operation, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	a.Operation = AclOperation(operation)
+	permission, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	a.PermissionType = AclPermissionType(permission)
+	return nil
+}
+
+func (f *AclFilter) encode(pe packetEncoder) error {
+	pe.putInt8(int8(f.ResourceType))
+	if err := putNullableString(pe, f.ResourceName); err != nil {
+		return err
+	}
+	if err := putNullableString(pe, f.Principal); err != nil {
+		return err
+	}
+	if err := putNullableString(pe, f.Host); err != nil {
+		return err
+	}
+	pe.putInt8(int8(f.Operation))
+	pe.putInt8(int8(f.PermissionType))
+	return nil
+}
+
+func (f *AclFilter) decode(pd packetDecoder) (err error) {
+	t, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	f.ResourceType = AclResourceType(t)
+	if f.ResourceName, err = getNullableString(pd); err != nil {
+		return err
+	}
+	if f.Principal, err = getNullableString(pd); err != nil {
+		return err
+	}
+	if f.Host, err = getNullableString(pd); err != nil {
+		return err
+	}
+	operation, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	f.Operation = AclOperation(operation)
+	permission, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	f.PermissionType = AclPermissionType(permission)
+	return nil
+}
@@ -0,0 +1,43 @@
+package sarama
+
+// AddOffsetsToTxnRequest adds the offsets of a consumer group to the ongoing
+// transaction of a transactional producer, before it commits them with a
+// TxnOffsetCommitRequest, and has to be sent to the coordinator of its
+// transactional ID. It requires Kafka 0.11.
+type AddOffsetsToTxnRequest struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	GroupID         string
+}
+
+func (r *AddOffsetsToTxnRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.TransactionalID); err != nil {
+		return err
+	}
+	pe.putInt64(r.ProducerID)
+	pe.putInt16(r.ProducerEpoch)
+	return pe.putString(r.GroupID)
+}
+
+func (r *AddOffsetsToTxnRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if r.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+	r.GroupID, err = pd.getString()
+	return err
+}
+
+func (r *AddOffsetsToTxnRequest) key() int16 {
+	return 25
+}
+
+func (r *AddOffsetsToTxnRequest) version() int16 {
+	return 0
+}
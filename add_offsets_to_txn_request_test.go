@@ -0,0 +1,15 @@
+package sarama
+
+import "testing"
+
+var addOffsetsToTxnRequest = []byte{
+	0, 3, 't', 'x', 'n',
+	0, 0, 0, 0, 0, 0, 31, 64, // ProducerID
+	0, 1, // ProducerEpoch
+	0, 5, 'g', 'r', 'o', 'u', 'p',
+}
+
+func TestAddOffsetsToTxnRequest(t *testing.T) {
+	request := &AddOffsetsToTxnRequest{TransactionalID: "txn", ProducerID: 8000, ProducerEpoch: 1, GroupID: "group"}
+	testRequest(t, "", request, addOffsetsToTxnRequest)
+}
@@ -0,0 +1,16 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var addOffsetsToTxnResponse = []byte{
+	0, 0, 0, 100, // ThrottleTime
+	0, 51, // ErrConcurrentTransactions
+}
+
+func TestAddOffsetsToTxnResponse(t *testing.T) {
+	response := &AddOffsetsToTxnResponse{ThrottleTime: 100 * time.Millisecond, Err: ErrConcurrentTransactions}
+	testResponse(t, "", response, addOffsetsToTxnResponse)
+}
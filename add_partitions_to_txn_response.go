@@ -0,0 +1,96 @@
+package sarama
+
+import "time"
+
+// PartitionError is the error of a partition in a response about several
+// partitions.
+type PartitionError struct {
+	Partition int32
+	Err       KError
+}
+
+func (e *PartitionError) encode(pe packetEncoder) error {
+	pe.putInt32(e.Partition)
+	pe.putInt16(int16(e.Err))
+	return nil
+}
+
+func (e *PartitionError) decode(pd packetDecoder) (err error) {
+	if e.Partition, err = pd.getInt32(); err != nil {
+		return err
+	}
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	e.Err = KError(kerr)
+	return nil
+}
+
+type AddPartitionsToTxnResponse struct {
+	ThrottleTime time.Duration
+	Errors       map[string][]*PartitionError
+}
+
+func (r *AddPartitionsToTxnResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	return encodePartitionErrors(pe, r.Errors)
+}
+
+func (r *AddPartitionsToTxnResponse) decode(pd packetDecoder) (err error) {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	r.Errors, err = decodePartitionErrors(pd)
+	return err
+}
+
+func encodePartitionErrors(pe packetEncoder, topics map[string][]*PartitionError) error {
+	if err := pe.putArrayLength(len(topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			if err := partition.encode(pe); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decodePartitionErrors(pd packetDecoder) (map[string][]*PartitionError, error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return nil, err
+	}
+	topics := make(map[string][]*PartitionError, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return nil, err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return nil, err
+		}
+		partitions := make([]*PartitionError, m)
+		for j := range partitions {
+			partitions[j] = new(PartitionError)
+			if err := partitions[j].decode(pd); err != nil {
+				return nil, err
+			}
+		}
+		topics[topic] = partitions
+	}
+	return topics, nil
+}
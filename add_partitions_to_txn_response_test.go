@@ -0,0 +1,23 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var addPartitionsToTxnResponse = []byte{
+	0, 0, 0, 100, // ThrottleTime
+	0, 0, 0, 1, // 1 topic
+	0, 5, 't', 'o', 'p', 'i', 'c',
+	0, 0, 0, 1, // 1 partition
+	0, 0, 0, 2,
+	0, 48, // ErrInvalidTxnState
+}
+
+func TestAddPartitionsToTxnResponse(t *testing.T) {
+	response := &AddPartitionsToTxnResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Errors:       map[string][]*PartitionError{"topic": {{Partition: 2, Err: ErrInvalidTxnState}}},
+	}
+	testResponse(t, "", response, addPartitionsToTxnResponse)
+}
@@ -0,0 +1,536 @@
+package sarama
+
+import "strconv"
+
+// ClusterAdmin is the administrative client for Kafka: it creates and deletes
+// topics, adds partitions to them and deletes their records, and describes and alters the configuration
+// of topics and brokers, and lists and describes groups and their committed
+// offsets, and manages ACLs. It requires Version >= V0_10_1_0; adding
+// partitions requires V1_0_0_0, and deleting records, configurations and ACLs
+// V0_11_0_0. Errors
+// the brokers explain
+// are logged to LogAdmin with their explanation. You MUST call Close() on a
+// ClusterAdmin to avoid leaks.
+type ClusterAdmin interface {
+	// CreateTopic creates a topic, waiting up to Admin.Timeout for the
+	// controller to create it. With validateOnly, which requires
+	// Version >= V0_11_0_0, the request is only validated.
+	CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error
+
+	// DeleteTopic deletes a topic, waiting up to Admin.Timeout for the
+	// controller to delete it.
+	DeleteTopic(topic string) error
+
+	// CreatePartitions adds partitions to a topic until it has count of them.
+	// The assignment, if not nil, places the replicas of each new partition
+	// on the brokers of the given IDs. With validateOnly, the request is only
+	// validated.
+	CreatePartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error
+
+	// DeleteRecords deletes the records of the partitions of a topic before the
+	// given offsets, by partition, waiting up to Admin.Timeout for their
+	// replicas to delete them. An offset of -1 deletes every record up to the
+	// high watermark. The partitions whose records could be deleted have them
+	// deleted even if it returns an error for others.
+	DeleteRecords(topic string, partitionOffsets map[int32]int64) error
+
+	// DescribeConfig returns the configuration entries of a topic or broker.
+	DescribeConfig(resource ConfigResource) ([]*ConfigEntry, error)
+
+	// AlterConfig replaces the configuration overrides of a topic or broker
+	// with the given entries; overrides left out are reset to their defaults.
+	// With validateOnly, the request is only validated.
+	AlterConfig(resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error
+
+	// ListConsumerGroups returns the groups of the cluster, consumer groups and
+	// others, with the protocol type of each ("consumer" for consumer groups).
+	// Every broker is asked for the groups it coordinates.
+	ListConsumerGroups() (map[string]string, error)
+
+	// DescribeConsumerGroups returns the state, protocol and members of each of
+	// the groups, in the same order, as their coordinators describe them. The
+	// error of each group is that of its description.
+	DescribeConsumerGroups(groups []string) ([]*GroupDescription, error)
+
+	// ListConsumerGroupOffsets returns the offsets the group committed for the
+	// given partitions, by topic, which are -1 for partitions without one. If
+	// topicPartitions is empty, which requires Version >= V0_10_2_0, it returns
+	// the offsets of every partition the group committed one for.
+	ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*OffsetFetchResponse, error)
+
+	// CreateACL creates an ACL on a resource.
+	CreateACL(resource Resource, acl Acl) error
+
+	// ListAcls returns the ACLs the filter matches, by resource.
+	ListAcls(filter AclFilter) ([]*ResourceAcls, error)
+
+	// DeleteACL deletes the ACLs the filter matches, and returns them. If some
+	// of them could not be deleted, it returns them all with the first error,
+	// and the error of each is that of its deletion.
+	DeleteACL(filter AclFilter) ([]*MatchingAcl, error)
+
+	// Close closes the ClusterAdmin, and its client if it created it.
+	Close() error
+}
+
+type clusterAdmin struct {
+	client    Client
+	conf      *Config
+	ownClient bool
+}
+
+// NewClusterAdmin creates a new ClusterAdmin using the given broker addresses and configuration.
+func NewClusterAdmin(addrs []string, conf *Config) (ClusterAdmin, error) {
+	client, err := NewClient(addrs, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	admin, err := NewClusterAdminFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	admin.(*clusterAdmin).ownClient = true
+	return admin, nil
+}
+
+// NewClusterAdminFromClient creates a new ClusterAdmin using the given client.
+// It is still necessary to call Close() on the underlying client after closing
+// the admin.
+func NewClusterAdminFromClient(client Client) (ClusterAdmin, error) {
+	// Check that we are not dealing with a closed Client before processing any other arguments
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	conf := client.Config()
+	if !conf.Version.IsAtLeast(V0_10_1_0) {
+		return nil, ConfigurationError("ClusterAdmin requires Version >= V0_10_1_0")
+	}
+
+	return &clusterAdmin{client: client, conf: conf}, nil
+}
+
+func (ca *clusterAdmin) Close() error {
+	if ca.ownClient {
+		return ca.client.Close()
+	}
+	return nil
+}
+
+func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	if detail == nil {
+		return ConfigurationError("CreateTopic requires a TopicDetail")
+	}
+
+	request := &CreateTopicsRequest{
+		TopicDetails: map[string]*TopicDetail{topic: detail},
+		Timeout:      ca.conf.Admin.Timeout,
+		ValidateOnly: validateOnly,
+	}
+	switch {
+	case ca.conf.Version.IsAtLeast(V1_0_0_0):
+		request.Version = 2
+	case ca.conf.Version.IsAtLeast(V0_11_0_0):
+		request.Version = 1
+	case validateOnly:
+		return ConfigurationError("CreateTopic with validateOnly requires Version >= V0_11_0_0")
+	}
+
+	return ca.retryOnController(func(controller *Broker) error {
+		response, err := controller.CreateTopics(request)
+		if err != nil {
+			return err
+		}
+		return ca.topicErr("create topic", topic, response.TopicErrors[topic])
+	})
+}
+
+func (ca *clusterAdmin) DeleteTopic(topic string) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+
+	request := &DeleteTopicsRequest{Topics: []string{topic}, Timeout: ca.conf.Admin.Timeout}
+	if ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		request.Version = 1
+	}
+
+	return ca.retryOnController(func(controller *Broker) error {
+		response, err := controller.DeleteTopics(request)
+		if err != nil {
+			return err
+		}
+		kerr, ok := response.TopicErrorCodes[topic]
+		if !ok {
+			return ErrIncompleteResponse
+		}
+		if kerr != ErrNoError {
+			return kerr
+		}
+		return nil
+	})
+}
+
+func (ca *clusterAdmin) CreatePartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	if !ca.conf.Version.IsAtLeast(V1_0_0_0) {
+		return ConfigurationError("CreatePartitions requires Version >= V1_0_0_0")
+	}
+
+	request := &CreatePartitionsRequest{
+		TopicPartitions: map[string]*TopicPartition{topic: {Count: count, Assignment: assignment}},
+		Timeout:         ca.conf.Admin.Timeout,
+		ValidateOnly:    validateOnly,
+	}
+
+	return ca.retryOnController(func(controller *Broker) error {
+		response, err := controller.CreatePartitions(request)
+		if err != nil {
+			return err
+		}
+		return ca.topicErr("create partitions", topic, response.TopicPartitionErrors[topic])
+	})
+}
+
+func (ca *clusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) error {
+	if topic == "" {
+		return ErrInvalidTopic
+	}
+	if !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return ConfigurationError("DeleteRecords requires Version >= V0_11_0_0")
+	}
+
+	// the records are deleted by the leaders of the partitions
+	requests := make(map[*Broker]*DeleteRecordsRequest)
+	for partition, offset := range partitionOffsets {
+		leader, err := ca.client.Leader(topic, partition)
+		if err != nil {
+			return err
+		}
+		request := requests[leader]
+		if request == nil {
+			request = &DeleteRecordsRequest{Topics: map[string]map[int32]int64{topic: {}}, Timeout: ca.conf.Admin.Timeout}
+			requests[leader] = request
+		}
+		request.Topics[topic][partition] = offset
+	}
+
+	var firstErr error
+	for leader, request := range requests {
+		response, err := leader.DeleteRecords(request)
+		if err == nil {
+			for partition := range request.Topics[topic] {
+				result := response.Topics[topic][partition]
+				if result == nil {
+					err = ErrIncompleteResponse
+				} else if result.Err != ErrNoError {
+					err = result.Err
+				}
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (ca *clusterAdmin) DescribeConfig(resource ConfigResource) ([]*ConfigEntry, error) {
+	if !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return nil, ConfigurationError("DescribeConfig requires Version >= V0_11_0_0")
+	}
+	broker, err := ca.configBroker(resource.Type, resource.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := broker.DescribeConfigs(&DescribeConfigsRequest{Resources: []*ConfigResource{&resource}})
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range response.Resources {
+		if result.Type == resource.Type && result.Name == resource.Name {
+			if err := ca.resourceErr("describe config", result); err != nil {
+				return nil, err
+			}
+			return result.Configs, nil
+		}
+	}
+	return nil, ErrIncompleteResponse
+}
+
+func (ca *clusterAdmin) AlterConfig(resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error {
+	if !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return ConfigurationError("AlterConfig requires Version >= V0_11_0_0")
+	}
+	broker, err := ca.configBroker(resourceType, name)
+	if err != nil {
+		return err
+	}
+
+	request := &AlterConfigsRequest{
+		Resources:    []*AlterConfigsResource{{Type: resourceType, Name: name, ConfigEntries: entries}},
+		ValidateOnly: validateOnly,
+	}
+	response, err := broker.AlterConfigs(request)
+	if err != nil {
+		return err
+	}
+	for _, result := range response.Resources {
+		if result.Type == resourceType && result.Name == name {
+			return ca.resourceErr("alter config", result)
+		}
+	}
+	return ErrIncompleteResponse
+}
+
+func (ca *clusterAdmin) ListConsumerGroups() (map[string]string, error) {
+	groups := make(map[string]string)
+	for _, broker := range ca.client.Brokers() {
+		_ = broker.Open(ca.conf)
+		response, err := broker.ListGroups(&ListGroupsRequest{})
+		if err != nil {
+			return nil, err
+		}
+		if response.Err != ErrNoError {
+			return nil, response.Err
+		}
+		for group, protocolType := range response.Groups {
+			groups[group] = protocolType
+		}
+	}
+	return groups, nil
+}
+
+func (ca *clusterAdmin) DescribeConsumerGroups(groups []string) ([]*GroupDescription, error) {
+	byCoordinator := make(map[*Broker][]string)
+	for _, group := range groups {
+		coordinator, err := ca.client.Coordinator(group)
+		if err != nil {
+			return nil, err
+		}
+		byCoordinator[coordinator] = append(byCoordinator[coordinator], group)
+	}
+
+	descriptions := make(map[string]*GroupDescription, len(groups))
+	for coordinator, groups := range byCoordinator {
+		response, err := coordinator.DescribeGroups(&DescribeGroupsRequest{Groups: groups})
+		if err != nil {
+			return nil, err
+		}
+		for _, description := range response.Groups {
+			descriptions[description.GroupId] = description
+		}
+	}
+
+	result := make([]*GroupDescription, len(groups))
+	for i, group := range groups {
+		if result[i] = descriptions[group]; result[i] == nil {
+			return nil, ErrIncompleteResponse
+		}
+	}
+	return result, nil
+}
+
+func (ca *clusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*OffsetFetchResponse, error) {
+	request := &OffsetFetchRequest{ConsumerGroup: group, Version: 1}
+	switch {
+	case ca.conf.Version.IsAtLeast(V0_10_2_0):
+		request.Version = 2
+	case len(topicPartitions) == 0:
+		return nil, ConfigurationError("ListConsumerGroupOffsets of every partition requires Version >= V0_10_2_0")
+	}
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			request.AddPartition(topic, partition)
+		}
+	}
+
+	var response *OffsetFetchResponse
+	err := ca.retryOnCoordinator(group, func(coordinator *Broker) (err error) {
+		if response, err = coordinator.FetchOffset(request); err != nil {
+			return err
+		}
+		if response.Err != ErrNoError {
+			return response.Err
+		}
+		// before version 2, the partitions hold the errors of the request
+		for _, partitions := range response.Blocks {
+			for _, block := range partitions {
+				if block.Err == ErrNotCoordinatorForConsumer {
+					return block.Err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (ca *clusterAdmin) CreateACL(resource Resource, acl Acl) error {
+	if !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return ConfigurationError("CreateACL requires Version >= V0_11_0_0")
+	}
+	controller, err := ca.client.Controller()
+	if err != nil {
+		return err
+	}
+
+	response, err := controller.CreateAcls(&CreateAclsRequest{AclCreations: []*AclCreation{{Resource: resource, Acl: acl}}})
+	if err != nil {
+		return err
+	}
+	if len(response.AclCreationResponses) != 1 {
+		return ErrIncompleteResponse
+	}
+	result := response.AclCreationResponses[0]
+	return ca.aclErr("create acl", resource.ResourceName, result.Err, result.ErrMsg)
+}
+
+func (ca *clusterAdmin) ListAcls(filter AclFilter) ([]*ResourceAcls, error) {
+	if !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return nil, ConfigurationError("ListAcls requires Version >= V0_11_0_0")
+	}
+	controller, err := ca.client.Controller()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := controller.DescribeAcls(&DescribeAclsRequest{AclFilter: filter})
+	if err != nil {
+		return nil, err
+	}
+	if err := ca.aclErr("list acls", "", response.Err, response.ErrMsg); err != nil {
+		return nil, err
+	}
+	return response.ResourceAcls, nil
+}
+
+func (ca *clusterAdmin) DeleteACL(filter AclFilter) ([]*MatchingAcl, error) {
+	if !ca.conf.Version.IsAtLeast(V0_11_0_0) {
+		return nil, ConfigurationError("DeleteACL requires Version >= V0_11_0_0")
+	}
+	controller, err := ca.client.Controller()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := controller.DeleteAcls(&DeleteAclsRequest{Filters: []*AclFilter{&filter}})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.FilterResponses) != 1 {
+		return nil, ErrIncompleteResponse
+	}
+	result := response.FilterResponses[0]
+	if err := ca.aclErr("delete acls", "", result.Err, result.ErrMsg); err != nil {
+		return nil, err
+	}
+	for _, matching := range result.MatchingAcls {
+		if err := ca.aclErr("delete acl", matching.ResourceName, matching.Err, matching.ErrMsg); err != nil {
+			return result.MatchingAcls, err
+		}
+	}
+	return result.MatchingAcls, nil
+}
+
+// retryOnCoordinator calls fn with the coordinator of the group, and once more
+// with the new coordinator if the broker turns out not to coordinate the group
+// any more.
+func (ca *clusterAdmin) retryOnCoordinator(group string, fn func(coordinator *Broker) error) error {
+	for attempt := 0; ; attempt++ {
+		coordinator, err := ca.client.Coordinator(group)
+		if err != nil {
+			return err
+		}
+
+		err = fn(coordinator)
+		if err != ErrNotCoordinatorForConsumer || attempt > 0 {
+			return err
+		}
+		LogAdmin.warn("coordinator moved, refreshing it", "group", group, "broker", coordinator.ID())
+		if err := ca.client.RefreshCoordinator(group); err != nil {
+			return err
+		}
+	}
+}
+
+// retryOnController calls fn with the controller, and once more with the new
+// controller if the broker turns out not to be the controller any more.
+func (ca *clusterAdmin) retryOnController(fn func(controller *Broker) error) error {
+	for attempt := 0; ; attempt++ {
+		controller, err := ca.client.Controller()
+		if err != nil {
+			return err
+		}
+
+		err = fn(controller)
+		if err != ErrNotController || attempt > 0 {
+			return err
+		}
+		LogAdmin.warn("controller moved, refreshing metadata", "broker", controller.ID())
+		if err := ca.client.RefreshMetadata(); err != nil {
+			return err
+		}
+	}
+}
+
+// configBroker returns the broker to send configuration requests about the
+// resource to: the broker itself for broker resources, which only it can
+// answer for, and otherwise the controller.
+func (ca *clusterAdmin) configBroker(resourceType ConfigResourceType, name string) (*Broker, error) {
+	if resourceType != BrokerResource {
+		return ca.client.Controller()
+	}
+	id, err := strconv.ParseInt(name, 10, 32)
+	if err != nil {
+		return nil, ConfigurationError("the name of a BrokerResource must be the ID of the broker")
+	}
+	return ca.client.Broker(int32(id))
+}
+
+func (ca *clusterAdmin) topicErr(op, topic string, result *TopicError) error {
+	if result == nil {
+		return ErrIncompleteResponse
+	}
+	if result.Err == ErrNoError {
+		return nil
+	}
+	if result.ErrMsg != nil {
+		LogAdmin.error("failed to "+op, "topic", topic, "err", result.Err, "message", *result.ErrMsg)
+	}
+	return result.Err
+}
+
+func (ca *clusterAdmin) resourceErr(op string, result *ResourceResponse) error {
+	if result.Err == ErrNoError {
+		return nil
+	}
+	if result.ErrMsg != nil {
+		LogAdmin.error("failed to "+op, "resource", result.Name, "err", result.Err, "message", *result.ErrMsg)
+	}
+	return result.Err
+}
+
+func (ca *clusterAdmin) aclErr(op, resource string, kerr KError, msg *string) error {
+	if kerr == ErrNoError {
+		return nil
+	}
+	if msg != nil {
+		LogAdmin.error("failed to "+op, "resource", resource, "err", kerr, "message", *msg)
+	}
+	return kerr
+}
@@ -0,0 +1,317 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+func newClusterAdminForTest(t *testing.T, handlers map[string]MockResponse) (*MockBroker, ClusterAdmin) {
+	controller := NewMockBroker(t, 1)
+	handlers["MetadataRequest"] = NewMockMetadataResponse(t).
+		SetBroker(controller.Addr(), controller.BrokerID()).
+		SetController(controller.BrokerID())
+	controller.SetHandlerByMap(handlers)
+
+	config := NewConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{controller.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return controller, admin
+}
+
+func lastAdminRequest(t *testing.T, mb *MockBroker) requestBody {
+	history := mb.History()
+	if len(history) == 0 {
+		t.Fatal("Expected a request to the controller")
+	}
+	return history[len(history)-1].Request.(requestBody)
+}
+
+func TestClusterAdminCreateTopic(t *testing.T) {
+	controller, admin := newClusterAdminForTest(t, map[string]MockResponse{
+		"CreateTopicsRequest": NewMockWrapper(&CreateTopicsResponse{Version: 2, TopicErrors: map[string]*TopicError{"my_topic": {Err: ErrNoError}}}),
+	})
+	defer controller.Close()
+	defer safeClose(t, admin)
+
+	if err := admin.CreateTopic("my_topic", &TopicDetail{NumPartitions: 3, ReplicationFactor: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+	request := lastAdminRequest(t, controller).(*CreateTopicsRequest)
+	if request.Version != 2 || request.Timeout != 3*time.Second || request.TopicDetails["my_topic"].NumPartitions != 3 {
+		t.Error("Expected a version 2 request for the topic within Admin.Timeout, got", request)
+	}
+
+	if err := admin.CreateTopic("", &TopicDetail{}, false); err != ErrInvalidTopic {
+		t.Error("Expected a topic without a name to be rejected, got", err)
+	}
+}
+
+func TestClusterAdminCreateTopicErrors(t *testing.T) {
+	msg := "Topic 'my_topic' already exists."
+	controller, admin := newClusterAdminForTest(t, map[string]MockResponse{
+		"CreateTopicsRequest": NewMockWrapper(&CreateTopicsResponse{Version: 2, TopicErrors: map[string]*TopicError{"my_topic": {Err: ErrTopicAlreadyExists, ErrMsg: &msg}}}),
+	})
+	defer controller.Close()
+	defer safeClose(t, admin)
+
+	if err := admin.CreateTopic("my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); err != ErrTopicAlreadyExists {
+		t.Error("Expected the topic to exist already, got", err)
+	}
+	if err := admin.CreateTopic("other_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); err != ErrIncompleteResponse {
+		t.Error("Expected a response without the topic to be incomplete, got", err)
+	}
+}
+
+func TestClusterAdminRetriesMovedController(t *testing.T) {
+	controller, admin := newClusterAdminForTest(t, map[string]MockResponse{
+		"DeleteTopicsRequest": NewMockSequence(
+			&DeleteTopicsResponse{Version: 1, TopicErrorCodes: map[string]KError{"my_topic": ErrNotController}},
+			&DeleteTopicsResponse{Version: 1, TopicErrorCodes: map[string]KError{"my_topic": ErrNoError}},
+		),
+	})
+	defer controller.Close()
+	defer safeClose(t, admin)
+
+	if err := admin.DeleteTopic("my_topic"); err != nil {
+		t.Fatal(err)
+	}
+	var deletes, metadata int
+	for _, rr := range controller.History() {
+		switch rr.Request.(type) {
+		case *DeleteTopicsRequest:
+			deletes++
+		case *MetadataRequest:
+			metadata++
+		}
+	}
+	if deletes != 2 || metadata != 2 {
+		t.Error("Expected the metadata to be refreshed and the delete to be retried, got", metadata, deletes)
+	}
+}
+
+func TestClusterAdminCreatePartitions(t *testing.T) {
+	controller, admin := newClusterAdminForTest(t, map[string]MockResponse{
+		"CreatePartitionsRequest": NewMockWrapper(&CreatePartitionsResponse{TopicPartitionErrors: map[string]*TopicError{"my_topic": {Err: ErrNoError}}}),
+	})
+	defer controller.Close()
+	defer safeClose(t, admin)
+
+	if err := admin.CreatePartitions("my_topic", 4, [][]int32{{1}}, true); err != nil {
+		t.Fatal(err)
+	}
+	request := lastAdminRequest(t, controller).(*CreatePartitionsRequest)
+	if partition := request.TopicPartitions["my_topic"]; partition.Count != 4 || len(partition.Assignment) != 1 || !request.ValidateOnly {
+		t.Error("Expected a validating request for 4 partitions, got", request)
+	}
+}
+
+func TestClusterAdminDeleteRecords(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 2)
+	for i := 0; i < 3; i++ {
+		cluster.AddMessage("my_topic", 0, nil, StringEncoder("value"))
+		cluster.AddMessage("my_topic", 1, nil, StringEncoder("value"))
+	}
+
+	config := newMockClusterConfig()
+	config.Version = V0_11_0_0
+	client, err := NewClient(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+	admin, err := NewClusterAdminFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	if err := admin.DeleteRecords("my_topic", map[int32]int64{0: 2, 1: -1}); err != nil {
+		t.Fatal(err)
+	}
+	for partition, expected := range map[int32]int64{0: 2, 1: 3} {
+		if oldest, err := client.GetOffset("my_topic", partition, OffsetOldest); err != nil || oldest != expected {
+			t.Errorf("Expected the oldest offset of partition %d to be %d, got %d (%v)", partition, expected, oldest, err)
+		}
+	}
+
+	if err := admin.DeleteRecords("my_topic", map[int32]int64{0: 42}); err != ErrOffsetOutOfRange {
+		t.Error("Expected deleting past the high watermark to be refused, got", err)
+	}
+}
+
+func TestClusterAdminConfigs(t *testing.T) {
+	retention := "86400000"
+	controller, admin := newClusterAdminForTest(t, map[string]MockResponse{
+		"DescribeConfigsRequest": NewMockWrapper(&DescribeConfigsResponse{Resources: []*ResourceResponse{{
+			Type:    TopicResource,
+			Name:    "my_topic",
+			Configs: []*ConfigEntry{{Name: "retention.ms", Value: &retention}},
+		}}}),
+		"AlterConfigsRequest": NewMockWrapper(&AlterConfigsResponse{Resources: []*ResourceResponse{
+			{Err: ErrInvalidConfig, Type: BrokerResource, Name: "1"},
+		}}),
+	})
+	defer controller.Close()
+	defer safeClose(t, admin)
+
+	entries, err := admin.DescribeConfig(ConfigResource{Type: TopicResource, Name: "my_topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "retention.ms" || *entries[0].Value != retention {
+		t.Error("Expected the retention of the topic, got", entries)
+	}
+
+	if err := admin.AlterConfig(BrokerResource, "1", map[string]*string{"log.retention.ms": &retention}, false); err != ErrInvalidConfig {
+		t.Error("Expected the broker to refuse its config, got", err)
+	}
+	if err := admin.AlterConfig(BrokerResource, "42", nil, false); err != ErrBrokerNotFound {
+		t.Error("Expected the configs of an unknown broker to be refused, got", err)
+	}
+}
+
+func TestClusterAdminAcls(t *testing.T) {
+	msg := "msg"
+	resource := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
+	acl := Acl{Principal: "User:alice", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow}
+	controller, admin := newClusterAdminForTest(t, map[string]MockResponse{
+		"CreateAclsRequest": NewMockWrapper(&CreateAclsResponse{AclCreationResponses: []*AclCreationResponse{{Err: ErrSecurityDisabled, ErrMsg: &msg}}}),
+		"DescribeAclsRequest": NewMockWrapper(&DescribeAclsResponse{ResourceAcls: []*ResourceAcls{
+			{Resource: resource, Acls: []*Acl{&acl}},
+		}}),
+		"DeleteAclsRequest": NewMockWrapper(&DeleteAclsResponse{FilterResponses: []*FilterResponse{{MatchingAcls: []*MatchingAcl{
+			{Resource: resource, Acl: acl},
+			{Err: ErrClusterAuthorizationFailed, Resource: Resource{ResourceType: AclResourceCluster, ResourceName: "kafka-cluster"}, Acl: acl},
+		}}}}),
+	})
+	defer controller.Close()
+	defer safeClose(t, admin)
+
+	if err := admin.CreateACL(resource, acl); err != ErrSecurityDisabled {
+		t.Error("Expected the broker to refuse the ACL, got", err)
+	}
+	request := lastAdminRequest(t, controller).(*CreateAclsRequest)
+	if len(request.AclCreations) != 1 || request.AclCreations[0].Resource != resource || request.AclCreations[0].Acl != acl {
+		t.Error("Expected a request creating the ACL, got", request)
+	}
+
+	filter := AclFilter{ResourceType: AclResourceAny, Operation: AclOperationAny, PermissionType: AclPermissionAny}
+	acls, err := admin.ListAcls(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acls) != 1 || acls[0].Resource != resource || len(acls[0].Acls) != 1 || *acls[0].Acls[0] != acl {
+		t.Error("Expected the ACL of the topic, got", acls)
+	}
+
+	matching, err := admin.DeleteACL(filter)
+	if err != ErrClusterAuthorizationFailed {
+		t.Error("Expected the deletion of the cluster ACL to be refused, got", err)
+	}
+	if len(matching) != 2 || matching[0].Err != ErrNoError || matching[0].Resource != resource {
+		t.Error("Expected the ACLs the filter matched, got", matching)
+	}
+}
+
+func TestClusterAdminConsumerGroups(t *testing.T) {
+	cluster := NewMockCluster(t, 3)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 2)
+
+	coordinator := openMockClusterCoordinator(t, cluster, "my_group")
+	defer safeClose(t, coordinator)
+	commit := &OffsetCommitRequest{ConsumerGroup: "my_group", ConsumerGroupGeneration: GroupGenerationUndefined, Version: 1}
+	commit.AddBlock("my_topic", 1, 42, 0, "md")
+	if _, err := coordinator.CommitOffset(commit); err != nil {
+		t.Fatal(err)
+	}
+
+	config := newMockClusterConfig()
+	config.Version = V0_10_2_0
+	admin, err := NewClusterAdmin(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	groups, err := admin.ListConsumerGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := groups["my_group"]; !ok || len(groups) != 1 {
+		t.Error("Expected the group to be listed, got", groups)
+	}
+
+	descriptions, err := admin.DescribeConsumerGroups([]string{"my_group", "other_group"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descriptions) != 2 || descriptions[0].GroupId != "my_group" || descriptions[0].State != "Empty" {
+		t.Error("Expected the group to be described as empty, got", descriptions)
+	}
+	if descriptions[1].GroupId != "other_group" || descriptions[1].State != "Dead" {
+		t.Error("Expected the unknown group to be described as dead, got", descriptions[1])
+	}
+
+	offsets, err := admin.ListConsumerGroupOffsets("my_group", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block := offsets.GetBlock("my_topic", 1); block == nil || block.Offset != 42 || block.Metadata != "md" || len(offsets.Blocks["my_topic"]) != 1 {
+		t.Error("Expected the committed offset of every partition, got", offsets.Blocks)
+	}
+
+	offsets, err = admin.ListConsumerGroupOffsets("my_group", map[string][]int32{"my_topic": {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block := offsets.GetBlock("my_topic", 0); block == nil || block.Offset != -1 {
+		t.Error("Expected no offset for partition 0, got", block)
+	}
+	if block := offsets.GetBlock("my_topic", 1); block == nil || block.Offset != 42 {
+		t.Error("Expected the committed offset of partition 1, got", block)
+	}
+}
+
+func TestClusterAdminRequiresVersion(t *testing.T) {
+	mb := NewMockBroker(t, 1)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).SetBroker(mb.Addr(), mb.BrokerID()),
+	})
+
+	if _, err := NewClusterAdmin([]string{mb.Addr()}, nil); err == nil {
+		t.Error("Expected a ClusterAdmin to be refused on the default Version")
+	}
+
+	config := NewConfig()
+	config.Version = V0_10_1_0
+	admin, err := NewClusterAdmin([]string{mb.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+	if err := admin.CreateTopic("my_topic", &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, true); err == nil {
+		t.Error("Expected validateOnly to be refused before V0_11_0_0")
+	}
+	if err := admin.CreatePartitions("my_topic", 2, nil, false); err == nil {
+		t.Error("Expected CreatePartitions to be refused before V1_0_0_0")
+	}
+	if _, err := admin.DescribeConfig(ConfigResource{Type: TopicResource, Name: "my_topic"}); err == nil {
+		t.Error("Expected DescribeConfig to be refused before V0_11_0_0")
+	}
+	if _, err := admin.ListConsumerGroupOffsets("my_group", nil); err == nil {
+		t.Error("Expected the offsets of every partition to be refused before V0_10_2_0")
+	}
+	if err := admin.DeleteRecords("my_topic", map[int32]int64{0: 1}); err == nil {
+		t.Error("Expected DeleteRecords to be refused before V0_11_0_0")
+	}
+	if err := admin.CreateACL(Resource{}, Acl{}); err == nil {
+		t.Error("Expected CreateACL to be refused before V0_11_0_0")
+	}
+}
@@ -0,0 +1,90 @@
+package sarama
+
+// AlterConfigsRequest replaces the configuration overrides of topics and
+// brokers. It requires Kafka 0.11. Entries left out of a resource are reset to
+// their defaults. Broker resources have to be altered on the broker itself.
+type AlterConfigsRequest struct {
+	Resources []*AlterConfigsResource
+	// Whether to only validate the request, without altering anything.
+	ValidateOnly bool
+}
+
+// AlterConfigsResource is a resource to alter the configuration of.
+type AlterConfigsResource struct {
+	Type ConfigResourceType
+	// The topic, or the ID of the broker in decimal.
+	Name          string
+	ConfigEntries map[string]*string
+}
+
+func (r *AlterConfigsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+		return err
+	}
+	for _, resource := range r.Resources {
+		pe.putInt8(int8(resource.Type))
+		if err := pe.putString(resource.Name); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(resource.ConfigEntries)); err != nil {
+			return err
+		}
+		for name, value := range resource.ConfigEntries {
+			if err := pe.putString(name); err != nil {
+				return err
+			}
+			if err := putNullableString(pe, value); err != nil {
+				return err
+			}
+		}
+	}
+	putBool(pe, r.ValidateOnly)
+	return nil
+}
+
+func (r *AlterConfigsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Resources = make([]*AlterConfigsResource, n)
+	for i := range r.Resources {
+		resource := new(AlterConfigsResource)
+		t, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		resource.Type = ConfigResourceType(t)
+		if resource.Name, err = pd.getString(); err != nil {
+			return err
+		}
+
+		entries, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if entries > 0 {
+			resource.ConfigEntries = make(map[string]*string, entries)
+		}
+		for j := 0; j < entries; j++ {
+			name, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			if resource.ConfigEntries[name], err = getNullableString(pd); err != nil {
+				return err
+			}
+		}
+		r.Resources[i] = resource
+	}
+	r.ValidateOnly, err = getBool(pd)
+	return err
+}
+
+func (r *AlterConfigsRequest) key() int16 {
+	return 33
+}
+
+func (r *AlterConfigsRequest) version() int16 {
+	return 0
+}
@@ -0,0 +1,26 @@
+package sarama
+
+import "testing"
+
+var (
+	alterConfigsRequest = []byte{
+		0, 0, 0, 1,
+		2, // TopicResource
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 1,
+		0, 12, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+		0, 2, '-', '1',
+		1, // ValidateOnly
+	}
+)
+
+func TestAlterConfigsRequest(t *testing.T) {
+	retention := "-1"
+	request := &AlterConfigsRequest{
+		Resources: []*AlterConfigsResource{
+			{Type: TopicResource, Name: "topic", ConfigEntries: map[string]*string{"retention.ms": &retention}},
+		},
+		ValidateOnly: true,
+	}
+	testRequest(t, "topic", request, alterConfigsRequest)
+}
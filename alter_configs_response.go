@@ -0,0 +1,44 @@
+package sarama
+
+import "time"
+
+type AlterConfigsResponse struct {
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota.
+	ThrottleTime time.Duration
+	Resources    []*ResourceResponse
+}
+
+func (r *AlterConfigsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+		return err
+	}
+	for _, resource := range r.Resources {
+		if err := resource.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *AlterConfigsResponse) decode(pd packetDecoder) error {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Resources = make([]*ResourceResponse, n)
+	for i := range r.Resources {
+		r.Resources[i] = new(ResourceResponse)
+		if err := r.Resources[i].decode(pd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	alterConfigsResponse = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, 0, 1,
+		0, 40, // ErrInvalidConfig
+		0, 3, 'm', 's', 'g',
+		4, // BrokerResource
+		0, 1, '1',
+	}
+)
+
+func TestAlterConfigsResponse(t *testing.T) {
+	msg := "msg"
+	response := &AlterConfigsResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Resources:    []*ResourceResponse{{Err: ErrInvalidConfig, ErrMsg: &msg, Type: BrokerResource, Name: "1"}},
+	}
+	testResponse(t, "invalid config", response, alterConfigsResponse)
+}
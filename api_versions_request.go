@@ -0,0 +1,22 @@
+package sarama
+
+// ApiVersionsRequest asks the broker for the versions of each API it supports.
+// Brokers answer it from Kafka 0.10, even before SASL authentication.
+type ApiVersionsRequest struct {
+}
+
+func (r *ApiVersionsRequest) encode(pe packetEncoder) error {
+	return nil
+}
+
+func (r *ApiVersionsRequest) decode(pd packetDecoder) (err error) {
+	return nil
+}
+
+func (r *ApiVersionsRequest) key() int16 {
+	return 18
+}
+
+func (r *ApiVersionsRequest) version() int16 {
+	return 0
+}
@@ -0,0 +1,7 @@
+package sarama
+
+import "testing"
+
+func TestApiVersionsRequest(t *testing.T) {
+	testRequest(t, "basic", &ApiVersionsRequest{}, []byte{})
+}
@@ -0,0 +1,26 @@
+package sarama
+
+import "testing"
+
+var apiVersionsResponse = []byte{
+	0x00, 0x00,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x03, // Metadata
+	0x00, 0x00,
+	0x00, 0x02,
+}
+
+func TestApiVersionsResponse(t *testing.T) {
+	response := new(ApiVersionsResponse)
+	testDecodable(t, "one API", response, apiVersionsResponse)
+	if response.Err != ErrNoError {
+		t.Error("Decoding error failed: no error expected but found", response.Err)
+	}
+	if len(response.ApiVersions) != 1 {
+		t.Fatal("Decoding produced", len(response.ApiVersions), "APIs where there was one")
+	}
+	if block := response.ApiVersions[0]; block.ApiKey != 3 || block.MinVersion != 0 || block.MaxVersion != 2 {
+		t.Error("Decoding produced unexpected versions", block)
+	}
+	testResponse(t, "one API", response, apiVersionsResponse)
+}
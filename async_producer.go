@@ -1,12 +1,15 @@
 package sarama
 
 import (
+	"encoding/binary"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/eapache/go-resiliency/breaker"
 	"github.com/eapache/queue"
+	"github.com/rcrowley/go-metrics"
 )
 
 // AsyncProducer publishes Kafka messages using a non-blocking API. It routes messages
@@ -31,7 +34,9 @@ type AsyncProducer interface {
 	Close() error
 
 	// Input is the input channel for the user to write messages to that they
-	// wish to send.
+	// wish to send. With Producer.FlowControl, sends block while the producer
+	// has as many messages in flight as it allows, or the rate limiter holds
+	// them back.
 	Input() chan<- *ProducerMessage
 
 	// Successes is the success output channel back to the user when AckSuccesses is
@@ -43,22 +48,63 @@ type AsyncProducer interface {
 	// Errors is the error output channel back to the user. You MUST read from this
 	// channel or the Producer will deadlock when the channel is full. Alternatively,
 	// you can set Producer.Return.Errors in your config to false, which prevents
-	// errors to be returned.
+	// errors to be returned, or set Producer.Return.ErrorHandler to be passed them
+	// instead.
 	Errors() <-chan *ProducerError
+
+	// BeginTxn begins a transaction, which a transactional producer, configured
+	// with Producer.Transaction.ID, must do before producing messages. It
+	// returns ErrNotTransactional for other producers, and
+	// ErrTransactionNotReady while a transaction is ongoing.
+	BeginTxn() error
+
+	// CommitTxn waits for the messages of the ongoing transaction to succeed or
+	// fail, then commits the transaction, making its messages and offsets
+	// visible to consumers reading committed messages. If any of its messages
+	// failed, it returns the error without committing, and the transaction
+	// must be aborted instead. Messages sent while it waits are not part of the
+	// transaction, and fail with ErrTransactionNotReady.
+	CommitTxn() error
+
+	// AbortTxn waits for the messages of the ongoing transaction to succeed or
+	// fail, then aborts the transaction, discarding its messages and offsets.
+	AbortTxn() error
+
+	// AddOffsetsToTxn commits offsets of the consumer group in the ongoing
+	// transaction, so that they only take effect if it commits. The offsets
+	// are those of the next messages to consume, as with MarkOffset.
+	AddOffsetsToTxn(offsets map[string][]*PartitionOffsetMetadata, groupID string) error
+
+	// AddMessageToTxn commits the offset after the consumed message in the
+	// ongoing transaction, as AddOffsetsToTxn does, marking the message
+	// consumed by the group if the transaction commits.
+	AddMessageToTxn(msg *ConsumerMessage, groupID string, metadata *string) error
 }
 
 type asyncProducer struct {
+	messagesInFlight int64 // accepted but not yet returned, for snapshots; first for 64-bit alignment
+
 	client    Client
 	conf      *Config
 	ownClient bool
+	txnmgr    *transactionManager // nil unless Producer.Idempotent is set
 
 	errors                    chan *ProducerError
 	input, successes, retries chan *ProducerMessage
 	inFlight                  sync.WaitGroup
 
-	brokers    map[*Broker]chan<- *ProducerMessage
-	brokerRefs map[chan<- *ProducerMessage]int
-	brokerLock sync.Mutex
+	// flow is nil unless Producer.FlowControl is set, in which case the user
+	// sends to flowInput, which flow passes on to input
+	flow      *producerFlow
+	flowInput chan *ProducerMessage
+
+	brokers         map[*Broker]chan<- *ProducerMessage
+	brokerRefs      map[chan<- *ProducerMessage]int
+	brokerProducers map[chan<- *ProducerMessage]*brokerProducer
+	brokerLock      sync.Mutex
+
+	batchAware     map[string]BatchAwarePartitioner // by topic, told of the batches flushed
+	batchAwareLock sync.RWMutex
 }
 
 // NewAsyncProducer creates a new AsyncProducer using the given broker addresses and configuration.
@@ -70,6 +116,7 @@ func NewAsyncProducer(addrs []string, conf *Config) (AsyncProducer, error) {
 
 	p, err := NewAsyncProducerFromClient(client)
 	if err != nil {
+		_ = client.Close()
 		return nil, err
 	}
 	p.(*asyncProducer).ownClient = true
@@ -84,20 +131,36 @@ func NewAsyncProducerFromClient(client Client) (AsyncProducer, error) {
 		return nil, ErrClosedClient
 	}
 
+	var txnmgr *transactionManager
+	if client.Config().Producer.Idempotent {
+		var err error
+		if txnmgr, err = newTransactionManager(client); err != nil {
+			return nil, err
+		}
+	}
+
 	p := &asyncProducer{
-		client:     client,
-		conf:       client.Config(),
-		errors:     make(chan *ProducerError),
-		input:      make(chan *ProducerMessage),
-		successes:  make(chan *ProducerMessage),
-		retries:    make(chan *ProducerMessage),
-		brokers:    make(map[*Broker]chan<- *ProducerMessage),
-		brokerRefs: make(map[chan<- *ProducerMessage]int),
+		client:          client,
+		conf:            client.Config(),
+		txnmgr:          txnmgr,
+		errors:          make(chan *ProducerError),
+		input:           make(chan *ProducerMessage),
+		successes:       make(chan *ProducerMessage),
+		retries:         make(chan *ProducerMessage),
+		brokers:         make(map[*Broker]chan<- *ProducerMessage),
+		brokerRefs:      make(map[chan<- *ProducerMessage]int),
+		brokerProducers: make(map[chan<- *ProducerMessage]*brokerProducer),
+		batchAware:      make(map[string]BatchAwarePartitioner),
+		flow:            newProducerFlow(client.Config()),
 	}
 
 	// launch our singleton dispatchers
 	go withRecover(p.dispatcher)
 	go withRecover(p.retryHandler)
+	if p.flow != nil {
+		p.flowInput = make(chan *ProducerMessage)
+		go withRecover(func() { p.flow.run(p.flowInput, p.input) })
+	}
 
 	return p, nil
 }
@@ -108,24 +171,41 @@ const (
 	syn      flagSet = 1 << iota // first message from partitionProducer to brokerProducer
 	fin                          // final message from partitionProducer to brokerProducer and back
 	shutdown                     // start the shutdown process
+	endtxn                       // the messages of the ongoing transaction have all been sent to the dispatcher
 )
 
 // ProducerMessage is the collection of elements passed to the Producer in order to send a message.
 type ProducerMessage struct {
 	Topic string // The Kafka topic for this message.
 	// The partitioning key for this message. Pre-existing Encoders include
-	// StringEncoder and ByteEncoder.
+	// StringEncoder and ByteEncoder. A nil key is sent as null.
 	Key Encoder
 	// The actual message to store in Kafka. Pre-existing Encoders include
-	// StringEncoder and ByteEncoder.
+	// StringEncoder and ByteEncoder. A nil value is sent as null, which
+	// log-compacted topics treat as a tombstone.
 	Value Encoder
 
+	// Headers are sent with the message, and require Version >= V0_11_0_0.
+	Headers []RecordHeader
+
+	// Timestamp is the time the message was created, sent from Version
+	// V0_10_0_0. If it is zero, the producer sets it to the time it batches the
+	// message. If the topic uses LogAppendTime, the producer sets it to the time
+	// the broker appended the message once it has succeeded.
+	Timestamp time.Time
+
 	// This field is used to hold arbitrary data you wish to include so it
 	// will be available when receiving on the Successes and Errors channels.
 	// Sarama completely ignores this field and is only to be used for
 	// pass-through data.
 	Metadata interface{}
 
+	// If ManualPartition is set, the message is sent to the partition set in
+	// Partition, bypassing the configured Partitioner, as with a
+	// ManualPartitioner. The partition must exist, or the message fails with
+	// ErrInvalidPartition.
+	ManualPartition bool
+
 	// Below this point are filled in by the producer as the message is processed
 
 	// Offset is the offset of the message stored on the broker. This is only
@@ -133,11 +213,26 @@ type ProducerMessage struct {
 	// RequiredAcks is not NoResponse.
 	Offset int64
 	// Partition is the partition that the message was sent to. This is only
-	// guaranteed to be defined if the message was successfully delivered.
+	// guaranteed to be defined if the message was successfully delivered. It is
+	// set by the user instead with ManualPartition or a ManualPartitioner.
 	Partition int32
 
 	retries int
 	flags   flagSet
+	batch   *compressedBatch
+	// flowBytes is the size the message counts for against
+	// Producer.FlowControl while in flight, or 0
+	flowBytes int
+
+	// sequence is the sequence number of the message in its partition, once
+	// sequenced is set, for an idempotent producer; retries keep it unless the
+	// producer acquired a new producer ID or epoch since, as counted by
+	// generation
+	sequence   int32
+	generation int
+	sequenced  bool
+	// inTxn is set while the message counts as one of the ongoing transaction
+	inTxn bool
 }
 
 const producerMessageOverhead = 26 // the metadata overhead of CRC, flags, etc.
@@ -150,16 +245,24 @@ func (m *ProducerMessage) byteSize() int {
 	if m.Value != nil {
 		size += m.Value.Length()
 	}
+	for _, header := range m.Headers {
+		size += len(header.Key) + len(header.Value) + 2*binary.MaxVarintLen32
+	}
 	return size
 }
 
 func (m *ProducerMessage) clear() {
+	m.flowBytes = 0
 	m.flags = 0
 	m.retries = 0
+	m.batch = nil
+	m.sequenced = false
+	m.inTxn = false
 }
 
 // ProducerError is the type of error generated when the producer fails to deliver a message.
-// It contains the original ProducerMessage as well as the actual error value.
+// It contains the original ProducerMessage, with its key, value and the partition it was
+// last sent to, as well as the actual error value.
 type ProducerError struct {
 	Msg *ProducerMessage
 	Err error
@@ -169,6 +272,11 @@ func (pe ProducerError) Error() string {
 	return fmt.Sprintf("kafka: Failed to produce message to topic %s: %s", pe.Msg.Topic, pe.Err)
 }
 
+// Unwrap returns the error the message failed to deliver with.
+func (pe ProducerError) Unwrap() error {
+	return pe.Err
+}
+
 // ProducerErrors is a type that wraps a batch of "ProducerError"s and implements the Error interface.
 // It can be returned from the Producer's Close method to avoid the need to manually drain the Errors channel
 // when closing a producer.
@@ -187,6 +295,9 @@ func (p *asyncProducer) Successes() <-chan *ProducerMessage {
 }
 
 func (p *asyncProducer) Input() chan<- *ProducerMessage {
+	if p.flowInput != nil {
+		return p.flowInput
+	}
 	return p.input
 }
 
@@ -217,6 +328,53 @@ func (p *asyncProducer) AsyncClose() {
 	go withRecover(p.shutdown)
 }
 
+func (p *asyncProducer) BeginTxn() error {
+	if !p.txnmgr.transactional() {
+		return ErrNotTransactional
+	}
+	return p.txnmgr.begin()
+}
+
+func (p *asyncProducer) CommitTxn() error {
+	return p.endTxn(true)
+}
+
+func (p *asyncProducer) AbortTxn() error {
+	return p.endTxn(false)
+}
+
+func (p *asyncProducer) AddOffsetsToTxn(offsets map[string][]*PartitionOffsetMetadata, groupID string) error {
+	if !p.txnmgr.transactional() {
+		return ErrNotTransactional
+	}
+	return p.txnmgr.addOffsets(offsets, groupID)
+}
+
+func (p *asyncProducer) AddMessageToTxn(msg *ConsumerMessage, groupID string, metadata *string) error {
+	offsets := map[string][]*PartitionOffsetMetadata{
+		msg.Topic: {{Partition: msg.Partition, Offset: msg.Offset + 1, Metadata: metadata}},
+	}
+	return p.AddOffsetsToTxn(offsets, groupID)
+}
+
+// endTxn waits for the messages sent before it was called to succeed or fail,
+// then commits or aborts the ongoing transaction.
+func (p *asyncProducer) endTxn(commit bool) error {
+	if !p.txnmgr.transactional() {
+		return ErrNotTransactional
+	}
+
+	p.txnmgr.endLock.Lock()
+	defer p.txnmgr.endLock.Unlock()
+
+	// the dispatcher has counted the messages sent before once it takes the
+	// marker, and refuses those sent after until the transaction ended
+	p.Input() <- &ProducerMessage{flags: endtxn}
+	p.txnmgr.waitForMessages()
+
+	return p.txnmgr.end(commit)
+}
+
 // singleton
 // dispatches messages by topic
 func (p *asyncProducer) dispatcher() {
@@ -225,7 +383,7 @@ func (p *asyncProducer) dispatcher() {
 
 	for msg := range p.input {
 		if msg == nil {
-			Logger.Println("Something tried to send a nil message, it was ignored.")
+			LogProducer.warn("something tried to send a nil message, it was ignored")
 			continue
 		}
 
@@ -233,21 +391,36 @@ func (p *asyncProducer) dispatcher() {
 			shuttingDown = true
 			p.inFlight.Done()
 			continue
+		} else if msg.flags&endtxn != 0 {
+			p.txnmgr.stopProducing()
+			continue
 		} else if msg.retries == 0 {
 			if shuttingDown {
 				// we can't just call returnError here because that decrements the wait group,
 				// which hasn't been incremented yet for this message, and shouldn't be
-				pErr := &ProducerError{Msg: msg, Err: ErrShuttingDown}
-				if p.conf.Producer.Return.Errors {
-					p.errors <- pErr
-				} else {
-					Logger.Println(pErr)
-				}
+				flowBytes := msg.flowBytes
+				msg.flowBytes = 0
+				p.deliverError(&ProducerError{Msg: msg, Err: ErrShuttingDown})
+				p.flow.release(flowBytes)
 				continue
 			}
 			p.inFlight.Add(1)
+			atomic.AddInt64(&p.messagesInFlight, 1)
+
+			if p.txnmgr.transactional() {
+				if err := p.txnmgr.canProduce(msg); err != nil {
+					p.returnError(msg, err)
+					continue
+				}
+			}
+
+			interceptSend(p.conf.Producer.Interceptors, msg)
 		}
 
+		if len(msg.Headers) > 0 && !p.conf.Version.IsAtLeast(V0_11_0_0) {
+			p.returnError(msg, ConfigurationError("Producing headers requires Version >= V0_11_0_0"))
+			continue
+		}
 		if msg.byteSize() > p.conf.Producer.MaxMessageBytes {
 			p.returnError(msg, ErrMessageSizeTooLarge)
 			continue
@@ -289,6 +462,11 @@ func (p *asyncProducer) newTopicProducer(topic string) chan<- *ProducerMessage {
 		handlers:    make(map[int32]chan<- *ProducerMessage),
 		partitioner: p.conf.Producer.Partitioner(topic),
 	}
+	if partitioner, ok := tp.partitioner.(BatchAwarePartitioner); ok {
+		p.batchAwareLock.Lock()
+		p.batchAware[topic] = partitioner
+		p.batchAwareLock.Unlock()
+	}
 	go withRecover(tp.dispatch)
 	return input
 }
@@ -319,8 +497,13 @@ func (tp *topicProducer) dispatch() {
 func (tp *topicProducer) partitionMessage(msg *ProducerMessage) error {
 	var partitions []int32
 
+	partitioner := tp.partitioner
+	if msg.ManualPartition {
+		partitioner = manualPartitioning
+	}
+
 	err := tp.breaker.Run(func() (err error) {
-		if tp.partitioner.RequiresConsistency() {
+		if partitioner.RequiresConsistency() {
 			partitions, err = tp.parent.client.Partitions(msg.Topic)
 		} else {
 			partitions, err = tp.parent.client.WritablePartitions(msg.Topic)
@@ -338,7 +521,7 @@ func (tp *topicProducer) partitionMessage(msg *ProducerMessage) error {
 		return ErrLeaderNotAvailable
 	}
 
-	choice, err := tp.partitioner.Partition(msg, numPartitions)
+	choice, err := partitioner.Partition(msg, numPartitions)
 
 	if err != nil {
 		return err
@@ -406,7 +589,7 @@ func (pp *partitionProducer) dispatch() {
 		if msg.retries > pp.highWatermark {
 			// a new, higher, retry level; handle it and then back off
 			pp.newHighWatermark(msg.retries)
-			time.Sleep(pp.parent.conf.Producer.Retry.Backoff)
+			time.Sleep(pp.parent.retryBackoff(msg.retries))
 		} else if pp.highWatermark > 0 {
 			// we are retrying something (else highWatermark would be 0) but this message is not a *new* retry level
 			if msg.retries < pp.highWatermark {
@@ -432,12 +615,12 @@ func (pp *partitionProducer) dispatch() {
 		// without breaking any of our ordering guarantees
 
 		if pp.output == nil {
-			if err := pp.updateLeader(); err != nil {
+			if err := pp.waitForLeader(msg.retries); err != nil {
 				pp.parent.returnError(msg, err)
-				time.Sleep(pp.parent.conf.Producer.Retry.Backoff)
+				time.Sleep(pp.parent.retryBackoff(msg.retries + 1))
 				continue
 			}
-			Logger.Printf("producer/leader/%s/%d selected broker %d\n", pp.topic, pp.partition, pp.leader.ID())
+			LogProducer.debug("selected broker", "topic", pp.topic, "partition", pp.partition, "broker", pp.leader.ID())
 		}
 
 		pp.output <- msg
@@ -449,7 +632,7 @@ func (pp *partitionProducer) dispatch() {
 }
 
 func (pp *partitionProducer) newHighWatermark(hwm int) {
-	Logger.Printf("producer/leader/%s/%d state change to [retrying-%d]\n", pp.topic, pp.partition, hwm)
+	LogProducer.info("partition state change", "topic", pp.topic, "partition", pp.partition, "state", fmt.Sprintf("retrying-%d", hwm))
 	pp.highWatermark = hwm
 
 	// send off a fin so that we know when everything "in between" has made it
@@ -459,22 +642,22 @@ func (pp *partitionProducer) newHighWatermark(hwm int) {
 	pp.output <- &ProducerMessage{Topic: pp.topic, Partition: pp.partition, flags: fin, retries: pp.highWatermark - 1}
 
 	// a new HWM means that our current broker selection is out of date
-	Logger.Printf("producer/leader/%s/%d abandoning broker %d\n", pp.topic, pp.partition, pp.leader.ID())
+	LogProducer.info("abandoning broker", "topic", pp.topic, "partition", pp.partition, "broker", pp.leader.ID())
 	pp.parent.unrefBrokerProducer(pp.leader, pp.output)
 	pp.output = nil
 }
 
 func (pp *partitionProducer) flushRetryBuffers() {
-	Logger.Printf("producer/leader/%s/%d state change to [flushing-%d]\n", pp.topic, pp.partition, pp.highWatermark)
+	LogProducer.info("partition state change", "topic", pp.topic, "partition", pp.partition, "state", fmt.Sprintf("flushing-%d", pp.highWatermark))
 	for {
 		pp.highWatermark--
 
 		if pp.output == nil {
-			if err := pp.updateLeader(); err != nil {
+			if err := pp.waitForLeader(pp.highWatermark); err != nil {
 				pp.parent.returnErrors(pp.retryState[pp.highWatermark].buf, err)
 				goto flushDone
 			}
-			Logger.Printf("producer/leader/%s/%d selected broker %d\n", pp.topic, pp.partition, pp.leader.ID())
+			LogProducer.debug("selected broker", "topic", pp.topic, "partition", pp.partition, "broker", pp.leader.ID())
 		}
 
 		for _, msg := range pp.retryState[pp.highWatermark].buf {
@@ -484,10 +667,10 @@ func (pp *partitionProducer) flushRetryBuffers() {
 	flushDone:
 		pp.retryState[pp.highWatermark].buf = nil
 		if pp.retryState[pp.highWatermark].expectChaser {
-			Logger.Printf("producer/leader/%s/%d state change to [retrying-%d]\n", pp.topic, pp.partition, pp.highWatermark)
+			LogProducer.info("partition state change", "topic", pp.topic, "partition", pp.partition, "state", fmt.Sprintf("retrying-%d", pp.highWatermark))
 			break
 		} else if pp.highWatermark == 0 {
-			Logger.Printf("producer/leader/%s/%d state change to [normal]\n", pp.topic, pp.partition)
+			LogProducer.info("partition state change", "topic", pp.topic, "partition", pp.partition, "state", "normal")
 			break
 		}
 	}
@@ -511,12 +694,31 @@ func (pp *partitionProducer) updateLeader() error {
 	})
 }
 
+// waitForLeader looks the leader of the partition up for messages of the given
+// retry level. While the partition has no leader, as when its leader failed
+// and another one is yet to be elected, each failed lookup uses up one of the
+// messages' retries, backing off before the next one, so that they are only
+// returned as errors once they are out of retries.
+func (pp *partitionProducer) waitForLeader(retries int) error {
+	for {
+		err := pp.updateLeader()
+		if err == nil || retries >= pp.parent.conf.Producer.Retry.Max {
+			return err
+		}
+		retries++
+		LogProducer.info("failed to find the leader, retrying",
+			"topic", pp.topic, "partition", pp.partition, "attempt", retries, "err", err)
+		time.Sleep(pp.parent.retryBackoff(retries))
+	}
+}
+
 // one per broker; also constructs an associated flusher
-func (p *asyncProducer) newBrokerProducer(broker *Broker) chan<- *ProducerMessage {
+func (p *asyncProducer) newBrokerProducer(broker *Broker) (chan<- *ProducerMessage, *brokerProducer) {
 	var (
 		input     = make(chan *ProducerMessage)
 		bridge    = make(chan *produceSet)
 		responses = make(chan *brokerProducerResponse)
+		window    = make(chan none, p.conf.Net.MaxOpenRequests)
 	)
 
 	bp := &brokerProducer{
@@ -525,41 +727,117 @@ func (p *asyncProducer) newBrokerProducer(broker *Broker) chan<- *ProducerMessag
 		input:          input,
 		output:         bridge,
 		responses:      responses,
+		window:         window,
 		buffer:         newProduceSet(p),
 		currentRetries: make(map[string]map[int32]error),
 	}
 	go withRecover(bp.run)
 
-	// minimal bridge to make the network response `select`able
+	// an idempotent producer only takes the next set once the response to the last
+	// one has been handled: a set taken earlier could hold messages that fail
+	// behind the ones retried, and be retried after the messages that follow
+	// them, out of sequence
+	var handled chan none
+	if p.txnmgr != nil {
+		handled = make(chan none)
+	}
+
+	// minimal bridge to make the network response `select`able; it keeps up to
+	// Net.MaxOpenRequests requests in flight so that the broker's latency doesn't
+	// cap our throughput, and passes the responses on in the order they were sent
 	go withRecover(func() {
+		pending := make(chan *pendingProduce, p.conf.Net.MaxOpenRequests)
+
+		go withRecover(func() {
+			for pp := range pending {
+				if pp.txnErr != nil {
+					responses <- &brokerProducerResponse{set: pp.set, txnErr: pp.txnErr}
+					if handled != nil {
+						handled <- none{}
+					}
+					continue
+				}
+
+				var response *ProduceResponse
+				err := pp.err
+				if err == nil {
+					response, err = pp.wait()
+				}
+				<-window
+
+				responses <- &brokerProducerResponse{
+					set: pp.set,
+					err: err,
+					res: response,
+				}
+				if handled != nil {
+					handled <- none{}
+				}
+			}
+			close(responses)
+		})
+
 		for set := range bridge {
+			// a transactional producer adds the partitions to its transaction
+			// before producing to them
+			if err := p.txnmgr.addPartitions(set); err != nil {
+				pending <- &pendingProduce{set: set, txnErr: err}
+				if handled != nil {
+					<-handled
+				}
+				continue
+			}
+
 			request := set.buildRequest()
 
-			response, err := broker.Produce(request)
+			window <- none{}
+			wait, err := broker.asyncProduce(request)
+			if err == nil {
+				set.updateMetrics()
+			}
 
-			responses <- &brokerProducerResponse{
-				set: set,
-				err: err,
-				res: response,
+			pending <- &pendingProduce{set: set, wait: wait, err: err}
+			if handled != nil {
+				<-handled
 			}
 		}
-		close(responses)
+		close(pending)
 	})
 
-	return input
+	return input, bp
+}
+
+type pendingProduce struct {
+	set  *produceSet
+	wait func() (*ProduceResponse, error)
+	err  error
+	// txnErr is why the partitions of the set couldn't be added to the
+	// transaction, in which case it wasn't sent
+	txnErr error
 }
 
 type brokerProducerResponse struct {
-	set *produceSet
+	set    *produceSet
+	err    error
+	res    *ProduceResponse
+	txnErr error
+}
+
+// heldMessage is a message to retry once the sets in flight are handled.
+type heldMessage struct {
+	msg *ProducerMessage
 	err error
-	res *ProduceResponse
 }
 
 // groups messages together into appropriately-sized batches for sending to the broker
 // handles state related to retries etc
 type brokerProducer struct {
+	// the size of buffer, published for snapshots; first for 64-bit alignment
+	bufferedMessages, bufferedBytes int64
+
 	parent *asyncProducer
 	broker *Broker
+	window chan none // holds a token per request in flight
 
 	input     <-chan *ProducerMessage
 	output    chan<- *produceSet
@@ -569,13 +847,20 @@ type brokerProducer struct {
 	timer      <-chan time.Time
 	timerFired bool
 
+	// inFlight counts the sets passed on to be sent whose response is yet to
+	// be handled, and held the messages to retry once there are none left
+	inFlight int
+	held     []heldMessage
+
+	// written by run with retriesLock held, so that snapshots can read them
 	closing        error
 	currentRetries map[string]map[int32]error
+	retriesLock    sync.Mutex
 }
 
 func (bp *brokerProducer) run() {
 	var output chan<- *produceSet
-	Logger.Printf("producer/broker/%d starting up\n", bp.broker.ID())
+	LogProducer.debug("broker producer starting up", "broker", bp.broker.ID())
 
 	for {
 		select {
@@ -586,24 +871,28 @@ func (bp *brokerProducer) run() {
 			}
 
 			if msg.flags&syn == syn {
-				Logger.Printf("producer/broker/%d state change to [open] on %s/%d\n",
-					bp.broker.ID(), msg.Topic, msg.Partition)
+				LogProducer.info("broker state change",
+					"broker", bp.broker.ID(), "topic", msg.Topic, "partition", msg.Partition, "state", "open")
+				bp.retriesLock.Lock()
 				if bp.currentRetries[msg.Topic] == nil {
 					bp.currentRetries[msg.Topic] = make(map[int32]error)
 				}
 				bp.currentRetries[msg.Topic][msg.Partition] = nil
+				bp.retriesLock.Unlock()
 				bp.parent.inFlight.Done()
 				continue
 			}
 
 			if reason := bp.needsRetry(msg); reason != nil {
-				bp.parent.retryMessage(msg, reason)
+				bp.retry(msg, reason)
 
 				if bp.closing == nil && msg.flags&fin == fin {
 					// we were retrying this partition but we can start processing again
+					bp.retriesLock.Lock()
 					delete(bp.currentRetries[msg.Topic], msg.Partition)
-					Logger.Printf("producer/broker/%d state change to [closed] on %s/%d\n",
-						bp.broker.ID(), msg.Topic, msg.Partition)
+					bp.retriesLock.Unlock()
+					LogProducer.info("broker state change",
+						"broker", bp.broker.ID(), "topic", msg.Topic, "partition", msg.Partition, "state", "closed")
 				}
 
 				continue
@@ -611,7 +900,7 @@ func (bp *brokerProducer) run() {
 
 			if bp.buffer.wouldOverflow(msg) {
 				if err := bp.waitForSpace(msg); err != nil {
-					bp.parent.retryMessage(msg, err)
+					bp.retry(msg, err)
 					continue
 				}
 			}
@@ -627,7 +916,7 @@ func (bp *brokerProducer) run() {
 		case <-bp.timer:
 			bp.timerFired = true
 		case output <- bp.buffer:
-			bp.rollOver()
+			bp.sent()
 		case response := <-bp.responses:
 			bp.handleResponse(response)
 		}
@@ -637,6 +926,7 @@ func (bp *brokerProducer) run() {
 		} else {
 			output = nil
 		}
+		bp.publishBuffer()
 	}
 }
 
@@ -646,7 +936,7 @@ func (bp *brokerProducer) shutdown() {
 		case response := <-bp.responses:
 			bp.handleResponse(response)
 		case bp.output <- bp.buffer:
-			bp.rollOver()
+			bp.sent()
 		}
 	}
 	close(bp.output)
@@ -654,7 +944,7 @@ func (bp *brokerProducer) shutdown() {
 		bp.handleResponse(response)
 	}
 
-	Logger.Printf("producer/broker/%d shut down\n", bp.broker.ID())
+	LogProducer.debug("broker producer shut down", "broker", bp.broker.ID())
 }
 
 func (bp *brokerProducer) needsRetry(msg *ProducerMessage) error {
@@ -666,7 +956,7 @@ func (bp *brokerProducer) needsRetry(msg *ProducerMessage) error {
 }
 
 func (bp *brokerProducer) waitForSpace(msg *ProducerMessage) error {
-	Logger.Printf("producer/broker/%d maximum request accumulated, waiting for space\n", bp.broker.ID())
+	LogProducer.debug("maximum request accumulated, waiting for space", "broker", bp.broker.ID())
 
 	for {
 		select {
@@ -679,25 +969,65 @@ func (bp *brokerProducer) waitForSpace(msg *ProducerMessage) error {
 				return nil
 			}
 		case bp.output <- bp.buffer:
-			bp.rollOver()
+			bp.sent()
 			return nil
 		}
 	}
 }
 
+// sent rolls over the buffer once it is passed on to be sent.
+func (bp *brokerProducer) sent() {
+	bp.inFlight++
+	bp.rollOver()
+}
+
+// retry retries a message that can't be sent to the broker, unless sets are in
+// flight, in which case it is held until their responses are handled: those of
+// their messages that fail must be retried first, so as not to reorder their
+// partitions.
+func (bp *brokerProducer) retry(msg *ProducerMessage, err error) {
+	if bp.inFlight > 0 || len(bp.held) > 0 {
+		bp.held = append(bp.held, heldMessage{msg, err})
+		return
+	}
+	bp.parent.retryMessage(msg, err)
+}
+
+// rollOver starts a new buffer once the current one is flushed, or emptied by a
+// response.
 func (bp *brokerProducer) rollOver() {
+	bp.parent.flushed(bp.buffer)
 	bp.timer = nil
 	bp.timerFired = false
 	bp.buffer = newProduceSet(bp.parent)
+	bp.publishBuffer()
+}
+
+func (bp *brokerProducer) publishBuffer() {
+	atomic.StoreInt64(&bp.bufferedMessages, int64(bp.buffer.bufferCount))
+	atomic.StoreInt64(&bp.bufferedBytes, int64(bp.buffer.bufferBytes))
 }
 
 func (bp *brokerProducer) handleResponse(response *brokerProducerResponse) {
-	if response.err != nil {
+	bp.inFlight--
+	switch {
+	case response.txnErr != nil:
+		response.set.eachPartition(func(topic string, partition int32, msgs []*ProducerMessage) {
+			bp.parent.returnErrors(msgs, response.txnErr)
+		})
+	case response.err != nil:
 		bp.handleError(response.set, response.err)
-	} else {
+	default:
 		bp.handleSuccess(response.set, response.res)
 	}
 
+	if bp.inFlight == 0 {
+		for _, held := range bp.held {
+			bp.parent.retryMessage(held.msg, held.err)
+		}
+		bp.held = nil
+	}
+
 	if bp.buffer.empty() {
 		bp.rollOver() // this can happen if the response invalidated our buffer
 	}
@@ -720,23 +1050,47 @@ func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceRespo
 		}
 
 		switch block.Err {
-		// Success
-		case ErrNoError:
+		// Success; an idempotent producer's duplicates were written by an earlier attempt
+		case ErrNoError, ErrDuplicateSequenceNumber:
+			if block.Err == ErrDuplicateSequenceNumber {
+				LogProducer.debug("messages were already written",
+					"broker", bp.broker.ID(), "topic", topic, "partition", partition, "sequence", msgs[0].sequence)
+			}
 			for i, msg := range msgs {
-				msg.Offset = block.Offset + int64(i)
+				msg.Offset = -1
+				if block.Offset >= 0 {
+					msg.Offset = block.Offset + int64(i)
+				}
+				if !block.Timestamp.IsZero() {
+					msg.Timestamp = block.Timestamp
+				}
 			}
 			bp.parent.returnSuccesses(msgs)
 		// Retriable errors
 		case ErrUnknownTopicOrPartition, ErrNotLeaderForPartition, ErrLeaderNotAvailable,
 			ErrRequestTimedOut, ErrNotEnoughReplicas, ErrNotEnoughReplicasAfterAppend:
-			Logger.Printf("producer/broker/%d state change to [retrying] on %s/%d because %v\n",
-				bp.broker.ID(), topic, partition, block.Err)
+			LogProducer.warn("broker state change",
+				"broker", bp.broker.ID(), "topic", topic, "partition", partition, "state", "retrying", "err", block.Err)
+			bp.retriesLock.Lock()
 			bp.currentRetries[topic][partition] = block.Err
+			bp.retriesLock.Unlock()
 			bp.parent.retryMessages(msgs, block.Err)
-			bp.parent.retryMessages(bp.buffer.dropPartition(topic, partition), block.Err)
+			for _, msg := range bp.buffer.dropPartition(topic, partition) {
+				bp.retry(msg, block.Err)
+			}
+		// The broker lost track of an idempotent producer's messages, so retrying could
+		// reorder or duplicate them
+		case ErrOutOfOrderSequenceNumber, ErrInvalidProducerEpoch, ErrUnknownProducerID:
+			LogProducer.error("messages out of sequence",
+				"broker", bp.broker.ID(), "topic", topic, "partition", partition, "err", block.Err)
+			bp.parent.returnErrors(msgs, block.Err)
+			bp.buffer.resequence(topic, partition)
 		// Other non-retriable errors
 		default:
 			bp.parent.returnErrors(msgs, block.Err)
+			// the failed messages of an idempotent producer leave a gap in the
+			// sequence numbers of the messages buffered after them
+			bp.buffer.resequence(topic, partition)
 		}
 	})
 }
@@ -748,15 +1102,20 @@ func (bp *brokerProducer) handleError(sent *produceSet, err error) {
 			bp.parent.returnErrors(msgs, err)
 		})
 	default:
-		Logger.Printf("producer/broker/%d state change to [closing] because %s\n", bp.broker.ID(), err)
+		LogProducer.warn("broker state change", "broker", bp.broker.ID(), "state", "closing", "err", err)
 		bp.parent.abandonBrokerConnection(bp.broker)
 		_ = bp.broker.Close()
+		err = newBrokerError(bp.broker, err)
+		bp.retriesLock.Lock()
 		bp.closing = err
+		bp.retriesLock.Unlock()
 		sent.eachPartition(func(topic string, partition int32, msgs []*ProducerMessage) {
 			bp.parent.retryMessages(msgs, err)
 		})
 		bp.buffer.eachPartition(func(topic string, partition int32, msgs []*ProducerMessage) {
-			bp.parent.retryMessages(msgs, err)
+			for _, msg := range msgs {
+				bp.retry(msg, err)
+			}
 		})
 		bp.rollOver()
 	}
@@ -792,19 +1151,22 @@ func (p *asyncProducer) retryHandler() {
 // utility functions
 
 func (p *asyncProducer) shutdown() {
-	Logger.Println("Producer shutting down.")
+	LogProducer.info("producer shutting down")
 	p.inFlight.Add(1)
-	p.input <- &ProducerMessage{flags: shutdown}
+	p.Input() <- &ProducerMessage{flags: shutdown}
 
 	p.inFlight.Wait()
 
 	if p.ownClient {
 		err := p.client.Close()
 		if err != nil {
-			Logger.Println("producer/shutdown failed to close the embedded client:", err)
+			LogProducer.error("failed to close the embedded client", "err", err)
 		}
 	}
 
+	if p.flowInput != nil {
+		close(p.flowInput)
+	}
 	close(p.input)
 	close(p.retries)
 	close(p.errors)
@@ -812,16 +1174,32 @@ func (p *asyncProducer) shutdown() {
 }
 
 func (p *asyncProducer) returnError(msg *ProducerMessage, err error) {
+	p.markRecord("record-error-rate", msg.Topic)
+	p.txnmgr.messageFailed(msg, err)
+	flowBytes, inTxn := msg.flowBytes, msg.inTxn
 	msg.clear()
-	pErr := &ProducerError{Msg: msg, Err: err}
-	if p.conf.Producer.Return.Errors {
-		p.errors <- pErr
-	} else {
-		Logger.Println(pErr)
+	p.deliverError(&ProducerError{Msg: msg, Err: err})
+	if inTxn {
+		p.txnmgr.messageDone()
 	}
+	p.flow.release(flowBytes)
+	atomic.AddInt64(&p.messagesInFlight, -1)
 	p.inFlight.Done()
 }
 
+// deliverError passes pErr to the ErrorHandler if there is one, and otherwise
+// returns it on the Errors channel or logs it.
+func (p *asyncProducer) deliverError(pErr *ProducerError) {
+	switch {
+	case p.conf.Producer.Return.ErrorHandler != nil:
+		p.conf.Producer.Return.ErrorHandler(pErr)
+	case p.conf.Producer.Return.Errors:
+		p.errors <- pErr
+	default:
+		LogProducer.error("failed to produce message", "topic", pErr.Msg.Topic, "partition", pErr.Msg.Partition, "err", pErr.Err)
+	}
+}
+
 func (p *asyncProducer) returnErrors(batch []*ProducerMessage, err error) {
 	for _, msg := range batch {
 		p.returnError(msg, err)
@@ -830,10 +1208,20 @@ func (p *asyncProducer) returnErrors(batch []*ProducerMessage, err error) {
 
 func (p *asyncProducer) returnSuccesses(batch []*ProducerMessage) {
 	for _, msg := range batch {
+		flowBytes, inTxn := msg.flowBytes, msg.inTxn
 		if p.conf.Producer.Return.Successes {
 			msg.clear()
 			p.successes <- msg
+		} else {
+			msg.batch = nil
+			msg.flowBytes = 0
+			msg.inTxn = false
 		}
+		if inTxn {
+			p.txnmgr.messageDone()
+		}
+		p.flow.release(flowBytes)
+		atomic.AddInt64(&p.messagesInFlight, -1)
 		p.inFlight.Done()
 	}
 }
@@ -843,10 +1231,43 @@ func (p *asyncProducer) retryMessage(msg *ProducerMessage, err error) {
 		p.returnError(msg, err)
 	} else {
 		msg.retries++
+		if msg.flags == 0 {
+			// fin messages are retried too, but are not records
+			p.markRecord("record-retry-rate", msg.Topic)
+		}
 		p.retries <- msg
 	}
 }
 
+// retryBackoff is how long to wait before retrying messages for the given time.
+func (p *asyncProducer) retryBackoff(retries int) time.Duration {
+	if p.conf.Producer.Retry.BackoffFunc != nil {
+		return p.conf.Producer.Retry.BackoffFunc(retries, p.conf.Producer.Retry.Max)
+	}
+	return p.conf.Producer.Retry.Backoff
+}
+
+// markRecord marks one record on the named meter, in aggregate and for its topic.
+func (p *asyncProducer) markRecord(name, topic string) {
+	metrics.GetOrRegisterMeter(name, p.conf.MetricRegistry).Mark(1)
+	getOrRegisterTopicMeter(name, topic, p.conf.MetricRegistry).Mark(1)
+}
+
+// flushed tells the batch-aware partitioners of the topics of a flushed set of
+// the partitions it flushed.
+func (p *asyncProducer) flushed(set *produceSet) {
+	p.batchAwareLock.RLock()
+	defer p.batchAwareLock.RUnlock()
+
+	for topic, partitions := range set.msgs {
+		if partitioner := p.batchAware[topic]; partitioner != nil {
+			for partition := range partitions {
+				partitioner.Flushed(partition)
+			}
+		}
+	}
+}
+
 func (p *asyncProducer) retryMessages(batch []*ProducerMessage, err error) {
 	for _, msg := range batch {
 		p.retryMessage(msg, err)
@@ -860,9 +1281,11 @@ func (p *asyncProducer) getBrokerProducer(broker *Broker) chan<- *ProducerMessag
 	bp := p.brokers[broker]
 
 	if bp == nil {
-		bp = p.newBrokerProducer(broker)
+		var state *brokerProducer
+		bp, state = p.newBrokerProducer(broker)
 		p.brokers[broker] = bp
 		p.brokerRefs[bp] = 0
+		p.brokerProducers[bp] = state
 	}
 
 	p.brokerRefs[bp]++
@@ -878,6 +1301,7 @@ func (p *asyncProducer) unrefBrokerProducer(broker *Broker, bp chan<- *ProducerM
 	if p.brokerRefs[bp] == 0 {
 		close(bp)
 		delete(p.brokerRefs, bp)
+		delete(p.brokerProducers, bp)
 
 		if p.brokers[broker] == bp {
 			delete(p.brokers, broker)
@@ -5,9 +5,14 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"reflect"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 const TestMessage = "ABC THE MESSAGE"
@@ -94,8 +99,8 @@ func (f flakyEncoder) Encode() ([]byte, error) {
 }
 
 func TestAsyncProducer(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
@@ -140,8 +145,8 @@ func TestAsyncProducer(t *testing.T) {
 }
 
 func TestAsyncProducerMultipleFlushes(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
@@ -174,10 +179,61 @@ func TestAsyncProducerMultipleFlushes(t *testing.T) {
 	seedBroker.Close()
 }
 
+func TestAsyncProducerStickyPartitionerMovesOnAfterFlush(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader0 := NewMockBroker(t, 2)
+	leader1 := NewMockBroker(t, 3)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader0.Addr(), leader0.BrokerID())
+	metadataResponse.AddBroker(leader1.Addr(), leader1.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader0.BrokerID(), nil, nil, ErrNoError)
+	metadataResponse.AddTopicPartition("my_topic", 1, leader1.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodResponse0 := new(ProduceResponse)
+	prodResponse0.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader0.Returns(prodResponse0)
+
+	prodResponse1 := new(ProduceResponse)
+	prodResponse1.AddTopicPartition("my_topic", 1, ErrNoError)
+	leader1.Returns(prodResponse1)
+
+	config := NewConfig()
+	config.Producer.Flush.Messages = 5
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = NewStickyPartitioner
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for partition := int32(0); partition < 2; partition++ {
+		for i := 0; i < 5; i++ {
+			producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+		}
+		for i := 0; i < 5; i++ {
+			select {
+			case msg := <-producer.Successes():
+				if msg.Partition != partition {
+					t.Errorf("Expected the batch to stick to partition %d, got a message to %d", partition, msg.Partition)
+				}
+			case err := <-producer.Errors():
+				t.Fatal(err)
+			}
+		}
+	}
+
+	closeProducer(t, producer)
+	leader1.Close()
+	leader0.Close()
+	seedBroker.Close()
+}
+
 func TestAsyncProducerMultipleBrokers(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader0 := newMockBroker(t, 2)
-	leader1 := newMockBroker(t, 3)
+	seedBroker := NewMockBroker(t, 1)
+	leader0 := NewMockBroker(t, 2)
+	leader1 := NewMockBroker(t, 3)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader0.Addr(), leader0.BrokerID())
@@ -215,8 +271,8 @@ func TestAsyncProducerMultipleBrokers(t *testing.T) {
 }
 
 func TestAsyncProducerCustomPartitioner(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
@@ -257,9 +313,9 @@ func TestAsyncProducerCustomPartitioner(t *testing.T) {
 }
 
 func TestAsyncProducerFailureRetry(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader1 := newMockBroker(t, 2)
-	leader2 := newMockBroker(t, 3)
+	seedBroker := NewMockBroker(t, 1)
+	leader1 := NewMockBroker(t, 2)
+	leader2 := NewMockBroker(t, 3)
 
 	metadataLeader1 := new(MetadataResponse)
 	metadataLeader1.AddBroker(leader1.Addr(), leader1.BrokerID())
@@ -305,8 +361,8 @@ func TestAsyncProducerFailureRetry(t *testing.T) {
 }
 
 func TestAsyncProducerEncoderFailures(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
@@ -335,17 +391,163 @@ func TestAsyncProducerEncoderFailures(t *testing.T) {
 		expectResults(t, producer, 1, 2)
 	}
 
+	if failed := config.MetricRegistry.Get("record-error-rate-for-topic-my_topic").(metrics.Meter); failed.Count() != 6 {
+		t.Error("Expected 6 record errors, got", failed.Count())
+	}
+
 	closeProducer(t, producer)
 	leader.Close()
 	seedBroker.Close()
 }
 
+func TestAsyncProducerHeadersRequireVersion(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Headers: []RecordHeader{{Key: []byte("header")}}}
+	select {
+	case err := <-producer.Errors():
+		if _, ok := err.Err.(ConfigurationError); !ok {
+			t.Error("Expected a ConfigurationError producing headers before V0_11_0_0, got", err.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Timed out waiting for the message with headers to be refused")
+	}
+
+	closeProducer(t, producer)
+	seedBroker.Close()
+}
+
+func TestAsyncProducerZstdSendsVersion7(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"ProduceRequest": NewMockProduceResponse(t),
+	})
+
+	config := NewConfig()
+	config.Version = V2_1_0_0
+	config.Producer.Compression = CompressionZSTD
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+	closeProducer(t, producer)
+
+	// brokers refuse zstd batches in produce requests before version 7
+	for _, rr := range seedBroker.History() {
+		if request, ok := rr.Request.(*ProduceRequest); ok {
+			if batch := request.recordBatches["my_topic"][0]; request.Version != 7 || batch.Codec != CompressionZSTD {
+				t.Errorf("Expected a zstd batch in a request of version 7, got %v in version %d", batch.Codec, request.Version)
+			}
+		}
+	}
+	seedBroker.Close()
+}
+
+func TestAsyncProducerMessageTooLarge(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+	})
+
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	config.Producer.MaxMessageBytes = 100
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []*ProducerMessage{
+		{Topic: "my_topic", Value: ByteEncoder(make([]byte, 100))},
+		{Topic: "my_topic", Value: StringEncoder(TestMessage), Headers: []RecordHeader{{Key: []byte("header"), Value: make([]byte, 60)}}},
+	} {
+		producer.Input() <- msg
+		select {
+		case err := <-producer.Errors():
+			if err.Err != ErrMessageSizeTooLarge || err.Msg != msg {
+				t.Error("Expected ErrMessageSizeTooLarge for the oversized message, got", err.Err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("Timed out waiting for the oversized message to be refused")
+		}
+	}
+
+	closeProducer(t, producer)
+	seedBroker.Close()
+	for _, req := range seedBroker.History() {
+		if _, ok := req.Request.(*ProduceRequest); ok {
+			t.Error("Expected oversized messages not to be sent to the broker")
+		}
+	}
+}
+
+func TestAsyncProducerErrorHandler(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodInvalid := new(ProduceResponse)
+	prodInvalid.AddTopicPartition("my_topic", 0, ErrInvalidMessage)
+	leader.Returns(prodInvalid)
+
+	failed := make(chan *ProducerError, 1)
+	config := NewConfig()
+	config.Producer.Return.ErrorHandler = func(pErr *ProducerError) { failed <- pErr }
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &ProducerMessage{Topic: "my_topic", Key: StringEncoder("key"), Value: StringEncoder(TestMessage)}
+	producer.Input() <- msg
+	select {
+	case pErr := <-failed:
+		if pErr.Msg != msg || pErr.Msg.Partition != 0 || pErr.Unwrap() != ErrInvalidMessage {
+			t.Error("Expected the handler to be passed the message with ErrInvalidMessage, got", pErr)
+		}
+	case pErr := <-producer.Errors():
+		t.Error("Expected the error to be passed to the handler, got", pErr)
+	case <-time.After(5 * time.Second):
+		t.Error("Timed out waiting for the error")
+	}
+
+	if err := producer.Close(); err != nil {
+		t.Error("Expected no errors to be returned on closing, got", err)
+	}
+	leader.Close()
+	seedBroker.Close()
+}
+
 // If a Kafka broker becomes unavailable and then returns back in service, then
 // producer reconnects to it and continues sending messages.
 func TestAsyncProducerBrokerBounce(t *testing.T) {
 	// Given
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 	leaderAddr := leader.Addr()
 
 	metadataResponse := new(MetadataResponse)
@@ -370,7 +572,7 @@ func TestAsyncProducerBrokerBounce(t *testing.T) {
 
 	// When: a broker connection gets reset by a broker (network glitch, restart, you name it).
 	leader.Close()                               // producer should get EOF
-	leader = newMockBrokerAddr(t, 2, leaderAddr) // start it up again right away for giggles
+	leader = NewMockBrokerAddr(t, 2, leaderAddr) // start it up again right away for giggles
 	seedBroker.Returns(metadataResponse)         // tell it to go to broker 2 again
 
 	// Then: a produced message goes through the new broker connection.
@@ -384,9 +586,9 @@ func TestAsyncProducerBrokerBounce(t *testing.T) {
 }
 
 func TestAsyncProducerBrokerBounceWithStaleMetadata(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader1 := newMockBroker(t, 2)
-	leader2 := newMockBroker(t, 3)
+	seedBroker := NewMockBroker(t, 1)
+	leader1 := NewMockBroker(t, 2)
+	leader2 := NewMockBroker(t, 3)
 
 	metadataLeader1 := new(MetadataResponse)
 	metadataLeader1.AddBroker(leader1.Addr(), leader1.BrokerID())
@@ -427,9 +629,9 @@ func TestAsyncProducerBrokerBounceWithStaleMetadata(t *testing.T) {
 }
 
 func TestAsyncProducerMultipleRetries(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader1 := newMockBroker(t, 2)
-	leader2 := newMockBroker(t, 3)
+	seedBroker := NewMockBroker(t, 1)
+	leader1 := NewMockBroker(t, 2)
+	leader2 := NewMockBroker(t, 3)
 
 	metadataLeader1 := new(MetadataResponse)
 	metadataLeader1.AddBroker(leader1.Addr(), leader1.BrokerID())
@@ -469,6 +671,10 @@ func TestAsyncProducerMultipleRetries(t *testing.T) {
 	leader2.Returns(prodSuccess)
 	expectResults(t, producer, 10, 0)
 
+	if retries := config.MetricRegistry.Get("record-retry-rate").(metrics.Meter); retries.Count() != 40 {
+		t.Error("Expected 40 record retries, got", retries.Count())
+	}
+
 	for i := 0; i < 10; i++ {
 		producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
 	}
@@ -481,11 +687,72 @@ func TestAsyncProducerMultipleRetries(t *testing.T) {
 	closeProducer(t, producer)
 }
 
+func TestAsyncProducerRetryBackoffFunc(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader1 := NewMockBroker(t, 2)
+	leader2 := NewMockBroker(t, 3)
+
+	metadataLeader1 := new(MetadataResponse)
+	metadataLeader1.AddBroker(leader1.Addr(), leader1.BrokerID())
+	metadataLeader1.AddTopicPartition("my_topic", 0, leader1.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataLeader1)
+
+	var lock sync.Mutex
+	var backoffs []int
+	config := NewConfig()
+	config.Producer.Flush.Messages = 10
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 4
+	config.Producer.Retry.BackoffFunc = func(retries, maxRetries int) time.Duration {
+		lock.Lock()
+		defer lock.Unlock()
+		if maxRetries != 4 {
+			t.Error("Expected the maximum of retries, got", maxRetries)
+		}
+		backoffs = append(backoffs, retries)
+		return 0
+	}
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	}
+	prodNotLeader := new(ProduceResponse)
+	prodNotLeader.AddTopicPartition("my_topic", 0, ErrNotLeaderForPartition)
+	leader1.Returns(prodNotLeader)
+
+	metadataLeader2 := new(MetadataResponse)
+	metadataLeader2.AddBroker(leader2.Addr(), leader2.BrokerID())
+	metadataLeader2.AddTopicPartition("my_topic", 0, leader2.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataLeader2)
+	leader2.Returns(prodNotLeader)
+	seedBroker.Returns(metadataLeader2)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader2.Returns(prodSuccess)
+	expectResults(t, producer, 10, 0)
+
+	lock.Lock()
+	if !reflect.DeepEqual(backoffs, []int{1, 2}) {
+		t.Error("Expected to back off before the first and second retries, got", backoffs)
+	}
+	lock.Unlock()
+
+	seedBroker.Close()
+	leader1.Close()
+	leader2.Close()
+	closeProducer(t, producer)
+}
+
 func TestAsyncProducerOutOfRetries(t *testing.T) {
 	t.Skip("Enable once bug #294 is fixed.")
 
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
@@ -539,8 +806,8 @@ func TestAsyncProducerOutOfRetries(t *testing.T) {
 }
 
 func TestAsyncProducerRetryWithReferenceOpen(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 	leaderAddr := leader.Addr()
 
 	metadataResponse := new(MetadataResponse)
@@ -575,7 +842,7 @@ func TestAsyncProducerRetryWithReferenceOpen(t *testing.T) {
 
 	// reboot the broker (the producer will get EOF on its existing connection)
 	leader.Close()
-	leader = newMockBrokerAddr(t, 2, leaderAddr)
+	leader = NewMockBrokerAddr(t, 2, leaderAddr)
 
 	// send another message on partition 0 to trigger the EOF and retry
 	producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
@@ -596,8 +863,8 @@ func TestAsyncProducerRetryWithReferenceOpen(t *testing.T) {
 }
 
 func TestAsyncProducerFlusherRetryCondition(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
@@ -638,7 +905,7 @@ func TestAsyncProducerFlusherRetryCondition(t *testing.T) {
 	time.Sleep(50 * time.Millisecond)
 
 	leader.SetHandlerByMap(map[string]MockResponse{
-		"ProduceRequest": newMockProduceResponse(t).
+		"ProduceRequest": NewMockProduceResponse(t).
 			SetError("my_topic", 0, ErrNoError),
 	})
 
@@ -661,8 +928,8 @@ func TestAsyncProducerFlusherRetryCondition(t *testing.T) {
 }
 
 func TestAsyncProducerRetryShutdown(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataLeader := new(MetadataResponse)
 	metadataLeader.AddBroker(leader.Addr(), leader.BrokerID())
@@ -711,6 +978,474 @@ func TestAsyncProducerRetryShutdown(t *testing.T) {
 
 // This example shows how to use the producer while simultaneously
 // reading the Errors channel to know about any failures.
+func newIdempotentProducerConfig() *Config {
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Idempotent = true
+	config.Producer.RequiredAcks = WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Backoff = 0
+	config.Net.MaxOpenRequests = 1
+	return config
+}
+
+func TestAsyncProducerIdempotentRetriesKeepSequences(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()),
+		"InitProducerIDRequest": NewMockWrapper(&InitProducerIDResponse{ProducerID: 1000, ProducerEpoch: 2}),
+		"ProduceRequest": NewMockSequence(
+			// the broker writes the messages, but fails to replicate them in time
+			NewMockProduceResponse(t).SetError("my_topic", 0, ErrNotEnoughReplicasAfterAppend),
+			NewMockProduceResponse(t).SetError("my_topic", 0, ErrDuplicateSequenceNumber),
+			NewMockProduceResponse(t),
+		),
+	})
+
+	config := newIdempotentProducerConfig()
+	config.Producer.Flush.Messages = 3
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	expectResults(t, producer, 3, 0)
+	for i := 0; i < 3; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	expectResults(t, producer, 3, 0)
+	closeProducer(t, producer)
+
+	var sequences []int32
+	for _, rr := range broker.History() {
+		request, ok := rr.Request.(*ProduceRequest)
+		if !ok {
+			continue
+		}
+		batch := request.recordBatches["my_topic"][0]
+		if batch.ProducerID != 1000 || batch.ProducerEpoch != 2 || len(batch.Records) != 3 {
+			t.Errorf("Expected a batch of 3 records from producer 1000 at epoch 2, got %d from %d at %d",
+				len(batch.Records), batch.ProducerID, batch.ProducerEpoch)
+		}
+		sequences = append(sequences, batch.FirstSequence)
+	}
+	if !reflect.DeepEqual(sequences, []int32{0, 0, 3}) {
+		t.Error("Expected the retried batch to keep its sequence number, got batches from", sequences)
+	}
+}
+
+func TestAsyncProducerIdempotentResetsSequencesAfterFailures(t *testing.T) {
+	for _, kerr := range []KError{ErrMessageSizeTooLarge, ErrOutOfOrderSequenceNumber, ErrUnknownProducerID} {
+		broker := NewMockBroker(t, 1)
+		broker.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t).
+				SetBroker(broker.Addr(), broker.BrokerID()).
+				SetLeader("my_topic", 0, broker.BrokerID()),
+			"InitProducerIDRequest": NewMockSequence(
+				&InitProducerIDResponse{ProducerID: 1000},
+				&InitProducerIDResponse{ProducerID: 1001},
+			),
+			"ProduceRequest": NewMockSequence(
+				NewMockProduceResponse(t),
+				NewMockProduceResponse(t).SetError("my_topic", 0, kerr),
+				NewMockProduceResponse(t),
+			),
+		})
+
+		producer, err := NewAsyncProducer([]string{broker.Addr()}, newIdempotentProducerConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, failures := range []int{0, 1, 0} {
+			producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+			expectResults(t, producer, 1-failures, failures)
+		}
+		closeProducer(t, producer)
+
+		var producerIDs []int64
+		var sequences []int32
+		for _, rr := range broker.History() {
+			if request, ok := rr.Request.(*ProduceRequest); ok {
+				batch := request.recordBatches["my_topic"][0]
+				producerIDs = append(producerIDs, batch.ProducerID)
+				sequences = append(sequences, batch.FirstSequence)
+			}
+		}
+		// the message after the failed one is numbered from 0 with a new producer ID
+		if !reflect.DeepEqual(producerIDs, []int64{1000, 1000, 1001}) || !reflect.DeepEqual(sequences, []int32{0, 1, 0}) {
+			t.Errorf("Expected a new producer ID after %s, got batches from %v at %v", kerr, producerIDs, sequences)
+		}
+		broker.Close()
+	}
+}
+
+func TestAsyncProducerIdempotentLeaderChanges(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	config := newIdempotentProducerConfig()
+	config.Producer.Retry.Backoff = 10 * time.Millisecond
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+	producer, err := NewAsyncProducer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leader := range []int32{1, 2, 1} {
+		cluster.SetLeader("my_topic", 0, leader)
+		for i := 0; i < 5; i++ {
+			producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+		}
+		expectResults(t, producer, 5, 0)
+	}
+	closeProducer(t, producer)
+
+	if messages := cluster.Messages("my_topic", 0); len(messages) != 15 {
+		t.Error("Expected 15 messages on the partition, got", len(messages))
+	}
+}
+
+func TestAsyncProducerReplaysToNewLeader(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+	// so that the leader fails with a set in flight, one waiting to be sent
+	// and messages buffered
+	cluster.Broker(1).SetLatency(20 * time.Millisecond)
+
+	config := newMockClusterConfig()
+	config.Net.MaxOpenRequests = 1
+	config.Producer.Flush.Frequency = 5 * time.Millisecond
+	config.Producer.Retry.Max = 20
+	producer, err := NewAsyncProducer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i := 0; i < 200; i++ {
+			producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(strconv.Itoa(i))}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	expectResults(t, producer, 20, 0)
+
+	// the messages wait for the next leader rather than fail
+	cluster.StopBroker(1)
+	time.Sleep(50 * time.Millisecond)
+	cluster.SetLeader("my_topic", 0, 2)
+	expectResults(t, producer, 180, 0)
+	closeProducer(t, producer)
+
+	// retries may write messages again, but not out of order
+	next := 0
+	for _, msg := range cluster.Messages("my_topic", 0) {
+		i, err := strconv.Atoi(string(msg.Value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > next {
+			t.Fatal("Expected message", next, "got", i)
+		}
+		if i == next {
+			next++
+		}
+	}
+	if next != 200 {
+		t.Error("Expected the 200 messages on the partition, got", next)
+	}
+}
+
+func newTransactionalProducerConfig() *Config {
+	config := newIdempotentProducerConfig()
+	config.Producer.Transaction.ID = "my_txn"
+	config.Producer.Retry.Backoff = 10 * time.Millisecond
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+	return config
+}
+
+func fetchMockClusterOffset(t *testing.T, cluster *MockCluster, group, topic string, partition int32) int64 {
+	coordinator := openMockClusterCoordinator(t, cluster, group)
+	defer safeClose(t, coordinator)
+	fetch := &OffsetFetchRequest{Version: 1, ConsumerGroup: group}
+	fetch.AddPartition(topic, partition)
+	response, err := coordinator.FetchOffset(fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.GetBlock(topic, partition).Offset
+}
+
+func TestAsyncProducerTransactions(t *testing.T) {
+	cluster := NewMockCluster(t, 3)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 2)
+	cluster.CreateTopic("in", 1)
+
+	producer, err := NewAsyncProducer(cluster.Addrs(), newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	if msg := <-producer.Errors(); msg.Err != ErrTransactionNotReady {
+		t.Error("Expected a message outside of a transaction to fail, got", msg.Err)
+	}
+
+	if err := producer.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.BeginTxn(); err != ErrTransactionNotReady {
+		t.Error("Expected a transaction not to begin while another is ongoing, got", err)
+	}
+	for i := 0; i < 4; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	expectResults(t, producer, 4, 0)
+	offsets := map[string][]*PartitionOffsetMetadata{"in": {{Partition: 0, Offset: 5}}}
+	if err := producer.AddOffsetsToTxn(offsets, "my_group"); err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.CommitTxn(); err != nil {
+		t.Fatal(err)
+	}
+	if offset := fetchMockClusterOffset(t, cluster, "my_group", "in", 0); offset != 5 {
+		t.Error("Expected the committed transaction to commit offset 5, got", offset)
+	}
+
+	if err := producer.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+	if err := producer.AddMessageToTxn(&ConsumerMessage{Topic: "in", Partition: 0, Offset: 9}, "my_group", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.AbortTxn(); err != nil {
+		t.Fatal(err)
+	}
+	if offset := fetchMockClusterOffset(t, cluster, "my_group", "in", 0); offset != 5 {
+		t.Error("Expected the aborted transaction to leave offset 5, got", offset)
+	}
+	closeProducer(t, producer)
+
+	messages := 0
+	for partition := int32(0); partition < 2; partition++ {
+		for _, msg := range cluster.Messages("my_topic", partition) {
+			if msg != nil { // not a transaction marker
+				messages++
+			}
+		}
+	}
+	if messages != 5 {
+		t.Error("Expected 5 messages to be written, got", messages)
+	}
+}
+
+func TestAsyncProducerFlowControl(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	config := newMockClusterConfig()
+	config.Producer.FlowControl.MaxInFlightMessages = 1
+	producer, err := NewAsyncProducer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first message is in flight until its success is read, so the
+	// producer takes the second but holds it back
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	select {
+	case producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}:
+		t.Fatal("Expected the third message to be refused while the first is in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	expectResults(t, producer, 1, 0)
+	select {
+	case producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the third message to be taken once the first returned")
+	}
+	expectResults(t, producer, 2, 0)
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerTransactionFailedMessages(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	producer, err := NewAsyncProducer(cluster.Addrs(), newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), ManualPartition: true, Partition: 1}
+	expectResults(t, producer, 0, 1)
+	if err := producer.CommitTxn(); err != ErrInvalidPartition {
+		t.Error("Expected a transaction with a failed message not to commit, got", err)
+	}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 0, 1)
+	if err := producer.AbortTxn(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := producer.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+	if err := producer.CommitTxn(); err != nil {
+		t.Fatal(err)
+	}
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerTransactionEndsUnderLoad(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	producer, err := NewAsyncProducer(cluster.Addrs(), newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+
+	// messages keep coming while the transaction is committed
+	stop := make(chan none)
+	sent := make(chan int)
+	go func() {
+		n := 0
+		for {
+			select {
+			case <-stop:
+				sent <- n
+				return
+			case producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}:
+				n++
+			}
+		}
+	}()
+	var results int32
+	drained := make(chan none)
+	go func() {
+		defer close(drained)
+		for {
+			select {
+			case _, ok := <-producer.Successes():
+				if !ok {
+					return
+				}
+			case err, ok := <-producer.Errors():
+				if !ok {
+					return
+				}
+				if err.Err != ErrTransactionNotReady {
+					t.Error("Expected the messages sent after the commit to be refused, got", err.Err)
+				}
+			}
+			atomic.AddInt32(&results, 1)
+		}
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	committed := make(chan error)
+	go func() { committed <- producer.CommitTxn() }()
+	select {
+	case err := <-committed:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out committing the transaction while messages were sent")
+	}
+	close(stop)
+	n := <-sent
+	for deadline := time.Now().Add(5 * time.Second); int(atomic.LoadInt32(&results)) < n; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the results of", n, "messages")
+		}
+	}
+	producer.AsyncClose()
+	<-drained
+
+	if written := len(cluster.Messages("my_topic", 0)); written == 0 || written > n {
+		t.Errorf("Expected some of the %d messages to be committed, got %d", n, written)
+	}
+}
+
+func TestAsyncProducerTransactionFencing(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	first, err := NewAsyncProducer(cluster.Addrs(), newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+	first.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, first, 1, 0)
+
+	second, err := NewAsyncProducer(cluster.Addrs(), newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.CommitTxn(); err != ErrInvalidProducerEpoch {
+		t.Error("Expected the fenced producer not to commit, got", err)
+	}
+	if err := first.BeginTxn(); err != ErrInvalidProducerEpoch {
+		t.Error("Expected the fenced producer not to begin a transaction, got", err)
+	}
+	closeProducer(t, first)
+
+	if err := second.BeginTxn(); err != nil {
+		t.Fatal(err)
+	}
+	second.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, second, 1, 0)
+	if err := second.CommitTxn(); err != nil {
+		t.Fatal(err)
+	}
+	closeProducer(t, second)
+}
+
+func TestAsyncProducerNotTransactional(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.Returns(new(MetadataResponse))
+
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := producer.BeginTxn(); err != ErrNotTransactional {
+		t.Error("Expected a producer without a transactional ID to refuse transactions, got", err)
+	}
+	if err := producer.CommitTxn(); err != ErrNotTransactional {
+		t.Error("Expected a producer without a transactional ID to refuse transactions, got", err)
+	}
+	closeProducer(t, producer)
+}
+
 func ExampleAsyncProducer_select() {
 	producer, err := NewAsyncProducer([]string{"localhost:9092"}, nil)
 	if err != nil {
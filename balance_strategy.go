@@ -0,0 +1,336 @@
+package sarama
+
+import "sort"
+
+// BalanceStrategyPlan is the plan of a BalanceStrategy: the partitions of each
+// topic assigned to each member of a group, by member ID.
+type BalanceStrategyPlan map[string]map[string][]int32
+
+// Add assigns the given partitions of the topic to the member.
+func (p BalanceStrategyPlan) Add(memberID, topic string, partitions ...int32) {
+	if len(partitions) == 0 {
+		return
+	}
+	if p[memberID] == nil {
+		p[memberID] = make(map[string][]int32, 1)
+	}
+	p[memberID][topic] = append(p[memberID][topic], partitions...)
+}
+
+// BalanceStrategy is how the leader of a consumer group assigns the partitions
+// of the topics the group consumes to its members. All the members of a group
+// must use the same strategy.
+type BalanceStrategy interface {
+	// Name uniquely identifies the strategy. It is the protocol the members join
+	// the group with, as "range" and "roundrobin" are for the JVM's assignors.
+	Name() string
+
+	// Plan assigns the given partitions of each topic to the given members,
+	// by member ID. Each partition of a topic must be assigned to no more than
+	// one member, and only to members subscribed to the topic.
+	Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error)
+}
+
+// RebalanceProtocol is how the members of a consumer group hand their
+// partitions over to each other when the group rebalances.
+type RebalanceProtocol int8
+
+const (
+	// RebalanceProtocolEager has each member release all its partitions before
+	// it rejoins the group, so that the whole group stops consuming until the
+	// rebalance completes.
+	RebalanceProtocolEager RebalanceProtocol = iota
+	// RebalanceProtocolCooperative (KIP-429) has each member keep consuming the
+	// partitions it owns while the group rebalances, and only release those it
+	// isn't assigned again. The leader doesn't assign a partition to a member
+	// while another one owns it, so a partition that moves is assigned to its
+	// new owner in a second rebalance, once its previous owner has released it.
+	RebalanceProtocolCooperative
+)
+
+// RebalanceProtocolStrategy is implemented by the BalanceStrategies that
+// support a rebalance protocol other than RebalanceProtocolEager, which is
+// the protocol of those that don't. The Plan of a strategy of
+// RebalanceProtocolCooperative must not assign a partition to a member that
+// another member owns, according to their OwnedPartitions.
+type RebalanceProtocolStrategy interface {
+	BalanceStrategy
+	RebalanceProtocol() RebalanceProtocol
+}
+
+func rebalanceProtocol(strategy BalanceStrategy) RebalanceProtocol {
+	if s, ok := strategy.(RebalanceProtocolStrategy); ok {
+		return s.RebalanceProtocol()
+	}
+	return RebalanceProtocolEager
+}
+
+var (
+	// BalanceStrategyRange assigns each member a range of consecutive partitions
+	// of each topic it subscribes to, the first members by ID getting one more
+	// partition when they don't divide evenly. For example, with two members
+	// subscribed to two topics of three partitions each:
+	//
+	//   M1: {T1: [0, 1], T2: [0, 1]}
+	//   M2: {T1: [2], T2: [2]}
+	BalanceStrategyRange BalanceStrategy = &balanceStrategy{
+		name:   "range",
+		assign: assignRange,
+	}
+
+	// BalanceStrategyRoundRobin assigns the partitions of all topics, ordered by
+	// topic and partition, to the members in turn, by ID, skipping members that
+	// don't subscribe to the topic. For example, with two members subscribed to
+	// two topics of three partitions each:
+	//
+	//   M1: {T1: [0, 2], T2: [1]}
+	//   M2: {T1: [1], T2: [0, 2]}
+	BalanceStrategyRoundRobin BalanceStrategy = &balanceStrategy{
+		name:   "roundrobin",
+		assign: assignRoundRobin,
+	}
+
+	// BalanceStrategySticky balances the partitions between the members as
+	// evenly as their subscriptions allow, while leaving each member as many
+	// as it can of the partitions it owned in the previous generation, so that
+	// a rebalance moves as few partitions as possible. For example, when M2
+	// joins M1, which owned the four partitions of T1:
+	//
+	//   M1: {T1: [0, 1]}
+	//   M2: {T1: [2, 3]}
+	BalanceStrategySticky BalanceStrategy = &stickyBalanceStrategy{name: "sticky"}
+
+	// BalanceStrategyCooperativeSticky assigns the partitions the way
+	// BalanceStrategySticky does, with RebalanceProtocolCooperative: in the
+	// example above, M1 keeps consuming partitions 0 and 1 while the group
+	// rebalances, and M2 is only assigned partitions 2 and 3 once M1 has
+	// released them.
+	BalanceStrategyCooperativeSticky BalanceStrategy = &stickyBalanceStrategy{name: "cooperative-sticky", cooperative: true}
+)
+
+type balanceStrategy struct {
+	name   string
+	assign func(plan BalanceStrategyPlan, subscribers map[string][]string, topics map[string][]int32)
+}
+
+func (s *balanceStrategy) Name() string { return s.name }
+
+func (s *balanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	// the sorted IDs of the members subscribed to each topic
+	subscribers := make(map[string][]string)
+	for memberID, meta := range members {
+		for _, topic := range meta.Topics {
+			subscribers[topic] = append(subscribers[topic], memberID)
+		}
+	}
+	for _, memberIDs := range subscribers {
+		sort.Strings(memberIDs)
+	}
+
+	sorted := make(map[string][]int32, len(topics))
+	for topic, partitions := range topics {
+		partitions = append([]int32(nil), partitions...)
+		sort.Sort(int32Slice(partitions))
+		sorted[topic] = partitions
+	}
+
+	plan := make(BalanceStrategyPlan, len(members))
+	s.assign(plan, subscribers, sorted)
+	return plan, nil
+}
+
+func assignRange(plan BalanceStrategyPlan, subscribers map[string][]string, topics map[string][]int32) {
+	for topic, memberIDs := range subscribers {
+		partitions := topics[topic]
+		if len(memberIDs) == 0 {
+			continue
+		}
+		size, extra := len(partitions)/len(memberIDs), len(partitions)%len(memberIDs)
+		start := 0
+		for i, memberID := range memberIDs {
+			end := start + size
+			if i < extra {
+				end++
+			}
+			plan.Add(memberID, topic, partitions[start:end]...)
+			start = end
+		}
+	}
+}
+
+func assignRoundRobin(plan BalanceStrategyPlan, subscribers map[string][]string, topics map[string][]int32) {
+	var memberIDs []string
+	subscribed := make(map[string]map[string]bool)
+	for topic, ids := range subscribers {
+		for _, memberID := range ids {
+			if subscribed[memberID] == nil {
+				subscribed[memberID] = make(map[string]bool)
+				memberIDs = append(memberIDs, memberID)
+			}
+			subscribed[memberID][topic] = true
+		}
+	}
+	if len(memberIDs) == 0 {
+		return
+	}
+	sort.Strings(memberIDs)
+
+	names := make([]string, 0, len(topics))
+	for topic := range topics {
+		if len(subscribers[topic]) > 0 {
+			names = append(names, topic)
+		}
+	}
+	sort.Strings(names)
+
+	next := 0
+	for _, topic := range names {
+		for _, partition := range topics[topic] {
+			for !subscribed[memberIDs[next%len(memberIDs)]][topic] {
+				next++
+			}
+			plan.Add(memberIDs[next%len(memberIDs)], topic, partition)
+			next++
+		}
+	}
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+type stickyBalanceStrategy struct {
+	name        string
+	cooperative bool
+}
+
+func (s *stickyBalanceStrategy) Name() string { return s.name }
+
+func (s *stickyBalanceStrategy) RebalanceProtocol() RebalanceProtocol {
+	if s.cooperative {
+		return RebalanceProtocolCooperative
+	}
+	return RebalanceProtocolEager
+}
+
+func (s *stickyBalanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	memberIDs := make([]string, 0, len(members))
+	for memberID := range members {
+		memberIDs = append(memberIDs, memberID)
+	}
+	sort.Strings(memberIDs)
+
+	exists := make(map[topicPartition]bool)
+	for topic, partitions := range topics {
+		for _, partition := range partitions {
+			exists[topicPartition{topic, partition}] = true
+		}
+	}
+	subscribers := make(map[string][]string)
+	for _, memberID := range memberIDs {
+		for _, topic := range members[memberID].Topics {
+			subscribers[topic] = append(subscribers[topic], memberID)
+		}
+	}
+
+	// each member keeps the partitions it owned that still exist and that it
+	// still subscribes to, unless a member before it by ID owned them too
+	owners := make(map[topicPartition]string)
+	kept := make(map[topicPartition]bool)
+	assigned := make(map[string][]topicPartition, len(members))
+	for _, memberID := range memberIDs {
+		subscribed := make(map[string]bool)
+		for _, topic := range members[memberID].Topics {
+			subscribed[topic] = true
+		}
+		for _, tp := range sortedTopicPartitions(members[memberID].OwnedPartitions) {
+			if _, owned := owners[tp]; owned {
+				continue
+			}
+			owners[tp] = memberID
+			if subscribed[tp.topic] && exists[tp] {
+				kept[tp] = true
+				assigned[memberID] = append(assigned[memberID], tp)
+			}
+		}
+	}
+
+	leastAssigned := func(topic string) string {
+		least := subscribers[topic][0]
+		for _, memberID := range subscribers[topic][1:] {
+			if len(assigned[memberID]) < len(assigned[least]) {
+				least = memberID
+			}
+		}
+		return least
+	}
+	// the other partitions go to the subscriber with the fewest, those of the
+	// topics with the fewest subscribers first
+	sorted := sortedTopicPartitions(topics)
+	for n := 1; n <= len(memberIDs); n++ {
+		for _, tp := range sorted {
+			if !kept[tp] && len(subscribers[tp.topic]) == n {
+				memberID := leastAssigned(tp.topic)
+				assigned[memberID] = append(assigned[memberID], tp)
+			}
+		}
+	}
+
+	// then partitions move from the members with more than one partition more
+	// than a subscriber of their topic, until none does; each move makes the
+	// plan more balanced, so this ends
+	for moved := true; moved; {
+		moved = false
+		for _, from := range memberIDs {
+			for i := len(assigned[from]) - 1; i >= 0; i-- {
+				tp := assigned[from][i]
+				to := leastAssigned(tp.topic)
+				if len(assigned[to])+1 < len(assigned[from]) {
+					assigned[from] = append(assigned[from][:i], assigned[from][i+1:]...)
+					assigned[to] = append(assigned[to], tp)
+					moved = true
+				}
+			}
+		}
+	}
+
+	plan := make(BalanceStrategyPlan, len(members))
+	for _, memberID := range memberIDs {
+		partitions := topicPartitionSlice(assigned[memberID])
+		sort.Sort(partitions)
+		for _, tp := range partitions {
+			if owner, owned := owners[tp]; s.cooperative && owned && owner != memberID {
+				// the owner must release it first
+				continue
+			}
+			plan.Add(memberID, tp.topic, tp.partition)
+		}
+	}
+	return plan, nil
+}
+
+// sortedTopicPartitions returns the partitions of each topic, ordered by topic
+// and partition.
+func sortedTopicPartitions(topics map[string][]int32) []topicPartition {
+	var partitions []topicPartition
+	for topic, ids := range topics {
+		for _, partition := range ids {
+			partitions = append(partitions, topicPartition{topic, partition})
+		}
+	}
+	sort.Sort(topicPartitionSlice(partitions))
+	return partitions
+}
+
+// topicPartitionSlice sorts partitions by topic and partition.
+type topicPartitionSlice []topicPartition
+
+func (s topicPartitionSlice) Len() int      { return len(s) }
+func (s topicPartitionSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s topicPartitionSlice) Less(i, j int) bool {
+	if s[i].topic != s[j].topic {
+		return s[i].topic < s[j].topic
+	}
+	return s[i].partition < s[j].partition
+}
@@ -0,0 +1,180 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+var balanceStrategyTests = []struct {
+	strategy BalanceStrategy
+	members  map[string][]string
+	topics   map[string][]int32
+	expected BalanceStrategyPlan
+}{
+	{
+		BalanceStrategyRange,
+		map[string][]string{"M1": {"T1", "T2"}, "M2": {"T1", "T2"}},
+		map[string][]int32{"T1": {0, 1, 2}, "T2": {2, 1, 0}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0, 1}, "T2": {0, 1}},
+			"M2": {"T1": {2}, "T2": {2}},
+		},
+	},
+	{
+		BalanceStrategyRange,
+		map[string][]string{"M1": {"T1"}, "M2": {"T1", "T2"}, "M3": {"T1"}},
+		map[string][]int32{"T1": {0, 1}, "T2": {0, 1}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0}},
+			"M2": {"T1": {1}, "T2": {0, 1}},
+		},
+	},
+	{
+		BalanceStrategyRoundRobin,
+		map[string][]string{"M1": {"T1", "T2"}, "M2": {"T1", "T2"}},
+		map[string][]int32{"T1": {0, 1, 2}, "T2": {2, 1, 0}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0, 2}, "T2": {1}},
+			"M2": {"T1": {1}, "T2": {0, 2}},
+		},
+	},
+	{
+		BalanceStrategyRoundRobin,
+		map[string][]string{"M1": {"T1"}, "M2": {"T1", "T2"}, "M3": {"T1", "T3"}},
+		map[string][]int32{"T1": {0, 1, 2}, "T2": {0, 1}, "T3": {0}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0}},
+			"M2": {"T1": {1}, "T2": {0, 1}},
+			"M3": {"T1": {2}, "T3": {0}},
+		},
+	},
+}
+
+func TestBalanceStrategies(t *testing.T) {
+	for i, test := range balanceStrategyTests {
+		members := make(map[string]ConsumerGroupMemberMetadata)
+		for memberID, topics := range test.members {
+			members[memberID] = ConsumerGroupMemberMetadata{Topics: topics}
+		}
+
+		plan, err := test.strategy.Plan(members, test.topics)
+		if err != nil {
+			t.Error(i, err)
+		} else if !reflect.DeepEqual(plan, test.expected) {
+			t.Errorf("Expected the %s plan %d to be %v, got %v", test.strategy.Name(), i, test.expected, plan)
+		}
+	}
+}
+
+var stickyBalanceStrategyTests = []struct {
+	strategy BalanceStrategy
+	members  map[string]ConsumerGroupMemberMetadata
+	topics   map[string][]int32
+	expected BalanceStrategyPlan
+}{
+	{
+		BalanceStrategySticky,
+		map[string]ConsumerGroupMemberMetadata{
+			"M1": {Topics: []string{"T1", "T2"}},
+			"M2": {Topics: []string{"T1", "T2"}},
+			"M3": {Topics: []string{"T1", "T2"}},
+		},
+		map[string][]int32{"T1": {0, 1, 2}, "T2": {1, 0}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0}, "T2": {0}},
+			"M2": {"T1": {1}, "T2": {1}},
+			"M3": {"T1": {2}},
+		},
+	},
+	{
+		BalanceStrategySticky,
+		map[string]ConsumerGroupMemberMetadata{
+			"M1": {Topics: []string{"T1"}, OwnedPartitions: map[string][]int32{"T1": {0, 1, 2, 3}}},
+			"M2": {Topics: []string{"T1"}},
+		},
+		map[string][]int32{"T1": {0, 1, 2, 3}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0, 1}},
+			"M2": {"T1": {2, 3}},
+		},
+	},
+	{
+		BalanceStrategySticky,
+		map[string]ConsumerGroupMemberMetadata{
+			"M1": {Topics: []string{"T1"}, OwnedPartitions: map[string][]int32{"T1": {0, 1}}},
+			"M2": {Topics: []string{"T1"}, OwnedPartitions: map[string][]int32{"T1": {2, 3}}},
+		},
+		map[string][]int32{"T1": {0, 1, 2, 3, 4, 5}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0, 1, 4}},
+			"M2": {"T1": {2, 3, 5}},
+		},
+	},
+	{
+		BalanceStrategySticky,
+		map[string]ConsumerGroupMemberMetadata{
+			"M1": {Topics: []string{"T1"}},
+			"M2": {Topics: []string{"T1", "T2"}},
+		},
+		map[string][]int32{"T1": {0, 1}, "T2": {0, 1}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0, 1}},
+			"M2": {"T2": {0, 1}},
+		},
+	},
+	{
+		// partitions that no longer exist or that two members owned
+		BalanceStrategySticky,
+		map[string]ConsumerGroupMemberMetadata{
+			"M1": {Topics: []string{"T1"}, OwnedPartitions: map[string][]int32{"T1": {0, 5}}},
+			"M2": {Topics: []string{"T1"}, OwnedPartitions: map[string][]int32{"T1": {0}}},
+		},
+		map[string][]int32{"T1": {0, 1}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0}},
+			"M2": {"T1": {1}},
+		},
+	},
+	{
+		// the partitions that move are left for the next rebalance
+		BalanceStrategyCooperativeSticky,
+		map[string]ConsumerGroupMemberMetadata{
+			"M1": {Topics: []string{"T1"}, OwnedPartitions: map[string][]int32{"T1": {0, 1, 2, 3}}},
+			"M2": {Topics: []string{"T1"}},
+		},
+		map[string][]int32{"T1": {0, 1, 2, 3}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0, 1}},
+		},
+	},
+	{
+		BalanceStrategyCooperativeSticky,
+		map[string]ConsumerGroupMemberMetadata{
+			"M1": {Topics: []string{"T1"}, OwnedPartitions: map[string][]int32{"T1": {0, 1}}},
+			"M2": {Topics: []string{"T1"}},
+		},
+		map[string][]int32{"T1": {0, 1, 2, 3}},
+		BalanceStrategyPlan{
+			"M1": {"T1": {0, 1}},
+			"M2": {"T1": {2, 3}},
+		},
+	},
+}
+
+func TestStickyBalanceStrategies(t *testing.T) {
+	for i, test := range stickyBalanceStrategyTests {
+		plan, err := test.strategy.Plan(test.members, test.topics)
+		if err != nil {
+			t.Error(i, err)
+		} else if !reflect.DeepEqual(plan, test.expected) {
+			t.Errorf("Expected the %s plan %d to be %v, got %v", test.strategy.Name(), i, test.expected, plan)
+		}
+	}
+
+	if rebalanceProtocol(BalanceStrategyRange) != RebalanceProtocolEager || rebalanceProtocol(BalanceStrategySticky) != RebalanceProtocolEager {
+		t.Error("Expected the range and sticky strategies to be eager")
+	}
+	if rebalanceProtocol(BalanceStrategyCooperativeSticky) != RebalanceProtocolCooperative {
+		t.Error("Expected the cooperative-sticky strategy to be cooperative")
+	}
+}
@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -9,12 +10,18 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 // Broker represents a single Kafka broker connection. All operations on this object are entirely concurrency-safe.
 type Broker struct {
 	id   int32
 	addr string
+	rack *string // from metadata responses of version 1 and later
+	// serverName is the host a seed broker's address was resolved from, to
+	// verify its TLS certificate against
+	serverName string
 
 	conf          *Config
 	correlationID int32
@@ -22,13 +29,31 @@ type Broker struct {
 	connErr       error
 	lock          sync.Mutex
 	opened        int32
+	writeBuf      []byte // reused to encode requests, guarded by lock
+	// apiVersions are the versions of each API the broker supports, by key, if
+	// it was asked with an ApiVersionsRequest; guarded by lock
+	apiVersions map[int16]*ApiVersionsResponseBlock
 
 	responses chan responsePromise
 	done      chan bool
+
+	// metrics aggregates across all brokers sharing the registry;
+	// brokerMetrics is nil until the broker's ID is known
+	metrics       *brokerMetrics
+	brokerMetrics *brokerMetrics
+
+	observers atomic.Value // []RequestObserver, stored by Open so it can be read without the lock
+
+	// throttledUntil is when the broker stops throttling the client, if it
+	// throttles it after responding; guarded by lock
+	throttledUntil time.Time
 }
 
 type responsePromise struct {
 	correlationID int32
+	apiKey        int16
+	apiVersion    int16
+	requestTime   time.Time
 	packets       chan []byte
 	errors        chan error
 }
@@ -62,46 +87,285 @@ func (b *Broker) Open(conf *Config) error {
 
 	if b.conn != nil {
 		b.lock.Unlock()
-		Logger.Printf("Failed to connect to broker %s: %s\n", b.addr, ErrAlreadyConnected)
+		LogBroker.error("failed to connect to broker", "addr", b.addr, "err", ErrAlreadyConnected)
 		return ErrAlreadyConnected
 	}
 
+	b.observers.Store(conf.RequestObservers)
+
 	go withRecover(func() {
-		defer b.lock.Unlock()
+		event, err := b.connect(conf)
+		b.observeConnection(conf, event, err)
+	})
 
-		dialer := net.Dialer{
-			Timeout:   conf.Net.DialTimeout,
-			KeepAlive: conf.Net.KeepAlive,
-		}
+	return nil
+}
 
-		if conf.Net.TLS.Enable {
-			b.conn, b.connErr = tls.DialWithDialer(&dialer, "tcp", b.addr, conf.Net.TLS.Config)
-		} else {
-			b.conn, b.connErr = dialer.Dial("tcp", b.addr)
+// connect dials the broker and sets up the connection, then releases the lock
+// taken by Open. It returns the resulting ConnectionEvent for the observers.
+func (b *Broker) connect(conf *Config) (ConnectionEvent, error) {
+	defer b.lock.Unlock()
+
+	connMetrics := b.connectionMetrics(conf.MetricRegistry)
+	for _, m := range connMetrics {
+		m.attemptRate.Mark(1)
+	}
+
+	start := time.Now()
+	var authFailed bool
+	b.conn, authFailed, b.connErr = b.dial(conf)
+	b.apiVersions = nil
+	if b.connErr == nil && conf.ApiVersionsRequest && conf.Version.IsAtLeast(V0_10_0_0) {
+		if b.connErr = b.requestApiVersions(conf); b.connErr != nil {
+			_ = b.conn.Close()
 		}
-		if b.connErr != nil {
-			b.conn = nil
-			atomic.StoreInt32(&b.opened, 0)
-			Logger.Printf("Failed to connect to broker %s: %s\n", b.addr, b.connErr)
-			return
+	}
+	if b.connErr == nil && conf.Net.SASL.Enable {
+		if b.connErr = b.authenticateSASL(conf); b.connErr != nil {
+			_ = b.conn.Close()
+			authFailed = true
+		}
+	}
+	if b.connErr != nil {
+		for _, m := range connMetrics {
+			m.failureRate.Mark(1)
+			if authFailed {
+				m.authenticationFailure.Mark(1)
+			}
 		}
-		b.conn = newBufConn(b.conn)
+		b.conn = nil
+		atomic.StoreInt32(&b.opened, 0)
+		LogBroker.error("failed to connect to broker", "addr", b.addr, "err", b.connErr)
+		if authFailed {
+			return ConnectionAuthenticationFailed, b.connErr
+		}
+		return ConnectionFailed, b.connErr
+	}
+	for _, m := range connMetrics {
+		m.creationRate.Mark(1)
+		m.connectionCount.Inc(1)
+		m.handshakeLatency.Update(int64(time.Since(start) / time.Millisecond))
+	}
+	b.conn = newBufConn(b.conn)
 
-		b.conf = conf
-		b.done = make(chan bool)
-		b.responses = make(chan responsePromise, b.conf.Net.MaxOpenRequests-1)
+	b.conf = conf
+	b.done = make(chan bool)
+	b.responses = make(chan responsePromise, b.conf.Net.MaxOpenRequests-1)
+
+	b.metrics = newBrokerMetrics(conf.MetricRegistry, func(name string) string { return name })
+	if b.id >= 0 {
+		b.brokerMetrics = newBrokerMetrics(conf.MetricRegistry, func(name string) string {
+			return getMetricNameForBroker(name, b)
+		})
+	}
+
+	if b.id >= 0 {
+		LogBroker.info("connected to broker", "addr", b.addr, "broker", b.id)
+	} else {
+		LogBroker.info("connected to unregistered broker", "addr", b.addr)
+	}
+	go withRecover(b.responseReceiver)
+
+	return ConnectionOpened, nil
+}
 
-		if b.id >= 0 {
-			Logger.Printf("Connected to broker at %s (registered as #%d)\n", b.addr, b.id)
+// dial connects to the broker's address. It reports whether a failure happened
+// during the TLS handshake rather than while connecting.
+func (b *Broker) dial(conf *Config) (conn net.Conn, handshakeFailed bool, err error) {
+	dialer := net.Dialer{
+		Timeout:   conf.Net.DialTimeout,
+		KeepAlive: conf.Net.KeepAlive,
+	}
+
+	if !conf.Net.TLS.Enable {
+		conn, err = dialer.Dial("tcp", b.addr)
+		return conn, false, err
+	}
+
+	tlsConfig := conf.Net.TLS.Config
+	if b.serverName != "" && (tlsConfig == nil || tlsConfig.ServerName == "") {
+		if tlsConfig == nil {
+			tlsConfig = new(tls.Config)
 		} else {
-			Logger.Printf("Connected to broker at %s (unregistered)\n", b.addr)
+			tlsConfig = tlsConfig.Clone()
 		}
-		go withRecover(b.responseReceiver)
-	})
+		tlsConfig.ServerName = b.serverName
+	}
+
+	conn, err = tls.DialWithDialer(&dialer, "tcp", b.addr, tlsConfig)
+	if err != nil {
+		// errors from the dial itself are reported as such; anything else
+		// (alerts, certificate errors, a hang-up) comes from the handshake
+		opErr, isOpErr := err.(*net.OpError)
+		handshakeFailed = !isOpErr || opErr.Op != "dial"
+	}
+	return conn, handshakeFailed, err
+}
+
+// requestApiVersions asks the broker of the new connection which versions of
+// each API it supports, which requests are then negotiated down to.
+func (b *Broker) requestApiVersions(conf *Config) error {
+	response := new(ApiVersionsResponse)
+	if err := b.roundTripUnreceived(conf, &ApiVersionsRequest{}, response); err != nil {
+		return err
+	}
+	if response.Err != ErrNoError {
+		LogBroker.error("failed to get the API versions of broker", "addr", b.addr, "err", response.Err)
+		return response.Err
+	}
 
+	b.apiVersions = make(map[int16]*ApiVersionsResponseBlock, len(response.ApiVersions))
+	for _, block := range response.ApiVersions {
+		b.apiVersions[block.ApiKey] = block
+	}
 	return nil
 }
 
+// negotiateVersion lowers rb to the newest version the broker supports, if the
+// broker said which versions it supports and rb is a downgradableBody. It
+// returns ErrUnsupportedVersion if the broker supports no version rb can be
+// sent at. The caller must hold the lock.
+func (b *Broker) negotiateVersion(rb requestBody) error {
+	if b.apiVersions == nil {
+		return nil
+	}
+	supported, ok := b.apiVersions[rb.key()]
+	if !ok || rb.version() < supported.MinVersion {
+		return ErrUnsupportedVersion
+	}
+	if rb.version() <= supported.MaxVersion {
+		return nil
+	}
+	if d, ok := rb.(downgradableBody); ok && d.minVersion() <= supported.MaxVersion {
+		d.setVersion(supported.MaxVersion)
+		return nil
+	}
+	return ErrUnsupportedVersion
+}
+
+// authenticateSASL authenticates the new connection with the mechanism of
+// Net.SASL. Nothing else is sent on the connection until it is done, so it
+// reads the responses itself rather than through responseReceiver.
+func (b *Broker) authenticateSASL(conf *Config) error {
+	session, err := newSASLSession(conf)
+	if err != nil {
+		return err
+	}
+
+	handshake := &SaslHandshakeRequest{Mechanism: string(conf.Net.SASL.Mechanism)}
+	if conf.Version.IsAtLeast(V1_0_0_0) {
+		handshake.Version = 1
+	}
+	if err := b.negotiateVersion(handshake); err != nil {
+		return err
+	}
+	handshakeResponse := new(SaslHandshakeResponse)
+	if err := b.roundTripUnreceived(conf, handshake, handshakeResponse); err != nil {
+		return err
+	}
+	if handshakeResponse.Err != ErrNoError {
+		LogBroker.error("SASL handshake failed", "addr", b.addr, "mechanism", handshake.Mechanism, "enabled", handshakeResponse.EnabledMechanisms, "err", handshakeResponse.Err)
+		return handshakeResponse.Err
+	}
+
+	var challenge []byte
+	for {
+		token, done, err := session.next(challenge)
+		if err != nil || done {
+			return err
+		}
+		if handshake.Version == 0 {
+			challenge, err = b.exchangeRawSASLToken(conf, token)
+		} else {
+			challenge, err = b.exchangeSASLToken(conf, token)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// exchangeSASLToken sends a token in a SaslAuthenticateRequest and returns the
+// broker's challenge.
+func (b *Broker) exchangeSASLToken(conf *Config, token []byte) ([]byte, error) {
+	response := new(SaslAuthenticateResponse)
+	if err := b.roundTripUnreceived(conf, &SaslAuthenticateRequest{SaslAuthBytes: token}, response); err != nil {
+		return nil, err
+	}
+	if response.Err != ErrNoError {
+		LogBroker.error("SASL authentication failed", "addr", b.addr, "err", response.Err, "message", response.ErrorMessage)
+		return nil, response.Err
+	}
+	return response.SaslAuthBytes, nil
+}
+
+// exchangeRawSASLToken sends a token prefixed by its length, as after a version
+// 0 handshake, and returns the broker's challenge, framed the same way.
+func (b *Broker) exchangeRawSASLToken(conf *Config, token []byte) ([]byte, error) {
+	if err := b.conn.SetWriteDeadline(time.Now().Add(conf.Net.WriteTimeout)); err != nil {
+		return nil, err
+	}
+	if err := writeFrame(b.conn, token); err != nil {
+		return nil, err
+	}
+	if err := b.conn.SetReadDeadline(time.Now().Add(conf.Net.ReadTimeout)); err != nil {
+		return nil, err
+	}
+	return readFrame(b.conn, conf.maxResponseSize())
+}
+
+// roundTripUnreceived sends a request and reads its response directly from the
+// connection, for requests sent before responseReceiver is started.
+func (b *Broker) roundTripUnreceived(conf *Config, rb requestBody, res decoder) error {
+	req := &request{correlationID: b.correlationID, clientID: conf.ClientID, body: rb}
+	buf, err := encodeInto(req, nil, conf.maxRequestSize())
+	if err != nil {
+		return err
+	}
+	if err := b.conn.SetWriteDeadline(time.Now().Add(conf.Net.WriteTimeout)); err != nil {
+		return err
+	}
+	if _, err := b.conn.Write(buf); err != nil {
+		return err
+	}
+	b.correlationID++
+
+	if err := b.conn.SetReadDeadline(time.Now().Add(conf.Net.ReadTimeout)); err != nil {
+		return err
+	}
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(b.conn, header); err != nil {
+		return err
+	}
+	decodedHeader := responseHeader{}
+	if err := decode(header, &decodedHeader); err != nil {
+		return err
+	}
+	if err := conf.checkResponseSize(decodedHeader.length); err != nil {
+		return err
+	}
+	if decodedHeader.correlationID != req.correlationID {
+		return PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", req.correlationID, decodedHeader.correlationID)}
+	}
+	payload := make([]byte, decodedHeader.length-4)
+	if _, err := io.ReadFull(b.conn, payload); err != nil {
+		return err
+	}
+	return decode(payload, res)
+}
+
+// connectionMetrics returns the connection metrics to update, aggregated and,
+// if the broker's ID is known, for this broker.
+func (b *Broker) connectionMetrics(r metrics.Registry) []*connectionMetrics {
+	connMetrics := []*connectionMetrics{newConnectionMetrics(r, func(name string) string { return name })}
+	if b.id >= 0 {
+		connMetrics = append(connMetrics, newConnectionMetrics(r, func(name string) string {
+			return getMetricNameForBroker(name, b)
+		}))
+	}
+	return connMetrics
+}
+
 // Connected returns true if the broker is connected and false otherwise. If the broker is not
 // connected but it had tried to connect, the error from that connection attempt is also returned.
 func (b *Broker) Connected() (bool, error) {
@@ -113,9 +377,9 @@ func (b *Broker) Connected() (bool, error) {
 
 func (b *Broker) Close() error {
 	b.lock.Lock()
-	defer b.lock.Unlock()
 
 	if b.conn == nil {
+		b.lock.Unlock()
 		return ErrNotConnected
 	}
 
@@ -128,14 +392,25 @@ func (b *Broker) Close() error {
 	b.connErr = nil
 	b.done = nil
 	b.responses = nil
+	b.writeBuf = nil
+	b.metrics = nil
+	b.brokerMetrics = nil
+
+	for _, m := range b.connectionMetrics(b.conf.MetricRegistry) {
+		m.closeRate.Mark(1)
+		m.connectionCount.Dec(1)
+	}
 
 	atomic.StoreInt32(&b.opened, 0)
+	conf := b.conf
+	b.lock.Unlock()
 
 	if err == nil {
-		Logger.Printf("Closed connection to broker %s\n", b.addr)
+		LogBroker.info("closed connection to broker", "addr", b.addr)
 	} else {
-		Logger.Printf("Error while closing connection to broker %s: %s\n", b.addr, err)
+		LogBroker.error("error while closing connection to broker", "addr", b.addr, "err", err)
 	}
+	b.observeConnection(conf, ConnectionClosed, err)
 
 	return err
 }
@@ -150,8 +425,17 @@ func (b *Broker) Addr() string {
 	return b.addr
 }
 
+// Rack returns the rack of the broker as retrieved from Kafka's metadata, or
+// the empty string if it is unknown or the broker has none.
+func (b *Broker) Rack() string {
+	if b.rack == nil {
+		return ""
+	}
+	return *b.rack
+}
+
 func (b *Broker) GetMetadata(request *MetadataRequest) (*MetadataResponse, error) {
-	response := new(MetadataResponse)
+	response := &MetadataResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 
@@ -163,7 +447,7 @@ func (b *Broker) GetMetadata(request *MetadataRequest) (*MetadataResponse, error
 }
 
 func (b *Broker) GetConsumerMetadata(request *ConsumerMetadataRequest) (*ConsumerMetadataResponse, error) {
-	response := new(ConsumerMetadataResponse)
+	response := &ConsumerMetadataResponse{Version: request.Version}
 
 	err := b.sendAndReceive(request, response)
 
@@ -187,25 +471,67 @@ func (b *Broker) GetAvailableOffsets(request *OffsetRequest) (*OffsetResponse, e
 }
 
 func (b *Broker) Produce(request *ProduceRequest) (*ProduceResponse, error) {
-	var response *ProduceResponse
-	var err error
+	wait, err := b.asyncProduce(request)
+	if err != nil {
+		return nil, err
+	}
+	return wait()
+}
 
-	if request.RequiredAcks == NoResponse {
-		err = b.sendAndReceive(request, nil)
-	} else {
-		response = new(ProduceResponse)
-		err = b.sendAndReceive(request, response)
+// asyncProduce sends the request, returning a function which waits for and
+// returns its response, so that callers can keep several produce requests in
+// flight on the connection at once.
+func (b *Broker) asyncProduce(request *ProduceRequest) (func() (*ProduceResponse, error), error) {
+	start := time.Now()
+	if err := b.waitThrottled(context.Background()); err != nil {
+		return nil, err
+	}
+	promise, err := b.send(request, request.RequiredAcks != NoResponse)
+	if err != nil {
+		b.observe(request, nil, err, start)
+		return nil, err
+	}
+
+	if promise == nil {
+		b.observe(request, nil, nil, start)
+		return func() (*ProduceResponse, error) { return nil, nil }, nil
 	}
 
+	return func() (*ProduceResponse, error) {
+		response := &ProduceResponse{Version: request.Version}
+		err := promise.wait(response)
+		b.observe(request, response, err, start)
+		if err != nil {
+			return nil, err
+		}
+		b.throttled("produce", request.key(), response.Version, response.ThrottleTime)
+		return response, nil
+	}, nil
+}
+
+func (b *Broker) Fetch(request *FetchRequest) (*FetchResponse, error) {
+	return b.FetchContext(context.Background(), request)
+}
+
+// FetchContext is like Fetch, but stops waiting for the response when ctx is
+// done and returns ctx.Err(). The request may still have reached the broker;
+// its response is discarded when it arrives.
+func (b *Broker) FetchContext(ctx context.Context, request *FetchRequest) (*FetchResponse, error) {
+	response := &FetchResponse{Version: request.Version}
+
+	err := b.sendAndReceiveContext(ctx, request, response)
+
 	if err != nil {
 		return nil, err
 	}
 
+	b.throttled("fetch", request.key(), response.Version, response.ThrottleTime)
 	return response, nil
 }
 
-func (b *Broker) Fetch(request *FetchRequest) (*FetchResponse, error) {
-	response := new(FetchResponse)
+// ApiVersions asks the broker which versions of each API it supports.
+func (b *Broker) ApiVersions(request *ApiVersionsRequest) (*ApiVersionsResponse, error) {
+	response := new(ApiVersionsResponse)
 
 	err := b.sendAndReceive(request, response)
 
@@ -240,6 +566,270 @@ func (b *Broker) FetchOffset(request *OffsetFetchRequest) (*OffsetFetchResponse,
 	return response, nil
 }
 
+func (b *Broker) JoinGroup(request *JoinGroupRequest) (*JoinGroupResponse, error) {
+	response := new(JoinGroupResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) SyncGroup(request *SyncGroupRequest) (*SyncGroupResponse, error) {
+	response := new(SyncGroupResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) LeaveGroup(request *LeaveGroupRequest) (*LeaveGroupResponse, error) {
+	response := new(LeaveGroupResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) Heartbeat(request *HeartbeatRequest) (*HeartbeatResponse, error) {
+	response := new(HeartbeatResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) CreateTopics(request *CreateTopicsRequest) (*CreateTopicsResponse, error) {
+	response := &CreateTopicsResponse{Version: request.Version}
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) DeleteTopics(request *DeleteTopicsRequest) (*DeleteTopicsResponse, error) {
+	response := &DeleteTopicsResponse{Version: request.Version}
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) CreatePartitions(request *CreatePartitionsRequest) (*CreatePartitionsResponse, error) {
+	response := new(CreatePartitionsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) InitProducerID(request *InitProducerIDRequest) (*InitProducerIDResponse, error) {
+	response := new(InitProducerIDResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) AddPartitionsToTxn(request *AddPartitionsToTxnRequest) (*AddPartitionsToTxnResponse, error) {
+	response := new(AddPartitionsToTxnResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) AddOffsetsToTxn(request *AddOffsetsToTxnRequest) (*AddOffsetsToTxnResponse, error) {
+	response := new(AddOffsetsToTxnResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) EndTxn(request *EndTxnRequest) (*EndTxnResponse, error) {
+	response := new(EndTxnResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) TxnOffsetCommit(request *TxnOffsetCommitRequest) (*TxnOffsetCommitResponse, error) {
+	response := new(TxnOffsetCommitResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) DeleteRecords(request *DeleteRecordsRequest) (*DeleteRecordsResponse, error) {
+	response := new(DeleteRecordsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) DescribeAcls(request *DescribeAclsRequest) (*DescribeAclsResponse, error) {
+	response := new(DescribeAclsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) CreateAcls(request *CreateAclsRequest) (*CreateAclsResponse, error) {
+	response := new(CreateAclsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) DeleteAcls(request *DeleteAclsRequest) (*DeleteAclsResponse, error) {
+	response := new(DeleteAclsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) DescribeConfigs(request *DescribeConfigsRequest) (*DescribeConfigsResponse, error) {
+	response := new(DescribeConfigsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) AlterConfigs(request *AlterConfigsRequest) (*AlterConfigsResponse, error) {
+	response := new(AlterConfigsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) ListGroups(request *ListGroupsRequest) (*ListGroupsResponse, error) {
+	response := new(ListGroupsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) DescribeGroups(request *DescribeGroupsRequest) (*DescribeGroupsResponse, error) {
+	response := new(DescribeGroupsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) GetTelemetrySubscriptions(request *GetTelemetrySubscriptionsRequest) (*GetTelemetrySubscriptionsResponse, error) {
+	response := new(GetTelemetrySubscriptionsResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (b *Broker) PushTelemetry(request *PushTelemetryRequest) (*PushTelemetryResponse, error) {
+	response := new(PushTelemetryResponse)
+
+	err := b.sendAndReceive(request, response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 func (b *Broker) send(rb requestBody, promiseResponse bool) (*responsePromise, error) {
 	b.lock.Lock()
 	defer b.lock.Unlock()
@@ -250,19 +840,58 @@ func (b *Broker) send(rb requestBody, promiseResponse bool) (*responsePromise, e
 		}
 		return nil, ErrNotConnected
 	}
+	if err := b.negotiateVersion(rb); err != nil {
+		return nil, err
+	}
 
 	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
-	buf, err := encode(req)
+
+	// produce requests carry the bulk of the data, so their message sets are
+	// written directly from where they already are instead of being copied
+	var buf []byte
+	var buffers net.Buffers
+	var err error
+	if _, ok := rb.(*ProduceRequest); ok {
+		buffers, buf, err = encodeVectored(req, b.writeBuf, b.conf.maxRequestSize())
+	} else {
+		buf, err = encodeInto(req, b.writeBuf, b.conf.maxRequestSize())
+	}
 	if err != nil {
 		return nil, err
 	}
+	// the connection is done with buf once the write returns, so keep it
+	// around for the next request unless it is unusually large
+	if cap(buf) <= maxPooledBufferSize {
+		b.writeBuf = buf
+	}
 
 	err = b.conn.SetWriteDeadline(time.Now().Add(b.conf.Net.WriteTimeout))
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = b.conn.Write(buf)
+	if b.conf.Net.Debug.DumpFrames {
+		frame := buffers
+		if frame == nil {
+			frame = net.Buffers{buf}
+		}
+		b.dumpRequestFrame(req, frame)
+	}
+
+	requestTime := time.Now()
+	var bytes int64
+	if buffers != nil {
+		if bc, ok := b.conn.(*bufConn); ok {
+			bytes, err = bc.writeBuffers(buffers)
+		} else {
+			bytes, err = buffers.WriteTo(b.conn)
+		}
+	} else {
+		var n int
+		n, err = b.conn.Write(buf)
+		bytes = int64(n)
+	}
+	b.updateOutgoingCommunicationMetrics(bytes)
 	if err != nil {
 		return nil, err
 	}
@@ -272,13 +901,49 @@ func (b *Broker) send(rb requestBody, promiseResponse bool) (*responsePromise, e
 		return nil, nil
 	}
 
-	promise := responsePromise{req.correlationID, make(chan []byte), make(chan error)}
+	promise := responsePromise{
+		correlationID: req.correlationID,
+		apiKey:        rb.key(),
+		apiVersion:    rb.version(),
+		requestTime:   requestTime,
+		packets:       make(chan []byte, 1),
+		errors:        make(chan error, 1),
+	}
+	b.addRequestInFlightMetrics(1)
 	b.responses <- promise
 
 	return &promise, nil
 }
 
 func (b *Broker) sendAndReceive(req requestBody, res decoder) error {
+	start := time.Now()
+	err := b.roundTrip(req, res)
+	b.observe(req, res, err, start)
+	return err
+}
+
+// sendAndReceiveContext is like sendAndReceive, but stops waiting for the
+// response when ctx is done. Writing the request is not interrupted; it is
+// bounded by Net.WriteTimeout.
+func (b *Broker) sendAndReceiveContext(ctx context.Context, req requestBody, res decoder) error {
+	start := time.Now()
+	err := b.roundTripContext(ctx, req, res)
+	b.observe(req, res, err, start)
+	return err
+}
+
+func (b *Broker) roundTrip(req requestBody, res decoder) error {
+	return b.roundTripContext(context.Background(), req, res)
+}
+
+func (b *Broker) roundTripContext(ctx context.Context, req requestBody, res decoder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := b.waitThrottled(ctx); err != nil {
+		return err
+	}
+
 	promise, err := b.send(req, res != nil)
 
 	if err != nil {
@@ -289,11 +954,28 @@ func (b *Broker) sendAndReceive(req requestBody, res decoder) error {
 		return nil
 	}
 
+	return promise.waitContext(ctx, res)
+}
+
+// wait blocks until the response to the promised request arrives, and decodes it into res.
+func (p *responsePromise) wait(res decoder) error {
+	return p.waitContext(context.Background(), res)
+}
+
+// waitContext is like wait, but gives up when ctx is done. The channels of the
+// promise are buffered, so the response receiver never blocks on a promise
+// nobody waits for any more.
+func (p *responsePromise) waitContext(ctx context.Context, res decoder) error {
+	if v, ok := res.(versionedResponse); ok {
+		v.setVersion(p.apiVersion)
+	}
 	select {
-	case buf := <-promise.packets:
+	case buf := <-p.packets:
 		return decode(buf, res)
-	case err = <-promise.errors:
+	case err := <-p.errors:
 		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
@@ -349,6 +1031,7 @@ func (b *Broker) responseReceiver() {
 	header := make([]byte, 8)
 	for response := range b.responses {
 		if dead != nil {
+			b.addRequestInFlightMetrics(-1)
 			response.errors <- dead
 			continue
 		}
@@ -356,6 +1039,7 @@ func (b *Broker) responseReceiver() {
 		err := b.conn.SetReadDeadline(time.Now().Add(b.conf.Net.ReadTimeout))
 		if err != nil {
 			dead = err
+			b.addRequestInFlightMetrics(-1)
 			response.errors <- err
 			continue
 		}
@@ -363,14 +1047,19 @@ func (b *Broker) responseReceiver() {
 		_, err = io.ReadFull(b.conn, header)
 		if err != nil {
 			dead = err
+			b.addRequestInFlightMetrics(-1)
 			response.errors <- err
 			continue
 		}
 
 		decodedHeader := responseHeader{}
 		err = decode(header, &decodedHeader)
+		if err == nil {
+			err = b.conf.checkResponseSize(decodedHeader.length)
+		}
 		if err != nil {
 			dead = err
+			b.addRequestInFlightMetrics(-1)
 			response.errors <- err
 			continue
 		}
@@ -378,19 +1067,133 @@ func (b *Broker) responseReceiver() {
 			// TODO if decoded ID < cur ID, discard until we catch up
 			// TODO if decoded ID > cur ID, save it so when cur ID catches up we have a response
 			dead = PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", response.correlationID, decodedHeader.correlationID)}
+			b.addRequestInFlightMetrics(-1)
 			response.errors <- dead
 			continue
 		}
 
 		buf := make([]byte, decodedHeader.length-4)
 		_, err = io.ReadFull(b.conn, buf)
+		b.addRequestInFlightMetrics(-1)
 		if err != nil {
 			dead = err
 			response.errors <- err
 			continue
 		}
+		latency := time.Since(response.requestTime)
+		b.updateIncomingCommunicationMetrics(len(header)+len(buf), latency)
+		if threshold := b.conf.Net.SlowRequestThreshold; threshold > 0 && latency >= threshold {
+			b.logSlowRequest(response, latency)
+		}
+		if b.conf.Net.Debug.DumpFrames {
+			b.dumpResponseFrame(response, header, buf)
+		}
 
 		response.packets <- buf
 	}
 	close(b.done)
 }
+
+// logSlowRequest logs a request whose response took longer than
+// Net.SlowRequestThreshold to arrive.
+func (b *Broker) logSlowRequest(promise responsePromise, latency time.Duration) {
+	LogBroker.warn("slow request",
+		"broker", b.id,
+		"addr", b.addr,
+		"api", apiName(promise.apiKey, promise.apiVersion),
+		"apiVersion", promise.apiVersion,
+		"correlationID", promise.correlationID,
+		"duration", latency,
+	)
+}
+
+func (b *Broker) updateIncomingCommunicationMetrics(bytes int, latency time.Duration) {
+	for _, m := range []*brokerMetrics{b.metrics, b.brokerMetrics} {
+		if m == nil {
+			continue
+		}
+		m.responseRate.Mark(1)
+		m.incomingByteRate.Mark(int64(bytes))
+		m.responseSize.Update(int64(bytes))
+		m.requestLatency.Update(int64(latency / time.Millisecond))
+	}
+}
+
+func (b *Broker) updateOutgoingCommunicationMetrics(bytes int64) {
+	for _, m := range []*brokerMetrics{b.metrics, b.brokerMetrics} {
+		if m == nil {
+			continue
+		}
+		m.requestRate.Mark(1)
+		m.outgoingByteRate.Mark(bytes)
+		m.requestSize.Update(bytes)
+	}
+}
+
+// throttledAfterResponse holds, by API key, the first version of the responses
+// that brokers send right away when they throttle the client, muting its
+// connection for the throttle time instead of delaying the response (KIP-219).
+var throttledAfterResponse = map[int16]int16{
+	0: 6, // Produce
+	1: 8, // Fetch
+}
+
+// throttled records the time a response of the given API was throttled for, if
+// its version reports it, and notifies the throttle observers. If the broker
+// throttles the client after responding, the requests that follow wait until
+// it stops.
+func (b *Broker) throttled(api string, key, version int16, throttleTime time.Duration) {
+	if version < 1 {
+		return
+	}
+	registry := b.conf.MetricRegistry
+	name := api + "-throttle-time-in-ms"
+	ms := int64(throttleTime / time.Millisecond)
+	getOrRegisterHistogram(name, registry).Update(ms)
+	if b.id >= 0 {
+		getOrRegisterHistogram(getMetricNameForBroker(name, b), registry).Update(ms)
+	}
+	if throttleTime <= 0 {
+		return
+	}
+
+	if after, ok := throttledAfterResponse[key]; ok && version >= after {
+		until := time.Now().Add(throttleTime)
+		b.lock.Lock()
+		if until.After(b.throttledUntil) {
+			b.throttledUntil = until
+		}
+		b.lock.Unlock()
+	}
+	for _, observer := range b.conf.ThrottleObservers {
+		observer.ObserveThrottle(b, api, throttleTime)
+	}
+}
+
+// waitThrottled waits until the broker stops throttling the client, or until
+// ctx is done.
+func (b *Broker) waitThrottled(ctx context.Context) error {
+	b.lock.Lock()
+	d := b.throttledUntil.Sub(time.Now())
+	b.lock.Unlock()
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Broker) addRequestInFlightMetrics(delta int64) {
+	for _, m := range []*brokerMetrics{b.metrics, b.brokerMetrics} {
+		if m != nil {
+			m.requestsInFlight.Inc(delta)
+		}
+	}
+}
@@ -1,8 +1,16 @@
 package sarama
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
+	"net"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 func ExampleBroker() {
@@ -52,7 +60,7 @@ func TestBrokerAccessors(t *testing.T) {
 }
 
 func TestSimpleBrokerCommunication(t *testing.T) {
-	mb := newMockBroker(t, 0)
+	mb := NewMockBroker(t, 0)
 	defer mb.Close()
 
 	broker := NewBroker(mb.Addr())
@@ -74,6 +82,82 @@ func TestSimpleBrokerCommunication(t *testing.T) {
 	}
 }
 
+func TestBrokerPipelinedProduce(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var waits []func() (*ProduceResponse, error)
+	for i := int32(0); i < 3; i++ {
+		response := new(ProduceResponse)
+		response.AddTopicPartition("my_topic", i, ErrNoError)
+		mb.Returns(response)
+
+		request := &ProduceRequest{RequiredAcks: WaitForLocal}
+		request.AddMessage("my_topic", i, &Message{Value: []byte(TestMessage)})
+		wait, err := broker.asyncProduce(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waits = append(waits, wait)
+	}
+
+	for i, wait := range waits {
+		response, err := wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.GetBlock("my_topic", int32(i)) == nil {
+			t.Error("Response", i, "does not belong to request", i)
+		}
+	}
+
+	if err := broker.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBrokerFetchContext(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := broker.FetchContext(ctx, new(FetchRequest)); err != context.Canceled {
+		t.Error("Expected a done context to stop the fetch before it is sent, got", err)
+	}
+
+	mb.SetLatency(200 * time.Millisecond)
+	mb.Returns(new(FetchResponse))
+	mb.Returns(new(FetchResponse))
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := broker.FetchContext(ctx, new(FetchRequest)); err != context.DeadlineExceeded {
+		t.Error("Expected the fetch to give up at the deadline, got", err)
+	}
+
+	// the response to the abandoned fetch is discarded, not mistaken for this one
+	if _, err := broker.Fetch(new(FetchRequest)); err != nil {
+		t.Error(err)
+	}
+	if len(mb.History()) != 2 {
+		t.Error("Expected the abandoned fetch to have been sent, got", len(mb.History()), "requests")
+	}
+
+	if err := broker.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
 // We're not testing encoding/decoding here, so most of the requests/responses will be empty for simplicity's sake
 var brokerTestTable = []struct {
 	response []byte
@@ -165,6 +249,78 @@ var brokerTestTable = []struct {
 			}
 		}},
 
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := JoinGroupRequest{}
+			response, err := broker.JoinGroup(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("JoinGroup request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := SyncGroupRequest{}
+			response, err := broker.SyncGroup(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("SyncGroup request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := LeaveGroupRequest{}
+			response, err := broker.LeaveGroup(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("LeaveGroup request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := HeartbeatRequest{}
+			response, err := broker.Heartbeat(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("Heartbeat request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := ListGroupsRequest{}
+			response, err := broker.ListGroups(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("ListGroups request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := DescribeGroupsRequest{}
+			response, err := broker.DescribeGroups(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("DescribeGroups request got no response!")
+			}
+		}},
+
 	{[]byte{0x00, 0x00, 0x00, 0x00},
 		func(t *testing.T, broker *Broker) {
 			request := OffsetRequest{}
@@ -176,4 +332,615 @@ var brokerTestTable = []struct {
 				t.Error("Offset request got no response!")
 			}
 		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := CreateTopicsRequest{}
+			response, err := broker.CreateTopics(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("CreateTopics request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := DeleteTopicsRequest{}
+			response, err := broker.DeleteTopics(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("DeleteTopics request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := CreatePartitionsRequest{}
+			response, err := broker.CreatePartitions(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("CreatePartitions request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := DescribeConfigsRequest{}
+			response, err := broker.DescribeConfigs(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("DescribeConfigs request got no response!")
+			}
+		}},
+
+	{[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		func(t *testing.T, broker *Broker) {
+			request := AlterConfigsRequest{}
+			response, err := broker.AlterConfigs(&request)
+			if err != nil {
+				t.Error(err)
+			}
+			if response == nil {
+				t.Error("AlterConfigs request got no response!")
+			}
+		}},
+}
+
+func TestBrokerMetrics(t *testing.T) {
+	mb := NewMockBroker(t, 7)
+	defer mb.Close()
+
+	config := NewConfig()
+	broker := NewBroker(mb.Addr())
+	broker.id = 7
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+
+	mb.Returns(new(MetadataResponse))
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := broker.Close(); err != nil {
+		t.Error(err)
+	}
+
+	for _, name := range []string{"request-rate", "request-rate-for-broker-7", "response-rate", "response-rate-for-broker-7"} {
+		meter, ok := config.MetricRegistry.Get(name).(metrics.Meter)
+		if !ok {
+			t.Error("Metric", name, "was not registered")
+		} else if meter.Count() != 1 {
+			t.Error("Expected", name, "to be 1, got", meter.Count())
+		}
+	}
+
+	if size := config.MetricRegistry.Get("request-size").(metrics.Histogram); size.Max() <= 0 {
+		t.Error("Expected the request size to be recorded")
+	}
+	if inFlight := config.MetricRegistry.Get("requests-in-flight").(metrics.Counter); inFlight.Count() != 0 {
+		t.Error("Expected no requests in flight, got", inFlight.Count())
+	}
+}
+
+func TestBrokerThrottleMetrics(t *testing.T) {
+	mb := NewMockBroker(t, 3)
+	defer mb.Close()
+
+	config := NewConfig()
+	broker := NewBroker(mb.Addr())
+	broker.id = 3
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+
+	mb.Returns(&ProduceResponse{Version: 1, ThrottleTime: 40 * time.Millisecond})
+	if _, err := broker.Produce(&ProduceRequest{Version: 1, RequiredAcks: WaitForLocal}); err != nil {
+		t.Fatal(err)
+	}
+	mb.Returns(&FetchResponse{Version: 1, ThrottleTime: 60 * time.Millisecond})
+	if _, err := broker.Fetch(&FetchRequest{Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	mb.Returns(new(FetchResponse))
+	if _, err := broker.Fetch(new(FetchRequest)); err != nil {
+		t.Fatal(err)
+	}
+	safeClose(t, broker)
+
+	for name, expected := range map[string]int64{
+		"produce-throttle-time-in-ms":              40,
+		"produce-throttle-time-in-ms-for-broker-3": 40,
+		"fetch-throttle-time-in-ms":                60,
+		"fetch-throttle-time-in-ms-for-broker-3":   60,
+	} {
+		histogram, ok := config.MetricRegistry.Get(name).(metrics.Histogram)
+		if !ok {
+			t.Error("Metric", name, "was not registered")
+		} else if histogram.Count() != 1 || histogram.Max() != expected {
+			t.Error("Expected", name, "to record", expected, "once, got", histogram.Max(), "in", histogram.Count())
+		}
+	}
+}
+
+func TestBrokerThrottlesAfterResponse(t *testing.T) {
+	mb := NewMockBroker(t, 3)
+	defer mb.Close()
+
+	var observed []time.Duration
+	config := NewConfig()
+	config.ThrottleObservers = []ThrottleObserver{ThrottleObserverFunc(func(broker *Broker, api string, throttleTime time.Duration) {
+		if api != "fetch" {
+			t.Error("Expected a fetch to be throttled, got", api)
+		}
+		observed = append(observed, throttleTime)
+	})}
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	// brokers delay the responses of older versions themselves
+	mb.Returns(&FetchResponse{Version: 7, ThrottleTime: time.Second})
+	if _, err := broker.Fetch(&FetchRequest{Version: 7}); err != nil {
+		t.Fatal(err)
+	}
+	mb.Returns(&FetchResponse{Version: 8, ThrottleTime: 100 * time.Millisecond})
+	start := time.Now()
+	if _, err := broker.Fetch(&FetchRequest{Version: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Error("Expected the fetch to be sent right away, took", elapsed)
+	}
+
+	mb.Returns(&FetchResponse{Version: 8})
+	if _, err := broker.Fetch(&FetchRequest{Version: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Error("Expected the fetch to wait for the throttle time, took", elapsed)
+	}
+	if len(observed) != 2 || observed[0] != time.Second || observed[1] != 100*time.Millisecond {
+		t.Error("Expected both throttle times to be observed, got", observed)
+	}
+
+	mb.Returns(&FetchResponse{Version: 8, ThrottleTime: time.Minute})
+	if _, err := broker.Fetch(&FetchRequest{Version: 8}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := broker.FetchContext(ctx, &FetchRequest{Version: 8}); err != context.DeadlineExceeded {
+		t.Error("Expected the throttled fetch to give up with its context, got", err)
+	}
+}
+
+func TestBrokerLogsSlowRequests(t *testing.T) {
+	logger := new(recordingLogger)
+	SetSubsystemLogger(LogBroker, logger, LogLevelWarn)
+	defer ResetSubsystemLogger(LogBroker)
+
+	mb := NewMockBroker(t, 2)
+	defer mb.Close()
+	mb.SetLatency(30 * time.Millisecond)
+	mb.Returns(new(MetadataResponse))
+
+	config := NewConfig()
+	config.Net.SlowRequestThreshold = 10 * time.Millisecond
+	broker := NewBroker(mb.Addr())
+	broker.id = 2
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Fatal(err)
+	}
+	safeClose(t, broker)
+
+	events := logger.recorded()
+	if len(events) != 1 || events[0].msg != "slow request" {
+		t.Fatal("Expected a slow request to be logged, got", events)
+	}
+	event := events[0]
+	if event.field("broker") != int32(2) || event.field("api") != "Metadata" || event.field("correlationID") != int32(0) {
+		t.Error("Unexpected slow request", event)
+	}
+	if duration, _ := event.field("duration").(time.Duration); duration < 30*time.Millisecond {
+		t.Error("Expected a duration of at least 30ms, got", event.field("duration"))
+	}
+}
+
+func TestBrokerRequestObservers(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+
+	var observed []interface{}
+	var observedErr error
+	config := NewConfig()
+	config.RequestObservers = []RequestObserver{RequestObserverFunc(func(broker *Broker, request, response interface{}, err error, latency time.Duration) {
+		observed = append(observed, request, response)
+		observedErr = err
+		if latency < 0 {
+			t.Error("Expected a non-negative latency, got", latency)
+		}
+	})}
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+
+	mb.Returns(new(MetadataResponse))
+	request := new(MetadataRequest)
+	response, err := broker.GetMetadata(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observed) != 2 || observed[0] != request || observed[1] != response || observedErr != nil {
+		t.Error("Expected the metadata round trip to be observed, got", observed, observedErr)
+	}
+
+	produce := &ProduceRequest{RequiredAcks: NoResponse}
+	if _, err := broker.Produce(produce); err != nil {
+		t.Fatal(err)
+	}
+	if len(observed) != 4 || observed[2] != produce || observed[3] != nil {
+		t.Error("Expected the produce request to be observed without a response, got", observed[2:])
+	}
+
+	if err := broker.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := broker.GetMetadata(request); err != ErrNotConnected {
+		t.Fatal("Expected ErrNotConnected, got", err)
+	}
+	if observedErr != ErrNotConnected || observed[len(observed)-1] != nil {
+		t.Error("Expected the failed request to be observed with its error, got", observedErr)
+	}
+}
+
+func TestBrokerConnectionObservers(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+
+	var lock sync.Mutex
+	var events []ConnectionEvent
+	config := NewConfig()
+	config.ConnectionObservers = []ConnectionObserver{ConnectionObserverFunc(func(broker *Broker, event ConnectionEvent, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event)
+	})}
+	// observers are notified after Connected returns, so wait for the events
+	observed := func(n int) []ConnectionEvent {
+		for i := 0; i < 100; i++ {
+			lock.Lock()
+			observed := append([]ConnectionEvent(nil), events...)
+			lock.Unlock()
+			if len(observed) >= n {
+				return observed
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatal("Timed out waiting for", n, "connection events")
+		return nil
+	}
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); !connected || err != nil {
+		t.Fatal("Expected the broker to connect, got", err)
+	}
+	observed(1)
+	safeClose(t, broker)
+
+	if events := observed(2); len(events) != 2 || events[0] != ConnectionOpened || events[1] != ConnectionClosed {
+		t.Error("Expected the connection to be opened and closed, got", events)
+	}
+	registry := config.MetricRegistry
+	if count := registry.Get("connection-creation-rate").(metrics.Meter).Count(); count != 1 {
+		t.Error("Expected 1 connection created, got", count)
+	}
+	if count := registry.Get("connection-close-rate").(metrics.Meter).Count(); count != 1 {
+		t.Error("Expected 1 connection closed, got", count)
+	}
+	if count := registry.Get("connection-count").(metrics.Counter).Count(); count != 0 {
+		t.Error("Expected no open connections, got", count)
+	}
+	if count := registry.Get("handshake-latency-in-ms").(metrics.Histogram).Count(); count != 1 {
+		t.Error("Expected 1 handshake latency, got", count)
+	}
+
+	// a listener hanging up immediately makes the TLS handshake fail
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	config.Net.TLS.Enable = true
+	broker = NewBroker(listener.Addr().String())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); connected || err == nil {
+		t.Fatal("Expected the TLS handshake to fail")
+	}
+
+	if events := observed(3); len(events) != 3 || events[2] != ConnectionAuthenticationFailed {
+		t.Error("Expected a failed authentication, got", events)
+	}
+	if count := registry.Get("connection-attempt-rate").(metrics.Meter).Count(); count != 2 {
+		t.Error("Expected 2 connection attempts, got", count)
+	}
+	if count := registry.Get("failed-authentication-rate").(metrics.Meter).Count(); count != 1 {
+		t.Error("Expected 1 failed authentication, got", count)
+	}
+	if count := registry.Get("connection-failure-rate").(metrics.Meter).Count(); count != 1 {
+		t.Error("Expected 1 failed connection, got", count)
+	}
+}
+
+func newSASLBrokerConfig(version KafkaVersion) *Config {
+	config := NewConfig()
+	config.Version = version
+	config.Net.SASL.Enable = true
+	config.Net.SASL.User = "user"
+	config.Net.SASL.Password = "pencil"
+	return config
+}
+
+func TestBrokerSASLAuthenticate(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"SaslHandshakeRequest":    NewMockWrapper(&SaslHandshakeResponse{EnabledMechanisms: []string{"PLAIN"}}),
+		"SaslAuthenticateRequest": NewMockWrapper(new(SaslAuthenticateResponse)),
+		"MetadataRequest":         NewMockMetadataResponse(t),
+	})
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(newSASLBrokerConfig(V1_0_0_0)); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Fatal(err)
+	}
+
+	history := mb.History()
+	if len(history) != 4 {
+		t.Fatal("Expected an API versions request, a handshake, a token and a metadata request, got", len(history))
+	}
+	if _, ok := history[0].Request.(*ApiVersionsRequest); !ok {
+		t.Error("Expected the API versions to be requested first, got", history[0].Request)
+	}
+	if handshake, ok := history[1].Request.(*SaslHandshakeRequest); !ok || handshake.Version != 1 || handshake.Mechanism != "PLAIN" {
+		t.Error("Expected a version 1 PLAIN handshake next, got", history[1].Request)
+	}
+	if authenticate, ok := history[2].Request.(*SaslAuthenticateRequest); !ok || string(authenticate.SaslAuthBytes) != "\x00user\x00pencil" {
+		t.Error("Expected the PLAIN token to be sent next, got", history[2].Request)
+	}
+}
+
+func TestBrokerSASLAuthenticationFailure(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"SaslHandshakeRequest":    NewMockWrapper(&SaslHandshakeResponse{EnabledMechanisms: []string{"PLAIN"}}),
+		"SaslAuthenticateRequest": NewMockWrapper(&SaslAuthenticateResponse{Err: ErrSASLAuthenticationFailed, ErrorMessage: "bad password"}),
+	})
+
+	config := newSASLBrokerConfig(V1_0_0_0)
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); connected || err != ErrSASLAuthenticationFailed {
+		t.Fatal("Expected the authentication to fail, got", err)
+	}
+	if count := config.MetricRegistry.Get("failed-authentication-rate").(metrics.Meter).Count(); count != 1 {
+		t.Error("Expected 1 failed authentication, got", count)
+	}
+
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"SaslHandshakeRequest": NewMockWrapper(&SaslHandshakeResponse{Err: ErrUnsupportedSASLMechanism, EnabledMechanisms: []string{"GSSAPI"}}),
+	})
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := broker.Connected(); connected || err != ErrUnsupportedSASLMechanism {
+		t.Fatal("Expected the handshake to fail, got", err)
+	}
+}
+
+// Before Kafka 1.0 the tokens follow the handshake raw, each prefixed by its
+// length, which the mock broker cannot parse, so a listener plays the broker.
+func TestBrokerSASLRawTokens(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	tokens := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := decodeRequest(conn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if handshake, ok := req.body.(*SaslHandshakeRequest); !ok || handshake.Version != 0 {
+			t.Error("Expected a version 0 handshake, got", req.body)
+		}
+		body, _ := encode(&SaslHandshakeResponse{EnabledMechanisms: []string{"PLAIN"}})
+		correlationID := make([]byte, 4)
+		binary.BigEndian.PutUint32(correlationID, uint32(req.correlationID))
+		_ = writeFrame(conn, append(correlationID, body...))
+
+		token, err := readFrame(conn, MaxRequestSize)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		tokens <- token
+		// PLAIN brokers answer with an empty challenge
+		_ = writeFrame(conn, nil)
+		_, _ = readFrame(conn, MaxRequestSize)
+	}()
+
+	// the listener only speaks SASL, so the API versions aren't asked for
+	config := newSASLBrokerConfig(V0_10_0_0)
+	config.ApiVersionsRequest = false
+	broker := NewBroker(listener.Addr().String())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+	if connected, err := broker.Connected(); !connected || err != nil {
+		t.Fatal("Expected the broker to authenticate, got", err)
+	}
+	if token := <-tokens; string(token) != "\x00user\x00pencil" {
+		t.Errorf("Expected the raw PLAIN token, got %q", token)
+	}
+}
+
+func TestBrokerNegotiatesVersions(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t).
+			SetApiVersions(3, 0, 0).
+			SetApiVersions(0, 0, 2).
+			RemoveApi(19),
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	config := NewConfig()
+	config.Version = V1_0_0_0
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	response, err := broker.GetMetadata(&MetadataRequest{Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Version != 0 {
+		t.Error("Expected the response to follow the version of the request, got", response.Version)
+	}
+	history := mb.History()
+	if len(history) != 2 {
+		t.Fatal("Expected an API versions and a metadata request, got", len(history))
+	}
+	if request, ok := history[1].Request.(*MetadataRequest); !ok || request.Version != 0 {
+		t.Error("Expected the metadata request to be lowered to version 0, got", history[1].Request)
+	}
+
+	if _, err := broker.Produce(&ProduceRequest{Version: 3, RequiredAcks: NoResponse}); err != ErrUnsupportedVersion {
+		t.Error("Expected a produce request of a newer version than supported to be refused, got", err)
+	}
+	if _, err := broker.CreateTopics(&CreateTopicsRequest{Version: 1}); err != ErrUnsupportedVersion {
+		t.Error("Expected a request of an unsupported API to be refused, got", err)
+	}
+	if len(mb.History()) != 2 {
+		t.Error("Expected refused requests not to be sent, got", mb.History())
+	}
+}
+
+func TestBrokerApiVersionsRequestRequiresVersion(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(NewConfig()); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range mb.History() {
+		if _, ok := rr.Request.(*ApiVersionsRequest); ok {
+			t.Error("Expected the API versions not to be asked for before V0_10_0_0")
+		}
+	}
+
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockWrapper(&ApiVersionsResponse{Err: ErrUnsupportedVersion}),
+	})
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	failing := NewBroker(mb.Addr())
+	if err := failing.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	if connected, err := failing.Connected(); connected || err != ErrUnsupportedVersion {
+		t.Error("Expected the connection to fail with the error of the API versions response, got", err)
+	}
+}
+
+func TestBrokerMaxRequestAndResponseSizes(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(mb.Addr(), mb.BrokerID()).
+			SetLeader(strings.Repeat("t", 100), 0, mb.BrokerID()),
+	})
+
+	config := NewConfig()
+	config.Net.MaxRequestSize = 100
+	config.Net.MaxResponseSize = 100
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	if _, err := broker.GetMetadata(&MetadataRequest{Topics: []string{strings.Repeat("t", 100)}}); err == nil {
+		t.Fatal("Expected a request larger than Net.MaxRequestSize to be refused")
+	} else if _, ok := err.(PacketEncodingError); !ok {
+		t.Fatal("Expected a PacketEncodingError, got", err)
+	}
+	if len(mb.History()) != 0 {
+		t.Error("Expected the request not to be sent")
+	}
+
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err == nil {
+		t.Fatal("Expected a response larger than Net.MaxResponseSize to fail")
+	} else if _, ok := err.(PacketDecodingError); !ok {
+		t.Fatal("Expected a PacketDecodingError, got", err)
+	}
 }
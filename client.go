@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"math/rand"
+	"net"
 	"sort"
 	"sync"
 	"time"
@@ -42,7 +43,10 @@ type Client interface {
 	// GetOffset queries the cluster to get the most recent available offset at the
 	// given time on the topic/partition combination. Time should be OffsetOldest for
 	// the earliest available offset, OffsetNewest for the offset of the message that
-	// will be produced next, or a time.
+	// will be produced next, or a time in milliseconds since the epoch. From
+	// Version V0_10_1_0, a time gets the offset of the first message with a
+	// timestamp at or after it, or -1 if there is none; before, it gets the offset
+	// of the last log segment written before it.
 	GetOffset(topic string, partitionID int32, time int64) (int64, error)
 
 	// Coordinator returns the coordinating broker for a consumer group. It will
@@ -55,6 +59,36 @@ type Client interface {
 	// in local cache. This function only works on Kafka 0.8.2 and higher.
 	RefreshCoordinator(consumerGroup string) error
 
+	// Broker returns the broker of the given ID, as retrieved from the cluster
+	// metadata, or ErrBrokerNotFound.
+	Broker(brokerID int32) (*Broker, error)
+
+	// Brokers returns the brokers of the cluster, as retrieved from the cluster
+	// metadata, sorted by ID.
+	Brokers() []*Broker
+
+	// Controller returns the cluster's controller broker, which requests to
+	// create topics and partitions and to delete topics have to be sent to. It
+	// will return a locally cached value if it's available, refreshing the
+	// metadata otherwise. It requires Version >= V0_10_0_0, from which
+	// metadata names the controller.
+	Controller() (*Broker, error)
+
+	// TransactionCoordinator returns the coordinating broker for a transactional
+	// ID, as Coordinator does for a consumer group. It requires
+	// Version >= V0_11_0_0.
+	TransactionCoordinator(transactionalID string) (*Broker, error)
+
+	// RefreshTransactionCoordinator retrieves the coordinator for a
+	// transactional ID and stores it in local cache. It requires
+	// Version >= V0_11_0_0.
+	RefreshTransactionCoordinator(transactionalID string) error
+
+	// InitProducerID acquires a new producer ID and epoch for an idempotent
+	// producer from any broker. It requires Version >= V0_11_0_0. Errors the
+	// broker returns are left in the response.
+	InitProducerID() (*InitProducerIDResponse, error)
+
 	// Close shuts down all broker connections managed by this client. It is required
 	// to call this function before a client object passes out of scope, as it will
 	// otherwise leak memory. You must close any Producers or Consumers using a client
@@ -78,32 +112,67 @@ const (
 	OffsetOldest int64 = -2
 )
 
+// offsetTimeBase is the pseudo-offset OffsetTime returns for the epoch; those
+// of later times are below it. It is far from the offsets that mean nothing,
+// which ConsumePartition refuses as out of range.
+const offsetTimeBase int64 = -1 << 62
+
+// OffsetTime returns the pseudo-offset that stands for the first message of a
+// partition with a timestamp at or after t. You can send it when calling
+// ConsumePartition, or set it as Consumer.Offsets.Initial, like OffsetNewest and
+// OffsetOldest, which requires Version >= V0_10_1_0. A partition without such
+// a message is consumed from OffsetNewest.
+func OffsetTime(t time.Time) int64 {
+	millis := t.UnixNano() / int64(time.Millisecond)
+	if millis < 0 {
+		millis = 0
+	}
+	return offsetTimeBase - millis
+}
+
+// offsetTime returns the time in milliseconds, to send to GetOffset, that a
+// pseudo-offset from OffsetTime stands for, and whether offset is one.
+func offsetTime(offset int64) (int64, bool) {
+	if offset > offsetTimeBase {
+		return 0, false
+	}
+	return offsetTimeBase - offset, true
+}
+
 type client struct {
 	conf           *Config
-	closer, closed chan none // for shutting down background metadata updater
+	closer, closed chan none          // for shutting down background metadata updater
+	telemetry      *telemetryReporter // nil unless Telemetry.Enable
 
 	// the broker addresses given to us through the constructor are not guaranteed to be returned in
 	// the cluster metadata (I *think* it only returns brokers who are currently leading partitions?)
 	// so we store them separately
 	seedBrokers []*Broker
 	deadSeeds   []*Broker
+	seedAddrs   []string // as given, resolved again with Net.ResolveSeedBrokers
 
-	brokers      map[int32]*Broker                       // maps broker ids to brokers
-	metadata     map[string]map[int32]*PartitionMetadata // maps topics to partition ids to metadata
-	coordinators map[string]int32                        // Maps consumer group names to coordinating broker IDs
+	brokers                 map[int32]*Broker                       // maps broker ids to brokers
+	metadata                map[string]map[int32]*PartitionMetadata // maps topics to partition ids to metadata
+	metadataAge             map[string]time.Time                    // maps topics to when their metadata was fetched
+	coordinators            map[string]int32                        // Maps consumer group names to coordinating broker IDs
+	transactionCoordinators map[string]int32                        // Maps transactional IDs to coordinating broker IDs
+	controllerID            int32                                   // from metadata of version 1 and later, or -1
 
 	// If the number of partitions is large, we can get some churn calling cachedPartitions,
 	// so the result is cached.  It is important to update this value whenever metadata is changed
 	cachedPartitionsResults map[string][maxPartitionIndex][]int32
 
 	lock sync.RWMutex // protects access to the maps that hold cluster state.
+
+	random     *rand.Rand // from conf.RandSource
+	randomLock sync.Mutex // protects random, which any() uses under a read lock
 }
 
 // NewClient creates a new Client. It connects to one of the given broker addresses
 // and uses that broker to automatically fetch metadata on the rest of the kafka cluster. If metadata cannot
 // be retrieved from any of the given broker addresses, the client is not created.
 func NewClient(addrs []string, conf *Config) (Client, error) {
-	Logger.Println("Initializing new client")
+	LogClient.info("initializing new client")
 
 	if conf == nil {
 		conf = NewConfig()
@@ -123,13 +192,21 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 		closed:                  make(chan none),
 		brokers:                 make(map[int32]*Broker),
 		metadata:                make(map[string]map[int32]*PartitionMetadata),
+		metadataAge:             make(map[string]time.Time),
 		cachedPartitionsResults: make(map[string][maxPartitionIndex][]int32),
 		coordinators:            make(map[string]int32),
+		transactionCoordinators: make(map[string]int32),
+		controllerID:            -1,
+		random:                  rand.New(conf.RandSource()),
 	}
 
-	random := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for _, index := range random.Perm(len(addrs)) {
-		client.seedBrokers = append(client.seedBrokers, NewBroker(addrs[index]))
+	if conf.Net.ResolveSeedBrokers {
+		client.seedAddrs = append([]string(nil), addrs...)
+		client.reconcileSeedBrokers(client.resolveSeedAddrs())
+	} else {
+		for _, index := range client.random.Perm(len(addrs)) {
+			client.seedBrokers = append(client.seedBrokers, NewBroker(addrs[index]))
+		}
 	}
 
 	// do an initial fetch of all cluster metadata by specifing an empty list of topics
@@ -139,15 +216,19 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 		break
 	case ErrLeaderNotAvailable, ErrReplicaNotAvailable:
 		// indicates that maybe part of the cluster is down, but is not fatal to creating the client
-		Logger.Println(err)
+		LogClient.warn("some partitions are leaderless", "err", err)
 	default:
 		close(client.closed) // we haven't started the background updater yet, so we have to do this manually
 		_ = client.Close()
 		return nil, err
 	}
 	go withRecover(client.backgroundMetadataUpdater)
+	if conf.Telemetry.Enable {
+		client.telemetry = newTelemetryReporter(client)
+		go withRecover(client.telemetry.run)
+	}
 
-	Logger.Println("Successfully initialized new client")
+	LogClient.info("successfully initialized new client")
 
 	return client, nil
 }
@@ -160,17 +241,20 @@ func (client *client) Close() error {
 	if client.Closed() {
 		// Chances are this is being called from a defer() and the error will go unobserved
 		// so we go ahead and log the event in this case.
-		Logger.Printf("Close() called on already closed client")
+		LogClient.warn("Close() called on already closed client")
 		return ErrClosedClient
 	}
 
 	// shutdown and wait for the background thread before we take the lock, to avoid races
 	close(client.closer)
 	<-client.closed
+	if client.telemetry != nil {
+		client.telemetry.close()
+	}
 
 	client.lock.Lock()
 	defer client.lock.Unlock()
-	Logger.Println("Closing Client")
+	LogClient.info("closing client")
 
 	for _, broker := range client.brokers {
 		safeAsyncClose(broker)
@@ -210,6 +294,7 @@ func (client *client) Partitions(topic string) ([]int32, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
 	}
+	client.refreshIfStale(topic)
 
 	partitions := client.cachedPartitions(topic, allPartitions)
 
@@ -232,6 +317,7 @@ func (client *client) WritablePartitions(topic string) ([]int32, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
 	}
+	client.refreshIfStale(topic)
 
 	partitions := client.cachedPartitions(topic, writablePartitions)
 
@@ -260,6 +346,7 @@ func (client *client) Replicas(topic string, partitionID int32) ([]int32, error)
 	if client.Closed() {
 		return nil, ErrClosedClient
 	}
+	client.refreshIfStale(topic)
 
 	metadata := client.cachedMetadata(topic, partitionID)
 
@@ -285,6 +372,7 @@ func (client *client) Leader(topic string, partitionID int32) (*Broker, error) {
 	if client.Closed() {
 		return nil, ErrClosedClient
 	}
+	client.refreshIfStale(topic)
 
 	leader, err := client.cachedLeader(topic, partitionID)
 
@@ -338,13 +426,13 @@ func (client *client) Coordinator(consumerGroup string) (*Broker, error) {
 		return nil, ErrClosedClient
 	}
 
-	coordinator := client.cachedCoordinator(consumerGroup)
+	coordinator := client.cachedCoordinator(client.coordinators, consumerGroup)
 
 	if coordinator == nil {
 		if err := client.RefreshCoordinator(consumerGroup); err != nil {
 			return nil, err
 		}
-		coordinator = client.cachedCoordinator(consumerGroup)
+		coordinator = client.cachedCoordinator(client.coordinators, consumerGroup)
 	}
 
 	if coordinator == nil {
@@ -355,12 +443,90 @@ func (client *client) Coordinator(consumerGroup string) (*Broker, error) {
 	return coordinator, nil
 }
 
+func (client *client) Broker(brokerID int32) (*Broker, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	client.lock.RLock()
+	broker := client.brokers[brokerID]
+	client.lock.RUnlock()
+
+	if broker == nil {
+		return nil, ErrBrokerNotFound
+	}
+
+	_ = broker.Open(client.conf)
+	return broker, nil
+}
+
+func (client *client) Brokers() []*Broker {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	brokers := make([]*Broker, 0, len(client.brokers))
+	for _, broker := range client.brokers {
+		brokers = append(brokers, broker)
+	}
+	sort.Slice(brokers, func(i, j int) bool { return brokers[i].ID() < brokers[j].ID() })
+	return brokers
+}
+
+func (client *client) Controller() (*Broker, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+	if !client.conf.Version.IsAtLeast(V0_10_0_0) {
+		return nil, ConfigurationError("Controller requires Version >= V0_10_0_0")
+	}
+
+	controller := client.cachedController()
+	if controller == nil {
+		if err := client.RefreshMetadata(); err != nil {
+			return nil, err
+		}
+		controller = client.cachedController()
+	}
+
+	if controller == nil {
+		return nil, ErrControllerNotAvailable
+	}
+
+	_ = controller.Open(client.conf)
+	return controller, nil
+}
+
+func (client *client) InitProducerID() (*InitProducerIDResponse, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+	if !client.conf.Version.IsAtLeast(V0_11_0_0) {
+		return nil, ConfigurationError("InitProducerID requires Version >= V0_11_0_0")
+	}
+
+	for broker := client.any(); broker != nil; broker = client.any() {
+		response, err := broker.InitProducerID(new(InitProducerIDRequest))
+		switch err.(type) {
+		case nil:
+			return response, nil
+		case PacketEncodingError:
+			return nil, err
+		default:
+			LogClient.warn("producer ID request failed", "addr", broker.Addr(), "err", err)
+			_ = broker.Close()
+			client.deregisterBroker(broker)
+		}
+	}
+
+	return nil, ErrOutOfBrokers
+}
+
 func (client *client) RefreshCoordinator(consumerGroup string) error {
 	if client.Closed() {
 		return ErrClosedClient
 	}
 
-	response, err := client.getConsumerMetadata(consumerGroup, client.conf.Metadata.Retry.Max)
+	response, err := client.getConsumerMetadata(consumerGroup, CoordinatorGroup, client.conf.Metadata.Retry.Max)
 	if err != nil {
 		return err
 	}
@@ -372,6 +538,48 @@ func (client *client) RefreshCoordinator(consumerGroup string) error {
 	return nil
 }
 
+func (client *client) TransactionCoordinator(transactionalID string) (*Broker, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	coordinator := client.cachedCoordinator(client.transactionCoordinators, transactionalID)
+
+	if coordinator == nil {
+		if err := client.RefreshTransactionCoordinator(transactionalID); err != nil {
+			return nil, err
+		}
+		coordinator = client.cachedCoordinator(client.transactionCoordinators, transactionalID)
+	}
+
+	if coordinator == nil {
+		return nil, ErrConsumerCoordinatorNotAvailable
+	}
+
+	_ = coordinator.Open(client.conf)
+	return coordinator, nil
+}
+
+func (client *client) RefreshTransactionCoordinator(transactionalID string) error {
+	if client.Closed() {
+		return ErrClosedClient
+	}
+	if !client.conf.Version.IsAtLeast(V0_11_0_0) {
+		return ConfigurationError("TransactionCoordinator requires Version >= V0_11_0_0")
+	}
+
+	response, err := client.getConsumerMetadata(transactionalID, CoordinatorTransaction, client.conf.Metadata.Retry.Max)
+	if err != nil {
+		return err
+	}
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.registerBroker(response.Coordinator)
+	client.transactionCoordinators[transactionalID] = response.Coordinator.ID()
+	return nil
+}
+
 // private broker management helpers
 
 // registerBroker makes sure a broker received by a Metadata or Coordinator request is registered
@@ -380,11 +588,11 @@ func (client *client) RefreshCoordinator(consumerGroup string) error {
 func (client *client) registerBroker(broker *Broker) {
 	if client.brokers[broker.ID()] == nil {
 		client.brokers[broker.ID()] = broker
-		Logger.Printf("client/brokers registered new broker #%d at %s", broker.ID(), broker.Addr())
+		LogClient.info("registered new broker", "broker", broker.ID(), "addr", broker.Addr())
 	} else if broker.Addr() != client.brokers[broker.ID()].Addr() {
 		safeAsyncClose(client.brokers[broker.ID()])
 		client.brokers[broker.ID()] = broker
-		Logger.Printf("client/brokers replaced registered broker #%d with %s", broker.ID(), broker.Addr())
+		LogClient.info("replaced registered broker", "broker", broker.ID(), "addr", broker.Addr())
 	}
 }
 
@@ -402,18 +610,109 @@ func (client *client) deregisterBroker(broker *Broker) {
 		// but we really shouldn't have to; once that loop is made better this case can be
 		// removed, and the function generally can be renamed from `deregisterBroker` to
 		// `nextSeedBroker` or something
-		Logger.Printf("client/brokers deregistered broker #%d at %s", broker.ID(), broker.Addr())
+		LogClient.info("deregistered broker", "broker", broker.ID(), "addr", broker.Addr())
 		delete(client.brokers, broker.ID())
 	}
 }
 
 func (client *client) resurrectDeadBrokers() {
+	var resolved []*Broker
+	if client.conf.Net.ResolveSeedBrokers {
+		resolved = client.resolveSeedAddrs()
+	}
+
 	client.lock.Lock()
 	defer client.lock.Unlock()
 
-	Logger.Printf("client/brokers resurrecting %d dead seed brokers", len(client.deadSeeds))
+	LogClient.info("resurrecting dead seed brokers", "count", len(client.deadSeeds))
 	client.seedBrokers = append(client.seedBrokers, client.deadSeeds...)
 	client.deadSeeds = nil
+	if resolved != nil {
+		client.reconcileSeedBrokers(resolved)
+	}
+}
+
+// refreshSeedBrokers resolves the seed addresses again, with
+// Net.ResolveSeedBrokers.
+func (client *client) refreshSeedBrokers() {
+	if !client.conf.Net.ResolveSeedBrokers {
+		return
+	}
+	resolved := client.resolveSeedAddrs()
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if client.Closed() {
+		return
+	}
+	client.reconcileSeedBrokers(resolved)
+}
+
+// resolveSeedAddrs returns an unopened seed broker, in random order, for each
+// IP address the hosts of the seed addresses resolve to. Addresses that fail to
+// resolve are kept as they are, to be resolved again as they are dialled.
+func (client *client) resolveSeedAddrs() []*Broker {
+	var resolved []*Broker
+	for _, addr := range client.seedAddrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			resolved = append(resolved, NewBroker(addr))
+			continue
+		}
+		ips, err := net.LookupHost(host)
+		if err != nil {
+			LogClient.warn("failed to resolve seed broker", "addr", addr, "err", err)
+			resolved = append(resolved, NewBroker(addr))
+			continue
+		}
+		for _, ip := range ips {
+			broker := NewBroker(net.JoinHostPort(ip, port))
+			broker.serverName = host
+			resolved = append(resolved, broker)
+		}
+	}
+
+	client.randomLock.Lock()
+	defer client.randomLock.Unlock()
+	shuffled := make([]*Broker, 0, len(resolved))
+	for _, index := range client.random.Perm(len(resolved)) {
+		shuffled = append(shuffled, resolved[index])
+	}
+	return shuffled
+}
+
+// reconcileSeedBrokers drops the seed brokers, live or dead, whose address was
+// not resolved again, and adds the resolved brokers whose address is new. The
+// caller must hold the lock.
+func (client *client) reconcileSeedBrokers(resolved []*Broker) {
+	fresh := make(map[string]*Broker, len(resolved))
+	for _, broker := range resolved {
+		fresh[broker.addr] = broker
+	}
+
+	keep := func(brokers []*Broker) []*Broker {
+		var kept []*Broker
+		for _, broker := range brokers {
+			if _, ok := fresh[broker.addr]; ok {
+				kept = append(kept, broker)
+				delete(fresh, broker.addr)
+			} else {
+				LogClient.info("dropping seed broker that no longer resolves", "addr", broker.addr)
+				safeAsyncClose(broker)
+			}
+		}
+		return kept
+	}
+	client.seedBrokers = keep(client.seedBrokers)
+	client.deadSeeds = keep(client.deadSeeds)
+
+	for _, broker := range resolved {
+		if fresh[broker.addr] == broker {
+			LogClient.debug("adding resolved seed broker", "addr", broker.addr, "host", broker.serverName)
+			client.seedBrokers = append(client.seedBrokers, broker)
+			delete(fresh, broker.addr)
+		}
+	}
 }
 
 func (client *client) any() *Broker {
@@ -425,13 +724,20 @@ func (client *client) any() *Broker {
 		return client.seedBrokers[0]
 	}
 
-	// not guaranteed to be random *or* deterministic
-	for _, broker := range client.brokers {
-		_ = broker.Open(client.conf)
-		return broker
+	if len(client.brokers) == 0 {
+		return nil
+	}
+	ids := make([]int32, 0, len(client.brokers))
+	for id := range client.brokers {
+		ids = append(ids, id)
 	}
+	sort.Sort(int32Slice(ids))
 
-	return nil
+	client.randomLock.Lock()
+	broker := client.brokers[ids[client.random.Intn(len(ids))]]
+	client.randomLock.Unlock()
+	_ = broker.Open(client.conf)
+	return broker
 }
 
 // private caching/lazy metadata helpers
@@ -447,6 +753,26 @@ const (
 	maxPartitionIndex
 )
 
+// refreshIfStale refreshes the metadata of the topic if it is older than
+// Metadata.TTL, keeping the stale metadata if that fails.
+func (client *client) refreshIfStale(topic string) {
+	if client.conf.Metadata.TTL <= 0 {
+		return
+	}
+
+	client.lock.RLock()
+	fetched, ok := client.metadataAge[topic]
+	client.lock.RUnlock()
+	if !ok || time.Since(fetched) < client.conf.Metadata.TTL {
+		return
+	}
+
+	LogClient.debug("refreshing stale metadata", "topic", topic, "age", time.Since(fetched))
+	if err := client.RefreshMetadata(topic); err != nil {
+		LogClient.warn("failed to refresh stale metadata, using it anyway", "topic", topic, "err", err)
+	}
+}
+
 func (client *client) cachedMetadata(topic string, partitionID int32) *PartitionMetadata {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
@@ -520,6 +846,9 @@ func (client *client) getOffset(topic string, partitionID int32, time int64) (in
 	}
 
 	request := &OffsetRequest{}
+	if client.conf.Version.IsAtLeast(V0_10_1_0) {
+		request.Version = 1
+	}
 	request.AddBlock(topic, partitionID, time, 1)
 
 	response, err := broker.GetAvailableOffsets(request)
@@ -536,6 +865,9 @@ func (client *client) getOffset(topic string, partitionID int32, time int64) (in
 	if block.Err != ErrNoError {
 		return -1, block.Err
 	}
+	if response.Version >= 1 {
+		return block.Offset, nil
+	}
 	if len(block.Offsets) != 1 {
 		return -1, ErrOffsetOutOfRange
 	}
@@ -558,8 +890,9 @@ func (client *client) backgroundMetadataUpdater() {
 	for {
 		select {
 		case <-ticker.C:
+			client.refreshSeedBrokers()
 			if err := client.RefreshMetadata(); err != nil {
-				Logger.Println("Client background metadata update:", err)
+				LogClient.error("background metadata update failed", "err", err)
 			}
 		case <-client.closer:
 			return
@@ -570,7 +903,7 @@ func (client *client) backgroundMetadataUpdater() {
 func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int) error {
 	retry := func(err error) error {
 		if attemptsRemaining > 0 {
-			Logger.Printf("client/metadata retrying after %dms... (%d attempts remaining)\n", client.conf.Metadata.Retry.Backoff/time.Millisecond, attemptsRemaining)
+			LogClient.warn("retrying metadata request", "backoff", client.conf.Metadata.Retry.Backoff, "attemptsRemaining", attemptsRemaining)
 			time.Sleep(client.conf.Metadata.Retry.Backoff)
 			return client.tryRefreshMetadata(topics, attemptsRemaining-1)
 		}
@@ -579,17 +912,21 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int)
 
 	for broker := client.any(); broker != nil; broker = client.any() {
 		if len(topics) > 0 {
-			Logger.Printf("client/metadata fetching metadata for %v from broker %s\n", topics, broker.addr)
+			LogClient.debug("fetching metadata", "topics", topics, "addr", broker.addr)
 		} else {
-			Logger.Printf("client/metadata fetching metadata for all topics from broker %s\n", broker.addr)
+			LogClient.debug("fetching metadata for all topics", "addr", broker.addr)
 		}
-		response, err := broker.GetMetadata(&MetadataRequest{Topics: topics})
+		request := &MetadataRequest{Topics: topics}
+		if client.conf.Version.IsAtLeast(V0_10_0_0) {
+			request.Version = 1
+		}
+		response, err := broker.GetMetadata(request)
 
 		switch err.(type) {
 		case nil:
 			// valid response, use it
 			if shouldRetry, err := client.updateMetadata(response); shouldRetry {
-				Logger.Println("client/metadata found some partitions to be leaderless")
+				LogClient.warn("found some partitions to be leaderless")
 				return retry(err) // note: err can be nil
 			} else {
 				return err
@@ -600,13 +937,13 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int)
 			return err
 		default:
 			// some other error, remove that broker and try again
-			Logger.Println("client/metadata got error from broker while fetching metadata:", err)
+			LogClient.warn("got error from broker while fetching metadata", "addr", broker.addr, "err", err)
 			_ = broker.Close()
 			client.deregisterBroker(broker)
 		}
 	}
 
-	Logger.Println("client/metadata no available broker to send metadata request to")
+	LogClient.error("no available broker to send metadata request to")
 	client.resurrectDeadBrokers()
 	return retry(ErrOutOfBrokers)
 }
@@ -623,9 +960,13 @@ func (client *client) updateMetadata(data *MetadataResponse) (retry bool, err er
 	for _, broker := range data.Brokers {
 		client.registerBroker(broker)
 	}
+	if data.Version >= 1 {
+		client.controllerID = data.ControllerID
+	}
 
 	for _, topic := range data.Topics {
 		delete(client.metadata, topic.Name)
+		delete(client.metadataAge, topic.Name)
 		delete(client.cachedPartitionsResults, topic.Name)
 
 		switch topic.Err {
@@ -642,12 +983,13 @@ func (client *client) updateMetadata(data *MetadataResponse) (retry bool, err er
 			retry = true
 			break
 		default: // don't retry, don't store partial results
-			Logger.Printf("Unexpected topic-level metadata error: %s", topic.Err)
+			LogClient.error("unexpected topic-level metadata error", "topic", topic.Name, "err", topic.Err)
 			err = topic.Err
 			continue
 		}
 
 		client.metadata[topic.Name] = make(map[int32]*PartitionMetadata, len(topic.Partitions))
+		client.metadataAge[topic.Name] = time.Now()
 		for _, partition := range topic.Partitions {
 			client.metadata[topic.Name][partition.ID] = partition
 			if partition.Err == ErrLeaderNotAvailable {
@@ -664,35 +1006,56 @@ func (client *client) updateMetadata(data *MetadataResponse) (retry bool, err er
 	return
 }
 
-func (client *client) cachedCoordinator(consumerGroup string) *Broker {
+func (client *client) cachedController() *Broker {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
-	if coordinatorID, ok := client.coordinators[consumerGroup]; ok {
+	return client.brokers[client.controllerID]
+}
+
+// cachedCoordinator returns the coordinator cached in coordinators, the
+// coordinators of either groups or transactional IDs, for the key.
+func (client *client) cachedCoordinator(coordinators map[string]int32, key string) *Broker {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+	if coordinatorID, ok := coordinators[key]; ok {
 		return client.brokers[coordinatorID]
 	}
 	return nil
 }
 
-func (client *client) getConsumerMetadata(consumerGroup string, attemptsRemaining int) (*ConsumerMetadataResponse, error) {
+// getConsumerMetadata finds the coordinator of the given type for the key, a
+// consumer group or a transactional ID.
+func (client *client) getConsumerMetadata(consumerGroup string, coordinatorType CoordinatorType, attemptsRemaining int) (*ConsumerMetadataResponse, error) {
 	retry := func(err error) (*ConsumerMetadataResponse, error) {
 		if attemptsRemaining > 0 {
-			Logger.Printf("client/coordinator retrying after %dms... (%d attempts remaining)\n", client.conf.Metadata.Retry.Backoff/time.Millisecond, attemptsRemaining)
+			LogGroup.warn("retrying coordinator request", "backoff", client.conf.Metadata.Retry.Backoff, "attemptsRemaining", attemptsRemaining)
 			time.Sleep(client.conf.Metadata.Retry.Backoff)
-			return client.getConsumerMetadata(consumerGroup, attemptsRemaining-1)
+			return client.getConsumerMetadata(consumerGroup, coordinatorType, attemptsRemaining-1)
 		}
 		return nil, err
 	}
 
+	// the coordinators of transactional IDs are the leaders of the partitions of
+	// __transaction_state, as those of groups are of __consumer_offsets
+	keyName, topic := "group", "__consumer_offsets"
+	if coordinatorType == CoordinatorTransaction {
+		keyName, topic = "transactionalID", "__transaction_state"
+	}
+
 	for broker := client.any(); broker != nil; broker = client.any() {
-		Logger.Printf("client/coordinator requesting coordinator for consumergoup %s from %s\n", consumerGroup, broker.Addr())
+		LogGroup.debug("requesting coordinator", keyName, consumerGroup, "addr", broker.Addr())
 
 		request := new(ConsumerMetadataRequest)
 		request.ConsumerGroup = consumerGroup
+		if coordinatorType != CoordinatorGroup {
+			request.Version = 1
+			request.CoordinatorType = coordinatorType
+		}
 
 		response, err := broker.GetConsumerMetadata(request)
 
 		if err != nil {
-			Logger.Printf("client/coordinator request to broker %s failed: %s\n", broker.Addr(), err)
+			LogGroup.warn("coordinator request failed", "addr", broker.Addr(), "err", err)
 
 			switch err.(type) {
 			case PacketEncodingError:
@@ -706,17 +1069,17 @@ func (client *client) getConsumerMetadata(consumerGroup string, attemptsRemainin
 
 		switch response.Err {
 		case ErrNoError:
-			Logger.Printf("client/coordinator coordinator for consumergoup %s is #%d (%s)\n", consumerGroup, response.Coordinator.ID(), response.Coordinator.Addr())
+			LogGroup.info("found coordinator", keyName, consumerGroup, "broker", response.Coordinator.ID(), "addr", response.Coordinator.Addr())
 			return response, nil
 
 		case ErrConsumerCoordinatorNotAvailable:
-			Logger.Printf("client/coordinator coordinator for consumer group %s is not available\n", consumerGroup)
+			LogGroup.warn("coordinator is not available", keyName, consumerGroup)
 
 			// This is very ugly, but this scenario will only happen once per cluster.
 			// The __consumer_offsets topic only has to be created one time.
 			// The number of partitions not configurable, but partition 0 should always exist.
-			if _, err := client.Leader("__consumer_offsets", 0); err != nil {
-				Logger.Printf("client/coordinator the __consumer_offsets topic is not initialized completely yet. Waiting 2 seconds...\n")
+			if _, err := client.Leader(topic, 0); err != nil {
+				LogGroup.info("the " + topic + " topic is not initialized completely yet, waiting 2 seconds")
 				time.Sleep(2 * time.Second)
 			}
 
@@ -726,7 +1089,7 @@ func (client *client) getConsumerMetadata(consumerGroup string, attemptsRemainin
 		}
 	}
 
-	Logger.Println("client/coordinator no available broker to send consumer metadata request to")
+	LogGroup.error("no available broker to send consumer metadata request to")
 	client.resurrectDeadBrokers()
 	return retry(ErrOutOfBrokers)
 }
@@ -2,6 +2,8 @@ package sarama
 
 import (
 	"io"
+	"math/rand"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -15,7 +17,7 @@ func safeClose(t testing.TB, c io.Closer) {
 }
 
 func TestSimpleClient(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
+	seedBroker := NewMockBroker(t, 1)
 
 	seedBroker.Returns(new(MetadataResponse))
 
@@ -29,7 +31,7 @@ func TestSimpleClient(t *testing.T) {
 }
 
 func TestCachedPartitions(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
+	seedBroker := NewMockBroker(t, 1)
 
 	replicas := []int32{3, 1, 5}
 	isr := []int32{5, 1}
@@ -68,7 +70,7 @@ func TestCachedPartitions(t *testing.T) {
 }
 
 func TestClientDoesntCachePartitionsForTopicsWithErrors(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
+	seedBroker := NewMockBroker(t, 1)
 
 	replicas := []int32{seedBroker.BrokerID()}
 
@@ -122,7 +124,7 @@ func TestClientDoesntCachePartitionsForTopicsWithErrors(t *testing.T) {
 }
 
 func TestClientSeedBrokers(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
+	seedBroker := NewMockBroker(t, 1)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker("localhost:12345", 2)
@@ -137,9 +139,83 @@ func TestClientSeedBrokers(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientSeedBrokersOrderFollowsRandSource(t *testing.T) {
+	var addrs []string
+	for i := int32(1); i <= 5; i++ {
+		seedBroker := NewMockBroker(t, i)
+		defer seedBroker.Close()
+		seedBroker.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t),
+		})
+		addrs = append(addrs, seedBroker.Addr())
+	}
+
+	config := NewConfig()
+	config.RandSource = func() rand.Source { return rand.NewSource(1) }
+	c, err := NewClient(addrs, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, c)
+
+	for i, index := range rand.New(rand.NewSource(1)).Perm(len(addrs)) {
+		if addr := c.(*client).seedBrokers[i].Addr(); addr != addrs[index] {
+			t.Errorf("Expected seed broker %d to be %s, got %s", i, addrs[index], addr)
+		}
+	}
+}
+
+func TestClientResolveSeedBrokers(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+	_, port, err := net.SplitHostPort(seedBroker.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewConfig()
+	config.Net.ResolveSeedBrokers = true
+	c, err := NewClient([]string{net.JoinHostPort("localhost", port)}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, c)
+
+	found := false
+	for _, broker := range append(c.(*client).seedBrokers, c.(*client).deadSeeds...) {
+		if host, _, _ := net.SplitHostPort(broker.Addr()); net.ParseIP(host) == nil || broker.serverName != "localhost" {
+			t.Error("Expected the seed broker to be resolved from localhost, got", broker.Addr(), broker.serverName)
+		}
+		found = found || broker.Addr() == seedBroker.Addr()
+	}
+	if !found {
+		t.Error("Expected a seed broker at", seedBroker.Addr())
+	}
+}
+
+func TestClientReconcileSeedBrokers(t *testing.T) {
+	kept, dead := NewBroker("10.0.0.2:9092"), NewBroker("10.0.0.3:9092")
+	c := &client{
+		seedBrokers: []*Broker{NewBroker("10.0.0.1:9092"), kept},
+		deadSeeds:   []*Broker{dead},
+	}
+
+	c.reconcileSeedBrokers([]*Broker{NewBroker("10.0.0.4:9092"), NewBroker("10.0.0.3:9092"), NewBroker("10.0.0.2:9092")})
+
+	if len(c.seedBrokers) != 2 || c.seedBrokers[0] != kept || c.seedBrokers[1].Addr() != "10.0.0.4:9092" {
+		t.Error("Expected the seed broker that still resolves to be kept ahead of the new one, got", c.seedBrokers)
+	}
+	if len(c.deadSeeds) != 1 || c.deadSeeds[0] != dead {
+		t.Error("Expected the dead seed broker that still resolves to stay dead, got", c.deadSeeds)
+	}
+}
+
 func TestClientMetadata(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 5)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 5)
 
 	replicas := []int32{3, 1, 5}
 	isr := []int32{5, 1}
@@ -164,6 +240,10 @@ func TestClientMetadata(t *testing.T) {
 		t.Error("Client returned incorrect topics:", topics)
 	}
 
+	if brokers := client.Brokers(); len(brokers) != 1 || brokers[0].ID() != 5 {
+		t.Error("Client returned incorrect brokers:", brokers)
+	}
+
 	parts, err := client.Partitions("my_topic")
 	if err != nil {
 		t.Error(err)
@@ -202,8 +282,8 @@ func TestClientMetadata(t *testing.T) {
 }
 
 func TestClientGetOffset(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 	leaderAddr := leader.Addr()
 
 	metadata := new(MetadataResponse)
@@ -231,7 +311,7 @@ func TestClientGetOffset(t *testing.T) {
 	leader.Close()
 	seedBroker.Returns(metadata)
 
-	leader = newMockBrokerAddr(t, 2, leaderAddr)
+	leader = NewMockBrokerAddr(t, 2, leaderAddr)
 	offsetResponse = new(OffsetResponse)
 	offsetResponse.AddTopicPartition("foo", 0, 456)
 	leader.Returns(offsetResponse)
@@ -250,7 +330,7 @@ func TestClientGetOffset(t *testing.T) {
 }
 
 func TestClientReceivingUnknownTopic(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
+	seedBroker := NewMockBroker(t, 1)
 
 	metadataResponse1 := new(MetadataResponse)
 	seedBroker.Returns(metadataResponse1)
@@ -286,8 +366,8 @@ func TestClientReceivingUnknownTopic(t *testing.T) {
 }
 
 func TestClientReceivingPartialMetadata(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 5)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 5)
 
 	metadataResponse1 := new(MetadataResponse)
 	metadataResponse1.AddBroker(leader.Addr(), leader.BrokerID())
@@ -339,8 +419,8 @@ func TestClientReceivingPartialMetadata(t *testing.T) {
 }
 
 func TestClientRefreshBehaviour(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 5)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 5)
 
 	metadataResponse1 := new(MetadataResponse)
 	metadataResponse1.AddBroker(leader.Addr(), leader.BrokerID())
@@ -374,8 +454,48 @@ func TestClientRefreshBehaviour(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientMetadataTTL(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader1 := NewMockBroker(t, 5)
+	defer leader1.Close()
+	leader2 := NewMockBroker(t, 6)
+	defer leader2.Close()
+
+	metadataResponse1 := new(MetadataResponse)
+	metadataResponse1.AddBroker(leader1.Addr(), leader1.BrokerID())
+	metadataResponse1.AddBroker(leader2.Addr(), leader2.BrokerID())
+	metadataResponse1.AddTopicPartition("my_topic", 0, leader1.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse1)
+
+	metadataResponse2 := new(MetadataResponse)
+	metadataResponse2.AddBroker(leader1.Addr(), leader1.BrokerID())
+	metadataResponse2.AddBroker(leader2.Addr(), leader2.BrokerID())
+	metadataResponse2.AddTopicPartition("my_topic", 0, leader2.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse2)
+
+	config := NewConfig()
+	config.Metadata.TTL = 50 * time.Millisecond
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	if broker, err := client.Leader("my_topic", 0); err != nil || broker.ID() != leader1.BrokerID() {
+		t.Fatal("Expected the first leader from fresh metadata, got", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if broker, err := client.Leader("my_topic", 0); err != nil || broker.ID() != leader2.BrokerID() {
+		t.Error("Expected the stale metadata to be refreshed and the new leader found, got", err)
+	}
+	if len(seedBroker.History()) != 2 {
+		t.Error("Expected one refresh of the stale metadata, got", len(seedBroker.History())-1)
+	}
+}
+
 func TestClientResurrectDeadSeeds(t *testing.T) {
-	initialSeed := newMockBroker(t, 0)
+	initialSeed := NewMockBroker(t, 0)
 	emptyMetadata := new(MetadataResponse)
 	initialSeed.Returns(emptyMetadata)
 
@@ -390,9 +510,9 @@ func TestClientResurrectDeadSeeds(t *testing.T) {
 
 	client := c.(*client)
 
-	seed1 := newMockBroker(t, 1)
-	seed2 := newMockBroker(t, 2)
-	seed3 := newMockBroker(t, 3)
+	seed1 := NewMockBroker(t, 1)
+	seed2 := NewMockBroker(t, 2)
+	seed3 := NewMockBroker(t, 3)
 	addr1 := seed1.Addr()
 	addr2 := seed2.Addr()
 	addr3 := seed3.Addr()
@@ -413,8 +533,8 @@ func TestClientResurrectDeadSeeds(t *testing.T) {
 	seed1.Close()
 	seed2.Close()
 
-	seed1 = newMockBrokerAddr(t, 1, addr1)
-	seed2 = newMockBrokerAddr(t, 2, addr2)
+	seed1 = NewMockBrokerAddr(t, 1, addr1)
+	seed2 = NewMockBrokerAddr(t, 2, addr2)
 
 	seed3.Close()
 
@@ -434,9 +554,9 @@ func TestClientResurrectDeadSeeds(t *testing.T) {
 }
 
 func TestClientCoordinatorWithConsumerOffsetsTopic(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	staleCoordinator := newMockBroker(t, 2)
-	freshCoordinator := newMockBroker(t, 3)
+	seedBroker := NewMockBroker(t, 1)
+	staleCoordinator := NewMockBroker(t, 2)
+	freshCoordinator := NewMockBroker(t, 3)
 
 	replicas := []int32{staleCoordinator.BrokerID(), freshCoordinator.BrokerID()}
 	metadataResponse1 := new(MetadataResponse)
@@ -512,9 +632,54 @@ func TestClientCoordinatorWithConsumerOffsetsTopic(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientController(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	controller := NewMockBroker(t, 2)
+	defer controller.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(controller.Addr(), controller.BrokerID()).
+			SetController(controller.BrokerID()),
+	})
+
+	config := NewConfig()
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Controller(); err == nil {
+		t.Error("Expected the controller to be refused on the default Version")
+	}
+	safeClose(t, client)
+
+	config.Version = V0_10_0_0
+	client, err = NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	broker, err := client.Controller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broker.ID() != controller.BrokerID() || broker.Addr() != controller.Addr() {
+		t.Errorf("Expected the controller to be broker %d, got %d", controller.BrokerID(), broker.ID())
+	}
+	if broker, err := client.Broker(seedBroker.BrokerID()); err != nil || broker.Addr() != seedBroker.Addr() {
+		t.Error("Expected to find the seed broker by its ID, got", err)
+	}
+	if _, err := client.Broker(42); err != ErrBrokerNotFound {
+		t.Error("Expected an unknown broker not to be found, got", err)
+	}
+}
+
 func TestClientCoordinatorWithoutConsumerOffsetsTopic(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	coordinator := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	coordinator := NewMockBroker(t, 2)
 
 	metadataResponse1 := new(MetadataResponse)
 	seedBroker.Returns(metadataResponse1)
@@ -566,7 +731,7 @@ func TestClientCoordinatorWithoutConsumerOffsetsTopic(t *testing.T) {
 }
 
 func TestClientAutorefreshShutdownRace(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
+	seedBroker := NewMockBroker(t, 1)
 
 	metadataResponse := new(MetadataResponse)
 	seedBroker.Returns(metadataResponse)
@@ -594,7 +759,7 @@ func TestClientAutorefreshShutdownRace(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 
 	// Then return some metadata to the still-running background thread
-	leader := newMockBroker(t, 2)
+	leader := NewMockBroker(t, 2)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
 	metadataResponse.AddTopicPartition("foo", 0, leader.BrokerID(), []int32{2}, []int32{2}, ErrNoError)
 	seedBroker.Returns(metadataResponse)
@@ -0,0 +1,155 @@
+package sarama
+
+// Uuid is the 16-byte universally unique identifier of the Kafka protocol, used
+// for example to identify client instances. The zero Uuid means none.
+type Uuid [16]byte
+
+// The helpers below implement the compact encodings of the "flexible" request
+// and response versions introduced by KIP-482: lengths are unsigned varints
+// offset by one (so that zero can mean null), and structures end with tagged
+// fields. Sarama never sends tagged fields and skips those it receives.
+
+func getUVarint(pd packetDecoder) (uint64, error) {
+	var x uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := pd.getInt8()
+		if err != nil {
+			return 0, err
+		}
+		x |= uint64(b&0x7f) << shift
+		if b >= 0 {
+			return x, nil
+		}
+	}
+	return 0, PacketDecodingError{"invalid varint"}
+}
+
+func putCompactArrayLength(pe packetEncoder, in int) error {
+	pe.putUVarint(uint64(in) + 1)
+	return nil
+}
+
+// getCompactArrayLength returns -1 for a null array.
+func getCompactArrayLength(pd packetDecoder) (int, error) {
+	n, err := getUVarint(pd)
+	if err != nil {
+		return -1, err
+	}
+	if n > uint64(pd.remaining())+1 {
+		return -1, ErrInsufficientData
+	}
+	return int(n) - 1, nil
+}
+
+func putCompactBytes(pe packetEncoder, in []byte) error {
+	if in == nil {
+		pe.putUVarint(0)
+		return nil
+	}
+	if err := putCompactArrayLength(pe, len(in)); err != nil {
+		return err
+	}
+	return pe.putRawBytes(in)
+}
+
+func getCompactBytes(pd packetDecoder) ([]byte, error) {
+	n, err := getCompactArrayLength(pd)
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	return pd.getRawBytes(n)
+}
+
+func putCompactString(pe packetEncoder, in string) error {
+	if err := putCompactArrayLength(pe, len(in)); err != nil {
+		return err
+	}
+	return pe.putRawBytes([]byte(in))
+}
+
+// getCompactString returns "" for a null string, like getString.
+func getCompactString(pd packetDecoder) (string, error) {
+	buf, err := getCompactBytes(pd)
+	return string(buf), err
+}
+
+func putCompactStringArray(pe packetEncoder, in []string) error {
+	if err := putCompactArrayLength(pe, len(in)); err != nil {
+		return err
+	}
+	for _, str := range in {
+		if err := putCompactString(pe, str); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getCompactStringArray(pd packetDecoder) ([]string, error) {
+	n, err := getCompactArrayLength(pd)
+	if err != nil || n <= 0 {
+		return nil, err
+	}
+	ret := make([]string, n)
+	for i := range ret {
+		if ret[i], err = getCompactString(pd); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func putUuid(pe packetEncoder, in Uuid) error {
+	return pe.putRawBytes(in[:])
+}
+
+func getUuid(pd packetDecoder) (Uuid, error) {
+	var id Uuid
+	buf, err := pd.getRawBytes(len(id))
+	if err != nil {
+		return id, err
+	}
+	copy(id[:], buf)
+	return id, nil
+}
+
+func putBool(pe packetEncoder, in bool) {
+	if in {
+		pe.putInt8(1)
+	} else {
+		pe.putInt8(0)
+	}
+}
+
+func getBool(pd packetDecoder) (bool, error) {
+	b, err := pd.getInt8()
+	return b != 0, err
+}
+
+func putEmptyTaggedFields(pe packetEncoder) error {
+	pe.putUVarint(0)
+	return nil
+}
+
+func skipTaggedFields(pd packetDecoder) error {
+	n, err := getUVarint(pd)
+	if err != nil {
+		return err
+	}
+	for ; n > 0; n-- {
+		if _, err := getUVarint(pd); err != nil { // tag
+			return err
+		}
+		size, err := getUVarint(pd)
+		if err != nil {
+			return err
+		}
+		if size > uint64(pd.remaining()) {
+			return ErrInsufficientData
+		}
+		if _, err := pd.getRawBytes(int(size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
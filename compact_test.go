@@ -0,0 +1,69 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+type compactStrings struct {
+	values []string
+}
+
+func (c *compactStrings) encode(pe packetEncoder) error {
+	if err := putCompactStringArray(pe, c.values); err != nil {
+		return err
+	}
+	return putEmptyTaggedFields(pe)
+}
+
+func (c *compactStrings) decode(pd packetDecoder) (err error) {
+	if c.values, err = getCompactStringArray(pd); err != nil {
+		return err
+	}
+	return skipTaggedFields(pd)
+}
+
+func TestCompactStringArray(t *testing.T) {
+	testEncodable(t, "empty", &compactStrings{}, []byte{1, 0})
+
+	long := string(make([]byte, 200))
+	in := &compactStrings{values: []string{"a", long}}
+	packet, err := encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 201 needs two bytes as an unsigned varint
+	if len(packet) != 1+2+2+len(long)+1 || packet[3] != 0xc9 || packet[4] != 0x01 {
+		t.Error("Unexpected encoding", packet[:5])
+	}
+
+	out := new(compactStrings)
+	testDecodable(t, "strings", out, packet)
+	if !reflect.DeepEqual(in, out) {
+		t.Error("Decoded strings do not match the encoded ones", out.values)
+	}
+}
+
+func TestSkipTaggedFields(t *testing.T) {
+	out := new(compactStrings)
+	testDecodable(t, "tagged fields", out, []byte{
+		2, 3, 'h', 'i', // Strings
+		2,              // Tagged fields
+		0, 2, 'a', 'b', // Tag 0
+		0x81, 0x01, 0, // Tag 129, empty
+	})
+	if !reflect.DeepEqual(out.values, []string{"hi"}) {
+		t.Error("Unexpected strings", out.values)
+	}
+
+	if err := decode([]byte{1, 1, 0, 5, 'a'}, new(compactStrings)); err != ErrInsufficientData {
+		t.Error("Expected ErrInsufficientData for a truncated tagged field, got", err)
+	}
+}
+
+func TestUVarintTooLong(t *testing.T) {
+	garbage := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	if _, err := getUVarint(&realDecoder{raw: garbage}); err == nil {
+		t.Error("Expected an error decoding a varint longer than 64 bits")
+	}
+}
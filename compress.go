@@ -0,0 +1,165 @@
+package sarama
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// CompressFunc compresses the encoded wrapped message set of a compressed message.
+// The level is the one configured on the message (see Message.CompressionLevel),
+// and may be ignored by codecs without a notion of compression levels.
+type CompressFunc func(src []byte, level int) ([]byte, error)
+
+// DecompressFunc reverses a CompressFunc, returning the encoded message set.
+type DecompressFunc func(src []byte) ([]byte, error)
+
+type compressionCodecFuncs struct {
+	compress   CompressFunc
+	decompress DecompressFunc
+}
+
+var (
+	customCodecsLock sync.RWMutex
+	customCodecs     = make(map[CompressionCodec]compressionCodecFuncs)
+)
+
+// RegisterCompressionCodec makes a user-supplied codec available under the given
+// attribute value, for both the produce and the fetch path. The value must fit in
+// the three bits Kafka reserves for the codec (0-7) and must not collide with one
+// of the built-in codecs; registering the same value again replaces the previous
+// functions. Either function may be nil if only producing or only consuming is
+// required. Bear in mind that the brokers (or whatever sits between them and
+// Sarama) have to understand any codec in use.
+func RegisterCompressionCodec(codec CompressionCodec, compress CompressFunc, decompress DecompressFunc) error {
+	switch {
+	case int8(codec)&^compressionCodecMask != 0:
+		return ConfigurationError(fmt.Sprintf("compression codec %d does not fit in the message attributes", codec))
+	case codec.isBuiltin():
+		return ConfigurationError(fmt.Sprintf("compression codec %d is built in and cannot be replaced", codec))
+	case compress == nil && decompress == nil:
+		return ConfigurationError("at least one of compress or decompress must be provided")
+	}
+
+	customCodecsLock.Lock()
+	defer customCodecsLock.Unlock()
+
+	customCodecs[codec] = compressionCodecFuncs{compress: compress, decompress: decompress}
+	return nil
+}
+
+func (cc CompressionCodec) String() string {
+	switch cc {
+	case CompressionNone:
+		return "none"
+	case CompressionGZIP:
+		return "gzip"
+	case CompressionSnappy:
+		return "snappy"
+	case CompressionLZ4:
+		return "lz4"
+	case CompressionZSTD:
+		return "zstd"
+	}
+	return fmt.Sprintf("codec(%d)", int8(cc))
+}
+
+func (cc CompressionCodec) isBuiltin() bool {
+	switch cc {
+	case CompressionNone, CompressionGZIP, CompressionSnappy, CompressionLZ4, CompressionZSTD:
+		return true
+	}
+	return false
+}
+
+func lookupCompressionCodec(cc CompressionCodec) (compressionCodecFuncs, bool) {
+	customCodecsLock.RLock()
+	defer customCodecsLock.RUnlock()
+
+	funcs, ok := customCodecs[cc]
+	return funcs, ok
+}
+
+// canCompress reports whether messages can be produced with the given codec.
+func canCompress(cc CompressionCodec) bool {
+	if cc.isBuiltin() {
+		return true
+	}
+	funcs, ok := lookupCompressionCodec(cc)
+	return ok && funcs.compress != nil
+}
+
+func compress(cc CompressionCodec, level int, lz4Legacy bool, data []byte) ([]byte, error) {
+	switch cc {
+	case CompressionNone:
+		return data, nil
+	case CompressionGZIP:
+		return gzipEncode(data, level)
+	case CompressionSnappy:
+		return snappyEncode(data), nil
+	case CompressionLZ4:
+		return lz4Encode(data, level, lz4Legacy)
+	case CompressionZSTD:
+		return zstdEncode(data, level)
+	}
+
+	if funcs, ok := lookupCompressionCodec(cc); ok && funcs.compress != nil {
+		return funcs.compress(data, level)
+	}
+	return nil, PacketEncodingError{fmt.Sprintf("unsupported compression codec (%d)", cc)}
+}
+
+// decompress reverses compress. The built-in codecs stop decoding as soon as the
+// output exceeds MaxDecompressedBatchSize; the output of custom codecs is only
+// checked afterwards.
+func decompress(cc CompressionCodec, data []byte) ([]byte, error) {
+	limit := maxDecompressedBatchBytes()
+
+	switch cc {
+	case CompressionNone:
+		return data, nil
+	case CompressionGZIP:
+		return gzipDecode(data, limit)
+	case CompressionSnappy:
+		return snappyDecode(data, limit)
+	case CompressionLZ4:
+		return lz4Decode(data, limit)
+	case CompressionZSTD:
+		return zstdDecode(data, limit)
+	}
+
+	if funcs, ok := lookupCompressionCodec(cc); ok && funcs.decompress != nil {
+		out, err := funcs.decompress(data)
+		if err == nil && int64(len(out)) > limit {
+			return nil, ErrDecompressedSizeExceeded
+		}
+		return out, err
+	}
+	return nil, PacketDecodingError{fmt.Sprintf("invalid compression specified (%d)", cc)}
+}
+
+// maxDecompressedBatchBytes returns MaxDecompressedBatchSize, or the largest
+// possible limit if it is disabled.
+func maxDecompressedBatchBytes() int64 {
+	if MaxDecompressedBatchSize <= 0 {
+		return math.MaxInt64 - 1
+	}
+	return int64(MaxDecompressedBatchSize)
+}
+
+// readLimited reads all of r into an exactly-sized slice, failing with
+// ErrDecompressedSizeExceeded if there are more than limit bytes.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	buf := getScratch()
+	n, err := buf.ReadFrom(io.LimitReader(r, limit+1))
+	if err != nil {
+		putScratch(buf)
+		return nil, err
+	}
+	if n > limit {
+		putScratch(buf)
+		return nil, ErrDecompressedSizeExceeded
+	}
+	return copyScratch(buf), nil
+}
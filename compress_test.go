@@ -0,0 +1,142 @@
+package sarama
+
+import "testing"
+
+func reverseBytes(src []byte) []byte {
+	dst := make([]byte, len(src))
+	for i, b := range src {
+		dst[len(src)-1-i] = b
+	}
+	return dst
+}
+
+func TestRegisterCompressionCodec(t *testing.T) {
+	const codec = CompressionCodec(7)
+	defer func() {
+		customCodecsLock.Lock()
+		delete(customCodecs, codec)
+		customCodecsLock.Unlock()
+	}()
+
+	if canCompress(codec) {
+		t.Fatal("Unregistered codec reported as usable.")
+	}
+
+	err := RegisterCompressionCodec(codec,
+		func(src []byte, level int) ([]byte, error) { return reverseBytes(src), nil },
+		func(src []byte) ([]byte, error) { return reverseBytes(src), nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !canCompress(codec) {
+		t.Fatal("Registered codec reported as unusable.")
+	}
+
+	inner := &MessageSet{}
+	inner.addMessage(&Message{Key: []byte("key"), Value: []byte("value")})
+	payload, err := encode(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packet, err := encode(&Message{Codec: codec, Value: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := Message{}
+	testDecodable(t, "custom codec", &decoded, packet)
+	if decoded.Codec != codec {
+		t.Errorf("Decoding produced codec %d, but expected %d.", decoded.Codec, codec)
+	}
+	if decoded.Set == nil || len(decoded.Set.Messages) != 1 {
+		t.Fatal("Decoding produced no set, or a set of the wrong size.")
+	}
+	if string(decoded.Set.Messages[0].Msg.Value) != "value" {
+		t.Error("Decoding produced the wrong inner message.")
+	}
+}
+
+func TestRegisterCompressionCodecErrors(t *testing.T) {
+	noop := func(src []byte) ([]byte, error) { return src, nil }
+
+	if err := RegisterCompressionCodec(CompressionGZIP, nil, noop); err == nil {
+		t.Error("Expected an error replacing a built-in codec.")
+	}
+	if err := RegisterCompressionCodec(CompressionCodec(8), nil, noop); err == nil {
+		t.Error("Expected an error registering an out-of-range codec.")
+	}
+	if err := RegisterCompressionCodec(CompressionCodec(6), nil, nil); err == nil {
+		t.Error("Expected an error registering a codec without functions.")
+	}
+}
+
+func TestUnknownCompressionCodec(t *testing.T) {
+	if _, err := encode(&Message{Codec: CompressionCodec(6), Value: []byte("value")}); err == nil {
+		t.Error("Expected an error encoding with an unregistered codec.")
+	}
+
+	config := NewConfig()
+	config.Producer.Compression = CompressionCodec(6)
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an error validating an unregistered codec.")
+	}
+}
+
+func TestDecompressedSizeLimit(t *testing.T) {
+	defer func(batch int32) { MaxDecompressedBatchSize = batch }(MaxDecompressedBatchSize)
+
+	data := make([]byte, 10*1024)
+	for _, codec := range []CompressionCodec{CompressionGZIP, CompressionSnappy, CompressionLZ4, CompressionZSTD} {
+		compressed, err := compress(codec, CompressionLevelDefault, false, data)
+		if err != nil {
+			t.Fatal(codec, err)
+		}
+
+		MaxDecompressedBatchSize = int32(len(data))
+		if _, err := decompress(codec, compressed); err != nil {
+			t.Errorf("%s: unexpected error at the limit: %v", codec, err)
+		}
+
+		MaxDecompressedBatchSize = int32(len(data) - 1)
+		if _, err := decompress(codec, compressed); err != ErrDecompressedSizeExceeded {
+			t.Errorf("%s: expected ErrDecompressedSizeExceeded, got %v", codec, err)
+		}
+
+		MaxDecompressedBatchSize = 0
+		if _, err := decompress(codec, compressed); err != nil {
+			t.Errorf("%s: unexpected error with the limit disabled: %v", codec, err)
+		}
+	}
+}
+
+func TestDecompressedResponseSizeLimit(t *testing.T) {
+	defer func(response int32) { MaxDecompressedResponseSize = response }(MaxDecompressedResponseSize)
+
+	inner := &MessageSet{}
+	inner.addMessage(&Message{Value: make([]byte, 1024)})
+	payload, err := encode(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := new(FetchResponse)
+	response.AddMessage("my_topic", 0, nil, ByteEncoder(nil), 0)
+	response.Blocks["my_topic"][0].MsgSet.Messages[0].Msg = &Message{Codec: CompressionGZIP, Value: payload}
+	response.AddMessage("my_topic", 1, nil, ByteEncoder(nil), 0)
+	response.Blocks["my_topic"][1].MsgSet.Messages[0].Msg = &Message{Codec: CompressionGZIP, Value: payload}
+	packet, err := encode(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	MaxDecompressedResponseSize = int32(2 * len(payload))
+	if err := decode(packet, new(FetchResponse)); err != nil {
+		t.Error("Unexpected error at the limit:", err)
+	}
+
+	MaxDecompressedResponseSize = int32(2*len(payload) - 1)
+	if err := decode(packet, new(FetchResponse)); err != ErrDecompressedSizeExceeded {
+		t.Error("Expected ErrDecompressedSizeExceeded, got", err)
+	}
+}
@@ -1,8 +1,13 @@
 package sarama
 
 import (
+	"compress/gzip"
 	"crypto/tls"
+	"fmt"
+	"math/rand"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 // Config is used to pass multiple configuration options to Sarama's constructors.
@@ -11,7 +16,11 @@ type Config struct {
 	// shared by the Client/Producer/Consumer.
 	Net struct {
 		// How many outstanding requests a connection is allowed to have before
-		// sending on it blocks (default 5).
+		// sending on it blocks (default 5). The producer pipelines up to this many
+		// produce requests to each broker; as with the JVM's
+		// `max.in.flight.requests.per.connection`, values above 1 mean that a
+		// failed and retried request can end up behind one sent after it, so set it
+		// to 1 if messages must never be reordered.
 		MaxOpenRequests int
 
 		// All three of the below configurations are similar to the
@@ -32,9 +41,65 @@ type Config struct {
 			Config *tls.Config
 		}
 
+		// SASL based authentication with the brokers, after the TLS handshake
+		// if TLS is enabled. It requires Version >= V0_10_0_0. From V1_0_0_0
+		// the tokens are exchanged in SaslAuthenticate requests, whose
+		// responses say why authentication failed.
+		SASL struct {
+			// Whether or not to authenticate with SASL when connecting to the
+			// broker (defaults to false).
+			Enable bool
+			// The mechanism to authenticate with: SASLTypePlaintext (the
+			// default), SASLTypeSCRAMSHA256 or SASLTypeSCRAMSHA512, which
+			// require Version >= V0_10_2_0.
+			Mechanism SASLMechanism
+			// The identity to act as with PLAIN, if not the User (defaults
+			// to empty).
+			AuthIdentity string
+			// The user and password to authenticate with.
+			User     string
+			Password string
+		}
+
 		// KeepAlive specifies the keep-alive period for an active network connection.
 		// If zero, keep-alives are disabled. (default is 0: disabled).
 		KeepAlive time.Duration
+
+		// Whether to resolve the host of each address given to the client into
+		// all its IP addresses, trying each as a separate seed broker (defaults
+		// to false). The hosts are resolved again every Metadata.RefreshFrequency
+		// and whenever every seed broker has failed, so brokers behind
+		// round-robin DNS or a Kubernetes Service are picked up as they move.
+		// With TLS, the certificates of the brokers are still verified against
+		// the host name, unless the TLS Config sets a ServerName.
+		ResolveSeedBrokers bool
+
+		// Requests whose response takes longer than this to arrive are logged to
+		// LogBroker at LogLevelWarn, with their API and version, the broker, the
+		// correlation ID and the duration (defaults to 0: disabled).
+		SlowRequestThreshold time.Duration
+
+		// The largest request, in bytes, to send to the brokers, and the largest
+		// response to read from them (both default to 0, for the global
+		// MaxRequestSize and MaxResponseSize, which they can only be lower than).
+		// Larger requests fail with a PacketEncodingError. A larger response
+		// fails its request with a PacketDecodingError before any of it is read,
+		// closing the connection, so that a corrupted or malicious broker can't
+		// make the client allocate more than this.
+		MaxRequestSize  int32
+		MaxResponseSize int32
+
+		// Debug is for diagnosing protocol problems, for instance with proxies or
+		// new broker versions. It is expensive and should not be left enabled.
+		Debug struct {
+			// Whether to log every request and response frame sent and received
+			// (defaults to false). Frames are logged to the LogProtocol subsystem at
+			// LogLevelInfo, with their correlation ID, API key and version, length
+			// and the start of the frame in hex.
+			DumpFrames bool
+			// How many bytes of each frame to dump (default 256).
+			MaxDumpBytes int
+		}
 	}
 
 	// Metadata is the namespace for metadata management properties used by the
@@ -52,13 +117,22 @@ type Config struct {
 		// Defaults to 10 minutes. Set to 0 to disable. Similar to
 		// `topic.metadata.refresh.interval.ms` in the JVM version.
 		RefreshFrequency time.Duration
+		// How long the metadata of a topic is trusted after it was fetched
+		// (default 0, forever). Asking the client for the partitions, leaders or
+		// replicas of a topic whose metadata is older first refreshes it, so
+		// leadership changes are picked up between background refreshes. If that
+		// refresh fails, the stale metadata is used.
+		TTL time.Duration
 	}
 
 	// Producer is the namespace for configuration related to producing messages,
 	// used by the Producer.
 	Producer struct {
 		// The maximum permitted size of a message (defaults to 1000000). Should be
-		// set equal to or smaller than the broker's `message.max.bytes`.
+		// set equal to or smaller than the broker's `message.max.bytes`. Messages
+		// whose key, value and headers add up to more, before compression, fail
+		// with ErrMessageSizeTooLarge without being sent; compressed batches, and
+		// from V0_11_0_0 all record batches, are also kept below it.
 		MaxMessageBytes int
 		// The level of acknowledgement reliability needed from the broker (defaults
 		// to WaitForLocal). Equivalent to the `request.required.acks` setting of the
@@ -71,12 +145,57 @@ type Config struct {
 		// the JVM producer's `request.timeout.ms` setting.
 		Timeout time.Duration
 		// The type of compression to use on messages (defaults to no compression).
-		// Similar to `compression.codec` setting of the JVM producer.
+		// Similar to `compression.codec` setting of the JVM producer. Codecs other
+		// than the built-in ones must be registered with RegisterCompressionCodec
+		// before the configuration is validated. When using
+		// CompressionLZ4, the frame format is chosen based on Version: brokers older
+		// than 0.10 require the legacy (incorrectly checksummed) LZ4 framing.
 		Compression CompressionCodec
+		// The level of compression to use on messages. The meaning depends
+		// on the actual compression type used and defaults to default compression
+		// level for the codec. GZIP, ZSTD and LZ4 honour it; LZ4 switches to its
+		// slower high compression mode above 0. As for Message, 0 selects the
+		// codec's default like CompressionLevelDefault, so it is rejected with
+		// CompressionGZIP, where it would mean no compression.
+		CompressionLevel int
 		// Generates partitioners for choosing the partition to send messages to
 		// (defaults to hashing the message key). Similar to the `partitioner.class`
 		// setting for the JVM producer.
 		Partitioner PartitionerConstructor
+		// If enabled, the producer acquires a producer ID and numbers the
+		// messages of each partition, so that the broker discards the duplicates
+		// retries would otherwise write (default disabled). It requires
+		// Version >= V0_11_0_0, RequiredAcks set to WaitForAll, Retry.Max >= 1
+		// and Net.MaxOpenRequests set to 1. A message that fails once it was
+		// numbered, because it ran out of retries or was rejected, leaves a gap
+		// in the numbers of its partition, which brokers would fail the
+		// partition's following messages for with ErrOutOfOrderSequenceNumber,
+		// so the producer then acquires a new producer ID and numbers the
+		// messages of every partition from 0 again. The messages retried after
+		// it may then be written twice. Equivalent to the `enable.idempotence`
+		// setting of the JVM producer.
+		Idempotent bool
+
+		// Transaction makes an idempotent producer transactional: its messages
+		// are then produced in transactions begun with BeginTxn, which commit or
+		// abort them all at once, together with offsets of consumer groups.
+		Transaction struct {
+			// The transactional ID of the producer (default none, which leaves
+			// the producer non-transactional). A producer that starts with the
+			// ID of another fences it, and aborts its ongoing transaction, so
+			// the ID must stay the same across restarts of a producer.
+			// Equivalent to the `transactional.id` setting of the JVM producer.
+			ID string
+			// How long the coordinator waits for an ongoing transaction to end
+			// before aborting it (default 1 minute). Equivalent to the
+			// `transaction.timeout.ms` setting of the JVM producer.
+			Timeout time.Duration
+		}
+
+		// Interceptors are called in order with every message sent to the
+		// producer, before it is partitioned (default none). Equivalent to the
+		// `interceptor.classes` setting of the JVM producer.
+		Interceptors []ProducerInterceptor
 
 		// Return specifies what channels will be populated. If they are set to true,
 		// you must read from the respective channels to prevent deadlock.
@@ -88,6 +207,32 @@ type Config struct {
 			// If enabled, messages that failed to deliver will be returned on the
 			// Errors channel, including error (default enabled).
 			Errors bool
+
+			// If not nil, messages that failed to deliver are passed to
+			// ErrorHandler rather than returned on the Errors channel, whatever
+			// Errors is set to, for instance to route them to a dead-letter
+			// topic. It is called from the goroutines of the producer, which it
+			// holds up until it returns. It is not supported by SyncProducer.
+			ErrorHandler func(*ProducerError)
+		}
+
+		// FlowControl limits the messages the producer holds, from the time it
+		// accepts them on Input until it returns them, and the rate it accepts
+		// them at, so that a burst of messages can neither exhaust memory nor
+		// overwhelm the cluster. Once a limit is reached, sends to Input block.
+		FlowControl struct {
+			// The maximum number of messages in flight (default 0 for
+			// unlimited).
+			MaxInFlightMessages int
+			// The maximum total size of the messages in flight, in bytes
+			// (default 0 for unlimited). A larger message is accepted once no
+			// other is in flight. Similar to the `buffer.memory` setting of the
+			// JVM producer.
+			MaxInFlightBytes int
+			// If not nil, RateLimiter paces the messages the producer accepts
+			// (default none). NewRateLimiter returns one that accepts a number
+			// of messages a second.
+			RateLimiter RateLimiter
 		}
 
 		// The following config options control how often messages are batched up and
@@ -118,6 +263,11 @@ type Config struct {
 			// (default 100ms). Similar to the `retry.backoff.ms` setting of the
 			// JVM producer.
 			Backoff time.Duration
+			// If set, BackoffFunc is called with the number of times a message
+			// has been retried, from 1, and Max, and returns how long to wait
+			// before retrying instead of Backoff. NewExponentialBackoff
+			// returns one that doubles the wait with every retry.
+			BackoffFunc func(retries, maxRetries int) time.Duration
 		}
 	}
 
@@ -167,6 +317,18 @@ type Config struct {
 		// (MaxProcessingTime * ChanneBufferSize). Defaults to 100ms.
 		MaxProcessingTime time.Duration
 
+		// IsolationLevel is what the consumer returns of the messages of
+		// transactional producers (default ReadUncommitted, every message).
+		// ReadCommitted, which requires Version >= V0_11_0_0, only returns the
+		// messages of committed transactions, once they have committed.
+		// Equivalent to the `isolation.level` setting of the JVM consumer.
+		IsolationLevel IsolationLevel
+
+		// Interceptors are called in order with every message consumed, before
+		// it is returned (default none). Equivalent to the
+		// `interceptor.classes` setting of the JVM consumer.
+		Interceptors []ConsumerInterceptor
+
 		// Return specifies what channels will be populated. If they are set to true,
 		// you must read from them to prevent deadlock.
 		Return struct {
@@ -176,27 +338,202 @@ type Config struct {
 		}
 
 		// Offsets specifies configuration for how and when to commit consumed
-		// offsets. This currently requires the manual use of an OffsetManager
-		// but will eventually be automated.
+		// offsets. This requires the manual use of an OffsetManager, unless
+		// consuming with a ConsumerGroup, which commits the offsets it marks.
 		Offsets struct {
 			// How frequently to commit updated offsets. Defaults to 1s.
 			CommitInterval time.Duration
 
+			// Whether to commit the offsets marked every CommitInterval (default
+			// true). If disabled, they are only committed by calling
+			// OffsetManager.Commit(), and as partition offset managers are closed
+			// or the claims of a ConsumerGroup are released.
+			AutoCommit bool
+
+			// How long the coordinator keeps the committed offsets of the group
+			// once it has no members (default 0, which defers to the broker's
+			// `offsets.retention.minutes`). Requires Version >= V0_9_0_0.
+			Retention time.Duration
+
+			// How frequently a LagMonitor recomputes the lag of its consumer group
+			// (default 10s).
+			LagInterval time.Duration
+
 			// The initial offset to use if no offset was previously committed.
-			// Should be OffsetNewest or OffsetOldest. Defaults to OffsetNewest.
+			// Should be OffsetNewest, OffsetOldest, or an OffsetTime to start
+			// from the first message at or after a timestamp, which requires
+			// Version >= V0_10_1_0. Defaults to OffsetNewest.
 			Initial int64
 		}
+
+		// Group is the namespace for configuring the membership of a ConsumerGroup
+		// in its group, which requires Version >= V0_9_0_0.
+		Group struct {
+			Session struct {
+				// How long the coordinator waits for a heartbeat before it removes
+				// the member from the group and rebalances it (default 10s). It
+				// must be within the broker's group.min.session.timeout.ms and
+				// group.max.session.timeout.ms, and lower than Net.ReadTimeout,
+				// as joining the group can take as long. Equivalent to the JVM's
+				// `session.timeout.ms`.
+				Timeout time.Duration
+			}
+			Heartbeat struct {
+				// How often to heartbeat to the coordinator, which is also how
+				// soon the member notices a rebalance (default 3s). It must be
+				// lower than Session.Timeout, and typically no more than a third
+				// of it. Equivalent to the JVM's `heartbeat.interval.ms`.
+				Interval time.Duration
+			}
+			Rebalance struct {
+				// The strategy the leader of the group assigns the partitions to
+				// the members with (default BalanceStrategyRange); see also
+				// BalanceStrategyRoundRobin, BalanceStrategySticky and
+				// BalanceStrategyCooperativeSticky, whose rebalances don't stop
+				// the whole group. Every member of a group must use the same
+				// strategy. Equivalent to the JVM's `partition.assignment.strategy`.
+				Strategy BalanceStrategy
+				Retry    struct {
+					// How many times joining the group is retried before the failure
+					// is returned; the member keeps trying to join after that
+					// (default 4).
+					Max int
+					// How long to wait after failing to join the group before
+					// trying again (default 2s).
+					Backoff time.Duration
+				}
+			}
+			Return struct {
+				// If enabled, rebalances are notified on the Notifications channel
+				// (default disabled).
+				Notifications bool
+			}
+		}
+	}
+
+	// Admin is the namespace for ClusterAdmin properties.
+	Admin struct {
+		// How long the controller waits for topics and partitions to be
+		// created or deleted before answering (default 3s). The requests fail
+		// with ErrRequestTimedOut if it takes longer, though the operation
+		// still completes in the background.
+		Timeout time.Duration
+	}
+
+	// Telemetry is the namespace for pushing client metrics to the brokers, as
+	// defined by KIP-714, so that they can be collected without scraping every
+	// application.
+	Telemetry struct {
+		// Whether the client pushes the metrics the brokers subscribe to
+		// (default false). Requires Version >= V3_7_0_0, and brokers with a
+		// client metrics reporter and subscriptions configured. The metrics
+		// pushed are those recorded in MetricRegistry, under the names of their
+		// equivalents in the JVM clients.
+		Enable bool
+		Retry  struct {
+			// How long to wait before trying again after failing to get a
+			// subscription or to push metrics (default 30s).
+			Backoff time.Duration
+		}
 	}
 
 	// A user-provided string sent with every request to the brokers for logging,
 	// debugging, and auditing purposes. Defaults to "sarama", but you should
 	// probably set it to something specific to your application.
 	ClientID string
+	// The rack the client runs in, like `client.rack` in the JVM version
+	// (defaults to empty). If set, which requires Version >= V2_3_0_0, the
+	// consumer sends it with its fetches, and leaders configured with a
+	// `replica.selector.class` may then have it fetch from a replica in the
+	// same rack (KIP-392).
+	ClientRack string
 	// The number of events to buffer in internal and external channels. This
 	// permits the producer and consumer to continue processing some messages
 	// in the background while user code is working, greatly improving throughput.
 	// Defaults to 256.
 	ChannelBufferSize int
+	// The version of Kafka that Sarama will assume it is running against.
+	// Defaults to the oldest supported stable version. Since Kafka provides
+	// backwards-compatibility, setting it to a version older than you have
+	// will not break anything, although it may prevent you from using the
+	// latest features. Setting it to a version greater than you are actually
+	// running may lead to random breakage.
+	Version KafkaVersion
+	// Whether to ask each broker which versions of each API it supports when
+	// connecting to it, if Version is at least V0_10_0_0 (default true).
+	// Requests are then sent at the newest version supported both by the
+	// broker and by Version; requests Version needs newer versions of than
+	// the broker supports fail with ErrUnsupportedVersion rather than being
+	// refused by the broker.
+	ApiVersionsRequest bool
+	// The registry to define metrics into. Defaults to a local registry.
+	// If you want to disable metrics gathering, set "metrics.UseNilMetrics" to
+	// "true" prior to starting Sarama.
+	//
+	// Brokers record incoming-byte-rate, request-rate, request-size,
+	// request-latency-in-ms, outgoing-byte-rate, response-rate, response-size
+	// and requests-in-flight, both in aggregate and with a -for-broker-<id>
+	// suffix. The producer records batch-size (in bytes), records-per-batch,
+	// record-send-rate, records-per-request, compression-ratio (multiplied by
+	// 100), record-retry-rate and record-error-rate, both in aggregate and with
+	// a -for-topic-<topic> suffix. The consumer records
+	// fetch-latency-in-ms, in aggregate and per broker, and consumer-lag and
+	// consumer-end-to-end-latency-in-ms for each partition consumed, with a
+	// -for-topic-<topic>-partition-<partition> suffix. The end-to-end latency is
+	// the time from the timestamp of each message, when it was produced or
+	// appended to the log, to when it was fetched, for messages which have one
+	// (from Version V0_10_0_0). Since the timestamp comes from the clock of the
+	// producer or of the broker, it is only as accurate as their clocks are in
+	// sync with the consumer's, and can even be negative.
+	// Brokers also record connection-attempt-rate, connection-creation-rate,
+	// connection-close-rate, connection-failure-rate, failed-authentication-rate
+	// (failed TLS handshakes and SASL authentications), handshake-latency-in-ms
+	// and connection-count, both in aggregate and, once the broker's ID is
+	// known, per broker.
+	// When Version is at least V0_9_0_0, brokers also record produce-throttle-time-in-ms
+	// and fetch-throttle-time-in-ms, the time responses were delayed by quotas,
+	// in aggregate and per broker.
+	// A LagMonitor records consumer-group-lag for each partition it monitors,
+	// with a -for-group-<group>-for-topic-<topic>-partition-<partition> suffix.
+	MetricRegistry metrics.Registry
+	// RequestObservers are notified of every request sent to a broker, with its
+	// response or error and latency; see RequestObserver (defaults to none).
+	RequestObservers []RequestObserver
+	// ConnectionObservers are notified each time a broker connects, fails to
+	// connect or is closed; see ConnectionObserver (defaults to none).
+	ConnectionObservers []ConnectionObserver
+	// ThrottleObservers are notified each time a broker throttles a produce or
+	// fetch response because of a quota; see ThrottleObserver (defaults to
+	// none).
+	ThrottleObservers []ThrottleObserver
+	// RandSource returns the source of randomness for the order in which a
+	// client tries the seed brokers, the broker it picks when any will do, and
+	// the spreading of its first telemetry push. It is called for each client,
+	// and for the telemetry of each, so that they don't share a source, which
+	// isn't safe for concurrent use. Defaults to sources seeded with the time;
+	// return sources of a fixed seed, such as rand.NewSource(1), for tests that
+	// assert exact behavior. See NewRandomPartitionerWithSource for partitioners.
+	RandSource func() rand.Source
+}
+
+func newTimeSeededSource() rand.Source {
+	return rand.NewSource(time.Now().UnixNano())
+}
+
+// NewExponentialBackoff returns a function for Producer.Retry.BackoffFunc that
+// waits initial before the first retry, then twice as long before each retry
+// after it, up to max.
+func NewExponentialBackoff(initial, max time.Duration) func(retries, maxRetries int) time.Duration {
+	return func(retries, maxRetries int) time.Duration {
+		backoff := initial
+		for i := 1; i < retries && backoff < max; i++ {
+			backoff *= 2
+		}
+		if backoff > max {
+			backoff = max
+		}
+		return backoff
+	}
 }
 
 // NewConfig returns a new configuration instance with sane defaults.
@@ -207,6 +544,8 @@ func NewConfig() *Config {
 	c.Net.DialTimeout = 30 * time.Second
 	c.Net.ReadTimeout = 30 * time.Second
 	c.Net.WriteTimeout = 30 * time.Second
+	c.Net.SASL.Mechanism = SASLTypePlaintext
+	c.Net.Debug.MaxDumpBytes = 256
 
 	c.Metadata.Retry.Max = 3
 	c.Metadata.Retry.Backoff = 250 * time.Millisecond
@@ -215,9 +554,11 @@ func NewConfig() *Config {
 	c.Producer.MaxMessageBytes = 1000000
 	c.Producer.RequiredAcks = WaitForLocal
 	c.Producer.Timeout = 10 * time.Second
+	c.Producer.CompressionLevel = CompressionLevelDefault
 	c.Producer.Partitioner = NewHashPartitioner
 	c.Producer.Retry.Max = 3
 	c.Producer.Retry.Backoff = 100 * time.Millisecond
+	c.Producer.Transaction.Timeout = 1 * time.Minute
 	c.Producer.Return.Errors = true
 
 	c.Consumer.Fetch.Min = 1
@@ -227,9 +568,24 @@ func NewConfig() *Config {
 	c.Consumer.MaxProcessingTime = 100 * time.Millisecond
 	c.Consumer.Return.Errors = false
 	c.Consumer.Offsets.CommitInterval = 1 * time.Second
+	c.Consumer.Offsets.AutoCommit = true
+	c.Consumer.Offsets.LagInterval = 10 * time.Second
 	c.Consumer.Offsets.Initial = OffsetNewest
+	c.Consumer.Group.Session.Timeout = 10 * time.Second
+	c.Consumer.Group.Heartbeat.Interval = 3 * time.Second
+	c.Consumer.Group.Rebalance.Strategy = BalanceStrategyRange
+	c.Consumer.Group.Rebalance.Retry.Max = 4
+	c.Consumer.Group.Rebalance.Retry.Backoff = 2 * time.Second
+
+	c.Admin.Timeout = 3 * time.Second
+
+	c.Telemetry.Retry.Backoff = 30 * time.Second
 
 	c.ChannelBufferSize = 256
+	c.Version = minVersion
+	c.ApiVersionsRequest = true
+	c.MetricRegistry = metrics.NewRegistry()
+	c.RandSource = newTimeSeededSource
 
 	return c
 }
@@ -239,28 +595,31 @@ func NewConfig() *Config {
 func (c *Config) Validate() error {
 	// some configuration values should be warned on but not fail completely, do those first
 	if c.Net.TLS.Enable == false && c.Net.TLS.Config != nil {
-		Logger.Println("Net.TLS is disabled but a non-nil configuration was provided.")
+		LogConfig.warn("Net.TLS is disabled but a non-nil configuration was provided")
+	}
+	if c.Net.SASL.Enable && c.Net.SASL.Mechanism == SASLTypePlaintext && !c.Net.TLS.Enable {
+		LogConfig.warn("Net.SASL is enabled with PLAIN but Net.TLS is not; the password will be sent in the clear")
 	}
 	if c.Producer.RequiredAcks > 1 {
-		Logger.Println("Producer.RequiredAcks > 1 is deprecated and will raise an exception with kafka >= 0.8.2.0.")
+		LogConfig.warn("Producer.RequiredAcks > 1 is deprecated and will raise an exception with kafka >= 0.8.2.0")
 	}
-	if c.Producer.MaxMessageBytes >= int(MaxRequestSize) {
-		Logger.Println("Producer.MaxMessageBytes is larger than MaxRequestSize; it will be ignored.")
+	if c.Producer.MaxMessageBytes >= int(c.maxRequestSize()) {
+		LogConfig.warn("Producer.MaxMessageBytes is larger than MaxRequestSize; it will be ignored")
 	}
-	if c.Producer.Flush.Bytes >= int(MaxRequestSize) {
-		Logger.Println("Producer.Flush.Bytes is larger than MaxRequestSize; it will be ignored.")
+	if c.Producer.Flush.Bytes >= int(c.maxRequestSize()) {
+		LogConfig.warn("Producer.Flush.Bytes is larger than MaxRequestSize; it will be ignored")
 	}
 	if c.Producer.Timeout%time.Millisecond != 0 {
-		Logger.Println("Producer.Timeout only supports millisecond resolution; nanoseconds will be truncated.")
+		LogConfig.warn("Producer.Timeout only supports millisecond resolution; nanoseconds will be truncated")
 	}
 	if c.Consumer.MaxWaitTime < 100*time.Millisecond {
-		Logger.Println("Consumer.MaxWaitTime is very low, which can cause high CPU and network usage. See documentation for details.")
+		LogConfig.warn("Consumer.MaxWaitTime is very low, which can cause high CPU and network usage; see documentation for details")
 	}
 	if c.Consumer.MaxWaitTime%time.Millisecond != 0 {
-		Logger.Println("Consumer.MaxWaitTime only supports millisecond precision; nanoseconds will be truncated.")
+		LogConfig.warn("Consumer.MaxWaitTime only supports millisecond precision; nanoseconds will be truncated")
 	}
 	if c.ClientID == "sarama" {
-		Logger.Println("ClientID is the default of 'sarama', you should consider setting it to something application-specific.")
+		LogConfig.warn("ClientID is the default of 'sarama', you should consider setting it to something application-specific")
 	}
 
 	// validate Net values
@@ -273,8 +632,26 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Net.ReadTimeout must be > 0")
 	case c.Net.WriteTimeout <= 0:
 		return ConfigurationError("Net.WriteTimeout must be > 0")
+	case c.Net.Debug.MaxDumpBytes < 0:
+		return ConfigurationError("Net.Debug.MaxDumpBytes must be >= 0")
 	case c.Net.KeepAlive < 0:
 		return ConfigurationError("Net.KeepAlive must be >= 0")
+	case c.Net.SlowRequestThreshold < 0:
+		return ConfigurationError("Net.SlowRequestThreshold must be >= 0")
+	case c.Net.MaxRequestSize < 0 || c.Net.MaxRequestSize > MaxRequestSize:
+		return ConfigurationError("Net.MaxRequestSize must be >= 0 and <= MaxRequestSize")
+	case c.Net.MaxResponseSize < 0 || c.Net.MaxResponseSize > MaxResponseSize:
+		return ConfigurationError("Net.MaxResponseSize must be >= 0 and <= MaxResponseSize")
+	case c.Net.SASL.Enable && !c.Version.IsAtLeast(V0_10_0_0):
+		return ConfigurationError("Net.SASL requires Version >= V0_10_0_0")
+	case c.Net.SASL.Enable && c.Net.SASL.User == "":
+		return ConfigurationError("Net.SASL.User must not be empty when SASL is enabled")
+	case c.Net.SASL.Enable && c.Net.SASL.Password == "":
+		return ConfigurationError("Net.SASL.Password must not be empty when SASL is enabled")
+	case c.Net.SASL.Enable && c.Net.SASL.Mechanism != SASLTypePlaintext && c.Net.SASL.Mechanism != SASLTypeSCRAMSHA256 && c.Net.SASL.Mechanism != SASLTypeSCRAMSHA512:
+		return ConfigurationError("Net.SASL.Mechanism must be SASLTypePlaintext, SASLTypeSCRAMSHA256 or SASLTypeSCRAMSHA512")
+	case c.Net.SASL.Enable && c.Net.SASL.Mechanism != SASLTypePlaintext && !c.Version.IsAtLeast(V0_10_2_0):
+		return ConfigurationError("Net.SASL.Mechanism SCRAM requires Version >= V0_10_2_0")
 	}
 
 	// validate the Metadata values
@@ -285,6 +662,8 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Metadata.Retry.Backoff must be >= 0")
 	case c.Metadata.RefreshFrequency < 0:
 		return ConfigurationError("Metadata.RefreshFrequency must be >= 0")
+	case c.Metadata.TTL < 0:
+		return ConfigurationError("Metadata.TTL must be >= 0")
 	}
 
 	// validate the Producer values
@@ -295,8 +674,25 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Producer.RequiredAcks must be >= -1")
 	case c.Producer.Timeout <= 0:
 		return ConfigurationError("Producer.Timeout must be > 0")
+	case !canCompress(c.Producer.Compression):
+		return ConfigurationError("Producer.Compression must be a built-in or registered compression codec")
+	case c.Producer.Compression == CompressionZSTD && !c.Version.IsAtLeast(V2_1_0_0):
+		return ConfigurationError("zstd compression requires Version >= V2_1_0_0")
+	case c.Producer.Compression == CompressionGZIP && c.Producer.CompressionLevel != CompressionLevelDefault &&
+		(c.Producer.CompressionLevel < gzip.DefaultCompression || c.Producer.CompressionLevel > gzip.BestCompression):
+		return ConfigurationError("Producer.CompressionLevel is not a valid gzip compression level")
+	case c.Producer.Compression == CompressionGZIP && c.Producer.CompressionLevel == gzip.NoCompression:
+		return ConfigurationError("Producer.CompressionLevel 0 selects the default gzip level; use CompressionNone not to compress")
+	case c.MetricRegistry == nil:
+		return ConfigurationError("MetricRegistry must not be nil")
+	case c.RandSource == nil:
+		return ConfigurationError("RandSource must not be nil")
 	case c.Producer.Partitioner == nil:
 		return ConfigurationError("Producer.Partitioner must not be nil")
+	case c.Producer.FlowControl.MaxInFlightMessages < 0:
+		return ConfigurationError("Producer.FlowControl.MaxInFlightMessages must be >= 0")
+	case c.Producer.FlowControl.MaxInFlightBytes < 0:
+		return ConfigurationError("Producer.FlowControl.MaxInFlightBytes must be >= 0")
 	case c.Producer.Flush.Bytes < 0:
 		return ConfigurationError("Producer.Flush.Bytes must be >= 0")
 	case c.Producer.Flush.Messages < 0:
@@ -313,6 +709,31 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Producer.Retry.Backoff must be >= 0")
 	}
 
+	if c.Producer.Idempotent {
+		switch {
+		case !c.Version.IsAtLeast(V0_11_0_0):
+			return ConfigurationError("Producer.Idempotent requires Version >= V0_11_0_0")
+		case c.Producer.RequiredAcks != WaitForAll:
+			return ConfigurationError("Producer.Idempotent requires Producer.RequiredAcks to be WaitForAll")
+		case c.Producer.Retry.Max == 0:
+			return ConfigurationError("Producer.Idempotent requires Producer.Retry.Max >= 1")
+		case c.Net.MaxOpenRequests > 1:
+			return ConfigurationError("Producer.Idempotent requires Net.MaxOpenRequests to be 1")
+		}
+	}
+
+	if c.Producer.Transaction.ID != "" {
+		switch {
+		case !c.Producer.Idempotent:
+			return ConfigurationError("Producer.Transaction.ID requires Producer.Idempotent")
+		case c.Producer.Transaction.Timeout < 1*time.Millisecond:
+			return ConfigurationError("Producer.Transaction.Timeout must be >= 1ms")
+		case (c.Producer.Flush.Messages > 0 || c.Producer.Flush.Bytes > 0) && c.Producer.Flush.Frequency == 0:
+			// ending a transaction waits for its messages, which could otherwise stay buffered
+			return ConfigurationError("Producer.Transaction.ID requires Producer.Flush.Frequency > 0 when flushing by messages or bytes")
+		}
+	}
+
 	// validate the Consumer values
 	switch {
 	case c.Consumer.Fetch.Min <= 0:
@@ -325,20 +746,86 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.MaxWaitTime must be >= 1ms")
 	case c.Consumer.MaxProcessingTime <= 0:
 		return ConfigurationError("Consumer.MaxProcessingTime must be > 0")
+	case c.Consumer.IsolationLevel != ReadUncommitted && c.Consumer.IsolationLevel != ReadCommitted:
+		return ConfigurationError("Consumer.IsolationLevel must be ReadUncommitted or ReadCommitted")
+	case c.Consumer.IsolationLevel == ReadCommitted && !c.Version.IsAtLeast(V0_11_0_0):
+		return ConfigurationError("Consumer.IsolationLevel ReadCommitted requires Version >= V0_11_0_0")
 	case c.Consumer.Retry.Backoff < 0:
 		return ConfigurationError("Consumer.Retry.Backoff must be >= 0")
 	case c.Consumer.Offsets.CommitInterval <= 0:
 		return ConfigurationError("Consumer.Offsets.CommitInterval must be > 0")
-	case c.Consumer.Offsets.Initial != OffsetOldest && c.Consumer.Offsets.Initial != OffsetNewest:
-		return ConfigurationError("Consumer.Offsets.Initial must be OffsetOldest or OffsetNewest")
+	case c.Consumer.Offsets.Retention < 0:
+		return ConfigurationError("Consumer.Offsets.Retention must be >= 0")
+	case c.Consumer.Offsets.Retention > 0 && !c.Version.IsAtLeast(V0_9_0_0):
+		return ConfigurationError("Consumer.Offsets.Retention requires Version >= V0_9_0_0")
+	case c.Consumer.Offsets.LagInterval <= 0:
+		return ConfigurationError("Consumer.Offsets.LagInterval must be > 0")
+	case c.Consumer.Offsets.Initial != OffsetOldest && c.Consumer.Offsets.Initial != OffsetNewest && c.Consumer.Offsets.Initial > offsetTimeBase:
+		return ConfigurationError("Consumer.Offsets.Initial must be OffsetOldest, OffsetNewest or an OffsetTime")
+	case c.Consumer.Offsets.Initial <= offsetTimeBase && !c.Version.IsAtLeast(V0_10_1_0):
+		return ConfigurationError("Consumer.Offsets.Initial as an OffsetTime requires Version >= V0_10_1_0")
+	case c.Consumer.Group.Session.Timeout < 2*time.Millisecond:
+		return ConfigurationError("Consumer.Group.Session.Timeout must be >= 2ms")
+	case c.Consumer.Group.Heartbeat.Interval < 1*time.Millisecond:
+		return ConfigurationError("Consumer.Group.Heartbeat.Interval must be >= 1ms")
+	case c.Consumer.Group.Heartbeat.Interval >= c.Consumer.Group.Session.Timeout:
+		return ConfigurationError("Consumer.Group.Heartbeat.Interval must be < Consumer.Group.Session.Timeout")
+	case c.Consumer.Group.Rebalance.Strategy == nil:
+		return ConfigurationError("Consumer.Group.Rebalance.Strategy must not be nil")
+	case c.Consumer.Group.Rebalance.Retry.Max < 0:
+		return ConfigurationError("Consumer.Group.Rebalance.Retry.Max must be >= 0")
+	case c.Consumer.Group.Rebalance.Retry.Backoff < 0:
+		return ConfigurationError("Consumer.Group.Rebalance.Retry.Backoff must be >= 0")
+	}
 
+	// validate the Admin values
+	switch {
+	case c.Admin.Timeout < 0:
+		return ConfigurationError("Admin.Timeout must be >= 0")
+	}
+
+	// validate the Telemetry values
+	switch {
+	case c.Telemetry.Enable && !c.Version.IsAtLeast(V3_7_0_0):
+		return ConfigurationError("Telemetry requires Version >= V3_7_0_0")
+	case c.Telemetry.Retry.Backoff < 0:
+		return ConfigurationError("Telemetry.Retry.Backoff must be >= 0")
 	}
 
 	// validate misc shared values
 	switch {
 	case c.ChannelBufferSize < 0:
 		return ConfigurationError("ChannelBufferSize must be >= 0")
+	case c.ClientRack != "" && !c.Version.IsAtLeast(V2_3_0_0):
+		return ConfigurationError("ClientRack requires Version >= V2_3_0_0")
+	}
+
+	return nil
+}
+
+// maxRequestSize returns Net.MaxRequestSize, or the global MaxRequestSize if it
+// is unset.
+func (c *Config) maxRequestSize() int32 {
+	if c.Net.MaxRequestSize > 0 {
+		return c.Net.MaxRequestSize
 	}
+	return MaxRequestSize
+}
 
+// maxResponseSize returns Net.MaxResponseSize, or the global MaxResponseSize if
+// it is unset.
+func (c *Config) maxResponseSize() int32 {
+	if c.Net.MaxResponseSize > 0 {
+		return c.Net.MaxResponseSize
+	}
+	return MaxResponseSize
+}
+
+// checkResponseSize fails a response whose header announces more than
+// maxResponseSize bytes.
+func (c *Config) checkResponseSize(length int32) error {
+	if length > c.maxResponseSize() {
+		return PacketDecodingError{fmt.Sprintf("response of length %d larger than Net.MaxResponseSize", length)}
+	}
 	return nil
 }
@@ -1,6 +1,9 @@
 package sarama
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestDefaultConfigValidates(t *testing.T) {
 	config := NewConfig()
@@ -8,3 +11,235 @@ func TestDefaultConfigValidates(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestZstdConfigRequiresVersion(t *testing.T) {
+	config := NewConfig()
+	config.Producer.Compression = CompressionZSTD
+	if err := config.Validate(); err == nil {
+		t.Error("Expected zstd compression to be rejected on the default Version")
+	}
+
+	config.Version = V2_1_0_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTelemetryConfigRequiresVersion(t *testing.T) {
+	config := NewConfig()
+	config.Telemetry.Enable = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected telemetry to be rejected on the default Version")
+	}
+
+	config.Version = V3_7_0_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGzipCompressionLevelValidation(t *testing.T) {
+	config := NewConfig()
+	config.Producer.Compression = CompressionGZIP
+	config.Producer.CompressionLevel = 42
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an out-of-range gzip compression level to be rejected")
+	}
+
+	config.Producer.CompressionLevel = 0
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a gzip compression level of 0, which would be ignored, to be rejected")
+	}
+
+	config.Producer.CompressionLevel = 9
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIdempotentProducerConfigValidation(t *testing.T) {
+	config := NewConfig()
+	config.Producer.Idempotent = true
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an idempotent producer to be rejected on the default Version")
+	}
+
+	config.Version = V0_11_0_0
+	config.Producer.RequiredAcks = WaitForAll
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an idempotent producer to be rejected with several requests in flight")
+	}
+
+	config.Net.MaxOpenRequests = 1
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	config.Producer.Retry.Max = 0
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an idempotent producer to be rejected without retries")
+	}
+}
+
+func TestProducerFlowControlValidation(t *testing.T) {
+	config := NewConfig()
+	config.Producer.FlowControl.MaxInFlightBytes = -1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a negative MaxInFlightBytes to be rejected")
+	}
+	config.Producer.FlowControl.MaxInFlightBytes = 1 << 20
+	config.Producer.FlowControl.MaxInFlightMessages = -1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a negative MaxInFlightMessages to be rejected")
+	}
+}
+
+func TestMaxRequestAndResponseSizeValidation(t *testing.T) {
+	config := NewConfig()
+	config.Net.MaxRequestSize = MaxRequestSize + 1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a MaxRequestSize larger than the global one to be rejected")
+	}
+	config.Net.MaxRequestSize = 1 << 20
+	config.Net.MaxResponseSize = -1
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a negative MaxResponseSize to be rejected")
+	}
+	config.Net.MaxResponseSize = 1 << 20
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTransactionalProducerConfigValidation(t *testing.T) {
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Transaction.ID = "my_txn"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a transactional producer to be rejected unless idempotent")
+	}
+
+	config.Producer.Idempotent = true
+	config.Producer.RequiredAcks = WaitForAll
+	config.Net.MaxOpenRequests = 1
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	config.Producer.Flush.Messages = 10
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a transactional producer to be rejected when buffering without a flush frequency")
+	}
+	config.Producer.Flush.Frequency = 10 * time.Millisecond
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIsolationLevelValidation(t *testing.T) {
+	config := NewConfig()
+	config.Consumer.IsolationLevel = ReadCommitted
+	if err := config.Validate(); err == nil {
+		t.Error("Expected reading committed messages to be rejected on the default Version")
+	}
+	config.Version = V0_11_0_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOffsetsRetentionValidation(t *testing.T) {
+	config := NewConfig()
+	config.Consumer.Offsets.Retention = time.Hour
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an offsets retention to be rejected on the default Version")
+	}
+	config.Version = V0_9_0_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+	config.Consumer.Offsets.Retention = -time.Hour
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a negative offsets retention to be rejected")
+	}
+}
+
+func TestInitialOffsetTimeValidation(t *testing.T) {
+	config := NewConfig()
+	config.Consumer.Offsets.Initial = OffsetTime(time.Now())
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an OffsetTime initial offset to be rejected on the default Version")
+	}
+	config.Version = V0_10_1_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+	config.Consumer.Offsets.Initial = 42
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a literal initial offset to be rejected")
+	}
+}
+
+func TestClientRackValidation(t *testing.T) {
+	config := NewConfig()
+	config.ClientRack = "rack"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a client rack to be rejected on the default Version")
+	}
+	config.Version = V2_3_0_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGroupHeartbeatIntervalValidation(t *testing.T) {
+	config := NewConfig()
+	config.Consumer.Group.Heartbeat.Interval = config.Consumer.Group.Session.Timeout
+	if err := config.Validate(); err == nil {
+		t.Error("Expected a heartbeat interval as long as the session timeout to be rejected")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := NewExponentialBackoff(100*time.Millisecond, time.Second)
+	for i, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		if actual := backoff(i+1, 10); actual != expected {
+			t.Errorf("Expected a backoff of %s before retry %d, got %s", expected, i+1, actual)
+		}
+	}
+}
+
+func TestSASLConfigValidation(t *testing.T) {
+	config := NewConfig()
+	config.Net.SASL.Enable = true
+	config.Net.SASL.User = "user"
+	config.Net.SASL.Password = "pencil"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected SASL to be rejected on the default Version")
+	}
+
+	config.Version = V0_10_0_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	config.Net.SASL.Mechanism = SASLTypeSCRAMSHA256
+	if err := config.Validate(); err == nil {
+		t.Error("Expected SCRAM to be rejected before V0_10_2_0")
+	}
+	config.Version = V0_10_2_0
+	if err := config.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	config.Net.SASL.Mechanism = "GSSAPI"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected an unsupported mechanism to be rejected")
+	}
+
+	config.Net.SASL.Mechanism = SASLTypePlaintext
+	config.Net.SASL.Password = ""
+	if err := config.Validate(); err == nil {
+		t.Error("Expected SASL without a password to be rejected")
+	}
+}
@@ -3,21 +3,62 @@ package sarama
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 // ConsumerMessage encapsulates a Kafka message returned by the consumer.
+//
+// Key and Value are not copied out of the fetch response they were decoded from:
+// they are slices of the same buffer as every other message in that response, so
+// holding on to any one message keeps the whole response in memory. The buffer is
+// never reused, so the slices stay valid for as long as they are referenced; use
+// Clone for messages that are retained well beyond their processing.
 type ConsumerMessage struct {
 	Key, Value []byte
 	Topic      string
 	Partition  int32
 	Offset     int64
+	// Timestamp is the time the message was produced, or appended to the log if
+	// the topic is configured with log append time; only set for messages of
+	// version 1 and later, which requires Version >= V0_10_0_0.
+	Timestamp time.Time
+	// Headers are the headers the message was produced with, which requires
+	// Version >= V0_11_0_0.
+	Headers []*RecordHeader
+}
+
+// Clone returns a copy of the message whose Key, Value and Headers no longer
+// share memory with the fetch response, allowing the response to be garbage
+// collected.
+func (m *ConsumerMessage) Clone() *ConsumerMessage {
+	clone := *m
+	clone.Key = cloneBytes(m.Key)
+	clone.Value = cloneBytes(m.Value)
+	if m.Headers != nil {
+		clone.Headers = make([]*RecordHeader, len(m.Headers))
+		for i, header := range m.Headers {
+			clone.Headers[i] = &RecordHeader{Key: cloneBytes(header.Key), Value: cloneBytes(header.Value)}
+		}
+	}
+	return &clone
+}
+
+// cloneBytes copies b, keeping nil as nil.
+func cloneBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append(make([]byte, 0, len(b)), b...)
 }
 
 // ConsumerError is what is provided to the user when an error occurs.
-// It wraps an error and includes the topic and partition.
+// It wraps an error and includes the topic and partition. Errors of the
+// requests to the broker the partition was fetched from are BrokerErrors.
 type ConsumerError struct {
 	Topic     string
 	Partition int32
@@ -28,6 +69,10 @@ func (ce ConsumerError) Error() string {
 	return fmt.Sprintf("kafka: error while consuming %s/%d: %s", ce.Topic, ce.Partition, ce.Err)
 }
 
+func (ce ConsumerError) Unwrap() error {
+	return ce.Err
+}
+
 // ConsumerErrors is a type that wraps a batch of errors and implements the Error interface.
 // It can be returned from the PartitionConsumer's Close methods to avoid the need to manually drain errors
 // when stopping.
@@ -41,9 +86,9 @@ func (ce ConsumerErrors) Error() string {
 // on a consumer to avoid leaks, it will not be garbage-collected automatically when it passes out of
 // scope.
 //
-// Sarama's Consumer type does not currently support automatic consumer group rebalancing and offset tracking,
-// however the https://github.com/wvanbergen/kafka library builds on Sarama to add this support. We plan
-// to properly integrate this functionality at a later date.
+// The Consumer does not track offsets or share partitions with other consumers by itself; a ConsumerGroup
+// builds on it to consume topics as a member of a consumer group, with automatic rebalancing and offset
+// tracking.
 type Consumer interface {
 
 	// Topics returns the set of available topics as retrieved from the cluster
@@ -57,10 +102,26 @@ type Consumer interface {
 
 	// ConsumePartition creates a PartitionConsumer on the given topic/partition with
 	// the given offset. It will return an error if this Consumer is already consuming
-	// on the given topic/partition. Offset can be a literal offset, or OffsetNewest
-	// or OffsetOldest
+	// on the given topic/partition. Offset can be a literal offset, OffsetNewest,
+	// OffsetOldest, or the pseudo-offset OffsetTime returns for a timestamp.
 	ConsumePartition(topic string, partition int32, offset int64) (PartitionConsumer, error)
 
+	// Pause stops fetching messages from the given partitions until they are
+	// resumed, without closing their PartitionConsumers; the messages already
+	// fetched are still delivered. A pause also holds for the partitions
+	// consumed after the call, so that a partition consumed again, as when a
+	// ConsumerGroup rebalances, stays paused.
+	Pause(topicPartitions map[string][]int32)
+
+	// Resume resumes fetching messages from the given paused partitions.
+	Resume(topicPartitions map[string][]int32)
+
+	// PauseAll pauses every partition being consumed.
+	PauseAll()
+
+	// ResumeAll resumes every paused partition.
+	ResumeAll()
+
 	// Close shuts down the consumer. It must be called after all child
 	// PartitionConsumers have already been closed.
 	Close() error
@@ -73,6 +134,7 @@ type consumer struct {
 
 	lock            sync.Mutex
 	children        map[string]map[int32]*partitionConsumer
+	paused          map[string]map[int32]bool
 	brokerConsumers map[*Broker]*brokerConsumer
 }
 
@@ -103,6 +165,7 @@ func NewConsumerFromClient(client Client) (Consumer, error) {
 		client:          client,
 		conf:            client.Config(),
 		children:        make(map[string]map[int32]*partitionConsumer),
+		paused:          make(map[string]map[int32]bool),
 		brokerConsumers: make(map[*Broker]*brokerConsumer),
 	}
 
@@ -136,15 +199,16 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 		trigger:   make(chan none, 1),
 		dying:     make(chan none),
 		fetchSize: c.conf.Consumer.Fetch.Default,
+
+		preferredReadReplica: -1,
 	}
 
 	if err := child.chooseStartingOffset(offset); err != nil {
 		return nil, err
 	}
 
-	var leader *Broker
-	var err error
-	if leader, err = c.client.Leader(child.topic, child.partition); err != nil {
+	broker, err := child.preferredBroker()
+	if err != nil {
 		return nil, err
 	}
 
@@ -152,10 +216,15 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 		return nil, err
 	}
 
+	child.lagMetricName = getMetricNameForPartition("consumer-lag", topic, partition)
+	child.lag = metrics.GetOrRegisterGauge(child.lagMetricName, c.conf.MetricRegistry)
+	child.latencyMetricName = getMetricNameForPartition("consumer-end-to-end-latency-in-ms", topic, partition)
+	child.latency = getOrRegisterHistogram(child.latencyMetricName, c.conf.MetricRegistry)
+
 	go withRecover(child.dispatcher)
 	go withRecover(child.responseFeeder)
 
-	child.broker = c.refBrokerConsumer(leader)
+	child.broker = c.refBrokerConsumer(broker)
 	child.broker.input <- child
 
 	return child, nil
@@ -176,6 +245,9 @@ func (c *consumer) addChild(child *partitionConsumer) error {
 	}
 
 	topicChildren[child.partition] = child
+	if c.paused[child.topic][child.partition] {
+		atomic.StoreInt32(&child.paused, 1)
+	}
 	return nil
 }
 
@@ -186,6 +258,74 @@ func (c *consumer) removeChild(child *partitionConsumer) {
 	delete(c.children[child.topic], child.partition)
 }
 
+func (c *consumer) Pause(topicPartitions map[string][]int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			c.setPaused(topic, partition, true)
+		}
+	}
+}
+
+func (c *consumer) Resume(topicPartitions map[string][]int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			c.setPaused(topic, partition, false)
+		}
+	}
+}
+
+func (c *consumer) PauseAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for topic, children := range c.children {
+		for partition := range children {
+			c.setPaused(topic, partition, true)
+		}
+	}
+}
+
+func (c *consumer) ResumeAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for topic, partitions := range c.paused {
+		for partition := range partitions {
+			c.setPaused(topic, partition, false)
+		}
+	}
+}
+
+// setPaused pauses or resumes a partition, and its child if it is being
+// consumed. It must be called with the lock held.
+func (c *consumer) setPaused(topic string, partition int32, paused bool) {
+	if paused {
+		if c.paused[topic] == nil {
+			c.paused[topic] = make(map[int32]bool)
+		}
+		c.paused[topic][partition] = true
+	} else {
+		delete(c.paused[topic], partition)
+		if len(c.paused[topic]) == 0 {
+			delete(c.paused, topic)
+		}
+	}
+
+	if child := c.children[topic][partition]; child != nil {
+		var flag int32
+		if paused {
+			flag = 1
+		}
+		atomic.StoreInt32(&child.paused, flag)
+	}
+}
+
 func (c *consumer) refBrokerConsumer(broker *Broker) *brokerConsumer {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -282,10 +422,27 @@ type partitionConsumer struct {
 
 	fetchSize           int32
 	offset              int64
+	position            int64 // offset as of the last response, published for snapshots
 	highWaterMarkOffset int64
+
+	lagMetricName string
+	lag           metrics.Gauge // messages between the fetched offset and the high water mark
+	// latency is the time between when messages were produced, or appended to
+	// the log, and when they were fetched
+	latencyMetricName string
+	latency           metrics.Histogram
+
+	// preferredReadReplica is the ID of the replica the leader redirected the
+	// consumer to with Config.ClientRack, or -1 to fetch from the leader
+	preferredReadReplica int32
+
+	paused int32 // 1 while the partition is paused, accessed atomically
 }
 
-var errTimedOut = errors.New("timed out feeding messages to the user") // not user-facing
+var (
+	errTimedOut   = errors.New("timed out feeding messages to the user")   // not user-facing
+	errRedirected = errors.New("redirected to the preferred read replica") // not user-facing
+)
 
 func (child *partitionConsumer) sendError(err error) {
 	cErr := &ConsumerError{
@@ -297,7 +454,7 @@ func (child *partitionConsumer) sendError(err error) {
 	if child.conf.Consumer.Return.Errors {
 		child.errors <- cErr
 	} else {
-		Logger.Println(cErr)
+		LogConsumer.error("partition consumer error", "topic", cErr.Topic, "partition", cErr.Partition, "err", cErr.Err)
 	}
 }
 
@@ -312,7 +469,7 @@ func (child *partitionConsumer) dispatcher() {
 				child.broker = nil
 			}
 
-			Logger.Printf("consumer/%s/%d finding new broker\n", child.topic, child.partition)
+			LogConsumer.info("finding new broker", "topic", child.topic, "partition", child.partition)
 			if err := child.dispatch(); err != nil {
 				child.sendError(err)
 				child.trigger <- none{}
@@ -332,19 +489,33 @@ func (child *partitionConsumer) dispatch() error {
 		return err
 	}
 
-	var leader *Broker
-	var err error
-	if leader, err = child.consumer.client.Leader(child.topic, child.partition); err != nil {
+	broker, err := child.preferredBroker()
+	if err != nil {
 		return err
 	}
 
-	child.broker = child.consumer.refBrokerConsumer(leader)
+	child.broker = child.consumer.refBrokerConsumer(broker)
 
 	child.broker.input <- child
 
 	return nil
 }
 
+// preferredBroker returns the broker to fetch from: the replica the leader
+// redirected the consumer to, if it is still known, and otherwise the leader.
+func (child *partitionConsumer) preferredBroker() (*Broker, error) {
+	if child.preferredReadReplica >= 0 {
+		broker, err := child.consumer.client.Broker(child.preferredReadReplica)
+		if err == nil {
+			return broker, nil
+		}
+		LogConsumer.warn("preferred read replica is unavailable, fetching from the leader",
+			"topic", child.topic, "partition", child.partition, "broker", child.preferredReadReplica, "err", err)
+		child.preferredReadReplica = -1
+	}
+	return child.consumer.client.Leader(child.topic, child.partition)
+}
+
 func (child *partitionConsumer) chooseStartingOffset(offset int64) error {
 	newestOffset, err := child.consumer.client.GetOffset(child.topic, child.partition, OffsetNewest)
 	if err != nil {
@@ -360,11 +531,26 @@ func (child *partitionConsumer) chooseStartingOffset(offset int64) error {
 		child.offset = newestOffset
 	case offset == OffsetOldest:
 		child.offset = oldestOffset
+	case offset <= offsetTimeBase:
+		if !child.conf.Version.IsAtLeast(V0_10_1_0) {
+			return ConfigurationError("consuming from an OffsetTime requires Version >= V0_10_1_0")
+		}
+		millis, _ := offsetTime(offset)
+		found, err := child.consumer.client.GetOffset(child.topic, child.partition, millis)
+		if err != nil {
+			return err
+		}
+		if found < 0 {
+			// no message is that recent yet
+			found = newestOffset
+		}
+		child.offset = found
 	case offset >= oldestOffset && offset <= newestOffset:
 		child.offset = offset
 	default:
 		return ErrOffsetOutOfRange
 	}
+	atomic.StoreInt64(&child.position, child.offset)
 
 	return nil
 }
@@ -415,6 +601,9 @@ func (child *partitionConsumer) responseFeeder() {
 feederLoop:
 	for response := range child.feeder {
 		msgs, child.responseResult = child.parseResponse(response)
+		for _, msg := range msgs {
+			interceptConsume(child.conf.Consumer.Interceptors, msg)
+		}
 
 		for i, msg := range msgs {
 			select {
@@ -433,6 +622,8 @@ feederLoop:
 		child.broker.acks.Done()
 	}
 
+	child.conf.MetricRegistry.Unregister(child.lagMetricName)
+	child.conf.MetricRegistry.Unregister(child.latencyMetricName)
 	close(child.messages)
 	close(child.errors)
 }
@@ -447,10 +638,15 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 		return nil, block.Err
 	}
 
-	if len(block.MsgSet.Messages) == 0 {
+	if replica := block.PreferredReadReplica; replica >= 0 && replica != child.broker.broker.ID() {
+		child.preferredReadReplica = replica
+		return nil, errRedirected
+	}
+
+	if block.empty() {
 		// We got no messages. If we got a trailing one then we need to ask for more data.
 		// Otherwise we just poll again and wait for one to be produced...
-		if block.MsgSet.PartialTrailingMessage {
+		if block.partial() {
 			if child.conf.Consumer.Fetch.Max > 0 && child.fetchSize == child.conf.Consumer.Fetch.Max {
 				// we can't ask for more data, we've hit the configured limit
 				child.sendError(ErrMessageTooLarge)
@@ -470,26 +666,33 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 	child.fetchSize = child.conf.Consumer.Fetch.Default
 	atomic.StoreInt64(&child.highWaterMarkOffset, block.HighWaterMarkOffset)
 
+	startOffset := child.offset
 	incomplete := false
 	prelude := true
 	var messages []*ConsumerMessage
 	for _, msgBlock := range block.MsgSet.Messages {
 
 		for _, msg := range msgBlock.Messages() {
-			if prelude && msg.Offset < child.offset {
+			offset := msgBlock.innerOffset(msg)
+			if prelude && offset < child.offset {
 				continue
 			}
 			prelude = false
 
-			if msg.Offset >= child.offset {
+			if offset >= child.offset {
+				timestamp := msg.Msg.Timestamp
+				if msgBlock.Msg.LogAppendTime {
+					timestamp = msgBlock.Msg.Timestamp
+				}
 				messages = append(messages, &ConsumerMessage{
 					Topic:     child.topic,
 					Partition: child.partition,
 					Key:       msg.Msg.Key,
 					Value:     msg.Msg.Value,
-					Offset:    msg.Offset,
+					Offset:    offset,
+					Timestamp: timestamp,
 				})
-				child.offset = msg.Offset + 1
+				child.offset = offset + 1
 			} else {
 				incomplete = true
 			}
@@ -497,9 +700,74 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 
 	}
 
-	if incomplete || len(messages) == 0 {
+	// reading committed messages, the transactional batches of a producer are
+	// skipped from the first offset of its aborted transaction to the marker
+	// that ends it
+	readCommitted := child.conf.Consumer.IsolationLevel == ReadCommitted
+	aborted := make([]*AbortedTransaction, len(block.AbortedTransactions))
+	copy(aborted, block.AbortedTransactions)
+	sort.Slice(aborted, func(i, j int) bool { return aborted[i].FirstOffset < aborted[j].FirstOffset })
+	abortedProducers := make(map[int64]bool)
+
+	for _, batch := range block.RecordBatches {
+		if readCommitted {
+			for len(aborted) > 0 && aborted[0].FirstOffset <= batch.LastOffset() {
+				abortedProducers[aborted[0].ProducerID] = true
+				aborted = aborted[1:]
+			}
+			if batch.Control && len(batch.Records) > 0 {
+				if kind, err := controlRecordType(batch.Records[0]); err == nil && kind == ControlRecordAbort {
+					delete(abortedProducers, batch.ProducerID)
+				}
+			}
+		}
+		if batch.LastOffset() < child.offset {
+			continue
+		}
+		if readCommitted && batch.Transactional && abortedProducers[batch.ProducerID] {
+			child.offset = batch.LastOffset() + 1
+			continue
+		}
+		for _, record := range batch.Records {
+			offset := batch.Offset(record)
+			if offset < child.offset || batch.Control {
+				continue
+			}
+			messages = append(messages, &ConsumerMessage{
+				Topic:     child.topic,
+				Partition: child.partition,
+				Key:       record.Key,
+				Value:     record.Value,
+				Offset:    offset,
+				Timestamp: batch.Timestamp(record),
+				Headers:   record.Headers,
+			})
+		}
+		// batches may end in offsets that were compacted away, and control batches
+		// hold no messages at all, so carry on after the last offset of the batch
+		child.offset = batch.LastOffset() + 1
+	}
+
+	if incomplete {
 		return nil, ErrIncompleteResponse
 	}
+	if len(messages) == 0 {
+		if child.offset > startOffset {
+			// only control records, or records before our offset
+			atomic.StoreInt64(&child.position, child.offset)
+			return nil, nil
+		}
+		return nil, ErrIncompleteResponse
+	}
+	child.lag.Update(block.HighWaterMarkOffset - child.offset)
+	now := time.Now()
+	for _, msg := range messages {
+		// messages of version 0 have no timestamp
+		if !msg.Timestamp.IsZero() {
+			child.latency.Update(int64(now.Sub(msg.Timestamp) / time.Millisecond))
+		}
+	}
+	atomic.StoreInt64(&child.position, child.offset)
 	return messages, nil
 }
 
@@ -512,6 +780,7 @@ type brokerConsumer struct {
 	newSubscriptions chan []*partitionConsumer
 	wait             chan none
 	subscriptions    map[*partitionConsumer]none
+	session          fetchSession
 	acks             sync.WaitGroup
 	refs             int
 }
@@ -587,33 +856,57 @@ func (bc *brokerConsumer) subscriptionConsumer() {
 			continue
 		}
 
-		response, err := bc.fetchNewMessages()
+		fetching := bc.unpausedSubscriptions()
+		if len(fetching) == 0 {
+			// every subscription is paused, so check again after the time a
+			// fetch would have waited, or as soon as there are new ones
+			select {
+			case <-bc.wait:
+			case <-time.After(bc.consumer.conf.Consumer.MaxWaitTime):
+			}
+			continue
+		}
+
+		response, err := bc.fetchNewMessages(fetching)
 
 		if err != nil {
-			Logger.Printf("consumer/broker/%d disconnecting due to error processing FetchRequest: %s\n", bc.broker.ID(), err)
+			LogConsumer.error("disconnecting due to error processing FetchRequest", "broker", bc.broker.ID(), "err", err)
 			bc.abort(err)
 			return
 		}
 
-		bc.acks.Add(len(bc.subscriptions))
-		for child := range bc.subscriptions {
+		bc.acks.Add(len(fetching))
+		for child := range fetching {
 			child.feeder <- response
 		}
 		bc.acks.Wait()
-		bc.handleResponses()
+		bc.handleResponses(fetching)
 	}
 }
 
+// unpausedSubscriptions returns the subscriptions to fetch, leaving out the
+// paused ones. Leaving them out of a fetch session forgets them, so that the
+// broker stops answering for them until they are resumed.
+func (bc *brokerConsumer) unpausedSubscriptions() map[*partitionConsumer]none {
+	subscriptions := make(map[*partitionConsumer]none, len(bc.subscriptions))
+	for child := range bc.subscriptions {
+		if atomic.LoadInt32(&child.paused) == 0 {
+			subscriptions[child] = none{}
+		}
+	}
+	return subscriptions
+}
+
 func (bc *brokerConsumer) updateSubscriptions(newSubscriptions []*partitionConsumer) {
 	for _, child := range newSubscriptions {
 		bc.subscriptions[child] = none{}
-		Logger.Printf("consumer/broker/%d added subscription to %s/%d\n", bc.broker.ID(), child.topic, child.partition)
+		LogConsumer.debug("added subscription", "broker", bc.broker.ID(), "topic", child.topic, "partition", child.partition)
 	}
 
 	for child := range bc.subscriptions {
 		select {
 		case <-child.dying:
-			Logger.Printf("consumer/broker/%d closed dead subscription to %s/%d\n", bc.broker.ID(), child.topic, child.partition)
+			LogConsumer.debug("closed dead subscription", "broker", bc.broker.ID(), "topic", child.topic, "partition", child.partition)
 			close(child.trigger)
 			delete(bc.subscriptions, child)
 		default:
@@ -622,9 +915,9 @@ func (bc *brokerConsumer) updateSubscriptions(newSubscriptions []*partitionConsu
 	}
 }
 
-func (bc *brokerConsumer) handleResponses() {
-	// handles the response codes left for us by our subscriptions, and abandons ones that have been closed
-	for child := range bc.subscriptions {
+func (bc *brokerConsumer) handleResponses(fetched map[*partitionConsumer]none) {
+	// handles the response codes left for us by the subscriptions we fetched, and abandons ones that have been closed
+	for child := range fetched {
 		result := child.responseResult
 		child.responseResult = nil
 
@@ -632,27 +925,43 @@ func (bc *brokerConsumer) handleResponses() {
 		case nil:
 			break
 		case errTimedOut:
-			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because consuming was taking too long\n",
-				bc.broker.ID(), child.topic, child.partition)
+			LogConsumer.warn("abandoned subscription because consuming was taking too long",
+				"broker", bc.broker.ID(), "topic", child.topic, "partition", child.partition)
+			delete(bc.subscriptions, child)
+		case errRedirected:
+			LogConsumer.info("moving subscription to the preferred read replica",
+				"broker", bc.broker.ID(), "topic", child.topic, "partition", child.partition, "replica", child.preferredReadReplica)
+			child.trigger <- none{}
 			delete(bc.subscriptions, child)
 		case ErrOffsetOutOfRange:
+			if child.preferredReadReplica >= 0 {
+				// the replica may lag behind the leader, which has the last word
+				LogConsumer.info("offset out of range on the preferred read replica, fetching from the leader",
+					"broker", bc.broker.ID(), "topic", child.topic, "partition", child.partition)
+				child.preferredReadReplica = -1
+				child.trigger <- none{}
+				delete(bc.subscriptions, child)
+				break
+			}
 			// there's no point in retrying this it will just fail the same way again
 			// shut it down and force the user to choose what to do
 			child.sendError(result)
-			Logger.Printf("consumer/%s/%d shutting down because %s\n", child.topic, child.partition, result)
+			LogConsumer.error("shutting down partition consumer", "topic", child.topic, "partition", child.partition, "err", result)
 			close(child.trigger)
 			delete(bc.subscriptions, child)
 		case ErrUnknownTopicOrPartition, ErrNotLeaderForPartition, ErrLeaderNotAvailable:
-			// not an error, but does need redispatching
-			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because %s\n",
-				bc.broker.ID(), child.topic, child.partition, result)
+			// not an error, but does need redispatching, to the leader
+			LogConsumer.info("abandoned subscription",
+				"broker", bc.broker.ID(), "topic", child.topic, "partition", child.partition, "err", result)
+			child.preferredReadReplica = -1
 			child.trigger <- none{}
 			delete(bc.subscriptions, child)
 		default:
-			// dunno, tell the user and try redispatching
+			// dunno, tell the user and try redispatching to the leader
 			child.sendError(result)
-			Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because %s\n",
-				bc.broker.ID(), child.topic, child.partition, result)
+			LogConsumer.warn("abandoned subscription",
+				"broker", bc.broker.ID(), "topic", child.topic, "partition", child.partition, "err", result)
+			child.preferredReadReplica = -1
 			child.trigger <- none{}
 			delete(bc.subscriptions, child)
 		}
@@ -662,29 +971,98 @@ func (bc *brokerConsumer) handleResponses() {
 func (bc *brokerConsumer) abort(err error) {
 	bc.consumer.abandonBrokerConsumer(bc)
 	_ = bc.broker.Close() // we don't care about the error this might return, we already have one
+	err = newBrokerError(bc.broker, err)
 
 	for child := range bc.subscriptions {
 		child.sendError(err)
+		child.preferredReadReplica = -1
 		child.trigger <- none{}
 	}
 
 	for newSubscription := range bc.newSubscriptions {
 		for _, child := range newSubscription {
 			child.sendError(err)
+			child.preferredReadReplica = -1
 			child.trigger <- none{}
 		}
 	}
 }
 
-func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
+func (bc *brokerConsumer) fetchNewMessages(subscriptions map[*partitionConsumer]none) (*FetchResponse, error) {
 	request := &FetchRequest{
 		MinBytes:    bc.consumer.conf.Consumer.Fetch.Min,
 		MaxWaitTime: int32(bc.consumer.conf.Consumer.MaxWaitTime / time.Millisecond),
 	}
+	switch {
+	case bc.consumer.conf.Version.IsAtLeast(V2_3_0_0):
+		request.Version = 11
+		request.MaxBytes = bc.consumer.conf.maxResponseSize()
+		request.Isolation = bc.consumer.conf.Consumer.IsolationLevel
+		request.RackID = bc.consumer.conf.ClientRack
+	case bc.consumer.conf.Version.IsAtLeast(V2_1_0_0):
+		// zstd compressed batches are only returned from version 10
+		request.Version = 10
+		request.MaxBytes = bc.consumer.conf.maxResponseSize()
+		request.Isolation = bc.consumer.conf.Consumer.IsolationLevel
+	case bc.consumer.conf.Version.IsAtLeast(V1_1_0_0):
+		request.Version = 7
+		request.MaxBytes = bc.consumer.conf.maxResponseSize()
+		request.Isolation = bc.consumer.conf.Consumer.IsolationLevel
+	case bc.consumer.conf.Version.IsAtLeast(V0_11_0_0):
+		request.Version = 4
+		request.MaxBytes = bc.consumer.conf.maxResponseSize()
+		request.Isolation = bc.consumer.conf.Consumer.IsolationLevel
+	case bc.consumer.conf.Version.IsAtLeast(V0_10_1_0):
+		request.Version = 3
+		request.MaxBytes = bc.consumer.conf.maxResponseSize()
+	case bc.consumer.conf.Version.IsAtLeast(V0_10_0_0):
+		request.Version = 2
+	case bc.consumer.conf.Version.IsAtLeast(V0_9_0_0):
+		request.Version = 1
+	}
 
-	for child := range bc.subscriptions {
-		request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize)
+	if request.Version >= 7 {
+		bc.session.addBlocks(request, subscriptions)
+	} else {
+		for child := range subscriptions {
+			request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize)
+		}
 	}
 
-	return bc.broker.Fetch(request)
+	requestTime := time.Now()
+	response, err := bc.broker.Fetch(request)
+	if err != nil {
+		return nil, err
+	}
+	bc.updateFetchLatencyMetrics(time.Since(requestTime))
+
+	if response.Version < 7 {
+		// the broker doesn't support fetch sessions
+		bc.session.reset()
+		return response, nil
+	}
+	switch response.Err {
+	case ErrNoError:
+		bc.session.update(request, response)
+		return response, nil
+	case ErrFetchSessionIDNotFound, ErrInvalidFetchSessionEpoch:
+		if request.SessionID == 0 {
+			return nil, response.Err
+		}
+		LogConsumer.info("fetch session was evicted, fetching every partition again",
+			"broker", bc.broker.ID(), "session", request.SessionID, "err", response.Err)
+		bc.session.reset()
+		return bc.fetchNewMessages(subscriptions)
+	default:
+		return nil, response.Err
+	}
+}
+
+// updateFetchLatencyMetrics records the time taken by a fetch, including the time
+// the broker spent waiting for Consumer.Fetch.Min bytes to become available.
+func (bc *brokerConsumer) updateFetchLatencyMetrics(latency time.Duration) {
+	registry := bc.consumer.conf.MetricRegistry
+	ms := int64(latency / time.Millisecond)
+	getOrRegisterHistogram("fetch-latency-in-ms", registry).Update(ms)
+	getOrRegisterHistogram(getMetricNameForBroker("fetch-latency-in-ms", bc.broker), registry).Update(ms)
 }
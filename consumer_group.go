@@ -0,0 +1,736 @@
+package sarama
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ConsumerGroupNotificationType is the type of a ConsumerGroupNotification.
+type ConsumerGroupNotificationType int8
+
+const (
+	// RebalanceStart is notified when the member starts to rebalance, before it
+	// releases its claims, or keeps them with RebalanceProtocolCooperative.
+	RebalanceStart ConsumerGroupNotificationType = iota
+	// RebalanceOK is notified when the member has joined the next generation of
+	// the group and claimed the partitions assigned to it.
+	RebalanceOK
+)
+
+// ConsumerGroupNotification notifies the application of a rebalance of the group
+// of a ConsumerGroup.
+type ConsumerGroupNotification struct {
+	Type ConsumerGroupNotificationType
+
+	// MemberID and GenerationID identify the member in the group: in the
+	// generation that is over for RebalanceStart, and in the new one for
+	// RebalanceOK.
+	MemberID     string
+	GenerationID int32
+
+	// Claimed, Released and Current are the partitions of each topic the member
+	// claimed in the rebalance, the ones it released, and all those it claims
+	// after it. They are only set for RebalanceOK.
+	Claimed  map[string][]int32
+	Released map[string][]int32
+	Current  map[string][]int32
+}
+
+// ConsumerGroupClaim is a partition claimed by a member of a consumer group,
+// which consumes it until the next rebalance of the group.
+type ConsumerGroupClaim interface {
+	// Topic and Partition identify the partition claimed.
+	Topic() string
+	Partition() int32
+
+	// Messages returns the read channel for the messages of the partition. It is
+	// closed when the claim is released, in a rebalance or as the ConsumerGroup
+	// is closed.
+	Messages() <-chan *ConsumerMessage
+
+	// HighWaterMarkOffset returns the high water mark offset of the partition,
+	// as PartitionConsumer.HighWaterMarkOffset does.
+	HighWaterMarkOffset() int64
+
+	// MarkOffset marks the message at the given offset as processed, alongside
+	// a metadata string, as PartitionOffsetManager.MarkOffset does. The offsets
+	// marked are committed every Consumer.Offsets.CommitInterval and when the
+	// claim is released, and the member that claims the partition next resumes
+	// from the last committed. Offsets marked after the claim is released are
+	// not committed.
+	MarkOffset(offset int64, metadata string)
+}
+
+// ConsumerGroup consumes topics as a member of a Kafka consumer group, sharing
+// their partitions with the other members of the group so that each partition
+// is consumed by a single member. The members coordinate through the group's
+// coordinator broker, which rebalances the group whenever a member joins or
+// leaves it, or fails to heartbeat within Consumer.Group.Session.Timeout. The
+// leader of each generation of the group assigns the partitions to the members
+// with Consumer.Group.Rebalance.Strategy.
+//
+// The partitions the member is assigned are delivered as claims on the Claims
+// channel. The messages of a claim start at the offset the group committed for
+// its partition, or Consumer.Offsets.Initial if there is none. A rebalance
+// releases every claim, closing its Messages channel and committing its marked
+// offset, before the member rejoins the group, then delivers the claims of the
+// new generation. With a strategy of RebalanceProtocolCooperative, the member
+// keeps its claims while it rejoins the group, then only releases those it
+// isn't assigned again and delivers the new ones. You MUST call Close() on a
+// ConsumerGroup to leave the group and avoid leaks. It requires
+// Version >= V0_9_0_0.
+type ConsumerGroup interface {
+	// Claims returns the read channel for the partitions claimed by the member.
+	// You must read from it: the member doesn't heartbeat while it waits to
+	// deliver a claim. It is closed when the ConsumerGroup is closed.
+	Claims() <-chan ConsumerGroupClaim
+
+	// Notifications returns the read channel for the rebalances of the group, if
+	// Consumer.Group.Return.Notifications is enabled, in which case you must
+	// read from it.
+	Notifications() <-chan *ConsumerGroupNotification
+
+	// Errors returns the read channel for the errors of the member, if
+	// Consumer.Return.Errors is enabled; otherwise they are logged. Errors of
+	// the group as a whole, such as failing to join it, have no topic and a
+	// partition of -1.
+	Errors() <-chan *ConsumerError
+
+	// Pause stops fetching messages from the given partitions until they are
+	// resumed, without leaving the group or releasing their claims. Pauses hold
+	// across rebalances: a paused partition the member claims again stays
+	// paused.
+	Pause(topicPartitions map[string][]int32)
+
+	// Resume resumes fetching messages from the given paused partitions.
+	Resume(topicPartitions map[string][]int32)
+
+	// PauseAll pauses every claimed partition.
+	PauseAll()
+
+	// ResumeAll resumes every paused partition.
+	ResumeAll()
+
+	// Close releases the claims of the member, committing their offsets, leaves
+	// the group and closes the channels.
+	Close() error
+}
+
+type consumerGroup struct {
+	client    Client
+	conf      *Config
+	ownClient bool
+	consumer  Consumer
+	offsets   *offsetManager
+	groupID   string
+	topics    []string
+
+	// stateLock guards the writes to memberID, generationID and claimed, which
+	// only the goroutine running the group makes, against snapshots
+	stateLock    sync.Mutex
+	memberID     string
+	generationID int32
+	claimed      []*consumerGroupClaim
+	// rejoin is set when the member released partitions with
+	// RebalanceProtocolCooperative, so that they are assigned to their new
+	// owners in another rebalance
+	rejoin bool
+
+	claims        chan ConsumerGroupClaim
+	notifications chan *ConsumerGroupNotification
+	errors        chan *ConsumerError
+	closing, done chan none
+	closeOnce     sync.Once
+}
+
+// NewConsumerGroup creates a new ConsumerGroup joining the group of the given ID
+// to consume the given topics, using the given broker addresses and
+// configuration.
+func NewConsumerGroup(addrs []string, groupID string, topics []string, config *Config) (ConsumerGroup, error) {
+	client, err := NewClient(addrs, config)
+	if err != nil {
+		return nil, err
+	}
+
+	cg, err := NewConsumerGroupFromClient(groupID, topics, client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	cg.(*consumerGroup).ownClient = true
+	return cg, nil
+}
+
+// NewConsumerGroupFromClient creates a new ConsumerGroup joining the group of
+// the given ID to consume the given topics, using the given client. It is still
+// necessary to call Close() on the underlying client after closing the group.
+func NewConsumerGroupFromClient(groupID string, topics []string, client Client) (ConsumerGroup, error) {
+	// Check that we are not dealing with a closed Client before processing any other arguments
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	conf := client.Config()
+	if !conf.Version.IsAtLeast(V0_9_0_0) {
+		return nil, ConfigurationError("ConsumerGroup requires Version >= V0_9_0_0")
+	}
+	if len(topics) == 0 {
+		return nil, ConfigurationError("ConsumerGroup requires at least one topic")
+	}
+
+	consumer, err := NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+
+	cg := &consumerGroup{
+		client:        client,
+		conf:          conf,
+		consumer:      consumer,
+		offsets:       newOffsetManagerFromClient(groupID, client),
+		groupID:       groupID,
+		topics:        append([]string(nil), topics...),
+		generationID:  GroupGenerationUndefined,
+		claims:        make(chan ConsumerGroupClaim, conf.ChannelBufferSize),
+		notifications: make(chan *ConsumerGroupNotification, conf.ChannelBufferSize),
+		errors:        make(chan *ConsumerError, conf.ChannelBufferSize),
+		closing:       make(chan none),
+		done:          make(chan none),
+	}
+
+	go withRecover(cg.run)
+
+	return cg, nil
+}
+
+func (cg *consumerGroup) Claims() <-chan ConsumerGroupClaim {
+	return cg.claims
+}
+
+func (cg *consumerGroup) Notifications() <-chan *ConsumerGroupNotification {
+	return cg.notifications
+}
+
+func (cg *consumerGroup) Errors() <-chan *ConsumerError {
+	return cg.errors
+}
+
+func (cg *consumerGroup) Pause(topicPartitions map[string][]int32) {
+	cg.consumer.Pause(topicPartitions)
+}
+
+func (cg *consumerGroup) Resume(topicPartitions map[string][]int32) {
+	cg.consumer.Resume(topicPartitions)
+}
+
+func (cg *consumerGroup) PauseAll() {
+	cg.consumer.PauseAll()
+}
+
+func (cg *consumerGroup) ResumeAll() {
+	cg.consumer.ResumeAll()
+}
+
+func (cg *consumerGroup) Close() (err error) {
+	cg.closeOnce.Do(func() {
+		close(cg.closing)
+		<-cg.done
+
+		close(cg.claims)
+		close(cg.notifications)
+		close(cg.errors)
+
+		err = cg.consumer.Close()
+		if cg.ownClient {
+			if cerr := cg.client.Close(); err == nil {
+				err = cerr
+			}
+		}
+	})
+	return
+}
+
+func (cg *consumerGroup) run() {
+	defer close(cg.done)
+
+	for cg.rebalance() && cg.heartbeat() {
+	}
+
+	cg.release()
+	cg.leave()
+}
+
+// rebalance releases the claims of the member and rejoins the group until it
+// succeeds, then delivers the new claims. It returns false if the group is
+// closed meanwhile.
+func (cg *consumerGroup) rebalance() bool {
+	if !cg.notify(&ConsumerGroupNotification{Type: RebalanceStart, MemberID: cg.memberID, GenerationID: cg.generationID}) {
+		return false
+	}
+	cooperative := rebalanceProtocol(cg.conf.Consumer.Group.Rebalance.Strategy) == RebalanceProtocolCooperative
+	previous := cg.claimedPartitions()
+	if !cooperative {
+		cg.release()
+	}
+	held := make(map[*consumerGroupClaim]bool, len(cg.claimed))
+	for _, claim := range cg.claimed {
+		held[claim] = true
+	}
+
+	owned := previous
+	for attempt := 1; ; attempt++ {
+		if cg.memberID == "" {
+			// the member is new to the group, or the coordinator removed it, in
+			// which case its partitions may already be assigned to others
+			cg.release()
+			owned = nil
+		}
+		err := cg.join(owned)
+		if err == nil {
+			break
+		}
+
+		LogGroup.info("failed to join the group", "group", cg.groupID, "attempt", attempt, "err", err)
+		if attempt > cg.conf.Consumer.Group.Rebalance.Retry.Max {
+			cg.handleError(&ConsumerError{Partition: -1, Err: err})
+			attempt = 0
+		}
+
+		select {
+		case <-time.After(cg.conf.Consumer.Group.Rebalance.Retry.Backoff):
+		case <-cg.closing:
+			return false
+		}
+	}
+
+	current := cg.claimedPartitions()
+	LogGroup.info("joined the group", "group", cg.groupID, "member", cg.memberID, "generation", cg.generationID, "claims", len(cg.claimed))
+	notification := &ConsumerGroupNotification{
+		Type:         RebalanceOK,
+		MemberID:     cg.memberID,
+		GenerationID: cg.generationID,
+		Claimed:      partitionsDifference(current, previous),
+		Released:     partitionsDifference(previous, current),
+		Current:      current,
+	}
+	if !cg.notify(notification) {
+		return false
+	}
+	cg.rejoin = cooperative && len(notification.Released) > 0
+
+	for _, claim := range cg.claimed {
+		if held[claim] {
+			// delivered in a previous generation
+			continue
+		}
+		select {
+		case cg.claims <- claim:
+		case <-cg.closing:
+			return false
+		}
+	}
+	return true
+}
+
+// join joins the next generation of the group, assigning the partitions to its
+// members if the member leads it, and claims the partitions assigned to it. The
+// member tells the group the partitions it owned, for sticky strategies.
+func (cg *consumerGroup) join(owned map[string][]int32) error {
+	coordinator, err := cg.client.Coordinator(cg.groupID)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := encode(&ConsumerGroupMemberMetadata{Version: 1, Topics: cg.topics, OwnedPartitions: owned})
+	if err != nil {
+		return err
+	}
+	request := &JoinGroupRequest{
+		GroupId:        cg.groupID,
+		SessionTimeout: int32(cg.conf.Consumer.Group.Session.Timeout / time.Millisecond),
+		MemberId:       cg.memberID,
+		ProtocolType:   "consumer",
+	}
+	request.AddGroupProtocol(cg.conf.Consumer.Group.Rebalance.Strategy.Name(), metadata)
+
+	joined, err := coordinator.JoinGroup(request)
+	if err == nil && joined.Err != ErrNoError {
+		err = joined.Err
+	}
+	if err != nil {
+		cg.recoverFrom(coordinator, err)
+		return err
+	}
+	cg.stateLock.Lock()
+	cg.memberID, cg.generationID = joined.MemberId, joined.GenerationId
+	cg.stateLock.Unlock()
+
+	sync := &SyncGroupRequest{GroupId: cg.groupID, GenerationId: cg.generationID, MemberId: cg.memberID}
+	if joined.LeaderId == joined.MemberId {
+		plan, err := cg.plan(joined.Members)
+		if err != nil {
+			return err
+		}
+		for memberID, topics := range plan {
+			assignment, err := encode(&ConsumerGroupMemberAssignment{Topics: topics})
+			if err != nil {
+				return err
+			}
+			sync.AddGroupAssignment(memberID, assignment)
+		}
+	}
+
+	synced, err := coordinator.SyncGroup(sync)
+	if err == nil && synced.Err != ErrNoError {
+		err = synced.Err
+	}
+	if err != nil {
+		cg.recoverFrom(coordinator, err)
+		return err
+	}
+
+	assignment := new(ConsumerGroupMemberAssignment)
+	if len(synced.MemberAssignment) > 0 {
+		if err := decode(synced.MemberAssignment, assignment); err != nil {
+			return err
+		}
+	}
+
+	cg.offsets.setGeneration(cg.memberID, cg.generationID)
+	return cg.claim(assignment.Topics)
+}
+
+// plan assigns the partitions of the topics the members subscribe to, given
+// the metadata they joined the group with.
+func (cg *consumerGroup) plan(members map[string][]byte) (BalanceStrategyPlan, error) {
+	subscriptions := make(map[string]ConsumerGroupMemberMetadata, len(members))
+	var topics []string
+	subscribed := make(map[string]bool)
+	for memberID, data := range members {
+		var metadata ConsumerGroupMemberMetadata
+		if err := decode(data, &metadata); err != nil {
+			return nil, err
+		}
+		subscriptions[memberID] = metadata
+
+		for _, topic := range metadata.Topics {
+			if !subscribed[topic] {
+				subscribed[topic] = true
+				topics = append(topics, topic)
+			}
+		}
+	}
+
+	if err := cg.client.RefreshMetadata(topics...); err != nil {
+		return nil, err
+	}
+	partitions := make(map[string][]int32, len(topics))
+	for _, topic := range topics {
+		ids, err := cg.client.Partitions(topic)
+		if err != nil {
+			return nil, err
+		}
+		partitions[topic] = ids
+	}
+
+	return cg.conf.Consumer.Group.Rebalance.Strategy.Plan(subscriptions, partitions)
+}
+
+// recoverFrom prepares the next attempt after the coordinator failed a request.
+func (cg *consumerGroup) recoverFrom(coordinator *Broker, err error) {
+	switch err {
+	case ErrUnknownMemberId:
+		// the coordinator removed the member from the group, so it must join it
+		// as a new member
+		cg.stateLock.Lock()
+		cg.memberID = ""
+		cg.stateLock.Unlock()
+	case ErrIllegalGeneration, ErrRebalanceInProgress:
+	case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable:
+		_ = cg.client.RefreshCoordinator(cg.groupID)
+	default:
+		if _, ok := err.(KError); !ok {
+			// the connection to the coordinator failed
+			_ = coordinator.Close()
+			_ = cg.client.RefreshCoordinator(cg.groupID)
+		}
+	}
+}
+
+// claim starts consuming the given partitions of each topic, from the offsets
+// committed by the group. The claims the member still holds are kept if the
+// partition is assigned to it again, and released otherwise.
+func (cg *consumerGroup) claim(assignment map[string][]int32) error {
+	held := make(map[string]map[int32]*consumerGroupClaim)
+	for _, claim := range cg.claimed {
+		if held[claim.topic] == nil {
+			held[claim.topic] = make(map[int32]*consumerGroupClaim)
+		}
+		held[claim.topic][claim.partition] = claim
+	}
+	var revoked []*consumerGroupClaim
+	for _, claim := range cg.claimed {
+		if !partitionsContain(assignment[claim.topic], claim.partition) {
+			revoked = append(revoked, claim)
+			delete(held[claim.topic], claim.partition)
+		}
+	}
+	releaseClaims(revoked)
+
+	topics := make([]string, 0, len(assignment))
+	for topic := range assignment {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	var claimed []*consumerGroupClaim
+	for _, topic := range topics {
+		partitions := append([]int32(nil), assignment[topic]...)
+		sort.Sort(int32Slice(partitions))
+		for _, partition := range partitions {
+			if claim := held[topic][partition]; claim != nil {
+				delete(held[topic], partition)
+				claimed = append(claimed, claim)
+				continue
+			}
+			claim, err := cg.newClaim(topic, partition)
+			if err != nil {
+				for _, partitions := range held {
+					for _, claim := range partitions {
+						claimed = append(claimed, claim)
+					}
+				}
+				cg.setClaimed(claimed)
+				cg.release()
+				return err
+			}
+			claimed = append(claimed, claim)
+		}
+	}
+	cg.setClaimed(claimed)
+	return nil
+}
+
+func (cg *consumerGroup) setClaimed(claimed []*consumerGroupClaim) {
+	cg.stateLock.Lock()
+	cg.claimed = claimed
+	cg.stateLock.Unlock()
+}
+
+func partitionsContain(partitions []int32, partition int32) bool {
+	for _, p := range partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// release stops consuming the claimed partitions and commits their marked
+// offsets.
+func (cg *consumerGroup) release() {
+	releaseClaims(cg.claimed)
+	cg.setClaimed(nil)
+}
+
+// releaseClaims stops consuming the partitions of the claims and commits their
+// marked offsets. It waits for each partition consumer to stop before it closes
+// the partition's offset manager, so as to commit the last offset marked while
+// the claim was still held.
+func releaseClaims(claims []*consumerGroupClaim) {
+	for _, claim := range claims {
+		claim.consumer.AsyncClose()
+		go withRecover(claim.drain)
+	}
+	for _, claim := range claims {
+		claim.consuming.Wait()
+		claim.offsets.AsyncClose()
+	}
+	for _, claim := range claims {
+		claim.committing.Wait()
+	}
+}
+
+// leave leaves the group, so that it rebalances without waiting for the
+// session of the member to time out.
+func (cg *consumerGroup) leave() {
+	if cg.memberID == "" {
+		return
+	}
+
+	coordinator, err := cg.client.Coordinator(cg.groupID)
+	if err == nil {
+		var response *LeaveGroupResponse
+		response, err = coordinator.LeaveGroup(&LeaveGroupRequest{GroupId: cg.groupID, MemberId: cg.memberID})
+		if err == nil && response.Err != ErrNoError {
+			err = response.Err
+		}
+	}
+	if err != nil {
+		LogGroup.warn("failed to leave the group", "group", cg.groupID, "member", cg.memberID, "err", err)
+	}
+}
+
+// heartbeat heartbeats to the coordinator every Consumer.Group.Heartbeat.Interval
+// until the group needs to be rejoined, in which case it returns true, or the
+// ConsumerGroup is closed, in which case it returns false.
+func (cg *consumerGroup) heartbeat() bool {
+	if cg.rejoin {
+		LogGroup.info("rejoining the group to hand over the released partitions", "group", cg.groupID, "member", cg.memberID)
+		cg.rejoin = false
+		return true
+	}
+
+	ticker := time.NewTicker(cg.conf.Consumer.Group.Heartbeat.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-cg.closing:
+			return false
+		}
+
+		coordinator, err := cg.client.Coordinator(cg.groupID)
+		if err == nil {
+			var response *HeartbeatResponse
+			response, err = coordinator.Heartbeat(&HeartbeatRequest{GroupId: cg.groupID, GenerationId: cg.generationID, MemberId: cg.memberID})
+			if err == nil && response.Err != ErrNoError {
+				err = response.Err
+			}
+			if err != nil {
+				cg.recoverFrom(coordinator, err)
+			}
+		}
+		if err != nil {
+			LogGroup.info("rejoining the group", "group", cg.groupID, "member", cg.memberID, "err", err)
+			return true
+		}
+	}
+}
+
+func (cg *consumerGroup) notify(notification *ConsumerGroupNotification) bool {
+	if !cg.conf.Consumer.Group.Return.Notifications {
+		return true
+	}
+
+	select {
+	case cg.notifications <- notification:
+		return true
+	case <-cg.closing:
+		return false
+	}
+}
+
+func (cg *consumerGroup) handleError(err *ConsumerError) {
+	if cg.conf.Consumer.Return.Errors {
+		select {
+		case cg.errors <- err:
+			return
+		case <-cg.closing:
+		}
+	}
+	LogGroup.error("consumer group error", "group", cg.groupID, "topic", err.Topic, "partition", err.Partition, "err", err.Err)
+}
+
+// forward returns the errors of the partition consumer or offset manager of a
+// claim as errors of the group.
+func (cg *consumerGroup) forward(errors <-chan *ConsumerError, done *sync.WaitGroup) {
+	defer done.Done()
+	for err := range errors {
+		cg.handleError(err)
+	}
+}
+
+func (cg *consumerGroup) claimedPartitions() map[string][]int32 {
+	partitions := make(map[string][]int32)
+	for _, claim := range cg.claimed {
+		partitions[claim.topic] = append(partitions[claim.topic], claim.partition)
+	}
+	return partitions
+}
+
+// partitionsDifference returns the partitions of a that are not in b.
+func partitionsDifference(a, b map[string][]int32) map[string][]int32 {
+	difference := make(map[string][]int32)
+	for topic, partitions := range a {
+		in := make(map[int32]bool, len(b[topic]))
+		for _, partition := range b[topic] {
+			in[partition] = true
+		}
+		for _, partition := range partitions {
+			if !in[partition] {
+				difference[topic] = append(difference[topic], partition)
+			}
+		}
+	}
+	return difference
+}
+
+type consumerGroupClaim struct {
+	topic     string
+	partition int32
+	consumer  PartitionConsumer
+	offsets   PartitionOffsetManager
+
+	consuming, committing sync.WaitGroup
+}
+
+func (cg *consumerGroup) newClaim(topic string, partition int32) (*consumerGroupClaim, error) {
+	offsets, err := cg.offsets.ManagePartition(topic, partition)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, _ := offsets.NextOffset()
+	consumer, err := cg.consumer.ConsumePartition(topic, partition, offset)
+	if err == ErrOffsetOutOfRange {
+		// the committed offset was deleted by the retention of the partition
+		consumer, err = cg.consumer.ConsumePartition(topic, partition, cg.conf.Consumer.Offsets.Initial)
+	}
+	if err != nil {
+		_ = offsets.Close()
+		return nil, err
+	}
+
+	claim := &consumerGroupClaim{
+		topic:     topic,
+		partition: partition,
+		consumer:  consumer,
+		offsets:   offsets,
+	}
+	claim.consuming.Add(1)
+	claim.committing.Add(1)
+	go withRecover(func() { cg.forward(consumer.Errors(), &claim.consuming) })
+	go withRecover(func() { cg.forward(offsets.Errors(), &claim.committing) })
+
+	return claim, nil
+}
+
+func (c *consumerGroupClaim) Topic() string {
+	return c.topic
+}
+
+func (c *consumerGroupClaim) Partition() int32 {
+	return c.partition
+}
+
+func (c *consumerGroupClaim) Messages() <-chan *ConsumerMessage {
+	return c.consumer.Messages()
+}
+
+func (c *consumerGroupClaim) HighWaterMarkOffset() int64 {
+	return c.consumer.HighWaterMarkOffset()
+}
+
+func (c *consumerGroupClaim) MarkOffset(offset int64, metadata string) {
+	c.offsets.MarkOffset(offset, metadata)
+}
+
+// drain discards the messages of a released claim the application hasn't read,
+// so that its partition consumer can stop.
+func (c *consumerGroupClaim) drain() {
+	for _ = range c.consumer.Messages() {
+	}
+}
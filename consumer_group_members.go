@@ -0,0 +1,132 @@
+package sarama
+
+// ConsumerGroupMemberMetadata is the member metadata of the "consumer" protocol
+// type: the topics a member of a consumer group subscribes to and, from version
+// 1, the partitions of each topic it was assigned in the previous generation.
+type ConsumerGroupMemberMetadata struct {
+	Version         int16
+	Topics          []string
+	UserData        []byte
+	OwnedPartitions map[string][]int32
+}
+
+func (m *ConsumerGroupMemberMetadata) encode(pe packetEncoder) error {
+	pe.putInt16(m.Version)
+
+	if err := pe.putStringArray(m.Topics); err != nil {
+		return err
+	}
+
+	if err := pe.putBytes(m.UserData); err != nil {
+		return err
+	}
+
+	if m.Version >= 1 {
+		if err := pe.putArrayLength(len(m.OwnedPartitions)); err != nil {
+			return err
+		}
+		for topic, partitions := range m.OwnedPartitions {
+			if err := pe.putString(topic); err != nil {
+				return err
+			}
+			if err := pe.putInt32Array(partitions); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *ConsumerGroupMemberMetadata) decode(pd packetDecoder) (err error) {
+	if m.Version, err = pd.getInt16(); err != nil {
+		return
+	}
+
+	if m.Topics, err = pd.getStringArray(); err != nil {
+		return
+	}
+
+	if m.UserData, err = pd.getBytes(); err != nil {
+		return
+	}
+
+	if m.Version >= 1 {
+		var topicLen int
+		if topicLen, err = pd.getArrayLength(); err != nil {
+			return
+		}
+		m.OwnedPartitions = make(map[string][]int32, topicLen)
+		for i := 0; i < topicLen; i++ {
+			var topic string
+			if topic, err = pd.getString(); err != nil {
+				return
+			}
+			if m.OwnedPartitions[topic], err = pd.getInt32Array(); err != nil {
+				return
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConsumerGroupMemberAssignment is the member assignment of the "consumer"
+// protocol type: the partitions of each topic assigned to a member of a consumer
+// group.
+type ConsumerGroupMemberAssignment struct {
+	Version  int16
+	Topics   map[string][]int32
+	UserData []byte
+}
+
+func (m *ConsumerGroupMemberAssignment) encode(pe packetEncoder) error {
+	pe.putInt16(m.Version)
+
+	if err := pe.putArrayLength(len(m.Topics)); err != nil {
+		return err
+	}
+
+	for topic, partitions := range m.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putInt32Array(partitions); err != nil {
+			return err
+		}
+	}
+
+	if err := pe.putBytes(m.UserData); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *ConsumerGroupMemberAssignment) decode(pd packetDecoder) (err error) {
+	if m.Version, err = pd.getInt16(); err != nil {
+		return
+	}
+
+	var topicLen int
+	if topicLen, err = pd.getArrayLength(); err != nil {
+		return
+	}
+
+	m.Topics = make(map[string][]int32, topicLen)
+	for i := 0; i < topicLen; i++ {
+		var topic string
+		if topic, err = pd.getString(); err != nil {
+			return
+		}
+		if m.Topics[topic], err = pd.getInt32Array(); err != nil {
+			return
+		}
+	}
+
+	if m.UserData, err = pd.getBytes(); err != nil {
+		return
+	}
+
+	return nil
+}
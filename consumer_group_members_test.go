@@ -0,0 +1,83 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	groupMemberMetadata = []byte{
+		0, 1, // Version
+		0, 0, 0, 2, // Topic array length
+		0, 3, 'o', 'n', 'e', // Topic one
+		0, 3, 't', 'w', 'o', // Topic two
+		0, 0, 0, 3, 0x01, 0x02, 0x03, // Userdata
+		0, 0, 0, 1, // Owned partitions, topic array length
+		0, 3, 'o', 'n', 'e', // Topic one
+		0, 0, 0, 1, 0, 0, 0, 5, // 5
+	}
+	groupMemberAssignment = []byte{
+		0, 1, // Version
+		0, 0, 0, 1, // Topic array length
+		0, 3, 'o', 'n', 'e', // Topic one
+		0, 0, 0, 3, // Topic one, partition array length
+		0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 4, // 0, 2, 4
+		0, 0, 0, 3, 0x01, 0x02, 0x03, // Userdata
+	}
+)
+
+func TestConsumerGroupMemberMetadata(t *testing.T) {
+	meta := &ConsumerGroupMemberMetadata{
+		Version:  1,
+		Topics:   []string{"one", "two"},
+		UserData: []byte{0x01, 0x02, 0x03},
+
+		OwnedPartitions: map[string][]int32{"one": {5}},
+	}
+
+	testEncodable(t, "", meta, groupMemberMetadata)
+
+	decoded := new(ConsumerGroupMemberMetadata)
+	testDecodable(t, "", decoded, groupMemberMetadata)
+	if !reflect.DeepEqual(meta, decoded) {
+		t.Errorf("Decoded metadata does not match the original\nwant %#v\ngot  %#v", meta, decoded)
+	}
+}
+
+func TestConsumerGroupMemberAssignment(t *testing.T) {
+	amt := &ConsumerGroupMemberAssignment{
+		Version: 1,
+		Topics: map[string][]int32{
+			"one": {0, 2, 4},
+		},
+		UserData: []byte{0x01, 0x02, 0x03},
+	}
+
+	testEncodable(t, "", amt, groupMemberAssignment)
+
+	decoded := new(ConsumerGroupMemberAssignment)
+	testDecodable(t, "", decoded, groupMemberAssignment)
+	if !reflect.DeepEqual(amt, decoded) {
+		t.Errorf("Decoded assignment does not match the original\nwant %#v\ngot  %#v", amt, decoded)
+	}
+}
+
+func TestGroupMemberDescriptionDecodesConsumerProtocol(t *testing.T) {
+	description := &GroupMemberDescription{MemberMetadata: groupMemberMetadata, MemberAssignment: groupMemberAssignment}
+
+	metadata, err := description.GetMemberMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata.Topics) != 2 || metadata.Topics[1] != "two" {
+		t.Error("Unexpected member metadata", metadata)
+	}
+
+	assignment, err := description.GetMemberAssignment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partitions := assignment.Topics["one"]; len(partitions) != 3 || partitions[2] != 4 {
+		t.Error("Unexpected member assignment", assignment)
+	}
+}
@@ -0,0 +1,222 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newConsumerGroupConfig() *Config {
+	config := newMockClusterConfig()
+	config.Version = V0_9_0_0
+	config.Consumer.MaxWaitTime = 50 * time.Millisecond
+	config.Consumer.Offsets.Initial = OffsetOldest
+	config.Consumer.Offsets.CommitInterval = 10 * time.Millisecond
+	config.Consumer.Group.Session.Timeout = 2 * time.Second
+	config.Consumer.Group.Heartbeat.Interval = 20 * time.Millisecond
+	config.Consumer.Group.Rebalance.Retry.Backoff = 10 * time.Millisecond
+	config.Consumer.Group.Return.Notifications = true
+	return config
+}
+
+func expectConsumerGroupNotification(t *testing.T, cg ConsumerGroup, current map[string][]int32) *ConsumerGroupNotification {
+	for {
+		select {
+		case n := <-cg.Notifications():
+			if n.Type != RebalanceOK {
+				continue
+			}
+			if !reflect.DeepEqual(n.Current, current) {
+				t.Fatal("Expected the member to claim", current, "got", n.Current)
+			}
+			return n
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for the member to claim", current)
+		}
+	}
+}
+
+func expectConsumerGroupClaim(t *testing.T, cg ConsumerGroup) ConsumerGroupClaim {
+	select {
+	case claim := <-cg.Claims():
+		return claim
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for a claim")
+	}
+	return nil
+}
+
+func expectConsumerGroupMessage(t *testing.T, claim ConsumerGroupClaim, offset int64) *ConsumerMessage {
+	select {
+	case msg := <-claim.Messages():
+		if msg.Offset != offset {
+			t.Fatalf("Expected the message at offset %d of partition %d, got %d", offset, claim.Partition(), msg.Offset)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for the message at offset %d of partition %d", offset, claim.Partition())
+	}
+	return nil
+}
+
+func TestConsumerGroupResumesFromCommittedOffsets(t *testing.T) {
+	cluster := NewMockCluster(t, 3)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 2)
+	for i := 0; i < 3; i++ {
+		cluster.AddMessage("my_topic", 0, nil, StringEncoder("value"))
+		cluster.AddMessage("my_topic", 1, nil, StringEncoder("value"))
+	}
+
+	cg, err := NewConsumerGroup(cluster.Addrs(), "my_group", []string{"my_topic"}, newConsumerGroupConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := expectConsumerGroupNotification(t, cg, map[string][]int32{"my_topic": {0, 1}})
+	if n.MemberID == "" || !reflect.DeepEqual(n.Claimed, n.Current) || len(n.Released) != 0 {
+		t.Error("Expected the first generation to claim every partition, got", n)
+	}
+	for partition := int32(0); partition < 2; partition++ {
+		claim := expectConsumerGroupClaim(t, cg)
+		if claim.Topic() != "my_topic" || claim.Partition() != partition {
+			t.Fatal("Expected the claims in order of partition, got", claim.Topic(), claim.Partition())
+		}
+		for offset := int64(0); offset < 3; offset++ {
+			expectConsumerGroupMessage(t, claim, offset)
+		}
+		claim.MarkOffset(int64(partition), "")
+	}
+	safeClose(t, cg)
+
+	coordinator := openMockClusterCoordinator(t, cluster, "my_group")
+	defer safeClose(t, coordinator)
+	description, err := coordinator.DescribeGroups(&DescribeGroupsRequest{Groups: []string{"my_group"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group := description.Groups[0]; group.State != "Empty" {
+		t.Error("Expected the member to leave the group, got", group.State)
+	}
+
+	cg, err = NewConsumerGroup(cluster.Addrs(), "my_group", []string{"my_topic"}, newConsumerGroupConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, cg)
+	expectConsumerGroupNotification(t, cg, map[string][]int32{"my_topic": {0, 1}})
+	for partition := int32(0); partition < 2; partition++ {
+		claim := expectConsumerGroupClaim(t, cg)
+		expectConsumerGroupMessage(t, claim, int64(partition)+1)
+	}
+}
+
+func TestConsumerGroupRebalancesMembers(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 4)
+
+	first, err := NewConsumerGroup(cluster.Addrs(), "my_group", []string{"my_topic"}, newConsumerGroupConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, first)
+	expectConsumerGroupNotification(t, first, map[string][]int32{"my_topic": {0, 1, 2, 3}})
+	var claims []ConsumerGroupClaim
+	for i := 0; i < 4; i++ {
+		claims = append(claims, expectConsumerGroupClaim(t, first))
+	}
+
+	second, err := NewConsumerGroup(cluster.Addrs(), "my_group", []string{"my_topic"}, newConsumerGroupConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := expectConsumerGroupNotification(t, first, map[string][]int32{"my_topic": {0, 1}})
+	if len(n.Claimed) != 0 || !reflect.DeepEqual(n.Released, map[string][]int32{"my_topic": {2, 3}}) {
+		t.Error("Expected the first member to release half the partitions, got", n)
+	}
+	expectConsumerGroupNotification(t, second, map[string][]int32{"my_topic": {2, 3}})
+	for _, claim := range claims {
+		for _ = range claim.Messages() {
+		}
+	}
+
+	cluster.AddMessage("my_topic", 3, nil, StringEncoder("value"))
+	claim := expectConsumerGroupClaim(t, second)
+	if claim.Partition() != 2 {
+		t.Fatal("Expected the second member to claim partition 2 first, got", claim.Partition())
+	}
+	claim = expectConsumerGroupClaim(t, second)
+	expectConsumerGroupMessage(t, claim, 0)
+
+	safeClose(t, second)
+	expectConsumerGroupNotification(t, first, map[string][]int32{"my_topic": {0, 1, 2, 3}})
+}
+
+func TestConsumerGroupRequiresVersion(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+
+	if _, err := NewConsumerGroup(cluster.Addrs(), "my_group", []string{"my_topic"}, newMockClusterConfig()); err == nil {
+		t.Error("Expected a consumer group to be refused on the default Version")
+	}
+}
+
+func TestConsumerGroupRebalancesCooperatively(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 4)
+
+	config := newConsumerGroupConfig()
+	config.Consumer.Group.Rebalance.Strategy = BalanceStrategyCooperativeSticky
+	first, err := NewConsumerGroup(cluster.Addrs(), "my_group", []string{"my_topic"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, first)
+	expectConsumerGroupNotification(t, first, map[string][]int32{"my_topic": {0, 1, 2, 3}})
+	var claims []ConsumerGroupClaim
+	for i := 0; i < 4; i++ {
+		claims = append(claims, expectConsumerGroupClaim(t, first))
+	}
+
+	second, err := NewConsumerGroup(cluster.Addrs(), "my_group", []string{"my_topic"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, second)
+	n := expectConsumerGroupNotification(t, first, map[string][]int32{"my_topic": {0, 1}})
+	if len(n.Claimed) != 0 || !reflect.DeepEqual(n.Released, map[string][]int32{"my_topic": {2, 3}}) {
+		t.Error("Expected the first member to release half the partitions, got", n)
+	}
+	for _, claim := range claims[2:] {
+		for _ = range claim.Messages() {
+		}
+	}
+
+	// the second member is assigned the released partitions in the rebalance
+	// the first member starts once it has released them
+	for current := map[string][]int32{}; !reflect.DeepEqual(current, map[string][]int32{"my_topic": {2, 3}}); {
+		select {
+		case n := <-second.Notifications():
+			if n.Type == RebalanceOK {
+				current = n.Current
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for the second member to claim the released partitions, got", current)
+		}
+	}
+	for partition := int32(2); partition < 4; partition++ {
+		if claim := expectConsumerGroupClaim(t, second); claim.Partition() != partition {
+			t.Fatal("Expected the second member to claim partition", partition, "got", claim.Partition())
+		}
+	}
+
+	// the first member kept consuming the partitions it was assigned again
+	cluster.AddMessage("my_topic", 0, nil, StringEncoder("value"))
+	expectConsumerGroupMessage(t, claims[0], 0)
+	select {
+	case claim := <-first.Claims():
+		t.Error("Expected the first member not to claim its partitions again, got", claim.Partition())
+	default:
+	}
+}
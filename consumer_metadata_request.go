@@ -1,16 +1,50 @@
 package sarama
 
+// CoordinatorType is the type of coordinator a ConsumerMetadataRequest looks
+// for.
+type CoordinatorType int8
+
+const (
+	// CoordinatorGroup is the coordinator of a consumer group.
+	CoordinatorGroup CoordinatorType = iota
+	// CoordinatorTransaction is the coordinator of the transactions of a
+	// transactional ID, which requires version 1.
+	CoordinatorTransaction
+)
+
 type ConsumerMetadataRequest struct {
-	ConsumerGroup string
+	// Version can be 0, or 1 for Kafka 0.11 and later, which can look for the
+	// coordinator of a transactional ID, and whose response includes the time
+	// the request was throttled by quotas and the message of its error.
+	Version int16
+	// ConsumerGroup is the consumer group, or the transactional ID, to find
+	// the coordinator of.
+	ConsumerGroup   string
+	CoordinatorType CoordinatorType // v1 or later
 }
 
 func (r *ConsumerMetadataRequest) encode(pe packetEncoder) error {
-	return pe.putString(r.ConsumerGroup)
+	if err := pe.putString(r.ConsumerGroup); err != nil {
+		return err
+	}
+	if r.Version >= 1 {
+		pe.putInt8(int8(r.CoordinatorType))
+	}
+	return nil
 }
 
 func (r *ConsumerMetadataRequest) decode(pd packetDecoder) (err error) {
-	r.ConsumerGroup, err = pd.getString()
-	return err
+	if r.ConsumerGroup, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.Version >= 1 {
+		coordinatorType, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		r.CoordinatorType = CoordinatorType(coordinatorType)
+	}
+	return nil
 }
 
 func (r *ConsumerMetadataRequest) key() int16 {
@@ -18,5 +52,16 @@ func (r *ConsumerMetadataRequest) key() int16 {
 }
 
 func (r *ConsumerMetadataRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ConsumerMetadataRequest) minVersion() int16 {
+	if r.CoordinatorType != CoordinatorGroup {
+		return 1
+	}
 	return 0
 }
+
+func (r *ConsumerMetadataRequest) setVersion(version int16) {
+	r.Version = version
+}
@@ -8,6 +8,10 @@ var (
 
 	consumerMetadataRequestString = []byte{
 		0x00, 0x06, 'f', 'o', 'o', 'b', 'a', 'r'}
+
+	consumerMetadataRequestTransaction = []byte{
+		0x00, 0x03, 't', 'x', 'n',
+		0x01} // CoordinatorTransaction
 )
 
 func TestConsumerMetadataRequest(t *testing.T) {
@@ -17,3 +21,8 @@ func TestConsumerMetadataRequest(t *testing.T) {
 	request.ConsumerGroup = "foobar"
 	testRequest(t, "with string", request, consumerMetadataRequestString)
 }
+
+func TestConsumerMetadataRequestTransaction(t *testing.T) {
+	request := &ConsumerMetadataRequest{Version: 1, ConsumerGroup: "txn", CoordinatorType: CoordinatorTransaction}
+	testRequest(t, "transaction", request, consumerMetadataRequestTransaction)
+}
@@ -3,23 +3,49 @@ package sarama
 import (
 	"net"
 	"strconv"
+	"time"
 )
 
 type ConsumerMetadataResponse struct {
-	Err             KError
+	// Version must be set to the version of the request before decoding.
+	Version int16
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota, from version 1.
+	ThrottleTime time.Duration
+	Err          KError
+	// ErrMsg explains the error, from version 1, if the broker does.
+	ErrMsg          *string
 	Coordinator     *Broker
 	CoordinatorID   int32  // deprecated: use Coordinator.ID()
 	CoordinatorHost string // deprecated: use Coordinator.Addr()
 	CoordinatorPort int32  // deprecated: use Coordinator.Addr()
 }
 
+func (r *ConsumerMetadataResponse) setVersion(version int16) {
+	r.Version = version
+}
+
 func (r *ConsumerMetadataResponse) decode(pd packetDecoder) (err error) {
+	if r.Version >= 1 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		r.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
 	}
 	r.Err = KError(tmp)
 
+	if r.Version >= 1 {
+		if r.ErrMsg, err = getNullableString(pd); err != nil {
+			return err
+		}
+	}
+
 	coordinator := new(Broker)
 	if err := coordinator.decode(pd); err != nil {
 		return err
@@ -47,7 +73,15 @@ func (r *ConsumerMetadataResponse) decode(pd packetDecoder) (err error) {
 }
 
 func (r *ConsumerMetadataResponse) encode(pe packetEncoder) error {
+	if r.Version >= 1 {
+		pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	}
 	pe.putInt16(int16(r.Err))
+	if r.Version >= 1 {
+		if err := putNullableString(pe, r.ErrMsg); err != nil {
+			return err
+		}
+	}
 	if r.Coordinator != nil {
 		host, portstr, err := net.SplitHostPort(r.Coordinator.Addr())
 		if err != nil {
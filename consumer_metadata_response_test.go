@@ -1,6 +1,10 @@
 package sarama
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+	"time"
+)
 
 var (
 	consumerMetadataResponseError = []byte{
@@ -14,6 +18,14 @@ var (
 		0x00, 0x00, 0x00, 0xAB,
 		0x00, 0x03, 'f', 'o', 'o',
 		0x00, 0x00, 0xCC, 0xDD}
+
+	consumerMetadataResponseV1 = []byte{
+		0x00, 0x00, 0x00, 0x64, // ThrottleTime
+		0x00, 0x00,
+		0xFF, 0xFF, // no ErrMsg
+		0x00, 0x00, 0x00, 0xAB,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x00, 0xCC, 0xDD}
 )
 
 func TestConsumerMetadataResponseError(t *testing.T) {
@@ -33,3 +45,23 @@ func TestConsumerMetadataResponseSuccess(t *testing.T) {
 	}
 	testResponse(t, "success", &response, consumerMetadataResponseSuccess)
 }
+
+func TestConsumerMetadataResponseV1(t *testing.T) {
+	broker := NewBroker("foo:52445")
+	broker.id = 0xAB
+	response := &ConsumerMetadataResponse{
+		Version:         1,
+		ThrottleTime:    100 * time.Millisecond,
+		Coordinator:     broker,
+		CoordinatorID:   0xAB,
+		CoordinatorHost: "foo",
+		CoordinatorPort: 0xCCDD,
+	}
+	testEncodable(t, "v1", response, consumerMetadataResponseV1)
+
+	decoded := &ConsumerMetadataResponse{Version: 1}
+	testDecodable(t, "v1", decoded, consumerMetadataResponseV1)
+	if !reflect.DeepEqual(decoded, response) {
+		t.Errorf("Decoded response does not match the encoded one\nencoded: %#v\ndecoded: %#v", response, decoded)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 var testMsg = StringEncoder("Foo")
@@ -15,21 +17,21 @@ var testMsg = StringEncoder("Foo")
 // that offset.
 func TestConsumerOffsetManual(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 
-	mockFetchResponse := newMockFetchResponse(t, 1)
+	MockFetchResponse := NewMockFetchResponse(t, 1)
 	for i := 0; i < 10; i++ {
-		mockFetchResponse.SetMessage("my_topic", 0, int64(i+1234), testMsg)
+		MockFetchResponse.SetMessage("my_topic", 0, int64(i+1234), testMsg)
 	}
 
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetOldest, 0).
 			SetOffset("my_topic", 0, OffsetNewest, 2345),
-		"FetchRequest": mockFetchResponse,
+		"FetchRequest": MockFetchResponse,
 	})
 
 	// When
@@ -63,15 +65,15 @@ func TestConsumerOffsetManual(t *testing.T) {
 // newest in its metadata response.
 func TestConsumerOffsetNewest(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetNewest, 10).
 			SetOffset("my_topic", 0, OffsetOldest, 7),
-		"FetchRequest": newMockFetchResponse(t, 1).
+		"FetchRequest": NewMockFetchResponse(t, 1).
 			SetMessage("my_topic", 0, 9, testMsg).
 			SetMessage("my_topic", 0, 10, testMsg).
 			SetMessage("my_topic", 0, 11, testMsg).
@@ -103,15 +105,15 @@ func TestConsumerOffsetNewest(t *testing.T) {
 // It is possible to close a partition consumer and create the same anew.
 func TestConsumerRecreate(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetOldest, 0).
 			SetOffset("my_topic", 0, OffsetNewest, 1000),
-		"FetchRequest": newMockFetchResponse(t, 1).
+		"FetchRequest": NewMockFetchResponse(t, 1).
 			SetMessage("my_topic", 0, 10, testMsg),
 	})
 
@@ -144,15 +146,15 @@ func TestConsumerRecreate(t *testing.T) {
 // An attempt to consume the same partition twice should fail.
 func TestConsumerDuplicate(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetOldest, 0).
 			SetOffset("my_topic", 0, OffsetNewest, 1000),
-		"FetchRequest": newMockFetchResponse(t, 1),
+		"FetchRequest": NewMockFetchResponse(t, 1),
 	})
 
 	config := NewConfig()
@@ -184,19 +186,19 @@ func TestConsumerDuplicate(t *testing.T) {
 // specified by `Config.Consumer.Retry.Backoff`.
 func TestConsumerLeaderRefreshError(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 100)
+	broker0 := NewMockBroker(t, 100)
 
 	// Stage 1: my_topic/0 served by broker0
 	Logger.Printf("    STAGE 1")
 
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetOldest, 123).
 			SetOffset("my_topic", 0, OffsetNewest, 1000),
-		"FetchRequest": newMockFetchResponse(t, 1).
+		"FetchRequest": NewMockFetchResponse(t, 1).
 			SetMessage("my_topic", 0, 123, testMsg),
 	})
 
@@ -225,7 +227,7 @@ func TestConsumerLeaderRefreshError(t *testing.T) {
 	fetchResponse2.AddError("my_topic", 0, ErrNotLeaderForPartition)
 
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"FetchRequest": newMockWrapper(fetchResponse2),
+		"FetchRequest": NewMockWrapper(fetchResponse2),
 	})
 
 	if consErr := <-pc.Errors(); consErr.Err != ErrOutOfBrokers {
@@ -237,14 +239,14 @@ func TestConsumerLeaderRefreshError(t *testing.T) {
 
 	Logger.Printf("    STAGE 3")
 
-	broker1 := newMockBroker(t, 101)
+	broker1 := NewMockBroker(t, 101)
 
 	broker1.SetHandlerByMap(map[string]MockResponse{
-		"FetchRequest": newMockFetchResponse(t, 1).
+		"FetchRequest": NewMockFetchResponse(t, 1).
 			SetMessage("my_topic", 0, 124, testMsg),
 	})
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetBroker(broker1.Addr(), broker1.BrokerID()).
 			SetLeader("my_topic", 0, broker1.BrokerID()),
@@ -260,9 +262,9 @@ func TestConsumerLeaderRefreshError(t *testing.T) {
 
 func TestConsumerInvalidTopic(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 100)
+	broker0 := NewMockBroker(t, 100)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()),
 	})
 
@@ -287,15 +289,15 @@ func TestConsumerInvalidTopic(t *testing.T) {
 // the moment is closed.
 func TestConsumerClosePartitionWithoutLeader(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 100)
+	broker0 := NewMockBroker(t, 100)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetOldest, 123).
 			SetOffset("my_topic", 0, OffsetNewest, 1000),
-		"FetchRequest": newMockFetchResponse(t, 1).
+		"FetchRequest": NewMockFetchResponse(t, 1).
 			SetMessage("my_topic", 0, 123, testMsg),
 	})
 
@@ -322,7 +324,7 @@ func TestConsumerClosePartitionWithoutLeader(t *testing.T) {
 	fetchResponse2.AddError("my_topic", 0, ErrNotLeaderForPartition)
 
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"FetchRequest": newMockWrapper(fetchResponse2),
+		"FetchRequest": NewMockWrapper(fetchResponse2),
 	})
 
 	// When
@@ -341,17 +343,17 @@ func TestConsumerClosePartitionWithoutLeader(t *testing.T) {
 // immediately closing its output channels.
 func TestConsumerShutsDownOutOfRange(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 	fetchResponse := new(FetchResponse)
 	fetchResponse.AddError("my_topic", 0, ErrOffsetOutOfRange)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetNewest, 1234).
 			SetOffset("my_topic", 0, OffsetOldest, 7),
-		"FetchRequest": newMockWrapper(fetchResponse),
+		"FetchRequest": NewMockWrapper(fetchResponse),
 	})
 
 	master, err := NewConsumer([]string{broker0.Addr()}, nil)
@@ -379,7 +381,7 @@ func TestConsumerShutsDownOutOfRange(t *testing.T) {
 // requested, then such messages are ignored.
 func TestConsumerExtraOffsets(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 	fetchResponse1 := &FetchResponse{}
 	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 1)
 	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 2)
@@ -388,13 +390,13 @@ func TestConsumerExtraOffsets(t *testing.T) {
 	fetchResponse2 := &FetchResponse{}
 	fetchResponse2.AddError("my_topic", 0, ErrNoError)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetNewest, 1234).
 			SetOffset("my_topic", 0, OffsetOldest, 0),
-		"FetchRequest": newMockSequence(fetchResponse1, fetchResponse2),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
 	})
 
 	master, err := NewConsumer([]string{broker0.Addr()}, nil)
@@ -422,7 +424,7 @@ func TestConsumerExtraOffsets(t *testing.T) {
 // strictly increasing!).
 func TestConsumerNonSequentialOffsets(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 	fetchResponse1 := &FetchResponse{}
 	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 5)
 	fetchResponse1.AddMessage("my_topic", 0, nil, testMsg, 7)
@@ -430,13 +432,13 @@ func TestConsumerNonSequentialOffsets(t *testing.T) {
 	fetchResponse2 := &FetchResponse{}
 	fetchResponse2.AddError("my_topic", 0, ErrNoError)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetNewest, 1234).
 			SetOffset("my_topic", 0, OffsetOldest, 0),
-		"FetchRequest": newMockSequence(fetchResponse1, fetchResponse2),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
 	})
 
 	master, err := NewConsumer([]string{broker0.Addr()}, nil)
@@ -461,34 +463,162 @@ func TestConsumerNonSequentialOffsets(t *testing.T) {
 	broker0.Close()
 }
 
+// Records of record batches are consumed with their headers and timestamps,
+// and control batches are skipped.
+func TestConsumerRecordBatches(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	fetchResponse1 := &FetchResponse{Version: 4}
+	fetchResponse1.AddRecord("my_topic", 0, nil, testMsg, 1)
+	fetchResponse1.AddRecord("my_topic", 0, nil, testMsg, 2)
+	fetchResponse1.AddRecord("my_topic", 0, nil, testMsg, 3)
+	block := fetchResponse1.GetBlock("my_topic", 0)
+	batch := block.RecordBatches[0]
+	batch.FirstTimestamp, batch.MaxTimestamp = time.Unix(1000, 0), time.Unix(1000, 0).Add(2*time.Second)
+	batch.Records[1].TimestampDelta = 2 * time.Second
+	batch.Records[1].Headers = []*RecordHeader{{Key: []byte("header"), Value: []byte("value")}}
+	control := newRecordBatch(CompressionNone)
+	control.FirstOffset, control.Control = 4, true
+	control.addRecord(&Record{}, time.Time{})
+	last := newRecordBatch(CompressionNone)
+	last.FirstOffset = 5
+	block.RecordBatches = append(block.RecordBatches, control, last)
+	fetchResponse1.AddRecord("my_topic", 0, nil, testMsg, 5)
+	fetchResponse2 := &FetchResponse{Version: 4}
+	fetchResponse2.AddError("my_topic", 0, ErrNoError)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
+	})
+
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: the record at offset 1 is before the requested offset, and the
+	// control record at offset 4 is not a message.
+	msg := <-consumer.Messages()
+	assertMessageOffset(t, msg, 2)
+	if !msg.Timestamp.Equal(time.Unix(1002, 0)) {
+		t.Error("Expected the timestamp of the record at offset 2, got", msg.Timestamp)
+	}
+	if len(msg.Headers) != 1 || string(msg.Headers[0].Key) != "header" || string(msg.Headers[0].Value) != "value" {
+		t.Error("Expected the headers of the record at offset 2, got", msg.Headers)
+	}
+	assertMessageOffset(t, <-consumer.Messages(), 3)
+	assertMessageOffset(t, <-consumer.Messages(), 5)
+
+	// the record at offset 5 has no timestamp, and the latency of the one at
+	// offset 3 was measured a little before now
+	latency := config.MetricRegistry.Get("consumer-end-to-end-latency-in-ms-for-topic-my_topic-partition-0").(metrics.Histogram)
+	if latency.Count() != 2 || latency.Max() < int64(time.Since(time.Unix(1001, 0))/time.Millisecond) {
+		t.Error("Expected the end-to-end latency of the two records with timestamps, got", latency.Count(), latency.Min(), latency.Max())
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+// The messages wrapped in compressed messages of version 1 have offsets relative
+// to the first of them.
+func TestConsumerRelativeOffsets(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	inner := new(MessageSet)
+	for i := 0; i < 3; i++ {
+		msg := &Message{Codec: CompressionNone, Value: []byte("value"), Version: 1, Timestamp: time.Unix(int64(1000+i), 0)}
+		inner.Messages = append(inner.Messages, &MessageBlock{Offset: int64(i), Msg: msg})
+	}
+	value, err := encode(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchResponse1 := &FetchResponse{Version: 2}
+	fetchResponse1.AddError("my_topic", 0, ErrNoError)
+	wrapper := &Message{Codec: CompressionGZIP, Value: value, Version: 1, Timestamp: time.Unix(1002, 0)}
+	fetchResponse1.GetBlock("my_topic", 0).MsgSet.Messages = []*MessageBlock{{Offset: 12, Msg: wrapper}}
+	fetchResponse2 := &FetchResponse{Version: 2}
+	fetchResponse2.AddError("my_topic", 0, ErrNoError)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse1, fetchResponse2),
+	})
+
+	config := NewConfig()
+	config.Version = V0_10_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	for offset := int64(11); offset <= 12; offset++ {
+		msg := <-consumer.Messages()
+		assertMessageOffset(t, msg, offset)
+		if !msg.Timestamp.Equal(time.Unix(990+offset, 0)) {
+			t.Error("Expected the timestamp of the message at offset", offset, "got", msg.Timestamp)
+		}
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
 // If leadership for a partition is changing then consumer resolves the new
 // leader and switches to it.
 func TestConsumerRebalancingMultiplePartitions(t *testing.T) {
 	// initial setup
-	seedBroker := newMockBroker(t, 10)
-	leader0 := newMockBroker(t, 0)
-	leader1 := newMockBroker(t, 1)
+	seedBroker := NewMockBroker(t, 10)
+	leader0 := NewMockBroker(t, 0)
+	leader1 := NewMockBroker(t, 1)
 
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(leader0.Addr(), leader0.BrokerID()).
 			SetBroker(leader1.Addr(), leader1.BrokerID()).
 			SetLeader("my_topic", 0, leader0.BrokerID()).
 			SetLeader("my_topic", 1, leader1.BrokerID()),
 	})
 
-	mockOffsetResponse1 := newMockOffsetResponse(t).
+	mockOffsetResponse1 := NewMockOffsetResponse(t).
 		SetOffset("my_topic", 0, OffsetOldest, 0).
 		SetOffset("my_topic", 0, OffsetNewest, 1000).
 		SetOffset("my_topic", 1, OffsetOldest, 0).
 		SetOffset("my_topic", 1, OffsetNewest, 1000)
 	leader0.SetHandlerByMap(map[string]MockResponse{
 		"OffsetRequest": mockOffsetResponse1,
-		"FetchRequest":  newMockFetchResponse(t, 1),
+		"FetchRequest":  NewMockFetchResponse(t, 1),
 	})
 	leader1.SetHandlerByMap(map[string]MockResponse{
 		"OffsetRequest": mockOffsetResponse1,
-		"FetchRequest":  newMockFetchResponse(t, 1),
+		"FetchRequest":  NewMockFetchResponse(t, 1),
 	})
 
 	// launch test goroutines
@@ -535,12 +665,12 @@ func TestConsumerRebalancingMultiplePartitions(t *testing.T) {
 	//   * my_topic/0 -> leader0 serves 4 messages
 	//   * my_topic/1 -> leader1 serves 0 messages
 
-	mockFetchResponse := newMockFetchResponse(t, 1)
+	MockFetchResponse := NewMockFetchResponse(t, 1)
 	for i := 0; i < 4; i++ {
-		mockFetchResponse.SetMessage("my_topic", 0, int64(i), testMsg)
+		MockFetchResponse.SetMessage("my_topic", 0, int64(i), testMsg)
 	}
 	leader0.SetHandlerByMap(map[string]MockResponse{
-		"FetchRequest": mockFetchResponse,
+		"FetchRequest": MockFetchResponse,
 	})
 
 	time.Sleep(50 * time.Millisecond)
@@ -551,7 +681,7 @@ func TestConsumerRebalancingMultiplePartitions(t *testing.T) {
 
 	// seed broker tells that the new partition 0 leader is leader1
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetLeader("my_topic", 0, leader1.BrokerID()).
 			SetLeader("my_topic", 1, leader1.BrokerID()),
 	})
@@ -560,7 +690,7 @@ func TestConsumerRebalancingMultiplePartitions(t *testing.T) {
 	fetchResponse := new(FetchResponse)
 	fetchResponse.AddError("my_topic", 0, ErrNotLeaderForPartition)
 	leader0.SetHandlerByMap(map[string]MockResponse{
-		"FetchRequest": newMockWrapper(fetchResponse),
+		"FetchRequest": NewMockWrapper(fetchResponse),
 	})
 
 	time.Sleep(50 * time.Millisecond)
@@ -570,7 +700,7 @@ func TestConsumerRebalancingMultiplePartitions(t *testing.T) {
 	//   * my_topic/1 -> leader1 server 8 messages
 
 	// leader1 provides 3 message on partition 0, and 8 messages on partition 1
-	mockFetchResponse2 := newMockFetchResponse(t, 2)
+	mockFetchResponse2 := NewMockFetchResponse(t, 2)
 	for i := 4; i < 7; i++ {
 		mockFetchResponse2.SetMessage("my_topic", 0, int64(i), testMsg)
 	}
@@ -590,24 +720,24 @@ func TestConsumerRebalancingMultiplePartitions(t *testing.T) {
 
 	// metadata assigns 0 to leader1 and 1 to leader0
 	seedBroker.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetLeader("my_topic", 0, leader1.BrokerID()).
 			SetLeader("my_topic", 1, leader0.BrokerID()),
 	})
 
 	// leader1 provides three more messages on partition0, says no longer leader of partition1
-	mockFetchResponse3 := newMockFetchResponse(t, 3).
+	mockFetchResponse3 := NewMockFetchResponse(t, 3).
 		SetMessage("my_topic", 0, int64(7), testMsg).
 		SetMessage("my_topic", 0, int64(8), testMsg).
 		SetMessage("my_topic", 0, int64(9), testMsg)
 	fetchResponse4 := new(FetchResponse)
 	fetchResponse4.AddError("my_topic", 1, ErrNotLeaderForPartition)
 	leader1.SetHandlerByMap(map[string]MockResponse{
-		"FetchRequest": newMockSequence(mockFetchResponse3, fetchResponse4),
+		"FetchRequest": NewMockSequence(mockFetchResponse3, fetchResponse4),
 	})
 
 	// leader0 provides two messages on partition 1
-	mockFetchResponse4 := newMockFetchResponse(t, 2)
+	mockFetchResponse4 := NewMockFetchResponse(t, 2)
 	for i := 8; i < 10; i++ {
 		mockFetchResponse4.SetMessage("my_topic", 1, int64(i), testMsg)
 	}
@@ -627,18 +757,18 @@ func TestConsumerRebalancingMultiplePartitions(t *testing.T) {
 // read messages by the other consumer.
 func TestConsumerInterleavedClose(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()).
 			SetLeader("my_topic", 1, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetOldest, 1000).
 			SetOffset("my_topic", 0, OffsetNewest, 1100).
 			SetOffset("my_topic", 1, OffsetOldest, 2000).
 			SetOffset("my_topic", 1, OffsetNewest, 2100),
-		"FetchRequest": newMockFetchResponse(t, 1).
+		"FetchRequest": NewMockFetchResponse(t, 1).
 			SetMessage("my_topic", 0, 1000, testMsg).
 			SetMessage("my_topic", 0, 1001, testMsg).
 			SetMessage("my_topic", 0, 1002, testMsg).
@@ -674,36 +804,36 @@ func TestConsumerInterleavedClose(t *testing.T) {
 }
 
 func TestConsumerBounceWithReferenceOpen(t *testing.T) {
-	broker0 := newMockBroker(t, 0)
+	broker0 := NewMockBroker(t, 0)
 	broker0Addr := broker0.Addr()
-	broker1 := newMockBroker(t, 1)
+	broker1 := NewMockBroker(t, 1)
 
-	mockMetadataResponse := newMockMetadataResponse(t).
+	MockMetadataResponse := NewMockMetadataResponse(t).
 		SetBroker(broker0.Addr(), broker0.BrokerID()).
 		SetBroker(broker1.Addr(), broker1.BrokerID()).
 		SetLeader("my_topic", 0, broker0.BrokerID()).
 		SetLeader("my_topic", 1, broker1.BrokerID())
 
-	mockOffsetResponse := newMockOffsetResponse(t).
+	MockOffsetResponse := NewMockOffsetResponse(t).
 		SetOffset("my_topic", 0, OffsetOldest, 1000).
 		SetOffset("my_topic", 0, OffsetNewest, 1100).
 		SetOffset("my_topic", 1, OffsetOldest, 2000).
 		SetOffset("my_topic", 1, OffsetNewest, 2100)
 
-	mockFetchResponse := newMockFetchResponse(t, 1)
+	MockFetchResponse := NewMockFetchResponse(t, 1)
 	for i := 0; i < 10; i++ {
-		mockFetchResponse.SetMessage("my_topic", 0, int64(1000+i), testMsg)
-		mockFetchResponse.SetMessage("my_topic", 1, int64(2000+i), testMsg)
+		MockFetchResponse.SetMessage("my_topic", 0, int64(1000+i), testMsg)
+		MockFetchResponse.SetMessage("my_topic", 1, int64(2000+i), testMsg)
 	}
 
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"OffsetRequest": mockOffsetResponse,
-		"FetchRequest":  mockFetchResponse,
+		"OffsetRequest": MockOffsetResponse,
+		"FetchRequest":  MockFetchResponse,
 	})
 	broker1.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": mockMetadataResponse,
-		"OffsetRequest":   mockOffsetResponse,
-		"FetchRequest":    mockFetchResponse,
+		"MetadataRequest": MockMetadataResponse,
+		"OffsetRequest":   MockOffsetResponse,
+		"FetchRequest":    MockFetchResponse,
 	})
 
 	config := NewConfig()
@@ -745,9 +875,9 @@ func TestConsumerBounceWithReferenceOpen(t *testing.T) {
 	}
 
 	// Bring broker0 back to service.
-	broker0 = newMockBrokerAddr(t, 0, broker0Addr)
+	broker0 = NewMockBrokerAddr(t, 0, broker0Addr)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"FetchRequest": mockFetchResponse,
+		"FetchRequest": MockFetchResponse,
 	})
 
 	// Read the rest of messages from both partitions.
@@ -773,12 +903,12 @@ func TestConsumerBounceWithReferenceOpen(t *testing.T) {
 
 func TestConsumerOffsetOutOfRange(t *testing.T) {
 	// Given
-	broker0 := newMockBroker(t, 2)
+	broker0 := NewMockBroker(t, 2)
 	broker0.SetHandlerByMap(map[string]MockResponse{
-		"MetadataRequest": newMockMetadataResponse(t).
+		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
-		"OffsetRequest": newMockOffsetResponse(t).
+		"OffsetRequest": NewMockOffsetResponse(t).
 			SetOffset("my_topic", 0, OffsetNewest, 1234).
 			SetOffset("my_topic", 0, OffsetOldest, 2345),
 	})
@@ -852,3 +982,445 @@ ConsumerLoop:
 
 	log.Printf("Consumed: %d\n", consumed)
 }
+
+func TestConsumerMessageClone(t *testing.T) {
+	shared := []byte("keyvaluehkeyhvalue")
+	msg := &ConsumerMessage{Key: shared[:3], Value: shared[3:8], Topic: "my_topic", Partition: 1, Offset: 5,
+		Headers: []*RecordHeader{{Key: shared[8:12], Value: shared[12:]}, {Key: shared[8:12]}}}
+
+	clone := msg.Clone()
+	shared[0], shared[3], shared[8], shared[12] = 'X', 'X', 'X', 'X'
+
+	if string(clone.Key) != "key" || string(clone.Value) != "value" {
+		t.Error("Clone shares memory with the original message:", string(clone.Key), string(clone.Value))
+	}
+	if len(clone.Headers) != 2 || clone.Headers[0] == msg.Headers[0] ||
+		string(clone.Headers[0].Key) != "hkey" || string(clone.Headers[0].Value) != "hvalue" {
+		t.Error("Clone shares the headers with the original message:", clone.Headers)
+	} else if string(clone.Headers[1].Key) != "hkey" || clone.Headers[1].Value != nil {
+		t.Error("Clone did not copy a header without a value:", clone.Headers[1])
+	}
+	if clone.Topic != "my_topic" || clone.Partition != 1 || clone.Offset != 5 {
+		t.Error("Clone did not copy the message metadata")
+	}
+
+	if null := (&ConsumerMessage{}).Clone(); null.Key != nil || null.Value != nil {
+		t.Error("Clone turned a null key or value into an empty one")
+	}
+}
+
+func TestConsumerMetrics(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	MockFetchResponse := NewMockFetchResponse(t, 1).
+		SetMessage("my_topic", 0, 10, testMsg).
+		SetHighWaterMark("my_topic", 0, 15)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 15),
+		"FetchRequest": MockFetchResponse,
+	})
+
+	config := NewConfig()
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: the lag after the first message is the distance to the high water mark
+	select {
+	case message := <-consumer.Messages():
+		assertMessageOffset(t, message, 10)
+	case err := <-consumer.Errors():
+		t.Fatal(err)
+	}
+
+	lagName := "consumer-lag-for-topic-my_topic-partition-0"
+	if lag := config.MetricRegistry.Get(lagName).(metrics.Gauge); lag.Value() != 4 {
+		t.Error("Expected a lag of 4, got", lag.Value())
+	}
+	if latency := config.MetricRegistry.Get("fetch-latency-in-ms-for-broker-0").(metrics.Histogram); latency.Count() == 0 {
+		t.Error("Expected the fetch latency to be recorded")
+	}
+	latencyName := "consumer-end-to-end-latency-in-ms-for-topic-my_topic-partition-0"
+	if latency := config.MetricRegistry.Get(latencyName).(metrics.Histogram); latency.Count() != 0 {
+		t.Error("Expected no end-to-end latency for messages without timestamps, got", latency.Count())
+	}
+
+	safeClose(t, consumer)
+	if config.MetricRegistry.Get(lagName) != nil || config.MetricRegistry.Get(latencyName) != nil {
+		t.Error("Expected the partition metrics to be unregistered once the partition consumer is closed")
+	}
+
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestConsumerReadCommitted(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	producer, err := NewAsyncProducer(cluster.Addrs(), newTransactionalProducerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProducer(t, producer)
+	produceInTxn := func(messages int) {
+		if err := producer.BeginTxn(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < messages; i++ {
+			producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+		}
+		expectResults(t, producer, messages, 0)
+	}
+
+	// offsets 0 to 2 are committed, 4 and 5 aborted, and 7 ongoing; the
+	// markers take offsets 3 and 6
+	produceInTxn(3)
+	if err := producer.CommitTxn(); err != nil {
+		t.Fatal(err)
+	}
+	produceInTxn(2)
+	if err := producer.AbortTxn(); err != nil {
+		t.Fatal(err)
+	}
+	produceInTxn(1)
+
+	newIsolatedConfig := func(isolation IsolationLevel) *Config {
+		config := newMockClusterConfig()
+		config.Version = V0_11_0_0
+		config.Consumer.MaxWaitTime = 50 * time.Millisecond
+		config.Consumer.IsolationLevel = isolation
+		return config
+	}
+	consumer, err := NewConsumer(cluster.Addrs(), newIsolatedConfig(ReadCommitted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	pc, err := consumer.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, pc)
+
+	for _, offset := range []int64{0, 1, 2} {
+		assertMessageOffset(t, <-pc.Messages(), offset)
+	}
+	select {
+	case msg := <-pc.Messages():
+		t.Fatal("Expected no message of an aborted or ongoing transaction, got offset", msg.Offset)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := producer.CommitTxn(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-pc.Messages():
+		assertMessageOffset(t, msg, 7)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message of the committed transaction")
+	}
+
+	uncommitted, err := NewConsumer(cluster.Addrs(), newIsolatedConfig(ReadUncommitted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, uncommitted)
+	pc, err = uncommitted.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, pc)
+	for _, offset := range []int64{0, 1, 2, 4, 5, 7} {
+		assertMessageOffset(t, <-pc.Messages(), offset)
+	}
+}
+
+func TestConsumerFetchesFromPreferredReadReplica(t *testing.T) {
+	cluster := NewMockCluster(t, 3)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+	cluster.SetFollowers("my_topic", 0, 2, 3)
+	cluster.SetRack(1, "a")
+	cluster.SetRack(2, "b")
+	cluster.SetRack(3, "c")
+	cluster.AddMessage("my_topic", 0, nil, StringEncoder(TestMessage))
+
+	config := newMockClusterConfig()
+	config.Version = V2_3_0_0
+	config.ClientRack = "c"
+	config.Consumer.MaxWaitTime = 50 * time.Millisecond
+	config.Consumer.Retry.Backoff = 10 * time.Millisecond
+	consumer, err := NewConsumer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	pc, err := consumer.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, pc)
+
+	select {
+	case msg := <-pc.Messages():
+		assertMessageOffset(t, msg, 0)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message from the replica in the rack")
+	}
+	fetched := false
+	for _, rr := range cluster.Broker(3).History() {
+		if req, ok := rr.Request.(*FetchRequest); ok {
+			fetched = true
+			if req.Version != 11 || req.RackID != "c" {
+				t.Error("Expected fetches of version 11 with the rack of the client, got", req.Version, req.RackID)
+			}
+		}
+	}
+	if !fetched {
+		t.Error("Expected the consumer to fetch from the replica in its rack")
+	}
+
+	// the replica stops following the partition, and the consumer goes back
+	// to the leader
+	cluster.SetFollowers("my_topic", 0, 2)
+	cluster.AddMessage("my_topic", 0, nil, StringEncoder(TestMessage))
+	select {
+	case msg := <-pc.Messages():
+		assertMessageOffset(t, msg, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message from the leader")
+	}
+}
+
+func TestConsumerFetchSessions(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 2)
+	for i := 0; i < 3; i++ {
+		cluster.AddMessage("my_topic", 0, nil, StringEncoder(TestMessage))
+		cluster.AddMessage("my_topic", 1, nil, StringEncoder(TestMessage))
+	}
+
+	config := newMockClusterConfig()
+	config.Version = V1_1_0_0
+	config.Consumer.MaxWaitTime = 50 * time.Millisecond
+	config.Consumer.Return.Errors = true
+	consumer, err := NewConsumer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	var pcs []PartitionConsumer
+	for partition := int32(0); partition < 2; partition++ {
+		pc, err := consumer.ConsumePartition("my_topic", partition, OffsetOldest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer safeClose(t, pc)
+		pcs = append(pcs, pc)
+	}
+	for _, pc := range pcs {
+		for offset := int64(0); offset < 3; offset++ {
+			assertMessageOffset(t, <-pc.Messages(), offset)
+		}
+	}
+
+	// the broker forgets the session, and the consumer starts a new one
+	cluster.EvictFetchSessions()
+	cluster.AddMessage("my_topic", 1, nil, StringEncoder(TestMessage))
+	select {
+	case msg := <-pcs[1].Messages():
+		assertMessageOffset(t, msg, 3)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message after the session was evicted")
+	}
+	cluster.AddMessage("my_topic", 0, nil, StringEncoder(TestMessage))
+	select {
+	case msg := <-pcs[0].Messages():
+		assertMessageOffset(t, msg, 3)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message of the new session")
+	}
+	for _, pc := range pcs {
+		select {
+		case err := <-pc.Errors():
+			t.Error("Expected the eviction of the session not to fail the partitions, got", err)
+		default:
+		}
+	}
+
+	var incremental, evicted bool
+	for _, rr := range cluster.Broker(1).History() {
+		if req, ok := rr.Request.(*FetchRequest); ok && req.Version == 7 && req.SessionEpoch > 0 {
+			incremental = true
+		}
+		if res, ok := rr.Response.(*FetchResponse); ok && res.Err == ErrFetchSessionIDNotFound {
+			evicted = true
+		}
+	}
+	if !incremental || !evicted {
+		t.Error("Expected incremental fetches, and one of the evicted session, got", incremental, evicted)
+	}
+}
+
+func TestConsumerOffsetTime(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	config := newMockClusterConfig()
+	config.Version = V0_11_0_0
+	config.Consumer.MaxWaitTime = 50 * time.Millisecond
+	producer, err := NewSyncProducer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Truncate(time.Millisecond)
+	for i := 0; i < 3; i++ {
+		msg := &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Timestamp: start.Add(time.Duration(i) * time.Hour)}
+		if _, _, err := producer.SendMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	safeClose(t, producer)
+
+	consumer, err := NewConsumer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	pc, err := consumer.ConsumePartition("my_topic", 0, OffsetTime(start.Add(30*time.Minute)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMessageOffset(t, <-pc.Messages(), 1)
+	safeClose(t, pc)
+
+	pc, err = consumer.ConsumePartition("my_topic", 0, OffsetTime(start.Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMessageOffset(t, <-pc.Messages(), 1)
+	safeClose(t, pc)
+
+	// no message is that recent, so the partition is consumed from the newest
+	pc, err = consumer.ConsumePartition("my_topic", 0, OffsetTime(start.Add(3*time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, pc)
+	cluster.AddMessage("my_topic", 0, nil, StringEncoder(TestMessage))
+	select {
+	case msg := <-pc.Messages():
+		assertMessageOffset(t, msg, 3)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the next message")
+	}
+}
+
+func TestConsumerOffsetTimeRequiresVersion(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	consumer, err := NewConsumer(cluster.Addrs(), newMockClusterConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	if _, err := consumer.ConsumePartition("my_topic", 0, OffsetTime(time.Now())); err == nil {
+		t.Error("Expected consuming from an OffsetTime to be refused on the default Version")
+	}
+}
+
+func TestConsumerPauseResume(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 2)
+
+	config := newMockClusterConfig()
+	config.Version = V1_1_0_0
+	config.Consumer.MaxWaitTime = 50 * time.Millisecond
+	config.Consumer.Return.Errors = true
+	consumer, err := NewConsumer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+
+	// the pause holds for the partitions consumed after it
+	consumer.Pause(map[string][]int32{"my_topic": {0}})
+	var pcs []PartitionConsumer
+	for partition := int32(0); partition < 2; partition++ {
+		pc, err := consumer.ConsumePartition("my_topic", partition, OffsetOldest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer safeClose(t, pc)
+		pcs = append(pcs, pc)
+	}
+	cluster.AddMessage("my_topic", 0, nil, StringEncoder(TestMessage))
+	cluster.AddMessage("my_topic", 1, nil, StringEncoder(TestMessage))
+	select {
+	case msg := <-pcs[1].Messages():
+		assertMessageOffset(t, msg, 0)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message of the partition that isn't paused")
+	}
+	select {
+	case msg := <-pcs[0].Messages():
+		t.Fatal("Expected no message from the paused partition, got", msg.Offset)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	consumer.Resume(map[string][]int32{"my_topic": {0}})
+	select {
+	case msg := <-pcs[0].Messages():
+		assertMessageOffset(t, msg, 0)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message of the resumed partition")
+	}
+
+	consumer.PauseAll()
+	// let the fetch in flight when the partitions were paused return
+	time.Sleep(2 * config.Consumer.MaxWaitTime)
+	cluster.AddMessage("my_topic", 1, nil, StringEncoder(TestMessage))
+	select {
+	case msg := <-pcs[1].Messages():
+		t.Fatal("Expected no message while every partition is paused, got", msg.Offset)
+	case <-time.After(200 * time.Millisecond):
+	}
+	consumer.ResumeAll()
+	select {
+	case msg := <-pcs[1].Messages():
+		assertMessageOffset(t, msg, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the message after resuming every partition")
+	}
+
+	for _, pc := range pcs {
+		select {
+		case err := <-pc.Errors():
+			t.Error("Expected pausing not to fail the partitions, got", err)
+		default:
+		}
+	}
+}
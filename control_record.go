@@ -0,0 +1,34 @@
+package sarama
+
+import "encoding/binary"
+
+// ControlRecordType is the type of the control record a transaction
+// coordinator writes to each partition of a transaction to end it.
+type ControlRecordType int16
+
+const (
+	// ControlRecordAbort marks the end of an aborted transaction.
+	ControlRecordAbort ControlRecordType = 0
+	// ControlRecordCommit marks the end of a committed transaction.
+	ControlRecordCommit ControlRecordType = 1
+)
+
+// controlRecordType returns the type of a control record, which its key holds
+// after its version.
+func controlRecordType(record *Record) (ControlRecordType, error) {
+	pd := &realDecoder{raw: record.Key}
+	if _, err := pd.getInt16(); err != nil {
+		return 0, err
+	}
+	kind, err := pd.getInt16()
+	return ControlRecordType(kind), err
+}
+
+// newControlRecord returns the control record of the given type, of version 0,
+// from a coordinator of epoch 0.
+func newControlRecord(kind ControlRecordType) *Record {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint16(key[2:], uint16(kind))
+	// the value holds the version and the epoch of the coordinator
+	return &Record{Key: key, Value: make([]byte, 6)}
+}
@@ -0,0 +1,19 @@
+package sarama
+
+import "testing"
+
+func TestControlRecordType(t *testing.T) {
+	for _, kind := range []ControlRecordType{ControlRecordAbort, ControlRecordCommit} {
+		decoded, err := controlRecordType(newControlRecord(kind))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != kind {
+			t.Error("Expected a control record of type", kind, "got", decoded)
+		}
+	}
+
+	if _, err := controlRecordType(&Record{Key: []byte{0, 0}}); err != ErrInsufficientData {
+		t.Error("Expected a truncated control record key to fail, got", err)
+	}
+}
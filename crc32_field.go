@@ -6,9 +6,27 @@ import (
 	"github.com/klauspost/crc32"
 )
 
+type crcPolynomial int8
+
+const (
+	// crcIEEE is used by the legacy message format.
+	crcIEEE crcPolynomial = iota
+	// crcCastagnoli (CRC32C) is used by the v2 record batch format. klauspost/crc32
+	// uses SSE4.2 instructions for it where available.
+	crcCastagnoli
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
 // crc32Field implements the pushEncoder and pushDecoder interfaces for calculating CRC32s.
+// The zero value uses the IEEE polynomial.
 type crc32Field struct {
 	startOffset int
+	polynomial  crcPolynomial
+}
+
+func newCRC32Field(polynomial crcPolynomial) *crc32Field {
+	return &crc32Field{polynomial: polynomial}
 }
 
 func (c *crc32Field) saveOffset(in int) {
@@ -20,13 +38,13 @@ func (c *crc32Field) reserveLength() int {
 }
 
 func (c *crc32Field) run(curOffset int, buf []byte) error {
-	crc := crc32.ChecksumIEEE(buf[c.startOffset+4 : curOffset])
+	crc := c.update(0, buf[c.startOffset+4:curOffset])
 	binary.BigEndian.PutUint32(buf[c.startOffset:], crc)
 	return nil
 }
 
 func (c *crc32Field) check(curOffset int, buf []byte) error {
-	crc := crc32.ChecksumIEEE(buf[c.startOffset+4 : curOffset])
+	crc := c.update(0, buf[c.startOffset+4:curOffset])
 
 	if crc != binary.BigEndian.Uint32(buf[c.startOffset:]) {
 		return PacketDecodingError{"CRC didn't match"}
@@ -34,3 +52,11 @@ func (c *crc32Field) check(curOffset int, buf []byte) error {
 
 	return nil
 }
+
+// update adds p to the running checksum crc.
+func (c *crc32Field) update(crc uint32, p []byte) uint32 {
+	if c.polynomial == crcCastagnoli {
+		return crc32.Update(crc, castagnoliTable, p)
+	}
+	return crc32.Update(crc, crc32.IEEETable, p)
+}
@@ -0,0 +1,36 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestCRC32FieldPolynomials(t *testing.T) {
+	// "123456789" is the standard check input for CRC algorithms
+	for _, tc := range []struct {
+		polynomial crcPolynomial
+		expected   uint32
+	}{
+		{crcIEEE, 0xCBF43926},
+		{crcCastagnoli, 0xE3069283},
+	} {
+		buf := append(make([]byte, 4), "123456789"...)
+		field := newCRC32Field(tc.polynomial)
+		field.saveOffset(0)
+
+		if err := field.run(len(buf), buf); err != nil {
+			t.Fatal(err)
+		}
+		if crc := binary.BigEndian.Uint32(buf); crc != tc.expected {
+			t.Errorf("polynomial %d: got CRC %#x, expected %#x", tc.polynomial, crc, tc.expected)
+		}
+		if err := field.check(len(buf), buf); err != nil {
+			t.Error(err)
+		}
+
+		buf[4] ^= 0xFF
+		if err := field.check(len(buf), buf); err == nil {
+			t.Errorf("polynomial %d: corrupted data passed the CRC check", tc.polynomial)
+		}
+	}
+}
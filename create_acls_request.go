@@ -0,0 +1,54 @@
+package sarama
+
+// AclCreation is an ACL to create, with its resource.
+type AclCreation struct {
+	Resource
+	Acl
+}
+
+// CreateAclsRequest creates ACLs. It requires Kafka 0.11.
+type CreateAclsRequest struct {
+	AclCreations []*AclCreation
+}
+
+func (r *CreateAclsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.AclCreations)); err != nil {
+		return err
+	}
+	for _, creation := range r.AclCreations {
+		if err := creation.Resource.encode(pe); err != nil {
+			return err
+		}
+		if err := creation.Acl.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CreateAclsRequest) decode(pd packetDecoder) error {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.AclCreations = make([]*AclCreation, n)
+	for i := range r.AclCreations {
+		creation := new(AclCreation)
+		if err := creation.Resource.decode(pd); err != nil {
+			return err
+		}
+		if err := creation.Acl.decode(pd); err != nil {
+			return err
+		}
+		r.AclCreations[i] = creation
+	}
+	return nil
+}
+
+func (r *CreateAclsRequest) key() int16 {
+	return 30
+}
+
+func (r *CreateAclsRequest) version() int16 {
+	return 0
+}
@@ -0,0 +1,23 @@
+package sarama
+
+import "testing"
+
+var (
+	createAclsRequest = []byte{
+		0, 0, 0, 1,
+		2, // AclResourceTopic
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 10, 'U', 's', 'e', 'r', ':', 'a', 'l', 'i', 'c', 'e',
+		0, 1, '*',
+		3, // AclOperationRead
+		3, // AclPermissionAllow
+	}
+)
+
+func TestCreateAclsRequest(t *testing.T) {
+	request := &CreateAclsRequest{AclCreations: []*AclCreation{{
+		Resource: Resource{ResourceType: AclResourceTopic, ResourceName: "topic"},
+		Acl:      Acl{Principal: "User:alice", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow},
+	}}}
+	testRequest(t, "one creation", request, createAclsRequest)
+}
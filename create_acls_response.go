@@ -0,0 +1,59 @@
+package sarama
+
+import "time"
+
+type CreateAclsResponse struct {
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota.
+	ThrottleTime time.Duration
+	// AclCreationResponses holds the result of each creation, in the order of
+	// the request.
+	AclCreationResponses []*AclCreationResponse
+}
+
+// AclCreationResponse is the result of creating an ACL. ErrMsg may explain Err.
+type AclCreationResponse struct {
+	Err    KError
+	ErrMsg *string
+}
+
+func (r *CreateAclsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	if err := pe.putArrayLength(len(r.AclCreationResponses)); err != nil {
+		return err
+	}
+	for _, result := range r.AclCreationResponses {
+		pe.putInt16(int16(result.Err))
+		if err := putNullableString(pe, result.ErrMsg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CreateAclsResponse) decode(pd packetDecoder) error {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.AclCreationResponses = make([]*AclCreationResponse, n)
+	for i := range r.AclCreationResponses {
+		result := new(AclCreationResponse)
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		result.Err = KError(kerr)
+		if result.ErrMsg, err = getNullableString(pd); err != nil {
+			return err
+		}
+		r.AclCreationResponses[i] = result
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	createAclsResponse = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, 0, 2,
+		0, 0, // ErrNoError
+		255, 255, // null ErrMsg
+		0, 54, // ErrSecurityDisabled
+		0, 3, 'm', 's', 'g',
+	}
+)
+
+func TestCreateAclsResponse(t *testing.T) {
+	msg := "msg"
+	response := &CreateAclsResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		AclCreationResponses: []*AclCreationResponse{
+			{Err: ErrNoError},
+			{Err: ErrSecurityDisabled, ErrMsg: &msg},
+		},
+	}
+	testResponse(t, "two creations", response, createAclsResponse)
+}
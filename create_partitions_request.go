@@ -0,0 +1,117 @@
+package sarama
+
+import "time"
+
+// CreatePartitionsRequest adds partitions to topics, and has to be sent to the
+// controller. It requires Kafka 1.0.
+type CreatePartitionsRequest struct {
+	TopicPartitions map[string]*TopicPartition
+	// How long to wait for the partitions to be created on the controller;
+	// with 0, the request only starts their creation.
+	Timeout time.Duration
+	// Whether to only validate the request, without creating the partitions.
+	ValidateOnly bool
+}
+
+// TopicPartition describes the partitions to add to a topic.
+type TopicPartition struct {
+	// The number of partitions the topic is to have, including its existing
+	// ones.
+	Count int32
+	// The IDs of the brokers to place the replicas of each new partition on,
+	// or nil to let the controller assign them.
+	Assignment [][]int32
+}
+
+func (r *CreatePartitionsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.TopicPartitions)); err != nil {
+		return err
+	}
+	for topic, partition := range r.TopicPartitions {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := partition.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+	putBool(pe, r.ValidateOnly)
+	return nil
+}
+
+func (r *CreatePartitionsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.TopicPartitions = make(map[string]*TopicPartition, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		partition := new(TopicPartition)
+		if err := partition.decode(pd); err != nil {
+			return err
+		}
+		r.TopicPartitions[topic] = partition
+	}
+
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+	r.ValidateOnly, err = getBool(pd)
+	return err
+}
+
+func (r *CreatePartitionsRequest) key() int16 {
+	return 37
+}
+
+func (r *CreatePartitionsRequest) version() int16 {
+	return 0
+}
+
+func (t *TopicPartition) encode(pe packetEncoder) error {
+	pe.putInt32(t.Count)
+
+	// a null assignment lets the controller assign the replicas
+	if t.Assignment == nil {
+		pe.putInt32(-1)
+		return nil
+	}
+	if err := pe.putArrayLength(len(t.Assignment)); err != nil {
+		return err
+	}
+	for _, replicas := range t.Assignment {
+		if err := pe.putInt32Array(replicas); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TopicPartition) decode(pd packetDecoder) (err error) {
+	if t.Count, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	n, err := pd.getInt32()
+	if err != nil || n == -1 {
+		return err
+	}
+	if n < 0 || 4*int(n) > pd.remaining() {
+		return PacketDecodingError{"invalid array length"}
+	}
+	t.Assignment = make([][]int32, n)
+	for i := range t.Assignment {
+		if t.Assignment[i], err = pd.getInt32Array(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
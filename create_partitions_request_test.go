@@ -0,0 +1,40 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	createPartitionsRequestNoAssignment = []byte{
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 3, // Count
+		255, 255, 255, 255, // null Assignment
+		0, 0, 0, 100, // Timeout
+		0, // ValidateOnly
+	}
+
+	createPartitionsRequestAssignment = []byte{
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 3, // Count
+		0, 0, 0, 2, // 2 new partitions
+		0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 2,
+		0, 0, 0, 2, 0, 0, 0, 2, 0, 0, 0, 3,
+		0, 0, 0, 100, // Timeout
+		1, // ValidateOnly
+	}
+)
+
+func TestCreatePartitionsRequest(t *testing.T) {
+	request := &CreatePartitionsRequest{
+		TopicPartitions: map[string]*TopicPartition{"topic": {Count: 3}},
+		Timeout:         100 * time.Millisecond,
+	}
+	testRequest(t, "no assignment", request, createPartitionsRequestNoAssignment)
+
+	request.TopicPartitions["topic"].Assignment = [][]int32{{1, 2}, {2, 3}}
+	request.ValidateOnly = true
+	testRequest(t, "assignment", request, createPartitionsRequestAssignment)
+}
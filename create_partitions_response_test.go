@@ -0,0 +1,25 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	createPartitionsResponse = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 37, // ErrInvalidPartitions
+		0, 3, 'm', 's', 'g',
+	}
+)
+
+func TestCreatePartitionsResponse(t *testing.T) {
+	msg := "msg"
+	response := &CreatePartitionsResponse{
+		ThrottleTime:         100 * time.Millisecond,
+		TopicPartitionErrors: map[string]*TopicError{"topic": {Err: ErrInvalidPartitions, ErrMsg: &msg}},
+	}
+	testResponse(t, "invalid partitions", response, createPartitionsResponse)
+}
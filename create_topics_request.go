@@ -0,0 +1,175 @@
+package sarama
+
+import "time"
+
+// CreateTopicsRequest creates topics, and has to be sent to the controller. It
+// requires Kafka 0.10.1.
+type CreateTopicsRequest struct {
+	// Version can be 0, 1 for Kafka 0.11 and later, which adds ValidateOnly
+	// and error messages, or 2 for Kafka 1.0 and later, whose response
+	// includes the time the request was throttled by quotas.
+	Version int16
+
+	TopicDetails map[string]*TopicDetail
+	// How long to wait for the topics to be created on the controller; with
+	// 0, the request only starts their creation.
+	Timeout time.Duration
+	// Whether to only validate the request, without creating the topics.
+	ValidateOnly bool
+}
+
+// TopicDetail describes a topic to create.
+type TopicDetail struct {
+	// The number of partitions and replicas of each, both -1 if
+	// ReplicaAssignment is set.
+	NumPartitions     int32
+	ReplicationFactor int16
+	// The IDs of the brokers to place the replicas of each partition on,
+	// rather than letting the controller assign them.
+	ReplicaAssignment map[int32][]int32
+	// Configuration overrides for the topic, such as "retention.ms".
+	ConfigEntries map[string]*string
+}
+
+func (r *CreateTopicsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.TopicDetails)); err != nil {
+		return err
+	}
+	for topic, detail := range r.TopicDetails {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := detail.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+	if r.Version >= 1 {
+		putBool(pe, r.ValidateOnly)
+	}
+	return nil
+}
+
+func (r *CreateTopicsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.TopicDetails = make(map[string]*TopicDetail, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		detail := new(TopicDetail)
+		if err := detail.decode(pd); err != nil {
+			return err
+		}
+		r.TopicDetails[topic] = detail
+	}
+
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+	if r.Version >= 1 {
+		if r.ValidateOnly, err = getBool(pd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CreateTopicsRequest) key() int16 {
+	return 19
+}
+
+func (r *CreateTopicsRequest) version() int16 {
+	return r.Version
+}
+
+// minVersion is 1 for requests that only validate, which version 0 cannot.
+func (r *CreateTopicsRequest) minVersion() int16 {
+	if r.ValidateOnly {
+		return 1
+	}
+	return 0
+}
+
+func (r *CreateTopicsRequest) setVersion(version int16) {
+	r.Version = version
+}
+
+func (t *TopicDetail) encode(pe packetEncoder) error {
+	pe.putInt32(t.NumPartitions)
+	pe.putInt16(t.ReplicationFactor)
+
+	if err := pe.putArrayLength(len(t.ReplicaAssignment)); err != nil {
+		return err
+	}
+	for partition, replicas := range t.ReplicaAssignment {
+		pe.putInt32(partition)
+		if err := pe.putInt32Array(replicas); err != nil {
+			return err
+		}
+	}
+
+	if err := pe.putArrayLength(len(t.ConfigEntries)); err != nil {
+		return err
+	}
+	for name, value := range t.ConfigEntries {
+		if err := pe.putString(name); err != nil {
+			return err
+		}
+		if err := putNullableString(pe, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TopicDetail) decode(pd packetDecoder) (err error) {
+	if t.NumPartitions, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if t.ReplicationFactor, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		t.ReplicaAssignment = make(map[int32][]int32, n)
+		for i := 0; i < n; i++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			if t.ReplicaAssignment[partition], err = pd.getInt32Array(); err != nil {
+				return err
+			}
+		}
+	}
+
+	n, err = pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		t.ConfigEntries = make(map[string]*string, n)
+		for i := 0; i < n; i++ {
+			name, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			if t.ConfigEntries[name], err = getNullableString(pd); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package sarama
+
+import "time"
+
+type CreateTopicsResponse struct {
+	// Version must be set to the version of the request before decoding.
+	Version int16
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota, from version 2.
+	ThrottleTime time.Duration
+	TopicErrors  map[string]*TopicError
+}
+
+// TopicError is the result of creating or deleting a topic, or adding
+// partitions to it. ErrMsg, from the brokers of Kafka 0.11 and later, may
+// explain Err.
+type TopicError struct {
+	Err    KError
+	ErrMsg *string
+}
+
+func (r *CreateTopicsResponse) encode(pe packetEncoder) error {
+	if r.Version >= 2 {
+		pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	}
+
+	if err := pe.putArrayLength(len(r.TopicErrors)); err != nil {
+		return err
+	}
+	for topic, topicErr := range r.TopicErrors {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		pe.putInt16(int16(topicErr.Err))
+		if r.Version >= 1 {
+			if err := putNullableString(pe, topicErr.ErrMsg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *CreateTopicsResponse) setVersion(version int16) {
+	r.Version = version
+}
+
+func (r *CreateTopicsResponse) decode(pd packetDecoder) (err error) {
+	if r.Version >= 2 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		r.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.TopicErrors = make(map[string]*TopicError, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		topicErr := &TopicError{Err: KError(kerr)}
+		if r.Version >= 1 {
+			if topicErr.ErrMsg, err = getNullableString(pd); err != nil {
+				return err
+			}
+		}
+		r.TopicErrors[topic] = topicErr
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	createTopicsResponseV0 = []byte{
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 42, // ErrInvalidRequest
+	}
+
+	createTopicsResponseV1 = []byte{
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 42,
+		0, 3, 'm', 's', 'g',
+	}
+
+	createTopicsResponseV2 = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 42,
+		255, 255, // null ErrMsg
+	}
+)
+
+func TestCreateTopicsResponse(t *testing.T) {
+	response := &CreateTopicsResponse{TopicErrors: map[string]*TopicError{"topic": {Err: ErrInvalidRequest}}}
+	testResponse(t, "version 0", response, createTopicsResponseV0)
+
+	msg := "msg"
+	response = &CreateTopicsResponse{Version: 1, TopicErrors: map[string]*TopicError{"topic": {Err: ErrInvalidRequest, ErrMsg: &msg}}}
+	testEncodable(t, "version 1", response, createTopicsResponseV1)
+	decoded := &CreateTopicsResponse{Version: 1}
+	testDecodable(t, "version 1", decoded, createTopicsResponseV1)
+	if topicErr := decoded.TopicErrors["topic"]; topicErr == nil || topicErr.ErrMsg == nil || *topicErr.ErrMsg != msg {
+		t.Error("Decoding produced an unexpected topic error", topicErr)
+	}
+
+	response = &CreateTopicsResponse{Version: 2}
+	testDecodable(t, "version 2", response, createTopicsResponseV2)
+	if response.ThrottleTime != 100*time.Millisecond {
+		t.Error("Decoding produced", response.ThrottleTime, "throttle time instead of 100ms")
+	}
+	if topicErr := response.TopicErrors["topic"]; topicErr == nil || topicErr.Err != ErrInvalidRequest || topicErr.ErrMsg != nil {
+		t.Error("Decoding produced an unexpected topic error", topicErr)
+	}
+}
@@ -0,0 +1,42 @@
+package sarama
+
+// DeleteAclsRequest deletes the ACLs each of the filters matches. It requires
+// Kafka 0.11.
+type DeleteAclsRequest struct {
+	Filters []*AclFilter
+}
+
+func (r *DeleteAclsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Filters)); err != nil {
+		return err
+	}
+	for _, filter := range r.Filters {
+		if err := filter.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DeleteAclsRequest) decode(pd packetDecoder) error {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Filters = make([]*AclFilter, n)
+	for i := range r.Filters {
+		r.Filters[i] = new(AclFilter)
+		if err := r.Filters[i].decode(pd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DeleteAclsRequest) key() int16 {
+	return 31
+}
+
+func (r *DeleteAclsRequest) version() int16 {
+	return 0
+}
@@ -0,0 +1,26 @@
+package sarama
+
+import "testing"
+
+var (
+	deleteAclsRequest = []byte{
+		0, 0, 0, 1,
+		4, // AclResourceCluster
+		0, 13, 'k', 'a', 'f', 'k', 'a', '-', 'c', 'l', 'u', 's', 't', 'e', 'r',
+		255, 255, // any principal
+		255, 255, // any host
+		2, // AclOperationAll
+		1, // AclPermissionAny
+	}
+)
+
+func TestDeleteAclsRequest(t *testing.T) {
+	name := "kafka-cluster"
+	request := &DeleteAclsRequest{Filters: []*AclFilter{{
+		ResourceType:   AclResourceCluster,
+		ResourceName:   &name,
+		Operation:      AclOperationAll,
+		PermissionType: AclPermissionAny,
+	}}}
+	testRequest(t, "cluster", request, deleteAclsRequest)
+}
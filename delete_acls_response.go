@@ -0,0 +1,122 @@
+package sarama
+
+import "time"
+
+type DeleteAclsResponse struct {
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota.
+	ThrottleTime time.Duration
+	// FilterResponses holds the result of each filter, in the order of the
+	// request.
+	FilterResponses []*FilterResponse
+}
+
+// FilterResponse is the result of deleting the ACLs a filter matches: the
+// error of the filter as a whole, and the ACLs it matched with the error of
+// deleting each. ErrMsg may explain Err.
+type FilterResponse struct {
+	Err          KError
+	ErrMsg       *string
+	MatchingAcls []*MatchingAcl
+}
+
+// MatchingAcl is an ACL a filter matched, with its resource.
+type MatchingAcl struct {
+	Err    KError
+	ErrMsg *string
+	Resource
+	Acl
+}
+
+func (r *DeleteAclsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	if err := pe.putArrayLength(len(r.FilterResponses)); err != nil {
+		return err
+	}
+	for _, result := range r.FilterResponses {
+		if err := result.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DeleteAclsResponse) decode(pd packetDecoder) error {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.FilterResponses = make([]*FilterResponse, n)
+	for i := range r.FilterResponses {
+		r.FilterResponses[i] = new(FilterResponse)
+		if err := r.FilterResponses[i].decode(pd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *FilterResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+	if err := putNullableString(pe, r.ErrMsg); err != nil {
+		return err
+	}
+	if err := pe.putArrayLength(len(r.MatchingAcls)); err != nil {
+		return err
+	}
+	for _, matching := range r.MatchingAcls {
+		pe.putInt16(int16(matching.Err))
+		if err := putNullableString(pe, matching.ErrMsg); err != nil {
+			return err
+		}
+		if err := matching.Resource.encode(pe); err != nil {
+			return err
+		}
+		if err := matching.Acl.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *FilterResponse) decode(pd packetDecoder) error {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+	if r.ErrMsg, err = getNullableString(pd); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.MatchingAcls = make([]*MatchingAcl, n)
+	for i := range r.MatchingAcls {
+		matching := new(MatchingAcl)
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		matching.Err = KError(kerr)
+		if matching.ErrMsg, err = getNullableString(pd); err != nil {
+			return err
+		}
+		if err := matching.Resource.decode(pd); err != nil {
+			return err
+		}
+		if err := matching.Acl.decode(pd); err != nil {
+			return err
+		}
+		r.MatchingAcls[i] = matching
+	}
+	return nil
+}
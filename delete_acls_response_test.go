@@ -0,0 +1,31 @@
+package sarama
+
+import "testing"
+
+var (
+	deleteAclsResponse = []byte{
+		0, 0, 0, 0, // ThrottleTime
+		0, 0, 0, 1,
+		0, 0, // ErrNoError
+		255, 255, // null ErrMsg
+		0, 0, 0, 1,
+		0, 0, // ErrNoError
+		255, 255, // null ErrMsg
+		2, // AclResourceTopic
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 10, 'U', 's', 'e', 'r', ':', 'a', 'l', 'i', 'c', 'e',
+		0, 1, '*',
+		4, // AclOperationWrite
+		3, // AclPermissionAllow
+	}
+)
+
+func TestDeleteAclsResponse(t *testing.T) {
+	response := &DeleteAclsResponse{FilterResponses: []*FilterResponse{{
+		MatchingAcls: []*MatchingAcl{{
+			Resource: Resource{ResourceType: AclResourceTopic, ResourceName: "topic"},
+			Acl:      Acl{Principal: "User:alice", Host: "*", Operation: AclOperationWrite, PermissionType: AclPermissionAllow},
+		}},
+	}}}
+	testResponse(t, "one match", response, deleteAclsResponse)
+}
@@ -0,0 +1,77 @@
+package sarama
+
+import "time"
+
+// DeleteRecordsRequest deletes the records of partitions before the given
+// offsets, by moving their log start offsets, and has to be sent to the leaders
+// of the partitions. It requires Kafka 0.11.
+type DeleteRecordsRequest struct {
+	// Topics holds the offset to delete the records before of each partition,
+	// by topic, which is -1 to delete every record up to the high watermark.
+	Topics map[string]map[int32]int64
+	// How long to wait for the replicas of the partitions to delete the
+	// records.
+	Timeout time.Duration
+}
+
+func (r *DeleteRecordsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for partition, offset := range partitions {
+			pe.putInt32(partition)
+			pe.putInt64(offset)
+		}
+	}
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+	return nil
+}
+
+func (r *DeleteRecordsRequest) decode(pd packetDecoder) error {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make(map[string]map[int32]int64, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		r.Topics[topic] = make(map[int32]int64, m)
+		for j := 0; j < m; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			if r.Topics[topic][partition], err = pd.getInt64(); err != nil {
+				return err
+			}
+		}
+	}
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+	return nil
+}
+
+func (r *DeleteRecordsRequest) key() int16 {
+	return 21
+}
+
+func (r *DeleteRecordsRequest) version() int16 {
+	return 0
+}
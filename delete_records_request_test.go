@@ -0,0 +1,25 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	deleteRecordsRequest = []byte{
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 1,
+		0, 0, 0, 3, // partition
+		0, 0, 0, 0, 0, 0, 0, 42, // offset
+		0, 0, 0, 100, // Timeout
+	}
+)
+
+func TestDeleteRecordsRequest(t *testing.T) {
+	request := &DeleteRecordsRequest{
+		Topics:  map[string]map[int32]int64{"topic": {3: 42}},
+		Timeout: 100 * time.Millisecond,
+	}
+	testRequest(t, "one partition", request, deleteRecordsRequest)
+}
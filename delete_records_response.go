@@ -0,0 +1,91 @@
+package sarama
+
+import "time"
+
+type DeleteRecordsResponse struct {
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota.
+	ThrottleTime time.Duration
+	Topics       map[string]map[int32]*DeleteRecordsResponsePartition
+}
+
+// DeleteRecordsResponsePartition is the result of deleting the records of a
+// partition: its new log start offset, or the error that kept them.
+type DeleteRecordsResponsePartition struct {
+	LowWatermark int64
+	Err          KError
+}
+
+func (r *DeleteRecordsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for partition, result := range partitions {
+			pe.putInt32(partition)
+			pe.putInt64(result.LowWatermark)
+			pe.putInt16(int16(result.Err))
+		}
+	}
+	return nil
+}
+
+func (r *DeleteRecordsResponse) decode(pd packetDecoder) error {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make(map[string]map[int32]*DeleteRecordsResponsePartition, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		r.Topics[topic] = make(map[int32]*DeleteRecordsResponsePartition, m)
+		for j := 0; j < m; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			result := new(DeleteRecordsResponsePartition)
+			if result.LowWatermark, err = pd.getInt64(); err != nil {
+				return err
+			}
+			kerr, err := pd.getInt16()
+			if err != nil {
+				return err
+			}
+			result.Err = KError(kerr)
+			r.Topics[topic][partition] = result
+		}
+	}
+	return nil
+}
+
+// AddPartition adds the result of deleting the records of a partition.
+func (r *DeleteRecordsResponse) AddPartition(topic string, partition int32, lowWatermark int64, kerr KError) {
+	if r.Topics == nil {
+		r.Topics = make(map[string]map[int32]*DeleteRecordsResponsePartition)
+	}
+	if r.Topics[topic] == nil {
+		r.Topics[topic] = make(map[int32]*DeleteRecordsResponsePartition)
+	}
+	r.Topics[topic][partition] = &DeleteRecordsResponsePartition{LowWatermark: lowWatermark, Err: kerr}
+}
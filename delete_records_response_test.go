@@ -0,0 +1,24 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	deleteRecordsResponse = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 1,
+		0, 0, 0, 3, // partition
+		255, 255, 255, 255, 255, 255, 255, 255, // LowWatermark
+		0, 1, // ErrOffsetOutOfRange
+	}
+)
+
+func TestDeleteRecordsResponse(t *testing.T) {
+	response := &DeleteRecordsResponse{ThrottleTime: 100 * time.Millisecond}
+	response.AddPartition("topic", 3, -1, ErrOffsetOutOfRange)
+	testResponse(t, "offset out of range", response, deleteRecordsResponse)
+}
@@ -0,0 +1,51 @@
+package sarama
+
+import "time"
+
+// DeleteTopicsRequest deletes topics, and has to be sent to the controller. It
+// requires Kafka 0.10.1.
+type DeleteTopicsRequest struct {
+	// Version can be 0, or 1 for Kafka 0.11 and later, in which case the
+	// response includes the time the request was throttled by quotas.
+	Version int16
+	Topics  []string
+	// How long to wait for the topics to be deleted on the controller; with
+	// 0, the request only starts their deletion.
+	Timeout time.Duration
+}
+
+func (r *DeleteTopicsRequest) encode(pe packetEncoder) error {
+	if err := pe.putStringArray(r.Topics); err != nil {
+		return err
+	}
+	pe.putInt32(int32(r.Timeout / time.Millisecond))
+	return nil
+}
+
+func (r *DeleteTopicsRequest) decode(pd packetDecoder) (err error) {
+	if r.Topics, err = pd.getStringArray(); err != nil {
+		return err
+	}
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.Timeout = time.Duration(timeout) * time.Millisecond
+	return nil
+}
+
+func (r *DeleteTopicsRequest) key() int16 {
+	return 20
+}
+
+func (r *DeleteTopicsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DeleteTopicsRequest) minVersion() int16 {
+	return 0
+}
+
+func (r *DeleteTopicsRequest) setVersion(version int16) {
+	r.Version = version
+}
@@ -0,0 +1,23 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	deleteTopicsRequest = []byte{
+		0, 0, 0, 2,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 5, 'o', 't', 'h', 'e', 'r',
+		0, 0, 0, 100, // Timeout
+	}
+)
+
+func TestDeleteTopicsRequest(t *testing.T) {
+	request := &DeleteTopicsRequest{Topics: []string{"topic", "other"}, Timeout: 100 * time.Millisecond}
+	testRequest(t, "version 0", request, deleteTopicsRequest)
+
+	request.Version = 1
+	testRequest(t, "version 1", request, deleteTopicsRequest)
+}
@@ -0,0 +1,61 @@
+package sarama
+
+import "time"
+
+type DeleteTopicsResponse struct {
+	// Version must be set to the version of the request before decoding.
+	Version int16
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota, from version 1.
+	ThrottleTime    time.Duration
+	TopicErrorCodes map[string]KError
+}
+
+func (r *DeleteTopicsResponse) encode(pe packetEncoder) error {
+	if r.Version >= 1 {
+		pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	}
+
+	if err := pe.putArrayLength(len(r.TopicErrorCodes)); err != nil {
+		return err
+	}
+	for topic, kerr := range r.TopicErrorCodes {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		pe.putInt16(int16(kerr))
+	}
+	return nil
+}
+
+func (r *DeleteTopicsResponse) setVersion(version int16) {
+	r.Version = version
+}
+
+func (r *DeleteTopicsResponse) decode(pd packetDecoder) (err error) {
+	if r.Version >= 1 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		r.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.TopicErrorCodes = make(map[string]KError, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		r.TopicErrorCodes[topic] = KError(kerr)
+	}
+	return nil
+}
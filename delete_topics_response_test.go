@@ -0,0 +1,35 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	deleteTopicsResponseV0 = []byte{
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 3, // ErrUnknownTopicOrPartition
+	}
+
+	deleteTopicsResponseV1 = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, 0, 1,
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0,
+	}
+)
+
+func TestDeleteTopicsResponse(t *testing.T) {
+	response := &DeleteTopicsResponse{TopicErrorCodes: map[string]KError{"topic": ErrUnknownTopicOrPartition}}
+	testResponse(t, "version 0", response, deleteTopicsResponseV0)
+
+	response = &DeleteTopicsResponse{Version: 1, ThrottleTime: 100 * time.Millisecond, TopicErrorCodes: map[string]KError{"topic": ErrNoError}}
+	testEncodable(t, "version 1", response, deleteTopicsResponseV1)
+	decoded := &DeleteTopicsResponse{Version: 1}
+	testDecodable(t, "version 1", decoded, deleteTopicsResponseV1)
+	if !reflect.DeepEqual(decoded, response) {
+		t.Errorf("Decoded response does not match the encoded one\nencoded: %#v\ndecoded: %#v", response, decoded)
+	}
+}
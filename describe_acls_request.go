@@ -0,0 +1,23 @@
+package sarama
+
+// DescribeAclsRequest describes the ACLs the filter matches. It requires
+// Kafka 0.11.
+type DescribeAclsRequest struct {
+	AclFilter
+}
+
+func (r *DescribeAclsRequest) encode(pe packetEncoder) error {
+	return r.AclFilter.encode(pe)
+}
+
+func (r *DescribeAclsRequest) decode(pd packetDecoder) error {
+	return r.AclFilter.decode(pd)
+}
+
+func (r *DescribeAclsRequest) key() int16 {
+	return 29
+}
+
+func (r *DescribeAclsRequest) version() int16 {
+	return 0
+}
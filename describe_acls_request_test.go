@@ -0,0 +1,43 @@
+package sarama
+
+import "testing"
+
+var (
+	describeAclsRequestAny = []byte{
+		1,        // AclResourceAny
+		255, 255, // any name
+		255, 255, // any principal
+		255, 255, // any host
+		1, // AclOperationAny
+		1, // AclPermissionAny
+	}
+
+	describeAclsRequestTopic = []byte{
+		2, // AclResourceTopic
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 10, 'U', 's', 'e', 'r', ':', 'a', 'l', 'i', 'c', 'e',
+		0, 1, '*',
+		4, // AclOperationWrite
+		2, // AclPermissionDeny
+	}
+)
+
+func TestDescribeAclsRequest(t *testing.T) {
+	request := &DescribeAclsRequest{AclFilter{
+		ResourceType:   AclResourceAny,
+		Operation:      AclOperationAny,
+		PermissionType: AclPermissionAny,
+	}}
+	testRequest(t, "any", request, describeAclsRequestAny)
+
+	name, principal, host := "topic", "User:alice", "*"
+	request = &DescribeAclsRequest{AclFilter{
+		ResourceType:   AclResourceTopic,
+		ResourceName:   &name,
+		Principal:      &principal,
+		Host:           &host,
+		Operation:      AclOperationWrite,
+		PermissionType: AclPermissionDeny,
+	}}
+	testRequest(t, "topic", request, describeAclsRequestTopic)
+}
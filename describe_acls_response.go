@@ -0,0 +1,98 @@
+package sarama
+
+import "time"
+
+type DescribeAclsResponse struct {
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota.
+	ThrottleTime time.Duration
+	Err          KError
+	ErrMsg       *string
+	ResourceAcls []*ResourceAcls
+}
+
+// ResourceAcls is a resource and the ACLs that apply to it.
+type ResourceAcls struct {
+	Resource
+	Acls []*Acl
+}
+
+func (r *DescribeAclsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.Err))
+	if err := putNullableString(pe, r.ErrMsg); err != nil {
+		return err
+	}
+	if err := pe.putArrayLength(len(r.ResourceAcls)); err != nil {
+		return err
+	}
+	for _, resourceAcls := range r.ResourceAcls {
+		if err := resourceAcls.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DescribeAclsResponse) decode(pd packetDecoder) error {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+	if r.ErrMsg, err = getNullableString(pd); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.ResourceAcls = make([]*ResourceAcls, n)
+	for i := range r.ResourceAcls {
+		r.ResourceAcls[i] = new(ResourceAcls)
+		if err := r.ResourceAcls[i].decode(pd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ResourceAcls) encode(pe packetEncoder) error {
+	if err := r.Resource.encode(pe); err != nil {
+		return err
+	}
+	if err := pe.putArrayLength(len(r.Acls)); err != nil {
+		return err
+	}
+	for _, acl := range r.Acls {
+		if err := acl.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ResourceAcls) decode(pd packetDecoder) error {
+	if err := r.Resource.decode(pd); err != nil {
+		return err
+	}
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Acls = make([]*Acl, n)
+	for i := range r.Acls {
+		r.Acls[i] = new(Acl)
+		if err := r.Acls[i].decode(pd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package sarama
+
+import "testing"
+
+var (
+	describeAclsResponse = []byte{
+		0, 0, 0, 0, // ThrottleTime
+		0, 0, // ErrNoError
+		255, 255, // null ErrMsg
+		0, 0, 0, 1,
+		3, // AclResourceGroup
+		0, 5, 'g', 'r', 'o', 'u', 'p',
+		0, 0, 0, 1,
+		0, 8, 'U', 's', 'e', 'r', ':', 'b', 'o', 'b',
+		0, 9, '1', '0', '.', '0', '.', '0', '.', '1', '0',
+		3, // AclOperationRead
+		3, // AclPermissionAllow
+	}
+)
+
+func TestDescribeAclsResponse(t *testing.T) {
+	response := &DescribeAclsResponse{ResourceAcls: []*ResourceAcls{{
+		Resource: Resource{ResourceType: AclResourceGroup, ResourceName: "group"},
+		Acls:     []*Acl{{Principal: "User:bob", Host: "10.0.0.10", Operation: AclOperationRead, PermissionType: AclPermissionAllow}},
+	}}}
+	testResponse(t, "one group", response, describeAclsResponse)
+}
@@ -0,0 +1,92 @@
+package sarama
+
+// ConfigResourceType is the type of resource whose configuration is described
+// or altered.
+type ConfigResourceType int8
+
+const (
+	TopicResource  ConfigResourceType = 2
+	BrokerResource ConfigResourceType = 4
+)
+
+// ConfigResource names a resource to describe the configuration of.
+type ConfigResource struct {
+	Type ConfigResourceType
+	// The topic, or the ID of the broker in decimal.
+	Name string
+	// The configuration entries to describe, or nil for all of them.
+	ConfigNames []string
+}
+
+// DescribeConfigsRequest describes the configuration of topics and brokers. It
+// requires Kafka 0.11. Broker resources have to be described by the broker
+// itself.
+type DescribeConfigsRequest struct {
+	Resources []*ConfigResource
+}
+
+func (r *DescribeConfigsRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+		return err
+	}
+	for _, resource := range r.Resources {
+		pe.putInt8(int8(resource.Type))
+		if err := pe.putString(resource.Name); err != nil {
+			return err
+		}
+		// a null array of names asks for every entry
+		if resource.ConfigNames == nil {
+			pe.putInt32(-1)
+			continue
+		}
+		if err := pe.putStringArray(resource.ConfigNames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DescribeConfigsRequest) decode(pd packetDecoder) (err error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Resources = make([]*ConfigResource, n)
+	for i := range r.Resources {
+		resource := new(ConfigResource)
+		t, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		resource.Type = ConfigResourceType(t)
+		if resource.Name, err = pd.getString(); err != nil {
+			return err
+		}
+
+		names, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		if names != -1 {
+			if names < 0 || 2*int(names) > pd.remaining() {
+				return PacketDecodingError{"invalid array length"}
+			}
+			resource.ConfigNames = make([]string, names)
+			for j := range resource.ConfigNames {
+				if resource.ConfigNames[j], err = pd.getString(); err != nil {
+					return err
+				}
+			}
+		}
+		r.Resources[i] = resource
+	}
+	return nil
+}
+
+func (r *DescribeConfigsRequest) key() int16 {
+	return 32
+}
+
+func (r *DescribeConfigsRequest) version() int16 {
+	return 0
+}
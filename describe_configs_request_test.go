@@ -0,0 +1,24 @@
+package sarama
+
+import "testing"
+
+var (
+	describeConfigsRequest = []byte{
+		0, 0, 0, 2,
+		2, // TopicResource
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 1,
+		0, 12, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+		4, // BrokerResource
+		0, 1, '1',
+		255, 255, 255, 255, // all entries
+	}
+)
+
+func TestDescribeConfigsRequest(t *testing.T) {
+	request := &DescribeConfigsRequest{Resources: []*ConfigResource{
+		{Type: TopicResource, Name: "topic", ConfigNames: []string{"retention.ms"}},
+		{Type: BrokerResource, Name: "1"},
+	}}
+	testRequest(t, "topic and broker", request, describeConfigsRequest)
+}
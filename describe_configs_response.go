@@ -0,0 +1,145 @@
+package sarama
+
+import "time"
+
+type DescribeConfigsResponse struct {
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota.
+	ThrottleTime time.Duration
+	Resources    []*ResourceResponse
+}
+
+// ResourceResponse is the result of describing or altering the configuration
+// of a resource. ErrMsg may explain Err; Configs is only set when describing.
+type ResourceResponse struct {
+	Err     KError
+	ErrMsg  *string
+	Type    ConfigResourceType
+	Name    string
+	Configs []*ConfigEntry
+}
+
+// ConfigEntry is a configuration entry of a resource.
+type ConfigEntry struct {
+	Name string
+	// The value of the entry, nil if it is sensitive.
+	Value     *string
+	ReadOnly  bool
+	Default   bool
+	Sensitive bool
+}
+
+func (r *DescribeConfigsResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	if err := pe.putArrayLength(len(r.Resources)); err != nil {
+		return err
+	}
+	for _, resource := range r.Resources {
+		if err := resource.encode(pe); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(resource.Configs)); err != nil {
+			return err
+		}
+		for _, entry := range resource.Configs {
+			if err := entry.encode(pe); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *DescribeConfigsResponse) decode(pd packetDecoder) error {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Resources = make([]*ResourceResponse, n)
+	for i := range r.Resources {
+		resource := new(ResourceResponse)
+		if err := resource.decode(pd); err != nil {
+			return err
+		}
+		entries, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if entries > 0 {
+			resource.Configs = make([]*ConfigEntry, entries)
+		}
+		for j := range resource.Configs {
+			resource.Configs[j] = new(ConfigEntry)
+			if err := resource.Configs[j].decode(pd); err != nil {
+				return err
+			}
+		}
+		r.Resources[i] = resource
+	}
+	return nil
+}
+
+// encode and decode handle the fields shared by the responses to
+// DescribeConfigs and AlterConfigs requests, without the entries.
+func (r *ResourceResponse) encode(pe packetEncoder) error {
+	pe.putInt16(int16(r.Err))
+	if err := putNullableString(pe, r.ErrMsg); err != nil {
+		return err
+	}
+	pe.putInt8(int8(r.Type))
+	return pe.putString(r.Name)
+}
+
+func (r *ResourceResponse) decode(pd packetDecoder) (err error) {
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+	if r.ErrMsg, err = getNullableString(pd); err != nil {
+		return err
+	}
+	t, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	r.Type = ConfigResourceType(t)
+	r.Name, err = pd.getString()
+	return err
+}
+
+func (e *ConfigEntry) encode(pe packetEncoder) error {
+	if err := pe.putString(e.Name); err != nil {
+		return err
+	}
+	if err := putNullableString(pe, e.Value); err != nil {
+		return err
+	}
+	putBool(pe, e.ReadOnly)
+	putBool(pe, e.Default)
+	putBool(pe, e.Sensitive)
+	return nil
+}
+
+func (e *ConfigEntry) decode(pd packetDecoder) (err error) {
+	if e.Name, err = pd.getString(); err != nil {
+		return err
+	}
+	if e.Value, err = getNullableString(pd); err != nil {
+		return err
+	}
+	if e.ReadOnly, err = getBool(pd); err != nil {
+		return err
+	}
+	if e.Default, err = getBool(pd); err != nil {
+		return err
+	}
+	e.Sensitive, err = getBool(pd)
+	return err
+}
@@ -0,0 +1,36 @@
+package sarama
+
+import "testing"
+
+var (
+	describeConfigsResponse = []byte{
+		0, 0, 0, 0, // ThrottleTime
+		0, 0, 0, 1,
+		0, 0, // ErrNoError
+		255, 255, // null ErrMsg
+		2, // TopicResource
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 2,
+		0, 12, 'r', 'e', 't', 'e', 'n', 't', 'i', 'o', 'n', '.', 'm', 's',
+		0, 2, '-', '1',
+		0, // ReadOnly
+		1, // Default
+		0, // Sensitive
+		0, 8, 'p', 'a', 's', 's', 'w', 'o', 'r', 'd',
+		255, 255, // null Value
+		1, 0, 1,
+	}
+)
+
+func TestDescribeConfigsResponse(t *testing.T) {
+	retention := "-1"
+	response := &DescribeConfigsResponse{Resources: []*ResourceResponse{{
+		Type: TopicResource,
+		Name: "topic",
+		Configs: []*ConfigEntry{
+			{Name: "retention.ms", Value: &retention, Default: true},
+			{Name: "password", ReadOnly: true, Sensitive: true},
+		},
+	}}}
+	testResponse(t, "topic", response, describeConfigsResponse)
+}
@@ -1,6 +1,9 @@
 package sarama
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+)
 
 // Encoder is the interface that wraps the basic Encode method.
 // Anything implementing Encoder can be turned into bytes using Kafka's encoding rules.
@@ -10,6 +13,14 @@ type encoder interface {
 
 // Encode takes an Encoder and turns it into bytes.
 func encode(e encoder) ([]byte, error) {
+	return encodeInto(e, nil, MaxRequestSize)
+}
+
+// encodeInto is like encode, but serializes into buf if it has enough capacity
+// rather than allocating, so that callers sending many requests can reuse one
+// buffer. The returned slice aliases buf in that case. Packets larger than
+// limit are refused.
+func encodeInto(e encoder, buf []byte, limit int32) ([]byte, error) {
 	if e == nil {
 		return nil, nil
 	}
@@ -22,11 +33,15 @@ func encode(e encoder) ([]byte, error) {
 		return nil, err
 	}
 
-	if prepEnc.length < 0 || prepEnc.length > int(MaxRequestSize) {
+	if prepEnc.length < 0 || prepEnc.length > int(limit) {
 		return nil, PacketEncodingError{fmt.Sprintf("invalid request size (%d)", prepEnc.length)}
 	}
 
-	realEnc.raw = make([]byte, prepEnc.length)
+	if cap(buf) >= prepEnc.length {
+		realEnc.raw = buf[:prepEnc.length]
+	} else {
+		realEnc.raw = make([]byte, prepEnc.length)
+	}
 	err = e.encode(&realEnc)
 	if err != nil {
 		return nil, err
@@ -35,6 +50,36 @@ func encode(e encoder) ([]byte, error) {
 	return realEnc.raw, nil
 }
 
+// encodeVectored is like encodeInto, but large byte slices such as message values
+// are referenced from the result instead of being copied, so the packet can be
+// written with vectored I/O. The second return value holds the encoded fields
+// (aliasing buf if it had enough capacity) and may be reused once the buffers
+// have been written.
+func encodeVectored(e encoder, buf []byte, limit int32) (net.Buffers, []byte, error) {
+	if e == nil {
+		return nil, buf, nil
+	}
+
+	var prepEnc prepEncoder
+
+	err := e.encode(&prepEnc)
+	if err != nil {
+		return nil, buf, err
+	}
+
+	if prepEnc.length < 0 || prepEnc.length > int(limit) {
+		return nil, buf, PacketEncodingError{fmt.Sprintf("invalid request size (%d)", prepEnc.length)}
+	}
+
+	vecEnc := vectorEncoder{raw: buf[:0]}
+	err = e.encode(&vecEnc)
+	if err != nil {
+		return nil, vecEnc.raw, err
+	}
+
+	return vecEnc.buffers(), vecEnc.raw, nil
+}
+
 // Decoder is the interface that wraps the basic Decode method.
 // Anything implementing Decoder can be extracted from bytes using Kafka's encoding rules.
 type decoder interface {
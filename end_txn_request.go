@@ -0,0 +1,44 @@
+package sarama
+
+// EndTxnRequest commits or aborts the ongoing transaction of a transactional
+// producer, and has to be sent to the coordinator of its transactional ID. It
+// requires Kafka 0.11.
+type EndTxnRequest struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	// Committed is whether to commit the transaction rather than abort it.
+	Committed bool
+}
+
+func (r *EndTxnRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.TransactionalID); err != nil {
+		return err
+	}
+	pe.putInt64(r.ProducerID)
+	pe.putInt16(r.ProducerEpoch)
+	putBool(pe, r.Committed)
+	return nil
+}
+
+func (r *EndTxnRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if r.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+	r.Committed, err = getBool(pd)
+	return err
+}
+
+func (r *EndTxnRequest) key() int16 {
+	return 26
+}
+
+func (r *EndTxnRequest) version() int16 {
+	return 0
+}
@@ -0,0 +1,27 @@
+package sarama
+
+import "testing"
+
+var (
+	endTxnRequestCommit = []byte{
+		0, 3, 't', 'x', 'n',
+		0, 0, 0, 0, 0, 0, 31, 64, // ProducerID
+		0, 1, // ProducerEpoch
+		1, // Committed
+	}
+
+	endTxnRequestAbort = []byte{
+		0, 3, 't', 'x', 'n',
+		0, 0, 0, 0, 0, 0, 31, 64,
+		0, 1,
+		0,
+	}
+)
+
+func TestEndTxnRequest(t *testing.T) {
+	request := &EndTxnRequest{TransactionalID: "txn", ProducerID: 8000, ProducerEpoch: 1, Committed: true}
+	testRequest(t, "commit", request, endTxnRequestCommit)
+
+	request.Committed = false
+	testRequest(t, "abort", request, endTxnRequestAbort)
+}
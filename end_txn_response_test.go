@@ -0,0 +1,16 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var endTxnResponse = []byte{
+	0, 0, 0, 100, // ThrottleTime
+	0, 47, // ErrInvalidProducerEpoch
+}
+
+func TestEndTxnResponse(t *testing.T) {
+	response := &EndTxnResponse{ThrottleTime: 100 * time.Millisecond, Err: ErrInvalidProducerEpoch}
+	testResponse(t, "", response, endTxnResponse)
+}
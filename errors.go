@@ -3,6 +3,7 @@ package sarama
 import (
 	"errors"
 	"fmt"
+	"net"
 )
 
 // ErrOutOfBrokers is the error returned when the client has run out of brokers to talk to because all of them errored
@@ -26,6 +27,16 @@ var ErrAlreadyConnected = errors.New("kafka: broker connection already initiated
 // ErrNotConnected is the error returned when trying to send or call Close() on a Broker that is not connected.
 var ErrNotConnected = errors.New("kafka: broker not connected")
 
+// ErrBrokerNotFound is returned when the cluster metadata has no broker of the requested ID.
+var ErrBrokerNotFound = errors.New("kafka: broker for ID is not found")
+
+// ErrControllerNotAvailable is returned when the cluster metadata does not name a controller broker.
+var ErrControllerNotAvailable = errors.New("kafka: controller is not available")
+
+// ErrInvalidSASLChallenge is returned when a broker's SASL challenge is malformed, or fails to prove that the broker
+// knows the password, as a SCRAM server signature does.
+var ErrInvalidSASLChallenge = errors.New("kafka: broker sent an invalid SASL challenge")
+
 // ErrInsufficientData is returned when decoding and the packet is truncated. This can be expected
 // when requesting messages, since as an optimization the server is allowed to return a partial message at the end
 // of the message set.
@@ -37,6 +48,25 @@ var ErrShuttingDown = errors.New("kafka: message received by producer in process
 // ErrMessageTooLarge is returned when the next message to consume is larger than the configured Consumer.Fetch.Max
 var ErrMessageTooLarge = errors.New("kafka: message is larger than Consumer.Fetch.Max")
 
+// ErrNullValue is returned when decoding the value of a consumed message that has a null value (a tombstone).
+var ErrNullValue = errors.New("kafka: message has a null value")
+
+// ErrInvalidLengthPrefix is returned when splitting data that is not a sequence of length-prefixed parts, as
+// written by a LengthPrefixedEncoder.
+var ErrInvalidLengthPrefix = errors.New("kafka: data is not a sequence of length-prefixed parts")
+
+// ErrDecompressedSizeExceeded is returned when decoding compressed messages that decompress to more than
+// MaxDecompressedBatchSize, or to more than MaxDecompressedResponseSize over a whole fetch response.
+var ErrDecompressedSizeExceeded = errors.New("kafka: decompressed messages exceed MaxDecompressedBatchSize or MaxDecompressedResponseSize")
+
+// ErrNotTransactional is returned when a producer that is not transactional is asked to start or end transactions or to
+// add offsets to them.
+var ErrNotTransactional = errors.New("kafka: producer is not transactional, Producer.Transaction.ID is not set")
+
+// ErrTransactionNotReady is returned when a transactional producer is sent a message or offsets outside of a
+// transaction, when a transaction is begun before the previous one has ended, or one is ended before it was begun.
+var ErrTransactionNotReady = errors.New("kafka: transaction is not ready, begin a transaction before producing in it and end it before beginning another")
+
 // PacketEncodingError is returned from a failure while encoding a Kafka packet. This can happen, for example,
 // if you try to encode a string over 2^15 characters in length, since Kafka's encoding rules do not permit that.
 type PacketEncodingError struct {
@@ -65,44 +95,133 @@ func (err ConfigurationError) Error() string {
 	return "kafka: invalid configuration (" + string(err) + ")"
 }
 
+// BrokerError is the error a request to a broker failed with, such as a network
+// error, with the ID and address of the broker. Unwrap returns that error.
+type BrokerError struct {
+	ID   int32
+	Addr string
+	Err  error
+}
+
+func newBrokerError(broker *Broker, err error) *BrokerError {
+	return &BrokerError{ID: broker.ID(), Addr: broker.Addr(), Err: err}
+}
+
+func (err *BrokerError) Error() string {
+	return fmt.Sprintf("kafka: broker %d (%s) failed: %s", err.ID, err.Addr, err.Err)
+}
+
+func (err *BrokerError) Unwrap() error {
+	return err.Err
+}
+
+// unwrapper is implemented by the errors that wrap another, with its context.
+type unwrapper interface {
+	Unwrap() error
+}
+
+// AsKError returns the KError err is, or wraps as a ConsumerError,
+// ProducerError or BrokerError does, and whether there is one.
+func AsKError(err error) (KError, bool) {
+	for err != nil {
+		if kerr, ok := err.(KError); ok {
+			return kerr, true
+		}
+		u, ok := err.(unwrapper)
+		if !ok {
+			return 0, false
+		}
+		err = u.Unwrap()
+	}
+	return 0, false
+}
+
+// IsRetriable reports whether err, or the error it wraps, is a network error
+// or a retriable KError, so that the request that failed with it may succeed
+// once the cluster has settled, as when a partition's leader moves.
+func IsRetriable(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case KError:
+			return e.Retriable()
+		case net.Error:
+			return true
+		case unwrapper:
+			err = e.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
 // KError is the type of error that can be returned directly by the Kafka broker.
 // See https://cwiki.apache.org/confluence/display/KAFKA/A+Guide+To+The+Kafka+Protocol#AGuideToTheKafkaProtocol-ErrorCodes
 type KError int16
 
 // Numeric error codes returned by the Kafka server.
 const (
-	ErrNoError                         KError = 0
-	ErrUnknown                         KError = -1
-	ErrOffsetOutOfRange                KError = 1
-	ErrInvalidMessage                  KError = 2
-	ErrUnknownTopicOrPartition         KError = 3
-	ErrInvalidMessageSize              KError = 4
-	ErrLeaderNotAvailable              KError = 5
-	ErrNotLeaderForPartition           KError = 6
-	ErrRequestTimedOut                 KError = 7
-	ErrBrokerNotAvailable              KError = 8
-	ErrReplicaNotAvailable             KError = 9
-	ErrMessageSizeTooLarge             KError = 10
-	ErrStaleControllerEpochCode        KError = 11
-	ErrOffsetMetadataTooLarge          KError = 12
-	ErrOffsetsLoadInProgress           KError = 14
-	ErrConsumerCoordinatorNotAvailable KError = 15
-	ErrNotCoordinatorForConsumer       KError = 16
-	ErrInvalidTopic                    KError = 17
-	ErrMessageSetSizeTooLarge          KError = 18
-	ErrNotEnoughReplicas               KError = 19
-	ErrNotEnoughReplicasAfterAppend    KError = 20
-	ErrInvalidRequiredAcks             KError = 21
-	ErrIllegalGeneration               KError = 22
-	ErrInconsistentGroupProtocol       KError = 23
-	ErrInvalidGroupId                  KError = 24
-	ErrUnknownMemberId                 KError = 25
-	ErrInvalidSessionTimeout           KError = 26
-	ErrRebalanceInProgress             KError = 27
-	ErrInvalidCommitOffsetSize         KError = 28
-	ErrTopicAuthorizationFailed        KError = 29
-	ErrGroupAuthorizationFailed        KError = 30
-	ErrClusterAuthorizationFailed      KError = 31
+	ErrNoError                            KError = 0
+	ErrUnknown                            KError = -1
+	ErrOffsetOutOfRange                   KError = 1
+	ErrInvalidMessage                     KError = 2
+	ErrUnknownTopicOrPartition            KError = 3
+	ErrInvalidMessageSize                 KError = 4
+	ErrLeaderNotAvailable                 KError = 5
+	ErrNotLeaderForPartition              KError = 6
+	ErrRequestTimedOut                    KError = 7
+	ErrBrokerNotAvailable                 KError = 8
+	ErrReplicaNotAvailable                KError = 9
+	ErrMessageSizeTooLarge                KError = 10
+	ErrStaleControllerEpochCode           KError = 11
+	ErrOffsetMetadataTooLarge             KError = 12
+	ErrOffsetsLoadInProgress              KError = 14
+	ErrConsumerCoordinatorNotAvailable    KError = 15
+	ErrNotCoordinatorForConsumer          KError = 16
+	ErrInvalidTopic                       KError = 17
+	ErrMessageSetSizeTooLarge             KError = 18
+	ErrNotEnoughReplicas                  KError = 19
+	ErrNotEnoughReplicasAfterAppend       KError = 20
+	ErrInvalidRequiredAcks                KError = 21
+	ErrIllegalGeneration                  KError = 22
+	ErrInconsistentGroupProtocol          KError = 23
+	ErrInvalidGroupId                     KError = 24
+	ErrUnknownMemberId                    KError = 25
+	ErrInvalidSessionTimeout              KError = 26
+	ErrRebalanceInProgress                KError = 27
+	ErrInvalidCommitOffsetSize            KError = 28
+	ErrTopicAuthorizationFailed           KError = 29
+	ErrGroupAuthorizationFailed           KError = 30
+	ErrClusterAuthorizationFailed         KError = 31
+	ErrUnsupportedSASLMechanism           KError = 33
+	ErrIllegalSASLState                   KError = 34
+	ErrUnsupportedVersion                 KError = 35
+	ErrTopicAlreadyExists                 KError = 36
+	ErrInvalidPartitions                  KError = 37
+	ErrInvalidReplicationFactor           KError = 38
+	ErrInvalidReplicaAssignment           KError = 39
+	ErrInvalidConfig                      KError = 40
+	ErrNotController                      KError = 41
+	ErrInvalidRequest                     KError = 42
+	ErrPolicyViolation                    KError = 44
+	ErrOutOfOrderSequenceNumber           KError = 45
+	ErrDuplicateSequenceNumber            KError = 46
+	ErrInvalidProducerEpoch               KError = 47
+	ErrInvalidTxnState                    KError = 48
+	ErrInvalidProducerIDMapping           KError = 49
+	ErrInvalidTransactionTimeout          KError = 50
+	ErrConcurrentTransactions             KError = 51
+	ErrTransactionCoordinatorFenced       KError = 52
+	ErrTransactionalIDAuthorizationFailed KError = 53
+	ErrSecurityDisabled                   KError = 54
+	ErrSASLAuthenticationFailed           KError = 58
+	ErrUnknownProducerID                  KError = 59
+	ErrFetchSessionIDNotFound             KError = 70
+	ErrInvalidFetchSessionEpoch           KError = 71
+	ErrUnsupportedCompressionType         KError = 76
+	ErrInvalidRecord                      KError = 87
+	ErrUnknownSubscriptionId              KError = 117
+	ErrTelemetryTooLarge                  KError = 118
 )
 
 func (err KError) Error() string {
@@ -173,7 +292,80 @@ func (err KError) Error() string {
 		return "kafka server: The client is not authorized to access this group."
 	case ErrClusterAuthorizationFailed:
 		return "kafka server: The client is not authorized to send this request type."
+	case ErrUnsupportedSASLMechanism:
+		return "kafka server: The broker does not support the requested SASL mechanism."
+	case ErrIllegalSASLState:
+		return "kafka server: Request is not valid given the current SASL state."
+	case ErrUnsupportedVersion:
+		return "kafka server: The version of API is not supported."
+	case ErrTopicAlreadyExists:
+		return "kafka server: Topic with this name already exists."
+	case ErrInvalidPartitions:
+		return "kafka server: Number of partitions is invalid."
+	case ErrInvalidReplicationFactor:
+		return "kafka server: Replication factor is invalid."
+	case ErrInvalidReplicaAssignment:
+		return "kafka server: Replica assignment is invalid."
+	case ErrInvalidConfig:
+		return "kafka server: Configuration is invalid."
+	case ErrNotController:
+		return "kafka server: This is not the correct controller for this cluster."
+	case ErrInvalidRequest:
+		return "kafka server: The request is malformed or not supported by the broker."
+	case ErrPolicyViolation:
+		return "kafka server: Request parameters do not satisfy the configured policy."
+	case ErrOutOfOrderSequenceNumber:
+		return "kafka server: The broker received an out of order sequence number."
+	case ErrDuplicateSequenceNumber:
+		return "kafka server: The broker received a duplicate sequence number."
+	case ErrInvalidProducerEpoch:
+		return "kafka server: Producer attempted an operation with an old epoch."
+	case ErrInvalidTxnState:
+		return "kafka server: The producer attempted a transactional operation in an invalid state."
+	case ErrInvalidProducerIDMapping:
+		return "kafka server: The producer attempted to use a producer id which is not currently assigned to its transactional id."
+	case ErrInvalidTransactionTimeout:
+		return "kafka server: The transaction timeout is larger than the maximum value allowed by the broker."
+	case ErrConcurrentTransactions:
+		return "kafka server: The producer attempted to update a transaction while another concurrent operation on the same transaction was ongoing."
+	case ErrTransactionCoordinatorFenced:
+		return "kafka server: The transaction coordinator sending a WriteTxnMarker is no longer the current coordinator for a given producer."
+	case ErrTransactionalIDAuthorizationFailed:
+		return "kafka server: Transactional ID authorization failed."
+	case ErrSecurityDisabled:
+		return "kafka server: Security features are disabled."
+	case ErrSASLAuthenticationFailed:
+		return "kafka server: SASL authentication failed."
+	case ErrUnknownProducerID:
+		return "kafka server: The broker could not locate the producer metadata associated with the producer ID."
+	case ErrFetchSessionIDNotFound:
+		return "kafka server: The fetch session ID was not found."
+	case ErrInvalidFetchSessionEpoch:
+		return "kafka server: The fetch session epoch is invalid."
+	case ErrUnsupportedCompressionType:
+		return "kafka server: The requesting client does not support the compression type of given partition."
+	case ErrInvalidRecord:
+		return "kafka server: The record was rejected by the broker."
+	case ErrUnknownSubscriptionId:
+		return "kafka server: The client telemetry subscription is unknown or has changed."
+	case ErrTelemetryTooLarge:
+		return "kafka server: The client telemetry payload is larger than the broker accepts."
 	}
 
 	return fmt.Sprintf("Unknown error, how did this happen? Error code = %d", err)
 }
+
+// Retriable reports whether the broker may not return the error when the
+// request is retried, because it is about the state of the cluster, such as
+// where the leader of a partition is, rather than the request itself. The JVM
+// client retries the same errors.
+func (err KError) Retriable() bool {
+	switch err {
+	case ErrInvalidMessage, ErrUnknownTopicOrPartition, ErrLeaderNotAvailable, ErrNotLeaderForPartition,
+		ErrRequestTimedOut, ErrReplicaNotAvailable, ErrOffsetsLoadInProgress, ErrConsumerCoordinatorNotAvailable,
+		ErrNotCoordinatorForConsumer, ErrNotEnoughReplicas, ErrNotEnoughReplicasAfterAppend, ErrNotController,
+		ErrConcurrentTransactions, ErrFetchSessionIDNotFound, ErrInvalidFetchSessionEpoch:
+		return true
+	}
+	return false
+}
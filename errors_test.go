@@ -0,0 +1,55 @@
+package sarama
+
+import (
+	"net"
+	"testing"
+)
+
+func TestKErrorRetriable(t *testing.T) {
+	for _, kerr := range []KError{ErrLeaderNotAvailable, ErrNotLeaderForPartition, ErrUnknownTopicOrPartition, ErrNotCoordinatorForConsumer} {
+		if !kerr.Retriable() {
+			t.Error("Expected", kerr, "to be retriable")
+		}
+	}
+	for _, kerr := range []KError{ErrNoError, ErrOffsetOutOfRange, ErrTopicAuthorizationFailed, ErrMessageSizeTooLarge} {
+		if kerr.Retriable() {
+			t.Error("Expected", kerr, "not to be retriable")
+		}
+	}
+}
+
+func TestIsRetriable(t *testing.T) {
+	broker := NewBroker("localhost:9092")
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: ErrNotConnected}
+	for err, expected := range map[error]bool{
+		ErrLeaderNotAvailable: true,
+		ErrOffsetOutOfRange:   false,
+		netErr:                true,
+		ErrOutOfBrokers:       false,
+		&ConsumerError{Topic: "my_topic", Err: ErrNotLeaderForPartition}:  true,
+		&ProducerError{Err: newBrokerError(broker, netErr)}:               true,
+		&ConsumerError{Topic: "my_topic", Err: ErrInvalidTopic}:           false,
+		&ConsumerError{Topic: "my_topic", Err: ConfigurationError("bad")}: false,
+	} {
+		if actual := IsRetriable(err); actual != expected {
+			t.Errorf("Expected IsRetriable(%v) to be %v", err, expected)
+		}
+	}
+	if IsRetriable(nil) {
+		t.Error("Expected nil not to be retriable")
+	}
+}
+
+func TestAsKError(t *testing.T) {
+	broker := NewBroker("localhost:9092")
+	err := &ConsumerError{Topic: "my_topic", Partition: 1, Err: newBrokerError(broker, ErrLeaderNotAvailable)}
+	if kerr, ok := AsKError(err); !ok || kerr != ErrLeaderNotAvailable {
+		t.Error("Expected the wrapped KError, got", kerr, ok)
+	}
+	if expected := "kafka: error while consuming my_topic/1: kafka: broker -1 (localhost:9092) failed: " + ErrLeaderNotAvailable.Error(); err.Error() != expected {
+		t.Error("Expected the context of the error in its message, got", err.Error())
+	}
+	if _, ok := AsKError(&ConsumerError{Err: ErrOutOfBrokers}); ok {
+		t.Error("Expected no KError in a client error")
+	}
+}
@@ -5,32 +5,97 @@ type fetchRequestBlock struct {
 	maxBytes    int32
 }
 
-func (f *fetchRequestBlock) encode(pe packetEncoder) error {
+func (f *fetchRequestBlock) encode(pe packetEncoder, version int16) error {
+	if version >= 9 {
+		pe.putInt32(-1) // the current leader epoch is not checked for clients
+	}
 	pe.putInt64(f.fetchOffset)
+	if version >= 5 {
+		pe.putInt64(-1) // log start offset is always -1 for clients
+	}
 	pe.putInt32(f.maxBytes)
 	return nil
 }
 
-func (f *fetchRequestBlock) decode(pd packetDecoder) (err error) {
+func (f *fetchRequestBlock) decode(pd packetDecoder, version int16) (err error) {
+	if version >= 9 {
+		if _, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
 	if f.fetchOffset, err = pd.getInt64(); err != nil {
 		return err
 	}
+	if version >= 5 {
+		if _, err = pd.getInt64(); err != nil {
+			return err
+		}
+	}
 	if f.maxBytes, err = pd.getInt32(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// IsolationLevel is what a fetch of version 4 returns of the messages of
+// transactional producers.
+type IsolationLevel int8
+
+const (
+	// ReadUncommitted returns every message, including those of aborted and
+	// ongoing transactions.
+	ReadUncommitted IsolationLevel = iota
+	// ReadCommitted only returns messages up to the first ongoing transaction,
+	// the last stable offset, with the list of the aborted transactions among
+	// them, whose messages the consumer skips.
+	ReadCommitted
+)
+
 type FetchRequest struct {
 	MaxWaitTime int32
 	MinBytes    int32
-	blocks      map[string]map[int32]*fetchRequestBlock
+	// MaxBytes bounds the size of the whole response, from version 3. The
+	// first message is returned whatever its size, so that fetching makes
+	// progress.
+	MaxBytes int32
+	// Version can be 0, 1 for Kafka 0.9 and later, in which case the response
+	// includes the time the request was throttled by quotas, 2 for 0.10, whose
+	// messages have timestamps, 3 for 0.10.1, which adds MaxBytes, 4 for 0.11,
+	// whose responses hold record batches, 5 or 6 for 1.0, whose responses
+	// hold the log start offsets of the partitions, 7 for 1.1, which adds
+	// fetch sessions, 8 for 2.0, 9 or 10 for 2.1, or 11 for 2.3, which adds
+	// the RackID.
+	Version int16
+	// Isolation requires version 4 unless it is ReadUncommitted.
+	Isolation IsolationLevel
+	// SessionID and SessionEpoch, from version 7, identify the fetch session
+	// (KIP-227) the request is part of. An epoch of 0 asks the broker to
+	// create a session, and one of -1 fetches outside any session. Within a
+	// session, the request only holds the partitions added to it or whose
+	// offset or maximum size changed, and the forgotten ones it drops.
+	SessionID    int32
+	SessionEpoch int32
+	// RackID, from version 11, is the rack of the consumer, which the leader
+	// may answer with a replica in the same rack to fetch from (KIP-392).
+	RackID    string
+	blocks    map[string]map[int32]*fetchRequestBlock
+	forgotten map[string][]int32
 }
 
 func (f *FetchRequest) encode(pe packetEncoder) (err error) {
 	pe.putInt32(-1) // replica ID is always -1 for clients
 	pe.putInt32(f.MaxWaitTime)
 	pe.putInt32(f.MinBytes)
+	if f.Version >= 3 {
+		pe.putInt32(f.MaxBytes)
+	}
+	if f.Version >= 4 {
+		pe.putInt8(int8(f.Isolation))
+	}
+	if f.Version >= 7 {
+		pe.putInt32(f.SessionID)
+		pe.putInt32(f.SessionEpoch)
+	}
 	err = pe.putArrayLength(len(f.blocks))
 	if err != nil {
 		return err
@@ -46,12 +111,30 @@ func (f *FetchRequest) encode(pe packetEncoder) (err error) {
 		}
 		for partition, block := range blocks {
 			pe.putInt32(partition)
-			err = block.encode(pe)
+			err = block.encode(pe, f.Version)
 			if err != nil {
 				return err
 			}
 		}
 	}
+	if f.Version >= 7 {
+		if err = pe.putArrayLength(len(f.forgotten)); err != nil {
+			return err
+		}
+		for topic, partitions := range f.forgotten {
+			if err = pe.putString(topic); err != nil {
+				return err
+			}
+			if err = pe.putInt32Array(partitions); err != nil {
+				return err
+			}
+		}
+	}
+	if f.Version >= 11 {
+		if err = pe.putString(f.RackID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -65,14 +148,33 @@ func (f *FetchRequest) decode(pd packetDecoder) (err error) {
 	if f.MinBytes, err = pd.getInt32(); err != nil {
 		return err
 	}
+	if f.Version >= 3 {
+		if f.MaxBytes, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+	if f.Version >= 4 {
+		isolation, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		f.Isolation = IsolationLevel(isolation)
+	}
+	if f.Version >= 7 {
+		if f.SessionID, err = pd.getInt32(); err != nil {
+			return err
+		}
+		if f.SessionEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
 	topicCount, err := pd.getArrayLength()
 	if err != nil {
 		return err
 	}
-	if topicCount == 0 {
-		return nil
+	if topicCount > 0 {
+		f.blocks = make(map[string]map[int32]*fetchRequestBlock)
 	}
-	f.blocks = make(map[string]map[int32]*fetchRequestBlock)
 	for i := 0; i < topicCount; i++ {
 		topic, err := pd.getString()
 		if err != nil {
@@ -89,12 +191,36 @@ func (f *FetchRequest) decode(pd packetDecoder) (err error) {
 				return err
 			}
 			fetchBlock := &fetchRequestBlock{}
-			if err = fetchBlock.decode(pd); err != nil {
-				return nil
+			if err = fetchBlock.decode(pd, f.Version); err != nil {
+				return err
 			}
 			f.blocks[topic][partition] = fetchBlock
 		}
 	}
+	if f.Version < 7 {
+		return nil
+	}
+	forgottenCount, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if forgottenCount > 0 {
+		f.forgotten = make(map[string][]int32)
+	}
+	for i := 0; i < forgottenCount; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		if f.forgotten[topic], err = pd.getInt32Array(); err != nil {
+			return err
+		}
+	}
+	if f.Version >= 11 {
+		if f.RackID, err = pd.getString(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -103,9 +229,24 @@ func (f *FetchRequest) key() int16 {
 }
 
 func (f *FetchRequest) version() int16 {
+	return f.Version
+}
+
+func (f *FetchRequest) minVersion() int16 {
+	switch {
+	case f.SessionEpoch > 0 || len(f.forgotten) > 0:
+		// an incremental fetch means nothing outside its session
+		return 7
+	case f.Isolation != ReadUncommitted:
+		return 4
+	}
 	return 0
 }
 
+func (f *FetchRequest) setVersion(version int16) {
+	f.Version = version
+}
+
 func (f *FetchRequest) AddBlock(topic string, partitionID int32, fetchOffset int64, maxBytes int32) {
 	if f.blocks == nil {
 		f.blocks = make(map[string]map[int32]*fetchRequestBlock)
@@ -121,3 +262,12 @@ func (f *FetchRequest) AddBlock(topic string, partitionID int32, fetchOffset int
 
 	f.blocks[topic][partitionID] = tmp
 }
+
+// AddForgotten adds a partition for the broker to drop from the fetch session
+// of the request.
+func (f *FetchRequest) AddForgotten(topic string, partitionID int32) {
+	if f.forgotten == nil {
+		f.forgotten = make(map[string][]int32)
+	}
+	f.forgotten[topic] = append(f.forgotten[topic], partitionID)
+}
@@ -17,6 +17,45 @@ var (
 		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
 		0x00, 0x00, 0x00, 0x01,
 		0x00, 0x00, 0x00, 0x12, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, 0x00, 0x00, 0x00, 0x56}
+
+	fetchRequestReadCommittedV4 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x10, 0x00, // MaxBytes
+		0x01, // ReadCommitted
+		0x00, 0x00, 0x00, 0x00}
+
+	fetchRequestIncrementalV7 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x10, 0x00, // MaxBytes
+		0x00,                   // ReadUncommitted
+		0x00, 0x00, 0x00, 0x0A, // SessionID
+		0x00, 0x00, 0x00, 0x02, // SessionEpoch
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x12, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // log start offset
+		0x00, 0x00, 0x00, 0x56,
+		0x00, 0x00, 0x00, 0x01, // forgotten topics
+		0x00, 0x04, 'g', 'o', 'n', 'e',
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03}
+
+	fetchRequestRackV11 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x10, 0x00, // MaxBytes
+		0x00,                   // ReadUncommitted
+		0x00, 0x00, 0x00, 0x00, // SessionID
+		0x00, 0x00, 0x00, 0x00, // SessionEpoch
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x12,
+		0xFF, 0xFF, 0xFF, 0xFF, // current leader epoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // log start offset
+		0x00, 0x00, 0x00, 0x56,
+		0x00, 0x00, 0x00, 0x00, // forgotten topics
+		0x00, 0x04, 'r', 'a', 'c', 'k'}
 )
 
 func TestFetchRequest(t *testing.T) {
@@ -31,4 +70,36 @@ func TestFetchRequest(t *testing.T) {
 	request.MinBytes = 0
 	request.AddBlock("topic", 0x12, 0x34, 0x56)
 	testRequest(t, "one block", request, fetchRequestOneBlock)
+
+	request.Version = 1
+	testRequest(t, "one block v1", request, fetchRequestOneBlock)
+}
+
+func TestFetchRequestRack(t *testing.T) {
+	request := &FetchRequest{Version: 11, MaxBytes: 0x1000, RackID: "rack"}
+	request.AddBlock("topic", 0x12, 0x34, 0x56)
+	testRequest(t, "rack", request, fetchRequestRackV11)
+}
+
+func TestFetchRequestIsolation(t *testing.T) {
+	request := &FetchRequest{Version: 4, MaxBytes: 0x1000, Isolation: ReadCommitted}
+	testRequest(t, "read committed", request, fetchRequestReadCommittedV4)
+
+	if request.minVersion() != 4 {
+		t.Error("Expected a read committed fetch not to be downgraded below version 4, got", request.minVersion())
+	}
+}
+
+func TestFetchRequestSession(t *testing.T) {
+	request := &FetchRequest{Version: 7, MaxBytes: 0x1000, SessionID: 0x0A, SessionEpoch: 2}
+	request.AddBlock("topic", 0x12, 0x34, 0x56)
+	request.AddForgotten("gone", 3)
+	testRequest(t, "incremental", request, fetchRequestIncrementalV7)
+
+	if request.minVersion() != 7 {
+		t.Error("Expected an incremental fetch not to be downgraded below version 7, got", request.minVersion())
+	}
+	if request := (&FetchRequest{Version: 7}); request.minVersion() != 0 {
+		t.Error("Expected a fetch creating a session to be downgradable, got", request.minVersion())
+	}
 }
@@ -1,12 +1,41 @@
 package sarama
 
+import "time"
+
+// AbortedTransaction is a transaction of a transactional producer that was
+// aborted, from the given offset of the partition on.
+type AbortedTransaction struct {
+	ProducerID  int64
+	FirstOffset int64
+}
+
 type FetchResponseBlock struct {
 	Err                 KError
 	HighWaterMarkOffset int64
-	MsgSet              MessageSet
+	// LastStableOffset and AbortedTransactions, from version 4, are the offset
+	// below which all transactions are decided, and the aborted transactions
+	// among the messages returned.
+	LastStableOffset    int64
+	AbortedTransactions []*AbortedTransaction
+	// LogStartOffset, from version 5, is the offset of the oldest message the
+	// partition keeps.
+	LogStartOffset int64
+	// PreferredReadReplica, from version 11, is the ID of the replica the
+	// leader would rather the consumer fetched from, given its rack, in which
+	// case the block holds no messages, or -1.
+	PreferredReadReplica int32
+	// MsgSet holds the messages returned in the legacy message formats, and
+	// RecordBatches those in record batches, which only responses of version 4
+	// and later hold. A partition whose log was written in both holds the legacy
+	// messages first.
+	MsgSet        MessageSet
+	RecordBatches []*RecordBatch
+	// PartialTrailingBatch is whether the records ended with an incomplete
+	// record batch, as MsgSet.PartialTrailingMessage is for messages.
+	PartialTrailingBatch bool
 }
 
-func (pr *FetchResponseBlock) decode(pd packetDecoder) (err error) {
+func (pr *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -18,6 +47,41 @@ func (pr *FetchResponseBlock) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
+	if version >= 4 {
+		if pr.LastStableOffset, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if version >= 5 {
+			if pr.LogStartOffset, err = pd.getInt64(); err != nil {
+				return err
+			}
+		}
+		n, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			pr.AbortedTransactions = make([]*AbortedTransaction, n)
+		}
+		for i := range pr.AbortedTransactions {
+			txn := new(AbortedTransaction)
+			if txn.ProducerID, err = pd.getInt64(); err != nil {
+				return err
+			}
+			if txn.FirstOffset, err = pd.getInt64(); err != nil {
+				return err
+			}
+			pr.AbortedTransactions[i] = txn
+		}
+	}
+
+	pr.PreferredReadReplica = -1
+	if version >= 11 {
+		if pr.PreferredReadReplica, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
 	msgSetSize, err := pd.getInt32()
 	if err != nil {
 		return err
@@ -27,34 +91,161 @@ func (pr *FetchResponseBlock) decode(pd packetDecoder) (err error) {
 	if err != nil {
 		return err
 	}
-	err = (&pr.MsgSet).decode(msgSetDecoder)
+	return pr.decodeRecords(msgSetDecoder)
+}
+
+// decodeRecords decodes the messages and record batches of the block, telling
+// them apart by their magic byte, which both have at the same position.
+func (pr *FetchResponseBlock) decodeRecords(pd packetDecoder) error {
+	pr.MsgSet.Messages = nil
+	pr.RecordBatches = nil
 
-	return err
+	for pd.remaining() > 0 {
+		version, err := pd.peekInt8(recordBatchMagicOffset)
+		if err == ErrInsufficientData {
+			pr.MsgSet.PartialTrailingMessage = true
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if version < recordBatchVersion {
+			msb := new(MessageBlock)
+			switch err := msb.decode(pd); err {
+			case nil:
+				pr.MsgSet.Messages = append(pr.MsgSet.Messages, msb)
+			case ErrInsufficientData:
+				// As an optimization the server is allowed to return a partial message at the
+				// end of the message set. Clients should handle this case. So we just ignore such things.
+				pr.MsgSet.PartialTrailingMessage = true
+				return nil
+			default:
+				return err
+			}
+			continue
+		}
+
+		batch := new(RecordBatch)
+		switch err := batch.decode(pd); err {
+		case nil:
+			pr.RecordBatches = append(pr.RecordBatches, batch)
+		case ErrInsufficientData:
+			// batches can be cut short as messages can
+			pr.PartialTrailingBatch = true
+			return nil
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// decompressedSize returns the number of bytes that the compressed messages and
+// record batches of the block decompressed to.
+func (pr *FetchResponseBlock) decompressedSize() int64 {
+	size := pr.MsgSet.decompressedSize()
+	for _, batch := range pr.RecordBatches {
+		if batch.Codec != CompressionNone {
+			size += int64(batch.decompressedSize)
+		}
+	}
+	return size
+}
+
+// empty returns whether the block holds no messages or record batches,
+// complete or not.
+func (pr *FetchResponseBlock) empty() bool {
+	return len(pr.MsgSet.Messages) == 0 && len(pr.RecordBatches) == 0
+}
+
+// partial returns whether the block ended with an incomplete message or record
+// batch.
+func (pr *FetchResponseBlock) partial() bool {
+	return pr.MsgSet.PartialTrailingMessage || pr.PartialTrailingBatch
 }
 
 type FetchResponse struct {
 	Blocks map[string]map[int32]*FetchResponseBlock
+	// Version must be set to the version of the request before decoding.
+	Version int16
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota (version 1 and later).
+	ThrottleTime time.Duration
+	// Err and SessionID, from version 7, are the error of the request as a
+	// whole, such as an unknown or stale fetch session, and the ID of the
+	// fetch session, which is 0 if the broker didn't create or keep one. A
+	// response in a session only holds the partitions that changed.
+	Err       KError
+	SessionID int32
 }
 
-func (pr *FetchResponseBlock) encode(pe packetEncoder) (err error) {
+func (pr *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(int16(pr.Err))
 
 	pe.putInt64(pr.HighWaterMarkOffset)
 
+	if version >= 4 {
+		pe.putInt64(pr.LastStableOffset)
+		if version >= 5 {
+			pe.putInt64(pr.LogStartOffset)
+		}
+		if err = pe.putArrayLength(len(pr.AbortedTransactions)); err != nil {
+			return err
+		}
+		for _, txn := range pr.AbortedTransactions {
+			pe.putInt64(txn.ProducerID)
+			pe.putInt64(txn.FirstOffset)
+		}
+	}
+
+	if version >= 11 {
+		pe.putInt32(pr.PreferredReadReplica)
+	}
+
 	pe.push(&lengthField{})
 	err = pr.MsgSet.encode(pe)
 	if err != nil {
 		return err
 	}
+	for _, batch := range pr.RecordBatches {
+		if err = batch.encode(pe); err != nil {
+			return err
+		}
+	}
 	return pe.pop()
 }
 
+func (fr *FetchResponse) setVersion(version int16) {
+	fr.Version = version
+}
+
 func (fr *FetchResponse) decode(pd packetDecoder) (err error) {
+	if fr.Version >= 1 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		fr.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
+	if fr.Version >= 7 {
+		kerr, err := pd.getInt16()
+		if err != nil {
+			return err
+		}
+		fr.Err = KError(kerr)
+		if fr.SessionID, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
 	numTopics, err := pd.getArrayLength()
 	if err != nil {
 		return err
 	}
 
+	var decompressed int64
+
 	fr.Blocks = make(map[string]map[int32]*FetchResponseBlock, numTopics)
 	for i := 0; i < numTopics; i++ {
 		name, err := pd.getString()
@@ -76,11 +267,16 @@ func (fr *FetchResponse) decode(pd packetDecoder) (err error) {
 			}
 
 			block := new(FetchResponseBlock)
-			err = block.decode(pd)
+			err = block.decode(pd, fr.Version)
 			if err != nil {
 				return err
 			}
 			fr.Blocks[name][id] = block
+
+			decompressed += block.decompressedSize()
+			if MaxDecompressedResponseSize > 0 && decompressed > int64(MaxDecompressedResponseSize) {
+				return ErrDecompressedSizeExceeded
+			}
 		}
 	}
 
@@ -88,6 +284,15 @@ func (fr *FetchResponse) decode(pd packetDecoder) (err error) {
 }
 
 func (fr *FetchResponse) encode(pe packetEncoder) (err error) {
+	if fr.Version >= 1 {
+		pe.putInt32(int32(fr.ThrottleTime / time.Millisecond))
+	}
+
+	if fr.Version >= 7 {
+		pe.putInt16(int16(fr.Err))
+		pe.putInt32(fr.SessionID)
+	}
+
 	err = pe.putArrayLength(len(fr.Blocks))
 	if err != nil {
 		return err
@@ -106,7 +311,7 @@ func (fr *FetchResponse) encode(pe packetEncoder) (err error) {
 
 		for id, block := range partitions {
 			pe.putInt32(id)
-			err = block.encode(pe)
+			err = block.encode(pe, fr.Version)
 			if err != nil {
 				return err
 			}
@@ -129,6 +334,10 @@ func (fr *FetchResponse) GetBlock(topic string, partition int32) *FetchResponseB
 }
 
 func (fr *FetchResponse) AddError(topic string, partition int32, err KError) {
+	fr.getOrCreateBlock(topic, partition).Err = err
+}
+
+func (fr *FetchResponse) getOrCreateBlock(topic string, partition int32) *FetchResponseBlock {
 	if fr.Blocks == nil {
 		fr.Blocks = make(map[string]map[int32]*FetchResponseBlock)
 	}
@@ -139,26 +348,14 @@ func (fr *FetchResponse) AddError(topic string, partition int32, err KError) {
 	}
 	frb, ok := partitions[partition]
 	if !ok {
-		frb = new(FetchResponseBlock)
+		frb = &FetchResponseBlock{PreferredReadReplica: -1}
 		partitions[partition] = frb
 	}
-	frb.Err = err
+	return frb
 }
 
 func (fr *FetchResponse) AddMessage(topic string, partition int32, key, value Encoder, offset int64) {
-	if fr.Blocks == nil {
-		fr.Blocks = make(map[string]map[int32]*FetchResponseBlock)
-	}
-	partitions, ok := fr.Blocks[topic]
-	if !ok {
-		partitions = make(map[int32]*FetchResponseBlock)
-		fr.Blocks[topic] = partitions
-	}
-	frb, ok := partitions[partition]
-	if !ok {
-		frb = new(FetchResponseBlock)
-		partitions[partition] = frb
-	}
+	frb := fr.getOrCreateBlock(topic, partition)
 	var kb []byte
 	var vb []byte
 	if key != nil {
@@ -171,3 +368,28 @@ func (fr *FetchResponse) AddMessage(topic string, partition int32, key, value En
 	msgBlock := &MessageBlock{Msg: msg, Offset: offset}
 	frb.MsgSet.Messages = append(frb.MsgSet.Messages, msgBlock)
 }
+
+// AddRecord appends a record at the given offset to the last record batch of the
+// partition, which is created if there is none.
+func (fr *FetchResponse) AddRecord(topic string, partition int32, key, value Encoder, offset int64) {
+	frb := fr.getOrCreateBlock(topic, partition)
+	if len(frb.RecordBatches) == 0 {
+		batch := newRecordBatch(CompressionNone)
+		batch.FirstOffset = offset
+		frb.RecordBatches = append(frb.RecordBatches, batch)
+	}
+	batch := frb.RecordBatches[len(frb.RecordBatches)-1]
+	var kb []byte
+	var vb []byte
+	if key != nil {
+		kb, _ = key.Encode()
+	}
+	if value != nil {
+		vb, _ = value.Encode()
+	}
+	record := &Record{Key: kb, Value: vb, OffsetDelta: offset - batch.FirstOffset}
+	batch.Records = append(batch.Records, record)
+	if record.OffsetDelta > int64(batch.LastOffsetDelta) {
+		batch.LastOffsetDelta = int32(record.OffsetDelta)
+	}
+}
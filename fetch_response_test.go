@@ -3,6 +3,7 @@ package sarama
 import (
 	"bytes"
 	"testing"
+	"time"
 )
 
 var (
@@ -82,3 +83,153 @@ func TestOneMessageFetchResponse(t *testing.T) {
 		t.Error("Decoding produced incorrect message value.")
 	}
 }
+
+func TestFetchResponseV1(t *testing.T) {
+	response := FetchResponse{Version: 1}
+	testDecodable(t, "throttled", &response, []byte{
+		0x00, 0x00, 0x00, 0x64,
+		0x00, 0x00, 0x00, 0x00})
+	if response.ThrottleTime != 100*time.Millisecond {
+		t.Error("Decoding produced a throttle time of", response.ThrottleTime, "instead of 100ms")
+	}
+	if len(response.Blocks) != 0 {
+		t.Error("Decoding produced topic blocks where there were none.")
+	}
+}
+
+func TestFetchResponseV4(t *testing.T) {
+	response := &FetchResponse{Version: 4}
+	response.AddMessage("topic", 0, nil, StringEncoder("legacy"), 5)
+	response.AddRecord("topic", 0, StringEncoder("key"), StringEncoder("value"), 6)
+	response.AddRecord("topic", 0, nil, StringEncoder("value"), 7)
+	block := response.GetBlock("topic", 0)
+	block.LastStableOffset = 7
+	block.AbortedTransactions = []*AbortedTransaction{{ProducerID: 3, FirstOffset: 2}}
+
+	encoded, err := encode(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := &FetchResponse{Version: 4}
+	testDecodable(t, "v4", decoded, encoded)
+	block = decoded.GetBlock("topic", 0)
+	if block == nil {
+		t.Fatal("Decoding did not produce a block for topic/0")
+	}
+	if block.LastStableOffset != 7 || len(block.AbortedTransactions) != 1 || block.AbortedTransactions[0].ProducerID != 3 {
+		t.Error("Decoding produced last stable offset", block.LastStableOffset, "and aborted transactions", block.AbortedTransactions)
+	}
+	if len(block.MsgSet.Messages) != 1 || string(block.MsgSet.Messages[0].Msg.Value) != "legacy" {
+		t.Fatal("Decoding did not produce the legacy message")
+	}
+	if len(block.RecordBatches) != 1 || len(block.RecordBatches[0].Records) != 2 {
+		t.Fatal("Decoding did not produce a batch of 2 records")
+	}
+	batch := block.RecordBatches[0]
+	if batch.FirstOffset != 6 || batch.LastOffset() != 7 || string(batch.Records[0].Key) != "key" {
+		t.Error("Decoding produced a batch from offset", batch.FirstOffset, "to", batch.LastOffset())
+	}
+	if block.partial() {
+		t.Error("Decoding detected a partial trailing batch where there wasn't one.")
+	}
+}
+
+func TestFetchResponseV7(t *testing.T) {
+	response := FetchResponse{Version: 7}
+	testDecodable(t, "session", &response, []byte{
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime
+		0x00, 0x00, // Err
+		0x00, 0x00, 0x00, 0x0A, // SessionID
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x05,
+		0x00, 0x00, // Err
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, // HighWaterMarkOffset
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, // LastStableOffset
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // LogStartOffset
+		0x00, 0x00, 0x00, 0x00, // AbortedTransactions
+		0x00, 0x00, 0x00, 0x00})
+	if response.Err != ErrNoError || response.SessionID != 0x0A {
+		t.Error("Decoding produced error", response.Err, "and session", response.SessionID)
+	}
+	block := response.GetBlock("topic", 5)
+	if block == nil {
+		t.Fatal("Decoding did not produce a block for topic/5")
+	}
+	if block.HighWaterMarkOffset != 0x10 || block.LogStartOffset != 2 {
+		t.Error("Decoding produced high water mark", block.HighWaterMarkOffset, "and log start offset", block.LogStartOffset)
+	}
+	if block.PreferredReadReplica != -1 {
+		t.Error("Expected no preferred read replica before version 11, got", block.PreferredReadReplica)
+	}
+
+	response = FetchResponse{Version: 7}
+	testDecodable(t, "evicted session", &response, []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x46,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00})
+	if response.Err != ErrFetchSessionIDNotFound {
+		t.Error("Decoding produced error", response.Err, "instead of ErrFetchSessionIDNotFound")
+	}
+}
+
+func TestFetchResponseV11(t *testing.T) {
+	response := FetchResponse{Version: 11}
+	testDecodable(t, "preferred read replica", &response, []byte{
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime
+		0x00, 0x00, // Err
+		0x00, 0x00, 0x00, 0x00, // SessionID
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x05,
+		0x00, 0x00, // Err
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, // HighWaterMarkOffset
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, // LastStableOffset
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // LogStartOffset
+		0x00, 0x00, 0x00, 0x00, // AbortedTransactions
+		0x00, 0x00, 0x00, 0x03, // PreferredReadReplica
+		0x00, 0x00, 0x00, 0x00})
+	block := response.GetBlock("topic", 5)
+	if block == nil {
+		t.Fatal("Decoding did not produce a block for topic/5")
+	}
+	if block.PreferredReadReplica != 3 || !block.empty() {
+		t.Error("Decoding produced preferred read replica", block.PreferredReadReplica)
+	}
+}
+
+func TestFetchResponsePartialTrailingBatch(t *testing.T) {
+	response := &FetchResponse{Version: 4}
+	response.AddRecord("topic", 0, nil, StringEncoder("value"), 0)
+	batch, err := encode(response.GetBlock("topic", 0).RecordBatches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, records := range [][]byte{batch[:len(batch)-3], append(append([]byte(nil), batch...), batch[:20]...)} {
+		buf := []byte{
+			0x00, 0x00, 0x00, 0x01,
+			0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+			0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+			0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0x00, byte(len(records))}
+		buf = append(append([]byte{0x00, 0x00, 0x00, 0x00}, buf...), records...)
+
+		decoded := &FetchResponse{Version: 4}
+		testDecodable(t, "partial", decoded, buf)
+		block := decoded.GetBlock("topic", 0)
+		if block == nil || !block.partial() {
+			t.Fatal("Decoding did not detect the partial trailing batch")
+		}
+		if complete := len(records) > len(batch); complete != (len(block.RecordBatches) == 1) {
+			t.Error("Decoding produced", len(block.RecordBatches), "complete batches")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package sarama
+
+import "math"
+
+// fetchSession is the fetch session (KIP-227) of a brokerConsumer with its
+// broker. Once the broker has created it, fetches only hold the partitions
+// that were added to the session or whose fetch offset or size changed, and
+// forget those that were dropped; the broker only answers for the partitions
+// with news. A session the broker evicts is replaced by a new one, by a fetch
+// for every partition.
+type fetchSession struct {
+	id    int32
+	epoch int32
+	// sent holds the fetch offset and size the broker last got for each
+	// partition of the session
+	sent map[string]map[int32]fetchRequestBlock
+}
+
+func (s *fetchSession) reset() {
+	s.id, s.epoch, s.sent = 0, 0, nil
+}
+
+// addBlocks adds the partitions of the subscriptions to request, asking the
+// broker to create a session if there is none.
+func (s *fetchSession) addBlocks(request *FetchRequest, subscriptions map[*partitionConsumer]none) {
+	request.SessionID, request.SessionEpoch = s.id, s.epoch
+
+	subscribed := make(map[string]map[int32]bool)
+	for child := range subscriptions {
+		if subscribed[child.topic] == nil {
+			subscribed[child.topic] = make(map[int32]bool)
+		}
+		subscribed[child.topic][child.partition] = true
+
+		sent, ok := s.sent[child.topic][child.partition]
+		if s.id == 0 || !ok || sent.fetchOffset != child.offset || sent.maxBytes != child.fetchSize {
+			request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize)
+		}
+	}
+
+	if s.id == 0 {
+		return
+	}
+	for topic, partitions := range s.sent {
+		for partition := range partitions {
+			if !subscribed[topic][partition] {
+				request.AddForgotten(topic, partition)
+			}
+		}
+	}
+}
+
+// update follows the session through the successful response to request. The
+// partitions of the session the response of an incremental fetch leaves out
+// are added to it without messages, as they have nothing new.
+func (s *fetchSession) update(request *FetchRequest, response *FetchResponse) {
+	if response.SessionID == 0 {
+		// the broker didn't create a session, or closed it
+		s.reset()
+		return
+	}
+
+	if request.SessionID != response.SessionID {
+		s.id, s.epoch = response.SessionID, 0
+		s.sent = make(map[string]map[int32]fetchRequestBlock)
+	}
+	if s.epoch == math.MaxInt32 {
+		s.epoch = 1
+	} else {
+		s.epoch++
+	}
+
+	for topic, partitions := range request.forgotten {
+		for _, partition := range partitions {
+			delete(s.sent[topic], partition)
+		}
+		if len(s.sent[topic]) == 0 {
+			delete(s.sent, topic)
+		}
+	}
+	for topic, blocks := range request.blocks {
+		if s.sent[topic] == nil {
+			s.sent[topic] = make(map[int32]fetchRequestBlock)
+		}
+		for partition, block := range blocks {
+			s.sent[topic][partition] = *block
+		}
+	}
+
+	if request.SessionID == response.SessionID {
+		for topic, partitions := range s.sent {
+			for partition := range partitions {
+				response.getOrCreateBlock(topic, partition)
+			}
+		}
+	}
+}
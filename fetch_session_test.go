@@ -0,0 +1,53 @@
+package sarama
+
+import "testing"
+
+func TestFetchSession(t *testing.T) {
+	first := &partitionConsumer{topic: "my_topic", partition: 0, offset: 5, fetchSize: 100}
+	second := &partitionConsumer{topic: "my_topic", partition: 1, offset: 7, fetchSize: 100}
+	subscriptions := map[*partitionConsumer]none{first: {}, second: {}}
+	var session fetchSession
+
+	request := &FetchRequest{Version: 7}
+	session.addBlocks(request, subscriptions)
+	if request.SessionID != 0 || request.SessionEpoch != 0 || len(request.blocks["my_topic"]) != 2 {
+		t.Fatal("Expected a full fetch creating a session, got", request.SessionID, request.SessionEpoch, request.blocks)
+	}
+	response := &FetchResponse{Version: 7, SessionID: 3}
+	response.AddError("my_topic", 0, ErrNoError)
+	response.AddError("my_topic", 1, ErrNoError)
+	session.update(request, response)
+
+	// only the partition that moved on is fetched again
+	first.offset = 6
+	request = &FetchRequest{Version: 7}
+	session.addBlocks(request, subscriptions)
+	if request.SessionID != 3 || request.SessionEpoch != 1 || len(request.blocks["my_topic"]) != 1 || request.blocks["my_topic"][0] == nil {
+		t.Fatal("Expected an incremental fetch of partition 0, got", request.SessionID, request.SessionEpoch, request.blocks)
+	}
+	response = &FetchResponse{Version: 7, SessionID: 3}
+	session.update(request, response)
+	if response.GetBlock("my_topic", 0) == nil || response.GetBlock("my_topic", 1) == nil {
+		t.Error("Expected the partitions left out of the response to be added without messages")
+	}
+
+	// dropped partitions are forgotten
+	delete(subscriptions, second)
+	request = &FetchRequest{Version: 7}
+	session.addBlocks(request, subscriptions)
+	if request.SessionEpoch != 2 || len(request.blocks) != 0 || len(request.forgotten["my_topic"]) != 1 || request.forgotten["my_topic"][0] != 1 {
+		t.Fatal("Expected partition 1 to be forgotten, got", request.SessionEpoch, request.blocks, request.forgotten)
+	}
+	session.update(request, &FetchResponse{Version: 7, SessionID: 3})
+	if _, ok := session.sent["my_topic"][1]; ok {
+		t.Error("Expected the forgotten partition to leave the session")
+	}
+
+	// a broker without room for the session doesn't keep one
+	session.update(&FetchRequest{Version: 7, SessionID: 3, SessionEpoch: 3}, &FetchResponse{Version: 7})
+	request = &FetchRequest{Version: 7}
+	session.addBlocks(request, subscriptions)
+	if request.SessionID != 0 || request.SessionEpoch != 0 || len(request.blocks["my_topic"]) != 1 {
+		t.Error("Expected a full fetch once the session is gone, got", request.SessionID, request.SessionEpoch, request.blocks)
+	}
+}
@@ -0,0 +1,64 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+)
+
+// dumpHead returns the hex encoding of the first n bytes of the frame made up of
+// buffers, followed by "..." if the frame is longer.
+func dumpHead(buffers net.Buffers, n int) string {
+	head := make([]byte, 0, n)
+	total := 0
+	for _, buf := range buffers {
+		total += len(buf)
+		if room := n - len(head); room > 0 {
+			if len(buf) > room {
+				buf = buf[:room]
+			}
+			head = append(head, buf...)
+		}
+	}
+
+	dump := hex.EncodeToString(head)
+	if total > len(head) {
+		dump += "..."
+	}
+	return dump
+}
+
+// dumpRequestFrame logs a request frame, as encoded by request.encode.
+func (b *Broker) dumpRequestFrame(req *request, frame net.Buffers) {
+	LogProtocol.info("request frame",
+		"broker", b.id,
+		"addr", b.addr,
+		"correlationID", req.correlationID,
+		"apiKey", req.body.key(),
+		"apiVersion", req.body.version(),
+		"length", frameLength(frame),
+		"payload", dumpHead(frame, b.conf.Net.Debug.MaxDumpBytes),
+	)
+}
+
+// dumpResponseFrame logs a response frame: the 8 bytes of length and correlation
+// ID read first, and the rest of the response.
+func (b *Broker) dumpResponseFrame(promise responsePromise, header, body []byte) {
+	LogProtocol.info("response frame",
+		"broker", b.id,
+		"addr", b.addr,
+		"correlationID", int32(binary.BigEndian.Uint32(header[4:])),
+		"apiKey", promise.apiKey,
+		"apiVersion", promise.apiVersion,
+		"length", len(header)+len(body),
+		"payload", dumpHead(net.Buffers{header, body}, b.conf.Net.Debug.MaxDumpBytes),
+	)
+}
+
+func frameLength(frame net.Buffers) int {
+	length := 0
+	for _, buf := range frame {
+		length += len(buf)
+	}
+	return length
+}
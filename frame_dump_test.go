@@ -0,0 +1,62 @@
+package sarama
+
+import (
+	"net"
+	"testing"
+)
+
+func TestDumpHead(t *testing.T) {
+	frame := net.Buffers{{0x00, 0x01}, {0x02, 0x03, 0x04}}
+
+	if dump := dumpHead(frame, 3); dump != "000102..." {
+		t.Error("Expected a truncated dump, got", dump)
+	}
+	if dump := dumpHead(frame, 16); dump != "0001020304" {
+		t.Error("Expected the whole frame, got", dump)
+	}
+	if dump := dumpHead(frame, 0); dump != "..." {
+		t.Error("Expected only the truncation marker, got", dump)
+	}
+}
+
+func TestBrokerDumpsFrames(t *testing.T) {
+	logger := new(recordingLogger)
+	SetSubsystemLogger(LogProtocol, logger, LogLevelInfo)
+	defer ResetSubsystemLogger(LogProtocol)
+
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.Returns(new(MetadataResponse))
+
+	config := NewConfig()
+	config.Net.Debug.DumpFrames = true
+	config.Net.Debug.MaxDumpBytes = 8
+
+	broker := NewBroker(mb.Addr())
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.Close(); err != nil {
+		t.Error(err)
+	}
+
+	events := logger.recorded()
+	if len(events) != 2 {
+		t.Fatal("Expected a request and a response frame, got", events)
+	}
+
+	request, response := events[0], events[1]
+	if request.msg != "request frame" || request.field("apiKey") != int16(3) || request.field("correlationID") != int32(0) {
+		t.Error("Unexpected request frame", request)
+	}
+	// length, API key 3, version 0
+	if payload := request.field("payload").(string); payload[8:] != "00030000..." {
+		t.Error("Unexpected request payload", payload)
+	}
+	if response.msg != "response frame" || response.field("apiKey") != int16(3) || response.field("correlationID") != int32(0) {
+		t.Error("Unexpected response frame", response)
+	}
+}
@@ -0,0 +1,61 @@
+package sarama
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFuncClusterAdminTopics(t *testing.T) {
+	checkKafkaVersion(t, "0.11.0")
+	setupFunctionalTest(t)
+	defer teardownFunctionalTest(t)
+
+	config := NewConfig()
+	config.Version = V0_11_0_0
+	admin, err := NewClusterAdmin(kafkaBrokers, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	topic := fmt.Sprintf("sarama.TestFuncClusterAdminTopics.%d", time.Now().UnixNano())
+	retention := "3600000"
+	detail := &TopicDetail{
+		NumPartitions:     2,
+		ReplicationFactor: 3,
+		ConfigEntries:     map[string]*string{"retention.ms": &retention},
+	}
+	if err := admin.CreateTopic(topic, detail, true); err != nil {
+		t.Fatal("Expected the topic to be valid, got", err)
+	}
+	if err := admin.CreateTopic(topic, detail, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.CreateTopic(topic, detail, false); err != ErrTopicAlreadyExists {
+		t.Error("Expected creating the topic again to fail, got", err)
+	}
+
+	expectRetention := func(expected string) {
+		entries, err := admin.DescribeConfig(ConfigResource{Type: TopicResource, Name: topic, ConfigNames: []string{"retention.ms"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Value == nil || *entries[0].Value != expected {
+			t.Error("Expected the retention of the topic to be", expected, "got", entries)
+		}
+	}
+	expectRetention(retention)
+	retention = "7200000"
+	if err := admin.AlterConfig(TopicResource, topic, map[string]*string{"retention.ms": &retention}, false); err != nil {
+		t.Fatal(err)
+	}
+	expectRetention(retention)
+
+	if err := admin.DeleteTopic(topic); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.DeleteTopic(topic); err != ErrUnknownTopicOrPartition {
+		t.Error("Expected deleting the topic again to fail, got", err)
+	}
+}
@@ -22,6 +22,28 @@ func TestFuncConnectionFailure(t *testing.T) {
 	}
 }
 
+func TestFuncClientReadTimeout(t *testing.T) {
+	setupFunctionalTest(t)
+	defer teardownFunctionalTest(t)
+
+	config := NewConfig()
+	config.Net.ReadTimeout = 100 * time.Millisecond
+	config.Metadata.Retry.Max = 0
+	client, err := NewClient(kafkaBrokers, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, px := range KafkaProxies {
+		addLatency(t, px, 500*time.Millisecond, 0)
+	}
+	if err := client.RefreshMetadata(); err != ErrOutOfBrokers {
+		t.Error("Expected ErrOutOfBrokers once every broker timed out, got", err)
+	}
+
+	safeClose(t, client)
+}
+
 func TestFuncClientMetadata(t *testing.T) {
 	setupFunctionalTest(t)
 	defer teardownFunctionalTest(t)
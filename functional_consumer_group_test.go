@@ -0,0 +1,109 @@
+package sarama
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFuncConsumerGroup(t *testing.T) {
+	checkKafkaVersion(t, "0.9.0")
+	setupFunctionalTest(t)
+	defer teardownFunctionalTest(t)
+
+	group := fmt.Sprintf("sarama.TestFuncConsumerGroup.%d", time.Now().UnixNano())
+	config := NewConfig()
+	config.Version = V0_9_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = NewManualPartitioner
+	config.Consumer.Offsets.Initial = OffsetNewest
+	config.Consumer.Group.Heartbeat.Interval = 100 * time.Millisecond
+	config.Consumer.Group.Return.Notifications = true
+
+	first, err := NewConsumerGroup(kafkaBrokers, group, []string{"test.4"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, first)
+	expectConsumerGroupNotification(t, first, map[string][]int32{"test.4": {0, 1, 2, 3}})
+	var claims []ConsumerGroupClaim
+	for i := 0; i < 4; i++ {
+		claims = append(claims, expectConsumerGroupClaim(t, first))
+	}
+
+	producer, err := NewSyncProducer(kafkaBrokers, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, claim := range claims {
+		_, offset, err := producer.SendMessage(&ProducerMessage{Topic: "test.4", Partition: claim.Partition(), Value: StringEncoder("value")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		claim.MarkOffset(expectConsumerGroupMessage(t, claim, offset).Offset, "")
+	}
+	safeClose(t, producer)
+
+	second, err := NewConsumerGroup(kafkaBrokers, group, []string{"test.4"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, second)
+	claimed := make(map[int32]bool)
+	for _, member := range []ConsumerGroup{first, second} {
+		n := expectConsumerGroupRebalance(t, member)
+		if len(n.Current["test.4"]) != 2 {
+			t.Error("Expected the members to share the partitions, got", n.Current)
+		}
+		for _, partition := range n.Current["test.4"] {
+			claimed[partition] = true
+		}
+	}
+	if len(claimed) != 4 {
+		t.Error("Expected every partition to be claimed once, got", claimed)
+	}
+	for _, claim := range claims {
+		for _ = range claim.Messages() {
+		}
+	}
+
+	// the offsets marked were committed as the first member released its claims
+	client, err := NewClient(kafkaBrokers, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := &OffsetFetchRequest{Version: 1, ConsumerGroup: group}
+	for _, claim := range claims {
+		request.AddPartition("test.4", claim.Partition())
+	}
+	response, err := coordinator.FetchOffset(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, claim := range claims {
+		if block := response.GetBlock("test.4", claim.Partition()); block == nil || block.Offset < 0 {
+			t.Error("Expected an offset to be committed for partition", claim.Partition(), "got", block)
+		}
+	}
+}
+
+// expectConsumerGroupRebalance returns the next RebalanceOK notification of the
+// member, which the rebalances of real clusters may take a few seconds to reach.
+func expectConsumerGroupRebalance(t *testing.T, cg ConsumerGroup) *ConsumerGroupNotification {
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case n := <-cg.Notifications():
+			if n.Type == RebalanceOK {
+				return n
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for the member to rebalance")
+		}
+	}
+}
@@ -92,6 +92,74 @@ func TestFuncProducingToInvalidTopic(t *testing.T) {
 	safeClose(t, producer)
 }
 
+func TestFuncProducingAfterConnectionReset(t *testing.T) {
+	setupFunctionalTest(t)
+	defer teardownFunctionalTest(t)
+
+	config := NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Backoff = 250 * time.Millisecond
+	client, err := NewClient(kafkaBrokers, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err := NewSyncProducerFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := producer.SendMessage(&ProducerMessage{Topic: "test.1", Value: StringEncoder("before")}); err != nil {
+		t.Fatal(err)
+	}
+
+	leader, err := client.Leader("test.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetConnections(t, brokerProxy(t, leader))
+
+	if _, _, err := producer.SendMessage(&ProducerMessage{Topic: "test.1", Value: StringEncoder("after")}); err != nil {
+		t.Error("Expected the message to be retried on a new connection, got", err)
+	}
+
+	safeClose(t, producer)
+	safeClose(t, client)
+}
+
+func TestFuncProducingThroughPartition(t *testing.T) {
+	setupFunctionalTest(t)
+	defer teardownFunctionalTest(t)
+
+	config := NewConfig()
+	config.Net.ReadTimeout = 500 * time.Millisecond
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 10
+	config.Producer.Retry.Backoff = 250 * time.Millisecond
+	client, err := NewClient(kafkaBrokers, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer, err := NewSyncProducerFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leader, err := client.Leader("test.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heal := partitionProxy(t, brokerProxy(t, leader))
+	healing := time.AfterFunc(2*time.Second, heal)
+	defer healing.Stop()
+
+	if _, _, err := producer.SendMessage(&ProducerMessage{Topic: "test.1", Value: StringEncoder("partitioned")}); err != nil {
+		t.Error("Expected the message to be produced once the partition healed, got", err)
+	}
+
+	safeClose(t, producer)
+	safeClose(t, client)
+}
+
 func testProducingMessages(t *testing.T, config *Config) {
 	setupFunctionalTest(t)
 	defer teardownFunctionalTest(t)
@@ -0,0 +1,56 @@
+package sarama
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// The brokers authenticate the user of these tests on their SASL listeners,
+// which the docker cluster of vagrant/docker_test.sh exposes in KAFKA_SASL_PEERS.
+
+func checkKafkaSASL(t *testing.T) []string {
+	peers := os.Getenv("KAFKA_SASL_PEERS")
+	if peers == "" {
+		t.Skip("No KAFKA_SASL_PEERS set. Skipping...")
+	}
+	return strings.Split(peers, ",")
+}
+
+func testFuncSASL(t *testing.T, mechanism SASLMechanism, version KafkaVersion) {
+	brokers := checkKafkaSASL(t)
+	setupFunctionalTest(t)
+	defer teardownFunctionalTest(t)
+
+	config := NewConfig()
+	config.Version = version
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Mechanism = mechanism
+	config.Net.SASL.User = "sarama"
+	config.Net.SASL.Password = "sarama-secret"
+	client, err := NewClient(brokers, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partitions, err := client.Partitions("test.4"); err != nil || len(partitions) != 4 {
+		t.Error("Expected the metadata of test.4 once authenticated, got", partitions, err)
+	}
+	safeClose(t, client)
+
+	config.Net.SASL.Password = "wrong"
+	config.Metadata.Retry.Max = 0
+	if client, err := NewClient(brokers, config); err == nil {
+		t.Error("Expected a wrong password to be refused by every broker")
+		safeClose(t, client)
+	}
+}
+
+func TestFuncSASLPlain(t *testing.T) {
+	checkKafkaVersion(t, "0.10.0")
+	testFuncSASL(t, SASLTypePlaintext, V0_10_0_0)
+}
+
+func TestFuncSASLSCRAM(t *testing.T) {
+	checkKafkaVersion(t, "0.10.2")
+	testFuncSASL(t, SASLTypeSCRAMSHA256, V0_10_2_0)
+}
@@ -106,6 +106,63 @@ func SaveProxy(t *testing.T, px string) {
 	}
 }
 
+// Network faults between the tests and the brokers are injected with toxiproxy, on
+// the proxy with the given name. They are undone by teardownFunctionalTest.
+
+// brokerProxy returns the name of the proxy the given broker is reached through.
+func brokerProxy(t testing.TB, broker *Broker) string {
+	_, port, err := net.SplitHostPort(broker.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, proxy := range Proxies {
+		if strings.HasSuffix(proxy.Listen, ":"+port) {
+			return name
+		}
+	}
+	t.Fatalf("No proxy for broker %s", broker.Addr())
+	return ""
+}
+
+// addLatency delays everything the proxied broker sends by latency, give or take jitter.
+func addLatency(t testing.TB, px string, latency, jitter time.Duration) {
+	attrs := toxiproxy.Attributes{
+		"latency": int(latency / time.Millisecond),
+		"jitter":  int(jitter / time.Millisecond),
+	}
+	if _, err := Proxies[px].AddToxic("", "latency", "downstream", 1, attrs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// resetConnections closes all open connections through the proxy, which keeps
+// accepting new ones.
+func resetConnections(t testing.TB, px string) {
+	if err := Proxies[px].Disable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Proxies[px].Enable(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// partitionProxy stops all traffic through the proxy without closing its
+// connections, as a network partition would, until the returned function is called.
+func partitionProxy(t testing.TB, px string) (heal func()) {
+	for _, stream := range []string{"upstream", "downstream"} {
+		if _, err := Proxies[px].AddToxic("partition_"+stream, "timeout", stream, 1, toxiproxy.Attributes{"timeout": 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for _, stream := range []string{"upstream", "downstream"} {
+			if err := Proxies[px].RemoveToxic("partition_" + stream); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
 func setupFunctionalTest(t testing.TB) {
 	checkKafkaAvailability(t)
 	resetProxies(t)
@@ -0,0 +1,183 @@
+//go:build go1.18
+// +build go1.18
+
+package sarama
+
+import (
+	"bytes"
+	"testing"
+)
+
+// The fuzz targets below feed arbitrary bytes to the decoders of what Sarama reads
+// from the network: responses and, for the mock broker, requests. Decoding may fail,
+// but must neither panic nor allocate much more than the input's size, which the
+// fuzzer reports as running out of memory. Run them with, for example,
+//
+//	go test -run '^$' -fuzz FuzzDecodeResponse
+//
+// The fixtures of the unit tests are the seed corpus.
+
+// fuzzedResponses allocates the response types, of the given version for the
+// versioned ones, indexed by the fuzzed kind.
+var fuzzedResponses = []func(version int16) decoder{
+	func(v int16) decoder { return &ProduceResponse{Version: v} },
+	func(v int16) decoder { return &FetchResponse{Version: v} },
+	func(v int16) decoder { return &OffsetResponse{Version: v} },
+	func(v int16) decoder { return &MetadataResponse{Version: v} },
+	func(v int16) decoder { return new(OffsetCommitResponse) },
+	func(v int16) decoder { return &OffsetFetchResponse{Version: v} },
+	func(v int16) decoder { return &ConsumerMetadataResponse{Version: v} },
+	func(v int16) decoder { return new(JoinGroupResponse) },
+	func(v int16) decoder { return new(HeartbeatResponse) },
+	func(v int16) decoder { return new(LeaveGroupResponse) },
+	func(v int16) decoder { return new(SyncGroupResponse) },
+	func(v int16) decoder { return new(DescribeGroupsResponse) },
+	func(v int16) decoder { return new(ListGroupsResponse) },
+	func(v int16) decoder { return new(GetTelemetrySubscriptionsResponse) },
+	func(v int16) decoder { return new(PushTelemetryResponse) },
+	func(v int16) decoder { return new(ApiVersionsResponse) },
+	func(v int16) decoder { return new(SaslHandshakeResponse) },
+	func(v int16) decoder { return new(SaslAuthenticateResponse) },
+	func(v int16) decoder { return &CreateTopicsResponse{Version: v} },
+	func(v int16) decoder { return &DeleteTopicsResponse{Version: v} },
+	func(v int16) decoder { return new(CreatePartitionsResponse) },
+	func(v int16) decoder { return new(DeleteRecordsResponse) },
+	func(v int16) decoder { return new(DescribeConfigsResponse) },
+	func(v int16) decoder { return new(AlterConfigsResponse) },
+	func(v int16) decoder { return new(CreateAclsResponse) },
+	func(v int16) decoder { return new(DescribeAclsResponse) },
+	func(v int16) decoder { return new(DeleteAclsResponse) },
+	func(v int16) decoder { return new(InitProducerIDResponse) },
+	func(v int16) decoder { return new(AddPartitionsToTxnResponse) },
+	func(v int16) decoder { return new(AddOffsetsToTxnResponse) },
+	func(v int16) decoder { return new(EndTxnResponse) },
+	func(v int16) decoder { return new(TxnOffsetCommitResponse) },
+	func(v int16) decoder { return new(ConsumerGroupMemberMetadata) },
+	func(v int16) decoder { return new(ConsumerGroupMemberAssignment) },
+	func(v int16) decoder { return new(responseHeader) },
+}
+
+func FuzzDecodeResponse(f *testing.F) {
+	seeds := [][][]byte{
+		{produceResponseNoBlocks, produceResponseManyBlocks},
+		{emptyFetchResponse, oneMessageFetchResponse},
+		{emptyOffsetResponse, normalOffsetResponse},
+		{emptyMetadataResponse, brokersNoTopicsMetadataResponse, topicsNoBrokersMetadataResponse},
+		{emptyOffsetCommitResponse},
+		{emptyOffsetFetchResponse},
+		{consumerMetadataResponseError, consumerMetadataResponseSuccess},
+		{joinGroupResponseNoError, joinGroupResponseWithError, joinGroupResponseLeader},
+		{heartbeatResponseNoError},
+		{leaveGroupResponseWithError},
+		{syncGroupResponseNoError, syncGroupResponseWithError},
+		{describeGroupsResponseEmpty, describeGroupsResponsePopulated},
+		{listGroupsResponseEmpty, listGroupsResponseError, listGroupsResponseWithConsumer},
+		{getTelemetrySubscriptionsResponseNoMetrics, getTelemetrySubscriptionsResponseMetrics},
+		{pushTelemetryResponseTooLarge},
+		{apiVersionsResponse},
+		{saslHandshakeResponse},
+		{saslAuthenticateResponseNoError, saslAuthenticateResponseFailed},
+		{createTopicsResponseV0, createTopicsResponseV1, createTopicsResponseV2},
+		{deleteTopicsResponseV0, deleteTopicsResponseV1},
+		{createPartitionsResponse},
+		{deleteRecordsResponse},
+		{describeConfigsResponse},
+		{alterConfigsResponse},
+		{createAclsResponse},
+		{describeAclsResponse},
+		{deleteAclsResponse},
+		{initProducerIDResponse, initProducerIDResponseError},
+		{addPartitionsToTxnResponse},
+		{addOffsetsToTxnResponse},
+		{endTxnResponse},
+		{txnOffsetCommitResponse},
+		{groupMemberMetadata},
+		{groupMemberAssignment},
+		{},
+	}
+	for kind, fixtures := range seeds {
+		for _, fixture := range fixtures {
+			f.Add(uint8(kind), int16(0), fixture)
+			f.Add(uint8(kind), int16(1), fixture)
+		}
+	}
+	addGeneratedFetchResponses(f, 1)
+
+	f.Fuzz(func(t *testing.T, kind uint8, version int16, buf []byte) {
+		response := fuzzedResponses[int(kind)%len(fuzzedResponses)](version)
+		_ = decode(buf, response)
+	})
+}
+
+// addGeneratedFetchResponses seeds the fetch responses of the kind with those
+// of the versions that hold record batches, with headers and a transaction
+// aborted by a control batch.
+func addGeneratedFetchResponses(f *testing.F, kind uint8) {
+	for _, version := range []int16{4, 5, 11} {
+		for _, codec := range []CompressionCodec{CompressionNone, CompressionGZIP, CompressionSnappy} {
+			g := NewMessageGenerator(int64(codec))
+			g.Keys, g.ValueSize, g.Headers = 3, 20, 2
+
+			txn := g.RecordBatch(3, codec)
+			txn.FirstOffset, txn.Transactional, txn.ProducerID, txn.ProducerEpoch = 5, true, 7, 0
+			abort := g.ControlBatch(ControlRecordAbort, 7)
+			abort.FirstOffset = 8
+
+			response := &FetchResponse{Version: version}
+			block := response.getOrCreateBlock("my_topic", 0)
+			block.HighWaterMarkOffset, block.LastStableOffset = 9, 9
+			block.AbortedTransactions = []*AbortedTransaction{{ProducerID: 7, FirstOffset: 5}}
+			block.RecordBatches = []*RecordBatch{g.RecordBatch(5, codec), txn, abort}
+			buf, err := encode(response)
+			if err != nil {
+				f.Fatal(err)
+			}
+			f.Add(kind, version, buf)
+		}
+	}
+}
+
+func FuzzDecodeRequest(f *testing.F) {
+	bodies := []requestBody{
+		&ProduceRequest{},
+		&FetchRequest{},
+		&MetadataRequest{Topics: []string{"my_topic"}},
+		&OffsetCommitRequest{Version: 2, ConsumerGroup: "my_group"},
+		&JoinGroupRequest{GroupId: "my_group"},
+		&GetTelemetrySubscriptionsRequest{},
+	}
+	for _, body := range bodies {
+		buf, err := encode(&request{correlationID: 1, clientID: "sarama", body: body})
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf)
+	}
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		_, _ = decodeRequest(bytes.NewReader(buf))
+	})
+}
+
+func FuzzDecodeMessageSet(f *testing.F) {
+	for _, codec := range []CompressionCodec{CompressionNone, CompressionGZIP, CompressionSnappy} {
+		set := new(MessageSet)
+		set.addMessage(&Message{Codec: codec, Key: []byte("key"), Value: []byte("value")})
+		buf, err := encode(set)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf)
+
+		g := NewMessageGenerator(int64(codec))
+		g.Keys, g.ValueSize = 3, 20
+		if buf, err = g.EncodedMessageSet(5, codec); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf)
+	}
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		_ = decode(buf, new(MessageSet))
+	})
+}
@@ -0,0 +1,34 @@
+package sarama
+
+// GetTelemetrySubscriptionsRequest asks a broker which client metrics it wants
+// pushed, and how often (KIP-714). A zero ClientInstanceId asks the broker to
+// assign one.
+type GetTelemetrySubscriptionsRequest struct {
+	ClientInstanceId Uuid
+}
+
+func (r *GetTelemetrySubscriptionsRequest) encode(pe packetEncoder) error {
+	if err := putUuid(pe, r.ClientInstanceId); err != nil {
+		return err
+	}
+	return putEmptyTaggedFields(pe)
+}
+
+func (r *GetTelemetrySubscriptionsRequest) decode(pd packetDecoder) (err error) {
+	if r.ClientInstanceId, err = getUuid(pd); err != nil {
+		return err
+	}
+	return skipTaggedFields(pd)
+}
+
+func (r *GetTelemetrySubscriptionsRequest) key() int16 {
+	return 71
+}
+
+func (r *GetTelemetrySubscriptionsRequest) version() int16 {
+	return 0
+}
+
+func (r *GetTelemetrySubscriptionsRequest) flexible() bool {
+	return true
+}
@@ -0,0 +1,23 @@
+package sarama
+
+import "testing"
+
+var (
+	getTelemetrySubscriptionsRequestEmpty = []byte{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // Client instance ID
+		0, // Tagged fields
+	}
+
+	getTelemetrySubscriptionsRequestInstance = []byte{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // Client instance ID
+		0, // Tagged fields
+	}
+)
+
+func TestGetTelemetrySubscriptionsRequest(t *testing.T) {
+	request := new(GetTelemetrySubscriptionsRequest)
+	testRequest(t, "no instance", request, getTelemetrySubscriptionsRequestEmpty)
+
+	request.ClientInstanceId = Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	testRequest(t, "instance", request, getTelemetrySubscriptionsRequestInstance)
+}
@@ -0,0 +1,105 @@
+package sarama
+
+import "time"
+
+// GetTelemetrySubscriptionsResponse is the subscription a broker returns to a
+// GetTelemetrySubscriptionsRequest.
+type GetTelemetrySubscriptionsResponse struct {
+	ThrottleTime     time.Duration
+	Err              KError
+	ClientInstanceId Uuid
+	// SubscriptionId identifies this subscription in the PushTelemetryRequests
+	// that follow it.
+	SubscriptionId int32
+	// AcceptedCompressionTypes are the CompressionCodecs the broker accepts for
+	// pushed metrics, in order of preference.
+	AcceptedCompressionTypes []CompressionCodec
+	PushInterval             time.Duration
+	TelemetryMaxBytes        int32
+	// DeltaTemporality is true if sums are to be pushed as the change since the
+	// previous push rather than as cumulative values.
+	DeltaTemporality bool
+	// RequestedMetrics are the prefixes of the names of the metrics to push. A
+	// single empty prefix requests all metrics, and none requests no metrics.
+	RequestedMetrics []string
+}
+
+func (r *GetTelemetrySubscriptionsResponse) encode(pe packetEncoder) error {
+	if err := putEmptyTaggedFields(pe); err != nil { // response header
+		return err
+	}
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.Err))
+	if err := putUuid(pe, r.ClientInstanceId); err != nil {
+		return err
+	}
+	pe.putInt32(r.SubscriptionId)
+	if err := putCompactArrayLength(pe, len(r.AcceptedCompressionTypes)); err != nil {
+		return err
+	}
+	for _, codec := range r.AcceptedCompressionTypes {
+		pe.putInt8(int8(codec))
+	}
+	pe.putInt32(int32(r.PushInterval / time.Millisecond))
+	pe.putInt32(r.TelemetryMaxBytes)
+	putBool(pe, r.DeltaTemporality)
+	if err := putCompactStringArray(pe, r.RequestedMetrics); err != nil {
+		return err
+	}
+	return putEmptyTaggedFields(pe)
+}
+
+func (r *GetTelemetrySubscriptionsResponse) decode(pd packetDecoder) (err error) {
+	if err = skipTaggedFields(pd); err != nil { // response header
+		return err
+	}
+
+	throttle, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttle) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	if r.ClientInstanceId, err = getUuid(pd); err != nil {
+		return err
+	}
+	if r.SubscriptionId, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	n, err := getCompactArrayLength(pd)
+	if err != nil {
+		return err
+	}
+	r.AcceptedCompressionTypes = nil
+	for i := 0; i < n; i++ {
+		codec, err := pd.getInt8()
+		if err != nil {
+			return err
+		}
+		r.AcceptedCompressionTypes = append(r.AcceptedCompressionTypes, CompressionCodec(codec))
+	}
+
+	interval, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.PushInterval = time.Duration(interval) * time.Millisecond
+
+	if r.TelemetryMaxBytes, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.DeltaTemporality, err = getBool(pd); err != nil {
+		return err
+	}
+	if r.RequestedMetrics, err = getCompactStringArray(pd); err != nil {
+		return err
+	}
+	return skipTaggedFields(pd)
+}
@@ -0,0 +1,61 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	getTelemetrySubscriptionsResponseNoMetrics = []byte{
+		0,          // Header tagged fields
+		0, 0, 0, 0, // Throttle time
+		0, 0, // No error
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // Client instance ID
+		0, 0, 0, 1, // Subscription ID
+		1,                // No accepted compression types
+		0, 0, 0x75, 0x30, // Push interval
+		0, 0, 0x10, 0, // Telemetry max bytes
+		0, // Cumulative temporality
+		1, // No requested metrics
+		0, // Tagged fields
+	}
+
+	getTelemetrySubscriptionsResponseMetrics = []byte{
+		0,          // Header tagged fields
+		0, 0, 0, 5, // Throttle time
+		0, 0, // No error
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // Client instance ID
+		0, 0, 0, 2, // Subscription ID
+		3, 4, 1, // Accepted compression types
+		0, 0, 0x03, 0xe8, // Push interval
+		0, 0, 0x10, 0, // Telemetry max bytes
+		1,                                                       // Delta temporality
+		2, 11, 'o', 'r', 'g', '.', 'a', 'p', 'a', 'c', 'h', 'e', // Requested metrics
+		1, 0x2a, 3, 'x', 'y', 'z', // Tagged field to skip
+	}
+)
+
+func TestGetTelemetrySubscriptionsResponse(t *testing.T) {
+	response := new(GetTelemetrySubscriptionsResponse)
+	testDecodable(t, "no metrics", response, getTelemetrySubscriptionsResponseNoMetrics)
+	if response.Err != ErrNoError || response.SubscriptionId != 1 || response.PushInterval != 30*time.Second || response.TelemetryMaxBytes != 4096 {
+		t.Error("Decoding failed", response)
+	}
+	if response.ClientInstanceId[15] != 15 || len(response.AcceptedCompressionTypes) != 0 || len(response.RequestedMetrics) != 0 || response.DeltaTemporality {
+		t.Error("Decoding failed", response)
+	}
+	testResponse(t, "no metrics", response, getTelemetrySubscriptionsResponseNoMetrics)
+
+	response = new(GetTelemetrySubscriptionsResponse)
+	testDecodable(t, "metrics", response, getTelemetrySubscriptionsResponseMetrics)
+	if response.ThrottleTime != 5*time.Millisecond || response.SubscriptionId != 2 || response.PushInterval != time.Second || !response.DeltaTemporality {
+		t.Error("Decoding failed", response)
+	}
+	if !reflect.DeepEqual(response.AcceptedCompressionTypes, []CompressionCodec{CompressionZSTD, CompressionGZIP}) {
+		t.Error("Unexpected accepted compression types", response.AcceptedCompressionTypes)
+	}
+	if !reflect.DeepEqual(response.RequestedMetrics, []string{"org.apache"}) {
+		t.Error("Unexpected requested metrics", response.RequestedMetrics)
+	}
+}
@@ -0,0 +1,53 @@
+package sarama
+
+import (
+	"bytes"
+	"compress/gzip"
+)
+
+func gzipEncode(src []byte, level int) ([]byte, error) {
+	buf := getScratch()
+
+	var writer *gzip.Writer
+	pooled := level == CompressionLevelDefault || level == 0
+	if pooled {
+		writer = gzipWriterPool.Get().(*gzip.Writer)
+		writer.Reset(buf)
+	} else {
+		var err error
+		if writer, err = gzip.NewWriterLevel(buf, level); err != nil {
+			putScratch(buf)
+			return nil, err
+		}
+	}
+
+	_, err := writer.Write(src)
+	if err == nil {
+		err = writer.Close()
+	}
+	if pooled {
+		gzipWriterPool.Put(writer)
+	}
+	if err != nil {
+		putScratch(buf)
+		return nil, err
+	}
+	return copyScratch(buf), nil
+}
+
+func gzipDecode(src []byte, limit int64) ([]byte, error) {
+	var reader *gzip.Reader
+	var err error
+	if pooled, ok := gzipReaderPool.Get().(*gzip.Reader); ok {
+		reader = pooled
+		err = reader.Reset(bytes.NewReader(src))
+	} else {
+		reader, err = gzip.NewReader(bytes.NewReader(src))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReaderPool.Put(reader)
+
+	return readLimited(reader, limit)
+}
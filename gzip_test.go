@@ -0,0 +1,54 @@
+package sarama
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+	"testing"
+)
+
+func TestGzipRoundTrip(t *testing.T) {
+	src := bytes.Repeat([]byte("sarama gzip "), 1000)
+
+	for _, level := range []int{CompressionLevelDefault, gzip.BestSpeed, gzip.BestCompression} {
+		compressed, err := gzipEncode(src, level)
+		if err != nil {
+			t.Fatal(level, err)
+		}
+		decompressed, err := gzipDecode(compressed, maxDecompressedBatchBytes())
+		if err != nil {
+			t.Fatal(level, err)
+		}
+		if !bytes.Equal(src, decompressed) {
+			t.Error("gzip round trip mismatch at level", level)
+		}
+	}
+}
+
+func TestGzipPooledConcurrentUse(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			src := bytes.Repeat([]byte{byte(i)}, 1024*(i+1))
+			for j := 0; j < 20; j++ {
+				compressed, err := gzipEncode(src, CompressionLevelDefault)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				decompressed, err := gzipDecode(compressed, maxDecompressedBatchBytes())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(src, decompressed) {
+					t.Error("gzip round trip mismatch under concurrent use")
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
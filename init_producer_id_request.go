@@ -0,0 +1,44 @@
+package sarama
+
+import "time"
+
+// InitProducerIDRequest acquires a producer ID and epoch, which idempotent and
+// transactional producers attach to their record batches. It requires Kafka
+// 0.11.
+type InitProducerIDRequest struct {
+	// TransactionalID is the transactional ID of a transactional producer, or
+	// nil for an idempotent producer, which may then be sent to any broker.
+	TransactionalID *string
+	// How long the transaction coordinator waits for an update of the
+	// producer's transaction before aborting it. Only millisecond resolution is
+	// sent.
+	TransactionTimeout time.Duration
+}
+
+func (r *InitProducerIDRequest) encode(pe packetEncoder) error {
+	if err := putNullableString(pe, r.TransactionalID); err != nil {
+		return err
+	}
+	pe.putInt32(int32(r.TransactionTimeout / time.Millisecond))
+	return nil
+}
+
+func (r *InitProducerIDRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = getNullableString(pd); err != nil {
+		return err
+	}
+	timeout, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.TransactionTimeout = time.Duration(timeout) * time.Millisecond
+	return nil
+}
+
+func (r *InitProducerIDRequest) key() int16 {
+	return 22
+}
+
+func (r *InitProducerIDRequest) version() int16 {
+	return 0
+}
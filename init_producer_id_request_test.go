@@ -0,0 +1,27 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	initProducerIDRequestIdempotent = []byte{
+		255, 255, // no TransactionalID
+		0, 0, 0, 100, // TransactionTimeout
+	}
+
+	initProducerIDRequestTransactional = []byte{
+		0, 3, 't', 'x', 'n',
+		0, 0, 0xEA, 0x60, // TransactionTimeout
+	}
+)
+
+func TestInitProducerIDRequest(t *testing.T) {
+	request := &InitProducerIDRequest{TransactionTimeout: 100 * time.Millisecond}
+	testRequest(t, "idempotent", request, initProducerIDRequestIdempotent)
+
+	id := "txn"
+	request = &InitProducerIDRequest{TransactionalID: &id, TransactionTimeout: time.Minute}
+	testRequest(t, "transactional", request, initProducerIDRequestTransactional)
+}
@@ -0,0 +1,30 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	initProducerIDResponse = []byte{
+		0, 0, 0, 100, // ThrottleTime
+		0, 0, // ErrNoError
+		0, 0, 0, 0, 0, 0, 0x10, 0x00, // ProducerID
+		0, 3, // ProducerEpoch
+	}
+
+	initProducerIDResponseError = []byte{
+		0, 0, 0, 0,
+		0, 15, // ErrConsumerCoordinatorNotAvailable
+		255, 255, 255, 255, 255, 255, 255, 255,
+		255, 255,
+	}
+)
+
+func TestInitProducerIDResponse(t *testing.T) {
+	response := &InitProducerIDResponse{ThrottleTime: 100 * time.Millisecond, ProducerID: 4096, ProducerEpoch: 3}
+	testResponse(t, "success", response, initProducerIDResponse)
+
+	response = &InitProducerIDResponse{Err: ErrConsumerCoordinatorNotAvailable, ProducerID: -1, ProducerEpoch: -1}
+	testResponse(t, "error", response, initProducerIDResponseError)
+}
@@ -0,0 +1,50 @@
+package sarama
+
+// ProducerInterceptor is called with every message sent to a producer, before
+// it is partitioned, and may change it: to add headers, for example, or to
+// validate it against a schema. Interceptors are set in Producer.Interceptors.
+type ProducerInterceptor interface {
+	// OnSend is called with a message once, when the producer takes it from
+	// its input, and not again when the message is retried. It is called from
+	// the producer's dispatcher, so it must not block.
+	OnSend(*ProducerMessage)
+}
+
+// ConsumerInterceptor is called with every message a consumer returns, before
+// it is returned, and may change it. Interceptors are set in
+// Consumer.Interceptors.
+type ConsumerInterceptor interface {
+	// OnConsume is called with a message before it is sent on the Messages
+	// channel of its PartitionConsumer, or ConsumerGroupClaim.
+	OnConsume(*ConsumerMessage)
+}
+
+// interceptSend calls the producer interceptors with the message. An
+// interceptor that panics is logged, and the message is still produced.
+func interceptSend(interceptors []ProducerInterceptor, msg *ProducerMessage) {
+	for _, interceptor := range interceptors {
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					LogProducer.error("producer interceptor panicked", "topic", msg.Topic, "err", err)
+				}
+			}()
+			interceptor.OnSend(msg)
+		}()
+	}
+}
+
+// interceptConsume calls the consumer interceptors with the message. An
+// interceptor that panics is logged, and the message is still returned.
+func interceptConsume(interceptors []ConsumerInterceptor, msg *ConsumerMessage) {
+	for _, interceptor := range interceptors {
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					LogConsumer.error("consumer interceptor panicked", "topic", msg.Topic, "partition", msg.Partition, "err", err)
+				}
+			}()
+			interceptor.OnConsume(msg)
+		}()
+	}
+}
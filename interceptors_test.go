@@ -0,0 +1,77 @@
+package sarama
+
+import (
+	"sync"
+	"testing"
+)
+
+type headerInterceptor struct{}
+
+func (headerInterceptor) OnSend(msg *ProducerMessage) {
+	msg.Headers = append(msg.Headers, RecordHeader{Key: []byte("trace"), Value: []byte("id")})
+}
+
+type panickingInterceptor struct{}
+
+func (panickingInterceptor) OnSend(*ProducerMessage) { panic("interceptor") }
+
+func (panickingInterceptor) OnConsume(*ConsumerMessage) { panic("interceptor") }
+
+type countingInterceptor struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func (ci *countingInterceptor) OnConsume(msg *ConsumerMessage) {
+	ci.lock.Lock()
+	defer ci.lock.Unlock()
+	ci.counts[msg.Topic]++
+}
+
+func TestInterceptors(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	config := newMockClusterConfig()
+	config.Version = V0_11_0_0
+	config.Producer.Interceptors = []ProducerInterceptor{panickingInterceptor{}, headerInterceptor{}}
+	producer, err := NewSyncProducer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, err := producer.SendMessage(&ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	safeClose(t, producer)
+
+	counter := &countingInterceptor{counts: make(map[string]int)}
+	config = newMockClusterConfig()
+	config.Version = V0_11_0_0
+	config.Consumer.Interceptors = []ConsumerInterceptor{panickingInterceptor{}, counter}
+	consumer, err := NewConsumer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	pc, err := consumer.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, pc)
+
+	for i := int64(0); i < 3; i++ {
+		msg := <-pc.Messages()
+		assertMessageOffset(t, msg, i)
+		if len(msg.Headers) != 1 || string(msg.Headers[0].Key) != "trace" {
+			t.Error("Expected the producer interceptor to add a header, got", msg.Headers)
+		}
+	}
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+	if counter.counts["my_topic"] != 3 {
+		t.Error("Expected the consumer interceptor to see 3 messages, got", counter.counts["my_topic"])
+	}
+}
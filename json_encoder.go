@@ -0,0 +1,44 @@
+package sarama
+
+import "encoding/json"
+
+// JSONEncoder implements the Encoder interface for arbitrary values by marshalling them
+// with encoding/json. The value is marshalled at most once, the first time either Length()
+// or Encode() is called, so it should not be modified after the encoder has been handed
+// to a producer. A marshalling error is returned from Encode(), which the producer reports
+// on its Errors channel.
+type JSONEncoder struct {
+	v       interface{}
+	encoded []byte
+	err     error
+}
+
+// NewJSONEncoder returns an Encoder which produces the JSON encoding of v.
+func NewJSONEncoder(v interface{}) *JSONEncoder {
+	return &JSONEncoder{v: v}
+}
+
+func (je *JSONEncoder) ensureEncoded() {
+	if je.encoded == nil && je.err == nil {
+		je.encoded, je.err = json.Marshal(je.v)
+	}
+}
+
+func (je *JSONEncoder) Encode() ([]byte, error) {
+	je.ensureEncoded()
+	return je.encoded, je.err
+}
+
+func (je *JSONEncoder) Length() int {
+	je.ensureEncoded()
+	return len(je.encoded)
+}
+
+// DecodeJSONValue unmarshals the JSON-encoded value of the message into v, as with
+// json.Unmarshal. A null value cannot be decoded and results in an error.
+func (m *ConsumerMessage) DecodeJSONValue(v interface{}) error {
+	if m.Value == nil {
+		return ErrNullValue
+	}
+	return json.Unmarshal(m.Value, v)
+}
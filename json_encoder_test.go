@@ -0,0 +1,49 @@
+package sarama
+
+import "testing"
+
+type jsonTestRecord struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestJSONEncoder(t *testing.T) {
+	encoder := NewJSONEncoder(jsonTestRecord{Name: "foo", Count: 3})
+
+	if encoder.Length() != len(`{"name":"foo","count":3}`) {
+		t.Error("Length() does not match the encoded value:", encoder.Length())
+	}
+	encoded, err := encoder.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"name":"foo","count":3}` {
+		t.Error("Unexpected encoding:", string(encoded))
+	}
+
+	var decoded jsonTestRecord
+	if err := (&ConsumerMessage{Value: encoded}).DecodeJSONValue(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "foo" || decoded.Count != 3 {
+		t.Error("Unexpected decoded value:", decoded)
+	}
+}
+
+func TestJSONEncoderError(t *testing.T) {
+	encoder := NewJSONEncoder(make(chan int))
+
+	if encoder.Length() != 0 {
+		t.Error("Length() should be zero for an unencodable value")
+	}
+	if _, err := encoder.Encode(); err == nil {
+		t.Error("Expected an error encoding a channel")
+	}
+}
+
+func TestDecodeJSONValueNull(t *testing.T) {
+	var decoded jsonTestRecord
+	if err := (&ConsumerMessage{}).DecodeJSONValue(&decoded); err != ErrNullValue {
+		t.Error("Expected ErrNullValue, got", err)
+	}
+}
@@ -0,0 +1,264 @@
+package sarama
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// PartitionLag is how far a consumer group's committed offset for a partition
+// trails the partition's log-end offset.
+type PartitionLag struct {
+	Topic     string
+	Partition int32
+
+	// Committed is the offset committed by the group, or -1 if it has not
+	// committed one for this partition.
+	Committed int64
+	// LogEnd is the offset the next message produced to the partition will get.
+	LogEnd int64
+	// Lag is LogEnd - Committed, or -1 if the group has not committed an offset.
+	Lag int64
+
+	// UpdatedAt is when the lag was computed.
+	UpdatedAt time.Time
+}
+
+// LagMonitor periodically computes the lag of a consumer group on each partition
+// of a set of topics, every Consumer.Offsets.LagInterval. The lag is available
+// through Lag and Lags, and as a consumer-group-lag gauge per partition in the
+// client's MetricRegistry.
+type LagMonitor interface {
+	// Lag returns the lag on the given partition as of the last computation, and
+	// false if it has not been computed yet.
+	Lag(topic string, partition int32) (PartitionLag, bool)
+
+	// Lags returns the lag on every monitored partition as of the last
+	// computation, ordered by topic and partition.
+	Lags() []PartitionLag
+
+	// Errors returns a read channel of errors that occur while computing the lag,
+	// if Consumer.Return.Errors is enabled; otherwise they are logged. The errors
+	// do not stop the monitor, which tries again at the next interval.
+	Errors() <-chan *ConsumerError
+
+	// Close stops the LagMonitor and unregisters its metrics. It must be called
+	// before the underlying client is closed.
+	Close() error
+}
+
+type lagMonitor struct {
+	client Client
+	conf   *Config
+	group  string
+	topics []string
+
+	lock sync.RWMutex
+	lags map[string]map[int32]PartitionLag
+
+	errors         chan *ConsumerError
+	closing, done  chan none
+	closeOnce      sync.Once
+	registeredLags map[string]none
+}
+
+// NewLagMonitor creates a LagMonitor for the given group and topics, using the
+// given client. It is still necessary to call Close() on the underlying client
+// after closing the monitor.
+func NewLagMonitor(group string, topics []string, client Client) (LagMonitor, error) {
+	// Check that we are not dealing with a closed Client before processing any other arguments
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	conf := client.Config()
+	lm := &lagMonitor{
+		client:         client,
+		conf:           conf,
+		group:          group,
+		topics:         topics,
+		lags:           make(map[string]map[int32]PartitionLag),
+		errors:         make(chan *ConsumerError, conf.ChannelBufferSize),
+		closing:        make(chan none),
+		done:           make(chan none),
+		registeredLags: make(map[string]none),
+	}
+
+	go withRecover(lm.run)
+
+	return lm, nil
+}
+
+func (lm *lagMonitor) Lag(topic string, partition int32) (PartitionLag, bool) {
+	lm.lock.RLock()
+	defer lm.lock.RUnlock()
+
+	lag, ok := lm.lags[topic][partition]
+	return lag, ok
+}
+
+func (lm *lagMonitor) Lags() []PartitionLag {
+	lm.lock.RLock()
+	defer lm.lock.RUnlock()
+
+	var lags []PartitionLag
+	for _, partitions := range lm.lags {
+		for _, lag := range partitions {
+			lags = append(lags, lag)
+		}
+	}
+	sort.Sort(partitionLagSlice(lags))
+	return lags
+}
+
+func (lm *lagMonitor) Errors() <-chan *ConsumerError {
+	return lm.errors
+}
+
+func (lm *lagMonitor) Close() error {
+	lm.closeOnce.Do(func() {
+		close(lm.closing)
+		<-lm.done
+
+		for name := range lm.registeredLags {
+			lm.conf.MetricRegistry.Unregister(name)
+		}
+		close(lm.errors)
+	})
+	return nil
+}
+
+func (lm *lagMonitor) run() {
+	defer close(lm.done)
+
+	ticker := time.NewTicker(lm.conf.Consumer.Offsets.LagInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, topic := range lm.topics {
+			lm.updateTopic(topic)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-lm.closing:
+			return
+		}
+	}
+}
+
+func (lm *lagMonitor) updateTopic(topic string) {
+	partitions, err := lm.client.Partitions(topic)
+	if err != nil {
+		lm.handleError(topic, -1, err)
+		return
+	}
+
+	committed, err := lm.fetchCommittedOffsets(topic, partitions)
+	if err != nil {
+		lm.handleError(topic, -1, err)
+		return
+	}
+
+	for _, partition := range partitions {
+		block := committed.GetBlock(topic, partition)
+		if block == nil {
+			lm.handleError(topic, partition, ErrIncompleteResponse)
+			continue
+		}
+		if block.Err != ErrNoError {
+			lm.handleError(topic, partition, block.Err)
+			continue
+		}
+
+		logEnd, err := lm.client.GetOffset(topic, partition, OffsetNewest)
+		if err != nil {
+			lm.handleError(topic, partition, err)
+			continue
+		}
+
+		lag := PartitionLag{
+			Topic:     topic,
+			Partition: partition,
+			Committed: block.Offset,
+			LogEnd:    logEnd,
+			Lag:       -1,
+			UpdatedAt: time.Now(),
+		}
+		if block.Offset >= 0 {
+			lag.Lag = logEnd - block.Offset
+		}
+		lm.setLag(lag)
+	}
+}
+
+func (lm *lagMonitor) fetchCommittedOffsets(topic string, partitions []int32) (*OffsetFetchResponse, error) {
+	coordinator, err := lm.client.Coordinator(lm.group)
+	if err != nil {
+		return nil, err
+	}
+
+	request := new(OffsetFetchRequest)
+	request.Version = 1
+	request.ConsumerGroup = lm.group
+	for _, partition := range partitions {
+		request.AddPartition(topic, partition)
+	}
+
+	response, err := coordinator.FetchOffset(request)
+	if err != nil {
+		// the coordinator may have moved; look it up again next time
+		_ = lm.client.RefreshCoordinator(lm.group)
+		return nil, err
+	}
+	return response, nil
+}
+
+func (lm *lagMonitor) setLag(lag PartitionLag) {
+	lm.lock.Lock()
+	partitions := lm.lags[lag.Topic]
+	if partitions == nil {
+		partitions = make(map[int32]PartitionLag)
+		lm.lags[lag.Topic] = partitions
+	}
+	partitions[lag.Partition] = lag
+	lm.lock.Unlock()
+
+	name := getMetricNameForGroupPartition("consumer-group-lag", lm.group, lag.Topic, lag.Partition)
+	lm.registeredLags[name] = none{}
+	metrics.GetOrRegisterGauge(name, lm.conf.MetricRegistry).Update(lag.Lag)
+}
+
+func (lm *lagMonitor) handleError(topic string, partition int32, err error) {
+	if err == ErrNotCoordinatorForConsumer {
+		_ = lm.client.RefreshCoordinator(lm.group)
+	}
+
+	cErr := &ConsumerError{
+		Topic:     topic,
+		Partition: partition,
+		Err:       err,
+	}
+
+	if lm.conf.Consumer.Return.Errors {
+		select {
+		case lm.errors <- cErr:
+		case <-lm.closing:
+		}
+	} else {
+		LogGroup.error("failed to compute consumer group lag", "group", lm.group, "topic", topic, "partition", partition, "err", err)
+	}
+}
+
+type partitionLagSlice []PartitionLag
+
+func (s partitionLagSlice) Len() int      { return len(s) }
+func (s partitionLagSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s partitionLagSlice) Less(i, j int) bool {
+	if s[i].Topic != s[j].Topic {
+		return s[i].Topic < s[j].Topic
+	}
+	return s[i].Partition < s[j].Partition
+}
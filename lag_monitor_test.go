@@ -0,0 +1,91 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestLagMonitor(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()).
+			SetLeader("my_topic", 1, broker.BrokerID()),
+		"ConsumerMetadataRequest": NewMockConsumerMetadataResponse(t).
+			SetCoordinator("my_group", broker),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+			SetOffset("my_group", "my_topic", 0, 85, "", ErrNoError).
+			SetOffset("my_group", "my_topic", 1, -1, "", ErrNoError),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 100).
+			SetOffset("my_topic", 1, OffsetNewest, 20),
+	})
+
+	config := NewConfig()
+	config.Consumer.Offsets.LagInterval = 10 * time.Millisecond
+	client, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monitor, err := NewLagMonitor("my_group", []string{"my_topic"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lags []PartitionLag
+	for i := 0; i < 100 && len(lags) < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		lags = monitor.Lags()
+	}
+	if len(lags) != 2 {
+		t.Fatal("Expected the lag of 2 partitions, got", lags)
+	}
+
+	if lag := lags[0]; lag.Partition != 0 || lag.Committed != 85 || lag.LogEnd != 100 || lag.Lag != 15 {
+		t.Error("Unexpected lag on partition 0:", lag)
+	}
+	if lag := lags[1]; lag.Partition != 1 || lag.Committed != -1 || lag.LogEnd != 20 || lag.Lag != -1 {
+		t.Error("Expected an unknown lag on partition 1 without a committed offset, got", lag)
+	}
+	if lag, ok := monitor.Lag("my_topic", 0); !ok || lag.Lag != 15 || lag.UpdatedAt.IsZero() {
+		t.Error("Unexpected lag on partition 0:", lag, ok)
+	}
+	if _, ok := monitor.Lag("my_topic", 2); ok {
+		t.Error("Expected no lag for an unknown partition")
+	}
+
+	name := "consumer-group-lag-for-group-my_group-for-topic-my_topic-partition-0"
+	gauge, ok := config.MetricRegistry.Get(name).(metrics.Gauge)
+	if !ok || gauge.Value() != 15 {
+		t.Error("Expected a", name, "gauge of 15, got", config.MetricRegistry.Get(name))
+	}
+
+	safeClose(t, monitor)
+	if config.MetricRegistry.Get(name) != nil {
+		t.Error("Expected", name, "to be unregistered after closing the monitor")
+	}
+
+	safeClose(t, client)
+	broker.Close()
+}
+
+func TestNewLagMonitorClosedClient(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	seedBroker.Returns(new(MetadataResponse))
+
+	client, err := NewClient([]string{seedBroker.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	safeClose(t, client)
+
+	if _, err := NewLagMonitor("my_group", []string{"my_topic"}, client); err != ErrClosedClient {
+		t.Errorf("Expected ErrClosedClient, got %v", err)
+	}
+
+	seedBroker.Close()
+}
@@ -0,0 +1,82 @@
+package sarama
+
+import "encoding/binary"
+
+// LengthPrefixedEncoder implements the Encoder interface for values made up of several
+// parts, such as an envelope and its payload, by writing each part after its length as
+// a big-endian int32. A nil part, or one whose Encode() returns a nil slice, is written as
+// a length of -1 and no data, so that it can be told apart from an empty part.
+// SplitLengthPrefixed splits the value back up into its parts.
+//
+// Length() is computed from the Length() of the parts, without encoding them. The parts
+// are encoded, and copied into a single buffer, the first time Encode() is called.
+type LengthPrefixedEncoder struct {
+	parts []Encoder
+
+	encoded []byte
+	err     error
+}
+
+// NewLengthPrefixedEncoder returns an Encoder which writes each of the parts after its
+// length.
+func NewLengthPrefixedEncoder(parts ...Encoder) *LengthPrefixedEncoder {
+	return &LengthPrefixedEncoder{parts: parts}
+}
+
+func (le *LengthPrefixedEncoder) Encode() ([]byte, error) {
+	if le.encoded != nil || le.err != nil {
+		return le.encoded, le.err
+	}
+
+	buf := make([]byte, 0, le.Length())
+	var prefix [4]byte
+	for _, part := range le.parts {
+		var data []byte
+		if part != nil {
+			if data, le.err = part.Encode(); le.err != nil {
+				return nil, le.err
+			}
+		}
+		length := int32(-1)
+		if data != nil {
+			length = int32(len(data))
+		}
+		binary.BigEndian.PutUint32(prefix[:], uint32(length))
+		buf = append(append(buf, prefix[:]...), data...)
+	}
+	le.encoded = buf
+	return le.encoded, nil
+}
+
+func (le *LengthPrefixedEncoder) Length() int {
+	length := 4 * len(le.parts)
+	for _, part := range le.parts {
+		if part != nil {
+			length += part.Length()
+		}
+	}
+	return length
+}
+
+// SplitLengthPrefixed splits data written by a LengthPrefixedEncoder into its parts. The
+// parts share the memory of data rather than being copied out of it; null parts are nil.
+func SplitLengthPrefixed(data []byte) ([][]byte, error) {
+	var parts [][]byte
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, ErrInvalidLengthPrefix
+		}
+		length := int32(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		switch {
+		case length == -1:
+			parts = append(parts, nil)
+		case length < 0 || int(length) > len(data):
+			return nil, ErrInvalidLengthPrefix
+		default:
+			parts = append(parts, data[:length:length])
+			data = data[length:]
+		}
+	}
+	return parts, nil
+}
@@ -0,0 +1,56 @@
+package sarama
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestLengthPrefixedEncoder(t *testing.T) {
+	encoder := NewLengthPrefixedEncoder(StringEncoder("envelope"), nil, ByteEncoder([]byte{}), NewJSONEncoder(3))
+
+	encoded, err := encoder.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0, 0, 0, 8, 'e', 'n', 'v', 'e', 'l', 'o', 'p', 'e',
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0, 0, 0, 0,
+		0, 0, 0, 1, '3',
+	}
+	if !bytes.Equal(encoded, expected) {
+		t.Error("Unexpected encoding:", encoded)
+	}
+	if encoder.Length() != len(expected) {
+		t.Error("Length() reported", encoder.Length(), "but expected", len(expected))
+	}
+
+	parts, err := SplitLengthPrefixed(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parts, [][]byte{[]byte("envelope"), nil, {}, []byte("3")}) {
+		t.Error("Unexpected parts:", parts)
+	}
+}
+
+func TestLengthPrefixedEncoderError(t *testing.T) {
+	encoder := NewLengthPrefixedEncoder(StringEncoder("envelope"), NewJSONEncoder(make(chan int)))
+
+	if _, err := encoder.Encode(); err == nil {
+		t.Error("Expected the error encoding a part")
+	}
+}
+
+func TestSplitLengthPrefixedInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{0, 0, 0},
+		{0, 0, 0, 2, 'a'},
+		{0xFF, 0xFF, 0xFF, 0xFE},
+	} {
+		if _, err := SplitLengthPrefixed(data); err != ErrInvalidLengthPrefix {
+			t.Error("Expected ErrInvalidLengthPrefix splitting", data, "got", err)
+		}
+	}
+}
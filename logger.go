@@ -0,0 +1,141 @@
+package sarama
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// LogLevel is the severity of a log event. The levels have the same values as
+// the levels of the log/slog package, so they can be converted directly.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = -4 // Internal state changes, useful when diagnosing issues.
+	LogLevelInfo  LogLevel = 0  // Connection management and other notable events.
+	LogLevelWarn  LogLevel = 4  // Problems Sarama works around, such as retried requests.
+	LogLevelError LogLevel = 8  // Failures which are also returned to the user.
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+// StructuredLogger receives Sarama's log events as a level, a constant message
+// and alternating keys and values describing the event, in the same form as the
+// arguments of slog.Logger.Log. Every event carries a "subsystem" field naming
+// the LogSubsystem it comes from.
+type StructuredLogger interface {
+	Log(level LogLevel, msg string, keyvals ...interface{})
+}
+
+// LogSubsystem identifies the part of Sarama a log event comes from, so that it
+// can be sent to its own logger or have its own verbosity (see SetSubsystemLogger).
+type LogSubsystem string
+
+const (
+	LogClient   LogSubsystem = "client"   // Metadata management and broker registration.
+	LogBroker   LogSubsystem = "broker"   // Connections to individual brokers.
+	LogProducer LogSubsystem = "producer" // The AsyncProducer and SyncProducer.
+	LogConsumer LogSubsystem = "consumer" // The Consumer and PartitionConsumers.
+	LogGroup    LogSubsystem = "group"    // Coordinator lookup and offset management.
+	LogConfig   LogSubsystem = "config"   // Configuration and request validation warnings.
+	LogAdmin    LogSubsystem = "admin"    // The ClusterAdmin.
+	LogProtocol LogSubsystem = "protocol" // Frame dumps enabled with Config.Net.Debug.
+)
+
+// StructuredLog, if set, receives all of Sarama's log events instead of Logger. Like
+// Logger, it must be set before Sarama is used.
+var StructuredLog StructuredLogger
+
+// MinLogLevel is the least severe level that is logged, for subsystems without a
+// level of their own. Like Logger, it must be set before Sarama is used.
+var MinLogLevel = LogLevelInfo
+
+type subsystemConfig struct {
+	logger   StructuredLogger
+	minLevel LogLevel
+}
+
+var (
+	subsystemsLock sync.RWMutex
+	subsystems     = make(map[LogSubsystem]subsystemConfig)
+)
+
+// SetSubsystemLogger overrides the logger and minimum level used for the given
+// subsystem. A nil logger keeps sending the subsystem's events to StructuredLog
+// (or Logger), so that only its verbosity changes.
+func SetSubsystemLogger(subsystem LogSubsystem, logger StructuredLogger, minLevel LogLevel) {
+	subsystemsLock.Lock()
+	defer subsystemsLock.Unlock()
+
+	subsystems[subsystem] = subsystemConfig{logger: logger, minLevel: minLevel}
+}
+
+// ResetSubsystemLogger removes any override set by SetSubsystemLogger.
+func ResetSubsystemLogger(subsystem LogSubsystem) {
+	subsystemsLock.Lock()
+	defer subsystemsLock.Unlock()
+
+	delete(subsystems, subsystem)
+}
+
+func (s LogSubsystem) config() subsystemConfig {
+	subsystemsLock.RLock()
+	config, ok := subsystems[s]
+	subsystemsLock.RUnlock()
+
+	if !ok {
+		config.minLevel = MinLogLevel
+	}
+	if config.logger == nil {
+		config.logger = StructuredLog
+	}
+	return config
+}
+
+func (s LogSubsystem) debug(msg string, keyvals ...interface{}) { s.log(LogLevelDebug, msg, keyvals) }
+func (s LogSubsystem) info(msg string, keyvals ...interface{})  { s.log(LogLevelInfo, msg, keyvals) }
+func (s LogSubsystem) warn(msg string, keyvals ...interface{})  { s.log(LogLevelWarn, msg, keyvals) }
+func (s LogSubsystem) error(msg string, keyvals ...interface{}) { s.log(LogLevelError, msg, keyvals) }
+
+func (s LogSubsystem) log(level LogLevel, msg string, keyvals []interface{}) {
+	config := s.config()
+	if level < config.minLevel {
+		return
+	}
+
+	if config.logger != nil {
+		config.logger.Log(level, msg, append([]interface{}{"subsystem", string(s)}, keyvals...)...)
+		return
+	}
+
+	Logger.Println(formatLogEvent(s, msg, keyvals))
+}
+
+// formatLogEvent renders an event for the unstructured Logger as
+// "subsystem: msg key=value ...".
+func formatLogEvent(subsystem LogSubsystem, msg string, keyvals []interface{}) string {
+	var line bytes.Buffer
+	line.WriteString(string(subsystem))
+	line.WriteString(": ")
+	line.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 < len(keyvals) {
+			fmt.Fprintf(&line, " %v=%v", keyvals[i], keyvals[i+1])
+		} else {
+			fmt.Fprintf(&line, " %v", keyvals[i])
+		}
+	}
+	return line.String()
+}
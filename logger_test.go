@@ -0,0 +1,88 @@
+package sarama
+
+import (
+	"sync"
+	"testing"
+)
+
+type logEvent struct {
+	level   LogLevel
+	msg     string
+	keyvals []interface{}
+}
+
+type recordingLogger struct {
+	lock   sync.Mutex
+	events []logEvent
+}
+
+func (rl *recordingLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	rl.events = append(rl.events, logEvent{level, msg, keyvals})
+}
+
+func (rl *recordingLogger) recorded() []logEvent {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+	return append([]logEvent(nil), rl.events...)
+}
+
+// field returns the value of the given key in the event's fields.
+func (e logEvent) field(key string) interface{} {
+	for i := 0; i+1 < len(e.keyvals); i += 2 {
+		if e.keyvals[i] == key {
+			return e.keyvals[i+1]
+		}
+	}
+	return nil
+}
+
+// a subsystem of its own, so that the tests don't touch the package-level
+// loggers that goroutines left behind by other tests may be using
+const logTest LogSubsystem = "test"
+
+func TestSubsystemLogger(t *testing.T) {
+	logger := new(recordingLogger)
+	SetSubsystemLogger(logTest, logger, LogLevelInfo)
+	defer ResetSubsystemLogger(logTest)
+
+	logTest.info("partition state change", "topic", "my_topic", "partition", int32(1))
+	logTest.debug("selected broker") // below the subsystem's level
+
+	if len(logger.events) != 1 {
+		t.Fatal("Expected 1 event, got", len(logger.events))
+	}
+	event := logger.events[0]
+	if event.level != LogLevelInfo || event.msg != "partition state change" {
+		t.Error("Unexpected event", event)
+	}
+	if len(event.keyvals) != 6 || event.keyvals[0] != "subsystem" || event.keyvals[1] != "test" || event.keyvals[3] != "my_topic" {
+		t.Error("Unexpected fields", event.keyvals)
+	}
+
+	SetSubsystemLogger(logTest, logger, LogLevelError)
+	logTest.warn("retrying metadata request")
+	logTest.error("no available broker to send metadata request to")
+	if len(logger.events) != 2 || logger.events[1].level != LogLevelError {
+		t.Error("Expected only the error to be logged after raising the level, got", logger.events)
+	}
+}
+
+func TestFormatLogEvent(t *testing.T) {
+	line := formatLogEvent(LogBroker, "failed to connect to broker", []interface{}{"addr", "localhost:9092", "err", ErrOutOfBrokers})
+	want := "broker: failed to connect to broker addr=localhost:9092 err=kafka: client has run out of available brokers to talk to (Is your cluster reachable?)"
+	if line != want {
+		t.Errorf("Expected %q, got %q", want, line)
+	}
+
+	if line := formatLogEvent(LogBroker, "odd", []interface{}{"key"}); line != "broker: odd key" {
+		t.Error("Expected a dangling key to be printed on its own, got", line)
+	}
+}
+
+func TestLogLevelString(t *testing.T) {
+	if LogLevelWarn.String() != "WARN" || LogLevel(2).String() != "LEVEL(2)" {
+		t.Error("Unexpected level names", LogLevelWarn, LogLevel(2))
+	}
+}
@@ -0,0 +1,108 @@
+package sarama
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pierrec/lz4"
+	"github.com/pierrec/xxHash/xxHash32"
+)
+
+const (
+	lz4MagicLength      = 4
+	lz4FlagContentSize  = 0x08
+	lz4FlagDictionaryID = 0x01
+)
+
+// lz4Encode compresses src into a single LZ4 frame. Kafka brokers prior to 0.10
+// compute the frame header checksum over the magic number as well as the frame
+// descriptor (see https://issues.apache.org/jira/browse/KAFKA-3160), and reject
+// correctly framed data. Setting legacy reproduces that behaviour. Levels above
+// zero select the slower, high compression mode, searching deeper the higher
+// they are.
+func lz4Encode(src []byte, level int, legacy bool) ([]byte, error) {
+	buf := getScratch()
+	writer := lz4WriterPool.Get().(*lz4.Writer)
+	writer.Reset(buf)
+	if level > 0 {
+		writer.Header.CompressionLevel = level
+	}
+
+	_, err := writer.Write(src)
+	if err == nil {
+		err = writer.Close()
+	}
+	writer.Reset(nil)
+	lz4WriterPool.Put(writer)
+	if err != nil {
+		putScratch(buf)
+		return nil, err
+	}
+
+	frame := copyScratch(buf)
+	if legacy {
+		hc, err := lz4HeaderChecksumOffset(frame)
+		if err != nil {
+			return nil, err
+		}
+		frame[hc] = lz4HeaderChecksum(frame, hc, true)
+	}
+	return frame, nil
+}
+
+// lz4Decode decompresses a single LZ4 frame of at most limit bytes. Both the
+// standard and the legacy header checksum are accepted, since brokers return
+// whatever framing the original producer used.
+func lz4Decode(src []byte, limit int64) ([]byte, error) {
+	hc, err := lz4HeaderChecksumOffset(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var input io.Reader = bytes.NewReader(src)
+	if standard := lz4HeaderChecksum(src, hc, false); src[hc] != standard && src[hc] == lz4HeaderChecksum(src, hc, true) {
+		// rewrite the header rather than the shared input buffer
+		header := make([]byte, hc+1)
+		copy(header, src)
+		header[hc] = standard
+		input = io.MultiReader(bytes.NewReader(header), bytes.NewReader(src[hc+1:]))
+	}
+
+	reader := lz4ReaderPool.Get().(*lz4.Reader)
+	reader.Reset(input)
+	defer func() {
+		reader.Reset(nil)
+		lz4ReaderPool.Put(reader)
+	}()
+
+	return readLimited(reader, limit)
+}
+
+// lz4HeaderChecksumOffset returns the position of the header checksum byte in
+// the given frame, which depends on the optional descriptor fields present.
+func lz4HeaderChecksumOffset(frame []byte) (int, error) {
+	if len(frame) < lz4MagicLength+3 {
+		return -1, PacketDecodingError{"LZ4 frame too short"}
+	}
+
+	offset := lz4MagicLength + 2
+	if frame[lz4MagicLength]&lz4FlagContentSize != 0 {
+		offset += 8
+	}
+	if frame[lz4MagicLength]&lz4FlagDictionaryID != 0 {
+		offset += 4
+	}
+
+	if offset >= len(frame) {
+		return -1, PacketDecodingError{"LZ4 frame too short"}
+	}
+	return offset, nil
+}
+
+func lz4HeaderChecksum(frame []byte, hc int, legacy bool) byte {
+	start := lz4MagicLength
+	if legacy {
+		start = 0
+	}
+	return byte(xxHash32.Checksum(frame[start:hc], 0) >> 8)
+}
@@ -0,0 +1,80 @@
+package sarama
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pierrec/lz4"
+)
+
+var lz4TestCases = []string{
+	"",
+	"REALLY SHORT",
+	"REPEATREPEATREPEATREPEATREPEATREPEAT",
+}
+
+func TestLZ4RoundTrip(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		for _, src := range lz4TestCases {
+			encoded, err := lz4Encode([]byte(src), CompressionLevelDefault, legacy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := lz4Decode(encoded, maxDecompressedBatchBytes())
+			if err != nil {
+				t.Errorf("Decoding %q (legacy=%v) failed: %s", src, legacy, err)
+			} else if !bytes.Equal(decoded, []byte(src)) {
+				t.Errorf("Expected %q (legacy=%v), got %q", src, legacy, decoded)
+			}
+		}
+	}
+}
+
+func TestLZ4CompressionLevel(t *testing.T) {
+	src := bytes.Repeat([]byte("REPEAT REPEAL REPEAT REVEAL "), 1000)
+	fast, err := lz4Encode(src, CompressionLevelDefault, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := lz4Encode(src, 9, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(high) > len(fast) {
+		t.Errorf("Expected high compression to be no larger than %d bytes, got %d", len(fast), len(high))
+	}
+	decoded, err := lz4Decode(high, maxDecompressedBatchBytes())
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(decoded, src) {
+		t.Error("Expected the highly compressed frame to decode to its source")
+	}
+}
+
+func TestLZ4LegacyFraming(t *testing.T) {
+	standard, err := lz4Encode([]byte("REALLY SHORT"), CompressionLevelDefault, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(lz4.NewReader(bytes.NewReader(standard))); err != nil {
+		t.Error("Standard frame should be readable by a plain LZ4 reader:", err)
+	}
+
+	legacy, err := lz4Encode([]byte("REALLY SHORT"), CompressionLevelDefault, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(standard, legacy) {
+		t.Fatal("Legacy framing should produce a different header checksum")
+	}
+	if _, err := ioutil.ReadAll(lz4.NewReader(bytes.NewReader(legacy))); err == nil {
+		t.Error("Legacy frame should be rejected by a plain LZ4 reader")
+	}
+}
+
+func TestLZ4DecodeTooShort(t *testing.T) {
+	if _, err := lz4Decode([]byte{0x04, 0x22, 0x4d}, maxDecompressedBatchBytes()); err == nil {
+		t.Error("Expected an error decoding a truncated frame")
+	}
+}
@@ -1,27 +1,28 @@
 package sarama
 
 import (
-	"bytes"
-	"compress/gzip"
 	"fmt"
-	"io/ioutil"
+	"time"
 )
 
 // CompressionCodec represents the various compression codecs recognized by Kafka in messages.
 type CompressionCodec int8
 
-// only the last two bits are really used
-const compressionCodecMask int8 = 0x03
+// only the last three bits are really used
+const compressionCodecMask int8 = 0x07
 
 const (
 	CompressionNone   CompressionCodec = 0
 	CompressionGZIP   CompressionCodec = 1
 	CompressionSnappy CompressionCodec = 2
+	CompressionLZ4    CompressionCodec = 3
+	CompressionZSTD   CompressionCodec = 4
 )
 
-// The spec just says: "This is a version id used to allow backwards compatible evolution of the message
-// binary format." but it doesn't say what the current value is, so presumably 0...
-const messageFormat int8 = 0
+// CompressionLevelDefault is the constant to use in CompressionLevel
+// to have the default compression level for any codec. The value is picked
+// that we don't use any existing compression levels.
+const CompressionLevelDefault = -1000
 
 type Message struct {
 	Codec CompressionCodec // codec used to compress the message contents
@@ -29,17 +30,46 @@ type Message struct {
 	Value []byte           // the message contents
 	Set   *MessageSet      // the message set a message might wrap
 
-	compressedCache []byte
+	// Version is the message format: 0, or 1 for Kafka 0.10 and later, whose
+	// messages have a Timestamp. The messages a message wraps have the same
+	// version; from version 1 their offsets are relative to the first of them.
+	Version   int8
+	Timestamp time.Time // from version 1, the zero time if unknown
+	// LogAppendTime is whether the Timestamp is when the broker appended the
+	// message rather than when it was created. The messages a message with
+	// LogAppendTime wraps all have its timestamp.
+	LogAppendTime bool
+
+	// CompressionLevel is the level passed to the codec when compressing; zero or
+	// CompressionLevelDefault select the codec's own default. Of the built-in
+	// codecs, only Snappy ignores it.
+	CompressionLevel int
+
+	// lz4LegacyFraming selects the broken LZ4 frame header checksum expected by
+	// brokers prior to 0.10 (see KAFKA-3160); only relevant with CompressionLZ4.
+	lz4LegacyFraming bool
+	compressedCache  []byte
+	// retainCompressed keeps compressedCache after encoding, for messages whose
+	// Value is known not to change between sends
+	retainCompressed bool
 }
 
 func (m *Message) encode(pe packetEncoder) error {
-	pe.push(&crc32Field{})
+	crc := acquireCRC32Field(crcIEEE)
+	pe.push(crc)
 
-	pe.putInt8(messageFormat)
+	pe.putInt8(m.Version)
 
 	attributes := int8(m.Codec) & compressionCodecMask
+	if m.LogAppendTime {
+		attributes |= timestampTypeMask
+	}
 	pe.putInt8(attributes)
 
+	if m.Version >= 1 {
+		pe.putInt64(timestampMillis(m.Timestamp))
+	}
+
 	err := pe.putBytes(m.Key)
 	if err != nil {
 		return err
@@ -49,50 +79,41 @@ func (m *Message) encode(pe packetEncoder) error {
 
 	if m.compressedCache != nil {
 		payload = m.compressedCache
-		m.compressedCache = nil
+		if !m.retainCompressed {
+			m.compressedCache = nil
+		}
+	} else if m.Codec == CompressionNone {
+		payload = m.Value
 	} else {
-		switch m.Codec {
-		case CompressionNone:
-			payload = m.Value
-		case CompressionGZIP:
-			var buf bytes.Buffer
-			writer := gzip.NewWriter(&buf)
-			if _, err = writer.Write(m.Value); err != nil {
-				return err
-			}
-			if err = writer.Close(); err != nil {
-				return err
-			}
-			m.compressedCache = buf.Bytes()
-			payload = m.compressedCache
-		case CompressionSnappy:
-			tmp := snappyEncode(m.Value)
-			m.compressedCache = tmp
-			payload = m.compressedCache
-		default:
-			return PacketEncodingError{fmt.Sprintf("unsupported compression codec (%d)", m.Codec)}
+		if m.compressedCache, err = compress(m.Codec, m.CompressionLevel, m.lz4LegacyFraming, m.Value); err != nil {
+			return err
 		}
+		payload = m.compressedCache
 	}
 
 	if err = pe.putBytes(payload); err != nil {
 		return err
 	}
 
-	return pe.pop()
+	if err = pe.pop(); err != nil {
+		return err
+	}
+	releaseCRC32Field(crc)
+	return nil
 }
 
 func (m *Message) decode(pd packetDecoder) (err error) {
-	err = pd.push(&crc32Field{})
+	crc := acquireCRC32Field(crcIEEE)
+	err = pd.push(crc)
 	if err != nil {
 		return err
 	}
 
-	format, err := pd.getInt8()
-	if err != nil {
+	if m.Version, err = pd.getInt8(); err != nil {
 		return err
 	}
-	if format != messageFormat {
-		return PacketDecodingError{"unexpected messageFormat"}
+	if m.Version > 1 {
+		return PacketDecodingError{fmt.Sprintf("unexpected message version %d", m.Version)}
 	}
 
 	attribute, err := pd.getInt8()
@@ -100,6 +121,15 @@ func (m *Message) decode(pd packetDecoder) (err error) {
 		return err
 	}
 	m.Codec = CompressionCodec(attribute & compressionCodecMask)
+	m.LogAppendTime = attribute&timestampTypeMask != 0
+
+	if m.Version >= 1 {
+		millis, err := pd.getInt64()
+		if err != nil {
+			return err
+		}
+		m.Timestamp = millisTimestamp(millis)
+	}
 
 	m.Key, err = pd.getBytes()
 	if err != nil {
@@ -111,38 +141,23 @@ func (m *Message) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
-	switch m.Codec {
-	case CompressionNone:
-		// nothing to do
-	case CompressionGZIP:
+	if m.Codec != CompressionNone {
 		if m.Value == nil {
-			return PacketDecodingError{"GZIP compression specified, but no data to uncompress"}
-		}
-		reader, err := gzip.NewReader(bytes.NewReader(m.Value))
-		if err != nil {
-			return err
+			return PacketDecodingError{fmt.Sprintf("%s compression specified, but no data to uncompress", m.Codec)}
 		}
-		if m.Value, err = ioutil.ReadAll(reader); err != nil {
+		if m.Value, err = decompress(m.Codec, m.Value); err != nil {
 			return err
 		}
 		if err := m.decodeSet(); err != nil {
 			return err
 		}
-	case CompressionSnappy:
-		if m.Value == nil {
-			return PacketDecodingError{"Snappy compression specified, but no data to uncompress"}
-		}
-		if m.Value, err = snappyDecode(m.Value); err != nil {
-			return err
-		}
-		if err := m.decodeSet(); err != nil {
-			return err
-		}
-	default:
-		return PacketDecodingError{fmt.Sprintf("invalid compression specified (%d)", m.Codec)}
 	}
 
-	return pd.pop()
+	if err = pd.pop(); err != nil {
+		return err
+	}
+	releaseCRC32Field(crc)
+	return nil
 }
 
 // decodes a message set from a previousy encoded bulk-message
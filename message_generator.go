@@ -0,0 +1,180 @@
+package sarama
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// generatedText is what the compressible part of generated values is cut from.
+const generatedText = "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod " +
+	"tempor incididunt ut labore et dolore magna aliqua. Ut enim ad minim veniam, quis " +
+	"nostrud exercitation ullamco laboris nisi ut aliquip ex ea commodo consequat. "
+
+// generatedTime is the timestamp of the first record of generated batches, the
+// next ones following it by a millisecond each.
+var generatedTime = time.Unix(1500000000, 0)
+
+// MessageGenerator generates realistic messages for benchmarks, fuzzing corpora
+// and integration tests: ProducerMessages, and the message sets and record
+// batches brokers store them in, compressed or not. Its messages are reproducible: generators of the
+// same seed and settings generate the same messages in the same order. Set the
+// exported fields before generating messages. A MessageGenerator is not safe
+// for concurrent use.
+type MessageGenerator struct {
+	// Topic is the topic of the generated ProducerMessages.
+	Topic string
+	// Keys is the number of distinct keys the keys of the messages are drawn
+	// from, or 0 for messages without keys (default 0).
+	Keys int
+	// ValueSize is the average size of values, in bytes; each is between half
+	// and one and a half times as large (default 100).
+	ValueSize int
+	// Compressibility is the fraction of each value that is text rather than
+	// random bytes, between 0 and 1, which sets how well values compress
+	// (default 0.5).
+	Compressibility float64
+	// Tombstones is the fraction of messages whose value is null, between 0
+	// and 1, as deleted keys of compacted topics are (default 0).
+	Tombstones float64
+	// Headers is the number of headers of each message, which only
+	// ProducerMessages and records carry (default 0).
+	Headers int
+
+	random *rand.Rand
+}
+
+// NewMessageGenerator returns a generator of messages from the given seed.
+func NewMessageGenerator(seed int64) *MessageGenerator {
+	return &MessageGenerator{
+		ValueSize:       100,
+		Compressibility: 0.5,
+		random:          rand.New(rand.NewSource(seed)),
+	}
+}
+
+// ProducerMessage generates a message to produce, with ByteEncoder keys and
+// values.
+func (g *MessageGenerator) ProducerMessage() *ProducerMessage {
+	key, value := g.key(), g.value()
+	msg := &ProducerMessage{Topic: g.Topic}
+	if key != nil {
+		msg.Key = ByteEncoder(key)
+	}
+	if value != nil {
+		msg.Value = ByteEncoder(value)
+	}
+	for _, header := range g.headers() {
+		msg.Headers = append(msg.Headers, *header)
+	}
+	return msg
+}
+
+// ProducerMessages generates n messages to produce.
+func (g *MessageGenerator) ProducerMessages(n int) []*ProducerMessage {
+	msgs := make([]*ProducerMessage, n)
+	for i := range msgs {
+		msgs[i] = g.ProducerMessage()
+	}
+	return msgs
+}
+
+// Message generates an uncompressed message.
+func (g *MessageGenerator) Message() *Message {
+	key, value := g.key(), g.value()
+	return &Message{Codec: CompressionNone, Key: key, Value: value}
+}
+
+// MessageSet generates a message set of n messages at offsets from 0 to n-1. With
+// a codec other than CompressionNone, the messages are wrapped in a single
+// message compressed with it, at the offset of the last, as the producer and
+// brokers do.
+func (g *MessageGenerator) MessageSet(n int, codec CompressionCodec) (*MessageSet, error) {
+	set := new(MessageSet)
+	for i := 0; i < n; i++ {
+		set.Messages = append(set.Messages, &MessageBlock{Offset: int64(i), Msg: g.Message()})
+	}
+	if codec == CompressionNone {
+		return set, nil
+	}
+
+	value, err := encode(set)
+	if err != nil {
+		return nil, err
+	}
+	wrapper := &Message{Codec: codec, Value: value}
+	return &MessageSet{Messages: []*MessageBlock{{Offset: int64(n - 1), Msg: wrapper}}}, nil
+}
+
+// EncodedMessageSet generates a message set as MessageSet does, and returns it
+// encoded as in produce requests and fetch responses.
+func (g *MessageGenerator) EncodedMessageSet(n int, codec CompressionCodec) ([]byte, error) {
+	set, err := g.MessageSet(n, codec)
+	if err != nil {
+		return nil, err
+	}
+	return encode(set)
+}
+
+// RecordBatch generates a record batch of n records at offsets from 0 to n-1,
+// compressed with the given codec, as an idempotent producer's is not. Set its
+// FirstOffset to place it elsewhere in a partition, and its ProducerID,
+// ProducerEpoch and Transactional fields for a batch of a transaction.
+func (g *MessageGenerator) RecordBatch(n int, codec CompressionCodec) *RecordBatch {
+	batch := newRecordBatch(codec)
+	for i := 0; i < n; i++ {
+		record := &Record{Key: g.key(), Value: g.value(), Headers: g.headers()}
+		batch.addRecord(record, generatedTime.Add(time.Duration(i)*time.Millisecond))
+	}
+	return batch
+}
+
+// EncodedRecordBatch generates a record batch as RecordBatch does, and returns
+// it encoded as in produce requests and fetch responses.
+func (g *MessageGenerator) EncodedRecordBatch(n int, codec CompressionCodec) ([]byte, error) {
+	return encode(g.RecordBatch(n, codec))
+}
+
+// ControlBatch generates the control batch holding the marker that ends a
+// transaction of the given producer, of epoch 0, with the given outcome: the
+// batch a transaction coordinator writes to each partition of the transaction.
+// Set its FirstOffset to the offset following the records of the transaction.
+func (g *MessageGenerator) ControlBatch(kind ControlRecordType, producerID int64) *RecordBatch {
+	batch := newRecordBatch(CompressionNone)
+	batch.Control, batch.Transactional = true, true
+	batch.ProducerID, batch.ProducerEpoch = producerID, 0
+	batch.addRecord(newControlRecord(kind), generatedTime)
+	return batch
+}
+
+func (g *MessageGenerator) headers() []*RecordHeader {
+	var headers []*RecordHeader
+	for i := 0; i < g.Headers; i++ {
+		value := fmt.Sprintf("value-%d", g.random.Intn(100))
+		headers = append(headers, &RecordHeader{Key: []byte(fmt.Sprintf("header-%d", i)), Value: []byte(value)})
+	}
+	return headers
+}
+
+func (g *MessageGenerator) key() []byte {
+	if g.Keys <= 0 {
+		return nil
+	}
+	return []byte(fmt.Sprintf("key-%d", g.random.Intn(g.Keys)))
+}
+
+func (g *MessageGenerator) value() []byte {
+	if g.Tombstones > 0 && g.random.Float64() < g.Tombstones {
+		return nil
+	}
+
+	size := g.ValueSize/2 + g.random.Intn(g.ValueSize+1)
+	value := make([]byte, size)
+	text := int(float64(size) * g.Compressibility)
+	start := g.random.Intn(len(generatedText))
+	for i := 0; i < text; i++ {
+		value[i] = generatedText[(start+i)%len(generatedText)]
+	}
+	_, _ = g.random.Read(value[text:])
+	return value
+}
@@ -0,0 +1,160 @@
+package sarama
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMessageGeneratorIsReproducible(t *testing.T) {
+	first, second := NewMessageGenerator(42), NewMessageGenerator(42)
+	first.Keys, second.Keys = 10, 10
+
+	for i := 0; i < 100; i++ {
+		a, b := first.ProducerMessage(), second.ProducerMessage()
+		aKey, _ := a.Key.Encode()
+		bKey, _ := b.Key.Encode()
+		aValue, _ := a.Value.Encode()
+		bValue, _ := b.Value.Encode()
+		if !bytes.Equal(aKey, bKey) || !bytes.Equal(aValue, bValue) {
+			t.Fatalf("Expected message %d to be the same for the same seed, got %q and %q", i, aKey, bKey)
+		}
+		if len(aValue) < 50 || len(aValue) > 150 {
+			t.Error("Expected a value of 50 to 150 bytes, got", len(aValue))
+		}
+	}
+}
+
+func TestMessageGeneratorTombstones(t *testing.T) {
+	g := NewMessageGenerator(1)
+	g.Tombstones = 1
+	for _, msg := range g.ProducerMessages(10) {
+		if msg.Key != nil || msg.Value != nil {
+			t.Error("Expected tombstones without keys, got", msg)
+		}
+	}
+}
+
+func TestMessageGeneratorHeaders(t *testing.T) {
+	g := NewMessageGenerator(1)
+	g.Headers = 3
+	for _, msg := range g.ProducerMessages(10) {
+		if len(msg.Headers) != 3 || string(msg.Headers[2].Key) != "header-2" {
+			t.Error("Expected 3 headers, got", msg.Headers)
+		}
+	}
+}
+
+func TestMessageGeneratorMessageSets(t *testing.T) {
+	for _, codec := range []CompressionCodec{CompressionNone, CompressionGZIP, CompressionSnappy, CompressionLZ4} {
+		g := NewMessageGenerator(1)
+		g.Keys = 3
+		buf, err := g.EncodedMessageSet(20, codec)
+		if err != nil {
+			t.Fatal(codec, err)
+		}
+
+		set := new(MessageSet)
+		if err := decode(buf, set); err != nil {
+			t.Fatal(codec, err)
+		}
+		blocks := set.Messages
+		if codec != CompressionNone {
+			if len(blocks) != 1 || blocks[0].Msg.Codec != codec || blocks[0].Offset != 19 {
+				t.Fatal("Expected a single wrapper message compressed with", codec)
+			}
+			blocks = blocks[0].Messages()
+		}
+		if len(blocks) != 20 {
+			t.Fatal("Expected 20 messages with", codec, "got", len(blocks))
+		}
+		for i, block := range blocks {
+			if block.Offset != int64(i) || block.Msg.Key == nil {
+				t.Errorf("Expected a keyed message at offset %d with %s, got %d", i, codec, block.Offset)
+			}
+		}
+	}
+}
+
+func TestMessageGeneratorRecordBatches(t *testing.T) {
+	for _, codec := range []CompressionCodec{CompressionNone, CompressionGZIP, CompressionSnappy, CompressionLZ4, CompressionZSTD} {
+		g := NewMessageGenerator(1)
+		g.Keys, g.Headers = 3, 2
+		buf, err := g.EncodedRecordBatch(20, codec)
+		if err != nil {
+			t.Fatal(codec, err)
+		}
+
+		batch := new(RecordBatch)
+		if err := decode(buf, batch); err != nil {
+			t.Fatal(codec, err)
+		}
+		if batch.Codec != codec || batch.Control || batch.ProducerID != -1 || batch.LastOffset() != 19 || len(batch.Records) != 20 {
+			t.Fatal("Expected a batch of 20 records compressed with", codec)
+		}
+		for i, record := range batch.Records {
+			if batch.Offset(record) != int64(i) || record.Key == nil || len(record.Headers) != 2 {
+				t.Errorf("Expected a keyed record with 2 headers at offset %d with %s, got %d", i, codec, batch.Offset(record))
+			}
+		}
+	}
+}
+
+func TestMessageGeneratorControlBatches(t *testing.T) {
+	buf, err := encode(NewMessageGenerator(1).ControlBatch(ControlRecordAbort, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := new(RecordBatch)
+	if err := decode(buf, batch); err != nil {
+		t.Fatal(err)
+	}
+	if !batch.Control || !batch.Transactional || batch.ProducerID != 7 || len(batch.Records) != 1 {
+		t.Fatal("Expected the control batch of producer 7, got", batch)
+	}
+	if kind, err := controlRecordType(batch.Records[0]); err != nil || kind != ControlRecordAbort {
+		t.Error("Expected an abort marker, got", kind, err)
+	}
+}
+
+func TestMessageGeneratorCompressibility(t *testing.T) {
+	compressed := func(compressibility float64) int {
+		g := NewMessageGenerator(1)
+		g.Compressibility = compressibility
+		buf, err := g.EncodedMessageSet(50, CompressionGZIP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(buf)
+	}
+	if text, random := compressed(1), compressed(0); text*2 > random {
+		t.Errorf("Expected text values to compress much better than random ones, got %d and %d bytes", text, random)
+	}
+}
+
+func BenchmarkMessageSetDecodingUncompressed(b *testing.B) {
+	benchmarkMessageSetDecoding(b, CompressionNone)
+}
+
+func BenchmarkMessageSetDecodingGZIP(b *testing.B) {
+	benchmarkMessageSetDecoding(b, CompressionGZIP)
+}
+
+func BenchmarkMessageSetDecodingSnappy(b *testing.B) {
+	benchmarkMessageSetDecoding(b, CompressionSnappy)
+}
+
+func benchmarkMessageSetDecoding(b *testing.B, codec CompressionCodec) {
+	buf, err := NewMessageGenerator(1).EncodedMessageSet(100, codec)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(buf)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := decode(buf, new(MessageSet)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -14,14 +14,30 @@ func (msb *MessageBlock) Messages() []*MessageBlock {
 	return []*MessageBlock{msb}
 }
 
+// innerOffset returns the offset of inner, one of the messages that msb wraps.
+// From message version 1 the offsets of wrapped messages are relative to the
+// first of them, and the offset of msb is that of the last.
+func (msb *MessageBlock) innerOffset(inner *MessageBlock) int64 {
+	set := msb.Msg.Set
+	if msb.Msg.Version < 1 || set == nil || len(set.Messages) == 0 {
+		return inner.Offset
+	}
+	return msb.Offset - set.Messages[len(set.Messages)-1].Offset + inner.Offset
+}
+
 func (msb *MessageBlock) encode(pe packetEncoder) error {
 	pe.putInt64(msb.Offset)
-	pe.push(&lengthField{})
+	length := acquireLengthField()
+	pe.push(length)
 	err := msb.Msg.encode(pe)
 	if err != nil {
 		return err
 	}
-	return pe.pop()
+	if err := pe.pop(); err != nil {
+		return err
+	}
+	releaseLengthField(length)
+	return nil
 }
 
 func (msb *MessageBlock) decode(pd packetDecoder) (err error) {
@@ -29,7 +45,8 @@ func (msb *MessageBlock) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
-	if err = pd.push(&lengthField{}); err != nil {
+	length := acquireLengthField()
+	if err = pd.push(length); err != nil {
 		return err
 	}
 
@@ -41,6 +58,7 @@ func (msb *MessageBlock) decode(pd packetDecoder) (err error) {
 	if err = pd.pop(); err != nil {
 		return err
 	}
+	releaseLengthField(length)
 
 	return nil
 }
@@ -87,3 +105,17 @@ func (ms *MessageSet) addMessage(msg *Message) {
 	block.Msg = msg
 	ms.Messages = append(ms.Messages, block)
 }
+
+// decompressedSize returns the number of bytes that the compressed messages of
+// the set decompressed to.
+func (ms *MessageSet) decompressedSize() (size int64) {
+	for _, block := range ms.Messages {
+		if block.Msg.Codec != CompressionNone {
+			size += int64(len(block.Msg.Value))
+		}
+		if block.Msg.Set != nil {
+			size += block.Msg.Set.decompressedSize()
+		}
+	}
+	return size
+}
@@ -1,6 +1,9 @@
 package sarama
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 var (
 	emptyMessage = []byte{
@@ -111,3 +114,90 @@ func TestMessageDecodingBulkGzip(t *testing.T) {
 		t.Errorf("Decoding produced a set with %d messages, but 2 were expected.", len(message.Set.Messages))
 	}
 }
+
+func TestMessageLZ4RoundTrip(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		inner := &MessageSet{}
+		inner.addMessage(&Message{Key: []byte("key"), Value: []byte("value")})
+		payload, err := encode(inner)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		message := &Message{Codec: CompressionLZ4, Value: payload, lz4LegacyFraming: legacy}
+		packet, err := encode(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded := Message{}
+		testDecodable(t, "lz4", &decoded, packet)
+		if decoded.Codec != CompressionLZ4 {
+			t.Errorf("Decoding produced codec %d, but expected %d.", decoded.Codec, CompressionLZ4)
+		}
+		if decoded.Set == nil || len(decoded.Set.Messages) != 1 {
+			t.Fatal("Decoding produced no set, or a set of the wrong size.")
+		}
+		if string(decoded.Set.Messages[0].Msg.Value) != "value" {
+			t.Errorf("Decoding produced value %q, but expected %q.", decoded.Set.Messages[0].Msg.Value, "value")
+		}
+	}
+}
+
+func TestMessageZstdRoundTrip(t *testing.T) {
+	inner := &MessageSet{}
+	inner.addMessage(&Message{Key: []byte("key"), Value: []byte("value")})
+	payload, err := encode(inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := &Message{Codec: CompressionZSTD, CompressionLevel: 5, Value: payload}
+	packet, err := encode(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := Message{}
+	testDecodable(t, "zstd", &decoded, packet)
+	if decoded.Codec != CompressionZSTD {
+		t.Errorf("Decoding produced codec %d, but expected %d.", decoded.Codec, CompressionZSTD)
+	}
+	if decoded.Set == nil || len(decoded.Set.Messages) != 1 {
+		t.Fatal("Decoding produced no set, or a set of the wrong size.")
+	}
+}
+
+func TestMessageV1RoundTrip(t *testing.T) {
+	message := &Message{Version: 1, Timestamp: time.Unix(1500000000, 0), LogAppendTime: true, Value: []byte("value")}
+	packet, err := encode(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packet[4] != 1 || packet[5] != 0x08 {
+		t.Fatalf("Encoding produced magic %d and attributes %#x, expected 1 and 0x08", packet[4], packet[5])
+	}
+
+	decoded := Message{}
+	testDecodable(t, "v1", &decoded, packet)
+	if decoded.Version != 1 || !decoded.LogAppendTime || !decoded.Timestamp.Equal(message.Timestamp) {
+		t.Errorf("Decoding produced version %d and timestamp %v, expected 1 and %v", decoded.Version, decoded.Timestamp, message.Timestamp)
+	}
+	if string(decoded.Value) != "value" {
+		t.Errorf("Decoding produced value %q, but expected %q.", decoded.Value, "value")
+	}
+
+	packet, err = encode(&Message{Version: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDecodable(t, "v1 without timestamp", &decoded, packet)
+	if !decoded.Timestamp.IsZero() {
+		t.Error("Decoding produced a timestamp where there was none:", decoded.Timestamp)
+	}
+
+	packet[4] = 2
+	if err := decode(packet, &decoded); err == nil {
+		t.Error("Expected an error decoding a message of version 2")
+	}
+}
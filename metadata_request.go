@@ -1,10 +1,21 @@
 package sarama
 
 type MetadataRequest struct {
+	// Version can be 0, or 1 for Kafka 0.10 and later, in which case the
+	// response includes the controller, the racks of the brokers and whether
+	// topics are internal.
+	Version int16
+	// Topics to fetch the metadata of; empty for all topics.
 	Topics []string
 }
 
 func (mr *MetadataRequest) encode(pe packetEncoder) error {
+	if mr.Version >= 1 && len(mr.Topics) == 0 {
+		// from version 1 an empty array asks for no topics, and null for all
+		pe.putInt32(-1)
+		return nil
+	}
+
 	err := pe.putArrayLength(len(mr.Topics))
 	if err != nil {
 		return err
@@ -20,13 +31,18 @@ func (mr *MetadataRequest) encode(pe packetEncoder) error {
 }
 
 func (mr *MetadataRequest) decode(pd packetDecoder) error {
-	topicCount, err := pd.getArrayLength()
+	n, err := pd.getInt32()
 	if err != nil {
 		return err
 	}
-	if topicCount == 0 {
+	if n == 0 || (n == -1 && mr.Version >= 1) {
 		return nil
 	}
+	topicCount := int(n)
+	// every topic takes at least its two length bytes
+	if topicCount < 0 || 2*topicCount > pd.remaining() {
+		return PacketDecodingError{"invalid array length"}
+	}
 
 	mr.Topics = make([]string, topicCount)
 	for i := range mr.Topics {
@@ -44,5 +60,13 @@ func (mr *MetadataRequest) key() int16 {
 }
 
 func (mr *MetadataRequest) version() int16 {
+	return mr.Version
+}
+
+func (mr *MetadataRequest) minVersion() int16 {
 	return 0
 }
+
+func (mr *MetadataRequest) setVersion(version int16) {
+	mr.Version = version
+}
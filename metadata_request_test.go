@@ -15,6 +15,9 @@ var (
 		0x00, 0x03, 'f', 'o', 'o',
 		0x00, 0x03, 'b', 'a', 'r',
 		0x00, 0x03, 'b', 'a', 'z'}
+
+	metadataRequestAllTopicsV1 = []byte{
+		0xff, 0xff, 0xff, 0xff}
 )
 
 func TestMetadataRequest(t *testing.T) {
@@ -27,3 +30,11 @@ func TestMetadataRequest(t *testing.T) {
 	request.Topics = []string{"foo", "bar", "baz"}
 	testRequest(t, "three topics", request, metadataRequestThreeTopics)
 }
+
+func TestMetadataRequestV1(t *testing.T) {
+	request := &MetadataRequest{Version: 1}
+	testRequest(t, "all topics", request, metadataRequestAllTopicsV1)
+
+	request.Topics = []string{"topic1"}
+	testRequest(t, "one topic", request, metadataRequestOneTopic)
+}
@@ -9,6 +9,13 @@ type PartitionMetadata struct {
 }
 
 func (pm *PartitionMetadata) decode(pd packetDecoder) (err error) {
+	var slab []int32
+	return pm.decodeInto(pd, &slab)
+}
+
+// decodeInto decodes the replica and ISR lists onto the end of slab, so that the
+// partitions of a topic share a handful of allocations rather than two each.
+func (pm *PartitionMetadata) decodeInto(pd packetDecoder, slab *[]int32) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -25,12 +32,12 @@ func (pm *PartitionMetadata) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
-	pm.Replicas, err = pd.getInt32Array()
+	pm.Replicas, err = getInt32ArrayInto(pd, slab)
 	if err != nil {
 		return err
 	}
 
-	pm.Isr, err = pd.getInt32Array()
+	pm.Isr, err = getInt32ArrayInto(pd, slab)
 	if err != nil {
 		return err
 	}
@@ -38,6 +45,32 @@ func (pm *PartitionMetadata) decode(pd packetDecoder) (err error) {
 	return nil
 }
 
+// getInt32ArrayInto is like packetDecoder.getInt32Array, but appends the array to
+// slab and returns the appended part, capped so that appending to it cannot
+// overwrite whatever comes next.
+func getInt32ArrayInto(pd packetDecoder, slab *[]int32) ([]int32, error) {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	if n < 0 || 4*n > pd.remaining() {
+		return nil, PacketDecodingError{"invalid array length"}
+	}
+
+	start := len(*slab)
+	for i := 0; i < n; i++ {
+		val, err := pd.getInt32()
+		if err != nil {
+			return nil, err
+		}
+		*slab = append(*slab, val)
+	}
+	return (*slab)[start:len(*slab):len(*slab)], nil
+}
+
 func (pm *PartitionMetadata) encode(pe packetEncoder) (err error) {
 	pe.putInt16(int16(pm.Err))
 	pe.putInt32(pm.ID)
@@ -59,10 +92,11 @@ func (pm *PartitionMetadata) encode(pe packetEncoder) (err error) {
 type TopicMetadata struct {
 	Err        KError
 	Name       string
+	IsInternal bool // from version 1, as for __consumer_offsets
 	Partitions []*PartitionMetadata
 }
 
-func (tm *TopicMetadata) decode(pd packetDecoder) (err error) {
+func (tm *TopicMetadata) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -74,14 +108,24 @@ func (tm *TopicMetadata) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
+	if version >= 1 {
+		if tm.IsInternal, err = getBool(pd); err != nil {
+			return err
+		}
+	}
+
 	n, err := pd.getArrayLength()
 	if err != nil {
 		return err
 	}
+	// allocate the partitions, and room for three replicas and ISR members
+	// each, in bulk; large clusters have tens of thousands of partitions
 	tm.Partitions = make([]*PartitionMetadata, n)
+	partitions := make([]PartitionMetadata, n)
+	slab := make([]int32, 0, 6*n)
 	for i := 0; i < n; i++ {
-		tm.Partitions[i] = new(PartitionMetadata)
-		err = tm.Partitions[i].decode(pd)
+		tm.Partitions[i] = &partitions[i]
+		err = tm.Partitions[i].decodeInto(pd, &slab)
 		if err != nil {
 			return err
 		}
@@ -90,7 +134,7 @@ func (tm *TopicMetadata) decode(pd packetDecoder) (err error) {
 	return nil
 }
 
-func (tm *TopicMetadata) encode(pe packetEncoder) (err error) {
+func (tm *TopicMetadata) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(int16(tm.Err))
 
 	err = pe.putString(tm.Name)
@@ -98,6 +142,10 @@ func (tm *TopicMetadata) encode(pe packetEncoder) (err error) {
 		return err
 	}
 
+	if version >= 1 {
+		putBool(pe, tm.IsInternal)
+	}
+
 	err = pe.putArrayLength(len(tm.Partitions))
 	if err != nil {
 		return err
@@ -114,8 +162,17 @@ func (tm *TopicMetadata) encode(pe packetEncoder) (err error) {
 }
 
 type MetadataResponse struct {
+	// Version must be set to the version of the request before decoding.
+	Version int16
 	Brokers []*Broker
-	Topics  []*TopicMetadata
+	// ControllerID is the ID of the controller broker, from version 1, or -1
+	// if there is none.
+	ControllerID int32
+	Topics       []*TopicMetadata
+}
+
+func (m *MetadataResponse) setVersion(version int16) {
+	m.Version = version
 }
 
 func (m *MetadataResponse) decode(pd packetDecoder) (err error) {
@@ -131,6 +188,18 @@ func (m *MetadataResponse) decode(pd packetDecoder) (err error) {
 		if err != nil {
 			return err
 		}
+		if m.Version >= 1 {
+			if m.Brokers[i].rack, err = getNullableString(pd); err != nil {
+				return err
+			}
+		}
+	}
+
+	m.ControllerID = -1
+	if m.Version >= 1 {
+		if m.ControllerID, err = pd.getInt32(); err != nil {
+			return err
+		}
 	}
 
 	n, err = pd.getArrayLength()
@@ -141,7 +210,7 @@ func (m *MetadataResponse) decode(pd packetDecoder) (err error) {
 	m.Topics = make([]*TopicMetadata, n)
 	for i := 0; i < n; i++ {
 		m.Topics[i] = new(TopicMetadata)
-		err = m.Topics[i].decode(pd)
+		err = m.Topics[i].decode(pd, m.Version)
 		if err != nil {
 			return err
 		}
@@ -160,6 +229,15 @@ func (m *MetadataResponse) encode(pe packetEncoder) error {
 		if err != nil {
 			return err
 		}
+		if m.Version >= 1 {
+			if err = putNullableString(pe, broker.rack); err != nil {
+				return err
+			}
+		}
+	}
+
+	if m.Version >= 1 {
+		pe.putInt32(m.ControllerID)
 	}
 
 	err = pe.putArrayLength(len(m.Topics))
@@ -167,7 +245,7 @@ func (m *MetadataResponse) encode(pe packetEncoder) error {
 		return err
 	}
 	for _, tm := range m.Topics {
-		err = tm.encode(pe)
+		err = tm.encode(pe, m.Version)
 		if err != nil {
 			return err
 		}
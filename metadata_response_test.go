@@ -38,6 +38,21 @@ var (
 		0x00, 0x00, 0x00, 0x00}
 )
 
+var metadataResponseV1 = []byte{
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x09, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't',
+	0x00, 0x00, 0x00, 0x33,
+	0x00, 0x02, 'r', '1', // rack
+
+	0x00, 0x00, 0x00, 0x01, // controller
+
+	0x00, 0x00, 0x00, 0x01,
+	0x00, 0x00,
+	0x00, 0x03, 'f', 'o', 'o',
+	0x01, // internal
+	0x00, 0x00, 0x00, 0x00}
+
 func TestEmptyMetadataResponse(t *testing.T) {
 	response := MetadataResponse{}
 
@@ -137,3 +152,77 @@ func TestMetadataResponseWithTopics(t *testing.T) {
 		t.Error("Decoding produced invalid partition count for topic 1.")
 	}
 }
+
+func TestMetadataResponseV1(t *testing.T) {
+	response := MetadataResponse{Version: 1}
+
+	testDecodable(t, "version 1", &response, metadataResponseV1)
+	if response.ControllerID != 1 {
+		t.Error("Decoding produced controller", response.ControllerID, "instead of 1")
+	}
+	if len(response.Brokers) != 1 || response.Brokers[0].Addr() != "localhost:51" || response.Brokers[0].Rack() != "r1" {
+		t.Fatal("Decoding produced invalid brokers", response.Brokers)
+	}
+	if len(response.Topics) != 1 || response.Topics[0].Name != "foo" || !response.Topics[0].IsInternal {
+		t.Fatal("Decoding produced invalid topics", response.Topics)
+	}
+
+	if packet, err := encode(&response); err != nil {
+		t.Error(err)
+	} else if string(packet) != string(metadataResponseV1) {
+		t.Error("Encoding version 1 failed\ngot ", packet, "\nwant", metadataResponseV1)
+	}
+
+	v0 := new(MetadataResponse)
+	testDecodable(t, "version 0", v0, emptyMetadataResponse)
+	if v0.ControllerID != -1 {
+		t.Error("Expected no controller before version 1, got", v0.ControllerID)
+	}
+}
+
+func TestMetadataResponseSharedReplicaSlabs(t *testing.T) {
+	original := new(MetadataResponse)
+	for i := int32(0); i < 3; i++ {
+		original.AddTopicPartition("foo", i, 1, []int32{1, 2, 3}, []int32{1, 2}, ErrNoError)
+	}
+	packet, err := encode(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := new(MetadataResponse)
+	testDecodable(t, "many partitions", response, packet)
+	partitions := response.Topics[0].Partitions
+	if len(partitions) != 3 {
+		t.Fatal("Decoding produced", len(partitions), "partitions where there were three!")
+	}
+
+	// appending to one list must not clobber the lists decoded after it
+	partitions[0].Replicas = append(partitions[0].Replicas, 42)
+	partitions[0].Isr = append(partitions[0].Isr, 42)
+	for i, partition := range partitions {
+		if partition.Replicas[0] != 1 || partition.Replicas[2] != 3 || len(partition.Isr) < 2 || partition.Isr[1] != 2 {
+			t.Error("Decoding produced invalid replicas or isr for partition", i, partition.Replicas, partition.Isr)
+		}
+	}
+}
+
+func BenchmarkMetadataResponseDecode(b *testing.B) {
+	original := new(MetadataResponse)
+	original.AddBroker("localhost:9092", 1)
+	for i := int32(0); i < 10000; i++ {
+		original.AddTopicPartition("my_topic", i, 1, []int32{1, 2, 3}, []int32{1, 2, 3}, ErrNoError)
+	}
+	packet, err := encode(original)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := decode(packet, new(MetadataResponse)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
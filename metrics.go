@@ -0,0 +1,98 @@
+package sarama
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// Histograms use an exponentially decaying reservoir with the same defaults as
+// the metrics library used by the JVM clients.
+const (
+	metricsReservoirSize = 1028
+	metricsAlphaFactor   = 0.015
+)
+
+func getOrRegisterHistogram(name string, r metrics.Registry) metrics.Histogram {
+	return r.GetOrRegister(name, func() metrics.Histogram {
+		return metrics.NewHistogram(metrics.NewExpDecaySample(metricsReservoirSize, metricsAlphaFactor))
+	}).(metrics.Histogram)
+}
+
+func getMetricNameForBroker(name string, broker *Broker) string {
+	// use the broker ID rather than its address, which contains dots and colons
+	return fmt.Sprintf(name+"-for-broker-%d", broker.ID())
+}
+
+func getMetricNameForTopic(name string, topic string) string {
+	// dots are a common separator in metric names, so replace those in topic names
+	return fmt.Sprintf(name+"-for-topic-%s", strings.Replace(topic, ".", "_", -1))
+}
+
+func getMetricNameForPartition(name string, topic string, partition int32) string {
+	return fmt.Sprintf("%s-partition-%d", getMetricNameForTopic(name, topic), partition)
+}
+
+func getMetricNameForGroupPartition(name string, group string, topic string, partition int32) string {
+	return getMetricNameForPartition(name+"-for-group-"+strings.Replace(group, ".", "_", -1), topic, partition)
+}
+
+func getOrRegisterTopicMeter(name string, topic string, r metrics.Registry) metrics.Meter {
+	return metrics.GetOrRegisterMeter(getMetricNameForTopic(name, topic), r)
+}
+
+func getOrRegisterTopicHistogram(name string, topic string, r metrics.Registry) metrics.Histogram {
+	return getOrRegisterHistogram(getMetricNameForTopic(name, topic), r)
+}
+
+// brokerMetrics holds the request-level metrics of one broker connection, both
+// for the broker itself and aggregated across all brokers sharing the registry.
+type brokerMetrics struct {
+	incomingByteRate metrics.Meter
+	requestRate      metrics.Meter
+	requestSize      metrics.Histogram
+	requestLatency   metrics.Histogram
+	outgoingByteRate metrics.Meter
+	responseRate     metrics.Meter
+	responseSize     metrics.Histogram
+	requestsInFlight metrics.Counter
+}
+
+func newBrokerMetrics(r metrics.Registry, nameFor func(string) string) *brokerMetrics {
+	return &brokerMetrics{
+		incomingByteRate: metrics.GetOrRegisterMeter(nameFor("incoming-byte-rate"), r),
+		requestRate:      metrics.GetOrRegisterMeter(nameFor("request-rate"), r),
+		requestSize:      getOrRegisterHistogram(nameFor("request-size"), r),
+		requestLatency:   getOrRegisterHistogram(nameFor("request-latency-in-ms"), r),
+		outgoingByteRate: metrics.GetOrRegisterMeter(nameFor("outgoing-byte-rate"), r),
+		responseRate:     metrics.GetOrRegisterMeter(nameFor("response-rate"), r),
+		responseSize:     getOrRegisterHistogram(nameFor("response-size"), r),
+		requestsInFlight: metrics.GetOrRegisterCounter(nameFor("requests-in-flight"), r),
+	}
+}
+
+// connectionMetrics holds the connection-level metrics of one broker, or of all
+// brokers sharing the registry. Unlike brokerMetrics they are needed before a
+// connection exists, so they are looked up for each connection event.
+type connectionMetrics struct {
+	attemptRate           metrics.Meter
+	creationRate          metrics.Meter
+	closeRate             metrics.Meter
+	failureRate           metrics.Meter
+	authenticationFailure metrics.Meter
+	handshakeLatency      metrics.Histogram
+	connectionCount       metrics.Counter
+}
+
+func newConnectionMetrics(r metrics.Registry, nameFor func(string) string) *connectionMetrics {
+	return &connectionMetrics{
+		attemptRate:           metrics.GetOrRegisterMeter(nameFor("connection-attempt-rate"), r),
+		creationRate:          metrics.GetOrRegisterMeter(nameFor("connection-creation-rate"), r),
+		closeRate:             metrics.GetOrRegisterMeter(nameFor("connection-close-rate"), r),
+		failureRate:           metrics.GetOrRegisterMeter(nameFor("connection-failure-rate"), r),
+		authenticationFailure: metrics.GetOrRegisterMeter(nameFor("failed-authentication-rate"), r),
+		handshakeLatency:      getOrRegisterHistogram(nameFor("handshake-latency-in-ms"), r),
+		connectionCount:       metrics.GetOrRegisterCounter(nameFor("connection-count"), r),
+	}
+}
@@ -0,0 +1,28 @@
+package sarama
+
+import (
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+func TestMetricNames(t *testing.T) {
+	if name := getMetricNameForTopic("batch-size", "my.topic.name"); name != "batch-size-for-topic-my_topic_name" {
+		t.Error("Unexpected topic metric name", name)
+	}
+
+	broker := &Broker{id: 3}
+	if name := getMetricNameForBroker("request-rate", broker); name != "request-rate-for-broker-3" {
+		t.Error("Unexpected broker metric name", name)
+	}
+}
+
+func TestGetOrRegisterHistogramReusesMetric(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	first := getOrRegisterHistogram("request-size", registry)
+	first.Update(42)
+	if second := getOrRegisterHistogram("request-size", registry); second.Count() != 1 {
+		t.Error("Expected the registered histogram to be returned, got a fresh one")
+	}
+}
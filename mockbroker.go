@@ -0,0 +1,333 @@
+package sarama
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	expectationTimeout = 500 * time.Millisecond
+)
+
+// TestReporter is the part of *testing.T and *testing.B that a MockBroker and
+// the mock responses use to report problems, so that they don't depend on the
+// testing package.
+type TestReporter interface {
+	Error(...interface{})
+	Errorf(string, ...interface{})
+	Fatal(...interface{})
+	Fatalf(string, ...interface{})
+}
+
+type requestHandlerFunc func(req *request) (res encoder)
+
+// MockBroker is a mock Kafka broker, for testing code that uses Sarama without
+// a Kafka cluster. It consists of a TCP server on a kernel-selected localhost
+// port that can accept many connections. It reads Kafka requests from that
+// connection and passes them to the handler set with SetHandlerByMap, which
+// generates the responses with MockResponse builders such as
+// MockMetadataResponse and MockFetchResponse. Canned responses, such as those of
+// the group APIs, can be returned with a MockWrapper or a MockSequence. If no
+// handler has been set then the broker returns responses set by the Returns
+// function in the exact order they were provided (if a response has a len of
+// 0, nothing is sent, and the client request will timeout in this case).
+// ApiVersionsRequests are answered with a MockApiVersionsResponse, advertising
+// every API Sarama supports, unless the handler set with SetHandlerByMap
+// answers them.
+//
+// When running tests with one of these, it is strongly recommended to specify
+// a timeout to `go test` so that if the broker hangs waiting for a response,
+// the test panics.
+//
+// It is not necessary to prefix message length or correlation ID to your
+// response bytes, the server does that automatically as a convenience.
+type MockBroker struct {
+	brokerID     int32
+	port         int32
+	closing      chan none
+	stopper      chan none
+	expectations chan encoder
+	listener     net.Listener
+	t            TestReporter
+	latency      time.Duration
+	handler      requestHandlerFunc
+	concurrent   bool
+	history      []RequestResponse
+	lock         sync.Mutex
+}
+
+// RequestResponse is a request received by a MockBroker, and the response it
+// sent back, if any.
+type RequestResponse struct {
+	Request  requestBody
+	Response encoder
+}
+
+// SetLatency makes the broker wait for the given duration before handling each
+// request. It must be called before the broker receives requests.
+func (b *MockBroker) SetLatency(latency time.Duration) {
+	b.latency = latency
+}
+
+// SetHandlerByMap makes the broker answer each request with the response built
+// by the MockResponse found in handlerMap under the name of the request's type,
+// for example "MetadataRequest". Requests whose type isn't in the map are not
+// answered.
+func (b *MockBroker) SetHandlerByMap(handlerMap map[string]MockResponse) {
+	b.setHandler(func(req *request) (res encoder) {
+		reqTypeName := reflect.TypeOf(req.body).Elem().Name()
+		mockResponse := handlerMap[reqTypeName]
+		if mockResponse == nil {
+			if _, ok := req.body.(*ApiVersionsRequest); ok {
+				return NewMockApiVersionsResponse(b.t).For(req.body)
+			}
+			return nil
+		}
+		return mockResponse.For(req.body)
+	})
+}
+
+// BrokerID returns the ID given to NewMockBroker.
+func (b *MockBroker) BrokerID() int32 {
+	return b.brokerID
+}
+
+// History returns the requests received so far and their responses.
+func (b *MockBroker) History() []RequestResponse {
+	b.lock.Lock()
+	history := make([]RequestResponse, len(b.history))
+	copy(history, b.history)
+	b.lock.Unlock()
+	return history
+}
+
+// Port returns the port the broker listens on.
+func (b *MockBroker) Port() int32 {
+	return b.port
+}
+
+// Addr returns the address the broker listens on, to give to NewClient.
+func (b *MockBroker) Addr() string {
+	return b.listener.Addr().String()
+}
+
+// Close stops the broker, reporting an error if some of the responses given to
+// Returns were never sent.
+func (b *MockBroker) Close() {
+	close(b.expectations)
+	if len(b.expectations) > 0 {
+		buf := bytes.NewBufferString(fmt.Sprintf("mockbroker/%d: not all expectations were satisfied! Still waiting on:\n", b.BrokerID()))
+		for e := range b.expectations {
+			_, _ = fmt.Fprintf(buf, "%+v\n", e)
+		}
+		b.t.Error(buf.String())
+	}
+	close(b.closing)
+	<-b.stopper
+}
+
+// setHandler sets the specified function as the request handler. Whenever
+// a mock broker reads a request from the wire it passes the request to the
+// function and sends back whatever the handler function returns.
+func (b *MockBroker) setHandler(handler requestHandlerFunc) {
+	b.lock.Lock()
+	b.handler = handler
+	b.concurrent = false
+	b.lock.Unlock()
+}
+
+// setConcurrentHandler sets a request handler that is safe to call for several
+// requests at once, so that it may block a request until others are handled.
+func (b *MockBroker) setConcurrentHandler(handler requestHandlerFunc) {
+	b.lock.Lock()
+	b.handler = handler
+	b.concurrent = true
+	b.lock.Unlock()
+}
+
+// handle passes the request to the handler and records it in the history with
+// the response. Handlers are called one request at a time, but for those set
+// with setConcurrentHandler.
+func (b *MockBroker) handle(req *request) (res encoder) {
+	b.lock.Lock()
+	if b.concurrent {
+		handler := b.handler
+		b.lock.Unlock()
+		res = handler(req)
+		b.lock.Lock()
+	} else {
+		res = b.handler(req)
+	}
+	b.history = append(b.history, RequestResponse{req.body, res})
+	b.lock.Unlock()
+	return res
+}
+
+func (b *MockBroker) serverLoop() {
+	defer close(b.stopper)
+	var err error
+	var conn net.Conn
+
+	go func() {
+		<-b.closing
+		_ = b.listener.Close()
+	}()
+
+	wg := &sync.WaitGroup{}
+	i := 0
+	for conn, err = b.listener.Accept(); err == nil; conn, err = b.listener.Accept() {
+		wg.Add(1)
+		go b.handleRequests(conn, i, wg)
+		i++
+	}
+	wg.Wait()
+	Logger.Printf("*** mockbroker/%d: listener closed, err=%v", b.BrokerID(), err)
+}
+
+func (b *MockBroker) handleRequests(conn net.Conn, idx int, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer func() {
+		_ = conn.Close()
+	}()
+	Logger.Printf("*** mockbroker/%d/%d: connection opened", b.BrokerID(), idx)
+	var err error
+
+	abort := make(chan none)
+	defer close(abort)
+	go func() {
+		select {
+		case <-b.closing:
+			_ = conn.Close()
+		case <-abort:
+		}
+	}()
+
+	resHeader := make([]byte, 8)
+	for {
+		req, err := decodeRequest(conn)
+		if err != nil {
+			Logger.Printf("*** mockbroker/%d/%d: invalid request: err=%+v, %+v", b.brokerID, idx, err, req)
+			b.serverError(err)
+			break
+		}
+
+		if b.latency > 0 {
+			time.Sleep(b.latency)
+		}
+
+		res := b.handle(req)
+
+		if res == nil {
+			Logger.Printf("*** mockbroker/%d/%d: ignored %+v", b.brokerID, idx, req)
+			continue
+		}
+		Logger.Printf("*** mockbroker/%d/%d: served %v -> %v", b.brokerID, idx, req, res)
+
+		encodedRes, err := encode(res)
+		if err != nil {
+			b.serverError(err)
+			break
+		}
+		if len(encodedRes) == 0 {
+			continue
+		}
+
+		binary.BigEndian.PutUint32(resHeader, uint32(len(encodedRes)+4))
+		binary.BigEndian.PutUint32(resHeader[4:], uint32(req.correlationID))
+		if _, err = conn.Write(resHeader); err != nil {
+			b.serverError(err)
+			break
+		}
+		if _, err = conn.Write(encodedRes); err != nil {
+			b.serverError(err)
+			break
+		}
+	}
+	Logger.Printf("*** mockbroker/%d/%d: connection closed, err=%v", b.BrokerID(), idx, err)
+}
+
+func (b *MockBroker) defaultRequestHandler(req *request) (res encoder) {
+	if _, ok := req.body.(*ApiVersionsRequest); ok {
+		return NewMockApiVersionsResponse(b.t).For(req.body)
+	}
+	select {
+	case res, ok := <-b.expectations:
+		if !ok {
+			return nil
+		}
+		return res
+	case <-time.After(expectationTimeout):
+		return nil
+	}
+}
+
+func (b *MockBroker) serverError(err error) {
+	isConnectionClosedError := false
+	if _, ok := err.(*net.OpError); ok {
+		isConnectionClosedError = true
+	} else if err == io.EOF {
+		isConnectionClosedError = true
+	} else if err.Error() == "use of closed network connection" {
+		isConnectionClosedError = true
+	}
+
+	if isConnectionClosedError {
+		return
+	}
+
+	b.t.Error(err)
+}
+
+// NewMockBroker launches a fake Kafka broker. It takes a *testing.T as provided by the
+// test framework, with the ID the broker has in the metadata it returns. If an
+// error occurs it is simply logged to the *testing.T and the broker exits.
+func NewMockBroker(t TestReporter, brokerID int32) *MockBroker {
+	return NewMockBrokerAddr(t, brokerID, "localhost:0")
+}
+
+// NewMockBrokerAddr behaves like NewMockBroker but listens on the address you give
+// it rather than just some ephemeral port.
+func NewMockBrokerAddr(t TestReporter, brokerID int32, addr string) *MockBroker {
+	var err error
+
+	broker := &MockBroker{
+		closing:      make(chan none),
+		stopper:      make(chan none),
+		t:            t,
+		brokerID:     brokerID,
+		expectations: make(chan encoder, 512),
+	}
+	broker.handler = broker.defaultRequestHandler
+
+	broker.listener, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Logger.Printf("*** mockbroker/%d listening on %s\n", brokerID, broker.listener.Addr().String())
+	_, portStr, err := net.SplitHostPort(broker.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := strconv.ParseInt(portStr, 10, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker.port = int32(tmp)
+
+	go broker.serverLoop()
+
+	return broker
+}
+
+// Returns queues a response to send to the next request that no handler
+// answers. Requests are answered in the order their responses were queued.
+func (b *MockBroker) Returns(e encoder) {
+	b.expectations <- e
+}
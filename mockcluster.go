@@ -0,0 +1,1444 @@
+package sarama
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The states of a group of a MockCluster, as reported by DescribeGroups.
+const (
+	mockGroupEmpty              = "Empty"
+	mockGroupPreparingRebalance = "PreparingRebalance"
+	mockGroupAwaitingSync       = "AwaitingSync"
+	mockGroupStable             = "Stable"
+	mockGroupDead               = "Dead"
+)
+
+// MockCluster is an in-memory fake Kafka cluster made of MockBrokers, for
+// testing failover logic, such as leader changes and rebalances, without a
+// Kafka cluster. Unlike brokers given canned responses, it keeps state: the
+// messages produced to every partition, at real offsets, the partitions'
+// leaders, and the groups with their members and committed offsets.
+//
+// The brokers answer metadata, produce, fetch, offset, consumer metadata,
+// offset commit and fetch, delete records, producer ID, transaction, and the
+// group requests. Deleting records moves the oldest offset of a partition.
+// Record batches of idempotent producers are checked for their sequence
+// numbers, as brokers do: a batch written before is answered with
+// ErrDuplicateSequenceNumber and not written again, and one that skips sequence
+// numbers with ErrOutOfOrderSequenceNumber. Transactional batches are refused
+// with ErrInvalidTxnState unless their partition was added to the ongoing
+// transaction of their producer, and the offsets committed in a transaction
+// only take effect once it commits. Ending a transaction writes its markers,
+// and fetches reading committed messages stop at the first ongoing transaction
+// and list the aborted ones. Fetches from Kafka 1.1 on happen in fetch
+// sessions, which EvictFetchSessions has the brokers forget. From Kafka 2.3,
+// followers set with SetFollowers serve fetches too, and leaders redirect
+// consumers to the follower in their rack, as set with SetRack. A broker answers requests
+// for partitions it doesn't lead with ErrNotLeaderForPartition, and group
+// requests for groups it doesn't coordinate with ErrNotCoordinatorForConsumer,
+// as it does transaction requests for transactional IDs, so that moving a leader with SetLeader or a group with SetCoordinator has the
+// client fail over as it would with a real cluster, as do brokers stopped
+// with StopBroker. Topics aren't created
+// automatically; create them with CreateTopic.
+//
+// Groups rebalance the way they do in Kafka 0.9: a JoinGroup request is only
+// answered once every member has rejoined, or else its session timeout has
+// passed and the members that didn't rejoin are removed. The protocol is the
+// first by name that all members support. Members that don't heartbeat within
+// their session timeout are removed too.
+type MockCluster struct {
+	t            TestReporter
+	brokers      []*MockBroker
+	lock         sync.Mutex
+	cond         *sync.Cond
+	closed       bool
+	topics       map[string][]*mockPartition
+	groups       map[string]*mockGroup
+	coordinators map[string]int32
+	producerIDs  int64
+	txns         map[string]*mockTxn
+
+	fetchSessions  map[int32]*mockFetchSession
+	fetchSessionID int32
+
+	racks map[int32]string
+}
+
+// mockFetchSession is a fetch session of a broker, with the fetch offset and
+// size of each of its partitions.
+type mockFetchSession struct {
+	brokerID   int32
+	epoch      int32
+	partitions map[string]map[int32]*fetchRequestBlock
+}
+
+// mockAbortedTxn is an aborted transaction of a partition.
+type mockAbortedTxn struct {
+	AbortedTransaction
+	lastOffset int64 // the offset of its marker
+}
+
+// mockTxn is the transactional state of a transactional ID.
+type mockTxn struct {
+	producerID    int64
+	producerEpoch int16
+	// partitions holds the partitions added to the ongoing transaction
+	partitions map[string]map[int32]bool
+	// offsets holds the offsets committed in the ongoing transaction, by group
+	offsets map[string]map[string]map[int32]*OffsetFetchResponseBlock
+}
+
+// reset ends the ongoing transaction.
+func (txn *mockTxn) reset() {
+	txn.partitions = make(map[string]map[int32]bool)
+	txn.offsets = make(map[string]map[string]map[int32]*OffsetFetchResponseBlock)
+}
+
+type mockPartition struct {
+	leader int32
+	// followers holds the IDs of the other replicas, which serve fetches of
+	// version 11 and later
+	followers []int32
+	// messages holds the messages, and nil for the markers ending transactions
+	messages []*Message
+	// logStart is the offset of the oldest message not deleted
+	logStart int64
+	// headers holds the headers of each of the messages
+	headers [][]*RecordHeader
+	// txnProducers holds the producer ID of each message written in a
+	// transaction, and of each marker, or -1
+	txnProducers []int64
+	// ongoing holds the first offset of the ongoing transaction of each
+	// producer ID, and aborted the aborted transactions, up to their markers
+	ongoing map[int64]int64
+	aborted []mockAbortedTxn
+	// sequences holds the sequence number of the next batch of each producer
+	// ID
+	sequences map[int64]int32
+}
+
+type mockGroup struct {
+	protocolType string
+	protocol     string
+	state        string
+	generation   int32
+	leader       string
+	members      map[string]*mockGroupMember
+	joinDeadline time.Time
+	nextMemberID int
+	offsets      map[string]map[int32]*OffsetFetchResponseBlock
+}
+
+type mockGroupMember struct {
+	clientID       string
+	sessionTimeout time.Duration
+	lastSeen       time.Time
+	protocols      map[string][]byte
+	joined         bool
+	assignment     []byte
+}
+
+// NewMockCluster launches a fake cluster of the given number of brokers, with
+// IDs from 1. Errors are reported to t.
+func NewMockCluster(t TestReporter, brokers int) *MockCluster {
+	c := &MockCluster{
+		t:            t,
+		topics:       make(map[string][]*mockPartition),
+		groups:       make(map[string]*mockGroup),
+		coordinators: make(map[string]int32),
+		txns:         make(map[string]*mockTxn),
+
+		fetchSessions: make(map[int32]*mockFetchSession),
+		racks:         make(map[int32]string),
+	}
+	c.cond = sync.NewCond(&c.lock)
+
+	for i := 1; i <= brokers; i++ {
+		broker := NewMockBroker(t, int32(i))
+		broker.setConcurrentHandler(func(req *request) encoder {
+			return c.handle(broker.BrokerID(), req)
+		})
+		c.brokers = append(c.brokers, broker)
+	}
+	return c
+}
+
+// Addrs returns the addresses of the brokers, to give to NewClient.
+func (c *MockCluster) Addrs() []string {
+	addrs := make([]string, len(c.brokers))
+	for i, broker := range c.brokers {
+		addrs[i] = broker.Addr()
+	}
+	return addrs
+}
+
+// Broker returns the broker of the given ID, for example for its History.
+func (c *MockCluster) Broker(brokerID int32) *MockBroker {
+	for _, broker := range c.brokers {
+		if broker.BrokerID() == brokerID {
+			return broker
+		}
+	}
+	return nil
+}
+
+// CreateTopic creates a topic with the given number of partitions, whose
+// leaders are spread over the brokers.
+func (c *MockCluster) CreateTopic(topic string, partitions int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.topics[topic] != nil {
+		c.t.Errorf("mockcluster: topic %s already exists", topic)
+		return
+	}
+	c.topics[topic] = make([]*mockPartition, partitions)
+	for i := range c.topics[topic] {
+		c.topics[topic][i] = &mockPartition{leader: c.brokers[i%len(c.brokers)].BrokerID()}
+	}
+}
+
+// SetLeader moves the leadership of a partition to the broker of the given ID,
+// or -1 to leave the partition without a leader.
+func (c *MockCluster) SetLeader(topic string, partition, brokerID int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p := c.partition(topic, partition)
+	if p == nil {
+		c.t.Errorf("mockcluster: partition %s/%d doesn't exist", topic, partition)
+		return
+	}
+	p.leader = brokerID
+	c.cond.Broadcast()
+}
+
+// StopBroker stops the broker of the given ID, as if it crashed: requests in
+// flight to it fail, metadata no longer lists it, and the partitions it led
+// are left without a leader until SetLeader moves them.
+func (c *MockCluster) StopBroker(brokerID int32) {
+	c.lock.Lock()
+	var stopped *MockBroker
+	brokers := make([]*MockBroker, 0, len(c.brokers))
+	for _, broker := range c.brokers {
+		if broker.BrokerID() == brokerID {
+			stopped = broker
+		} else {
+			brokers = append(brokers, broker)
+		}
+	}
+	if stopped == nil {
+		c.lock.Unlock()
+		c.t.Errorf("mockcluster: broker %d doesn't exist", brokerID)
+		return
+	}
+	c.brokers = brokers
+	for _, partitions := range c.topics {
+		for _, p := range partitions {
+			if p.leader == brokerID {
+				p.leader = -1
+			}
+			followers := p.followers[:0]
+			for _, follower := range p.followers {
+				if follower != brokerID {
+					followers = append(followers, follower)
+				}
+			}
+			p.followers = followers
+		}
+	}
+	c.cond.Broadcast()
+	c.lock.Unlock()
+
+	stopped.Close()
+}
+
+// SetFollowers sets the replicas of a partition besides its leader, which
+// consumers may fetch from.
+func (c *MockCluster) SetFollowers(topic string, partition int32, brokerIDs ...int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p := c.partition(topic, partition)
+	if p == nil {
+		c.t.Errorf("mockcluster: partition %s/%d doesn't exist", topic, partition)
+		return
+	}
+	p.followers = brokerIDs
+}
+
+// SetRack sets the rack of the broker of the given ID. Leaders redirect
+// consumers in another rack to the first follower in theirs.
+func (c *MockCluster) SetRack(brokerID int32, rack string) {
+	c.lock.Lock()
+	c.racks[brokerID] = rack
+	c.lock.Unlock()
+}
+
+// Leader returns the ID of the leader of a partition, or -1 if it has none.
+func (c *MockCluster) Leader(topic string, partition int32) int32 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if p := c.partition(topic, partition); p != nil {
+		return p.leader
+	}
+	return -1
+}
+
+// AddMessage appends a message to a partition, as if it was produced, and
+// returns its offset.
+func (c *MockCluster) AddMessage(topic string, partition int32, key, value Encoder) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p := c.partition(topic, partition)
+	if p == nil {
+		c.t.Errorf("mockcluster: partition %s/%d doesn't exist", topic, partition)
+		return -1
+	}
+	msg := new(Message)
+	if key != nil {
+		msg.Key, _ = key.Encode()
+	}
+	if value != nil {
+		msg.Value, _ = value.Encode()
+	}
+	p.appendMessage(msg, nil)
+	c.cond.Broadcast()
+	return int64(len(p.messages) - 1)
+}
+
+// Messages returns the messages of a partition, the offset of each being its
+// index. The markers that end transactions take offsets too, and are nil.
+func (c *MockCluster) Messages(topic string, partition int32) []*Message {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	p := c.partition(topic, partition)
+	if p == nil {
+		return nil
+	}
+	messages := make([]*Message, len(p.messages))
+	copy(messages, p.messages)
+	return messages
+}
+
+// SetCoordinator moves the coordination of a group, or of a transactional ID,
+// to the broker of the given ID. They are otherwise coordinated by a broker
+// chosen by hashing their name.
+func (c *MockCluster) SetCoordinator(group string, brokerID int32) {
+	c.lock.Lock()
+	c.coordinators[group] = brokerID
+	c.cond.Broadcast()
+	c.lock.Unlock()
+}
+
+// EvictFetchSessions has the brokers forget their fetch sessions, as brokers
+// short of room for sessions do, so that the next fetch of each session fails
+// with ErrFetchSessionIDNotFound.
+func (c *MockCluster) EvictFetchSessions() {
+	c.lock.Lock()
+	c.fetchSessions = make(map[int32]*mockFetchSession)
+	c.lock.Unlock()
+}
+
+// Close stops the brokers, answering the requests that wait for a rebalance or
+// for messages to fetch.
+func (c *MockCluster) Close() {
+	c.lock.Lock()
+	c.closed = true
+	c.cond.Broadcast()
+	c.lock.Unlock()
+
+	for _, broker := range c.brokers {
+		broker.Close()
+	}
+}
+
+func (c *MockCluster) handle(brokerID int32, req *request) encoder {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch body := req.body.(type) {
+	case *MetadataRequest:
+		return c.metadata(body)
+	case *ProduceRequest:
+		return c.produce(brokerID, body)
+	case *FetchRequest:
+		return c.fetch(brokerID, body)
+	case *OffsetRequest:
+		return c.offsets(brokerID, body)
+	case *ConsumerMetadataRequest:
+		return c.consumerMetadata(body)
+	case *OffsetCommitRequest:
+		return c.commitOffsets(brokerID, body)
+	case *OffsetFetchRequest:
+		return c.fetchOffsets(brokerID, body)
+	case *JoinGroupRequest:
+		return c.joinGroup(brokerID, req.clientID, body)
+	case *SyncGroupRequest:
+		return c.syncGroup(brokerID, body)
+	case *HeartbeatRequest:
+		return c.heartbeat(brokerID, body)
+	case *LeaveGroupRequest:
+		return c.leaveGroup(brokerID, body)
+	case *DescribeGroupsRequest:
+		return c.describeGroups(brokerID, body)
+	case *ListGroupsRequest:
+		return c.listGroups(brokerID)
+	case *DeleteRecordsRequest:
+		return c.deleteRecords(brokerID, body)
+	case *InitProducerIDRequest:
+		return c.initProducerID(brokerID, body)
+	case *AddPartitionsToTxnRequest:
+		return c.addPartitionsToTxn(brokerID, body)
+	case *AddOffsetsToTxnRequest:
+		return c.addOffsetsToTxn(brokerID, body)
+	case *TxnOffsetCommitRequest:
+		return c.txnOffsetCommit(brokerID, body)
+	case *EndTxnRequest:
+		return c.endTxn(brokerID, body)
+	case *ApiVersionsRequest:
+		return NewMockApiVersionsResponse(c.t).For(body)
+	}
+	Logger.Printf("*** mockcluster/%d: unsupported request %T", brokerID, req.body)
+	return nil
+}
+
+// wait waits for a change to the cluster until the deadline, returning false if
+// the deadline has passed or the cluster is closed. It must be called with the
+// lock held.
+func (c *MockCluster) wait(deadline time.Time) bool {
+	d := deadline.Sub(time.Now())
+	if d <= 0 || c.closed {
+		return false
+	}
+	timer := time.AfterFunc(d, func() {
+		c.lock.Lock()
+		c.cond.Broadcast()
+		c.lock.Unlock()
+	})
+	c.cond.Wait()
+	timer.Stop()
+	return true
+}
+
+func (c *MockCluster) partition(topic string, partition int32) *mockPartition {
+	partitions := c.topics[topic]
+	if partition < 0 || int(partition) >= len(partitions) {
+		return nil
+	}
+	return partitions[partition]
+}
+
+// partitionError returns the error a broker answers a request for a partition
+// with, if any.
+func (c *MockCluster) partitionError(brokerID int32, topic string, partition int32) KError {
+	p := c.partition(topic, partition)
+	switch {
+	case p == nil:
+		return ErrUnknownTopicOrPartition
+	case p.leader < 0:
+		return ErrLeaderNotAvailable
+	case p.leader != brokerID:
+		return ErrNotLeaderForPartition
+	}
+	return ErrNoError
+}
+
+func (c *MockCluster) metadata(req *MetadataRequest) encoder {
+	// the first broker stands in as the controller
+	res := &MetadataResponse{Version: req.Version, ControllerID: c.brokers[0].BrokerID()}
+	for _, broker := range c.brokers {
+		res.AddBroker(broker.Addr(), broker.BrokerID())
+		if rack, ok := c.racks[broker.BrokerID()]; ok {
+			res.Brokers[len(res.Brokers)-1].rack = &rack
+		}
+	}
+
+	topics := req.Topics
+	if len(topics) == 0 {
+		for topic := range c.topics {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+	}
+	for _, topic := range topics {
+		partitions := c.topics[topic]
+		if partitions == nil {
+			res.AddTopic(topic, ErrUnknownTopicOrPartition)
+			continue
+		}
+		for id, p := range partitions {
+			if p.leader < 0 {
+				res.AddTopicPartition(topic, int32(id), -1, []int32{}, []int32{}, ErrLeaderNotAvailable)
+				continue
+			}
+			replicas := append([]int32{p.leader}, p.followers...)
+			res.AddTopicPartition(topic, int32(id), p.leader, replicas, replicas, ErrNoError)
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) produce(brokerID int32, req *ProduceRequest) encoder {
+	res := &ProduceResponse{Version: req.Version, Blocks: make(map[string]map[int32]*ProduceResponseBlock)}
+	appended := func(topic string, partition int32, appendTo func(p *mockPartition) KError) {
+		if res.Blocks[topic] == nil {
+			res.Blocks[topic] = make(map[int32]*ProduceResponseBlock)
+		}
+		block := &ProduceResponseBlock{Err: c.partitionError(brokerID, topic, partition), Offset: -1, StartOffset: -1}
+		if block.Err == ErrNoError {
+			p := c.partition(topic, partition)
+			offset := int64(len(p.messages))
+			if block.Err = appendTo(p); block.Err == ErrNoError {
+				block.Offset, block.StartOffset = offset, p.logStart
+			}
+		}
+		res.Blocks[topic][partition] = block
+	}
+	for topic, partitions := range req.msgSets {
+		for partition, set := range partitions {
+			appended(topic, partition, func(p *mockPartition) KError {
+				p.appendMessages(set)
+				return ErrNoError
+			})
+		}
+	}
+	for topic, partitions := range req.recordBatches {
+		for partition, batch := range partitions {
+			appended(topic, partition, func(p *mockPartition) KError {
+				if batch.Transactional && !c.inTxn(batch.ProducerID, topic, partition) {
+					return ErrInvalidTxnState
+				}
+				return p.appendRecords(batch)
+			})
+		}
+	}
+	c.cond.Broadcast()
+
+	if req.RequiredAcks == NoResponse {
+		return nil
+	}
+	return res
+}
+
+// appendMessages appends the messages of the set, decompressed, so that each
+// gets an offset of its own.
+// follows returns whether the broker of the given ID is a follower of the
+// partition.
+func (p *mockPartition) follows(brokerID int32) bool {
+	for _, id := range p.followers {
+		if id == brokerID {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredReadReplica returns the follower a leader redirects a fetch to: the
+// first in the rack of the consumer, unless the leader is in it, or -1.
+func (c *MockCluster) preferredReadReplica(brokerID int32, req *FetchRequest, p *mockPartition) int32 {
+	if req.Version < 11 || req.RackID == "" || brokerID != p.leader || c.racks[brokerID] == req.RackID {
+		return -1
+	}
+	for _, id := range p.followers {
+		if c.racks[id] == req.RackID {
+			return id
+		}
+	}
+	return -1
+}
+
+func (p *mockPartition) appendMessages(set *MessageSet) {
+	for _, block := range set.Messages {
+		if block.Msg.Set != nil {
+			p.appendMessages(block.Msg.Set)
+			continue
+		}
+		p.appendMessage(block.Msg, nil)
+	}
+}
+
+// appendRecords appends the records of the batch as messages of version 1,
+// unless the sequence number of the batch of an idempotent producer is not the
+// next one of the producer.
+func (p *mockPartition) appendRecords(batch *RecordBatch) KError {
+	if batch.ProducerID >= 0 {
+		next := p.sequences[batch.ProducerID]
+		switch {
+		case batch.FirstSequence < next:
+			return ErrDuplicateSequenceNumber
+		case batch.FirstSequence > next:
+			return ErrOutOfOrderSequenceNumber
+		}
+		if p.sequences == nil {
+			p.sequences = make(map[int64]int32)
+		}
+		p.sequences[batch.ProducerID] = sequenceAfter(next, len(batch.Records))
+	}
+
+	if batch.Transactional {
+		if _, ok := p.ongoing[batch.ProducerID]; !ok {
+			if p.ongoing == nil {
+				p.ongoing = make(map[int64]int64)
+			}
+			p.ongoing[batch.ProducerID] = int64(len(p.messages))
+		}
+	}
+	for _, record := range batch.Records {
+		msg := &Message{Codec: CompressionNone, Key: record.Key, Value: record.Value, Version: 1, Timestamp: batch.Timestamp(record)}
+		p.appendMessage(msg, record.Headers)
+		if batch.Transactional {
+			p.txnProducers[len(p.txnProducers)-1] = batch.ProducerID
+		}
+	}
+	return ErrNoError
+}
+
+func (p *mockPartition) appendMessage(msg *Message, headers []*RecordHeader) {
+	p.messages = append(p.messages, msg)
+	p.headers = append(p.headers, headers)
+	p.txnProducers = append(p.txnProducers, -1)
+}
+
+// appendMarker ends the ongoing transaction of the producer, if it wrote to
+// the partition.
+func (p *mockPartition) appendMarker(producerID int64, commit bool) {
+	first, ok := p.ongoing[producerID]
+	if !ok {
+		return
+	}
+	delete(p.ongoing, producerID)
+	if !commit {
+		p.aborted = append(p.aborted, mockAbortedTxn{
+			AbortedTransaction: AbortedTransaction{ProducerID: producerID, FirstOffset: first},
+			lastOffset:         int64(len(p.messages)),
+		})
+	}
+	p.messages = append(p.messages, nil)
+	p.headers = append(p.headers, nil)
+	p.txnProducers = append(p.txnProducers, producerID)
+}
+
+// abortedAt returns whether the marker at the offset aborted its transaction.
+func (p *mockPartition) abortedAt(offset int64) bool {
+	for _, txn := range p.aborted {
+		if txn.lastOffset == offset {
+			return true
+		}
+	}
+	return false
+}
+
+// lastStableOffset returns the offset of the first message of the ongoing
+// transactions, or else the high water mark.
+func (p *mockPartition) lastStableOffset() int64 {
+	offset := int64(len(p.messages))
+	for _, first := range p.ongoing {
+		if first < offset {
+			offset = first
+		}
+	}
+	return offset
+}
+
+func (c *MockCluster) fetch(brokerID int32, req *FetchRequest) encoder {
+	blocks, sessionID, incremental, kerr := c.fetchSession(brokerID, req)
+	if kerr != ErrNoError {
+		return &FetchResponse{Version: req.Version, Err: kerr}
+	}
+
+	deadline := time.Now().Add(time.Duration(req.MaxWaitTime) * time.Millisecond)
+	for {
+		res, size, failed := c.fetchMessages(brokerID, req, blocks)
+		res.SessionID = sessionID
+		if incremental {
+			// only the partitions with news are answered for
+			for topic, partitions := range res.Blocks {
+				for partition, block := range partitions {
+					if block.Err == ErrNoError && block.empty() && block.PreferredReadReplica < 0 {
+						delete(partitions, partition)
+					}
+				}
+				if len(partitions) == 0 {
+					delete(res.Blocks, topic)
+				}
+			}
+		}
+		if failed || size >= int(req.MinBytes) || !c.wait(deadline) {
+			return res
+		}
+	}
+}
+
+// fetchSession returns the partitions a fetch request fetches, which for one
+// in a fetch session are all those of the session, with the ID of its session
+// and whether the request is incremental, creating or updating the session.
+func (c *MockCluster) fetchSession(brokerID int32, req *FetchRequest) (blocks map[string]map[int32]*fetchRequestBlock, sessionID int32, incremental bool, kerr KError) {
+	if req.Version < 7 || req.SessionEpoch <= 0 {
+		// a new session replaces the old one, and an epoch of -1 closes it
+		delete(c.fetchSessions, req.SessionID)
+		if req.Version < 7 || req.SessionEpoch < 0 {
+			return req.blocks, 0, false, ErrNoError
+		}
+		c.fetchSessionID++
+		session := &mockFetchSession{brokerID: brokerID, epoch: 1, partitions: make(map[string]map[int32]*fetchRequestBlock)}
+		for topic, partitions := range req.blocks {
+			session.partitions[topic] = make(map[int32]*fetchRequestBlock)
+			for partition, block := range partitions {
+				session.partitions[topic][partition] = block
+			}
+		}
+		c.fetchSessions[c.fetchSessionID] = session
+		return req.blocks, c.fetchSessionID, false, ErrNoError
+	}
+
+	session := c.fetchSessions[req.SessionID]
+	if session == nil || session.brokerID != brokerID {
+		return nil, 0, false, ErrFetchSessionIDNotFound
+	}
+	if req.SessionEpoch != session.epoch {
+		return nil, 0, false, ErrInvalidFetchSessionEpoch
+	}
+	session.epoch++
+	for topic, partitions := range req.forgotten {
+		for _, partition := range partitions {
+			delete(session.partitions[topic], partition)
+		}
+	}
+	for topic, partitions := range req.blocks {
+		if session.partitions[topic] == nil {
+			session.partitions[topic] = make(map[int32]*fetchRequestBlock)
+		}
+		for partition, block := range partitions {
+			session.partitions[topic][partition] = block
+		}
+	}
+	return session.partitions, req.SessionID, true, ErrNoError
+}
+
+// fetchMessages builds the response to a fetch request for the given blocks,
+// with at least one message for each partition that has messages from the
+// fetched offset, and returns it with the size of its messages and whether it
+// holds errors or redirections, to answer right away.
+func (c *MockCluster) fetchMessages(brokerID int32, req *FetchRequest, blocks map[string]map[int32]*fetchRequestBlock) (res *FetchResponse, size int, failed bool) {
+	res = &FetchResponse{Version: req.Version}
+	for topic, partitions := range blocks {
+		for partition, block := range partitions {
+			kerr := c.partitionError(brokerID, topic, partition)
+			p := c.partition(topic, partition)
+			if kerr == ErrNotLeaderForPartition && req.Version >= 11 && p.follows(brokerID) {
+				kerr = ErrNoError
+			}
+			if kerr == ErrNoError && (block.fetchOffset < p.logStart || block.fetchOffset > int64(len(p.messages))) {
+				kerr = ErrOffsetOutOfRange
+			}
+			res.AddError(topic, partition, kerr)
+			if kerr != ErrNoError {
+				failed = true
+				continue
+			}
+
+			frb := res.GetBlock(topic, partition)
+			frb.HighWaterMarkOffset = int64(len(p.messages))
+			frb.LastStableOffset = p.lastStableOffset()
+			if replica := c.preferredReadReplica(brokerID, req, p); replica >= 0 {
+				// redirected right away, without messages
+				frb.PreferredReadReplica = replica
+				failed = true
+				continue
+			}
+			// reading committed, the messages of ongoing transactions aren't
+			// returned yet
+			end := frb.HighWaterMarkOffset
+			if req.Isolation == ReadCommitted {
+				end = frb.LastStableOffset
+				for _, txn := range p.aborted {
+					if txn.lastOffset >= block.fetchOffset && txn.FirstOffset < end {
+						txn := txn.AbortedTransaction
+						frb.AbortedTransactions = append(frb.AbortedTransactions, &txn)
+					}
+				}
+			}
+			var batch *RecordBatch
+			blockSize := 0
+			for offset := block.fetchOffset; offset < end; offset++ {
+				msg := p.messages[offset]
+				if msg == nil && req.Version < 4 {
+					continue // markers are only fetched in record batches
+				}
+				// the offset, length, CRC, magic byte, attributes, key and value
+				msgSize := 26
+				if msg != nil {
+					msgSize += len(msg.Key) + len(msg.Value)
+				}
+				if blockSize > 0 && blockSize+msgSize > int(block.maxBytes) {
+					break
+				}
+				switch {
+				case req.Version >= 4:
+					// messages are batched by producer, and markers batched alone
+					producerID := p.txnProducers[offset]
+					if batch == nil || msg == nil || batch.Control || batch.ProducerID != producerID {
+						if batch != nil {
+							frb.RecordBatches = append(frb.RecordBatches, batch)
+						}
+						batch = newRecordBatch(CompressionNone)
+						batch.FirstOffset = offset
+						batch.ProducerID = producerID
+						batch.Transactional = producerID >= 0
+						batch.Control = msg == nil
+					}
+					if msg != nil {
+						batch.addRecord(&Record{Key: msg.Key, Value: msg.Value, Headers: p.headers[offset]}, msg.Timestamp)
+					} else if p.abortedAt(offset) {
+						batch.addRecord(newControlRecord(ControlRecordAbort), time.Time{})
+					} else {
+						batch.addRecord(newControlRecord(ControlRecordCommit), time.Time{})
+					}
+				case req.Version >= 2:
+					// the brokers convert the messages to the version the client fetches
+					if msg.Version < 1 {
+						msg = &Message{Codec: msg.Codec, Key: msg.Key, Value: msg.Value, Version: 1}
+					}
+					frb.MsgSet.Messages = append(frb.MsgSet.Messages, &MessageBlock{Offset: offset, Msg: msg})
+				default:
+					if msg.Version > 0 {
+						msg = &Message{Codec: msg.Codec, Key: msg.Key, Value: msg.Value}
+					}
+					frb.MsgSet.Messages = append(frb.MsgSet.Messages, &MessageBlock{Offset: offset, Msg: msg})
+				}
+				blockSize += msgSize
+			}
+			if batch != nil {
+				frb.RecordBatches = append(frb.RecordBatches, batch)
+			}
+			size += blockSize
+		}
+	}
+	return res, size, failed
+}
+
+func (c *MockCluster) offsets(brokerID int32, req *OffsetRequest) encoder {
+	res := &OffsetResponse{Version: req.Version}
+	for topic, partitions := range req.blocks {
+		for partition, block := range partitions {
+			kerr := c.partitionError(brokerID, topic, partition)
+			if kerr != ErrNoError {
+				res.AddTopicPartition(topic, partition, -1)
+				res.GetBlock(topic, partition).Err = kerr
+				continue
+			}
+
+			p := c.partition(topic, partition)
+			offset := p.logStart
+			switch {
+			case block.time == OffsetNewest:
+				offset = int64(len(p.messages))
+			case block.time >= 0 && req.Version >= 1:
+				// the first message with a timestamp at or after the time;
+				// those added with AddMessage have none
+				offset = -1
+				for i := p.logStart; i < int64(len(p.messages)); i++ {
+					if msg := p.messages[i]; msg != nil && !msg.Timestamp.IsZero() && msg.Timestamp.UnixNano()/int64(time.Millisecond) >= block.time {
+						offset = i
+						break
+					}
+				}
+			}
+			// a version 0 lookup by time returns the offset of the only log
+			// segment, the oldest
+			res.AddTopicPartition(topic, partition, offset)
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) deleteRecords(brokerID int32, req *DeleteRecordsRequest) encoder {
+	res := &DeleteRecordsResponse{}
+	for topic, partitions := range req.Topics {
+		for partition, offset := range partitions {
+			kerr := c.partitionError(brokerID, topic, partition)
+			if kerr != ErrNoError {
+				res.AddPartition(topic, partition, -1, kerr)
+				continue
+			}
+
+			p := c.partition(topic, partition)
+			if offset == -1 {
+				offset = int64(len(p.messages))
+			}
+			if offset < 0 || offset > int64(len(p.messages)) {
+				res.AddPartition(topic, partition, -1, ErrOffsetOutOfRange)
+				continue
+			}
+			if offset > p.logStart {
+				p.logStart = offset
+			}
+			res.AddPartition(topic, partition, p.logStart, ErrNoError)
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) coordinator(group string) *MockBroker {
+	if brokerID, ok := c.coordinators[group]; ok {
+		return c.Broker(brokerID)
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(group))
+	return c.brokers[hash.Sum32()%uint32(len(c.brokers))]
+}
+
+func (c *MockCluster) coordinates(brokerID int32, group string) bool {
+	coordinator := c.coordinator(group)
+	return coordinator != nil && coordinator.BrokerID() == brokerID
+}
+
+func (c *MockCluster) consumerMetadata(req *ConsumerMetadataRequest) encoder {
+	coordinator := c.coordinator(req.ConsumerGroup)
+	if coordinator == nil {
+		return &ConsumerMetadataResponse{Version: req.Version, Err: ErrConsumerCoordinatorNotAvailable}
+	}
+	return &ConsumerMetadataResponse{Version: req.Version, Coordinator: &Broker{id: coordinator.BrokerID(), addr: coordinator.Addr()}}
+}
+
+// group returns the group of the given ID, creating it if it doesn't exist.
+func (c *MockCluster) group(groupID string) *mockGroup {
+	g := c.groups[groupID]
+	if g == nil {
+		g = &mockGroup{
+			state:   mockGroupEmpty,
+			members: make(map[string]*mockGroupMember),
+			offsets: make(map[string]map[int32]*OffsetFetchResponseBlock),
+		}
+		c.groups[groupID] = g
+	}
+	return g
+}
+
+func (c *MockCluster) commitOffsets(brokerID int32, req *OffsetCommitRequest) encoder {
+	res := new(OffsetCommitResponse)
+	kerr := ErrNoError
+	var g *mockGroup
+	switch {
+	case !c.coordinates(brokerID, req.ConsumerGroup):
+		kerr = ErrNotCoordinatorForConsumer
+	case req.Version >= 1 && req.ConsumerGroupGeneration != GroupGenerationUndefined:
+		g = c.group(req.ConsumerGroup)
+		c.expireMembers(g)
+		if g.members[req.ConsumerID] == nil {
+			kerr = ErrUnknownMemberId
+		} else if req.ConsumerGroupGeneration != g.generation {
+			kerr = ErrIllegalGeneration
+		} else if g.state == mockGroupAwaitingSync {
+			// members commit the offsets of the partitions they release while
+			// the group prepares to rebalance, but not before they know their
+			// assignment
+			kerr = ErrRebalanceInProgress
+		}
+	default:
+		g = c.group(req.ConsumerGroup)
+	}
+
+	for topic, partitions := range req.blocks {
+		for partition, block := range partitions {
+			switch {
+			case kerr != ErrNoError:
+				res.AddError(topic, partition, kerr)
+			case c.partition(topic, partition) == nil:
+				res.AddError(topic, partition, ErrUnknownTopicOrPartition)
+			default:
+				if g.offsets[topic] == nil {
+					g.offsets[topic] = make(map[int32]*OffsetFetchResponseBlock)
+				}
+				g.offsets[topic][partition] = &OffsetFetchResponseBlock{Offset: block.offset, Metadata: block.metadata}
+				res.AddError(topic, partition, ErrNoError)
+			}
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) fetchOffsets(brokerID int32, req *OffsetFetchRequest) encoder {
+	res := &OffsetFetchResponse{Version: req.Version}
+	coordinates := c.coordinates(brokerID, req.ConsumerGroup)
+	g := c.groups[req.ConsumerGroup]
+	if req.Version >= 2 && !coordinates {
+		res.Err = ErrNotCoordinatorForConsumer
+	}
+	partitions := req.partitions
+	if req.Version >= 2 && len(partitions) == 0 && coordinates && g != nil {
+		// every partition the group committed an offset for
+		partitions = make(map[string][]int32)
+		for topic, offsets := range g.offsets {
+			for partition := range offsets {
+				partitions[topic] = append(partitions[topic], partition)
+			}
+		}
+	}
+	for topic, partitions := range partitions {
+		for _, partition := range partitions {
+			block := &OffsetFetchResponseBlock{Offset: -1}
+			if !coordinates {
+				block.Err = ErrNotCoordinatorForConsumer
+			} else if g != nil && g.offsets[topic][partition] != nil {
+				*block = *g.offsets[topic][partition]
+			}
+			res.AddBlock(topic, partition, block)
+		}
+	}
+	return res
+}
+
+// rebalance starts a rebalance of the group, which completes once all its
+// members have rejoined, or else the longest of their session timeouts passed.
+func (g *mockGroup) rebalance() {
+	if g.state == mockGroupPreparingRebalance {
+		return
+	}
+	var timeout time.Duration
+	for _, m := range g.members {
+		if m.sessionTimeout > timeout {
+			timeout = m.sessionTimeout
+		}
+	}
+	g.state = mockGroupPreparingRebalance
+	g.joinDeadline = time.Now().Add(timeout)
+}
+
+// completeJoin completes a rebalance, removing the members that didn't rejoin.
+func (g *mockGroup) completeJoin() {
+	now := time.Now()
+	for id, m := range g.members {
+		if !m.joined {
+			delete(g.members, id)
+		}
+		m.joined = false
+		m.lastSeen = now
+		m.assignment = nil
+	}
+
+	g.generation++
+	if len(g.members) == 0 {
+		g.state, g.protocol, g.leader = mockGroupEmpty, "", ""
+		return
+	}
+	g.state = mockGroupAwaitingSync
+	g.protocol = g.selectProtocol()
+	if g.members[g.leader] == nil {
+		ids := make([]string, 0, len(g.members))
+		for id := range g.members {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		g.leader = ids[0]
+	}
+}
+
+// selectProtocol returns the first protocol by name that all members support,
+// or "" if there is none.
+func (g *mockGroup) selectProtocol() string {
+	var protocols []string
+	for _, m := range g.members {
+		for protocol := range m.protocols {
+			protocols = append(protocols, protocol)
+		}
+		break
+	}
+	sort.Strings(protocols)
+
+	for _, protocol := range protocols {
+		supported := true
+		for _, m := range g.members {
+			if _, ok := m.protocols[protocol]; !ok {
+				supported = false
+				break
+			}
+		}
+		if supported {
+			return protocol
+		}
+	}
+	return ""
+}
+
+func (g *mockGroup) allJoined() bool {
+	for _, m := range g.members {
+		if !m.joined {
+			return false
+		}
+	}
+	return true
+}
+
+// expireMembers removes the members of a stable or syncing group that haven't
+// been heard of within their session timeout, and rebalances the group if there
+// were any.
+func (c *MockCluster) expireMembers(g *mockGroup) {
+	if g.state != mockGroupStable && g.state != mockGroupAwaitingSync {
+		return
+	}
+	now := time.Now()
+	expired := false
+	for id, m := range g.members {
+		if now.Sub(m.lastSeen) > m.sessionTimeout {
+			delete(g.members, id)
+			expired = true
+		}
+	}
+	if expired {
+		g.rebalance()
+		c.cond.Broadcast()
+	}
+}
+
+func (c *MockCluster) joinGroup(brokerID int32, clientID string, req *JoinGroupRequest) encoder {
+	if !c.coordinates(brokerID, req.GroupId) {
+		return &JoinGroupResponse{Err: ErrNotCoordinatorForConsumer}
+	}
+	if req.SessionTimeout <= 0 {
+		return &JoinGroupResponse{Err: ErrInvalidSessionTimeout}
+	}
+
+	g := c.group(req.GroupId)
+	c.expireMembers(g)
+	if len(g.members) > 0 && req.ProtocolType != g.protocolType {
+		return &JoinGroupResponse{Err: ErrInconsistentGroupProtocol}
+	}
+
+	memberID := req.MemberId
+	if memberID == "" {
+		g.nextMemberID++
+		memberID = fmt.Sprintf("%s-%d", clientID, g.nextMemberID)
+		g.members[memberID] = &mockGroupMember{clientID: clientID}
+	}
+	m := g.members[memberID]
+	if m == nil {
+		return &JoinGroupResponse{Err: ErrUnknownMemberId}
+	}
+	old := *m
+	m.protocols = req.GroupProtocols
+	if g.selectProtocol() == "" {
+		if old.protocols == nil {
+			delete(g.members, memberID)
+		} else {
+			*m = old
+		}
+		return &JoinGroupResponse{Err: ErrInconsistentGroupProtocol}
+	}
+
+	m.sessionTimeout = time.Duration(req.SessionTimeout) * time.Millisecond
+	m.lastSeen = time.Now()
+	m.joined = true
+	g.protocolType = req.ProtocolType
+	g.rebalance()
+	c.cond.Broadcast()
+
+	generation := g.generation
+	for g.state == mockGroupPreparingRebalance && g.generation == generation {
+		if g.allJoined() || !c.wait(g.joinDeadline) {
+			g.completeJoin()
+			c.cond.Broadcast()
+		}
+	}
+
+	if g.members[memberID] == nil {
+		return &JoinGroupResponse{Err: ErrUnknownMemberId}
+	}
+	res := &JoinGroupResponse{
+		GenerationId:  g.generation,
+		GroupProtocol: g.protocol,
+		LeaderId:      g.leader,
+		MemberId:      memberID,
+	}
+	if memberID == g.leader {
+		res.Members = make(map[string][]byte, len(g.members))
+		for id, member := range g.members {
+			res.Members[id] = member.protocols[g.protocol]
+		}
+	}
+	return res
+}
+
+// member returns the member of a group, or the error to answer a request for it
+// with.
+func (c *MockCluster) member(brokerID int32, groupID, memberID string, generation int32) (*mockGroup, *mockGroupMember, KError) {
+	if !c.coordinates(brokerID, groupID) {
+		return nil, nil, ErrNotCoordinatorForConsumer
+	}
+	g := c.groups[groupID]
+	if g == nil {
+		return nil, nil, ErrUnknownMemberId
+	}
+	c.expireMembers(g)
+	m := g.members[memberID]
+	if m == nil {
+		return nil, nil, ErrUnknownMemberId
+	}
+	m.lastSeen = time.Now()
+	if generation != g.generation {
+		return nil, nil, ErrIllegalGeneration
+	}
+	if g.state == mockGroupPreparingRebalance {
+		return nil, nil, ErrRebalanceInProgress
+	}
+	return g, m, ErrNoError
+}
+
+func (c *MockCluster) syncGroup(brokerID int32, req *SyncGroupRequest) encoder {
+	g, m, kerr := c.member(brokerID, req.GroupId, req.MemberId, req.GenerationId)
+	if kerr != ErrNoError {
+		return &SyncGroupResponse{Err: kerr}
+	}
+
+	if req.MemberId == g.leader && g.state == mockGroupAwaitingSync {
+		for id, member := range g.members {
+			member.assignment = req.GroupAssignments[id]
+		}
+		g.state = mockGroupStable
+		c.cond.Broadcast()
+	}
+
+	deadline := time.Now().Add(m.sessionTimeout)
+	for g.state == mockGroupAwaitingSync && g.generation == req.GenerationId {
+		if !c.wait(deadline) {
+			break
+		}
+	}
+	if g.state != mockGroupStable || g.generation != req.GenerationId || g.members[req.MemberId] == nil {
+		return &SyncGroupResponse{Err: ErrRebalanceInProgress}
+	}
+	return &SyncGroupResponse{MemberAssignment: m.assignment}
+}
+
+func (c *MockCluster) heartbeat(brokerID int32, req *HeartbeatRequest) encoder {
+	g, _, kerr := c.member(brokerID, req.GroupId, req.MemberId, req.GenerationId)
+	if kerr == ErrNoError && g.state == mockGroupAwaitingSync {
+		kerr = ErrRebalanceInProgress
+	}
+	return &HeartbeatResponse{Err: kerr}
+}
+
+func (c *MockCluster) leaveGroup(brokerID int32, req *LeaveGroupRequest) encoder {
+	if !c.coordinates(brokerID, req.GroupId) {
+		return &LeaveGroupResponse{Err: ErrNotCoordinatorForConsumer}
+	}
+	g := c.groups[req.GroupId]
+	if g == nil || g.members[req.MemberId] == nil {
+		return &LeaveGroupResponse{Err: ErrUnknownMemberId}
+	}
+
+	delete(g.members, req.MemberId)
+	if len(g.members) == 0 && g.state != mockGroupPreparingRebalance {
+		g.generation++
+		g.state, g.protocol, g.leader = mockGroupEmpty, "", ""
+	} else {
+		g.rebalance()
+	}
+	c.cond.Broadcast()
+	return &LeaveGroupResponse{}
+}
+
+func (c *MockCluster) describeGroups(brokerID int32, req *DescribeGroupsRequest) encoder {
+	res := new(DescribeGroupsResponse)
+	for _, groupID := range req.Groups {
+		description := &GroupDescription{GroupId: groupID, Members: make(map[string]*GroupMemberDescription)}
+		res.Groups = append(res.Groups, description)
+
+		g := c.groups[groupID]
+		switch {
+		case !c.coordinates(brokerID, groupID):
+			description.Err = ErrNotCoordinatorForConsumer
+			continue
+		case g == nil:
+			description.State = mockGroupDead
+			continue
+		}
+
+		c.expireMembers(g)
+		description.State = g.state
+		description.ProtocolType = g.protocolType
+		description.Protocol = g.protocol
+		for id, m := range g.members {
+			description.Members[id] = &GroupMemberDescription{
+				ClientId:         m.clientID,
+				MemberMetadata:   m.protocols[g.protocol],
+				MemberAssignment: m.assignment,
+			}
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) listGroups(brokerID int32) encoder {
+	res := &ListGroupsResponse{Groups: make(map[string]string)}
+	for groupID, g := range c.groups {
+		if c.coordinates(brokerID, groupID) {
+			res.Groups[groupID] = g.protocolType
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) initProducerID(brokerID int32, req *InitProducerIDRequest) encoder {
+	if req.TransactionalID == nil {
+		c.producerIDs++
+		return &InitProducerIDResponse{ProducerID: c.producerIDs}
+	}
+	if !c.coordinates(brokerID, *req.TransactionalID) {
+		return &InitProducerIDResponse{Err: ErrNotCoordinatorForConsumer, ProducerID: -1, ProducerEpoch: -1}
+	}
+
+	// a new producer of the transactional ID gets its producer ID with a new
+	// epoch, which aborts the ongoing transaction
+	txn := c.txns[*req.TransactionalID]
+	if txn == nil {
+		c.producerIDs++
+		txn = &mockTxn{producerID: c.producerIDs}
+		c.txns[*req.TransactionalID] = txn
+	} else {
+		c.writeMarkers(txn, false)
+		txn.producerEpoch++
+	}
+	txn.reset()
+	return &InitProducerIDResponse{ProducerID: txn.producerID, ProducerEpoch: txn.producerEpoch}
+}
+
+// txn returns the transactional state of a transactional ID, or the error a
+// transaction request for it by the given producer is answered with.
+func (c *MockCluster) txn(brokerID int32, transactionalID string, producerID int64, producerEpoch int16) (*mockTxn, KError) {
+	txn := c.txns[transactionalID]
+	switch {
+	case !c.coordinates(brokerID, transactionalID):
+		return nil, ErrNotCoordinatorForConsumer
+	case txn == nil || txn.producerID != producerID:
+		return nil, ErrInvalidProducerIDMapping
+	case txn.producerEpoch != producerEpoch:
+		return nil, ErrInvalidProducerEpoch
+	}
+	return txn, ErrNoError
+}
+
+// inTxn returns whether the partition was added to the ongoing transaction of
+// the producer.
+func (c *MockCluster) inTxn(producerID int64, topic string, partition int32) bool {
+	for _, txn := range c.txns {
+		if txn.producerID == producerID {
+			return txn.partitions[topic][partition]
+		}
+	}
+	return false
+}
+
+func (c *MockCluster) addPartitionsToTxn(brokerID int32, req *AddPartitionsToTxnRequest) encoder {
+	res := &AddPartitionsToTxnResponse{Errors: make(map[string][]*PartitionError)}
+	txn, kerr := c.txn(brokerID, req.TransactionalID, req.ProducerID, req.ProducerEpoch)
+	for topic, partitions := range req.TopicPartitions {
+		for _, partition := range partitions {
+			partitionErr := kerr
+			switch {
+			case partitionErr != ErrNoError:
+			case c.partition(topic, partition) == nil:
+				partitionErr = ErrUnknownTopicOrPartition
+			default:
+				if txn.partitions[topic] == nil {
+					txn.partitions[topic] = make(map[int32]bool)
+				}
+				txn.partitions[topic][partition] = true
+			}
+			res.Errors[topic] = append(res.Errors[topic], &PartitionError{Partition: partition, Err: partitionErr})
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) addOffsetsToTxn(brokerID int32, req *AddOffsetsToTxnRequest) encoder {
+	txn, kerr := c.txn(brokerID, req.TransactionalID, req.ProducerID, req.ProducerEpoch)
+	if kerr == ErrNoError && txn.offsets[req.GroupID] == nil {
+		txn.offsets[req.GroupID] = make(map[string]map[int32]*OffsetFetchResponseBlock)
+	}
+	return &AddOffsetsToTxnResponse{Err: kerr}
+}
+
+// txnOffsetCommit answers the requests the group coordinator gets for the
+// offsets committed in transactions, which it holds until they end.
+func (c *MockCluster) txnOffsetCommit(brokerID int32, req *TxnOffsetCommitRequest) encoder {
+	res := &TxnOffsetCommitResponse{Topics: make(map[string][]*PartitionError)}
+	kerr := ErrNoError
+	txn := c.txns[req.TransactionalID]
+	switch {
+	case !c.coordinates(brokerID, req.GroupID):
+		kerr = ErrNotCoordinatorForConsumer
+	case txn == nil || txn.producerID != req.ProducerID:
+		kerr = ErrInvalidProducerIDMapping
+	case txn.producerEpoch != req.ProducerEpoch:
+		kerr = ErrInvalidProducerEpoch
+	case txn.offsets[req.GroupID] == nil:
+		// the group has to be added to the transaction first
+		kerr = ErrInvalidTxnState
+	}
+
+	for topic, partitions := range req.Topics {
+		for _, partition := range partitions {
+			partitionErr := kerr
+			switch {
+			case partitionErr != ErrNoError:
+			case c.partition(topic, partition.Partition) == nil:
+				partitionErr = ErrUnknownTopicOrPartition
+			default:
+				offsets := txn.offsets[req.GroupID]
+				if offsets[topic] == nil {
+					offsets[topic] = make(map[int32]*OffsetFetchResponseBlock)
+				}
+				block := &OffsetFetchResponseBlock{Offset: partition.Offset}
+				if partition.Metadata != nil {
+					block.Metadata = *partition.Metadata
+				}
+				offsets[topic][partition.Partition] = block
+			}
+			res.Topics[topic] = append(res.Topics[topic], &PartitionError{Partition: partition.Partition, Err: partitionErr})
+		}
+	}
+	return res
+}
+
+func (c *MockCluster) endTxn(brokerID int32, req *EndTxnRequest) encoder {
+	txn, kerr := c.txn(brokerID, req.TransactionalID, req.ProducerID, req.ProducerEpoch)
+	if kerr != ErrNoError {
+		return &EndTxnResponse{Err: kerr}
+	}
+
+	c.writeMarkers(txn, req.Committed)
+	if req.Committed {
+		for groupID, topics := range txn.offsets {
+			g := c.group(groupID)
+			for topic, partitions := range topics {
+				if g.offsets[topic] == nil {
+					g.offsets[topic] = make(map[int32]*OffsetFetchResponseBlock)
+				}
+				for partition, block := range partitions {
+					g.offsets[topic][partition] = block
+				}
+			}
+		}
+	}
+	txn.reset()
+	return &EndTxnResponse{}
+}
+
+// writeMarkers ends the ongoing transaction on the partitions added to it.
+func (c *MockCluster) writeMarkers(txn *mockTxn, commit bool) {
+	for topic, partitions := range txn.partitions {
+		for partition := range partitions {
+			c.partition(topic, partition).appendMarker(txn.producerID, commit)
+		}
+	}
+	c.cond.Broadcast()
+}
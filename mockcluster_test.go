@@ -0,0 +1,431 @@
+package sarama
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newMockClusterConfig() *Config {
+	config := NewConfig()
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+	config.Producer.Retry.Backoff = 10 * time.Millisecond
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = NewManualPartitioner
+	config.Consumer.Retry.Backoff = 10 * time.Millisecond
+	return config
+}
+
+func TestMockClusterProducesAndConsumesAtRealOffsets(t *testing.T) {
+	cluster := NewMockCluster(t, 3)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 3)
+
+	producer, err := NewSyncProducer(cluster.Addrs(), newMockClusterConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 3; i++ {
+		_, offset, err := producer.SendMessage(&ProducerMessage{Topic: "my_topic", Partition: 1, Value: StringEncoder("value")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != i {
+			t.Errorf("Expected message %d to be produced at offset %d, got %d", i, i, offset)
+		}
+	}
+	safeClose(t, producer)
+	if messages := cluster.Messages("my_topic", 1); len(messages) != 3 {
+		t.Error("Expected 3 messages on the partition, got", len(messages))
+	}
+
+	consumer, err := NewConsumer(cluster.Addrs(), newMockClusterConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	pc, err := consumer.ConsumePartition("my_topic", 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, pc)
+	for i := int64(1); i < 3; i++ {
+		select {
+		case msg := <-pc.Messages():
+			if msg.Offset != i || string(msg.Value) != "value" {
+				t.Errorf("Expected the message at offset %d, got %d %q", i, msg.Offset, msg.Value)
+			}
+		case err := <-pc.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for message", i)
+		}
+	}
+	if hwm := pc.HighWaterMarkOffset(); hwm != 3 {
+		t.Error("Expected a high water mark of 3, got", hwm)
+	}
+}
+
+func TestMockClusterMessageFormats(t *testing.T) {
+	timestamp := time.Unix(1500000000, 0)
+	for _, version := range []KafkaVersion{V0_10_0_0, V0_11_0_0} {
+		cluster := NewMockCluster(t, 1)
+		cluster.CreateTopic("my_topic", 1)
+
+		config := newMockClusterConfig()
+		config.Version = version
+		config.Producer.Compression = CompressionGZIP
+		config.Producer.Flush.Messages = 3
+		producer, err := NewAsyncProducer(cluster.Addrs(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			msg := &ProducerMessage{Topic: "my_topic", Value: StringEncoder("value"), Timestamp: timestamp.Add(time.Duration(i) * time.Second)}
+			if version.IsAtLeast(V0_11_0_0) {
+				msg.Headers = []RecordHeader{{Key: []byte("header"), Value: []byte(fmt.Sprint(i))}}
+			}
+			producer.Input() <- msg
+		}
+		for i := 0; i < 3; i++ {
+			select {
+			case <-producer.Successes():
+			case err := <-producer.Errors():
+				t.Fatal(version, err)
+			}
+		}
+		safeClose(t, producer)
+
+		for _, consumerVersion := range []KafkaVersion{V0_8_2_0, version} {
+			config := newMockClusterConfig()
+			config.Version = consumerVersion
+			consumer, err := NewConsumer(cluster.Addrs(), config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pc, err := consumer.ConsumePartition("my_topic", 0, OffsetOldest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := int64(0); i < 3; i++ {
+				var msg *ConsumerMessage
+				select {
+				case msg = <-pc.Messages():
+				case <-time.After(5 * time.Second):
+					t.Fatal("Timed out waiting for message", i, "at version", consumerVersion)
+				}
+				if msg.Offset != i || string(msg.Value) != "value" {
+					t.Errorf("Expected the message at offset %d at version %v, got %d %q", i, consumerVersion, msg.Offset, msg.Value)
+				}
+				if consumerVersion == V0_8_2_0 {
+					if !msg.Timestamp.IsZero() || msg.Headers != nil {
+						t.Error("Expected messages of version 0 without timestamps or headers, got", msg.Timestamp, msg.Headers)
+					}
+					continue
+				}
+				if expected := timestamp.Add(time.Duration(i) * time.Second); !msg.Timestamp.Equal(expected) {
+					t.Errorf("Expected the message at offset %d at version %v to have timestamp %v, got %v", i, consumerVersion, expected, msg.Timestamp)
+				}
+				if version.IsAtLeast(V0_11_0_0) && (len(msg.Headers) != 1 || string(msg.Headers[0].Value) != fmt.Sprint(i)) {
+					t.Errorf("Expected the message at offset %d to have its header, got %v", i, msg.Headers)
+				}
+			}
+			safeClose(t, pc)
+			safeClose(t, consumer)
+		}
+		cluster.Close()
+	}
+}
+
+func TestMockClusterFailsOverLeaderChanges(t *testing.T) {
+	cluster := NewMockCluster(t, 3)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+	if leader := cluster.Leader("my_topic", 0); leader != 1 {
+		t.Fatal("Expected the partition to be led by broker 1, got", leader)
+	}
+
+	consumer, err := NewConsumer(cluster.Addrs(), newMockClusterConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, consumer)
+	pc, err := consumer.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, pc)
+
+	producer, err := NewSyncProducer(cluster.Addrs(), newMockClusterConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, producer)
+
+	for i, leader := range []int32{1, 2, 3} {
+		cluster.SetLeader("my_topic", 0, leader)
+		_, offset, err := producer.SendMessage(&ProducerMessage{Topic: "my_topic", Value: StringEncoder("value")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset != int64(i) {
+			t.Errorf("Expected the message produced to broker %d at offset %d, got %d", leader, i, offset)
+		}
+
+		select {
+		case msg := <-pc.Messages():
+			if msg.Offset != int64(i) {
+				t.Errorf("Expected the message at offset %d from broker %d, got %d", i, leader, msg.Offset)
+			}
+		case err := <-pc.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the message from broker", leader)
+		}
+	}
+
+	for _, brokerID := range []int32{2, 3} {
+		produced := false
+		for _, rr := range cluster.Broker(brokerID).History() {
+			if _, ok := rr.Request.(*ProduceRequest); ok {
+				produced = true
+			}
+		}
+		if !produced {
+			t.Errorf("Expected the producer to fail over to broker %d", brokerID)
+		}
+	}
+}
+
+func TestMockClusterChecksSequenceNumbers(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	broker := NewBroker(cluster.Broker(1).Addr())
+	if err := broker.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, broker)
+
+	produce := func(firstSequence int32) *ProduceResponseBlock {
+		batch := newRecordBatch(CompressionNone)
+		batch.ProducerID, batch.ProducerEpoch, batch.FirstSequence = 7, 0, firstSequence
+		batch.addRecord(&Record{Value: []byte("a")}, time.Now())
+		batch.addRecord(&Record{Value: []byte("b")}, time.Now())
+		request := &ProduceRequest{Version: 3, RequiredAcks: WaitForAll, Timeout: 100}
+		request.AddBatch("my_topic", 0, batch)
+		response, err := broker.Produce(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response.GetBlock("my_topic", 0)
+	}
+
+	for _, step := range []struct {
+		firstSequence int32
+		err           KError
+		offset        int64
+	}{
+		{0, ErrNoError, 0},
+		{0, ErrDuplicateSequenceNumber, -1},
+		{5, ErrOutOfOrderSequenceNumber, -1},
+		{2, ErrNoError, 2},
+	} {
+		if block := produce(step.firstSequence); block.Err != step.err || block.Offset != step.offset {
+			t.Errorf("Expected the batch from sequence %d to get %v at offset %d, got %v at %d",
+				step.firstSequence, step.err, step.offset, block.Err, block.Offset)
+		}
+	}
+	if messages := cluster.Messages("my_topic", 0); len(messages) != 4 {
+		t.Error("Expected 4 messages on the partition, got", len(messages))
+	}
+}
+
+func openMockClusterCoordinator(t *testing.T, cluster *MockCluster, group string) *Broker {
+	client, err := NewClient(cluster.Addrs(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+	coordinator, err := client.Coordinator(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broker := NewBroker(coordinator.Addr())
+	broker.id = coordinator.ID()
+	if err := broker.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	return broker
+}
+
+func joinMockClusterGroup(t *testing.T, broker *Broker, memberID string) *JoinGroupResponse {
+	request := &JoinGroupRequest{GroupId: "my_group", SessionTimeout: 10000, MemberId: memberID, ProtocolType: "consumer"}
+	request.AddGroupProtocol("range", []byte(memberID))
+	response := new(JoinGroupResponse)
+	if err := broker.sendAndReceive(request, response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Err != ErrNoError {
+		t.Fatal("Failed to join the group:", response.Err)
+	}
+	return response
+}
+
+func syncMockClusterGroup(t *testing.T, broker *Broker, join *JoinGroupResponse) []byte {
+	request := &SyncGroupRequest{GroupId: "my_group", GenerationId: join.GenerationId, MemberId: join.MemberId}
+	for memberID := range join.Members {
+		request.AddGroupAssignment(memberID, []byte("for "+memberID))
+	}
+	response := new(SyncGroupResponse)
+	if err := broker.sendAndReceive(request, response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Err != ErrNoError {
+		t.Fatal("Failed to sync the group:", response.Err)
+	}
+	return response.MemberAssignment
+}
+
+func heartbeatMockClusterGroup(t *testing.T, broker *Broker, join *JoinGroupResponse) KError {
+	request := &HeartbeatRequest{GroupId: "my_group", GenerationId: join.GenerationId, MemberId: join.MemberId}
+	response := new(HeartbeatResponse)
+	if err := broker.sendAndReceive(request, response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Err
+}
+
+func TestMockClusterRebalancesGroups(t *testing.T) {
+	cluster := NewMockCluster(t, 3)
+	defer cluster.Close()
+
+	first := openMockClusterCoordinator(t, cluster, "my_group")
+	defer safeClose(t, first)
+	second := openMockClusterCoordinator(t, cluster, "my_group")
+	defer safeClose(t, second)
+
+	join1 := joinMockClusterGroup(t, first, "")
+	if join1.LeaderId != join1.MemberId || len(join1.Members) != 1 {
+		t.Fatal("Expected the only member to lead the group, got", join1)
+	}
+	if assignment := syncMockClusterGroup(t, first, join1); string(assignment) != "for "+join1.MemberId {
+		t.Error("Expected the leader's assignment, got", string(assignment))
+	}
+	if kerr := heartbeatMockClusterGroup(t, first, join1); kerr != ErrNoError {
+		t.Error("Expected the member of the stable group to heartbeat, got", kerr)
+	}
+
+	joined := make(chan *JoinGroupResponse)
+	go func() {
+		request := &JoinGroupRequest{GroupId: "my_group", SessionTimeout: 10000, ProtocolType: "consumer"}
+		request.AddGroupProtocol("range", nil)
+		response := new(JoinGroupResponse)
+		if err := second.sendAndReceive(request, response); err != nil {
+			t.Error(err)
+		}
+		joined <- response
+	}()
+
+	// the join of the second member waits for the first to rejoin
+	for {
+		kerr := heartbeatMockClusterGroup(t, first, join1)
+		if kerr == ErrRebalanceInProgress {
+			break
+		}
+		if kerr != ErrNoError {
+			t.Fatal("Expected a rebalance, got", kerr)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	rejoin1 := joinMockClusterGroup(t, first, join1.MemberId)
+	join2 := <-joined
+	if join2.Err != ErrNoError {
+		t.Fatal("Failed to join the group:", join2.Err)
+	}
+	if rejoin1.GenerationId != join1.GenerationId+1 || join2.GenerationId != rejoin1.GenerationId {
+		t.Error("Expected both members to join the next generation, got", rejoin1.GenerationId, join2.GenerationId)
+	}
+	if rejoin1.LeaderId != join1.MemberId || len(rejoin1.Members) != 2 || join2.Members != nil {
+		t.Error("Expected the first member to keep leading the group, got", rejoin1, join2)
+	}
+
+	synced := make(chan []byte)
+	go func() {
+		request := &SyncGroupRequest{GroupId: "my_group", GenerationId: join2.GenerationId, MemberId: join2.MemberId}
+		response := new(SyncGroupResponse)
+		if err := second.sendAndReceive(request, response); err != nil {
+			t.Error(err)
+		}
+		synced <- response.MemberAssignment
+	}()
+	syncMockClusterGroup(t, first, rejoin1)
+	if assignment := <-synced; string(assignment) != "for "+join2.MemberId {
+		t.Error("Expected the follower to get the assignment of the leader, got", string(assignment))
+	}
+
+	description, err := first.DescribeGroups(&DescribeGroupsRequest{Groups: []string{"my_group"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group := description.Groups[0]; group.State != "Stable" || group.Protocol != "range" || len(group.Members) != 2 {
+		t.Error("Expected a stable group of two members, got", group)
+	}
+
+	response := new(LeaveGroupResponse)
+	if err := second.sendAndReceive(&LeaveGroupRequest{GroupId: "my_group", MemberId: join2.MemberId}, response); err != nil || response.Err != ErrNoError {
+		t.Fatal("Failed to leave the group:", err, response.Err)
+	}
+	if kerr := heartbeatMockClusterGroup(t, first, rejoin1); kerr != ErrRebalanceInProgress {
+		t.Error("Expected a member leaving to rebalance the group, got", kerr)
+	}
+}
+
+func TestMockClusterMovesGroupCoordination(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 1)
+
+	coordinator := openMockClusterCoordinator(t, cluster, "my_group")
+	defer safeClose(t, coordinator)
+	commit := &OffsetCommitRequest{Version: 1, ConsumerGroup: "my_group", ConsumerGroupGeneration: GroupGenerationUndefined}
+	commit.AddBlock("my_topic", 0, 42, ReceiveTime, "meta")
+	commitResponse, err := coordinator.CommitOffset(commit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kerr := commitResponse.Errors["my_topic"][0]; kerr != ErrNoError {
+		t.Fatal("Failed to commit the offset:", kerr)
+	}
+
+	otherID := int32(1)
+	if coordinator.ID() == 1 {
+		otherID = 2
+	}
+	cluster.SetCoordinator("my_group", otherID)
+
+	fetch := &OffsetFetchRequest{Version: 1, ConsumerGroup: "my_group"}
+	fetch.AddPartition("my_topic", 0)
+	fetchResponse, err := coordinator.FetchOffset(fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block := fetchResponse.GetBlock("my_topic", 0); block.Err != ErrNotCoordinatorForConsumer {
+		t.Error("Expected the former coordinator to refuse the group, got", block.Err)
+	}
+
+	other := openMockClusterCoordinator(t, cluster, "my_group")
+	defer safeClose(t, other)
+	if other.ID() != otherID {
+		t.Fatal("Expected the group to be coordinated by broker", otherID, "got", other.ID())
+	}
+	fetchResponse, err = other.FetchOffset(fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block := fetchResponse.GetBlock("my_topic", 0); block.Err != ErrNoError || block.Offset != 42 || block.Metadata != "meta" {
+		t.Error("Expected the new coordinator to have the committed offset, got", block)
+	}
+}
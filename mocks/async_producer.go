@@ -19,6 +19,7 @@ type AsyncProducer struct {
 	successes    chan *sarama.ProducerMessage
 	errors       chan *sarama.ProducerError
 	lastOffset   int64
+	txn          mockTxn
 }
 
 // NewAsyncProducer instantiates a new Producer mock. The t argument should
@@ -36,6 +37,7 @@ func NewAsyncProducer(t ErrorReporter, config *sarama.Config) *AsyncProducer {
 		input:        make(chan *sarama.ProducerMessage, config.ChannelBufferSize),
 		successes:    make(chan *sarama.ProducerMessage, config.ChannelBufferSize),
 		errors:       make(chan *sarama.ProducerError, config.ChannelBufferSize),
+		txn:          mockTxn{transactional: config.Producer.Transaction.ID != ""},
 	}
 
 	go func() {
@@ -52,7 +54,12 @@ func NewAsyncProducer(t ErrorReporter, config *sarama.Config) *AsyncProducer {
 			} else {
 				expectation := mp.expectations[0]
 				mp.expectations = mp.expectations[1:]
-				if expectation.Result == errProduceSuccess {
+				if err := expectation.check(msg); err != nil {
+					mp.t.Errorf("%s", err)
+					if config.Producer.Return.Errors {
+						mp.errors <- &sarama.ProducerError{Err: err, Msg: msg}
+					}
+				} else if expectation.Result == errProduceSuccess {
 					mp.lastOffset++
 					if config.Producer.Return.Successes {
 						msg.Offset = mp.lastOffset
@@ -118,6 +125,45 @@ func (mp *AsyncProducer) Errors() <-chan *sarama.ProducerError {
 	return mp.errors
 }
 
+// BeginTxn corresponds with the BeginTxn method of sarama's Producer implementation.
+// It fails unless the config the mock was created with sets Producer.Transaction.ID,
+// and while a transaction is ongoing.
+func (mp *AsyncProducer) BeginTxn() error {
+	mp.l.Lock()
+	defer mp.l.Unlock()
+	return mp.txn.begin()
+}
+
+// CommitTxn corresponds with the CommitTxn method of sarama's Producer implementation.
+// It fails unless a transaction is ongoing.
+func (mp *AsyncProducer) CommitTxn() error {
+	mp.l.Lock()
+	defer mp.l.Unlock()
+	return mp.txn.end()
+}
+
+// AbortTxn corresponds with the AbortTxn method of sarama's Producer implementation.
+// It fails unless a transaction is ongoing.
+func (mp *AsyncProducer) AbortTxn() error {
+	mp.l.Lock()
+	defer mp.l.Unlock()
+	return mp.txn.end()
+}
+
+// AddOffsetsToTxn corresponds with the AddOffsetsToTxn method of sarama's Producer
+// implementation. It fails unless a transaction is ongoing.
+func (mp *AsyncProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupID string) error {
+	mp.l.Lock()
+	defer mp.l.Unlock()
+	return mp.txn.check()
+}
+
+// AddMessageToTxn corresponds with the AddMessageToTxn method of sarama's Producer
+// implementation. It fails unless a transaction is ongoing.
+func (mp *AsyncProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupID string, metadata *string) error {
+	return mp.AddOffsetsToTxn(nil, groupID)
+}
+
 ////////////////////////////////////////////////
 // Setting expectations
 ////////////////////////////////////////////////
@@ -140,3 +186,24 @@ func (mp *AsyncProducer) ExpectInputAndFail(err error) {
 	defer mp.l.Unlock()
 	mp.expectations = append(mp.expectations, &producerExpectation{Result: err})
 }
+
+// ExpectInputWithCheckerFunctionAndSucceed sets an expectation on the mock producer that a
+// message whose value passes the given checker will be provided on the input channel. The
+// mock producer will handle the message as if it is produced successfully, unless the
+// checker fails, in which case it makes a ProducerError with the checker's error available
+// on the Errors channel.
+func (mp *AsyncProducer) ExpectInputWithCheckerFunctionAndSucceed(cf ValueChecker) {
+	mp.l.Lock()
+	defer mp.l.Unlock()
+	mp.expectations = append(mp.expectations, &producerExpectation{Result: errProduceSuccess, CheckFunction: cf})
+}
+
+// ExpectInputWithCheckerFunctionAndFail sets an expectation on the mock producer that a
+// message whose value passes the given checker will be provided on the input channel. The
+// mock producer will handle the message as if it failed to produce successfully, with the
+// provided error, or the checker's error if it fails.
+func (mp *AsyncProducer) ExpectInputWithCheckerFunctionAndFail(cf ValueChecker, err error) {
+	mp.l.Lock()
+	defer mp.l.Unlock()
+	mp.expectations = append(mp.expectations, &producerExpectation{Result: err, CheckFunction: cf})
+}
@@ -1,7 +1,10 @@
 package mocks
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/Shopify/sarama"
@@ -19,6 +22,19 @@ func (trm *testReporterMock) Errorf(format string, args ...interface{}) {
 	trm.errors = append(trm.errors, fmt.Sprintf(format, args...))
 }
 
+func generateRegexpChecker(re string) func([]byte) error {
+	return func(val []byte) error {
+		matched, err := regexp.MatchString(re, string(val))
+		if err != nil {
+			return errors.New("Error while trying to match the input message with the expected pattern: " + err.Error())
+		}
+		if !matched {
+			return fmt.Errorf("No match between input value \"%s\" and expected pattern \"%s\"", val, re)
+		}
+		return nil
+	}
+}
+
 func TestMockAsyncProducerImplementsAsyncProducerInterface(t *testing.T) {
 	var mp interface{} = &AsyncProducer{}
 	if _, ok := mp.(sarama.AsyncProducer); !ok {
@@ -92,3 +108,51 @@ func TestProducerWithTooManyExpectations(t *testing.T) {
 		t.Error("Expected to report an error")
 	}
 }
+
+func TestProducerWithCheckerFunction(t *testing.T) {
+	trm := newTestReporterMock()
+	mp := NewAsyncProducer(trm, nil)
+	mp.ExpectInputWithCheckerFunctionAndSucceed(generateRegexpChecker("^tes"))
+	mp.ExpectInputWithCheckerFunctionAndSucceed(generateRegexpChecker("^tes$"))
+
+	mp.Input() <- &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("test")}
+	mp.Input() <- &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("test")}
+	if err := mp.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if len(mp.Errors()) != 1 {
+		t.Error("Expected to report an error")
+	}
+
+	err1 := <-mp.Errors()
+	if !strings.HasPrefix(err1.Err.Error(), "Check function returned an error") {
+		t.Error("Expected the checker to fail the second message, got", err1.Err)
+	}
+	if len(trm.errors) != 1 {
+		t.Error("Expected to report the failed check, got", trm.errors)
+	}
+}
+
+func TestProducerTransactions(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Transaction.ID = "test"
+	mp := NewAsyncProducer(t, config)
+
+	if err := mp.AddOffsetsToTxn(nil, "group"); err != sarama.ErrTransactionNotReady {
+		t.Errorf("Adding offsets without a transaction should fail, but got %v", err)
+	}
+	if err := mp.BeginTxn(); err != nil {
+		t.Error(err)
+	}
+	if err := mp.BeginTxn(); err != sarama.ErrTransactionNotReady {
+		t.Errorf("Beginning a transaction during another should fail, but got %v", err)
+	}
+	if err := mp.AbortTxn(); err != nil {
+		t.Error(err)
+	}
+
+	if err := mp.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,506 @@
+package mocks
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+type mockAcl struct {
+	resource sarama.Resource
+	acl      sarama.Acl
+}
+
+// ClusterAdmin implements sarama's ClusterAdmin interface for testing purposes. It keeps
+// the topics, configurations, groups and ACLs of an imaginary cluster in memory: the
+// topics and ACLs created with it, and those set up beforehand using the Expectation API,
+// are what it describes, lists and deletes. Calls that fail on a real cluster, such as
+// creating a topic twice, fail with the error the brokers would return.
+type ClusterAdmin struct {
+	l        sync.Mutex
+	t        ErrorReporter
+	config   *sarama.Config
+	topics   map[string]*sarama.TopicDetail
+	configs  map[sarama.ConfigResourceType]map[string]map[string]*string
+	groups   map[string]*sarama.GroupDescription
+	offsets  map[string]*sarama.OffsetFetchResponse
+	acls     []mockAcl
+	failures []error
+	closed   bool
+}
+
+// NewClusterAdmin returns a new mock ClusterAdmin instance, of a cluster without topics,
+// groups or ACLs. The t argument should be the *testing.T instance of your test method.
+// An error will be written to it if the mock is used after it is closed. The config
+// argument is currently unused and can be set to nil.
+func NewClusterAdmin(t ErrorReporter, config *sarama.Config) *ClusterAdmin {
+	if config == nil {
+		config = sarama.NewConfig()
+	}
+
+	return &ClusterAdmin{
+		t:       t,
+		config:  config,
+		topics:  make(map[string]*sarama.TopicDetail),
+		configs: make(map[sarama.ConfigResourceType]map[string]map[string]*string),
+		groups:  make(map[string]*sarama.GroupDescription),
+		offsets: make(map[string]*sarama.OffsetFetchResponse),
+	}
+}
+
+///////////////////////////////////////////////////
+// ClusterAdmin interface implementation
+///////////////////////////////////////////////////
+
+// CreateTopic implements the CreateTopic method from the sarama.ClusterAdmin interface.
+// The configuration entries of the detail become the topic's configuration.
+func (ca *ClusterAdmin) CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("CreateTopic"); err != nil {
+		return err
+	}
+	if topic == "" {
+		return sarama.ErrInvalidTopic
+	}
+	if detail == nil {
+		return sarama.ConfigurationError("CreateTopic requires a TopicDetail")
+	}
+	if ca.topics[topic] != nil {
+		return sarama.ErrTopicAlreadyExists
+	}
+	if detail.ReplicaAssignment == nil && (detail.NumPartitions <= 0 || detail.ReplicationFactor <= 0) {
+		return sarama.ErrInvalidPartitions
+	}
+	if validateOnly {
+		return nil
+	}
+
+	ca.addTopic(topic, detail)
+	return nil
+}
+
+// DeleteTopic implements the DeleteTopic method from the sarama.ClusterAdmin interface.
+// It also deletes the topic's configuration and the offsets committed for it.
+func (ca *ClusterAdmin) DeleteTopic(topic string) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("DeleteTopic"); err != nil {
+		return err
+	}
+	if ca.topics[topic] == nil {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+
+	delete(ca.topics, topic)
+	delete(ca.configs[sarama.TopicResource], topic)
+	for _, offsets := range ca.offsets {
+		delete(offsets.Blocks, topic)
+	}
+	return nil
+}
+
+// CreatePartitions implements the CreatePartitions method from the sarama.ClusterAdmin
+// interface. It fails with sarama.ErrInvalidPartitions unless count is more than the
+// partitions the topic has.
+func (ca *ClusterAdmin) CreatePartitions(topic string, count int32, assignment [][]int32, validateOnly bool) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("CreatePartitions"); err != nil {
+		return err
+	}
+	detail := ca.topics[topic]
+	if detail == nil {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	if count <= detail.NumPartitions {
+		return sarama.ErrInvalidPartitions
+	}
+	if assignment != nil && int32(len(assignment)) != count-detail.NumPartitions {
+		return sarama.ErrInvalidReplicaAssignment
+	}
+	if validateOnly {
+		return nil
+	}
+
+	for i, replicas := range assignment {
+		if detail.ReplicaAssignment == nil {
+			detail.ReplicaAssignment = make(map[int32][]int32)
+		}
+		detail.ReplicaAssignment[detail.NumPartitions+int32(i)] = replicas
+	}
+	detail.NumPartitions = count
+	return nil
+}
+
+// DeleteRecords implements the DeleteRecords method from the sarama.ClusterAdmin
+// interface. The mock has no records to delete: it only checks that the partitions exist.
+func (ca *ClusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("DeleteRecords"); err != nil {
+		return err
+	}
+	detail := ca.topics[topic]
+	if detail == nil {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	for partition, offset := range partitionOffsets {
+		if partition < 0 || partition >= detail.NumPartitions {
+			return sarama.ErrUnknownTopicOrPartition
+		}
+		if offset < -1 {
+			return sarama.ErrOffsetOutOfRange
+		}
+	}
+	return nil
+}
+
+// DescribeConfig implements the DescribeConfig method from the sarama.ClusterAdmin
+// interface. It returns the configuration overrides of the resource, sorted by name, as
+// set up with CreateTopic, AlterConfig or SetConfig; the mock doesn't know the defaults.
+func (ca *ClusterAdmin) DescribeConfig(resource sarama.ConfigResource) ([]*sarama.ConfigEntry, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("DescribeConfig"); err != nil {
+		return nil, err
+	}
+	if resource.Type == sarama.TopicResource && ca.topics[resource.Name] == nil {
+		return nil, sarama.ErrUnknownTopicOrPartition
+	}
+
+	entries := ca.configs[resource.Type][resource.Name]
+	names := resource.ConfigNames
+	if names == nil {
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var result []*sarama.ConfigEntry
+	for _, name := range names {
+		if value, ok := entries[name]; ok {
+			result = append(result, &sarama.ConfigEntry{Name: name, Value: value})
+		}
+	}
+	return result, nil
+}
+
+// AlterConfig implements the AlterConfig method from the sarama.ClusterAdmin interface:
+// like the brokers, it replaces the configuration overrides of the resource with entries.
+func (ca *ClusterAdmin) AlterConfig(resourceType sarama.ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("AlterConfig"); err != nil {
+		return err
+	}
+	if resourceType == sarama.TopicResource && ca.topics[name] == nil {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	if validateOnly {
+		return nil
+	}
+
+	ca.setConfig(resourceType, name, entries)
+	return nil
+}
+
+// ListConsumerGroups implements the ListConsumerGroups method from the sarama.ClusterAdmin
+// interface. It returns the groups registered with AddGroup.
+func (ca *ClusterAdmin) ListConsumerGroups() (map[string]string, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("ListConsumerGroups"); err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]string, len(ca.groups))
+	for group, description := range ca.groups {
+		groups[group] = description.ProtocolType
+	}
+	return groups, nil
+}
+
+// DescribeConsumerGroups implements the DescribeConsumerGroups method from the
+// sarama.ClusterAdmin interface. As the brokers do, it describes the groups that weren't
+// registered with AddGroup as "Dead" groups without members.
+func (ca *ClusterAdmin) DescribeConsumerGroups(groups []string) ([]*sarama.GroupDescription, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("DescribeConsumerGroups"); err != nil {
+		return nil, err
+	}
+
+	result := make([]*sarama.GroupDescription, 0, len(groups))
+	for _, group := range groups {
+		description := ca.groups[group]
+		if description == nil {
+			description = &sarama.GroupDescription{GroupId: group, State: "Dead"}
+		}
+		result = append(result, description)
+	}
+	return result, nil
+}
+
+// ListConsumerGroupOffsets implements the ListConsumerGroupOffsets method from the
+// sarama.ClusterAdmin interface. It returns the offsets set with SetGroupOffset.
+func (ca *ClusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions map[string][]int32) (*sarama.OffsetFetchResponse, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("ListConsumerGroupOffsets"); err != nil {
+		return nil, err
+	}
+
+	committed := ca.offsets[group]
+	response := new(sarama.OffsetFetchResponse)
+	if len(topicPartitions) == 0 {
+		if committed != nil {
+			for topic, partitions := range committed.Blocks {
+				for partition, block := range partitions {
+					response.AddBlock(topic, partition, block)
+				}
+			}
+		}
+		return response, nil
+	}
+
+	for topic, partitions := range topicPartitions {
+		for _, partition := range partitions {
+			var block *sarama.OffsetFetchResponseBlock
+			if committed != nil {
+				block = committed.GetBlock(topic, partition)
+			}
+			if block == nil {
+				block = &sarama.OffsetFetchResponseBlock{Offset: -1}
+			}
+			response.AddBlock(topic, partition, block)
+		}
+	}
+	return response, nil
+}
+
+// CreateACL implements the CreateACL method from the sarama.ClusterAdmin interface.
+// Creating an ACL that exists already does nothing, as on the brokers.
+func (ca *ClusterAdmin) CreateACL(resource sarama.Resource, acl sarama.Acl) error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("CreateACL"); err != nil {
+		return err
+	}
+
+	ca.addACL(resource, acl)
+	return nil
+}
+
+// ListAcls implements the ListAcls method from the sarama.ClusterAdmin interface. It
+// returns the ACLs the filter matches, by resource, in the order they were created.
+func (ca *ClusterAdmin) ListAcls(filter sarama.AclFilter) ([]*sarama.ResourceAcls, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("ListAcls"); err != nil {
+		return nil, err
+	}
+
+	var result []*sarama.ResourceAcls
+	byResource := make(map[sarama.Resource]*sarama.ResourceAcls)
+	for _, ma := range ca.acls {
+		if !aclMatches(filter, ma) {
+			continue
+		}
+		resourceAcls := byResource[ma.resource]
+		if resourceAcls == nil {
+			resourceAcls = &sarama.ResourceAcls{Resource: ma.resource}
+			byResource[ma.resource] = resourceAcls
+			result = append(result, resourceAcls)
+		}
+		acl := ma.acl
+		resourceAcls.Acls = append(resourceAcls.Acls, &acl)
+	}
+	return result, nil
+}
+
+// DeleteACL implements the DeleteACL method from the sarama.ClusterAdmin interface. It
+// deletes the ACLs the filter matches and returns them.
+func (ca *ClusterAdmin) DeleteACL(filter sarama.AclFilter) ([]*sarama.MatchingAcl, error) {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if err := ca.call("DeleteACL"); err != nil {
+		return nil, err
+	}
+
+	var deleted []*sarama.MatchingAcl
+	kept := ca.acls[:0]
+	for _, ma := range ca.acls {
+		if aclMatches(filter, ma) {
+			deleted = append(deleted, &sarama.MatchingAcl{Resource: ma.resource, Acl: ma.acl})
+		} else {
+			kept = append(kept, ma)
+		}
+	}
+	ca.acls = kept
+	return deleted, nil
+}
+
+// Close implements the Close method from the sarama.ClusterAdmin interface. It will
+// report the errors set with FailNextCall that no call returned.
+func (ca *ClusterAdmin) Close() error {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if ca.closed {
+		ca.t.Errorf("Unexpected call to Close: the cluster admin was already closed.")
+		return nil
+	}
+	if len(ca.failures) > 0 {
+		ca.t.Errorf("Expected %d more calls to the cluster admin to fail.", len(ca.failures))
+	}
+	ca.closed = true
+	return nil
+}
+
+///////////////////////////////////////////////////
+// Expectation API
+///////////////////////////////////////////////////
+
+// AddTopic adds a topic to the cluster as if it was created with CreateTopic, which
+// the detail's configuration entries become the configuration of.
+func (ca *ClusterAdmin) AddTopic(topic string, detail *sarama.TopicDetail) *ClusterAdmin {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	ca.addTopic(topic, detail)
+	return ca
+}
+
+// SetConfig sets the configuration overrides of a topic or broker, which DescribeConfig
+// returns. Unlike AlterConfig, it doesn't require the topic to exist.
+func (ca *ClusterAdmin) SetConfig(resourceType sarama.ConfigResourceType, name string, entries map[string]*string) *ClusterAdmin {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	ca.setConfig(resourceType, name, entries)
+	return ca
+}
+
+// AddGroup adds a group to the cluster, which ListConsumerGroups lists and
+// DescribeConsumerGroups returns the description of.
+func (ca *ClusterAdmin) AddGroup(description *sarama.GroupDescription) *ClusterAdmin {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	ca.groups[description.GroupId] = description
+	return ca
+}
+
+// SetGroupOffset sets the offset a group committed for a partition, which
+// ListConsumerGroupOffsets returns.
+func (ca *ClusterAdmin) SetGroupOffset(group, topic string, partition int32, offset int64, metadata string) *ClusterAdmin {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	if ca.offsets[group] == nil {
+		ca.offsets[group] = new(sarama.OffsetFetchResponse)
+	}
+	ca.offsets[group].AddBlock(topic, partition, &sarama.OffsetFetchResponseBlock{Offset: offset, Metadata: metadata})
+	return ca
+}
+
+// AddACL adds an ACL to the cluster as if it was created with CreateACL.
+func (ca *ClusterAdmin) AddACL(resource sarama.Resource, acl sarama.Acl) *ClusterAdmin {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	ca.addACL(resource, acl)
+	return ca
+}
+
+// FailNextCall makes the next call to the cluster admin, other than Close, return err
+// without doing anything. Each call made to FailNextCall fails one more call, in order.
+func (ca *ClusterAdmin) FailNextCall(err error) *ClusterAdmin {
+	ca.l.Lock()
+	defer ca.l.Unlock()
+
+	ca.failures = append(ca.failures, err)
+	return ca
+}
+
+// call reports a call made after the cluster admin was closed, and returns the error
+// the call has to fail with, if any.
+func (ca *ClusterAdmin) call(method string) error {
+	if ca.closed {
+		ca.t.Errorf("Unexpected call to %s after the cluster admin was closed.", method)
+		return sarama.ErrClosedClient
+	}
+	if len(ca.failures) > 0 {
+		err := ca.failures[0]
+		ca.failures = ca.failures[1:]
+		return err
+	}
+	return nil
+}
+
+func (ca *ClusterAdmin) addTopic(topic string, detail *sarama.TopicDetail) {
+	stored := *detail
+	if stored.ReplicaAssignment != nil {
+		stored.NumPartitions = int32(len(stored.ReplicaAssignment))
+		stored.ReplicaAssignment = make(map[int32][]int32, len(detail.ReplicaAssignment))
+		for partition, replicas := range detail.ReplicaAssignment {
+			stored.ReplicaAssignment[partition] = replicas
+		}
+	}
+	ca.topics[topic] = &stored
+	ca.setConfig(sarama.TopicResource, topic, detail.ConfigEntries)
+}
+
+func (ca *ClusterAdmin) setConfig(resourceType sarama.ConfigResourceType, name string, entries map[string]*string) {
+	if ca.configs[resourceType] == nil {
+		ca.configs[resourceType] = make(map[string]map[string]*string)
+	}
+	overrides := make(map[string]*string, len(entries))
+	for key, value := range entries {
+		overrides[key] = value
+	}
+	ca.configs[resourceType][name] = overrides
+}
+
+func (ca *ClusterAdmin) addACL(resource sarama.Resource, acl sarama.Acl) {
+	for _, ma := range ca.acls {
+		if ma.resource == resource && ma.acl == acl {
+			return
+		}
+	}
+	ca.acls = append(ca.acls, mockAcl{resource: resource, acl: acl})
+}
+
+// aclMatches tells whether the filter matches an ACL, as the brokers match them.
+func aclMatches(filter sarama.AclFilter, ma mockAcl) bool {
+	switch {
+	case filter.ResourceType != sarama.AclResourceAny && filter.ResourceType != ma.resource.ResourceType:
+		return false
+	case filter.ResourceName != nil && *filter.ResourceName != ma.resource.ResourceName:
+		return false
+	case filter.Principal != nil && *filter.Principal != ma.acl.Principal:
+		return false
+	case filter.Host != nil && *filter.Host != ma.acl.Host:
+		return false
+	case filter.Operation != sarama.AclOperationAny && filter.Operation != ma.acl.Operation:
+		return false
+	case filter.PermissionType != sarama.AclPermissionAny && filter.PermissionType != ma.acl.PermissionType:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package mocks
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestMockClusterAdminImplementsClusterAdminInterface(t *testing.T) {
+	var ca interface{} = &ClusterAdmin{}
+	if _, ok := ca.(sarama.ClusterAdmin); !ok {
+		t.Error("The mock cluster admin should implement the sarama.ClusterAdmin interface.")
+	}
+}
+
+func TestClusterAdminManagesTopicsAndConfigs(t *testing.T) {
+	retention := "1000"
+	ca := NewClusterAdmin(t, nil)
+	ca.AddTopic("existing", &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1})
+
+	if err := ca.CreateTopic("existing", &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false); err != sarama.ErrTopicAlreadyExists {
+		t.Error("Expected creating an existing topic to fail, got", err)
+	}
+	if err := ca.CreateTopic("validated", &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, true); err != nil {
+		t.Error(err)
+	}
+	detail := &sarama.TopicDetail{
+		NumPartitions:     2,
+		ReplicationFactor: 1,
+		ConfigEntries:     map[string]*string{"retention.ms": &retention},
+	}
+	if err := ca.CreateTopic("test", detail, false); err != nil {
+		t.Error(err)
+	}
+
+	if err := ca.CreatePartitions("test", 2, nil, false); err != sarama.ErrInvalidPartitions {
+		t.Error("Expected adding no partitions to fail, got", err)
+	}
+	if err := ca.CreatePartitions("test", 3, nil, false); err != nil {
+		t.Error(err)
+	}
+	if err := ca.DeleteRecords("test", map[int32]int64{2: -1}); err != nil {
+		t.Error("Expected the new partition to exist, got", err)
+	}
+	if err := ca.DeleteRecords("test", map[int32]int64{3: -1}); err != sarama.ErrUnknownTopicOrPartition {
+		t.Error("Expected deleting the records of an unknown partition to fail, got", err)
+	}
+
+	entries, err := ca.DescribeConfig(sarama.ConfigResource{Type: sarama.TopicResource, Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "retention.ms" || *entries[0].Value != retention {
+		t.Error("Expected the configuration the topic was created with, got", entries)
+	}
+	if err := ca.AlterConfig(sarama.TopicResource, "test", map[string]*string{}, false); err != nil {
+		t.Error(err)
+	}
+	if entries, err := ca.DescribeConfig(sarama.ConfigResource{Type: sarama.TopicResource, Name: "test"}); err != nil || len(entries) != 0 {
+		t.Error("Expected altering the configuration to replace it, got", entries, err)
+	}
+	ca.SetConfig(sarama.BrokerResource, "1", map[string]*string{"log.retention.ms": &retention})
+	if entries, err := ca.DescribeConfig(sarama.ConfigResource{Type: sarama.BrokerResource, Name: "1", ConfigNames: []string{"log.retention.ms"}}); err != nil || len(entries) != 1 {
+		t.Error("Expected the configuration of the broker, got", entries, err)
+	}
+
+	if err := ca.DeleteTopic("test"); err != nil {
+		t.Error(err)
+	}
+	if err := ca.DeleteTopic("test"); err != sarama.ErrUnknownTopicOrPartition {
+		t.Error("Expected deleting a deleted topic to fail, got", err)
+	}
+	if _, err := ca.DescribeConfig(sarama.ConfigResource{Type: sarama.TopicResource, Name: "validated"}); err != sarama.ErrUnknownTopicOrPartition {
+		t.Error("Expected a validated topic not to be created, got", err)
+	}
+
+	if err := ca.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterAdminManagesGroups(t *testing.T) {
+	ca := NewClusterAdmin(t, nil)
+	ca.AddGroup(&sarama.GroupDescription{GroupId: "group", State: "Stable", ProtocolType: "consumer"}).
+		SetGroupOffset("group", "test", 0, 42, "meta")
+
+	if groups, err := ca.ListConsumerGroups(); err != nil || len(groups) != 1 || groups["group"] != "consumer" {
+		t.Error("Expected the group to be listed, got", groups, err)
+	}
+	descriptions, err := ca.DescribeConsumerGroups([]string{"group", "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descriptions) != 2 || descriptions[0].State != "Stable" || descriptions[1].State != "Dead" {
+		t.Error("Expected the group and a dead one to be described, got", descriptions)
+	}
+
+	offsets, err := ca.ListConsumerGroupOffsets("group", map[string][]int32{"test": {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block := offsets.GetBlock("test", 0); block == nil || block.Offset != 42 || block.Metadata != "meta" {
+		t.Error("Expected the committed offset, got", block)
+	}
+	if block := offsets.GetBlock("test", 1); block == nil || block.Offset != -1 {
+		t.Error("Expected no offset for a partition without one, got", block)
+	}
+	if offsets, err := ca.ListConsumerGroupOffsets("group", nil); err != nil || len(offsets.Blocks["test"]) != 1 {
+		t.Error("Expected every committed offset, got", offsets, err)
+	}
+
+	if err := ca.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterAdminManagesACLs(t *testing.T) {
+	topic := sarama.Resource{ResourceType: sarama.AclResourceTopic, ResourceName: "test"}
+	group := sarama.Resource{ResourceType: sarama.AclResourceGroup, ResourceName: "group"}
+	read := sarama.Acl{Principal: "User:alice", Host: "*", Operation: sarama.AclOperationRead, PermissionType: sarama.AclPermissionAllow}
+	write := sarama.Acl{Principal: "User:bob", Host: "*", Operation: sarama.AclOperationWrite, PermissionType: sarama.AclPermissionAllow}
+
+	ca := NewClusterAdmin(t, nil)
+	ca.AddACL(topic, read)
+	if err := ca.CreateACL(topic, write); err != nil {
+		t.Error(err)
+	}
+	if err := ca.CreateACL(group, read); err != nil {
+		t.Error(err)
+	}
+	if err := ca.CreateACL(group, read); err != nil {
+		t.Error(err)
+	}
+
+	matchAll := sarama.AclFilter{ResourceType: sarama.AclResourceAny, Operation: sarama.AclOperationAny, PermissionType: sarama.AclPermissionAny}
+	acls, err := ca.ListAcls(matchAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acls) != 2 || acls[0].Resource != topic || len(acls[0].Acls) != 2 || len(acls[1].Acls) != 1 {
+		t.Error("Expected the ACLs by resource, without duplicates, got", acls)
+	}
+
+	alice := "User:alice"
+	filter := matchAll
+	filter.Principal = &alice
+	deleted, err := ca.DeleteACL(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0].Resource != topic || deleted[1].Resource != group {
+		t.Error("Expected the ACLs of the principal to be deleted, got", deleted)
+	}
+	if acls, err := ca.ListAcls(matchAll); err != nil || len(acls) != 1 || len(acls[0].Acls) != 1 || *acls[0].Acls[0] != write {
+		t.Error("Expected the other ACL to be kept, got", acls, err)
+	}
+
+	if err := ca.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterAdminFailsTheNextCalls(t *testing.T) {
+	ca := NewClusterAdmin(t, nil)
+	ca.FailNextCall(sarama.ErrNotController).FailNextCall(sarama.ErrPolicyViolation)
+
+	detail := &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1}
+	if err := ca.CreateTopic("test", detail, false); err != sarama.ErrNotController {
+		t.Error("Expected the first error, got", err)
+	}
+	if _, err := ca.ListConsumerGroups(); err != sarama.ErrPolicyViolation {
+		t.Error("Expected the second error, got", err)
+	}
+	if err := ca.CreateTopic("test", detail, false); err != nil {
+		t.Error(err)
+	}
+
+	if err := ca.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterAdminReportsUnusedFailuresAndCallsAfterClose(t *testing.T) {
+	trm := newTestReporterMock()
+	ca := NewClusterAdmin(trm, nil)
+	ca.FailNextCall(sarama.ErrNotController)
+
+	if err := ca.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := ca.DeleteTopic("test"); err != sarama.ErrClosedClient {
+		t.Error("Expected a call after close to fail, got", err)
+	}
+	if len(trm.errors) != 2 {
+		t.Error("Expected the unused failure and the call after close to be reported, got", trm.errors)
+	}
+}
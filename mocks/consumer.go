@@ -16,6 +16,7 @@ type Consumer struct {
 	config             *sarama.Config
 	partitionConsumers map[string]map[int32]*PartitionConsumer
 	metadata           map[string][]int32
+	closeExpected      bool
 }
 
 // NewConsumer returns a new mock Consumer instance. The t argument should
@@ -63,6 +64,19 @@ func (c *Consumer) ConsumePartition(topic string, partition int32, offset int64)
 	return pc, nil
 }
 
+// Pause implements the Pause method from the sarama.Consumer interface. It does
+// nothing: the mock partition consumers yield the messages they are given regardless.
+func (c *Consumer) Pause(topicPartitions map[string][]int32) {}
+
+// Resume implements the Resume method from the sarama.Consumer interface. It does nothing.
+func (c *Consumer) Resume(topicPartitions map[string][]int32) {}
+
+// PauseAll implements the PauseAll method from the sarama.Consumer interface. It does nothing.
+func (c *Consumer) PauseAll() {}
+
+// ResumeAll implements the ResumeAll method from the sarama.Consumer interface. It does nothing.
+func (c *Consumer) ResumeAll() {}
+
 // Topics returns a list of topics, as registered with SetMetadata
 func (c *Consumer) Topics() ([]string, error) {
 	c.l.Lock()
@@ -97,13 +111,17 @@ func (c *Consumer) Partitions(topic string) ([]int32, error) {
 }
 
 // Close implements the Close method from the sarama.Consumer interface. It will close
-// all registered PartitionConsumer instances.
+// all registered PartitionConsumer instances, after reporting those that weren't closed
+// yet if ExpectPartitionConsumersClosed was called.
 func (c *Consumer) Close() error {
 	c.l.Lock()
 	defer c.l.Unlock()
 
 	for _, partitions := range c.partitionConsumers {
 		for _, partitionConsumer := range partitions {
+			if c.closeExpected && partitionConsumer.consumed && !partitionConsumer.isClosed() {
+				c.t.Errorf("Expected the partition consumer for %s/%d to be closed before the consumer.", partitionConsumer.topic, partitionConsumer.partition)
+			}
 			_ = partitionConsumer.Close()
 		}
 	}
@@ -129,7 +147,7 @@ func (c *Consumer) SetTopicMetadata(metadata map[string][]int32) {
 // on it using method chanining. Once a topic/partition is registered, you are
 // expected to start consuming it using ConsumePartition. If that doesn't happen,
 // an error will be written to the error reporter once the mock consumer is closed. It will
-// also expect that the
+// also expect that ConsumePartition is called with the given offset, unless it is AnyOffset.
 func (c *Consumer) ExpectConsumePartition(topic string, partition int32, offset int64) *PartitionConsumer {
 	c.l.Lock()
 	defer c.l.Unlock()
@@ -139,7 +157,7 @@ func (c *Consumer) ExpectConsumePartition(topic string, partition int32, offset
 	}
 
 	if c.partitionConsumers[topic][partition] == nil {
-		c.partitionConsumers[topic][partition] = &PartitionConsumer{
+		pc := &PartitionConsumer{
 			t:         c.t,
 			topic:     topic,
 			partition: partition,
@@ -147,11 +165,27 @@ func (c *Consumer) ExpectConsumePartition(topic string, partition int32, offset
 			messages:  make(chan *sarama.ConsumerMessage, c.config.ChannelBufferSize),
 			errors:    make(chan *sarama.ConsumerError, c.config.ChannelBufferSize),
 		}
+		if offset >= 0 {
+			// yield messages from the expected offset on
+			pc.highWaterMarkOffset = offset - 1
+		}
+		c.partitionConsumers[topic][partition] = pc
 	}
 
 	return c.partitionConsumers[topic][partition]
 }
 
+// ExpectPartitionConsumersClosed sets an expectation on the consumer that the code under
+// test closes every partition consumer it started before closing the consumer itself, as
+// sarama requires. Partition consumers that are still open are reported to the error
+// reporter when Close is called.
+func (c *Consumer) ExpectPartitionConsumersClosed() {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.closeExpected = true
+}
+
 ///////////////////////////////////////////////////
 // PartitionConsumer mock type
 ///////////////////////////////////////////////////
@@ -170,6 +204,7 @@ type PartitionConsumer struct {
 	messages                chan *sarama.ConsumerMessage
 	errors                  chan *sarama.ConsumerError
 	singleClose             sync.Once
+	closed                  int32
 	consumed                bool
 	errorsShouldBeDrained   bool
 	messagesShouldBeDrained bool
@@ -183,11 +218,16 @@ type PartitionConsumer struct {
 // AsyncClose implements the AsyncClose method from the sarama.PartitionConsumer interface.
 func (pc *PartitionConsumer) AsyncClose() {
 	pc.singleClose.Do(func() {
+		atomic.StoreInt32(&pc.closed, 1)
 		close(pc.messages)
 		close(pc.errors)
 	})
 }
 
+func (pc *PartitionConsumer) isClosed() bool {
+	return atomic.LoadInt32(&pc.closed) == 1
+}
+
 // Close implements the Close method from the sarama.PartitionConsumer interface. It will
 // verify whether the partition consumer was actually started.
 func (pc *PartitionConsumer) Close() error {
@@ -256,10 +296,11 @@ func (pc *PartitionConsumer) HighWaterMarkOffset() int64 {
 ///////////////////////////////////////////////////
 
 // YieldMessage will yield a messages Messages channel of this partition consumer
-// when it is consumed. By default, the mock consumer will not verify whether this
-// message was consumed from the Messages channel, because there are legitimate
-// reasons forthis not to happen. ou can call ExpectMessagesDrainedOnClose so it will
-// verify that the channel is empty on close.
+// when it is consumed. Yielded messages get consecutive offsets, starting at the
+// offset given to ExpectConsumePartition if it is an absolute one. By default, the
+// mock consumer will not verify whether this message was consumed from the Messages
+// channel, because there are legitimate reasons for this not to happen. You can call
+// ExpectMessagesDrainedOnClose so it will verify that the channel is empty on close.
 func (pc *PartitionConsumer) YieldMessage(msg *sarama.ConsumerMessage) {
 	pc.l.Lock()
 	defer pc.l.Unlock()
@@ -0,0 +1,163 @@
+package mocks
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Shopify/sarama"
+)
+
+// ConsumerGroupClaim implements sarama's ConsumerGroupClaim interface for testing purposes,
+// to test the code processing the claims of a ConsumerGroup member. Specify the messages
+// its Messages channel provides using YieldMessage, and release the claim with Close, as a
+// rebalance would, once the code under test has processed them. Close verifies the
+// expectations set on the claim.
+type ConsumerGroupClaim struct {
+	l                       sync.Mutex
+	t                       ErrorReporter
+	topic                   string
+	partition               int32
+	messages                chan *sarama.ConsumerMessage
+	highWaterMarkOffset     int64
+	offset                  int64
+	metadata                string
+	expectedOffset          int64
+	singleClose             sync.Once
+	closed                  bool
+	messagesShouldBeDrained bool
+}
+
+// NewConsumerGroupClaim returns a new mock ConsumerGroupClaim of the given topic/partition.
+// The t argument should be the *testing.T instance of your test method. An error will be
+// written to it if an expectation is violated. The messages yielded get consecutive offsets,
+// starting at the given one. The config argument is used for the size of the Messages
+// channel, and can be set to nil.
+func NewConsumerGroupClaim(t ErrorReporter, config *sarama.Config, topic string, partition int32, offset int64) *ConsumerGroupClaim {
+	if config == nil {
+		config = sarama.NewConfig()
+	}
+
+	return &ConsumerGroupClaim{
+		t:                   t,
+		topic:               topic,
+		partition:           partition,
+		messages:            make(chan *sarama.ConsumerMessage, config.ChannelBufferSize),
+		highWaterMarkOffset: offset - 1,
+		offset:              -1,
+		expectedOffset:      AnyOffset,
+	}
+}
+
+///////////////////////////////////////////////////
+// ConsumerGroupClaim interface implementation
+///////////////////////////////////////////////////
+
+// Topic implements the Topic method from the sarama.ConsumerGroupClaim interface.
+func (c *ConsumerGroupClaim) Topic() string {
+	return c.topic
+}
+
+// Partition implements the Partition method from the sarama.ConsumerGroupClaim interface.
+func (c *ConsumerGroupClaim) Partition() int32 {
+	return c.partition
+}
+
+// Messages implements the Messages method from the sarama.ConsumerGroupClaim interface.
+// The channel is closed when the claim is closed.
+func (c *ConsumerGroupClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
+
+// HighWaterMarkOffset implements the HighWaterMarkOffset method from the
+// sarama.ConsumerGroupClaim interface: it returns the offset following the last
+// yielded message.
+func (c *ConsumerGroupClaim) HighWaterMarkOffset() int64 {
+	return atomic.LoadInt64(&c.highWaterMarkOffset) + 1
+}
+
+// MarkOffset implements the MarkOffset method from the sarama.ConsumerGroupClaim
+// interface. Like sarama's, it only moves the marked offset forward, and ignores the
+// offsets marked after the claim is released.
+func (c *ConsumerGroupClaim) MarkOffset(offset int64, metadata string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if !c.closed && offset > c.offset {
+		c.offset = offset
+		c.metadata = metadata
+	}
+}
+
+///////////////////////////////////////////////////
+// Expectation API
+///////////////////////////////////////////////////
+
+// YieldMessage will yield a message on the Messages channel of this claim, with the
+// claim's topic/partition and the offset following the last yielded message. It blocks
+// while the channel is full, without preventing the code under test from marking offsets.
+func (c *ConsumerGroupClaim) YieldMessage(msg *sarama.ConsumerMessage) *ConsumerGroupClaim {
+	c.l.Lock()
+	if c.closed {
+		c.l.Unlock()
+		c.t.Errorf("Unexpected call to YieldMessage for %s/%d after the claim was closed.", c.topic, c.partition)
+		return c
+	}
+	msg.Topic = c.topic
+	msg.Partition = c.partition
+	msg.Offset = atomic.AddInt64(&c.highWaterMarkOffset, 1)
+	c.l.Unlock()
+
+	c.messages <- msg
+	return c
+}
+
+// ExpectMarkedOffset sets an expectation on the claim that the given offset is the last
+// one marked (and so the one that would be committed) when it is closed. If this
+// expectation is not met, an error is reported to the error reporter.
+func (c *ConsumerGroupClaim) ExpectMarkedOffset(offset int64) *ConsumerGroupClaim {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.expectedOffset = offset
+	return c
+}
+
+// ExpectMessagesDrainedOnClose sets an expectation on the claim that the messages channel
+// will be fully drained when Close is called. If this expectation is not met, an error is
+// reported to the error reporter.
+func (c *ConsumerGroupClaim) ExpectMessagesDrainedOnClose() *ConsumerGroupClaim {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.messagesShouldBeDrained = true
+	return c
+}
+
+// MarkedOffset returns the last offset marked on the claim, or -1 if none was, with its
+// metadata.
+func (c *ConsumerGroupClaim) MarkedOffset() (int64, string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	return c.offset, c.metadata
+}
+
+// Close releases the claim, closing its Messages channel, and verifies the expectations
+// set on it. Calling it again has no effect.
+func (c *ConsumerGroupClaim) Close() error {
+	c.singleClose.Do(func() {
+		c.l.Lock()
+		defer c.l.Unlock()
+
+		if c.messagesShouldBeDrained && len(c.messages) > 0 {
+			c.t.Errorf("Expected the messages channel for %s/%d to be drained on close, but found %d messages.", c.topic, c.partition, len(c.messages))
+		}
+		if c.expectedOffset != AnyOffset && c.offset != c.expectedOffset {
+			c.t.Errorf("Expected offset %d to be marked for %s/%d, but the last marked offset is %d.", c.expectedOffset, c.topic, c.partition, c.offset)
+		}
+
+		c.closed = true
+		close(c.messages)
+	})
+	return nil
+}
@@ -0,0 +1,64 @@
+package mocks
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestMockConsumerGroupClaimImplementsConsumerGroupClaimInterface(t *testing.T) {
+	var c interface{} = &ConsumerGroupClaim{}
+	if _, ok := c.(sarama.ConsumerGroupClaim); !ok {
+		t.Error("The mock consumer group claim should implement the sarama.ConsumerGroupClaim interface.")
+	}
+}
+
+func TestConsumerGroupClaimYieldsMessagesAndMarksOffsets(t *testing.T) {
+	claim := NewConsumerGroupClaim(t, nil, "test", 1, 10)
+	claim.YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello")}).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("world")}).
+		ExpectMarkedOffset(11).
+		ExpectMessagesDrainedOnClose()
+
+	if claim.HighWaterMarkOffset() != 12 {
+		t.Error("Expected the high water mark to follow the last message, got", claim.HighWaterMarkOffset())
+	}
+	for offset := int64(10); offset < 12; offset++ {
+		msg := <-claim.Messages()
+		if msg.Topic != "test" || msg.Partition != 1 || msg.Offset != offset {
+			t.Errorf("Expected the message at test/1/%d, got %s/%d/%d", offset, msg.Topic, msg.Partition, msg.Offset)
+		}
+		claim.MarkOffset(msg.Offset, "meta")
+	}
+	claim.MarkOffset(10, "")
+	if offset, metadata := claim.MarkedOffset(); offset != 11 || metadata != "meta" {
+		t.Error("Expected marking offsets to only move forward, got", offset, metadata)
+	}
+
+	if err := claim.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, ok := <-claim.Messages(); ok {
+		t.Error("Expected the messages channel to be closed")
+	}
+	claim.MarkOffset(12, "")
+	if offset, _ := claim.MarkedOffset(); offset != 11 {
+		t.Error("Expected the offsets marked after close to be ignored, got", offset)
+	}
+}
+
+func TestConsumerGroupClaimReportsUnmetExpectationsOnClose(t *testing.T) {
+	trm := newTestReporterMock()
+	claim := NewConsumerGroupClaim(trm, nil, "test", 0, 0)
+	claim.YieldMessage(&sarama.ConsumerMessage{}).
+		ExpectMarkedOffset(0).
+		ExpectMessagesDrainedOnClose()
+
+	if err := claim.Close(); err != nil {
+		t.Error(err)
+	}
+	claim.YieldMessage(&sarama.ConsumerMessage{})
+	if len(trm.errors) != 3 {
+		t.Error("Expected the undrained message, the unmarked offset and the message yielded after close to be reported, got", trm.errors)
+	}
+}
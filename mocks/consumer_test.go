@@ -247,3 +247,53 @@ func TestConsumerUnexpectedTopicMetadata(t *testing.T) {
 		t.Errorf("Expected an expectation failure to be set on the error reporter.")
 	}
 }
+
+func TestConsumerYieldsFromExpectedOffset(t *testing.T) {
+	consumer := NewConsumer(t, nil)
+	consumer.ExpectConsumePartition("test", 0, 100).YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello")})
+
+	pc, err := consumer.ConsumePartition("test", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-pc.Messages(); msg.Offset != 100 {
+		t.Error("Expected the first message at offset 100, got", msg.Offset)
+	}
+	if hwm := pc.HighWaterMarkOffset(); hwm != 101 {
+		t.Error("Expected a high water mark of 101, got", hwm)
+	}
+
+	if err := pc.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := consumer.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestConsumerViolatesPartitionConsumersClosedExpectation(t *testing.T) {
+	trm := newTestReporterMock()
+	consumer := NewConsumer(trm, nil)
+	consumer.ExpectPartitionConsumersClosed()
+	consumer.ExpectConsumePartition("test", 0, sarama.OffsetOldest)
+	consumer.ExpectConsumePartition("test", 1, sarama.OffsetOldest)
+
+	pc0, err := consumer.ConsumePartition("test", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := consumer.ConsumePartition("test", 1, sarama.OffsetOldest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pc0.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := consumer.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if len(trm.errors) != 1 {
+		t.Error("Expected the unclosed partition consumer to be reported, got", trm.errors)
+	}
+}
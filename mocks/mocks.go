@@ -15,6 +15,7 @@ package mocks
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/Shopify/sarama"
 )
@@ -33,8 +34,68 @@ var (
 
 const AnyOffset int64 = -1000
 
+// ValueChecker is a function checking the encoded value of a message handed to
+// a producer mock. A non-nil error fails the message and is reported to the
+// ErrorReporter.
+type ValueChecker func(val []byte) error
+
 type producerExpectation struct {
-	Result error
+	Result        error
+	CheckFunction ValueChecker
+}
+
+// check runs the expectation's ValueChecker, if any, on the value of msg.
+func (pe *producerExpectation) check(msg *sarama.ProducerMessage) error {
+	if pe.CheckFunction == nil {
+		return nil
+	}
+	var val []byte
+	if msg.Value != nil {
+		var err error
+		if val, err = msg.Value.Encode(); err != nil {
+			return fmt.Errorf("Input message encoding failed: %s", err)
+		}
+	}
+	if err := pe.CheckFunction(val); err != nil {
+		return fmt.Errorf("Check function returned an error: %s", err)
+	}
+	return nil
+}
+
+// mockTxn tracks the transactions begun and ended on a producer mock, which
+// is transactional when its config sets Producer.Transaction.ID.
+type mockTxn struct {
+	transactional bool
+	ongoing       bool
+}
+
+func (mt *mockTxn) begin() error {
+	switch {
+	case !mt.transactional:
+		return sarama.ErrNotTransactional
+	case mt.ongoing:
+		return sarama.ErrTransactionNotReady
+	}
+	mt.ongoing = true
+	return nil
+}
+
+func (mt *mockTxn) check() error {
+	switch {
+	case !mt.transactional:
+		return sarama.ErrNotTransactional
+	case !mt.ongoing:
+		return sarama.ErrTransactionNotReady
+	}
+	return nil
+}
+
+func (mt *mockTxn) end() error {
+	if err := mt.check(); err != nil {
+		return err
+	}
+	mt.ongoing = false
+	return nil
 }
 
 type consumerExpectation struct {
@@ -0,0 +1,257 @@
+package mocks
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// OffsetManager implements sarama's OffsetManager interface for testing purposes.
+// Before you can manage a partition's offsets with this offset manager, you have to
+// register the topic/partition using ExpectManagePartition, and set expectations on it.
+type OffsetManager struct {
+	l      sync.Mutex
+	t      ErrorReporter
+	config *sarama.Config
+	poms   map[string]map[int32]*PartitionOffsetManager
+}
+
+// NewOffsetManager returns a new mock OffsetManager instance. The t argument should
+// be the *testing.T instance of your test method. An error will be written to it if
+// an expectation is violated. The config argument is used for the initial offset of
+// partitions without a committed offset, and can be set to nil.
+func NewOffsetManager(t ErrorReporter, config *sarama.Config) *OffsetManager {
+	if config == nil {
+		config = sarama.NewConfig()
+	}
+
+	return &OffsetManager{
+		t:      t,
+		config: config,
+		poms:   make(map[string]map[int32]*PartitionOffsetManager),
+	}
+}
+
+///////////////////////////////////////////////////
+// OffsetManager interface implementation
+///////////////////////////////////////////////////
+
+// ManagePartition implements the ManagePartition method from the sarama.OffsetManager
+// interface. Before you can manage a partition, you have to set expectations on it using
+// ExpectManagePartition. You can only manage a partition once per offset manager.
+func (om *OffsetManager) ManagePartition(topic string, partition int32) (sarama.PartitionOffsetManager, error) {
+	om.l.Lock()
+	defer om.l.Unlock()
+
+	if om.poms[topic] == nil || om.poms[topic][partition] == nil {
+		om.t.Errorf("No expectations set for %s/%d", topic, partition)
+		return nil, errOutOfExpectations
+	}
+
+	pom := om.poms[topic][partition]
+	if pom.managed {
+		return nil, sarama.ConfigurationError("That topic/partition is already being managed")
+	}
+
+	pom.managed = true
+	return pom, nil
+}
+
+// Commit implements the Commit method from the sarama.OffsetManager interface. It
+// does nothing, as the mock offset managers don't commit the offsets marked.
+func (om *OffsetManager) Commit() {}
+
+// Close implements the Close method from the sarama.OffsetManager interface. It will
+// report the partitions that were registered but never managed, and the partition
+// offset managers that weren't closed before the offset manager, as sarama requires.
+func (om *OffsetManager) Close() error {
+	om.l.Lock()
+	defer om.l.Unlock()
+
+	for _, partitions := range om.poms {
+		for _, pom := range partitions {
+			if !pom.managed {
+				om.t.Errorf("Expectations set on %s/%d, but no partition offset manager was started.", pom.topic, pom.partition)
+			} else if !pom.isClosed() {
+				om.t.Errorf("Expected the partition offset manager for %s/%d to be closed before the offset manager.", pom.topic, pom.partition)
+			}
+		}
+	}
+
+	return nil
+}
+
+///////////////////////////////////////////////////
+// Expectation API
+///////////////////////////////////////////////////
+
+// ExpectManagePartition will register a topic/partition, so you can set expectations on it.
+// The registered PartitionOffsetManager will be returned, so you can set expectations on it
+// using method chaining. Its NextOffset will start from the given committed offset and
+// metadata; use sarama.OffsetNewest or sarama.OffsetOldest as the offset to mimic a partition
+// without a committed offset, for which the config's Consumer.Offsets.Initial is returned.
+func (om *OffsetManager) ExpectManagePartition(topic string, partition int32, offset int64, metadata string) *PartitionOffsetManager {
+	om.l.Lock()
+	defer om.l.Unlock()
+
+	if om.poms[topic] == nil {
+		om.poms[topic] = make(map[int32]*PartitionOffsetManager)
+	}
+
+	if om.poms[topic][partition] == nil {
+		om.poms[topic][partition] = &PartitionOffsetManager{
+			t:              om.t,
+			topic:          topic,
+			partition:      partition,
+			initial:        om.config.Consumer.Offsets.Initial,
+			offset:         offset,
+			metadata:       metadata,
+			expectedOffset: AnyOffset,
+			errors:         make(chan *sarama.ConsumerError, om.config.ChannelBufferSize),
+		}
+	}
+
+	return om.poms[topic][partition]
+}
+
+///////////////////////////////////////////////////
+// PartitionOffsetManager mock type
+///////////////////////////////////////////////////
+
+// PartitionOffsetManager implements sarama's PartitionOffsetManager interface for testing
+// purposes. It is returned by the mock OffsetManager's ManagePartition method, but only if
+// it is registered first using the OffsetManager's ExpectManagePartition method. Like
+// sarama's, it only moves its offset forward when MarkOffset is called, and in either
+// direction when ResetOffset is.
+type PartitionOffsetManager struct {
+	l              sync.Mutex
+	t              ErrorReporter
+	topic          string
+	partition      int32
+	initial        int64
+	offset         int64
+	metadata       string
+	expectedOffset int64
+	errors         chan *sarama.ConsumerError
+	singleClose    sync.Once
+	managed        bool
+	closed         bool
+}
+
+///////////////////////////////////////////////////
+// PartitionOffsetManager interface implementation
+///////////////////////////////////////////////////
+
+// NextOffset implements the NextOffset method from the sarama.PartitionOffsetManager
+// interface: it returns the offset following the last marked or committed one.
+func (pom *PartitionOffsetManager) NextOffset() (int64, string) {
+	pom.l.Lock()
+	defer pom.l.Unlock()
+
+	if pom.offset >= 0 {
+		return pom.offset + 1, pom.metadata
+	}
+	return pom.initial, ""
+}
+
+// MarkOffset implements the MarkOffset method from the sarama.PartitionOffsetManager
+// interface.
+func (pom *PartitionOffsetManager) MarkOffset(offset int64, metadata string) {
+	pom.l.Lock()
+	defer pom.l.Unlock()
+
+	if pom.closed {
+		pom.t.Errorf("Unexpected call to MarkOffset for %s/%d after the partition offset manager was closed.", pom.topic, pom.partition)
+		return
+	}
+
+	if offset > pom.offset {
+		pom.offset = offset
+		pom.metadata = metadata
+	}
+}
+
+// ResetOffset implements the ResetOffset method from the sarama.PartitionOffsetManager
+// interface: unlike MarkOffset, it also moves the offset backwards.
+func (pom *PartitionOffsetManager) ResetOffset(offset int64, metadata string) {
+	pom.l.Lock()
+	defer pom.l.Unlock()
+
+	if pom.closed {
+		pom.t.Errorf("Unexpected call to ResetOffset for %s/%d after the partition offset manager was closed.", pom.topic, pom.partition)
+		return
+	}
+
+	pom.offset = offset
+	pom.metadata = metadata
+}
+
+// Errors implements the Errors method from the sarama.PartitionOffsetManager interface.
+func (pom *PartitionOffsetManager) Errors() <-chan *sarama.ConsumerError {
+	return pom.errors
+}
+
+// AsyncClose implements the AsyncClose method from the sarama.PartitionOffsetManager
+// interface. It will verify the offset set with ExpectMarkedOffset, if any.
+func (pom *PartitionOffsetManager) AsyncClose() {
+	pom.singleClose.Do(func() {
+		pom.l.Lock()
+		defer pom.l.Unlock()
+
+		if pom.expectedOffset != AnyOffset && pom.offset != pom.expectedOffset {
+			pom.t.Errorf("Expected offset %d to be marked for %s/%d, but the last marked offset is %d.", pom.expectedOffset, pom.topic, pom.partition, pom.offset)
+		}
+
+		pom.closed = true
+		close(pom.errors)
+	})
+}
+
+func (pom *PartitionOffsetManager) isClosed() bool {
+	pom.l.Lock()
+	defer pom.l.Unlock()
+
+	return pom.closed
+}
+
+// Close implements the Close method from the sarama.PartitionOffsetManager interface.
+// It returns the errors that were yielded but not consumed from the Errors channel.
+func (pom *PartitionOffsetManager) Close() error {
+	pom.AsyncClose()
+
+	var errs = make(sarama.ConsumerErrors, 0)
+	for err := range pom.errors {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////
+// Expectation API
+///////////////////////////////////////////////////
+
+// YieldError will yield an error on the Errors channel of this partition offset manager,
+// as sarama's does for failed commits when Consumer.Return.Errors is set.
+func (pom *PartitionOffsetManager) YieldError(err error) *PartitionOffsetManager {
+	pom.errors <- &sarama.ConsumerError{
+		Topic:     pom.topic,
+		Partition: pom.partition,
+		Err:       err,
+	}
+	return pom
+}
+
+// ExpectMarkedOffset sets an expectation on the partition offset manager that the given
+// offset is the last one marked (and so the one that would be committed) when it is
+// closed. If this expectation is not met, an error is reported to the error reporter.
+func (pom *PartitionOffsetManager) ExpectMarkedOffset(offset int64) *PartitionOffsetManager {
+	pom.l.Lock()
+	defer pom.l.Unlock()
+
+	pom.expectedOffset = offset
+	return pom
+}
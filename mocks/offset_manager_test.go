@@ -0,0 +1,122 @@
+package mocks
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+func TestMockOffsetManagerImplementsOffsetManagerInterface(t *testing.T) {
+	var om interface{} = &OffsetManager{}
+	if _, ok := om.(sarama.OffsetManager); !ok {
+		t.Error("The mock offset manager should implement the sarama.OffsetManager interface.")
+	}
+
+	var pom interface{} = &PartitionOffsetManager{}
+	if _, ok := pom.(sarama.PartitionOffsetManager); !ok {
+		t.Error("The mock partition offset manager should implement the sarama.PartitionOffsetManager interface.")
+	}
+}
+
+func TestOffsetManagerHandlesExpectations(t *testing.T) {
+	om := NewOffsetManager(t, nil)
+	om.ExpectManagePartition("test", 0, 41, "meta").ExpectMarkedOffset(43)
+	om.ExpectManagePartition("test", 1, sarama.OffsetNewest, "")
+
+	pom0, err := om.ManagePartition("test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, metadata := pom0.NextOffset(); offset != 42 || metadata != "meta" {
+		t.Errorf("Expected the committed offset to be resumed from, got %d %q", offset, metadata)
+	}
+	pom0.MarkOffset(43, "")
+	pom0.MarkOffset(40, "older")
+	if offset, metadata := pom0.NextOffset(); offset != 44 || metadata != "" {
+		t.Errorf("Expected marking an older offset to be ignored, got %d %q", offset, metadata)
+	}
+
+	pom1, err := om.ManagePartition("test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, _ := pom1.NextOffset(); offset != sarama.NewConfig().Consumer.Offsets.Initial {
+		t.Error("Expected the initial offset of the config, got", offset)
+	}
+	pom1.ResetOffset(10, "reset")
+	pom1.ResetOffset(5, "reset")
+	if offset, metadata := pom1.NextOffset(); offset != 6 || metadata != "reset" {
+		t.Errorf("Expected resetting to an older offset to move back, got %d %q", offset, metadata)
+	}
+
+	if _, err := om.ManagePartition("test", 1); err == nil {
+		t.Error("Expected an error when managing a partition twice")
+	}
+
+	if err := pom0.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := pom1.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := om.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOffsetManagerReturnsYieldedErrorsOnClose(t *testing.T) {
+	om := NewOffsetManager(t, nil)
+	om.ExpectManagePartition("test", 0, 0, "").YieldError(sarama.ErrNotCoordinatorForConsumer)
+
+	pom, err := om.ManagePartition("test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs, ok := pom.Close().(sarama.ConsumerErrors); !ok || len(errs) != 1 || errs[0].Err != sarama.ErrNotCoordinatorForConsumer {
+		t.Error("Expected the yielded error to be returned, got", errs)
+	}
+	if err := om.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOffsetManagerViolatesMarkedOffsetExpectation(t *testing.T) {
+	trm := newTestReporterMock()
+	om := NewOffsetManager(trm, nil)
+	om.ExpectManagePartition("test", 0, 10, "").ExpectMarkedOffset(12)
+
+	pom, err := om.ManagePartition("test", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pom.MarkOffset(11, "")
+	if err := pom.Close(); err != nil {
+		t.Error(err)
+	}
+	pom.MarkOffset(12, "")
+	if err := om.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if len(trm.errors) != 2 {
+		t.Error("Expected the marked offset and the late MarkOffset to be reported, found", trm.errors)
+	}
+}
+
+func TestOffsetManagerViolatesCloseOrderExpectation(t *testing.T) {
+	trm := newTestReporterMock()
+	om := NewOffsetManager(trm, nil)
+	om.ExpectManagePartition("test", 0, 0, "")
+	om.ExpectManagePartition("test", 1, 0, "")
+
+	if _, err := om.ManagePartition("test", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := om.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if len(trm.errors) != 2 {
+		t.Error("Expected the unclosed and the unmanaged partitions to be reported, found", trm.errors)
+	}
+}
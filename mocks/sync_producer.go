@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"sync"
 
 	"github.com/Shopify/sarama"
@@ -15,16 +16,21 @@ type SyncProducer struct {
 	t            ErrorReporter
 	expectations []*producerExpectation
 	lastOffset   int64
+	txn          mockTxn
 }
 
 // NewSyncProducer instantiates a new SyncProducer mock. The t argument should
 // be the *testing.T instance of your test method. An error will be written to it if
-// an expectation is violated. The config argument is currently unused, but is
-// maintained to be compatible with the async Producer.
+// an expectation is violated. The config argument is only used to determine
+// whether the producer is transactional.
 func NewSyncProducer(t ErrorReporter, config *sarama.Config) *SyncProducer {
+	if config == nil {
+		config = sarama.NewConfig()
+	}
 	return &SyncProducer{
 		t:            t,
 		expectations: make([]*producerExpectation, 0),
+		txn:          mockTxn{transactional: config.Producer.Transaction.ID != ""},
 	}
 }
 
@@ -44,6 +50,10 @@ func (sp *SyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int3
 		expectation := sp.expectations[0]
 		sp.expectations = sp.expectations[1:]
 
+		if err := expectation.check(msg); err != nil {
+			sp.t.Errorf("%s", err)
+			return -1, -1, err
+		}
 		if expectation.Result == errProduceSuccess {
 			sp.lastOffset++
 			msg.Offset = sp.lastOffset
@@ -57,6 +67,16 @@ func (sp *SyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int3
 	}
 }
 
+// SendMessageContext corresponds with the SendMessageContext method of sarama's SyncProducer
+// implementation. It returns the context's error without consuming an expectation if the
+// context is already done, and otherwise handles the message as SendMessage does.
+func (sp *SyncProducer) SendMessageContext(ctx context.Context, msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return -1, -1, err
+	}
+	return sp.SendMessage(msg)
+}
+
 // Close corresponds with the Close method of sarama's SyncProducer implementation.
 // By closing a mock syncproducer, you also tell it that no more SendMessage calls will follow,
 // so it will write an error to the test state if there's any remaining expectations.
@@ -71,6 +91,45 @@ func (sp *SyncProducer) Close() error {
 	return nil
 }
 
+// BeginTxn corresponds with the BeginTxn method of sarama's SyncProducer implementation.
+// It fails unless the config the mock was created with sets Producer.Transaction.ID,
+// and while a transaction is ongoing.
+func (sp *SyncProducer) BeginTxn() error {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+	return sp.txn.begin()
+}
+
+// CommitTxn corresponds with the CommitTxn method of sarama's SyncProducer implementation.
+// It fails unless a transaction is ongoing.
+func (sp *SyncProducer) CommitTxn() error {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+	return sp.txn.end()
+}
+
+// AbortTxn corresponds with the AbortTxn method of sarama's SyncProducer implementation.
+// It fails unless a transaction is ongoing.
+func (sp *SyncProducer) AbortTxn() error {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+	return sp.txn.end()
+}
+
+// AddOffsetsToTxn corresponds with the AddOffsetsToTxn method of sarama's SyncProducer
+// implementation. It fails unless a transaction is ongoing.
+func (sp *SyncProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupID string) error {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+	return sp.txn.check()
+}
+
+// AddMessageToTxn corresponds with the AddMessageToTxn method of sarama's SyncProducer
+// implementation. It fails unless a transaction is ongoing.
+func (sp *SyncProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupID string, metadata *string) error {
+	return sp.AddOffsetsToTxn(nil, groupID)
+}
+
 ////////////////////////////////////////////////
 // Setting expectations
 ////////////////////////////////////////////////
@@ -92,3 +151,22 @@ func (sp *SyncProducer) ExpectSendMessageAndFail(err error) {
 	defer sp.l.Unlock()
 	sp.expectations = append(sp.expectations, &producerExpectation{Result: err})
 }
+
+// ExpectSendMessageWithCheckerFunctionAndSucceed sets an expectation on the mock producer that
+// SendMessage will be called with a message whose value passes the given checker. The mock
+// producer will handle the message as if it produced successfully, unless the checker fails.
+func (sp *SyncProducer) ExpectSendMessageWithCheckerFunctionAndSucceed(cf ValueChecker) {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+	sp.expectations = append(sp.expectations, &producerExpectation{Result: errProduceSuccess, CheckFunction: cf})
+}
+
+// ExpectSendMessageWithCheckerFunctionAndFail sets an expectation on the mock producer that
+// SendMessage will be called with a message whose value passes the given checker. The mock
+// producer will handle the message as if it failed to produce successfully, by returning
+// the provided error, or the checker's error if it fails.
+func (sp *SyncProducer) ExpectSendMessageWithCheckerFunctionAndFail(cf ValueChecker, err error) {
+	sp.l.Lock()
+	defer sp.l.Unlock()
+	sp.expectations = append(sp.expectations, &producerExpectation{Result: err, CheckFunction: cf})
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/Shopify/sarama"
@@ -53,6 +55,26 @@ func TestSyncProducerReturnsExpectationsToSendMessage(t *testing.T) {
 	}
 }
 
+func TestSyncProducerSendMessageContext(t *testing.T) {
+	sp := NewSyncProducer(t, nil)
+	sp.ExpectSendMessageAndSucceed()
+
+	msg := &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("test")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := sp.SendMessageContext(ctx, msg); err != context.Canceled {
+		t.Errorf("A done context should have stopped the send, but got %v", err)
+	}
+	if _, offset, err := sp.SendMessageContext(context.Background(), msg); err != nil || offset != 1 {
+		t.Errorf("The message should have been produced at offset 1, but got %d and %v", offset, err)
+	}
+
+	if err := sp.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestSyncProducerWithTooManyExpectations(t *testing.T) {
 	trm := newTestReporterMock()
 
@@ -96,3 +118,53 @@ func TestSyncProducerWithTooFewExpectations(t *testing.T) {
 		t.Error("Expected to report an error")
 	}
 }
+
+func TestSyncProducerWithCheckerFunction(t *testing.T) {
+	trm := newTestReporterMock()
+
+	sp := NewSyncProducer(trm, nil)
+	sp.ExpectSendMessageWithCheckerFunctionAndSucceed(generateRegexpChecker("^tes"))
+	sp.ExpectSendMessageWithCheckerFunctionAndFail(generateRegexpChecker("^tes$"), sarama.ErrOutOfBrokers)
+
+	msg := &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("test")}
+	if _, _, err := sp.SendMessage(msg); err != nil {
+		t.Error("No error expected on first SendMessage call, found:", err)
+	}
+	if _, _, err := sp.SendMessage(msg); err == nil || !strings.HasPrefix(err.Error(), "Check function returned an error") {
+		t.Error("Expected the checker to fail the second SendMessage call, found:", err)
+	}
+
+	if err := sp.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if len(trm.errors) != 1 {
+		t.Error("Expected to report the failed check, got", trm.errors)
+	}
+}
+
+func TestSyncProducerTransactions(t *testing.T) {
+	sp := NewSyncProducer(t, nil)
+	if err := sp.BeginTxn(); err != sarama.ErrNotTransactional {
+		t.Errorf("A producer mock without a transactional ID should refuse transactions, but got %v", err)
+	}
+
+	config := sarama.NewConfig()
+	config.Producer.Transaction.ID = "test"
+	sp = NewSyncProducer(t, config)
+	if err := sp.CommitTxn(); err != sarama.ErrTransactionNotReady {
+		t.Errorf("Committing without a transaction should fail, but got %v", err)
+	}
+	if err := sp.BeginTxn(); err != nil {
+		t.Error(err)
+	}
+	if err := sp.AddMessageToTxn(&sarama.ConsumerMessage{Topic: "test"}, "group", nil); err != nil {
+		t.Error(err)
+	}
+	if err := sp.CommitTxn(); err != nil {
+		t.Error(err)
+	}
+	if err := sp.AbortTxn(); err != sarama.ErrTransactionNotReady {
+		t.Errorf("Aborting without a transaction should fail, but got %v", err)
+	}
+}
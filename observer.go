@@ -0,0 +1,124 @@
+package sarama
+
+import (
+	"fmt"
+	"time"
+)
+
+// RequestObserver is notified of every request a Broker sends, once the response
+// has been received and decoded or the request has failed. The request and the
+// response are the Request and Response types of the broker method called (for
+// example *MetadataRequest and *MetadataResponse); response is nil if the request
+// failed or, as for produce requests with NoResponse, no response was expected.
+// The latency runs from the call until that point.
+//
+// Observers are called synchronously on the goroutine making the request, and
+// potentially on several goroutines at once, so they must be safe for concurrent
+// use and should return quickly. They must not modify the request or response.
+type RequestObserver interface {
+	ObserveRequest(broker *Broker, request, response interface{}, err error, latency time.Duration)
+}
+
+// RequestObserverFunc is an adapter allowing an ordinary function to be used as a
+// RequestObserver.
+type RequestObserverFunc func(broker *Broker, request, response interface{}, err error, latency time.Duration)
+
+// ObserveRequest calls f(broker, request, response, err, latency).
+func (f RequestObserverFunc) ObserveRequest(broker *Broker, request, response interface{}, err error, latency time.Duration) {
+	f(broker, request, response, err, latency)
+}
+
+func (b *Broker) observe(request, response interface{}, err error, start time.Time) {
+	observers, _ := b.observers.Load().([]RequestObserver)
+	if len(observers) == 0 {
+		return
+	}
+
+	if err != nil {
+		response = nil
+	}
+	latency := time.Since(start)
+	for _, observer := range observers {
+		observer.ObserveRequest(b, request, response, err, latency)
+	}
+}
+
+// ConnectionEvent is a change in the state of a Broker's connection.
+type ConnectionEvent int
+
+const (
+	// ConnectionOpened means the broker connected, including the TLS handshake
+	// and SASL authentication if they are enabled.
+	ConnectionOpened ConnectionEvent = iota
+	// ConnectionFailed means the broker could not connect to its address.
+	ConnectionFailed
+	// ConnectionAuthenticationFailed means the broker connected but the TLS
+	// handshake or SASL authentication failed.
+	ConnectionAuthenticationFailed
+	// ConnectionClosed means the connection was closed by Close.
+	ConnectionClosed
+)
+
+func (e ConnectionEvent) String() string {
+	switch e {
+	case ConnectionOpened:
+		return "opened"
+	case ConnectionFailed:
+		return "failed"
+	case ConnectionAuthenticationFailed:
+		return "authentication failed"
+	case ConnectionClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("ConnectionEvent(%d)", int(e))
+}
+
+// ConnectionObserver is notified each time a Broker connects, fails to connect or
+// is closed, with the error that caused the failure if any. Together with the
+// connection metrics this makes flapping connections visible without reading logs.
+//
+// Observers are called on the goroutine that connected or closed the broker, once
+// the broker's lock is released, so they may call the broker's methods but may be
+// notified of a connection after Connected has returned. They must be safe for
+// concurrent use and should return quickly.
+type ConnectionObserver interface {
+	ObserveConnection(broker *Broker, event ConnectionEvent, err error)
+}
+
+// ConnectionObserverFunc is an adapter allowing an ordinary function to be used
+// as a ConnectionObserver.
+type ConnectionObserverFunc func(broker *Broker, event ConnectionEvent, err error)
+
+// ObserveConnection calls f(broker, event, err).
+func (f ConnectionObserverFunc) ObserveConnection(broker *Broker, event ConnectionEvent, err error) {
+	f(broker, event, err)
+}
+
+func (b *Broker) observeConnection(conf *Config, event ConnectionEvent, err error) {
+	for _, observer := range conf.ConnectionObservers {
+		observer.ObserveConnection(b, event, err)
+	}
+}
+
+// ThrottleObserver is notified each time a broker reports that it throttled a
+// produce or fetch response because the client exceeded a quota, with the API
+// ("produce" or "fetch") and the throttle time, so that applications can adapt
+// the rate they send at. Brokers of Kafka 2.0 and later answer fetch requests
+// of version 8 and later right away and stop reading from the connection for
+// the throttle time instead, so a Broker then holds back its next requests
+// until the throttle time has passed.
+//
+// Observers are called synchronously on the goroutine that received the
+// response, so they must be safe for concurrent use and should return quickly.
+type ThrottleObserver interface {
+	ObserveThrottle(broker *Broker, api string, throttleTime time.Duration)
+}
+
+// ThrottleObserverFunc is an adapter allowing an ordinary function to be used as
+// a ThrottleObserver.
+type ThrottleObserverFunc func(broker *Broker, api string, throttleTime time.Duration)
+
+// ObserveThrottle calls f(broker, api, throttleTime).
+func (f ThrottleObserverFunc) ObserveThrottle(broker *Broker, api string, throttleTime time.Duration) {
+	f(broker, api, throttleTime)
+}
@@ -21,7 +21,7 @@ func (r *offsetCommitRequestBlock) encode(pe packetEncoder, version int16) error
 	if version == 1 {
 		pe.putInt64(r.timestamp)
 	} else if r.timestamp != 0 {
-		Logger.Println("Non-zero timestamp specified for OffsetCommitRequest not v1, it will be ignored")
+		LogConfig.warn("non-zero timestamp specified for OffsetCommitRequest not v1, it will be ignored")
 	}
 
 	return pe.putString(r.metadata)
@@ -70,17 +70,17 @@ func (r *OffsetCommitRequest) encode(pe packetEncoder) error {
 		}
 	} else {
 		if r.ConsumerGroupGeneration != 0 {
-			Logger.Println("Non-zero ConsumerGroupGeneration specified for OffsetCommitRequest v0, it will be ignored")
+			LogConfig.warn("non-zero ConsumerGroupGeneration specified for OffsetCommitRequest v0, it will be ignored")
 		}
 		if r.ConsumerID != "" {
-			Logger.Println("Non-empty ConsumerID specified for OffsetCommitRequest v0, it will be ignored")
+			LogConfig.warn("non-empty ConsumerID specified for OffsetCommitRequest v0, it will be ignored")
 		}
 	}
 
 	if r.Version >= 2 {
 		pe.putInt64(r.RetentionTime)
 	} else if r.RetentionTime != 0 {
-		Logger.Println("Non-zero RetentionTime specified for OffsetCommitRequest version <2, it will be ignored")
+		LogConfig.warn("non-zero RetentionTime specified for OffsetCommitRequest version <2, it will be ignored")
 	}
 
 	if err := pe.putArrayLength(len(r.blocks)); err != nil {
@@ -2,18 +2,27 @@ package sarama
 
 type OffsetFetchRequest struct {
 	ConsumerGroup string
-	Version       int16
-	partitions    map[string][]int32
+	// Version can be 0, for offsets stored in Zookeeper, 1 for those stored in
+	// Kafka, or 2 for Kafka 0.10.2, which with no partitions added asks for all
+	// the offsets the group committed, and whose response holds an error for
+	// the request as a whole.
+	Version    int16
+	partitions map[string][]int32
 }
 
 func (r *OffsetFetchRequest) encode(pe packetEncoder) (err error) {
-	if r.Version < 0 || r.Version > 1 {
+	if r.Version < 0 || r.Version > 2 {
 		return PacketEncodingError{"invalid or unsupported OffsetFetchRequest version field"}
 	}
 
 	if err = pe.putString(r.ConsumerGroup); err != nil {
 		return err
 	}
+	if r.Version >= 2 && len(r.partitions) == 0 {
+		// from version 2 null asks for every partition
+		pe.putInt32(-1)
+		return nil
+	}
 	if err = pe.putArrayLength(len(r.partitions)); err != nil {
 		return err
 	}
@@ -32,13 +41,18 @@ func (r *OffsetFetchRequest) decode(pd packetDecoder) (err error) {
 	if r.ConsumerGroup, err = pd.getString(); err != nil {
 		return err
 	}
-	partitionCount, err := pd.getArrayLength()
+	n, err := pd.getInt32()
 	if err != nil {
 		return err
 	}
-	if partitionCount == 0 {
+	if n == 0 || (n == -1 && r.Version >= 2) {
 		return nil
 	}
+	partitionCount := int(n)
+	// every topic takes at least its two length bytes
+	if partitionCount < 0 || 2*partitionCount > pd.remaining() {
+		return PacketDecodingError{"invalid array length"}
+	}
 	r.partitions = make(map[string][]int32)
 	for i := 0; i < partitionCount; i++ {
 		topic, err := pd.getString()
@@ -17,6 +17,10 @@ var (
 		0x00, 0x0D, 't', 'o', 'p', 'i', 'c', 'T', 'h', 'e', 'F', 'i', 'r', 's', 't',
 		0x00, 0x00, 0x00, 0x01,
 		0x4F, 0x4F, 0x4F, 0x4F}
+
+	offsetFetchRequestAllPartitions = []byte{
+		0x00, 0x04, 'b', 'l', 'a', 'h',
+		0xFF, 0xFF, 0xFF, 0xFF}
 )
 
 func TestOffsetFetchRequest(t *testing.T) {
@@ -28,4 +32,7 @@ func TestOffsetFetchRequest(t *testing.T) {
 
 	request.AddPartition("topicTheFirst", 0x4F4F4F4F)
 	testRequest(t, "one partition", request, offsetFetchRequestOnePartition)
+
+	request = &OffsetFetchRequest{ConsumerGroup: "blah", Version: 2}
+	testRequest(t, "all partitions", request, offsetFetchRequestAllPartitions)
 }
@@ -41,6 +41,15 @@ func (r *OffsetFetchResponseBlock) encode(pe packetEncoder) (err error) {
 
 type OffsetFetchResponse struct {
 	Blocks map[string]map[int32]*OffsetFetchResponseBlock
+	// Version must be set to the version of the request before decoding.
+	Version int16
+	// Err, from version 2, is the error of the request as a whole, such as
+	// ErrNotCoordinatorForConsumer.
+	Err KError
+}
+
+func (r *OffsetFetchResponse) setVersion(version int16) {
+	r.Version = version
 }
 
 func (r *OffsetFetchResponse) encode(pe packetEncoder) error {
@@ -61,14 +70,20 @@ func (r *OffsetFetchResponse) encode(pe packetEncoder) error {
 			}
 		}
 	}
+	if r.Version >= 2 {
+		pe.putInt16(int16(r.Err))
+	}
 	return nil
 }
 
 func (r *OffsetFetchResponse) decode(pd packetDecoder) (err error) {
 	numTopics, err := pd.getArrayLength()
-	if err != nil || numTopics == 0 {
+	if err != nil {
 		return err
 	}
+	if numTopics == 0 {
+		return r.decodeErr(pd)
+	}
 
 	r.Blocks = make(map[string]map[int32]*OffsetFetchResponseBlock, numTopics)
 	for i := 0; i < numTopics; i++ {
@@ -103,6 +118,18 @@ func (r *OffsetFetchResponse) decode(pd packetDecoder) (err error) {
 		}
 	}
 
+	return r.decodeErr(pd)
+}
+
+func (r *OffsetFetchResponse) decodeErr(pd packetDecoder) error {
+	if r.Version < 2 {
+		return nil
+	}
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
 	return nil
 }
 
@@ -5,6 +5,10 @@ import "testing"
 var (
 	emptyOffsetFetchResponse = []byte{
 		0x00, 0x00, 0x00, 0x00}
+
+	offsetFetchResponseV2Error = []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x10}
 )
 
 func TestEmptyOffsetFetchResponse(t *testing.T) {
@@ -20,3 +24,18 @@ func TestNormalOffsetFetchResponse(t *testing.T) {
 	// unpredictable map traversal order.
 	testResponse(t, "normal", &response, nil)
 }
+
+func TestOffsetFetchResponseV2Error(t *testing.T) {
+	response := OffsetFetchResponse{Version: 2}
+	if err := decode(offsetFetchResponseV2Error, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Err != ErrNotCoordinatorForConsumer {
+		t.Error("Expected the error of the request, got", response.Err)
+	}
+
+	response.Version = 1
+	if err := decode(offsetFetchResponseV2Error, &response); err == nil {
+		t.Error("Expected the error to be refused before version 2")
+	}
+}
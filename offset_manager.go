@@ -14,6 +14,13 @@ type OffsetManager interface {
 	// topic/partition.
 	ManagePartition(topic string, partition int32) (PartitionOffsetManager, error)
 
+	// Commit commits the offsets marked on all the partitions it manages without
+	// waiting for the next Consumer.Offsets.CommitInterval, which is the only way
+	// they are committed before the partition offset managers are closed when
+	// Consumer.Offsets.AutoCommit is disabled. Offsets are committed in the
+	// background, and failures are reported as for automatic commits.
+	Commit()
+
 	// Close stops the OffsetManager from managing offsets. It is required to call
 	// this function before an OffsetManager object passes out of scope, as it
 	// will otherwise leak memory. You must call this after all the
@@ -29,6 +36,10 @@ type offsetManager struct {
 	lock sync.Mutex
 	poms map[string]map[int32]*partitionOffsetManager
 	boms map[*Broker]*brokerOffsetManager
+
+	// the membership offsets are committed under, set by a consumer group
+	memberID   string
+	generation int32
 }
 
 // NewOffsetManagerFromClient creates a new OffsetManager from the given client.
@@ -39,15 +50,18 @@ func NewOffsetManagerFromClient(group string, client Client) (OffsetManager, err
 		return nil, ErrClosedClient
 	}
 
-	om := &offsetManager{
-		client: client,
-		conf:   client.Config(),
-		group:  group,
-		poms:   make(map[string]map[int32]*partitionOffsetManager),
-		boms:   make(map[*Broker]*brokerOffsetManager),
-	}
+	return newOffsetManagerFromClient(group, client), nil
+}
 
-	return om, nil
+func newOffsetManagerFromClient(group string, client Client) *offsetManager {
+	return &offsetManager{
+		client:     client,
+		conf:       client.Config(),
+		group:      group,
+		poms:       make(map[string]map[int32]*partitionOffsetManager),
+		boms:       make(map[*Broker]*brokerOffsetManager),
+		generation: GroupGenerationUndefined,
+	}
 }
 
 func (om *offsetManager) ManagePartition(topic string, partition int32) (PartitionOffsetManager, error) {
@@ -73,10 +87,51 @@ func (om *offsetManager) ManagePartition(topic string, partition int32) (Partiti
 	return pom, nil
 }
 
+func (om *offsetManager) Commit() {
+	om.lock.Lock()
+	defer om.lock.Unlock()
+
+	for _, partitions := range om.poms {
+		for _, pom := range partitions {
+			pom.requestCommit()
+		}
+	}
+	om.nudge()
+}
+
+// nudge makes the broker offset managers commit the offsets requested now; the
+// caller must hold the lock. One that misses it commits them on its next tick.
+func (om *offsetManager) nudge() {
+	for _, bom := range om.boms {
+		select {
+		case bom.commit <- none{}:
+		default:
+		}
+	}
+}
+
 func (om *offsetManager) Close() error {
 	return nil
 }
 
+// setGeneration sets the member of the group and the generation of the group
+// offsets are committed as, so that the coordinator only accepts them from the
+// current members.
+func (om *offsetManager) setGeneration(memberID string, generation int32) {
+	om.lock.Lock()
+	defer om.lock.Unlock()
+
+	om.memberID = memberID
+	om.generation = generation
+}
+
+func (om *offsetManager) membership() (string, int32) {
+	om.lock.Lock()
+	defer om.lock.Unlock()
+
+	return om.memberID, om.generation
+}
+
 func (om *offsetManager) refBrokerOffsetManager(broker *Broker) *brokerOffsetManager {
 	om.lock.Lock()
 	defer om.lock.Unlock()
@@ -147,6 +202,11 @@ type PartitionOffsetManager interface {
 	// message twice, and your processing should ideally be idempotent.
 	MarkOffset(offset int64, metadata string)
 
+	// ResetOffset marks the provided offset and metadata like MarkOffset, but
+	// also when the offset is lower than the one marked last, so that the
+	// partition is consumed again from after it, by this or another consumer.
+	ResetOffset(offset int64, metadata string)
+
 	// Errors returns a read channel of errors that occur during offset management, if
 	// enabled. By default, errors are logged and not returned over this channel. If
 	// you want to implement any custom error handling, set your config's
@@ -176,6 +236,7 @@ type partitionOffsetManager struct {
 	offset   int64
 	metadata string
 	dirty    bool
+	commit   bool // whether to commit the offset even without AutoCommit
 	clean    chan none
 	broker   *brokerOffsetManager
 
@@ -306,7 +367,7 @@ func (pom *partitionOffsetManager) handleError(err error) {
 	if pom.parent.conf.Consumer.Return.Errors {
 		pom.errors <- cErr
 	} else {
-		Logger.Println(cErr)
+		LogGroup.error("offset management error", "topic", cErr.Topic, "partition", cErr.Partition, "err", cErr.Err)
 	}
 }
 
@@ -325,12 +386,33 @@ func (pom *partitionOffsetManager) MarkOffset(offset int64, metadata string) {
 	}
 }
 
+func (pom *partitionOffsetManager) ResetOffset(offset int64, metadata string) {
+	pom.lock.Lock()
+	defer pom.lock.Unlock()
+
+	if offset != pom.offset || metadata != pom.metadata {
+		pom.offset = offset
+		pom.metadata = metadata
+		pom.dirty = true
+	}
+}
+
+// requestCommit makes the marked offset, if not committed yet, be committed
+// even without Consumer.Offsets.AutoCommit.
+func (pom *partitionOffsetManager) requestCommit() {
+	pom.lock.Lock()
+	defer pom.lock.Unlock()
+
+	pom.commit = pom.dirty
+}
+
 func (pom *partitionOffsetManager) updateCommitted(offset int64, metadata string) {
 	pom.lock.Lock()
 	defer pom.lock.Unlock()
 
 	if pom.offset == offset && pom.metadata == metadata {
 		pom.dirty = false
+		pom.commit = false
 
 		select {
 		case pom.clean <- none{}:
@@ -352,11 +434,15 @@ func (pom *partitionOffsetManager) NextOffset() (int64, string) {
 
 func (pom *partitionOffsetManager) AsyncClose() {
 	go func() {
+		pom.requestCommit()
 		pom.lock.Lock()
 		dirty := pom.dirty
 		pom.lock.Unlock()
 
 		if dirty {
+			pom.parent.lock.Lock()
+			pom.parent.nudge()
+			pom.parent.lock.Unlock()
 			<-pom.clean
 		}
 
@@ -386,6 +472,7 @@ type brokerOffsetManager struct {
 	timer               *time.Ticker
 	updateSubscriptions chan *partitionOffsetManager
 	subscriptions       map[*partitionOffsetManager]none
+	commit              chan none
 	refs                int
 }
 
@@ -396,6 +483,7 @@ func (om *offsetManager) newBrokerOffsetManager(broker *Broker) *brokerOffsetMan
 		timer:               time.NewTicker(om.conf.Consumer.Offsets.CommitInterval),
 		updateSubscriptions: make(chan *partitionOffsetManager),
 		subscriptions:       make(map[*partitionOffsetManager]none),
+		commit:              make(chan none, 1),
 	}
 
 	go withRecover(bom.mainLoop)
@@ -410,6 +498,10 @@ func (bom *brokerOffsetManager) mainLoop() {
 			if len(bom.subscriptions) > 0 {
 				bom.flushToBroker()
 			}
+		case <-bom.commit:
+			if len(bom.subscriptions) > 0 {
+				bom.flushToBroker()
+			}
 		case s, ok := <-bom.updateSubscriptions:
 			if !ok {
 				bom.timer.Stop()
@@ -466,6 +558,12 @@ func (bom *brokerOffsetManager) flushToBroker() {
 		case ErrUnknownTopicOrPartition, ErrNotLeaderForPartition, ErrLeaderNotAvailable:
 			delete(bom.subscriptions, s)
 			s.rebalance <- none{}
+		case ErrIllegalGeneration, ErrUnknownMemberId, ErrRebalanceInProgress:
+			// the offset was marked by a member or in a generation of the group
+			// that is no longer current, so retrying will never commit it
+			s.handleError(err)
+			block := request.blocks[s.topic][s.partition]
+			s.updateCommitted(block.offset, block.metadata)
 		default:
 			s.handleError(err)
 			delete(bom.subscriptions, s)
@@ -475,15 +573,22 @@ func (bom *brokerOffsetManager) flushToBroker() {
 }
 
 func (bom *brokerOffsetManager) constructRequest() *OffsetCommitRequest {
+	memberID, generation := bom.parent.membership()
 	r := &OffsetCommitRequest{
 		Version:                 1,
 		ConsumerGroup:           bom.parent.group,
-		ConsumerGroupGeneration: GroupGenerationUndefined,
+		ConsumerID:              memberID,
+		ConsumerGroupGeneration: generation,
+	}
+	if retention := bom.parent.conf.Consumer.Offsets.Retention; retention > 0 {
+		r.Version = 2
+		r.RetentionTime = int64(retention / time.Millisecond)
 	}
 
+	autoCommit := bom.parent.conf.Consumer.Offsets.AutoCommit
 	for s := range bom.subscriptions {
 		s.lock.Lock()
-		if s.dirty {
+		if s.dirty && (autoCommit || s.commit) {
 			r.AddBlock(s.topic, s.partition, s.offset, ReceiveTime, s.metadata)
 		}
 		s.lock.Unlock()
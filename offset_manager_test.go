@@ -6,14 +6,19 @@ import (
 )
 
 func initOffsetManager(t *testing.T) (om OffsetManager,
-	testClient Client, broker, coordinator *mockBroker) {
+	testClient Client, broker, coordinator *MockBroker) {
 
 	config := NewConfig()
 	config.Metadata.Retry.Max = 1
 	config.Consumer.Offsets.CommitInterval = 1 * time.Millisecond
+	return initOffsetManagerWithConfig(t, config)
+}
+
+func initOffsetManagerWithConfig(t *testing.T, config *Config) (om OffsetManager,
+	testClient Client, broker, coordinator *MockBroker) {
 
-	broker = newMockBroker(t, 1)
-	coordinator = newMockBroker(t, 2)
+	broker = NewMockBroker(t, 1)
+	coordinator = NewMockBroker(t, 2)
 
 	seedMeta := new(MetadataResponse)
 	seedMeta.AddBroker(coordinator.Addr(), coordinator.BrokerID())
@@ -42,7 +47,7 @@ func initOffsetManager(t *testing.T) (om OffsetManager,
 }
 
 func initPartitionOffsetManager(t *testing.T, om OffsetManager,
-	coordinator *mockBroker, initialOffset int64, metadata string) PartitionOffsetManager {
+	coordinator *MockBroker, initialOffset int64, metadata string) PartitionOffsetManager {
 
 	fetchResponse := new(OffsetFetchResponse)
 	fetchResponse.AddBlock("my_topic", 0, &OffsetFetchResponseBlock{
@@ -61,7 +66,7 @@ func initPartitionOffsetManager(t *testing.T, om OffsetManager,
 }
 
 func TestNewOffsetManager(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
+	seedBroker := NewMockBroker(t, 1)
 	seedBroker.Returns(new(MetadataResponse))
 
 	testClient, err := NewClient([]string{seedBroker.Addr()}, nil)
@@ -101,7 +106,7 @@ func TestOffsetManagerFetchInitialFail(t *testing.T) {
 	coordinator.Returns(fetchResponse)
 
 	// Refresh coordinator
-	newCoordinator := newMockBroker(t, 3)
+	newCoordinator := NewMockBroker(t, 3)
 	broker.Returns(&ConsumerMetadataResponse{
 		CoordinatorID:   newCoordinator.BrokerID(),
 		CoordinatorHost: "127.0.0.1",
@@ -238,7 +243,7 @@ func TestPartitionOffsetManagerCommitErr(t *testing.T) {
 	ocResponse.AddError("my_topic", 1, ErrNoError)
 	coordinator.Returns(ocResponse)
 
-	newCoordinator := newMockBroker(t, 3)
+	newCoordinator := NewMockBroker(t, 3)
 
 	// For RefreshCoordinator()
 	broker.Returns(&ConsumerMetadataResponse{
@@ -311,7 +316,7 @@ func TestAbortPartitionOffsetManager(t *testing.T) {
 	coordinator.Close()
 
 	// Response to refresh coordinator request
-	newCoordinator := newMockBroker(t, 3)
+	newCoordinator := NewMockBroker(t, 3)
 	broker.Returns(&ConsumerMetadataResponse{
 		CoordinatorID:   newCoordinator.BrokerID(),
 		CoordinatorHost: "127.0.0.1",
@@ -329,3 +334,113 @@ func TestAbortPartitionOffsetManager(t *testing.T) {
 	broker.Close()
 	safeClose(t, testClient)
 }
+
+func TestPartitionOffsetManagerCommitsAsGroupMember(t *testing.T) {
+	om, testClient, broker, coordinator := initOffsetManager(t)
+	om.(*offsetManager).setGeneration("my_member", 7)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "meta")
+
+	// the group moved on to a later generation, so the offset can't be committed
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrIllegalGeneration)
+	coordinator.Returns(ocResponse)
+
+	pom.MarkOffset(100, "modified_meta")
+
+	if err := pom.Close(); err != nil {
+		t.Error(err)
+	}
+
+	var request *OffsetCommitRequest
+	for _, rr := range coordinator.History() {
+		if r, ok := rr.Request.(*OffsetCommitRequest); ok {
+			request = r
+		}
+	}
+	if request == nil || request.ConsumerID != "my_member" || request.ConsumerGroupGeneration != 7 {
+		t.Error("Expected the offset to be committed as generation 7 of the member, got", request)
+	}
+
+	broker.Close()
+	coordinator.Close()
+	safeClose(t, om)
+	safeClose(t, testClient)
+}
+
+func TestPartitionOffsetManagerResetOffset(t *testing.T) {
+	om, testClient, broker, coordinator := initOffsetManager(t)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "original_meta")
+
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrNoError)
+	coordinator.Returns(ocResponse)
+
+	pom.MarkOffset(100, "modified_meta")
+	pom.ResetOffset(3, "reset_meta")
+	if offset, meta := pom.NextOffset(); offset != 4 || meta != "reset_meta" {
+		t.Errorf("Expected resetting to an older offset to move back to 4, got %d %q", offset, meta)
+	}
+
+	safeClose(t, pom)
+	if request := lastOffsetCommitRequest(coordinator); request == nil || request.blocks["my_topic"][0].offset != 3 {
+		t.Error("Expected the reset offset to be committed, got", request)
+	}
+
+	safeClose(t, om)
+	safeClose(t, testClient)
+	broker.Close()
+	coordinator.Close()
+}
+
+func TestOffsetManagerCommitWithoutAutoCommit(t *testing.T) {
+	config := NewConfig()
+	config.Version = V0_9_0_0
+	config.Metadata.Retry.Max = 1
+	config.Consumer.Offsets.CommitInterval = 1 * time.Millisecond
+	config.Consumer.Offsets.AutoCommit = false
+	config.Consumer.Offsets.Retention = time.Hour
+	om, testClient, broker, coordinator := initOffsetManagerWithConfig(t, config)
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "meta")
+
+	ocResponse := new(OffsetCommitResponse)
+	ocResponse.AddError("my_topic", 0, ErrNoError)
+	coordinator.Returns(ocResponse)
+
+	pom.MarkOffset(100, "modified_meta")
+	time.Sleep(50 * time.Millisecond)
+	if request := lastOffsetCommitRequest(coordinator); request != nil {
+		t.Fatal("Expected no offsets to be committed without AutoCommit, got", request)
+	}
+
+	om.Commit()
+	var request *OffsetCommitRequest
+	for start := time.Now(); request == nil && time.Since(start) < 5*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+		request = lastOffsetCommitRequest(coordinator)
+	}
+	if request == nil {
+		t.Fatal("Timed out waiting for the offsets to be committed")
+	}
+	if request.blocks["my_topic"][0].offset != 100 {
+		t.Error("Expected offset 100 to be committed, got", request.blocks["my_topic"][0].offset)
+	}
+	if request.Version != 2 || request.RetentionTime != int64(time.Hour/time.Millisecond) {
+		t.Error("Expected the offsets to be committed with a retention of an hour, got", request.Version, request.RetentionTime)
+	}
+
+	safeClose(t, pom)
+	safeClose(t, om)
+	safeClose(t, testClient)
+	broker.Close()
+	coordinator.Close()
+}
+
+func lastOffsetCommitRequest(coordinator *MockBroker) *OffsetCommitRequest {
+	var request *OffsetCommitRequest
+	for _, rr := range coordinator.History() {
+		if r, ok := rr.Request.(*OffsetCommitRequest); ok {
+			request = r
+		}
+	}
+	return request
+}
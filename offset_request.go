@@ -5,16 +5,22 @@ type offsetRequestBlock struct {
 	maxOffsets int32
 }
 
-func (r *offsetRequestBlock) encode(pe packetEncoder) error {
+func (r *offsetRequestBlock) encode(pe packetEncoder, version int16) error {
 	pe.putInt64(int64(r.time))
-	pe.putInt32(r.maxOffsets)
+	if version == 0 {
+		pe.putInt32(r.maxOffsets)
+	}
 	return nil
 }
 
-func (r *offsetRequestBlock) decode(pd packetDecoder) (err error) {
+func (r *offsetRequestBlock) decode(pd packetDecoder, version int16) (err error) {
 	if r.time, err = pd.getInt64(); err != nil {
 		return err
 	}
+	if version >= 1 {
+		r.maxOffsets = 1 // version 1 returns a single offset
+		return nil
+	}
 	if r.maxOffsets, err = pd.getInt32(); err != nil {
 		return err
 	}
@@ -22,7 +28,12 @@ func (r *offsetRequestBlock) decode(pd packetDecoder) (err error) {
 }
 
 type OffsetRequest struct {
-	blocks map[string]map[int32]*offsetRequestBlock
+	// Version can be 0, or 1 for Kafka 0.10.1 and later, which returns the
+	// offset of the first message with a timestamp at or after the time of
+	// each block, rather than the offsets of the log segments written before
+	// it, and ignores the maximum number of offsets.
+	Version int16
+	blocks  map[string]map[int32]*offsetRequestBlock
 }
 
 func (r *OffsetRequest) encode(pe packetEncoder) error {
@@ -42,7 +53,7 @@ func (r *OffsetRequest) encode(pe packetEncoder) error {
 		}
 		for partition, block := range partitions {
 			pe.putInt32(partition)
-			if err = block.encode(pe); err != nil {
+			if err = block.encode(pe, r.Version); err != nil {
 				return err
 			}
 		}
@@ -79,7 +90,7 @@ func (r *OffsetRequest) decode(pd packetDecoder) error {
 				return err
 			}
 			block := &offsetRequestBlock{}
-			if err := block.decode(pd); err != nil {
+			if err := block.decode(pd, r.Version); err != nil {
 				return err
 			}
 			r.blocks[topic][partition] = block
@@ -93,9 +104,17 @@ func (r *OffsetRequest) key() int16 {
 }
 
 func (r *OffsetRequest) version() int16 {
+	return r.Version
+}
+
+func (r *OffsetRequest) minVersion() int16 {
 	return 0
 }
 
+func (r *OffsetRequest) setVersion(version int16) {
+	r.Version = version
+}
+
 func (r *OffsetRequest) AddBlock(topic string, partitionID int32, time int64, maxOffsets int32) {
 	if r.blocks == nil {
 		r.blocks = make(map[string]map[int32]*offsetRequestBlock)
@@ -1,30 +1,56 @@
 package sarama
 
 type OffsetResponseBlock struct {
-	Err     KError
+	Err KError
+	// Offsets holds the offsets of a version 0 response.
 	Offsets []int64
+	// Timestamp and Offset, from version 1, are the timestamp and offset of
+	// the message found, or -1 if no message has a timestamp at or after the
+	// time asked for. The timestamp is -1 for OffsetNewest and OffsetOldest.
+	Timestamp int64
+	Offset    int64
 }
 
-func (r *OffsetResponseBlock) decode(pd packetDecoder) (err error) {
+func (r *OffsetResponseBlock) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
 	}
 	r.Err = KError(tmp)
 
+	if version >= 1 {
+		if r.Timestamp, err = pd.getInt64(); err != nil {
+			return err
+		}
+		r.Offset, err = pd.getInt64()
+		return err
+	}
+
 	r.Offsets, err = pd.getInt64Array()
 
 	return err
 }
 
-func (r *OffsetResponseBlock) encode(pe packetEncoder) (err error) {
+func (r *OffsetResponseBlock) encode(pe packetEncoder, version int16) (err error) {
 	pe.putInt16(int16(r.Err))
 
+	if version >= 1 {
+		pe.putInt64(r.Timestamp)
+		pe.putInt64(r.Offset)
+		return nil
+	}
+
 	return pe.putInt64Array(r.Offsets)
 }
 
 type OffsetResponse struct {
 	Blocks map[string]map[int32]*OffsetResponseBlock
+	// Version must be set to the version of the request before decoding.
+	Version int16
+}
+
+func (r *OffsetResponse) setVersion(version int16) {
+	r.Version = version
 }
 
 func (r *OffsetResponse) decode(pd packetDecoder) (err error) {
@@ -54,7 +80,7 @@ func (r *OffsetResponse) decode(pd packetDecoder) (err error) {
 			}
 
 			block := new(OffsetResponseBlock)
-			err = block.decode(pd)
+			err = block.decode(pd, r.Version)
 			if err != nil {
 				return err
 			}
@@ -106,7 +132,7 @@ func (r *OffsetResponse) encode(pe packetEncoder) (err error) {
 		}
 		for partition, block := range partitions {
 			pe.putInt32(partition)
-			if err = block.encode(pe); err != nil {
+			if err = block.encode(pe, r.Version); err != nil {
 				return err
 			}
 		}
@@ -126,5 +152,5 @@ func (r *OffsetResponse) AddTopicPartition(topic string, partition int32, offset
 		byTopic = make(map[int32]*OffsetResponseBlock)
 		r.Blocks[topic] = byTopic
 	}
-	byTopic[partition] = &OffsetResponseBlock{Offsets: []int64{offset}}
+	byTopic[partition] = &OffsetResponseBlock{Offsets: []int64{offset}, Timestamp: -1, Offset: offset}
 }
@@ -19,6 +19,15 @@ var (
 		0x00, 0x00, 0x00, 0x02,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06}
+
+	offsetResponseV1 = []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x01, 'z',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00,
+		0x00, 0x00, 0x01, 0x5A, 0x3B, 0x1C, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05}
 )
 
 func TestEmptyOffsetResponse(t *testing.T) {
@@ -60,3 +69,17 @@ func TestNormalOffsetResponse(t *testing.T) {
 	}
 
 }
+
+func TestOffsetResponseV1(t *testing.T) {
+	response := OffsetResponse{Version: 1}
+
+	testDecodable(t, "v1", &response, offsetResponseV1)
+
+	block := response.GetBlock("z", 2)
+	if block == nil {
+		t.Fatal("Decoding did not produce a block for z/2")
+	}
+	if block.Err != ErrNoError || block.Timestamp != 0x15A3B1C0000 || block.Offset != 5 {
+		t.Error("Decoding produced error", block.Err, "timestamp", block.Timestamp, "and offset", block.Offset)
+	}
+}
@@ -0,0 +1,85 @@
+package otel
+
+import (
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var (
+	_ propagation.TextMapCarrier = ProducerMessageCarrier{}
+	_ propagation.TextMapCarrier = ConsumerMessageCarrier{}
+)
+
+// ProducerMessageCarrier is a propagation.TextMapCarrier over the headers of a
+// message to produce, which propagators inject the trace context into.
+type ProducerMessageCarrier struct {
+	Msg *sarama.ProducerMessage
+}
+
+// Get returns the value of the first header with the given key, or "".
+func (c ProducerMessageCarrier) Get(key string) string {
+	for _, header := range c.Msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces the headers with the given key with one of the value. The
+// headers are copied, as they may be shared with other messages.
+func (c ProducerMessageCarrier) Set(key, value string) {
+	headers := make([]sarama.RecordHeader, 0, len(c.Msg.Headers)+1)
+	for _, header := range c.Msg.Headers {
+		if string(header.Key) != key {
+			headers = append(headers, header)
+		}
+	}
+	c.Msg.Headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// Keys returns the keys of the headers.
+func (c ProducerMessageCarrier) Keys() []string {
+	keys := make([]string, len(c.Msg.Headers))
+	for i, header := range c.Msg.Headers {
+		keys[i] = string(header.Key)
+	}
+	return keys
+}
+
+// ConsumerMessageCarrier is a propagation.TextMapCarrier over the headers of a
+// consumed message, which propagators extract the trace context from.
+type ConsumerMessageCarrier struct {
+	Msg *sarama.ConsumerMessage
+}
+
+// Get returns the value of the first header with the given key, or "".
+func (c ConsumerMessageCarrier) Get(key string) string {
+	for _, header := range c.Msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces the headers with the given key with one of the value. The
+// headers are copied, as they may be shared with other messages.
+func (c ConsumerMessageCarrier) Set(key, value string) {
+	headers := make([]*sarama.RecordHeader, 0, len(c.Msg.Headers)+1)
+	for _, header := range c.Msg.Headers {
+		if string(header.Key) != key {
+			headers = append(headers, header)
+		}
+	}
+	c.Msg.Headers = append(headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// Keys returns the keys of the headers.
+func (c ConsumerMessageCarrier) Keys() []string {
+	keys := make([]string, len(c.Msg.Headers))
+	for i, header := range c.Msg.Headers {
+		keys[i] = string(header.Key)
+	}
+	return keys
+}
@@ -0,0 +1,174 @@
+package otel
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	messagingBroker        = attribute.Key("messaging.kafka.broker.id")
+	messagingConsumerGroup = attribute.Key("messaging.kafka.consumer.group")
+)
+
+type instrumentKind int
+
+const (
+	counterInstrument instrumentKind = iota // a meter's event count
+	gaugeInstrument                         // a gauge's or counter's value
+	meanInstrument                          // a histogram's mean over its sample
+)
+
+type instrumentInfo struct {
+	name        string
+	unit        string
+	description string
+	kind        instrumentKind
+	scale       float64 // multiplies histogram values; 0 means 1
+}
+
+// instruments maps the go-metrics names Sarama registers to OpenTelemetry
+// instruments. Metrics that aren't listed here are not exported.
+var instruments = map[string]instrumentInfo{
+	"incoming-byte-rate":                {"sarama.broker.incoming.bytes", "By", "Bytes read from brokers.", counterInstrument, 0},
+	"outgoing-byte-rate":                {"sarama.broker.outgoing.bytes", "By", "Bytes written to brokers.", counterInstrument, 0},
+	"request-rate":                      {"sarama.broker.requests", "{request}", "Requests sent to brokers.", counterInstrument, 0},
+	"response-rate":                     {"sarama.broker.responses", "{response}", "Responses received from brokers.", counterInstrument, 0},
+	"requests-in-flight":                {"sarama.broker.requests.in_flight", "{request}", "Requests sent to brokers and awaiting a response.", gaugeInstrument, 0},
+	"request-latency-in-ms":             {"sarama.broker.request.latency", "s", "Mean time from sending a request to receiving its response.", meanInstrument, 0.001},
+	"connection-attempt-rate":           {"sarama.broker.connection.attempts", "{attempt}", "Attempts to connect to brokers.", counterInstrument, 0},
+	"connection-creation-rate":          {"sarama.broker.connection.created", "{connection}", "Connections established to brokers.", counterInstrument, 0},
+	"connection-close-rate":             {"sarama.broker.connection.closed", "{connection}", "Connections to brokers that were closed.", counterInstrument, 0},
+	"connection-failure-rate":           {"sarama.broker.connection.failures", "{attempt}", "Attempts to connect to brokers that failed.", counterInstrument, 0},
+	"failed-authentication-rate":        {"sarama.broker.authentication.failures", "{attempt}", "Connections to brokers whose TLS handshake failed.", counterInstrument, 0},
+	"handshake-latency-in-ms":           {"sarama.broker.connection.latency", "s", "Mean time taken to connect to brokers, including the TLS handshake.", meanInstrument, 0.001},
+	"connection-count":                  {"sarama.broker.connections", "{connection}", "Open connections to brokers.", gaugeInstrument, 0},
+	"record-send-rate":                  {"sarama.producer.records.sent", "{record}", "Records sent by producers.", counterInstrument, 0},
+	"record-retry-rate":                 {"sarama.producer.records.retried", "{record}", "Records whose production was retried.", counterInstrument, 0},
+	"record-error-rate":                 {"sarama.producer.records.failed", "{record}", "Records that failed to be produced.", counterInstrument, 0},
+	"batch-size":                        {"sarama.producer.batch.size", "By", "Mean size of the message batches produced per partition.", meanInstrument, 0},
+	"records-per-batch":                 {"sarama.producer.batch.records", "{record}", "Mean number of records in the message batches produced per partition.", meanInstrument, 0},
+	"records-per-request":               {"sarama.producer.records.per_request", "{record}", "Mean number of records sent per produce request.", meanInstrument, 0},
+	"compression-ratio":                 {"sarama.producer.compression.ratio", "1", "Mean uncompressed size of produced batches relative to their compressed size.", meanInstrument, 0.01},
+	"produce-throttle-time-in-ms":       {"sarama.broker.produce.throttle_time", "s", "Mean time produce responses were delayed by broker quotas.", meanInstrument, 0.001},
+	"fetch-throttle-time-in-ms":         {"sarama.broker.fetch.throttle_time", "s", "Mean time fetch responses were delayed by broker quotas.", meanInstrument, 0.001},
+	"fetch-latency-in-ms":               {"sarama.consumer.fetch.latency", "s", "Mean time taken by fetch requests, including the time spent waiting for data.", meanInstrument, 0.001},
+	"consumer-lag":                      {"sarama.consumer.lag", "{message}", "Messages between the consumer's position and the partition's high water mark.", gaugeInstrument, 0},
+	"consumer-end-to-end-latency-in-ms": {"sarama.consumer.end_to_end.latency", "s", "Mean time from the timestamp of consumed messages to their consumption.", meanInstrument, 0.001},
+	"consumer-group-lag":                {"sarama.consumer_group.lag", "{message}", "Messages between the consumer group's committed offset and the partition's log-end offset.", gaugeInstrument, 0},
+}
+
+// RegisterMetrics exports the metrics Sarama records in registry (typically the
+// MetricRegistry of a Config) as OpenTelemetry instruments of the given provider,
+// or of the global MeterProvider if provider is nil. The registry is read each
+// time the provider collects. Per-broker, per-topic and per-partition metrics are
+// reported with the corresponding attributes; the aggregated go-metrics values
+// are skipped since OpenTelemetry aggregates across attributes itself.
+//
+// OpenTelemetry has no asynchronous histograms, so histograms are reported as
+// gauges of the mean of their decaying sample. Latencies are converted to
+// seconds and compression ratios to plain ratios.
+//
+// Unregister the returned registration to stop exporting.
+func RegisterMetrics(provider metric.MeterProvider, registry metrics.Registry) (metric.Registration, error) {
+	if provider == nil {
+		provider = otelapi.GetMeterProvider()
+	}
+	meter := provider.Meter(InstrumentationName)
+
+	counters := make(map[string]metric.Int64ObservableCounter)
+	gauges := make(map[string]metric.Float64ObservableGauge)
+	var observables []metric.Observable
+
+	for name, info := range instruments {
+		switch info.kind {
+		case counterInstrument:
+			counter, err := meter.Int64ObservableCounter(info.name, metric.WithUnit(info.unit), metric.WithDescription(info.description))
+			if err != nil {
+				return nil, err
+			}
+			counters[name] = counter
+			observables = append(observables, counter)
+		default:
+			gauge, err := meter.Float64ObservableGauge(info.name, metric.WithUnit(info.unit), metric.WithDescription(info.description))
+			if err != nil {
+				return nil, err
+			}
+			gauges[name] = gauge
+			observables = append(observables, gauge)
+		}
+	}
+
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		registry.Each(func(name string, value interface{}) {
+			base, attrs, ok := parseMetricName(name)
+			if !ok {
+				return
+			}
+			info, ok := instruments[base]
+			if !ok {
+				return
+			}
+			set := metric.WithAttributes(attrs...)
+
+			if counter, ok := counters[base]; ok {
+				if m, ok := value.(metrics.Meter); ok {
+					o.ObserveInt64(counter, m.Count(), set)
+				}
+				return
+			}
+
+			scale := info.scale
+			if scale == 0 {
+				scale = 1
+			}
+			gauge := gauges[base]
+			switch m := value.(type) {
+			case metrics.Gauge:
+				o.ObserveFloat64(gauge, float64(m.Value())*scale, set)
+			case metrics.Counter:
+				o.ObserveFloat64(gauge, float64(m.Count())*scale, set)
+			case metrics.Histogram:
+				if snapshot := m.Snapshot(); snapshot.Count() > 0 {
+					o.ObserveFloat64(gauge, snapshot.Mean()*scale, set)
+				}
+			}
+		})
+		return nil
+	}, observables...)
+}
+
+// parseMetricName splits a per-broker, per-topic, per-partition or per-group go-metrics name
+// into the metric's base name and attributes. Aggregated names are not split.
+func parseMetricName(name string) (string, []attribute.KeyValue, bool) {
+	if i := strings.LastIndex(name, "-for-broker-"); i >= 0 {
+		id, err := strconv.ParseInt(name[i+len("-for-broker-"):], 10, 32)
+		if err != nil {
+			return "", nil, false
+		}
+		return name[:i], []attribute.KeyValue{messagingBroker.Int64(id)}, true
+	}
+
+	if i := strings.Index(name, "-for-topic-"); i >= 0 {
+		topic := name[i+len("-for-topic-"):]
+		attrs := []attribute.KeyValue{messagingDestination.String(topic)}
+		if j := strings.LastIndex(topic, "-partition-"); j >= 0 {
+			if partition, err := strconv.ParseInt(topic[j+len("-partition-"):], 10, 32); err == nil {
+				attrs = []attribute.KeyValue{messagingDestination.String(topic[:j]), messagingPartition.Int64(partition)}
+			}
+		}
+		base := name[:i]
+		if j := strings.LastIndex(base, "-for-group-"); j >= 0 {
+			attrs = append(attrs, messagingConsumerGroup.String(base[j+len("-for-group-"):]))
+			base = base[:j]
+		}
+		return base, attrs, true
+	}
+
+	return "", nil, false
+}
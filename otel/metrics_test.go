@@ -0,0 +1,119 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func collect(t *testing.T, registry metrics.Registry) map[string]metricdata.Aggregation {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	registration, err := RegisterMetrics(provider, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := registration.Unregister(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]metricdata.Aggregation)
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			byName[m.Name] = m.Data
+		}
+	}
+	return byName
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterMeter("request-rate", registry).Mark(5)
+	metrics.GetOrRegisterMeter("request-rate-for-broker-2", registry).Mark(3)
+	metrics.GetOrRegisterGauge("consumer-lag-for-topic-my_topic-partition-1", registry).Update(12)
+	latency := metrics.GetOrRegisterHistogram("fetch-latency-in-ms-for-broker-2", registry, metrics.NewUniformSample(10))
+	latency.Update(100)
+	latency.Update(300)
+
+	data := collect(t, registry)
+
+	requests, ok := data["sarama.broker.requests"].(metricdata.Sum[int64])
+	if !ok || len(requests.DataPoints) != 1 {
+		t.Fatal("Expected a single per-broker request count, got", data["sarama.broker.requests"])
+	}
+	if point := requests.DataPoints[0]; point.Value != 3 || !requests.IsMonotonic {
+		t.Error("Expected a monotonic count of 3, got", point.Value)
+	} else if id, _ := point.Attributes.Value(messagingBroker); id.AsInt64() != 2 {
+		t.Error("Expected the broker attribute to be 2, got", id.Emit())
+	}
+
+	lag, ok := data["sarama.consumer.lag"].(metricdata.Gauge[float64])
+	if !ok || len(lag.DataPoints) != 1 || lag.DataPoints[0].Value != 12 {
+		t.Fatal("Expected a consumer lag of 12, got", data["sarama.consumer.lag"])
+	}
+	attrs := lag.DataPoints[0].Attributes
+	if topic, _ := attrs.Value(messagingDestination); topic.AsString() != "my_topic" {
+		t.Error("Expected the topic attribute to be my_topic, got", topic.Emit())
+	}
+	if partition, _ := attrs.Value(messagingPartition); partition.AsInt64() != 1 {
+		t.Error("Expected the partition attribute to be 1, got", partition.Emit())
+	}
+
+	fetchLatency, ok := data["sarama.consumer.fetch.latency"].(metricdata.Gauge[float64])
+	if !ok || len(fetchLatency.DataPoints) != 1 || fetchLatency.DataPoints[0].Value != 0.2 {
+		t.Error("Expected a mean fetch latency of 0.2 seconds, got", data["sarama.consumer.fetch.latency"])
+	}
+}
+
+func TestParseMetricName(t *testing.T) {
+	if _, _, ok := parseMetricName("request-rate"); ok {
+		t.Error("Expected aggregated metrics not to be parsed")
+	}
+
+	base, attrs, ok := parseMetricName("batch-size-for-topic-my-partition-topic")
+	if !ok || base != "batch-size" || len(attrs) != 1 || attrs[0] != messagingDestination.String("my-partition-topic") {
+		t.Error("Unexpected parse", base, attrs, ok)
+	}
+
+	base, attrs, ok = parseMetricName("consumer-lag-for-topic-t-partition-4")
+	if !ok || base != "consumer-lag" || len(attrs) != 2 || attrs[1] != messagingPartition.Int64(4) {
+		t.Error("Unexpected parse", base, attrs, ok)
+	}
+
+	base, attrs, ok = parseMetricName("consumer-group-lag-for-group-g-for-topic-t-partition-0")
+	if !ok || base != "consumer-group-lag" || len(attrs) != 3 || attrs[2] != messagingConsumerGroup.String("g") {
+		t.Error("Unexpected parse", base, attrs, ok)
+	}
+
+	if _, _, ok := parseMetricName("request-rate-for-broker-x"); ok {
+		t.Error("Expected an invalid broker ID not to be parsed")
+	}
+}
+
+func TestRegisterMetricsEndToEndLatency(t *testing.T) {
+	registry := metrics.NewRegistry()
+	latency := metrics.GetOrRegisterHistogram("consumer-end-to-end-latency-in-ms-for-topic-my_topic-partition-0", registry, metrics.NewUniformSample(10))
+	latency.Update(1000)
+	latency.Update(3000)
+
+	data := collect(t, registry)
+
+	gauge, ok := data["sarama.consumer.end_to_end.latency"].(metricdata.Gauge[float64])
+	if !ok || len(gauge.DataPoints) != 1 || gauge.DataPoints[0].Value != 2 {
+		t.Fatal("Expected a mean end-to-end latency of 2 seconds, got", data["sarama.consumer.end_to_end.latency"])
+	}
+	if partition, _ := gauge.DataPoints[0].Attributes.Value(messagingPartition); partition.AsInt64() != 0 {
+		t.Error("Expected the partition attribute to be 0, got", partition.Emit())
+	}
+}
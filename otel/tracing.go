@@ -0,0 +1,178 @@
+/*
+Package otel instruments Sarama producers and consumers with OpenTelemetry.
+RegisterMetrics exports the metrics Sarama records in Config.MetricRegistry as
+OpenTelemetry instruments, and Tracing creates spans for messages.
+
+Tracing creates a PRODUCER span for every message sent and a CONSUMER span for
+every message received, carrying the messaging attributes of the OpenTelemetry
+semantic conventions (topic, partition, offset):
+
+	tracing := otel.NewTracing(nil) // uses the global TracerProvider
+	producer := tracing.WrapSyncProducer(syncProducer)
+
+	for msg := range partitionConsumer.Messages() {
+		ctx, span := tracing.StartConsumeSpan(context.Background(), msg)
+		handle(ctx, msg)
+		span.End()
+	}
+
+The trace context travels from producer to consumer in the record headers of
+the message, so that the consume span continues the trace of the produce span.
+The propagator of NewTracing, the global TextMapPropagator, injects nothing
+until one is set with otel.SetTextMapPropagator; NewTracingWithPropagator takes
+one instead. Headers require Version >= V0_11_0_0: use a nil propagator with
+older versions. A consumed message without a trace context in its headers
+continues whatever trace the context passed in carries.
+
+NOTE: this package currently does not fall under the API stability guarantee of
+Sarama as it is still considered experimental.
+*/
+package otel
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName identifies this package as the instrumentation library to
+// OpenTelemetry.
+const InstrumentationName = "github.com/Shopify/sarama/otel"
+
+// Attribute keys from the OpenTelemetry messaging semantic conventions.
+const (
+	messagingSystem      = attribute.Key("messaging.system")
+	messagingDestination = attribute.Key("messaging.destination.name")
+	messagingOperation   = attribute.Key("messaging.operation")
+	messagingPartition   = attribute.Key("messaging.kafka.destination.partition")
+	messagingOffset      = attribute.Key("messaging.kafka.message.offset")
+	messagingKey         = attribute.Key("messaging.kafka.message.key")
+)
+
+// Tracing creates spans for produced and consumed messages.
+type Tracing struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator // nil not to propagate
+}
+
+// NewTracing returns a Tracing creating spans with the given provider, or with
+// the global TracerProvider if provider is nil, and propagating the trace
+// context with the global TextMapPropagator.
+func NewTracing(provider trace.TracerProvider) *Tracing {
+	return NewTracingWithPropagator(provider, otelapi.GetTextMapPropagator())
+}
+
+// NewTracingWithPropagator returns a Tracing as NewTracing does, but propagating
+// the trace context in the headers of messages with the given propagator, or
+// not at all if it is nil.
+func NewTracingWithPropagator(provider trace.TracerProvider, propagator propagation.TextMapPropagator) *Tracing {
+	if provider == nil {
+		provider = otelapi.GetTracerProvider()
+	}
+	return &Tracing{tracer: provider.Tracer(InstrumentationName), propagator: propagator}
+}
+
+// StartProduceSpan starts the span for sending msg. The span must be ended with
+// EndProduceSpan once the message has been acknowledged or failed; AsyncProducer
+// users typically keep it in the message's Metadata until it comes back on the
+// Successes or Errors channel. The trace context of the span is injected into
+// the headers of msg.
+func (t *Tracing) StartProduceSpan(ctx context.Context, msg *sarama.ProducerMessage) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		messagingSystem.String("kafka"),
+		messagingDestination.String(msg.Topic),
+		messagingOperation.String("publish"),
+	}
+	if key := encodedKey(msg.Key); key != "" {
+		attrs = append(attrs, messagingKey.String(key))
+	}
+
+	ctx, span := t.tracer.Start(ctx, msg.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...),
+	)
+	if t.propagator != nil {
+		t.propagator.Inject(ctx, ProducerMessageCarrier{msg})
+	}
+	return ctx, span
+}
+
+// EndProduceSpan ends a span started by StartProduceSpan, recording where the
+// message was stored or the error it failed with.
+func (t *Tracing) EndProduceSpan(span trace.Span, msg *sarama.ProducerMessage, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(
+			messagingPartition.Int64(int64(msg.Partition)),
+			messagingOffset.Int64(msg.Offset),
+		)
+	}
+	span.End()
+}
+
+// StartConsumeSpan starts the span for processing msg, as a child of the produce
+// span whose trace context the headers of msg carry, or else of any span in ctx.
+// The caller ends it once the message has been handled.
+func (t *Tracing) StartConsumeSpan(ctx context.Context, msg *sarama.ConsumerMessage) (context.Context, trace.Span) {
+	if t.propagator != nil {
+		ctx = t.propagator.Extract(ctx, ConsumerMessageCarrier{msg})
+	}
+	attrs := []attribute.KeyValue{
+		messagingSystem.String("kafka"),
+		messagingDestination.String(msg.Topic),
+		messagingOperation.String("receive"),
+		messagingPartition.Int64(int64(msg.Partition)),
+		messagingOffset.Int64(msg.Offset),
+	}
+	if msg.Key != nil {
+		attrs = append(attrs, messagingKey.String(string(msg.Key)))
+	}
+
+	return t.tracer.Start(ctx, msg.Topic+" receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// WrapSyncProducer returns a SyncProducer which traces every message it sends.
+func (t *Tracing) WrapSyncProducer(producer sarama.SyncProducer) sarama.SyncProducer {
+	return &tracedSyncProducer{SyncProducer: producer, tracing: t}
+}
+
+type tracedSyncProducer struct {
+	sarama.SyncProducer
+	tracing *Tracing
+}
+
+func (tp *tracedSyncProducer) SendMessage(msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	return tp.SendMessageContext(context.Background(), msg)
+}
+
+// SendMessageContext starts the span of the message as a child of the span ctx
+// carries, if any.
+func (tp *tracedSyncProducer) SendMessageContext(ctx context.Context, msg *sarama.ProducerMessage) (partition int32, offset int64, err error) {
+	ctx, span := tp.tracing.StartProduceSpan(ctx, msg)
+	partition, offset, err = tp.SyncProducer.SendMessageContext(ctx, msg)
+	tp.tracing.EndProduceSpan(span, msg, err)
+	return partition, offset, err
+}
+
+// encodedKey returns the key as a string for the span attributes. Only keys
+// which are plain strings or bytes are recorded: others may be expensive to
+// encode, or (like a ReaderEncoder) only encodable once.
+func encodedKey(key sarama.Encoder) string {
+	switch k := key.(type) {
+	case sarama.StringEncoder:
+		return string(k)
+	case sarama.ByteEncoder:
+		return string(k)
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracing() (*Tracing, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return NewTracing(provider), recorder
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracingSyncProducer(t *testing.T) {
+	tracing, recorder := newTestTracing()
+
+	mp := mocks.NewSyncProducer(t, nil)
+	mp.ExpectSendMessageAndSucceed()
+	mp.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	producer := tracing.WrapSyncProducer(mp)
+
+	msg := &sarama.ProducerMessage{Topic: "my_topic", Key: sarama.StringEncoder("key"), Value: sarama.StringEncoder("value")}
+	if _, _, err := producer.SendMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := producer.SendMessage(&sarama.ProducerMessage{Topic: "my_topic"}); err != sarama.ErrOutOfBrokers {
+		t.Error("Expected ErrOutOfBrokers, got", err)
+	}
+	if err := producer.Close(); err != nil {
+		t.Error(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatal("Expected 2 spans, got", len(spans))
+	}
+
+	if spans[0].Name() != "my_topic publish" || spans[0].SpanKind() != trace.SpanKindProducer {
+		t.Error("Unexpected span", spans[0].Name(), spans[0].SpanKind())
+	}
+	if offset, ok := spanAttribute(spans[0], messagingOffset); !ok || offset.AsInt64() != msg.Offset {
+		t.Error("Expected the offset to be recorded, got", offset.Emit())
+	}
+	if key, ok := spanAttribute(spans[0], messagingKey); !ok || key.AsString() != "key" {
+		t.Error("Expected the key to be recorded, got", key.Emit())
+	}
+
+	if spans[1].Status().Code != codes.Error {
+		t.Error("Expected the failed send to have an error status")
+	}
+	if _, ok := spanAttribute(spans[1], messagingOffset); ok {
+		t.Error("Expected no offset for the failed send")
+	}
+}
+
+func TestTracingSyncProducerContext(t *testing.T) {
+	tracing, recorder := newTestTracing()
+
+	mp := mocks.NewSyncProducer(t, nil)
+	mp.ExpectSendMessageAndSucceed()
+	producer := tracing.WrapSyncProducer(mp)
+
+	ctx, parent := tracing.tracer.Start(context.Background(), "request")
+	if _, _, err := producer.SendMessageContext(ctx, &sarama.ProducerMessage{Topic: "my_topic"}); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+	if err := producer.Close(); err != nil {
+		t.Error(err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatal("Expected 2 spans, got", len(spans))
+	}
+	if spans[0].Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("Expected the produce span to be a child of the span of the context")
+	}
+}
+
+func TestTracingConsumeSpan(t *testing.T) {
+	tracing, recorder := newTestTracing()
+
+	parentCtx, parent := tracing.tracer.Start(context.Background(), "handler")
+	msg := &sarama.ConsumerMessage{Topic: "my_topic", Partition: 3, Offset: 42, Value: []byte("value")}
+	ctx, span := tracing.StartConsumeSpan(parentCtx, msg)
+	span.End()
+	parent.End()
+
+	if trace.SpanFromContext(ctx) != span {
+		t.Error("Expected the returned context to carry the consume span")
+	}
+
+	consumed := recorder.Ended()[0]
+	if consumed.SpanKind() != trace.SpanKindConsumer {
+		t.Error("Expected a consumer span, got", consumed.SpanKind())
+	}
+	if consumed.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("Expected the consume span to be a child of the span in the context")
+	}
+	if partition, ok := spanAttribute(consumed, messagingPartition); !ok || partition.AsInt64() != 3 {
+		t.Error("Expected the partition to be recorded, got", partition.Emit())
+	}
+	if _, ok := spanAttribute(consumed, messagingKey); ok {
+		t.Error("Expected no key attribute for a null key")
+	}
+}
+
+func TestTracingPropagatesTheTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracing := NewTracingWithPropagator(provider, propagation.TraceContext{})
+
+	shared := []sarama.RecordHeader{{Key: []byte("app"), Value: []byte("value")}}
+	produced := &sarama.ProducerMessage{Topic: "my_topic", Headers: shared}
+	_, produceSpan := tracing.StartProduceSpan(context.Background(), produced)
+	tracing.EndProduceSpan(produceSpan, produced, nil)
+	if len(shared) != 1 || len(produced.Headers) != 2 {
+		t.Fatal("Expected the trace context to be added to a copy of the headers, got", produced.Headers)
+	}
+
+	// the consumed message carries the headers the message was produced with
+	consumed := &sarama.ConsumerMessage{Topic: "my_topic"}
+	for i := range produced.Headers {
+		consumed.Headers = append(consumed.Headers, &produced.Headers[i])
+	}
+	_, unrelated := tracing.tracer.Start(context.Background(), "handler")
+	ctx, consumeSpan := tracing.StartConsumeSpan(trace.ContextWithSpan(context.Background(), unrelated), consumed)
+	consumeSpan.End()
+	unrelated.End()
+
+	consumedContext := trace.SpanContextFromContext(ctx)
+	if consumedContext.TraceID() != produceSpan.SpanContext().TraceID() {
+		t.Error("Expected the consume span to continue the trace of the produce span")
+	}
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindConsumer && span.Parent().SpanID() != produceSpan.SpanContext().SpanID() {
+			t.Error("Expected the consume span to be a child of the produce span, got", span.Parent().SpanID())
+		}
+	}
+
+	// a message produced again carries a single trace context
+	_, again := tracing.StartProduceSpan(context.Background(), produced)
+	again.End()
+	if len(produced.Headers) != 2 || (ProducerMessageCarrier{produced}).Get("traceparent") == "" {
+		t.Error("Expected the trace context header to be replaced, got", produced.Headers)
+	}
+}
+
+func TestTracingWithoutPropagatorLeavesHeaders(t *testing.T) {
+	tracing := NewTracingWithPropagator(sdktrace.NewTracerProvider(), nil)
+	msg := &sarama.ProducerMessage{Topic: "my_topic"}
+	_, span := tracing.StartProduceSpan(context.Background(), msg)
+	span.End()
+	if len(msg.Headers) != 0 {
+		t.Error("Expected no headers without a propagator, got", msg.Headers)
+	}
+}
@@ -1,5 +1,7 @@
 package sarama
 
+import "math"
+
 // PacketDecoder is the interface providing helpers for reading with Kafka's encoding rules.
 // Types implementing Decoder only need to worry about calling methods like GetString,
 // not about how a string is represented in Kafka.
@@ -13,6 +15,7 @@ type packetDecoder interface {
 
 	// Collections
 	getBytes() ([]byte, error)
+	getRawBytes(length int) ([]byte, error)
 	getString() (string, error)
 	getInt32Array() ([]int32, error)
 	getInt64Array() ([]int64, error)
@@ -20,6 +23,7 @@ type packetDecoder interface {
 
 	// Subsets
 	remaining() int
+	peekInt8(offset int) (int8, error)
 	getSubset(length int) (packetDecoder, error)
 
 	// Stacks, see PushDecoder
@@ -43,3 +47,46 @@ type pushDecoder interface {
 	// of data from the saved offset, and verify it based on the data between the saved offset and curOffset.
 	check(curOffset int, buf []byte) error
 }
+
+// getNullableString reads a string which may be null, unlike getString, which
+// decodes null as the empty string.
+func getNullableString(pd packetDecoder) (*string, error) {
+	n, err := pd.getInt16()
+	if err != nil || n == -1 {
+		return nil, err
+	}
+	if n < -1 {
+		return nil, PacketDecodingError{"invalid string length"}
+	}
+	raw, err := pd.getRawBytes(int(n))
+	if err != nil {
+		return nil, err
+	}
+	str := string(raw)
+	return &str, nil
+}
+
+// getVarint reads a zigzag-encoded signed varint.
+func getVarint(pd packetDecoder) (int64, error) {
+	x, err := getUVarint(pd)
+	if err != nil {
+		return 0, err
+	}
+	return int64(x>>1) ^ -int64(x&1), nil
+}
+
+// getVarintBytes reads bytes prefixed with their varint length, returning nil
+// for a length of -1.
+func getVarintBytes(pd packetDecoder) ([]byte, error) {
+	n, err := getVarint(pd)
+	if err != nil {
+		return nil, err
+	}
+	if n == -1 {
+		return nil, nil
+	}
+	if n < -1 || n > math.MaxInt32 {
+		return nil, PacketDecodingError{"invalid byteslice length"}
+	}
+	return pd.getRawBytes(int(n))
+}
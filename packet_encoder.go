@@ -9,6 +9,7 @@ type packetEncoder interface {
 	putInt16(in int16)
 	putInt32(in int32)
 	putInt64(in int64)
+	putUVarint(in uint64)
 	putArrayLength(in int) error
 
 	// Collections
@@ -40,3 +41,52 @@ type pushEncoder interface {
 	// of data to the saved offset, based on the data between the saved offset and curOffset.
 	run(curOffset int, buf []byte) error
 }
+
+// putNullableString writes a string which may be null, as a length of -1.
+func putNullableString(pe packetEncoder, in *string) error {
+	if in == nil {
+		pe.putInt16(-1)
+		return nil
+	}
+	return pe.putString(*in)
+}
+
+// putVarint writes a zigzag-encoded signed varint, as the records of record
+// batches use for their fields.
+func putVarint(pe packetEncoder, in int64) error {
+	pe.putUVarint(uint64(in<<1) ^ uint64(in>>63))
+	return nil
+}
+
+// putVarintBytes writes bytes prefixed with their varint length, or -1 for nil.
+func putVarintBytes(pe packetEncoder, in []byte) error {
+	if in == nil {
+		return putVarint(pe, -1)
+	}
+	if err := putVarint(pe, int64(len(in))); err != nil {
+		return err
+	}
+	return pe.putRawBytes(in)
+}
+
+// varintSize returns the number of bytes putVarint writes for in.
+func varintSize(in int64) int {
+	return uvarintSize(uint64(in<<1) ^ uint64(in>>63))
+}
+
+// uvarintSize returns the number of bytes an unsigned varint takes.
+func uvarintSize(in uint64) int {
+	n := 1
+	for ; in >= 0x80; in >>= 7 {
+		n++
+	}
+	return n
+}
+
+// varintBytesSize returns the number of bytes putVarintBytes writes for in.
+func varintBytesSize(in []byte) int {
+	if in == nil {
+		return varintSize(-1)
+	}
+	return varintSize(int64(len(in))) + len(in)
+}
@@ -4,11 +4,12 @@ import (
 	"hash"
 	"hash/fnv"
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
 // Partitioner is anything that, given a Kafka message and a number of partitions indexed [0...numPartitions-1],
-// decides to which partition to send the message. RandomPartitioner, RoundRobinPartitioner and HashPartitioner are provided
+// decides to which partition to send the message. RandomPartitioner, RoundRobinPartitioner, StickyPartitioner and HashPartitioner are provided
 // as simple default implementations.
 type Partitioner interface {
 	// Partition takes a message and partition count and chooses a partition
@@ -25,10 +26,27 @@ type Partitioner interface {
 // PartitionerConstructor is the type for a function capable of constructing new Partitioners.
 type PartitionerConstructor func(topic string) Partitioner
 
+// BatchAwarePartitioner is a Partitioner that the producer tells when it flushes
+// a batch of messages of the partitioner's topic, so that it can take batches
+// into account in choosing partitions, as the sticky partitioner does.
+type BatchAwarePartitioner interface {
+	Partitioner
+
+	// Flushed is called when the producer flushes a batch of messages to the
+	// given partition (by ID, not by index of the partitions chosen from). It
+	// is called from the producer's broker goroutines, concurrently with
+	// Partition.
+	Flushed(partition int32)
+}
+
 type manualPartitioner struct{}
 
+// manualPartitioning partitions the messages with ManualPartition set.
+var manualPartitioning Partitioner = new(manualPartitioner)
+
 // NewManualPartitioner returns a Partitioner which uses the partition manually set in the provided
-// ProducerMessage's Partition field as the partition to produce to.
+// ProducerMessage's Partition field as the partition to produce to. To partition only some messages
+// manually, set ManualPartition on them instead.
 func NewManualPartitioner(topic string) Partitioner {
 	return new(manualPartitioner)
 }
@@ -47,9 +65,21 @@ type randomPartitioner struct {
 
 // NewRandomPartitioner returns a Partitioner which chooses a random partition each time.
 func NewRandomPartitioner(topic string) Partitioner {
-	p := new(randomPartitioner)
-	p.generator = rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
-	return p
+	return newRandomPartitioner(rand.NewSource(time.Now().UTC().UnixNano()))
+}
+
+// NewRandomPartitionerWithSource returns a PartitionerConstructor of Partitioners
+// which choose a random partition each time, from the source returned by source
+// for their topic. With sources of a fixed seed, tests can assert which partitions
+// messages are produced to.
+func NewRandomPartitionerWithSource(source func(topic string) rand.Source) PartitionerConstructor {
+	return func(topic string) Partitioner {
+		return newRandomPartitioner(source(topic))
+	}
+}
+
+func newRandomPartitioner(source rand.Source) *randomPartitioner {
+	return &randomPartitioner{generator: rand.New(source)}
 }
 
 func (p *randomPartitioner) Partition(message *ProducerMessage, numPartitions int32) (int32, error) {
@@ -82,13 +112,45 @@ func (p *roundRobinPartitioner) RequiresConsistency() bool {
 	return false
 }
 
+type stickyPartitioner struct {
+	partition int32
+	flushed   int32 // set to 1 by Flushed, read and cleared by Partition
+}
+
+// NewStickyPartitioner returns a Partitioner which cycles through the available partitions in order, as
+// the RoundRobinPartitioner does, but sends every message to the same partition until the producer flushes
+// a batch of messages of the topic, and only then moves on to the next partition. Keyless messages are
+// thereby sent in fuller batches than when they are spread over all the partitions, without giving up on
+// balancing them over time. Like the RoundRobinPartitioner, it ignores the keys of messages.
+func NewStickyPartitioner(topic string) Partitioner {
+	return new(stickyPartitioner)
+}
+
+func (p *stickyPartitioner) Partition(message *ProducerMessage, numPartitions int32) (int32, error) {
+	if atomic.CompareAndSwapInt32(&p.flushed, 1, 0) {
+		p.partition++
+	}
+	if p.partition >= numPartitions {
+		p.partition = 0
+	}
+	return p.partition, nil
+}
+
+func (p *stickyPartitioner) RequiresConsistency() bool {
+	return false
+}
+
+func (p *stickyPartitioner) Flushed(partition int32) {
+	atomic.StoreInt32(&p.flushed, 1)
+}
+
 type hashPartitioner struct {
 	random Partitioner
 	hasher hash.Hash32
 }
 
-// NewHashPartitioner returns a Partitioner which behaves as follows. If the message's key is nil, or fails to
-// encode, then a random partition is chosen. Otherwise the FNV-1a hash of the encoded bytes of the message key
+// NewHashPartitioner returns a Partitioner which behaves as follows. If the message's key is nil, or encodes
+// to nil (a null key), then a random partition is chosen. Otherwise the FNV-1a hash of the encoded bytes of the message key
 // is used, modulus the number of partitions. This ensures that messages with the same key always end up on the
 // same partition.
 func NewHashPartitioner(topic string) Partitioner {
@@ -98,6 +160,18 @@ func NewHashPartitioner(topic string) Partitioner {
 	return p
 }
 
+// NewHashPartitionerWithSource is like NewHashPartitioner, but messages with a null
+// key go to a partition chosen from the source returned by source for the topic,
+// as with NewRandomPartitionerWithSource.
+func NewHashPartitionerWithSource(source func(topic string) rand.Source) PartitionerConstructor {
+	return func(topic string) Partitioner {
+		p := new(hashPartitioner)
+		p.random = newRandomPartitioner(source(topic))
+		p.hasher = fnv.New32a()
+		return p
+	}
+}
+
 func (p *hashPartitioner) Partition(message *ProducerMessage, numPartitions int32) (int32, error) {
 	if message.Key == nil {
 		return p.random.Partition(message, numPartitions)
@@ -106,6 +180,9 @@ func (p *hashPartitioner) Partition(message *ProducerMessage, numPartitions int3
 	if err != nil {
 		return -1, err
 	}
+	if bytes == nil {
+		return p.random.Partition(message, numPartitions)
+	}
 	p.hasher.Reset()
 	_, err = p.hasher.Write(bytes)
 	if err != nil {
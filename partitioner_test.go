@@ -3,6 +3,7 @@ package sarama
 import (
 	"crypto/rand"
 	"log"
+	mathrand "math/rand"
 	"testing"
 )
 
@@ -47,6 +48,22 @@ func TestRandomPartitioner(t *testing.T) {
 	}
 }
 
+func TestRandomPartitionerWithSource(t *testing.T) {
+	seeded := func(topic string) mathrand.Source { return mathrand.NewSource(int64(len(topic))) }
+	first := NewRandomPartitionerWithSource(seeded)("mytopic")
+	second := NewRandomPartitionerWithSource(seeded)("mytopic")
+
+	for i := 1; i < 50; i++ {
+		choice, err := first.Partition(nil, 50)
+		if err != nil {
+			t.Error(first, err)
+		}
+		if again, _ := second.Partition(nil, 50); again != choice {
+			t.Error("Returned partition", again, "rather than", choice, "from the same seed.")
+		}
+	}
+}
+
 func TestRoundRobinPartitioner(t *testing.T) {
 	partitioner := NewRoundRobinPartitioner("mytopic")
 
@@ -70,6 +87,23 @@ func TestRoundRobinPartitioner(t *testing.T) {
 	}
 }
 
+func TestStickyPartitioner(t *testing.T) {
+	partitioner := NewStickyPartitioner("mytopic").(BatchAwarePartitioner)
+
+	for flush := int32(0); flush < 10; flush++ {
+		for i := 0; i < 3; i++ {
+			choice, err := partitioner.Partition(nil, 4)
+			if err != nil {
+				t.Error(partitioner, err)
+			}
+			if choice != flush%4 {
+				t.Error("Returned partition", choice, "expecting", flush%4)
+			}
+		}
+		partitioner.Flushed(flush % 4)
+	}
+}
+
 func TestHashPartitioner(t *testing.T) {
 	partitioner := NewHashPartitioner("mytopic")
 
@@ -196,3 +230,34 @@ func ExamplePartitioner_per_topic() {
 
 	// ...
 }
+
+func TestHashPartitionerNullKey(t *testing.T) {
+	partitioner := NewHashPartitioner("mytopic")
+
+	for i := 1; i < 50; i++ {
+		choice, err := partitioner.Partition(&ProducerMessage{Key: ByteEncoder(nil)}, 50)
+		if err != nil {
+			t.Error(partitioner, err)
+		}
+		if choice < 0 || choice >= 50 {
+			t.Error("Returned partition", choice, "outside of range for null key.")
+		}
+	}
+}
+
+func TestHashPartitionerWithSourceNullKey(t *testing.T) {
+	seeded := func(topic string) mathrand.Source { return mathrand.NewSource(1) }
+	first := NewHashPartitionerWithSource(seeded)("mytopic")
+	second := NewHashPartitionerWithSource(seeded)("mytopic")
+
+	for i := 1; i < 50; i++ {
+		choice, err := first.Partition(&ProducerMessage{Key: ByteEncoder(nil)}, 50)
+		if err != nil {
+			t.Error(first, err)
+		}
+		if again, _ := second.Partition(&ProducerMessage{Key: ByteEncoder(nil)}, 50); again != choice {
+			t.Error("Returned partition", again, "rather than", choice, "from the same seed for null key.")
+		}
+	}
+	assertPartitioningConsistent(t, first, &ProducerMessage{Key: StringEncoder("key")}, 50)
+}
@@ -0,0 +1,94 @@
+package sarama
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+
+	"github.com/pierrec/lz4"
+)
+
+// Compression codecs carry a surprising amount of internal state (the deflate
+// window alone is several hundred kilobytes), so writers and readers are kept in
+// pools and reset between uses instead of being allocated per message set. The
+// same goes for the scratch buffers they write into; results are copied out at
+// their exact size so that pooled memory is never handed to the caller, since
+// decoded messages alias the slice they were decoded from.
+
+// buffers larger than this are left to the garbage collector rather than pooled
+const maxPooledBufferSize = 4 * 1024 * 1024
+
+var (
+	scratchPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+	gzipWriterPool = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	gzipReaderPool sync.Pool
+
+	lz4WriterPool = sync.Pool{New: func() interface{} { return lz4.NewWriter(nil) }}
+	lz4ReaderPool = sync.Pool{New: func() interface{} { return lz4.NewReader(nil) }}
+
+	encodeBufferPool = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+	lengthFieldPool = sync.Pool{New: func() interface{} { return new(lengthField) }}
+	crc32FieldPool  = sync.Pool{New: func() interface{} { return new(crc32Field) }}
+)
+
+func getScratch() *bytes.Buffer {
+	buf := scratchPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putScratch(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBufferSize {
+		scratchPool.Put(buf)
+	}
+}
+
+// copyScratch returns an exactly-sized copy of the contents of buf and returns
+// buf to the pool.
+func copyScratch(buf *bytes.Buffer) []byte {
+	out := make([]byte, buf.Len())
+	copy(out, buf.Bytes())
+	putScratch(buf)
+	return out
+}
+
+// getEncodeBuffer returns a pooled buffer to encode into with encodeInto, for
+// packets that are only needed until they are compressed.
+func getEncodeBuffer() *[]byte {
+	return encodeBufferPool.Get().(*[]byte)
+}
+
+// putEncodeBuffer returns buf to the pool, keeping the larger of the slice it
+// holds and raw, which is what encodeInto returned for it.
+func putEncodeBuffer(buf *[]byte, raw []byte) {
+	if cap(raw) > cap(*buf) {
+		*buf = raw
+	}
+	if cap(*buf) <= maxPooledBufferSize {
+		encodeBufferPool.Put(buf)
+	}
+}
+
+// The length and CRC fields of each message are pushed for every message encoded
+// or decoded, so they are pooled too. They are only released once popped, as a
+// decoder which fails part way through a message keeps them on its stack.
+
+func acquireLengthField() *lengthField {
+	return lengthFieldPool.Get().(*lengthField)
+}
+
+func releaseLengthField(l *lengthField) {
+	lengthFieldPool.Put(l)
+}
+
+func acquireCRC32Field(polynomial crcPolynomial) *crc32Field {
+	c := crc32FieldPool.Get().(*crc32Field)
+	c.polynomial = polynomial
+	return c
+}
+
+func releaseCRC32Field(c *crc32Field) {
+	crc32FieldPool.Put(c)
+}
@@ -17,14 +17,31 @@ const (
 )
 
 type ProduceRequest struct {
-	RequiredAcks RequiredAcks
-	Timeout      int32
-	msgSets      map[string]map[int32]*MessageSet
+	// TransactionalID is the transactional ID of the producer, from version 3.
+	TransactionalID *string
+	RequiredAcks    RequiredAcks
+	Timeout         int32
+	// Version can be 0, 1 for Kafka 0.9 and later, in which case the response
+	// includes the time the request was throttled by quotas, 2 for 0.10, whose
+	// messages have timestamps, or 3 for 0.11, whose messages are sent in record
+	// batches, one per partition, added with AddBatch. Versions 4 to 7 are
+	// encoded as 3 is; 7, for Kafka 2.1, allows zstd compression.
+	Version       int16
+	msgSets       map[string]map[int32]*MessageSet
+	recordBatches map[string]map[int32]*RecordBatch
 }
 
 func (p *ProduceRequest) encode(pe packetEncoder) error {
+	if p.Version >= 3 {
+		if err := putNullableString(pe, p.TransactionalID); err != nil {
+			return err
+		}
+	}
 	pe.putInt16(int16(p.RequiredAcks))
 	pe.putInt32(p.Timeout)
+	if p.Version >= 3 {
+		return p.encodeRecordBatches(pe)
+	}
 	err := pe.putArrayLength(len(p.msgSets))
 	if err != nil {
 		return err
@@ -54,7 +71,39 @@ func (p *ProduceRequest) encode(pe packetEncoder) error {
 	return nil
 }
 
+func (p *ProduceRequest) encodeRecordBatches(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(p.recordBatches)); err != nil {
+		return err
+	}
+	for topic, partitions := range p.recordBatches {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for id, batch := range partitions {
+			pe.putInt32(id)
+			pe.push(&lengthField{})
+			if err := batch.encode(pe); err != nil {
+				return err
+			}
+			if err := pe.pop(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (p *ProduceRequest) decode(pd packetDecoder) error {
+	if p.Version >= 3 {
+		transactionalID, err := getNullableString(pd)
+		if err != nil {
+			return err
+		}
+		p.TransactionalID = transactionalID
+	}
 	requiredAcks, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -70,6 +119,9 @@ func (p *ProduceRequest) decode(pd packetDecoder) error {
 	if topicCount == 0 {
 		return nil
 	}
+	if p.Version >= 3 {
+		return p.decodeRecordBatches(pd, topicCount)
+	}
 	p.msgSets = make(map[string]map[int32]*MessageSet)
 	for i := 0; i < topicCount; i++ {
 		topic, err := pd.getString()
@@ -105,12 +157,47 @@ func (p *ProduceRequest) decode(pd packetDecoder) error {
 	return nil
 }
 
+func (p *ProduceRequest) decodeRecordBatches(pd packetDecoder, topicCount int) error {
+	p.recordBatches = make(map[string]map[int32]*RecordBatch)
+	for i := 0; i < topicCount; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		partitionCount, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		p.recordBatches[topic] = make(map[int32]*RecordBatch)
+		for j := 0; j < partitionCount; j++ {
+			partition, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			size, err := pd.getInt32()
+			if err != nil {
+				return err
+			}
+			batchDecoder, err := pd.getSubset(int(size))
+			if err != nil {
+				return err
+			}
+			batch := new(RecordBatch)
+			if err = batch.decode(batchDecoder); err != nil {
+				return err
+			}
+			p.recordBatches[topic][partition] = batch
+		}
+	}
+	return nil
+}
+
 func (p *ProduceRequest) key() int16 {
 	return 0
 }
 
 func (p *ProduceRequest) version() int16 {
-	return 0
+	return p.Version
 }
 
 func (p *ProduceRequest) AddMessage(topic string, partition int32, msg *Message) {
@@ -143,3 +230,17 @@ func (p *ProduceRequest) AddSet(topic string, partition int32, set *MessageSet)
 
 	p.msgSets[topic][partition] = set
 }
+
+// AddBatch sets the record batch of a partition, for requests of version 3 and
+// later.
+func (p *ProduceRequest) AddBatch(topic string, partition int32, batch *RecordBatch) {
+	if p.recordBatches == nil {
+		p.recordBatches = make(map[string]map[int32]*RecordBatch)
+	}
+
+	if p.recordBatches[topic] == nil {
+		p.recordBatches[topic] = make(map[int32]*RecordBatch)
+	}
+
+	p.recordBatches[topic][partition] = batch
+}
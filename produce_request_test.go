@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"testing"
+	"time"
 )
 
 var (
@@ -44,4 +45,26 @@ func TestProduceRequest(t *testing.T) {
 
 	request.AddMessage("topic", 0xAD, &Message{Codec: CompressionNone, Key: nil, Value: []byte{0x00, 0xEE}})
 	testRequest(t, "one message", request, produceRequestOneMessage)
+
+	request.Version = 1
+	testRequest(t, "one message v1", request, produceRequestOneMessage)
+}
+
+func TestProduceRequestV3(t *testing.T) {
+	id := "txn"
+	request := &ProduceRequest{Version: 3, TransactionalID: &id, RequiredAcks: WaitForAll, Timeout: 0x444}
+	batch := newRecordBatch(CompressionNone)
+	batch.addRecord(&Record{Value: []byte("v")}, time.Unix(1, 0))
+	request.AddBatch("topic", 0xAD, batch)
+
+	expected := []byte{
+		0x00, 0x03, 't', 'x', 'n',
+		0xFF, 0xFF,
+		0x00, 0x00, 0x04, 0x44,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x05, 't', 'o', 'p', 'i', 'c',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0xAD,
+		0x00, 0x00, 0x00, byte(len(oneRecordBatch))}
+	testRequest(t, "one batch", request, append(expected, oneRecordBatch...))
 }
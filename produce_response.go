@@ -1,11 +1,18 @@
 package sarama
 
+import "time"
+
 type ProduceResponseBlock struct {
 	Err    KError
 	Offset int64
+	// Timestamp is when the broker appended the messages if the topic uses
+	// LogAppendTime, from version 2; it is the zero time otherwise.
+	Timestamp time.Time
+	// StartOffset is the oldest offset of the partition, from version 5.
+	StartOffset int64
 }
 
-func (pr *ProduceResponseBlock) decode(pd packetDecoder) (err error) {
+func (pr *ProduceResponseBlock) decode(pd packetDecoder, version int16) (err error) {
 	tmp, err := pd.getInt16()
 	if err != nil {
 		return err
@@ -17,11 +24,31 @@ func (pr *ProduceResponseBlock) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
+	if version >= 2 {
+		millis, err := pd.getInt64()
+		if err != nil {
+			return err
+		}
+		pr.Timestamp = millisTimestamp(millis)
+	}
+
+	if version >= 5 {
+		pr.StartOffset, err = pd.getInt64()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 type ProduceResponse struct {
 	Blocks map[string]map[int32]*ProduceResponseBlock
+	// Version must be set to the version of the request before decoding.
+	Version int16
+	// ThrottleTime is how long the broker delayed the response because the
+	// client exceeded a quota (version 1 and later).
+	ThrottleTime time.Duration
 }
 
 func (pr *ProduceResponse) decode(pd packetDecoder) (err error) {
@@ -51,7 +78,7 @@ func (pr *ProduceResponse) decode(pd packetDecoder) (err error) {
 			}
 
 			block := new(ProduceResponseBlock)
-			err = block.decode(pd)
+			err = block.decode(pd, pr.Version)
 			if err != nil {
 				return err
 			}
@@ -59,6 +86,14 @@ func (pr *ProduceResponse) decode(pd packetDecoder) (err error) {
 		}
 	}
 
+	if pr.Version >= 1 {
+		millis, err := pd.getInt32()
+		if err != nil {
+			return err
+		}
+		pr.ThrottleTime = time.Duration(millis) * time.Millisecond
+	}
+
 	return nil
 }
 
@@ -80,8 +115,17 @@ func (pr *ProduceResponse) encode(pe packetEncoder) error {
 			pe.putInt32(id)
 			pe.putInt16(int16(prb.Err))
 			pe.putInt64(prb.Offset)
+			if pr.Version >= 2 {
+				pe.putInt64(timestampMillis(prb.Timestamp))
+			}
+			if pr.Version >= 5 {
+				pe.putInt64(prb.StartOffset)
+			}
 		}
 	}
+	if pr.Version >= 1 {
+		pe.putInt32(int32(pr.ThrottleTime / time.Millisecond))
+	}
 	return nil
 }
 
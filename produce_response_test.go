@@ -1,6 +1,9 @@
 package sarama
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 var (
 	produceResponseNoBlocks = []byte{
@@ -65,3 +68,87 @@ func TestProduceResponse(t *testing.T) {
 		}
 	}
 }
+
+func TestProduceResponseV1(t *testing.T) {
+	response := ProduceResponse{Version: 1}
+	testDecodable(t, "throttled", &response, []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x64})
+	if response.ThrottleTime != 100*time.Millisecond {
+		t.Error("Decoding produced a throttle time of", response.ThrottleTime, "instead of 100ms")
+	}
+
+	response = ProduceResponse{Version: 1, ThrottleTime: 20 * time.Millisecond}
+	response.AddTopicPartition("topic", 1, ErrNoError)
+	encoded, err := encode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := ProduceResponse{Version: 1}
+	testDecodable(t, "round trip", &decoded, encoded)
+	if decoded.ThrottleTime != response.ThrottleTime || decoded.GetBlock("topic", 1) == nil {
+		t.Error("Round trip produced", decoded)
+	}
+}
+
+func TestProduceResponseV2(t *testing.T) {
+	response := ProduceResponse{Version: 2}
+	testDecodable(t, "log append time", &response, []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xE8,
+		0x00, 0x00, 0x00, 0x00})
+	block := response.GetBlock("foo", 1)
+	if block == nil || block.Offset != 0xFF {
+		t.Fatal("Decoding did not produce the block for foo/1")
+	}
+	if !block.Timestamp.Equal(time.Unix(1, 0)) {
+		t.Error("Decoding produced a timestamp of", block.Timestamp, "instead of a second after the epoch")
+	}
+
+	response = ProduceResponse{Version: 2}
+	testDecodable(t, "create time", &response, []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x00})
+	if block := response.GetBlock("foo", 1); block == nil || !block.Timestamp.IsZero() {
+		t.Error("Decoding produced a timestamp for messages with their create time")
+	}
+}
+
+func TestProduceResponseV5(t *testing.T) {
+	response := ProduceResponse{Version: 5}
+	testDecodable(t, "log start offset", &response, []byte{
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x03, 'f', 'o', 'o',
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+		0x00, 0x00, 0x00, 0x00})
+	block := response.GetBlock("foo", 1)
+	if block == nil || block.Offset != 0xFF || block.StartOffset != 0x10 {
+		t.Fatal("Decoding did not produce the block for foo/1 with its start offset, got", block)
+	}
+
+	encoded, err := encode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := ProduceResponse{Version: 5}
+	testDecodable(t, "round trip", &decoded, encoded)
+	if block := decoded.GetBlock("foo", 1); block == nil || block.StartOffset != 0x10 {
+		t.Error("Round trip lost the start offset, got", block)
+	}
+}
@@ -1,11 +1,41 @@
 package sarama
 
-import "time"
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
 
 type partitionSet struct {
-	msgs        []*ProducerMessage
-	setToSend   *MessageSet
-	bufferBytes int
+	msgs []*ProducerMessage
+	// setToSend holds the messages before Version V0_11_0_0, and recordsToSend
+	// the records from it
+	setToSend     *MessageSet
+	recordsToSend *RecordBatch
+	bufferBytes   int
+}
+
+// compressedBatch remembers the compressed message or record batch a set of
+// messages was sent in, so that if exactly the same messages are retried together
+// (the common case when a partition's leader moves) the batch is resent without
+// compressing it again.
+type compressedBatch struct {
+	msgs    []*ProducerMessage
+	message *Message
+	records *RecordBatch
+}
+
+func (cb *compressedBatch) matches(msgs []*ProducerMessage) bool {
+	if cb == nil || len(cb.msgs) != len(msgs) {
+		return false
+	}
+	for i := range msgs {
+		if cb.msgs[i] != msgs[i] {
+			return false
+		}
+	}
+	return true
 }
 
 type produceSet struct {
@@ -45,16 +75,51 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 		ps.msgs[msg.Topic] = partitions
 	}
 
+	version := ps.parent.conf.Version
 	set := partitions[msg.Partition]
 	if set == nil {
-		set = &partitionSet{setToSend: new(MessageSet)}
+		set = new(partitionSet)
+		if version.IsAtLeast(V0_11_0_0) {
+			set.recordsToSend = newRecordBatch(ps.parent.conf.Producer.Compression)
+			set.recordsToSend.CompressionLevel = ps.parent.conf.Producer.CompressionLevel
+		} else {
+			set.setToSend = new(MessageSet)
+		}
 		partitions[msg.Partition] = set
 	}
 
-	set.msgs = append(set.msgs, msg)
-	set.setToSend.addMessage(&Message{Codec: CompressionNone, Key: key, Value: val})
+	if msg.Timestamp.IsZero() && version.IsAtLeast(V0_10_0_0) {
+		msg.Timestamp = time.Now()
+	}
 
+	set.msgs = append(set.msgs, msg)
 	size := producerMessageOverhead + len(key) + len(val)
+	switch {
+	case set.recordsToSend != nil:
+		if txnmgr := ps.parent.txnmgr; txnmgr != nil {
+			txnmgr.sequence(msg)
+			if len(set.recordsToSend.Records) == 0 {
+				set.recordsToSend.ProducerID, set.recordsToSend.ProducerEpoch = txnmgr.producer()
+				set.recordsToSend.Transactional = txnmgr.transactional()
+				set.recordsToSend.FirstSequence = msg.sequence
+			}
+		}
+		record := &Record{Key: key, Value: val}
+		for i := range msg.Headers {
+			header := &msg.Headers[i]
+			record.Headers = append(record.Headers, header)
+			size += len(header.Key) + len(header.Value) + 2*binary.MaxVarintLen32
+		}
+		set.recordsToSend.addRecord(record, msg.Timestamp)
+	case version.IsAtLeast(V0_10_0_0):
+		// wrapped messages of version 1 have offsets relative to the first
+		offset := int64(len(set.setToSend.Messages))
+		message := &Message{Codec: CompressionNone, Key: key, Value: val, Version: 1, Timestamp: msg.Timestamp}
+		set.setToSend.Messages = append(set.setToSend.Messages, &MessageBlock{Offset: offset, Msg: message})
+	default:
+		set.setToSend.addMessage(&Message{Codec: CompressionNone, Key: key, Value: val})
+	}
+
 	set.bufferBytes += size
 	ps.bufferBytes += size
 	ps.bufferCount++
@@ -67,26 +132,30 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 		RequiredAcks: ps.parent.conf.Producer.RequiredAcks,
 		Timeout:      int32(ps.parent.conf.Producer.Timeout / time.Millisecond),
 	}
+	switch {
+	case ps.parent.conf.Version.IsAtLeast(V2_1_0_0):
+		// zstd compressed batches are only accepted from version 7
+		req.Version = 7
+	case ps.parent.conf.Version.IsAtLeast(V0_11_0_0):
+		req.Version = 3
+	case ps.parent.conf.Version.IsAtLeast(V0_10_0_0):
+		req.Version = 2
+	case ps.parent.conf.Version.IsAtLeast(V0_9_0_0):
+		req.Version = 1
+	}
 
 	for topic, partitionSet := range ps.msgs {
 		for partition, set := range partitionSet {
-			if ps.parent.conf.Producer.Compression == CompressionNone {
+			if set.recordsToSend != nil {
+				req.AddBatch(topic, partition, ps.recordBatch(set))
+			} else if ps.parent.conf.Producer.Compression == CompressionNone {
 				req.AddSet(topic, partition, set.setToSend)
 			} else {
 				// When compression is enabled, the entire set for each partition is compressed
 				// and sent as the payload of a single fake "message" with the appropriate codec
 				// set and no key. When the server sees a message with a compression codec, it
 				// decompresses the payload and treats the result as its message set.
-				payload, err := encode(set.setToSend)
-				if err != nil {
-					Logger.Println(err) // if this happens, it's basically our fault.
-					panic(err)
-				}
-				req.AddMessage(topic, partition, &Message{
-					Codec: ps.parent.conf.Producer.Compression,
-					Key:   nil,
-					Value: payload,
-				})
+				req.AddMessage(topic, partition, ps.compressedMessage(set))
 			}
 		}
 	}
@@ -94,6 +163,58 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 	return req
 }
 
+func (ps *produceSet) compressedMessage(set *partitionSet) *Message {
+	if batch := set.msgs[0].batch; batch.matches(set.msgs) && batch.message != nil {
+		return batch.message
+	}
+
+	payload, err := encode(set.setToSend)
+	if err != nil {
+		LogProducer.error("failed to encode message set", "err", err) // if this happens, it's basically our fault.
+		panic(err)
+	}
+	batch := &compressedBatch{
+		msgs: set.msgs,
+		message: &Message{
+			Codec:            ps.parent.conf.Producer.Compression,
+			CompressionLevel: ps.parent.conf.Producer.CompressionLevel,
+			Key:              nil,
+			Value:            payload,
+			lz4LegacyFraming: !ps.parent.conf.Version.IsAtLeast(V0_10_0_0),
+			retainCompressed: true,
+		},
+	}
+	if ps.parent.conf.Version.IsAtLeast(V0_10_0_0) {
+		// the wrapper of messages of version 1 carries the latest of their timestamps
+		batch.message.Version = 1
+		for _, block := range set.setToSend.Messages {
+			if block.Msg.Timestamp.After(batch.message.Timestamp) {
+				batch.message.Timestamp = block.Msg.Timestamp
+			}
+		}
+	}
+	for _, msg := range set.msgs {
+		msg.batch = batch
+	}
+	return batch.message
+}
+
+func (ps *produceSet) recordBatch(set *partitionSet) *RecordBatch {
+	if set.recordsToSend.Codec == CompressionNone {
+		return set.recordsToSend
+	}
+	if batch := set.msgs[0].batch; batch.matches(set.msgs) && batch.records != nil {
+		return batch.records
+	}
+
+	set.recordsToSend.retainCompressed = true
+	batch := &compressedBatch{msgs: set.msgs, records: set.recordsToSend}
+	for _, msg := range set.msgs {
+		msg.batch = batch
+	}
+	return batch.records
+}
+
 func (ps *produceSet) eachPartition(cb func(topic string, partition int32, msgs []*ProducerMessage)) {
 	for topic, partitionSet := range ps.msgs {
 		for partition, set := range partitionSet {
@@ -119,13 +240,17 @@ func (ps *produceSet) dropPartition(topic string, partition int32) []*ProducerMe
 func (ps *produceSet) wouldOverflow(msg *ProducerMessage) bool {
 	switch {
 	// Would we overflow our maximum possible size-on-the-wire? 10KiB is arbitrary overhead for safety.
-	case ps.bufferBytes+msg.byteSize() >= int(MaxRequestSize-(10*1024)):
+	case ps.bufferBytes+msg.byteSize() >= int(ps.parent.conf.maxRequestSize()-(10*1024)):
 		return true
-	// Would we overflow the size-limit of a compressed message-batch for this partition?
-	case ps.parent.conf.Producer.Compression != CompressionNone &&
+	// Would we overflow the size-limit of a compressed message-batch or of a
+	// record batch, which the broker checks as a whole, for this partition?
+	case (ps.parent.conf.Producer.Compression != CompressionNone || ps.parent.conf.Version.IsAtLeast(V0_11_0_0)) &&
 		ps.msgs[msg.Topic] != nil && ps.msgs[msg.Topic][msg.Partition] != nil &&
 		ps.msgs[msg.Topic][msg.Partition].bufferBytes+msg.byteSize() >= ps.parent.conf.Producer.MaxMessageBytes:
 		return true
+	// Would the message not follow the messages of its partition in sequence?
+	case ps.breaksSequence(msg):
+		return true
 	// Would we overflow simply in number of messages?
 	case ps.parent.conf.Producer.Flush.MaxMessages > 0 && ps.bufferCount >= ps.parent.conf.Producer.Flush.MaxMessages:
 		return true
@@ -134,6 +259,40 @@ func (ps *produceSet) wouldOverflow(msg *ProducerMessage) bool {
 	}
 }
 
+// breaksSequence returns whether the message of an idempotent producer would not
+// take the sequence number after the last message buffered for its partition,
+// with the same producer ID and epoch, since the messages of a record batch take
+// consecutive sequence numbers.
+func (ps *produceSet) breaksSequence(msg *ProducerMessage) bool {
+	txnmgr := ps.parent.txnmgr
+	if txnmgr == nil || ps.msgs[msg.Topic] == nil || ps.msgs[msg.Topic][msg.Partition] == nil {
+		return false
+	}
+	set := ps.msgs[msg.Topic][msg.Partition]
+	sequence, generation := txnmgr.peekSequence(msg)
+	return generation != set.msgs[0].generation ||
+		sequence != sequenceAfter(set.recordsToSend.FirstSequence, len(set.recordsToSend.Records))
+}
+
+// resequence numbers the messages buffered for the partition again if the
+// producer acquired a new producer ID since they were numbered, as it does when
+// a message before them failed.
+func (ps *produceSet) resequence(topic string, partition int32) {
+	txnmgr := ps.parent.txnmgr
+	if txnmgr == nil || ps.msgs[topic] == nil || ps.msgs[topic][partition] == nil {
+		return
+	}
+	set := ps.msgs[topic][partition]
+	if _, generation := txnmgr.peekSequence(set.msgs[0]); generation == set.msgs[0].generation {
+		return
+	}
+	for _, msg := range set.msgs {
+		txnmgr.sequence(msg)
+	}
+	set.recordsToSend.ProducerID, set.recordsToSend.ProducerEpoch = txnmgr.producer()
+	set.recordsToSend.FirstSequence = set.msgs[0].sequence
+}
+
 func (ps *produceSet) readyToFlush() bool {
 	switch {
 	// If we don't have any messages, nothing else matters
@@ -156,3 +315,55 @@ func (ps *produceSet) readyToFlush() bool {
 func (ps *produceSet) empty() bool {
 	return ps.bufferCount == 0
 }
+
+// updateMetrics records the producer metrics for a set that has just been sent.
+// It must be called after the request is encoded, since compression ratios are
+// taken from the compressed messages.
+func (ps *produceSet) updateMetrics() {
+	registry := ps.parent.conf.MetricRegistry
+	batchSize := getOrRegisterHistogram("batch-size", registry)
+	recordsPerBatch := getOrRegisterHistogram("records-per-batch", registry)
+	recordSendRate := metrics.GetOrRegisterMeter("record-send-rate", registry)
+	compressionRatio := getOrRegisterHistogram("compression-ratio", registry)
+
+	for topic, partitionSet := range ps.msgs {
+		topicRecords := int64(0)
+		for _, set := range partitionSet {
+			topicRecords += int64(len(set.msgs))
+
+			batchSize.Update(int64(set.bufferBytes))
+			getOrRegisterTopicHistogram("batch-size", topic, registry).Update(int64(set.bufferBytes))
+			recordsPerBatch.Update(int64(len(set.msgs)))
+			getOrRegisterTopicHistogram("records-per-batch", topic, registry).Update(int64(len(set.msgs)))
+
+			if ratio, ok := set.compressionRatio(); ok {
+				compressionRatio.Update(ratio)
+				getOrRegisterTopicHistogram("compression-ratio", topic, registry).Update(ratio)
+			}
+		}
+		getOrRegisterTopicMeter("record-send-rate", topic, registry).Mark(topicRecords)
+		getOrRegisterTopicHistogram("records-per-request", topic, registry).Update(topicRecords)
+	}
+
+	recordSendRate.Mark(int64(ps.bufferCount))
+	getOrRegisterHistogram("records-per-request", registry).Update(int64(ps.bufferCount))
+}
+
+// compressionRatio returns the size of the set's message set relative to its
+// compressed size, multiplied by 100 so it fits in a histogram.
+func (set *partitionSet) compressionRatio() (int64, bool) {
+	batch := set.msgs[0].batch
+	if !batch.matches(set.msgs) {
+		return 0, false
+	}
+	if records := batch.records; records != nil {
+		if len(records.compressedRecords) == 0 {
+			return 0, false
+		}
+		return int64(100 * records.decompressedSize / len(records.compressedRecords)), true
+	}
+	if batch.message.Codec == CompressionNone || len(batch.message.compressedCache) == 0 {
+		return 0, false
+	}
+	return int64(100 * len(batch.message.Value) / len(batch.message.compressedCache)), true
+}
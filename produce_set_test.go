@@ -3,6 +3,8 @@ package sarama
 import (
 	"testing"
 	"time"
+
+	"github.com/rcrowley/go-metrics"
 )
 
 func makeProduceSet() (*asyncProducer, *produceSet) {
@@ -141,3 +143,177 @@ func TestProduceSetRequestBuilding(t *testing.T) {
 		t.Error("Wrong number of topics in request")
 	}
 }
+
+func TestProduceSetNullAndEmpty(t *testing.T) {
+	_, ps := makeProduceSet()
+
+	safeAddMessage(t, ps, &ProducerMessage{Topic: "t1", Partition: 0})
+	safeAddMessage(t, ps, &ProducerMessage{Topic: "t1", Partition: 0, Key: ByteEncoder(nil), Value: ByteEncoder(nil)})
+	safeAddMessage(t, ps, &ProducerMessage{Topic: "t1", Partition: 0, Key: StringEncoder(""), Value: ByteEncoder([]byte{})})
+
+	msgs := ps.msgs["t1"][0].setToSend.Messages
+	for i := 0; i < 2; i++ {
+		if msgs[i].Msg.Key != nil || msgs[i].Msg.Value != nil {
+			t.Errorf("message %d should have a null key and value", i)
+		}
+	}
+	if msgs[2].Msg.Key == nil || len(msgs[2].Msg.Key) != 0 || msgs[2].Msg.Value == nil || len(msgs[2].Msg.Value) != 0 {
+		t.Error("message 2 should have an empty, non-null key and value")
+	}
+
+	encoded, err := encode(ps.msgs["t1"][0].setToSend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(MessageSet)
+	if err := decode(encoded, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Messages[1].Msg.Value != nil {
+		t.Error("null value did not survive a round trip")
+	}
+	if decoded.Messages[2].Msg.Value == nil {
+		t.Error("empty value decoded as null")
+	}
+}
+
+func TestProduceSetReusesCompressedBatches(t *testing.T) {
+	parent, ps := makeProduceSet()
+	parent.conf.Producer.Compression = CompressionGZIP
+
+	msgs := []*ProducerMessage{
+		{Topic: "t1", Partition: 0, Value: StringEncoder(TestMessage)},
+		{Topic: "t1", Partition: 0, Value: StringEncoder(TestMessage)},
+	}
+	for _, msg := range msgs {
+		safeAddMessage(t, ps, msg)
+	}
+	first := ps.buildRequest()
+	if _, err := encode(first); err != nil {
+		t.Fatal(err)
+	}
+	wrapper := first.msgSets["t1"][0].Messages[0].Msg
+	if wrapper.compressedCache == nil {
+		t.Fatal("compressed payload was not retained after encoding")
+	}
+
+	// retrying exactly the same messages resends the same compressed message
+	ps = newProduceSet(parent)
+	for _, msg := range msgs {
+		safeAddMessage(t, ps, msg)
+	}
+	if ps.buildRequest().msgSets["t1"][0].Messages[0].Msg != wrapper {
+		t.Error("retried batch was compressed again")
+	}
+
+	// but a batch made up differently has to be compressed afresh
+	ps = newProduceSet(parent)
+	safeAddMessage(t, ps, msgs[1])
+	if ps.buildRequest().msgSets["t1"][0].Messages[0].Msg == wrapper {
+		t.Error("compressed batch reused for a different set of messages")
+	}
+}
+
+func TestProduceSetMetrics(t *testing.T) {
+	parent, ps := makeProduceSet()
+	parent.conf.Producer.Compression = CompressionGZIP
+
+	msg := &ProducerMessage{Topic: "t1.metrics", Partition: 0, Value: StringEncoder(TestMessage)}
+	for i := 0; i < 10; i++ {
+		safeAddMessage(t, ps, msg)
+	}
+
+	if _, err := encode(ps.buildRequest()); err != nil {
+		t.Fatal(err)
+	}
+	ps.updateMetrics()
+
+	registry := parent.conf.MetricRegistry
+	if rate := registry.Get("record-send-rate-for-topic-t1_metrics").(metrics.Meter); rate.Count() != 10 {
+		t.Error("Expected 10 records sent, got", rate.Count())
+	}
+	if records := registry.Get("records-per-request").(metrics.Histogram); records.Max() != 10 {
+		t.Error("Expected 10 records per request, got", records.Max())
+	}
+	if records := registry.Get("records-per-batch-for-topic-t1_metrics").(metrics.Histogram); records.Count() != 1 || records.Max() != 10 {
+		t.Error("Expected a single batch of 10 records, got", records.Max())
+	}
+	if ratio := registry.Get("compression-ratio").(metrics.Histogram); ratio.Count() != 1 || ratio.Max() <= 100 {
+		t.Error("Expected a single compression ratio above 100, got", ratio.Max())
+	}
+}
+
+func TestProduceSetRecordBatchSizeLimit(t *testing.T) {
+	parent, ps := makeProduceSet()
+	parent.conf.Producer.MaxMessageBytes = 1000
+
+	msg := &ProducerMessage{Topic: "t1", Partition: 0, Value: ByteEncoder(make([]byte, 400))}
+	safeAddMessage(t, ps, msg)
+	safeAddMessage(t, ps, msg)
+	if ps.wouldOverflow(msg) {
+		t.Error("Uncompressed message sets should not be limited to MaxMessageBytes")
+	}
+
+	parent.conf.Version = V0_11_0_0
+	ps = newProduceSet(parent)
+	safeAddMessage(t, ps, msg)
+	if ps.wouldOverflow(msg) {
+		t.Error("Record batch shouldn't be full after 1 message")
+	}
+	safeAddMessage(t, ps, msg)
+	if !ps.wouldOverflow(msg) {
+		t.Error("Record batch should be full once it would exceed MaxMessageBytes")
+	}
+	if ps.wouldOverflow(&ProducerMessage{Topic: "t1", Partition: 1, Value: ByteEncoder(make([]byte, 400))}) {
+		t.Error("Record batches of other partitions should not be full")
+	}
+}
+
+// benchmarkProduceSetEncoding measures building and encoding produce requests of
+// 100 messages of 1KiB into a reused buffer, as the broker producer does.
+func benchmarkProduceSetEncoding(b *testing.B, version KafkaVersion, codec CompressionCodec) {
+	parent, _ := makeProduceSet()
+	parent.conf.Version = version
+	parent.conf.Producer.Compression = codec
+	value := make([]byte, 1024)
+	for i := range value {
+		value[i] = byte(i % 7)
+	}
+
+	var buf []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ps := newProduceSet(parent)
+		for j := 0; j < 100; j++ {
+			if err := ps.add(&ProducerMessage{Topic: "my_topic", Key: StringEncoder("key"), Value: ByteEncoder(value)}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		req := &request{correlationID: 1, clientID: "sarama", body: ps.buildRequest()}
+		var err error
+		if _, buf, err = encodeVectored(req, buf, MaxRequestSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProduceSetEncodingMessageSet(b *testing.B) {
+	benchmarkProduceSetEncoding(b, V0_10_0_0, CompressionNone)
+}
+
+func BenchmarkProduceSetEncodingMessageSetGZIP(b *testing.B) {
+	benchmarkProduceSetEncoding(b, V0_10_0_0, CompressionGZIP)
+}
+
+func BenchmarkProduceSetEncodingRecordBatch(b *testing.B) {
+	benchmarkProduceSetEncoding(b, V0_11_0_0, CompressionNone)
+}
+
+func BenchmarkProduceSetEncodingRecordBatchGZIP(b *testing.B) {
+	benchmarkProduceSetEncoding(b, V0_11_0_0, CompressionGZIP)
+}
+
+func BenchmarkProduceSetEncodingRecordBatchSnappy(b *testing.B) {
+	benchmarkProduceSetEncoding(b, V0_11_0_0, CompressionSnappy)
+}
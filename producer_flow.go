@@ -0,0 +1,113 @@
+package sarama
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter paces the messages a producer accepts, as set with
+// Producer.FlowControl.RateLimiter: Wait blocks until the producer may accept
+// msg. It is called from a single goroutine of the producer, in the order the
+// messages were sent.
+type RateLimiter interface {
+	Wait(msg *ProducerMessage)
+}
+
+// NewRateLimiter returns a RateLimiter that accepts up to perSecond messages a
+// second, which must be positive, in bursts of up to burst messages, as a token
+// bucket.
+func NewRateLimiter(perSecond float64, burst int) RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{perSecond: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+type tokenBucket struct {
+	perSecond, burst float64
+	tokens           float64
+	last             time.Time
+}
+
+func (b *tokenBucket) Wait(msg *ProducerMessage) {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.perSecond
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens < 0 {
+		// the message takes the token of the time it waits for
+		time.Sleep(time.Duration(-b.tokens / b.perSecond * float64(time.Second)))
+	}
+}
+
+// producerFlow limits the messages of a producer in flight, from the time it
+// accepts them until it returns them, as set with Producer.FlowControl.
+type producerFlow struct {
+	maxMessages, maxBytes int
+	limiter               RateLimiter
+
+	lock            sync.Mutex
+	cond            *sync.Cond
+	messages, bytes int
+}
+
+func newProducerFlow(conf *Config) *producerFlow {
+	flow := &producerFlow{
+		maxMessages: conf.Producer.FlowControl.MaxInFlightMessages,
+		maxBytes:    conf.Producer.FlowControl.MaxInFlightBytes,
+		limiter:     conf.Producer.FlowControl.RateLimiter,
+	}
+	if flow.maxMessages == 0 && flow.maxBytes == 0 && flow.limiter == nil {
+		return nil
+	}
+	flow.cond = sync.NewCond(&flow.lock)
+	return flow
+}
+
+// run accepts the messages sent to input once the rate limiter lets it and
+// there is room for them, passing them on to output. Messages the producer
+// generates itself, such as the markers of shutdown, are passed on right away,
+// but only after the messages sent before them.
+func (f *producerFlow) run(input <-chan *ProducerMessage, output chan<- *ProducerMessage) {
+	for msg := range input {
+		if msg != nil && msg.flags == 0 && msg.retries == 0 {
+			if f.limiter != nil {
+				f.limiter.Wait(msg)
+			}
+			f.acquire(msg)
+		}
+		output <- msg
+	}
+}
+
+// acquire waits for room for msg. A message larger than MaxInFlightBytes is
+// accepted once no other is in flight.
+func (f *producerFlow) acquire(msg *ProducerMessage) {
+	size := msg.byteSize()
+	f.lock.Lock()
+	for f.messages > 0 && ((f.maxMessages > 0 && f.messages >= f.maxMessages) || (f.maxBytes > 0 && f.bytes+size > f.maxBytes)) {
+		f.cond.Wait()
+	}
+	f.messages++
+	f.bytes += size
+	f.lock.Unlock()
+	msg.flowBytes = size
+}
+
+// release makes room for the messages after one of the given size, as acquired,
+// once the producer has returned it. Messages generated by the producer, which
+// have a size of 0, were never acquired.
+func (f *producerFlow) release(size int) {
+	if f == nil || size == 0 {
+		return
+	}
+	f.lock.Lock()
+	f.messages--
+	f.bytes -= size
+	f.cond.Signal()
+	f.lock.Unlock()
+}
@@ -0,0 +1,57 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(100, 2)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		limiter.Wait(nil)
+	}
+	if elapsed := time.Since(start); elapsed >= 10*time.Millisecond {
+		t.Error("Expected a burst of 2 messages to be accepted right away, took", elapsed)
+	}
+	for i := 0; i < 5; i++ {
+		limiter.Wait(nil)
+	}
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond || elapsed > time.Second {
+		t.Error("Expected 5 more messages to take about 50ms, took", elapsed)
+	}
+}
+
+func TestProducerFlowLimitsBytes(t *testing.T) {
+	config := NewConfig()
+	config.Producer.FlowControl.MaxInFlightBytes = 2 * producerMessageOverhead
+	flow := newProducerFlow(config)
+
+	large := &ProducerMessage{Value: ByteEncoder(make([]byte, 100))}
+	flow.acquire(large) // larger than the limit, but alone
+	acquired := make(chan struct{})
+	small := new(ProducerMessage)
+	go func() {
+		flow.acquire(small)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected the message to wait for room")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	flow.release(large.flowBytes)
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the message to be accepted once the larger one was released")
+	}
+	if small.flowBytes != producerMessageOverhead {
+		t.Error("Expected the message to count for its size, got", small.flowBytes)
+	}
+
+	if newProducerFlow(NewConfig()) != nil {
+		t.Error("Expected no flow control by default")
+	}
+}
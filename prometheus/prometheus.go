@@ -0,0 +1,191 @@
+/*
+Package prometheus exposes the metrics Sarama records in its go-metrics registry
+(see Config.MetricRegistry) as Prometheus metrics, so they can be scraped along
+with the rest of an application's metrics:
+
+	config := sarama.NewConfig()
+	prometheus.MustRegister(saramaprom.NewCollector(config.MetricRegistry))
+
+Metric names are fixed and do not depend on the brokers or topics in use. Metrics
+Sarama records per broker or per topic are exported as separate metric families
+with a "broker" or "topic" label, next to the aggregated family without labels:
+
+	sarama_requests_total
+	sarama_broker_requests_total{broker="1"}
+	sarama_topic_records_sent_total{topic="my_topic"}
+	sarama_topic_consumer_lag{topic="my_topic",partition="0"}
+	sarama_topic_consumer_group_lag{group="my_group",topic="my_topic",partition="0"}
+
+Dots in topic names are replaced with underscores by Sarama before they reach the
+registry, so they are not recoverable here and appear as underscores in the topic
+label as well.
+
+Meters are exported as counters of the events they have seen, histograms as
+summaries computed over their (decaying) sample, and counters as gauges. Latencies
+are converted to seconds and compression ratios to plain ratios, as is customary
+for Prometheus.
+
+NOTE: this package currently does not fall under the API stability guarantee of
+Sarama as it is still considered experimental.
+*/
+package prometheus
+
+import (
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/rcrowley/go-metrics"
+)
+
+// Namespace prefixes the names of all exported metrics.
+const Namespace = "sarama"
+
+const (
+	brokerSuffix    = "-for-broker-"
+	topicSuffix     = "-for-topic-"
+	partitionSuffix = "-partition-"
+	groupSuffix     = "-for-group-"
+)
+
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+type metricInfo struct {
+	name  string
+	help  string
+	scale float64 // multiplies histogram values; 0 means 1
+}
+
+// knownMetrics maps the go-metrics names Sarama registers to their Prometheus
+// names. Metrics that aren't listed here are exported under their sanitized name.
+var knownMetrics = map[string]metricInfo{
+	"incoming-byte-rate":                {name: "incoming_bytes_total", help: "Bytes read from brokers."},
+	"outgoing-byte-rate":                {name: "outgoing_bytes_total", help: "Bytes written to brokers."},
+	"request-rate":                      {name: "requests_total", help: "Requests sent to brokers."},
+	"response-rate":                     {name: "responses_total", help: "Responses received from brokers."},
+	"request-size":                      {name: "request_size_bytes", help: "Size of requests sent to brokers."},
+	"response-size":                     {name: "response_size_bytes", help: "Size of responses received from brokers."},
+	"request-latency-in-ms":             {name: "request_latency_seconds", help: "Time from sending a request to receiving its response.", scale: 0.001},
+	"requests-in-flight":                {name: "requests_in_flight", help: "Requests sent to brokers and awaiting a response."},
+	"connection-attempt-rate":           {name: "connection_attempts_total", help: "Attempts to connect to brokers."},
+	"connection-creation-rate":          {name: "connections_created_total", help: "Connections established to brokers."},
+	"connection-close-rate":             {name: "connections_closed_total", help: "Connections to brokers that were closed."},
+	"connection-failure-rate":           {name: "connection_failures_total", help: "Attempts to connect to brokers that failed."},
+	"failed-authentication-rate":        {name: "failed_authentications_total", help: "Connections to brokers whose TLS handshake failed."},
+	"handshake-latency-in-ms":           {name: "handshake_latency_seconds", help: "Time taken to connect to brokers, including the TLS handshake.", scale: 0.001},
+	"connection-count":                  {name: "connections", help: "Open connections to brokers."},
+	"batch-size":                        {name: "batch_size_bytes", help: "Size of the message batches produced per partition."},
+	"records-per-batch":                 {name: "records_per_batch", help: "Records in the message batches produced per partition."},
+	"record-send-rate":                  {name: "records_sent_total", help: "Records sent by producers."},
+	"record-retry-rate":                 {name: "record_retries_total", help: "Records whose production was retried."},
+	"record-error-rate":                 {name: "record_errors_total", help: "Records that failed to be produced."},
+	"records-per-request":               {name: "records_per_request", help: "Records sent per produce request."},
+	"compression-ratio":                 {name: "compression_ratio", help: "Uncompressed size of produced batches relative to their compressed size.", scale: 0.01},
+	"produce-throttle-time-in-ms":       {name: "produce_throttle_time_seconds", help: "Time produce responses were delayed by broker quotas.", scale: 0.001},
+	"fetch-throttle-time-in-ms":         {name: "fetch_throttle_time_seconds", help: "Time fetch responses were delayed by broker quotas.", scale: 0.001},
+	"fetch-latency-in-ms":               {name: "fetch_latency_seconds", help: "Time taken by fetch requests, including the time spent waiting for data.", scale: 0.001},
+	"consumer-lag":                      {name: "consumer_lag", help: "Messages between the consumer's position and the partition's high water mark."},
+	"consumer-end-to-end-latency-in-ms": {name: "consumer_end_to_end_latency_seconds", help: "Time from the timestamp of consumed messages to their consumption.", scale: 0.001},
+	"consumer-group-lag":                {name: "consumer_group_lag", help: "Messages between the consumer group's committed offset and the partition's log-end offset."},
+}
+
+// Collector is a prometheus.Collector reading from a go-metrics registry. It is
+// an unchecked collector: the metrics it exports are only known once Sarama has
+// registered them, so Describe does not describe any.
+type Collector struct {
+	registry metrics.Registry
+}
+
+// NewCollector returns a Collector for the given registry, typically the
+// MetricRegistry of the Config used to create a client, producer or consumer.
+func NewCollector(registry metrics.Registry) *Collector {
+	return &Collector{registry: registry}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	c.registry.Each(func(name string, metric interface{}) {
+		info, labels, values := describe(name)
+		desc := prom.NewDesc(info.name, info.help, labels, nil)
+
+		switch m := metric.(type) {
+		case metrics.Meter:
+			ch <- prom.MustNewConstMetric(desc, prom.CounterValue, float64(m.Count()), values...)
+		case metrics.Counter:
+			ch <- prom.MustNewConstMetric(desc, prom.GaugeValue, float64(m.Count()), values...)
+		case metrics.Gauge:
+			ch <- prom.MustNewConstMetric(desc, prom.GaugeValue, float64(m.Value()), values...)
+		case metrics.GaugeFloat64:
+			ch <- prom.MustNewConstMetric(desc, prom.GaugeValue, m.Value(), values...)
+		case metrics.Histogram:
+			ch <- summary(desc, m.Snapshot(), info.scale, values)
+		}
+	})
+}
+
+// describe splits a go-metrics name into its Prometheus name and labels.
+func describe(name string) (info metricInfo, labels []string, values []string) {
+	family := Namespace
+	if i := strings.LastIndex(name, brokerSuffix); i >= 0 {
+		family, labels, values = Namespace+"_broker", []string{"broker"}, []string{name[i+len(brokerSuffix):]}
+		name = name[:i]
+	} else if i := strings.Index(name, topicSuffix); i >= 0 {
+		family, labels, values = Namespace+"_topic", []string{"topic"}, []string{name[i+len(topicSuffix):]}
+		name = name[:i]
+		if j := strings.LastIndex(values[0], partitionSuffix); j >= 0 && isNumeric(values[0][j+len(partitionSuffix):]) {
+			labels = append(labels, "partition")
+			values = []string{values[0][:j], values[0][j+len(partitionSuffix):]}
+		}
+		if j := strings.LastIndex(name, groupSuffix); j >= 0 {
+			labels = append(labels, "group")
+			values = append(values, name[j+len(groupSuffix):])
+			name = name[:j]
+		}
+	}
+
+	info, ok := knownMetrics[name]
+	if !ok {
+		info = metricInfo{name: sanitize(name), help: "Sarama metric " + name + "."}
+	}
+	info.name = family + "_" + info.name
+	return info, labels, values
+}
+
+func summary(desc *prom.Desc, h metrics.Histogram, scale float64, values []string) prom.Metric {
+	if scale == 0 {
+		scale = 1
+	}
+
+	points := h.Percentiles(quantiles)
+	byQuantile := make(map[float64]float64, len(quantiles))
+	for i, q := range quantiles {
+		byQuantile[q] = points[i] * scale
+	}
+
+	return prom.MustNewConstSummary(desc, uint64(h.Count()), float64(h.Sum())*scale, byQuantile, values...)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitize turns a go-metrics name into a valid Prometheus metric name.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,127 @@
+package prometheus
+
+import (
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rcrowley/go-metrics"
+)
+
+func gather(t *testing.T, registry metrics.Registry) map[string]*dto.MetricFamily {
+	promRegistry := prom.NewPedanticRegistry()
+	if err := promRegistry.Register(NewCollector(registry)); err != nil {
+		t.Fatal(err)
+	}
+
+	families, err := promRegistry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName
+}
+
+func TestCollectorNamesAndLabels(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterMeter("request-rate", registry).Mark(3)
+	metrics.GetOrRegisterMeter("request-rate-for-broker-1", registry).Mark(2)
+	metrics.GetOrRegisterMeter("record-send-rate-for-topic-my_topic", registry).Mark(5)
+	metrics.GetOrRegisterCounter("requests-in-flight", registry).Inc(4)
+	metrics.GetOrRegisterMeter("some-new-rate", registry).Mark(1)
+	metrics.GetOrRegisterGauge("consumer-lag-for-topic-my-topic-partition-2", registry).Update(7)
+	metrics.GetOrRegisterGauge("consumer-group-lag-for-group-my-group-for-topic-my_topic-partition-0", registry).Update(9)
+
+	families := gather(t, registry)
+
+	family := families["sarama_requests_total"]
+	if family == nil || family.GetType() != dto.MetricType_COUNTER || family.Metric[0].Counter.GetValue() != 3 {
+		t.Error("Expected sarama_requests_total to be a counter of 3, got", family)
+	}
+
+	family = families["sarama_broker_requests_total"]
+	if family == nil || family.Metric[0].Counter.GetValue() != 2 {
+		t.Fatal("Expected sarama_broker_requests_total to be 2, got", family)
+	}
+	if label := family.Metric[0].Label[0]; label.GetName() != "broker" || label.GetValue() != "1" {
+		t.Error("Expected a broker label of 1, got", label)
+	}
+
+	family = families["sarama_topic_records_sent_total"]
+	if family == nil || family.Metric[0].Counter.GetValue() != 5 {
+		t.Fatal("Expected sarama_topic_records_sent_total to be 5, got", family)
+	}
+	if label := family.Metric[0].Label[0]; label.GetName() != "topic" || label.GetValue() != "my_topic" {
+		t.Error("Expected a topic label of my_topic, got", label)
+	}
+
+	family = families["sarama_requests_in_flight"]
+	if family == nil || family.GetType() != dto.MetricType_GAUGE || family.Metric[0].Gauge.GetValue() != 4 {
+		t.Error("Expected sarama_requests_in_flight to be a gauge of 4, got", family)
+	}
+
+	family = families["sarama_topic_consumer_lag"]
+	if family == nil || family.Metric[0].Gauge.GetValue() != 7 {
+		t.Fatal("Expected sarama_topic_consumer_lag to be 7, got", family)
+	}
+	labels := family.Metric[0].Label
+	if len(labels) != 2 || labels[0].GetName() != "partition" || labels[0].GetValue() != "2" || labels[1].GetValue() != "my-topic" {
+		t.Error("Expected partition and topic labels, got", labels)
+	}
+
+	family = families["sarama_topic_consumer_group_lag"]
+	if family == nil || family.Metric[0].Gauge.GetValue() != 9 {
+		t.Fatal("Expected sarama_topic_consumer_group_lag to be 9, got", family)
+	}
+	if label := family.Metric[0].Label[0]; label.GetName() != "group" || label.GetValue() != "my-group" {
+		t.Error("Expected a group label of my-group, got", label)
+	}
+
+	if families["sarama_some_new_rate"] == nil {
+		t.Error("Expected unknown metrics to be exported under their sanitized name")
+	}
+}
+
+func TestCollectorScalesSummaries(t *testing.T) {
+	registry := metrics.NewRegistry()
+	latency := metrics.GetOrRegisterHistogram("request-latency-in-ms", registry, metrics.NewUniformSample(10))
+	latency.Update(250)
+	latency.Update(250)
+
+	family := gather(t, registry)["sarama_request_latency_seconds"]
+	if family == nil || family.GetType() != dto.MetricType_SUMMARY {
+		t.Fatal("Expected sarama_request_latency_seconds to be a summary, got", family)
+	}
+
+	summary := family.Metric[0].Summary
+	if summary.GetSampleCount() != 2 {
+		t.Error("Expected 2 samples, got", summary.GetSampleCount())
+	}
+	if summary.GetSampleSum() != 0.5 {
+		t.Error("Expected a sum of 0.5 seconds, got", summary.GetSampleSum())
+	}
+	if q := summary.Quantile[0]; q.GetQuantile() != 0.5 || q.GetValue() != 0.25 {
+		t.Error("Expected a median of 0.25 seconds, got", q)
+	}
+}
+
+func TestCollectorEndToEndLatency(t *testing.T) {
+	registry := metrics.NewRegistry()
+	latency := metrics.GetOrRegisterHistogram("consumer-end-to-end-latency-in-ms-for-topic-my_topic-partition-0", registry, metrics.NewUniformSample(10))
+	latency.Update(1500)
+
+	family := gather(t, registry)["sarama_topic_consumer_end_to_end_latency_seconds"]
+	if family == nil || family.GetType() != dto.MetricType_SUMMARY {
+		t.Fatal("Expected sarama_topic_consumer_end_to_end_latency_seconds to be a summary, got", family)
+	}
+	if summary := family.Metric[0].Summary; summary.GetSampleSum() != 1.5 {
+		t.Error("Expected a sum of 1.5 seconds, got", summary.GetSampleSum())
+	}
+	if labels := family.Metric[0].Label; len(labels) != 2 || labels[0].GetName() != "partition" || labels[1].GetValue() != "my_topic" {
+		t.Error("Expected partition and topic labels, got", labels)
+	}
+}
@@ -0,0 +1,60 @@
+package sarama
+
+// PushTelemetryRequest sends client metrics to a broker, as requested by the
+// subscription it returned to a GetTelemetrySubscriptionsRequest (KIP-714).
+type PushTelemetryRequest struct {
+	ClientInstanceId Uuid
+	SubscriptionId   int32
+	// Terminating is set on the last push of a client that is shutting down.
+	Terminating     bool
+	CompressionType CompressionCodec
+	// Metrics is an OpenTelemetry MetricsData protobuf message, compressed with
+	// CompressionType.
+	Metrics []byte
+}
+
+func (r *PushTelemetryRequest) encode(pe packetEncoder) error {
+	if err := putUuid(pe, r.ClientInstanceId); err != nil {
+		return err
+	}
+	pe.putInt32(r.SubscriptionId)
+	putBool(pe, r.Terminating)
+	pe.putInt8(int8(r.CompressionType))
+	if err := putCompactBytes(pe, r.Metrics); err != nil {
+		return err
+	}
+	return putEmptyTaggedFields(pe)
+}
+
+func (r *PushTelemetryRequest) decode(pd packetDecoder) (err error) {
+	if r.ClientInstanceId, err = getUuid(pd); err != nil {
+		return err
+	}
+	if r.SubscriptionId, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.Terminating, err = getBool(pd); err != nil {
+		return err
+	}
+	codec, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	r.CompressionType = CompressionCodec(codec)
+	if r.Metrics, err = getCompactBytes(pd); err != nil {
+		return err
+	}
+	return skipTaggedFields(pd)
+}
+
+func (r *PushTelemetryRequest) key() int16 {
+	return 72
+}
+
+func (r *PushTelemetryRequest) version() int16 {
+	return 0
+}
+
+func (r *PushTelemetryRequest) flexible() bool {
+	return true
+}
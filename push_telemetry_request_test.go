@@ -0,0 +1,25 @@
+package sarama
+
+import "testing"
+
+var (
+	pushTelemetryRequestTerminating = []byte{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // Client instance ID
+		0, 0, 0, 7, // Subscription ID
+		1,             // Terminating
+		4,             // Compression type
+		4, 0x0a, 1, 2, // Metrics
+		0, // Tagged fields
+	}
+)
+
+func TestPushTelemetryRequest(t *testing.T) {
+	request := &PushTelemetryRequest{
+		ClientInstanceId: Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		SubscriptionId:   7,
+		Terminating:      true,
+		CompressionType:  CompressionZSTD,
+		Metrics:          []byte{0x0a, 1, 2},
+	}
+	testRequest(t, "terminating", request, pushTelemetryRequestTerminating)
+}
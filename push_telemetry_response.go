@@ -0,0 +1,37 @@
+package sarama
+
+import "time"
+
+type PushTelemetryResponse struct {
+	ThrottleTime time.Duration
+	Err          KError
+}
+
+func (r *PushTelemetryResponse) encode(pe packetEncoder) error {
+	if err := putEmptyTaggedFields(pe); err != nil { // response header
+		return err
+	}
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	pe.putInt16(int16(r.Err))
+	return putEmptyTaggedFields(pe)
+}
+
+func (r *PushTelemetryResponse) decode(pd packetDecoder) (err error) {
+	if err = skipTaggedFields(pd); err != nil { // response header
+		return err
+	}
+
+	throttle, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(throttle) * time.Millisecond
+
+	kerr, err := pd.getInt16()
+	if err != nil {
+		return err
+	}
+	r.Err = KError(kerr)
+
+	return skipTaggedFields(pd)
+}
@@ -0,0 +1,24 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	pushTelemetryResponseTooLarge = []byte{
+		0,          // Header tagged fields
+		0, 0, 0, 3, // Throttle time
+		0, 118, // Error
+		0, // Tagged fields
+	}
+)
+
+func TestPushTelemetryResponse(t *testing.T) {
+	response := new(PushTelemetryResponse)
+	testDecodable(t, "too large", response, pushTelemetryResponseTooLarge)
+	if response.Err != ErrTelemetryTooLarge || response.ThrottleTime != 3*time.Millisecond {
+		t.Error("Decoding failed", response)
+	}
+	testResponse(t, "too large", response, pushTelemetryResponseTooLarge)
+}
@@ -0,0 +1,49 @@
+package sarama
+
+import (
+	"fmt"
+	"io"
+)
+
+// ReaderEncoder implements the Encoder interface for values that are available from an
+// io.Reader of known length, such as files or HTTP request bodies, so that they don't
+// have to be read into memory before the message is handed to the producer. Since the
+// length is known up front, size limits like Producer.MaxMessageBytes are checked without
+// reading anything.
+//
+// The data is read once, into a buffer of exactly the given length, the first time
+// Encode() is called; it then stays in memory until the message has been sent, and as a
+// large value it is written to the broker from that buffer without being copied again.
+// Reading fewer than length bytes is an error, as is a negative length.
+type ReaderEncoder struct {
+	r      io.Reader
+	length int
+
+	encoded []byte
+	err     error
+}
+
+// NewReaderEncoder returns an Encoder which reads exactly length bytes from r. With a
+// negative length, its Length is 0 and Encode returns an error.
+func NewReaderEncoder(r io.Reader, length int) *ReaderEncoder {
+	if length < 0 {
+		return &ReaderEncoder{r: r, err: fmt.Errorf("kafka: invalid length %d of message data", length)}
+	}
+	return &ReaderEncoder{r: r, length: length}
+}
+
+func (re *ReaderEncoder) Encode() ([]byte, error) {
+	if re.encoded == nil && re.err == nil {
+		buf := make([]byte, re.length)
+		if n, err := io.ReadFull(re.r, buf); err != nil {
+			re.err = fmt.Errorf("kafka: read %d of %d bytes of message data: %s", n, re.length, err)
+		} else {
+			re.encoded = buf
+		}
+	}
+	return re.encoded, re.err
+}
+
+func (re *ReaderEncoder) Length() int {
+	return re.length
+}
@@ -0,0 +1,43 @@
+package sarama
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReaderEncoder(t *testing.T) {
+	data := bytes.Repeat([]byte("abc"), 1000)
+	encoder := NewReaderEncoder(bytes.NewReader(data), len(data))
+
+	if encoder.Length() != len(data) {
+		t.Error("Length() reported", encoder.Length(), "but expected", len(data))
+	}
+	for i := 0; i < 2; i++ {
+		encoded, err := encoder.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Error("Encode() returned the wrong data on call", i)
+		}
+	}
+}
+
+func TestReaderEncoderShortRead(t *testing.T) {
+	encoder := NewReaderEncoder(bytes.NewReader([]byte("abc")), 4)
+
+	if _, err := encoder.Encode(); err == nil {
+		t.Error("Expected an error reading fewer bytes than the declared length")
+	}
+}
+
+func TestReaderEncoderNegativeLength(t *testing.T) {
+	encoder := NewReaderEncoder(bytes.NewReader([]byte("abc")), -1)
+
+	if encoder.Length() != 0 {
+		t.Error("Expected a negative length to be reported as 0, got", encoder.Length())
+	}
+	if _, err := encoder.Encode(); err == nil {
+		t.Error("Expected an error encoding data of a negative length")
+	}
+}
@@ -97,6 +97,19 @@ func (rd *realDecoder) getBytes() ([]byte, error) {
 	return tmpStr, nil
 }
 
+func (rd *realDecoder) getRawBytes(length int) ([]byte, error) {
+	if length < 0 {
+		return nil, PacketDecodingError{"invalid byteslice length"}
+	} else if length > rd.remaining() {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
+
+	start := rd.off
+	rd.off += length
+	return rd.raw[start:rd.off], nil
+}
+
 func (rd *realDecoder) getString() (string, error) {
 	tmp, err := rd.getInt16()
 
@@ -128,14 +141,9 @@ func (rd *realDecoder) getInt32Array() ([]int32, error) {
 		rd.off = len(rd.raw)
 		return nil, ErrInsufficientData
 	}
-	n := int(binary.BigEndian.Uint32(rd.raw[rd.off:]))
+	n := int(int32(binary.BigEndian.Uint32(rd.raw[rd.off:])))
 	rd.off += 4
 
-	if rd.remaining() < 4*n {
-		rd.off = len(rd.raw)
-		return nil, ErrInsufficientData
-	}
-
 	if n == 0 {
 		return nil, nil
 	}
@@ -144,6 +152,11 @@ func (rd *realDecoder) getInt32Array() ([]int32, error) {
 		return nil, PacketDecodingError{"invalid array length"}
 	}
 
+	if rd.remaining() < 4*n {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
+
 	ret := make([]int32, n)
 	for i := range ret {
 		ret[i] = int32(binary.BigEndian.Uint32(rd.raw[rd.off:]))
@@ -157,14 +170,9 @@ func (rd *realDecoder) getInt64Array() ([]int64, error) {
 		rd.off = len(rd.raw)
 		return nil, ErrInsufficientData
 	}
-	n := int(binary.BigEndian.Uint32(rd.raw[rd.off:]))
+	n := int(int32(binary.BigEndian.Uint32(rd.raw[rd.off:])))
 	rd.off += 4
 
-	if rd.remaining() < 8*n {
-		rd.off = len(rd.raw)
-		return nil, ErrInsufficientData
-	}
-
 	if n == 0 {
 		return nil, nil
 	}
@@ -173,6 +181,11 @@ func (rd *realDecoder) getInt64Array() ([]int64, error) {
 		return nil, PacketDecodingError{"invalid array length"}
 	}
 
+	if rd.remaining() < 8*n {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
+
 	ret := make([]int64, n)
 	for i := range ret {
 		ret[i] = int64(binary.BigEndian.Uint64(rd.raw[rd.off:]))
@@ -186,7 +199,7 @@ func (rd *realDecoder) getStringArray() ([]string, error) {
 		rd.off = len(rd.raw)
 		return nil, ErrInsufficientData
 	}
-	n := int(binary.BigEndian.Uint32(rd.raw[rd.off:]))
+	n := int(int32(binary.BigEndian.Uint32(rd.raw[rd.off:])))
 	rd.off += 4
 
 	if n == 0 {
@@ -197,6 +210,12 @@ func (rd *realDecoder) getStringArray() ([]string, error) {
 		return nil, PacketDecodingError{"invalid array length"}
 	}
 
+	// every string takes at least its two length bytes
+	if rd.remaining() < 2*n {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
+
 	ret := make([]string, n)
 	for i := range ret {
 		if str, err := rd.getString(); err != nil {
@@ -214,6 +233,15 @@ func (rd *realDecoder) remaining() int {
 	return len(rd.raw) - rd.off
 }
 
+// peekInt8 returns the byte at the given offset from the current position,
+// without consuming anything.
+func (rd *realDecoder) peekInt8(offset int) (int8, error) {
+	if offset < 0 || rd.remaining() <= offset {
+		return -1, ErrInsufficientData
+	}
+	return int8(rd.raw[rd.off+offset]), nil
+}
+
 func (rd *realDecoder) getSubset(length int) (packetDecoder, error) {
 	if length < 0 {
 		return nil, PacketDecodingError{"invalid subset size"}
@@ -0,0 +1,122 @@
+package sarama
+
+import "time"
+
+// RecordHeader is a key-value pair attached to a record, from Kafka 0.11. The
+// value may be nil.
+type RecordHeader struct {
+	Key   []byte
+	Value []byte
+}
+
+// Record is a message in a RecordBatch, the message format of Kafka 0.11 and
+// later. Its offset and timestamp are stored relative to those of its batch.
+type Record struct {
+	Attributes     int8
+	TimestampDelta time.Duration
+	OffsetDelta    int64
+	Key            []byte
+	Value          []byte
+	Headers        []*RecordHeader
+}
+
+// bodySize returns the size of the encoded record after its length.
+func (r *Record) bodySize() int {
+	size := 1 // attributes
+	size += varintSize(int64(r.TimestampDelta / time.Millisecond))
+	size += varintSize(r.OffsetDelta)
+	size += varintBytesSize(r.Key)
+	size += varintBytesSize(r.Value)
+	size += varintSize(int64(len(r.Headers)))
+	for _, header := range r.Headers {
+		size += varintBytesSize(header.Key)
+		size += varintBytesSize(header.Value)
+	}
+	return size
+}
+
+func (r *Record) encode(pe packetEncoder) error {
+	if err := putVarint(pe, int64(r.bodySize())); err != nil {
+		return err
+	}
+	pe.putInt8(r.Attributes)
+	if err := putVarint(pe, int64(r.TimestampDelta/time.Millisecond)); err != nil {
+		return err
+	}
+	if err := putVarint(pe, r.OffsetDelta); err != nil {
+		return err
+	}
+	if err := putVarintBytes(pe, r.Key); err != nil {
+		return err
+	}
+	if err := putVarintBytes(pe, r.Value); err != nil {
+		return err
+	}
+	if err := putVarint(pe, int64(len(r.Headers))); err != nil {
+		return err
+	}
+	for _, header := range r.Headers {
+		if err := putVarintBytes(pe, header.Key); err != nil {
+			return err
+		}
+		if err := putVarintBytes(pe, header.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Record) decode(pd packetDecoder) (err error) {
+	length, err := getVarint(pd)
+	if err != nil {
+		return err
+	}
+	if length < 0 {
+		return PacketDecodingError{"invalid record length"}
+	}
+	body, err := pd.getSubset(int(length))
+	if err != nil {
+		return err
+	}
+
+	if r.Attributes, err = body.getInt8(); err != nil {
+		return err
+	}
+	timestampDelta, err := getVarint(body)
+	if err != nil {
+		return err
+	}
+	r.TimestampDelta = time.Duration(timestampDelta) * time.Millisecond
+	if r.OffsetDelta, err = getVarint(body); err != nil {
+		return err
+	}
+	if r.Key, err = getVarintBytes(body); err != nil {
+		return err
+	}
+	if r.Value, err = getVarintBytes(body); err != nil {
+		return err
+	}
+
+	n, err := getVarint(body)
+	if err != nil {
+		return err
+	}
+	if n < 0 || n > int64(body.remaining()) {
+		return PacketDecodingError{"invalid header count"}
+	}
+	r.Headers = nil
+	if n > 0 {
+		r.Headers = make([]*RecordHeader, n)
+	}
+	for i := range r.Headers {
+		header := new(RecordHeader)
+		if header.Key, err = getVarintBytes(body); err != nil {
+			return err
+		}
+		if header.Value, err = getVarintBytes(body); err != nil {
+			return err
+		}
+		r.Headers[i] = header
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package sarama
+
+import (
+	"fmt"
+	"time"
+)
+
+// recordBatchVersion is the magic byte of record batches, which follows the
+// batch's offset, length and leader epoch where the legacy message format has
+// the CRC and magic byte of its first message.
+const recordBatchVersion int8 = 2
+
+// recordBatchMagicOffset is where the magic byte of a message set entry or
+// record batch is, from its start.
+const recordBatchMagicOffset = 16
+
+const (
+	timestampTypeMask = 0x08
+	transactionalMask = 0x10
+	controlMask       = 0x20
+)
+
+// RecordBatch is a batch of records, the message format of Kafka 0.11 and later,
+// which replaces message sets in produce requests of version 3 and fetch
+// responses of version 4. Unlike messages, records have headers, and the whole
+// batch is compressed together.
+type RecordBatch struct {
+	FirstOffset          int64
+	PartitionLeaderEpoch int32
+	Codec                CompressionCodec
+	// CompressionLevel is the level passed to the codec when compressing, as for
+	// Message.
+	CompressionLevel int
+	// Control batches hold the transaction markers of the transactional producer
+	// rather than messages.
+	Control       bool
+	Transactional bool
+	// LogAppendTime is whether the timestamps are the time the broker appended
+	// the batch, which is then MaxTimestamp, rather than when it was created.
+	LogAppendTime   bool
+	LastOffsetDelta int32
+	FirstTimestamp  time.Time
+	MaxTimestamp    time.Time
+	// ProducerID, ProducerEpoch and FirstSequence identify the batches of
+	// idempotent and transactional producers; they are -1 otherwise.
+	ProducerID    int64
+	ProducerEpoch int16
+	FirstSequence int32
+	Records       []*Record
+
+	compressedRecords []byte
+	// retainCompressed keeps compressedRecords after encoding, for batches whose
+	// records are known not to change between sends
+	retainCompressed bool
+	// decompressedSize is the size the records of a compressed batch
+	// decompressed to
+	decompressedSize int
+}
+
+// newRecordBatch returns an empty batch which is not from an idempotent producer.
+func newRecordBatch(codec CompressionCodec) *RecordBatch {
+	return &RecordBatch{Codec: codec, ProducerID: -1, ProducerEpoch: -1, FirstSequence: -1}
+}
+
+// addRecord appends a record at the next offset, with the given timestamp.
+func (b *RecordBatch) addRecord(r *Record, timestamp time.Time) {
+	if len(b.Records) == 0 {
+		b.FirstTimestamp = timestamp
+	}
+	r.OffsetDelta = int64(len(b.Records))
+	r.TimestampDelta = timestamp.Sub(b.FirstTimestamp)
+	if r.TimestampDelta < 0 {
+		r.TimestampDelta = 0
+	}
+	if timestamp.After(b.MaxTimestamp) {
+		b.MaxTimestamp = timestamp
+	}
+	b.LastOffsetDelta = int32(len(b.Records))
+	b.Records = append(b.Records, r)
+}
+
+// LastOffset returns the offset of the last record in the batch, which
+// compaction may have removed.
+func (b *RecordBatch) LastOffset() int64 {
+	return b.FirstOffset + int64(b.LastOffsetDelta)
+}
+
+// Offset returns the offset of r, one of the records of the batch.
+func (b *RecordBatch) Offset(r *Record) int64 {
+	return b.FirstOffset + r.OffsetDelta
+}
+
+// Timestamp returns the timestamp of r, one of the records of the batch.
+func (b *RecordBatch) Timestamp(r *Record) time.Time {
+	if b.LogAppendTime || b.FirstTimestamp.IsZero() {
+		return b.MaxTimestamp
+	}
+	return b.FirstTimestamp.Add(r.TimestampDelta)
+}
+
+func (b *RecordBatch) attributes() int16 {
+	attributes := int16(b.Codec) & int16(compressionCodecMask)
+	if b.LogAppendTime {
+		attributes |= timestampTypeMask
+	}
+	if b.Transactional {
+		attributes |= transactionalMask
+	}
+	if b.Control {
+		attributes |= controlMask
+	}
+	return attributes
+}
+
+func (b *RecordBatch) encode(pe packetEncoder) error {
+	pe.putInt64(b.FirstOffset)
+	pe.push(&lengthField{})
+	pe.putInt32(b.PartitionLeaderEpoch)
+	pe.putInt8(recordBatchVersion)
+	pe.push(newCRC32Field(crcCastagnoli))
+	pe.putInt16(b.attributes())
+	pe.putInt32(b.LastOffsetDelta)
+	pe.putInt64(timestampMillis(b.FirstTimestamp))
+	pe.putInt64(timestampMillis(b.MaxTimestamp))
+	pe.putInt64(b.ProducerID)
+	pe.putInt16(b.ProducerEpoch)
+	pe.putInt32(b.FirstSequence)
+	if err := pe.putArrayLength(len(b.Records)); err != nil {
+		return err
+	}
+
+	if b.Codec == CompressionNone {
+		if err := recordsEncoder(b.Records).encode(pe); err != nil {
+			return err
+		}
+	} else {
+		// the encoding is computed twice, so the records are compressed once
+		// and kept for the second pass, as Message does
+		var payload []byte
+		if b.compressedRecords != nil {
+			payload = b.compressedRecords
+			if !b.retainCompressed {
+				b.compressedRecords = nil
+			}
+		} else {
+			// the records are only needed until they are compressed
+			buf := getEncodeBuffer()
+			raw, err := encodeInto(recordsEncoder(b.Records), *buf, MaxRequestSize)
+			if err != nil {
+				return err
+			}
+			b.compressedRecords, err = compress(b.Codec, b.CompressionLevel, false, raw)
+			b.decompressedSize = len(raw)
+			putEncodeBuffer(buf, raw)
+			if err != nil {
+				return err
+			}
+			payload = b.compressedRecords
+		}
+		if err := pe.putRawBytes(payload); err != nil {
+			return err
+		}
+	}
+
+	if err := pe.pop(); err != nil {
+		return err
+	}
+	return pe.pop()
+}
+
+func (b *RecordBatch) decode(pd packetDecoder) (err error) {
+	if b.FirstOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	length, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	batch, err := pd.getSubset(int(length))
+	if err != nil {
+		return err
+	}
+
+	if b.PartitionLeaderEpoch, err = batch.getInt32(); err != nil {
+		return err
+	}
+	version, err := batch.getInt8()
+	if err != nil {
+		return err
+	}
+	if version != recordBatchVersion {
+		return PacketDecodingError{fmt.Sprintf("unexpected record batch version %d", version)}
+	}
+	if err = batch.push(newCRC32Field(crcCastagnoli)); err != nil {
+		return err
+	}
+
+	attributes, err := batch.getInt16()
+	if err != nil {
+		return err
+	}
+	b.Codec = CompressionCodec(int8(attributes) & compressionCodecMask)
+	b.LogAppendTime = attributes&timestampTypeMask != 0
+	b.Transactional = attributes&transactionalMask != 0
+	b.Control = attributes&controlMask != 0
+
+	if b.LastOffsetDelta, err = batch.getInt32(); err != nil {
+		return err
+	}
+	firstTimestamp, err := batch.getInt64()
+	if err != nil {
+		return err
+	}
+	b.FirstTimestamp = millisTimestamp(firstTimestamp)
+	maxTimestamp, err := batch.getInt64()
+	if err != nil {
+		return err
+	}
+	b.MaxTimestamp = millisTimestamp(maxTimestamp)
+	if b.ProducerID, err = batch.getInt64(); err != nil {
+		return err
+	}
+	if b.ProducerEpoch, err = batch.getInt16(); err != nil {
+		return err
+	}
+	if b.FirstSequence, err = batch.getInt32(); err != nil {
+		return err
+	}
+	// the count is not checked against the bytes left, which compressed
+	// records may well take fewer of
+	count, err := batch.getInt32()
+	if err != nil {
+		return err
+	}
+
+	records := batch
+	if b.Codec != CompressionNone {
+		raw, err := batch.getRawBytes(batch.remaining())
+		if err != nil {
+			return err
+		}
+		decompressed, err := decompress(b.Codec, raw)
+		if err != nil {
+			return err
+		}
+		b.decompressedSize = len(decompressed)
+		records = &realDecoder{raw: decompressed}
+	}
+	if count < 0 || int(count) > records.remaining() {
+		return PacketDecodingError{fmt.Sprintf("invalid record count %d", count)}
+	}
+	b.Records = nil
+	if count > 0 {
+		b.Records = make([]*Record, count)
+	}
+	for i := range b.Records {
+		record := new(Record)
+		if err := record.decode(records); err != nil {
+			if err == ErrInsufficientData {
+				// the batch is complete, so its records have to be too
+				return PacketDecodingError{"truncated record"}
+			}
+			return err
+		}
+		b.Records[i] = record
+	}
+
+	return batch.pop()
+}
+
+// recordsEncoder encodes records one after the other, as they are in batches
+// before compression.
+type recordsEncoder []*Record
+
+func (rs recordsEncoder) encode(pe packetEncoder) error {
+	for _, r := range rs {
+		if err := r.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timestampMillis returns t in milliseconds since the epoch, or -1 for the
+// zero time, as timestamps are sent.
+func timestampMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return -1
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// millisTimestamp is the inverse of timestampMillis.
+func millisTimestamp(millis int64) time.Time {
+	if millis < 0 {
+		return time.Time{}
+	}
+	return time.Unix(millis/1000, (millis%1000)*int64(time.Millisecond))
+}
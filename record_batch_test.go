@@ -0,0 +1,108 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var oneRecordBatch = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // first offset
+	0x00, 0x00, 0x00, 0x39, // length
+	0x00, 0x00, 0x00, 0x00, // partition leader epoch
+	0x02,                   // magic
+	0xAE, 0x59, 0xB0, 0x8F, // CRC
+	0x00, 0x00, // attributes
+	0x00, 0x00, 0x00, 0x00, // last offset delta
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xE8, // first timestamp
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xE8, // max timestamp
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // producer ID
+	0xFF, 0xFF, // producer epoch
+	0xFF, 0xFF, 0xFF, 0xFF, // first sequence
+	0x00, 0x00, 0x00, 0x01, // records
+	0x0E, 0x00, 0x00, 0x00, 0x01, 0x02, 'v', 0x00}
+
+func TestRecordBatchEncoding(t *testing.T) {
+	batch := newRecordBatch(CompressionNone)
+	batch.addRecord(&Record{Value: []byte("v")}, time.Unix(1, 0))
+	testEncodable(t, "one record", batch, oneRecordBatch)
+
+	decoded := new(RecordBatch)
+	testDecodable(t, "one record", decoded, oneRecordBatch)
+	if !reflect.DeepEqual(decoded, batch) {
+		t.Errorf("Decoding produced %#v, expected %#v", decoded, batch)
+	}
+}
+
+func TestRecordBatchOffsetsAndTimestamps(t *testing.T) {
+	first := time.Unix(100, 0)
+	batch := newRecordBatch(CompressionNone)
+	batch.FirstOffset = 10
+	batch.addRecord(&Record{Value: []byte("a")}, first)
+	batch.addRecord(&Record{Value: []byte("b")}, first.Add(time.Second))
+	batch.addRecord(&Record{Value: []byte("c")}, first.Add(-time.Second))
+
+	if batch.LastOffset() != 12 {
+		t.Error("Expected the last offset to be 12, got", batch.LastOffset())
+	}
+	if !batch.MaxTimestamp.Equal(first.Add(time.Second)) {
+		t.Error("Expected the max timestamp to be that of the second record, got", batch.MaxTimestamp)
+	}
+	for i, record := range batch.Records {
+		if batch.Offset(record) != int64(10+i) {
+			t.Errorf("Expected record %d at offset %d, got %d", i, 10+i, batch.Offset(record))
+		}
+	}
+	if ts := batch.Timestamp(batch.Records[1]); !ts.Equal(first.Add(time.Second)) {
+		t.Error("Expected the timestamp of the second record to be a second after the first, got", ts)
+	}
+
+	batch.LogAppendTime = true
+	if ts := batch.Timestamp(batch.Records[0]); !ts.Equal(batch.MaxTimestamp) {
+		t.Error("Expected records appended at log append time to have the max timestamp, got", ts)
+	}
+}
+
+func TestRecordBatchCompressionRoundTrip(t *testing.T) {
+	for _, codec := range []CompressionCodec{CompressionGZIP, CompressionSnappy, CompressionLZ4} {
+		batch := newRecordBatch(codec)
+		for i := 0; i < 10; i++ {
+			record := &Record{Key: []byte("key"), Value: []byte("value"), Headers: []*RecordHeader{{Key: []byte("h")}}}
+			batch.addRecord(record, time.Unix(int64(i), 0))
+		}
+		buf, err := encode(batch)
+		if err != nil {
+			t.Fatal(codec, err)
+		}
+
+		decoded := new(RecordBatch)
+		testDecodable(t, "compressed", decoded, buf)
+		if decoded.Codec != codec || len(decoded.Records) != 10 {
+			t.Fatalf("Expected 10 records compressed with %s, got %d with %s", codec, len(decoded.Records), decoded.Codec)
+		}
+		if !reflect.DeepEqual(decoded.Records, batch.Records) {
+			t.Error("Decoding produced different records with", codec)
+		}
+		if decoded.decompressedSize == 0 {
+			t.Error("Expected the decompressed size of the records with", codec)
+		}
+	}
+}
+
+func TestRecordBatchDecodingErrors(t *testing.T) {
+	corrupt := append([]byte(nil), oneRecordBatch...)
+	corrupt[len(corrupt)-2] = 'w'
+	if err := decode(corrupt, new(RecordBatch)); err == nil {
+		t.Error("Expected an error from a batch with a bad CRC")
+	}
+
+	version := append([]byte(nil), oneRecordBatch...)
+	version[recordBatchMagicOffset] = 1
+	if err := decode(version, new(RecordBatch)); err == nil {
+		t.Error("Expected an error from a batch of version 1")
+	}
+
+	if err := decode(oneRecordBatch[:len(oneRecordBatch)-1], new(RecordBatch)); err != ErrInsufficientData {
+		t.Error("Expected ErrInsufficientData from a truncated batch, got", err)
+	}
+}
@@ -0,0 +1,60 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var recordWithHeader = []byte{
+	0x18,      // length
+	0x00,      // attributes
+	0x0A,      // timestamp delta
+	0x02,      // offset delta
+	0x02, 'k', // key
+	0x02, 'v', // value
+	0x02,      // headers
+	0x02, 'h', // header key
+	0x02, 'x'} // header value
+
+func TestRecordEncoding(t *testing.T) {
+	record := &Record{
+		TimestampDelta: 5 * time.Millisecond,
+		OffsetDelta:    1,
+		Key:            []byte("k"),
+		Value:          []byte("v"),
+		Headers:        []*RecordHeader{{Key: []byte("h"), Value: []byte("x")}},
+	}
+	testEncodable(t, "with header", record, recordWithHeader)
+
+	decoded := new(Record)
+	testDecodable(t, "with header", decoded, recordWithHeader)
+	if !reflect.DeepEqual(decoded, record) {
+		t.Errorf("Decoding produced %#v, expected %#v", decoded, record)
+	}
+}
+
+func TestRecordNullKeyAndValue(t *testing.T) {
+	buf, err := encode(&Record{OffsetDelta: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(Record)
+	testDecodable(t, "null", decoded, buf)
+	if decoded.Key != nil || decoded.Value != nil || decoded.Headers != nil || decoded.OffsetDelta != -1 {
+		t.Errorf("Decoding produced %#v from a record without a key, value or headers", decoded)
+	}
+}
+
+func TestRecordDecodingErrors(t *testing.T) {
+	truncated := recordWithHeader[:len(recordWithHeader)-1]
+	if err := decode(truncated, new(Record)); err != ErrInsufficientData {
+		t.Error("Expected ErrInsufficientData from a truncated record, got", err)
+	}
+
+	tooManyHeaders := append([]byte(nil), recordWithHeader...)
+	tooManyHeaders[8] = 0x7E
+	if err := decode(tooManyHeaders, new(Record)); err == nil {
+		t.Error("Expected an error from a record with more headers than bytes")
+	}
+}
@@ -0,0 +1,357 @@
+package sarama
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+)
+
+// RecordedExchange is a request that a RecordingProxy forwarded to the broker,
+// and the broker's response.
+type RecordedExchange struct {
+	APIKey     int16
+	APIVersion int16
+	// Request is the request as the client sent it, without its length.
+	Request []byte
+	// Response is the response without its length and correlation ID, or nil if
+	// the broker didn't respond, as to produce requests that require no acks.
+	Response []byte
+}
+
+// Recording is the traffic between clients and a broker that a RecordingProxy
+// recorded, which a MockBroker can replay with SetHandlerByRecording.
+type Recording struct {
+	// Addr is the address of the proxy, which is the address the recorded
+	// metadata and coordinator responses give for the broker.
+	Addr string
+	// Exchanges are in the order the requests were sent in.
+	Exchanges []RecordedExchange
+}
+
+// Save writes the recording to the file at path, typically under testdata.
+func (r *Recording) Save(path string) error {
+	buf, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf, 0644)
+}
+
+// LoadRecording reads a recording written by Recording.Save.
+func LoadRecording(path string) (*Recording, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	recording := new(Recording)
+	if err := json.Unmarshal(buf, recording); err != nil {
+		return nil, err
+	}
+	return recording, nil
+}
+
+// RecordingProxy sits between clients and a real broker, for recording
+// protocol-level bugs in a regression test that doesn't need a Kafka cluster.
+// Every connection to the proxy is forwarded to the broker, and every request
+// and response it carries is recorded, so that a MockBroker can replay them
+// with SetHandlerByRecording.
+//
+// So that clients keep talking to the proxy, it rewrites the address of the
+// broker in metadata and coordinator responses to its own. The upstream address
+// must therefore be the one the broker advertises. Clients connect directly to
+// the other brokers of the cluster, unrecorded, so the cluster is best made of
+// a single broker or the client is best limited to topics that broker leads.
+type RecordingProxy struct {
+	t         TestReporter
+	upstream  string
+	listener  net.Listener
+	closing   chan none
+	wg        sync.WaitGroup
+	lock      sync.Mutex
+	exchanges []RecordedExchange
+}
+
+// NewRecordingProxy starts a proxy to the broker at upstream on a
+// kernel-selected localhost port. Errors are reported to t.
+func NewRecordingProxy(t TestReporter, upstream string) *RecordingProxy {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &RecordingProxy{
+		t:        t,
+		upstream: upstream,
+		listener: listener,
+		closing:  make(chan none),
+	}
+	Logger.Printf("*** recordingproxy: forwarding %s to %s\n", p.Addr(), upstream)
+
+	p.wg.Add(1)
+	go p.serverLoop()
+	return p
+}
+
+// Addr returns the address the proxy listens on, to give to NewClient.
+func (p *RecordingProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Recording returns the traffic recorded so far.
+func (p *RecordingProxy) Recording() *Recording {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	recording := &Recording{Addr: p.Addr(), Exchanges: make([]RecordedExchange, len(p.exchanges))}
+	copy(recording.Exchanges, p.exchanges)
+	return recording
+}
+
+// Close stops the proxy and closes the connections it forwards.
+func (p *RecordingProxy) Close() {
+	close(p.closing)
+	_ = p.listener.Close()
+	p.wg.Wait()
+}
+
+func (p *RecordingProxy) serverLoop() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.wg.Add(1)
+		go p.handleConn(conn)
+	}
+}
+
+func (p *RecordingProxy) handleConn(client net.Conn) {
+	defer p.wg.Done()
+
+	server, err := net.Dial("tcp", p.upstream)
+	if err != nil {
+		p.t.Error(err)
+		_ = client.Close()
+		return
+	}
+	closeBoth := func() {
+		_ = client.Close()
+		_ = server.Close()
+	}
+
+	done := make(chan none)
+	go func() {
+		select {
+		case <-p.closing:
+		case <-done:
+		}
+		closeBoth()
+	}()
+
+	// pending indexes the exchanges awaiting a response by correlation ID, which
+	// is only unique within a connection.
+	pending := make(map[int32]int)
+	responsesDone := make(chan none)
+	go func() {
+		defer close(responsesDone)
+		defer closeBoth()
+		p.forwardResponses(server, client, pending)
+	}()
+
+	p.forwardRequests(client, server, pending)
+	closeBoth()
+	<-responsesDone
+	close(done)
+}
+
+func (p *RecordingProxy) forwardRequests(client, server net.Conn, pending map[int32]int) {
+	for {
+		frame, err := readFrame(client, MaxRequestSize)
+		if err != nil {
+			return
+		}
+		if len(frame) < 8 {
+			p.t.Errorf("recordingproxy: request of length %d too short", len(frame))
+			return
+		}
+
+		p.lock.Lock()
+		pending[int32(binary.BigEndian.Uint32(frame[4:]))] = len(p.exchanges)
+		p.exchanges = append(p.exchanges, RecordedExchange{
+			APIKey:     int16(binary.BigEndian.Uint16(frame)),
+			APIVersion: int16(binary.BigEndian.Uint16(frame[2:])),
+			Request:    frame,
+		})
+		p.lock.Unlock()
+
+		if err := writeFrame(server, frame); err != nil {
+			return
+		}
+	}
+}
+
+func (p *RecordingProxy) forwardResponses(server, client net.Conn, pending map[int32]int) {
+	for {
+		frame, err := readFrame(server, MaxResponseSize)
+		if err != nil {
+			return
+		}
+		if len(frame) < 4 {
+			p.t.Errorf("recordingproxy: response of length %d too short", len(frame))
+			return
+		}
+
+		correlationID := int32(binary.BigEndian.Uint32(frame))
+		p.lock.Lock()
+		idx, ok := pending[correlationID]
+		if ok {
+			delete(pending, correlationID)
+			exchange := &p.exchanges[idx]
+			exchange.Response = rewriteBrokerAddr(exchange.APIKey, frame[4:], p.upstream, p.Addr())
+			frame = append(frame[:4:4], exchange.Response...)
+		}
+		p.lock.Unlock()
+		if !ok {
+			p.t.Errorf("recordingproxy: response to unknown correlation ID %d", correlationID)
+		}
+
+		if err := writeFrame(client, frame); err != nil {
+			return
+		}
+	}
+}
+
+// SetHandlerByRecording makes the broker replay a recording: it answers each
+// request with the response to the same request in the recording, or else to
+// the first request of the same API key and version not replayed yet. Requests
+// that were not answered when recorded are not answered either, and requests
+// the recording has none left for are reported as errors. Metadata and
+// coordinator responses give the address of the broker for that of the proxy.
+func (b *MockBroker) SetHandlerByRecording(recording *Recording) {
+	replayed := make([]bool, len(recording.Exchanges))
+	b.setHandler(func(req *request) (res encoder) {
+		fingerprint, err := requestFingerprint(req)
+		if err != nil {
+			b.t.Error(err)
+			return nil
+		}
+
+		match := -1
+		for i, exchange := range recording.Exchanges {
+			if replayed[i] || exchange.APIKey != req.body.key() || exchange.APIVersion != req.body.version() {
+				continue
+			}
+			if match < 0 {
+				match = i
+			}
+			if len(exchange.Request) >= 8 && bytes.Equal(exchange.Request[8:], fingerprint[8:]) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			b.t.Errorf("mockbroker/%d: no recorded response left for %T", b.brokerID, req.body)
+			return nil
+		}
+
+		replayed[match] = true
+		response := recording.Exchanges[match].Response
+		if response == nil {
+			return nil
+		}
+		return rawResponse(rewriteBrokerAddr(req.body.key(), response, recording.Addr, b.Addr()))
+	})
+}
+
+// rawResponse is a response replayed as recorded.
+type rawResponse []byte
+
+func (r rawResponse) encode(pe packetEncoder) error {
+	return pe.putRawBytes(r)
+}
+
+// requestFingerprint encodes a request the way it is recorded, without its
+// length, to compare it with recorded requests but for its correlation ID.
+func requestFingerprint(req *request) ([]byte, error) {
+	buf, err := encode(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < 12 {
+		return nil, fmt.Errorf("request of length %d too short", len(buf))
+	}
+	return buf[4:], nil
+}
+
+// rewriteBrokerAddr replaces the address from with to in the metadata and
+// coordinator responses among the response body of the given API key. Any other
+// response, or one that fails to decode, is returned as is.
+func rewriteBrokerAddr(apiKey int16, body []byte, from, to string) []byte {
+	var (
+		response encoder
+		brokers  []*Broker
+	)
+	switch apiKey {
+	case 3:
+		metadata := new(MetadataResponse)
+		if err := decode(body, metadata); err != nil {
+			return body
+		}
+		response, brokers = metadata, metadata.Brokers
+	case 10:
+		coordinator := new(ConsumerMetadataResponse)
+		if err := decode(body, coordinator); err != nil || coordinator.Coordinator == nil {
+			return body
+		}
+		response, brokers = coordinator, []*Broker{coordinator.Coordinator}
+	default:
+		return body
+	}
+
+	rewritten := false
+	for _, broker := range brokers {
+		if broker.addr == from {
+			broker.addr = to
+			rewritten = true
+		}
+	}
+	if !rewritten {
+		return body
+	}
+	buf, err := encode(response)
+	if err != nil {
+		return body
+	}
+	return buf
+}
+
+// readFrame reads a length-prefixed frame from r, returning it without its
+// length.
+func readFrame(r io.Reader, maxLength int32) ([]byte, error) {
+	lengthBytes := make([]byte, 4)
+	if _, err := io.ReadFull(r, lengthBytes); err != nil {
+		return nil, err
+	}
+	length := int32(binary.BigEndian.Uint32(lengthBytes))
+	if length < 0 || length > maxLength {
+		return nil, PacketDecodingError{fmt.Sprintf("frame of length %d too large or too small", length)}
+	}
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func writeFrame(w io.Writer, frame []byte) error {
+	buf := make([]byte, 4+len(frame))
+	binary.BigEndian.PutUint32(buf, uint32(len(frame)))
+	copy(buf[4:], frame)
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,140 @@
+package sarama
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingProxyRecordsAndReplays(t *testing.T) {
+	upstream := NewMockBroker(t, 1)
+	upstream.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(upstream.Addr(), upstream.BrokerID()).
+			SetLeader("my_topic", 0, upstream.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234),
+	})
+	proxy := NewRecordingProxy(t, upstream.Addr())
+
+	client, err := NewClient([]string{proxy.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, err := client.GetOffset("my_topic", 0, OffsetNewest); err != nil || offset != 1234 {
+		t.Fatal("Expected the offset of the broker through the proxy, got", offset, err)
+	}
+	safeClose(t, client)
+	proxy.Close()
+	upstream.Close()
+
+	recording := proxy.Recording()
+	keys := make(map[int16]int)
+	for _, exchange := range recording.Exchanges {
+		keys[exchange.APIKey]++
+		if exchange.Response == nil {
+			t.Errorf("Expected the response to the request of key %d to be recorded", exchange.APIKey)
+		}
+	}
+	if keys[3] == 0 || keys[2] != 1 {
+		t.Fatal("Expected the metadata and offset requests to be recorded, got", keys)
+	}
+	if len(upstream.History()) != len(recording.Exchanges) {
+		t.Errorf("Expected every request to go through the proxy, %d went to the broker and %d through the proxy", len(upstream.History()), len(recording.Exchanges))
+	}
+
+	dir, err := ioutil.TempDir("", "sarama-recording")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "recording.json")
+	if err := recording.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Addr != proxy.Addr() || len(loaded.Exchanges) != len(recording.Exchanges) {
+		t.Fatal("Expected the recording to be loaded as saved, got", loaded)
+	}
+
+	replay := NewMockBroker(t, 1)
+	defer replay.Close()
+	replay.SetHandlerByRecording(loaded)
+
+	client, err = NewClient([]string{replay.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+	leader, err := client.Leader("my_topic", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leader.Addr() != replay.Addr() {
+		t.Error("Expected the replayed metadata to give the address of the replaying broker, got", leader.Addr())
+	}
+	if offset, err := client.GetOffset("my_topic", 0, OffsetNewest); err != nil || offset != 1234 {
+		t.Error("Expected the recorded offset to be replayed, got", offset, err)
+	}
+}
+
+type replayReporter struct {
+	errors []string
+}
+
+func (r *replayReporter) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *replayReporter) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *replayReporter) Fatal(args ...interface{}) {
+	r.Error(args...)
+}
+
+func (r *replayReporter) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func TestRecordingReplayReportsUnrecordedRequests(t *testing.T) {
+	reporter := new(replayReporter)
+	replay := NewMockBroker(reporter, 1)
+	replay.SetHandlerByRecording(&Recording{Exchanges: []RecordedExchange{
+		{APIKey: 3, APIVersion: 0, Response: []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+	}})
+
+	broker := NewBroker(replay.Addr())
+	if err := broker.Open(nil); err != nil {
+		t.Fatal(err)
+	}
+	if response, err := broker.GetMetadata(new(MetadataRequest)); err != nil || len(response.Brokers) != 0 {
+		t.Error("Expected the recorded metadata to be replayed, got", response, err)
+	}
+	if len(reporter.errors) != 0 {
+		t.Error("Expected no errors, got", reporter.errors)
+	}
+
+	conf := NewConfig()
+	conf.Net.ReadTimeout = 100 * time.Millisecond
+	_ = broker.Close()
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(new(MetadataRequest)); err == nil {
+		t.Error("Expected the request the recording has no response left for to time out")
+	}
+	safeClose(t, broker)
+	replay.Close()
+
+	if len(reporter.errors) != 1 {
+		t.Error("Expected the unrecorded request to be reported, got", reporter.errors)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"reflect"
+	"strings"
 )
 
 type requestBody interface {
@@ -13,6 +15,32 @@ type requestBody interface {
 	version() int16
 }
 
+// flexibleBody is implemented by request bodies whose version is flexible in the
+// sense of KIP-482: their request header ends with tagged fields, and so does
+// the header of their response, which their response's decode has to skip.
+type flexibleBody interface {
+	flexible() bool
+}
+
+func isFlexible(body requestBody) bool {
+	f, ok := body.(flexibleBody)
+	return ok && f.flexible()
+}
+
+// downgradableBody is implemented by request bodies that mean the same at every
+// version from minVersion up to the one they were built at, so that a broker
+// which supports only older versions can be sent the newest of those instead.
+type downgradableBody interface {
+	minVersion() int16
+	setVersion(version int16)
+}
+
+// versionedResponse is implemented by the responses to downgradable requests,
+// whose version has to follow that of the request they answer.
+type versionedResponse interface {
+	setVersion(version int16)
+}
+
 type request struct {
 	correlationID int32
 	clientID      string
@@ -28,6 +56,11 @@ func (r *request) encode(pe packetEncoder) (err error) {
 	if err != nil {
 		return err
 	}
+	if isFlexible(r.body) {
+		if err = putEmptyTaggedFields(pe); err != nil {
+			return err
+		}
+	}
 	err = r.body.encode(pe)
 	if err != nil {
 		return err
@@ -47,12 +80,19 @@ func (r *request) decode(pd packetDecoder) (err error) {
 	if r.correlationID, err = pd.getInt32(); err != nil {
 		return err
 	}
-	r.clientID, err = pd.getString()
+	if r.clientID, err = pd.getString(); err != nil {
+		return err
+	}
 
 	r.body = allocateBody(key, version)
 	if r.body == nil {
 		return PacketDecodingError{fmt.Sprintf("unknown request key (%d)", key)}
 	}
+	if isFlexible(r.body) {
+		if err = skipTaggedFields(pd); err != nil {
+			return err
+		}
+	}
 	return r.body.decode(pd)
 }
 
@@ -82,19 +122,19 @@ func decodeRequest(r io.Reader) (req *request, err error) {
 func allocateBody(key, version int16) requestBody {
 	switch key {
 	case 0:
-		return &ProduceRequest{}
+		return &ProduceRequest{Version: version}
 	case 1:
-		return &FetchRequest{}
+		return &FetchRequest{Version: version}
 	case 2:
-		return &OffsetRequest{}
+		return &OffsetRequest{Version: version}
 	case 3:
-		return &MetadataRequest{}
+		return &MetadataRequest{Version: version}
 	case 8:
 		return &OffsetCommitRequest{Version: version}
 	case 9:
-		return &OffsetFetchRequest{}
+		return &OffsetFetchRequest{Version: version}
 	case 10:
-		return &ConsumerMetadataRequest{}
+		return &ConsumerMetadataRequest{Version: version}
 	case 11:
 		return &JoinGroupRequest{}
 	case 12:
@@ -107,6 +147,91 @@ func allocateBody(key, version int16) requestBody {
 		return &DescribeGroupsRequest{}
 	case 16:
 		return &ListGroupsRequest{}
+	case 17:
+		return &SaslHandshakeRequest{Version: version}
+	case 18:
+		return &ApiVersionsRequest{}
+	case 19:
+		return &CreateTopicsRequest{Version: version}
+	case 20:
+		return &DeleteTopicsRequest{Version: version}
+	case 21:
+		return &DeleteRecordsRequest{}
+	case 22:
+		return &InitProducerIDRequest{}
+	case 24:
+		return &AddPartitionsToTxnRequest{}
+	case 25:
+		return &AddOffsetsToTxnRequest{}
+	case 26:
+		return &EndTxnRequest{}
+	case 28:
+		return &TxnOffsetCommitRequest{}
+	case 29:
+		return &DescribeAclsRequest{}
+	case 30:
+		return &CreateAclsRequest{}
+	case 31:
+		return &DeleteAclsRequest{}
+	case 32:
+		return &DescribeConfigsRequest{}
+	case 33:
+		return &AlterConfigsRequest{}
+	case 36:
+		return &SaslAuthenticateRequest{}
+	case 37:
+		return &CreatePartitionsRequest{}
+	case 71:
+		return &GetTelemetrySubscriptionsRequest{}
+	case 72:
+		return &PushTelemetryRequest{}
 	}
 	return nil
 }
+
+// supportedVersions is the newest version of each API that Sarama can encode and
+// decode, by key, which mock brokers advertise in their ApiVersionsResponses.
+var supportedVersions = map[int16]int16{
+	0:  7,  // Produce
+	1:  11, // Fetch
+	2:  1,  // Offset
+	3:  1,  // Metadata
+	8:  2,  // OffsetCommit
+	9:  2,  // OffsetFetch
+	10: 1,  // ConsumerMetadata
+	11: 0,  // JoinGroup
+	12: 0,  // Heartbeat
+	13: 0,  // LeaveGroup
+	14: 0,  // SyncGroup
+	15: 0,  // DescribeGroups
+	16: 0,  // ListGroups
+	17: 1,  // SaslHandshake
+	18: 0,  // ApiVersions
+	19: 2,  // CreateTopics
+	20: 1,  // DeleteTopics
+	21: 0,  // DeleteRecords
+	22: 0,  // InitProducerID
+	24: 0,  // AddPartitionsToTxn
+	25: 0,  // AddOffsetsToTxn
+	26: 0,  // EndTxn
+	28: 0,  // TxnOffsetCommit
+	29: 0,  // DescribeAcls
+	30: 0,  // CreateAcls
+	31: 0,  // DeleteAcls
+	32: 0,  // DescribeConfigs
+	33: 0,  // AlterConfigs
+	36: 0,  // SaslAuthenticate
+	37: 0,  // CreatePartitions
+	71: 0,  // GetTelemetrySubscriptions
+	72: 0,  // PushTelemetry
+}
+
+// apiName returns the name of the API with the given key, after its request
+// type (for example "Metadata" for MetadataRequest), for logging.
+func apiName(key, version int16) string {
+	body := allocateBody(key, version)
+	if body == nil {
+		return fmt.Sprintf("unknown(%d)", key)
+	}
+	return strings.TrimSuffix(reflect.TypeOf(body).Elem().Name(), "Request")
+}
@@ -45,6 +45,9 @@ func testRequest(t *testing.T, name string, rb requestBody, expected []byte) {
 	req := &request{correlationID: 123, clientID: "foo", body: rb}
 	packet, err := encode(req)
 	headerSize := 14 + len("foo")
+	if isFlexible(rb) {
+		headerSize++ // the header's tagged fields
+	}
 	if err != nil {
 		t.Error(err)
 	} else if !bytes.Equal(packet[headerSize:], expected) {
@@ -78,3 +81,31 @@ func testResponse(t *testing.T, name string, res encoder, expected []byte) {
 		t.Errorf("Decoded response does not match the encoded one\nencoded: %#v\ndecoded: %#v", res, decoded)
 	}
 }
+
+func TestEncodeIntoReusesBuffer(t *testing.T) {
+	req := &request{correlationID: 123, clientID: "foo", body: &MetadataRequest{Topics: []string{"foo"}}}
+	expected, err := encode(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 0, 128)
+	packet, err := encodeInto(req, buf, MaxRequestSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet, expected) {
+		t.Error("encodeInto produced", packet, "but encode produced", expected)
+	}
+	if &packet[0] != &buf[:1][0] {
+		t.Error("encodeInto allocated despite a large enough buffer")
+	}
+
+	packet, err = encodeInto(req, make([]byte, 0, 2), MaxRequestSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet, expected) {
+		t.Error("encodeInto with a small buffer produced", packet, "but encode produced", expected)
+	}
+}
@@ -19,7 +19,9 @@ import (
 
 // Logger is the instance of a StdLogger interface that Sarama writes connection
 // management events to. By default it is set to discard all log messages via ioutil.Discard,
-// but you can set it to redirect wherever you want.
+// but you can set it to redirect wherever you want. Events are written as
+// "subsystem: message key=value ...", filtered by MinLogLevel; set StructuredLog to receive
+// them with their level and fields instead.
 var Logger StdLogger = log.New(ioutil.Discard, "[Sarama] ", log.LstdFlags)
 
 // StdLogger is used to log error messages.
@@ -36,12 +38,25 @@ var PanicHandler func(interface{})
 // MaxRequestSize is the maximum size (in bytes) of any request that Sarama will attempt to send. Trying
 // to send a request larger than this will result in an PacketEncodingError. The default of 100 MiB is aligned
 // with Kafka's default `socket.request.max.bytes`, which is the largest request the broker will attempt
-// to process.
+// to process. Net.MaxRequestSize lowers it for the clients of a Config.
 var MaxRequestSize int32 = 100 * 1024 * 1024
 
 // MaxResponseSize is the maximum size (in bytes) of any response that Sarama will attempt to parse. If
 // a broker returns a response message larger than this value, Sarama will return a PacketDecodingError to
 // protect the client from running out of memory. Please note that brokers do not have any natural limit on
 // the size of responses they send. In particular, they can send arbitrarily large fetch responses to consumers
-// (see https://issues.apache.org/jira/browse/KAFKA-2063).
+// (see https://issues.apache.org/jira/browse/KAFKA-2063). Net.MaxResponseSize lowers it for the clients of a
+// Config.
 var MaxResponseSize int32 = 100 * 1024 * 1024
+
+// MaxDecompressedBatchSize is the maximum size (in bytes) that Sarama will allow any single compressed message
+// set to decompress to. Decompression stops, and ErrDecompressedSizeExceeded is returned, as soon as the limit
+// is passed, which protects the client from "decompression bombs" that would otherwise expand a small fetch
+// response into an arbitrarily large amount of memory. Set it to 0 to disable the check.
+var MaxDecompressedBatchSize int32 = 100 * 1024 * 1024
+
+// MaxDecompressedResponseSize is the maximum total size (in bytes) that the compressed message sets of a single
+// fetch response may decompress to, returning ErrDecompressedSizeExceeded when exceeded. Since it is checked as
+// each message set is decompressed, the actual peak can exceed it by up to MaxDecompressedBatchSize. Set it to 0
+// to disable the check.
+var MaxDecompressedResponseSize int32 = 500 * 1024 * 1024
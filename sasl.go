@@ -0,0 +1,190 @@
+package sarama
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SASLMechanism is the name of a SASL mechanism to authenticate with (see
+// Config.Net.SASL).
+type SASLMechanism string
+
+const (
+	// SASLTypePlaintext is the PLAIN mechanism, which sends the user and
+	// password as they are, so should only be used over TLS.
+	SASLTypePlaintext SASLMechanism = "PLAIN"
+	// SASLTypeSCRAMSHA256 is the SCRAM-SHA-256 mechanism of RFC 7677, which
+	// proves the password without sending it. It requires Kafka 0.10.2.
+	SASLTypeSCRAMSHA256 SASLMechanism = "SCRAM-SHA-256"
+	// SASLTypeSCRAMSHA512 is the SCRAM-SHA-512 mechanism, as SCRAM-SHA-256
+	// with SHA-512.
+	SASLTypeSCRAMSHA512 SASLMechanism = "SCRAM-SHA-512"
+)
+
+// saslSession is the client side of the exchange of tokens of a SASL mechanism.
+type saslSession interface {
+	// next returns the token answering the broker's last challenge, which is
+	// nil before the first token, or done once the mechanism is complete.
+	next(challenge []byte) (token []byte, done bool, err error)
+}
+
+func newSASLSession(conf *Config) (saslSession, error) {
+	sasl := conf.Net.SASL
+	switch sasl.Mechanism {
+	case SASLTypeSCRAMSHA256:
+		return newSCRAMSession(sha256.New, sasl.User, sasl.Password)
+	case SASLTypeSCRAMSHA512:
+		return newSCRAMSession(sha512.New, sasl.User, sasl.Password)
+	default:
+		return &plainSession{authIdentity: sasl.AuthIdentity, user: sasl.User, password: sasl.Password}, nil
+	}
+}
+
+// plainSession sends a single token of the identity, user and password, as in
+// RFC 4616.
+type plainSession struct {
+	authIdentity, user, password string
+	sent                         bool
+}
+
+func (s *plainSession) next(challenge []byte) ([]byte, bool, error) {
+	if s.sent {
+		return nil, true, nil
+	}
+	s.sent = true
+	return []byte(s.authIdentity + "\x00" + s.user + "\x00" + s.password), false, nil
+}
+
+// scramSession is the client of RFC 5802: it sends its first message, answers
+// the broker's salt and iterations with the proof of the password, and checks
+// the broker's signature in return. Usernames are sent as they are, without
+// SASLprep, as the JVM client does.
+type scramSession struct {
+	hash           func() hash.Hash
+	user, password string
+	nonce          string
+	step           int
+
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAMSession(h func() hash.Hash, user, password string) (*scramSession, error) {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &scramSession{hash: h, user: user, password: password, nonce: base64.StdEncoding.EncodeToString(nonce)}, nil
+}
+
+func (s *scramSession) next(challenge []byte) ([]byte, bool, error) {
+	s.step++
+	switch s.step {
+	case 1:
+		user := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(s.user)
+		s.clientFirstBare = "n=" + user + ",r=" + s.nonce
+		return []byte("n,," + s.clientFirstBare), false, nil
+	case 2:
+		token, err := s.clientFinal(string(challenge))
+		return token, false, err
+	default:
+		return nil, true, s.verify(string(challenge))
+	}
+}
+
+// scramMaxIterations bounds the iterations of the Hi function a broker may ask
+// for, so that a broker, or whoever impersonates it, cannot make the client
+// spin on the CPU for minutes. Kafka itself caps them at 16384.
+const scramMaxIterations = 1 << 20
+
+// clientFinal answers the server-first-message with the client-final-message.
+func (s *scramSession) clientFinal(serverFirst string) ([]byte, error) {
+	attrs := scramAttributes(serverFirst)
+	if msg, ok := attrs["e"]; ok {
+		LogBroker.error("SCRAM authentication failed", "err", msg)
+		return nil, ErrSASLAuthenticationFailed
+	}
+	nonce := attrs["r"]
+	salt, err := base64.StdEncoding.DecodeString(attrs["s"])
+	if err != nil || len(salt) == 0 {
+		return nil, ErrInvalidSASLChallenge
+	}
+	iterations, err := strconv.Atoi(attrs["i"])
+	if err != nil || iterations <= 0 || iterations > scramMaxIterations || !strings.HasPrefix(nonce, s.nonce) || len(nonce) == len(s.nonce) {
+		return nil, ErrInvalidSASLChallenge
+	}
+
+	salted := scramHi(s.hash, []byte(s.password), salt, iterations)
+	clientKey := s.hmac(salted, "Client Key")
+	h := s.hash()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	// "biws" is the GS2 header "n,," of the first message, base64 encoded
+	clientFinalWithoutProof := "c=biws,r=" + nonce
+	authMessage := s.clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof
+	proof := s.hmac(storedKey, authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	s.serverSignature = s.hmac(s.hmac(salted, "Server Key"), authMessage)
+
+	return []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verify checks the server signature of the server-final-message.
+func (s *scramSession) verify(serverFinal string) error {
+	attrs := scramAttributes(serverFinal)
+	if msg, ok := attrs["e"]; ok {
+		LogBroker.error("SCRAM authentication failed", "err", msg)
+		return ErrSASLAuthenticationFailed
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, s.serverSignature) {
+		return ErrInvalidSASLChallenge
+	}
+	return nil
+}
+
+func (s *scramSession) hmac(key []byte, msg string) []byte {
+	mac := hmac.New(s.hash, key)
+	mac.Write([]byte(msg))
+	return mac.Sum(nil)
+}
+
+// scramHi is the Hi function of RFC 5802, PBKDF2 with HMAC as the
+// pseudorandom function and the size of the hash as the key length.
+func scramHi(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	mac := hmac.New(h, password)
+	mac.Write(salt)
+	mac.Write([]byte{0, 0, 0, 1})
+	u := mac.Sum(nil)
+	result := append([]byte(nil), u...)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range result {
+			result[j] ^= u[j]
+		}
+	}
+	return result
+}
+
+// scramAttributes parses the comma separated attribute=value pairs of a SCRAM
+// message.
+func scramAttributes(msg string) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range strings.Split(msg, ",") {
+		if i := strings.Index(attr, "="); i > 0 {
+			attrs[attr[:i]] = attr[i+1:]
+		}
+	}
+	return attrs
+}
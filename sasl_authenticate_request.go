@@ -0,0 +1,24 @@
+package sarama
+
+// SaslAuthenticateRequest carries a token of the SASL mechanism agreed on by a
+// version 1 SaslHandshakeRequest. It requires Kafka 1.0.
+type SaslAuthenticateRequest struct {
+	SaslAuthBytes []byte
+}
+
+func (r *SaslAuthenticateRequest) encode(pe packetEncoder) error {
+	return pe.putBytes(r.SaslAuthBytes)
+}
+
+func (r *SaslAuthenticateRequest) decode(pd packetDecoder) (err error) {
+	r.SaslAuthBytes, err = pd.getBytes()
+	return err
+}
+
+func (r *SaslAuthenticateRequest) key() int16 {
+	return 36
+}
+
+func (r *SaslAuthenticateRequest) version() int16 {
+	return 0
+}
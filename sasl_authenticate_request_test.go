@@ -0,0 +1,14 @@
+package sarama
+
+import "testing"
+
+var (
+	saslAuthenticateRequest = []byte{
+		0x00, 0x00, 0x00, 0x03, 'f', 'o', 'o', // SaslAuthBytes
+	}
+)
+
+func TestSaslAuthenticateRequest(t *testing.T) {
+	request := &SaslAuthenticateRequest{SaslAuthBytes: []byte("foo")}
+	testRequest(t, "basic", request, saslAuthenticateRequest)
+}
@@ -0,0 +1,32 @@
+package sarama
+
+import "testing"
+
+var (
+	saslAuthenticateResponseNoError = []byte{
+		0x00, 0x00, // ErrNoError
+		0xFF, 0xFF, // null ErrorMessage
+		0x00, 0x00, 0x00, 0x03, 'f', 'o', 'o', // SaslAuthBytes
+	}
+
+	saslAuthenticateResponseFailed = []byte{
+		0x00, 0x3A, // ErrSASLAuthenticationFailed
+		0x00, 0x03, 'b', 'a', 'd', // ErrorMessage
+		0x00, 0x00, 0x00, 0x00, // SaslAuthBytes
+	}
+)
+
+func TestSaslAuthenticateResponse(t *testing.T) {
+	response := new(SaslAuthenticateResponse)
+	testDecodable(t, "no error", response, saslAuthenticateResponseNoError)
+	if response.Err != ErrNoError || response.ErrorMessage != "" || string(response.SaslAuthBytes) != "foo" {
+		t.Error("Decoding produced an unexpected response", response)
+	}
+
+	response = new(SaslAuthenticateResponse)
+	testDecodable(t, "failed", response, saslAuthenticateResponseFailed)
+	if response.Err != ErrSASLAuthenticationFailed || response.ErrorMessage != "bad" || len(response.SaslAuthBytes) != 0 {
+		t.Error("Decoding produced an unexpected response", response)
+	}
+	testResponse(t, "failed", response, saslAuthenticateResponseFailed)
+}
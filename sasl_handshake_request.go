@@ -0,0 +1,35 @@
+package sarama
+
+// SaslHandshakeRequest asks the broker to authenticate the connection with a
+// SASL mechanism. After version 0 the tokens of the mechanism are exchanged raw,
+// each prefixed by its length; after version 1 they are exchanged in
+// SaslAuthenticateRequests.
+type SaslHandshakeRequest struct {
+	Version   int16
+	Mechanism string
+}
+
+func (r *SaslHandshakeRequest) encode(pe packetEncoder) error {
+	return pe.putString(r.Mechanism)
+}
+
+func (r *SaslHandshakeRequest) decode(pd packetDecoder) (err error) {
+	r.Mechanism, err = pd.getString()
+	return err
+}
+
+func (r *SaslHandshakeRequest) key() int16 {
+	return 17
+}
+
+func (r *SaslHandshakeRequest) version() int16 {
+	return r.Version
+}
+
+func (r *SaslHandshakeRequest) minVersion() int16 {
+	return 0
+}
+
+func (r *SaslHandshakeRequest) setVersion(version int16) {
+	r.Version = version
+}
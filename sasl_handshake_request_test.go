@@ -0,0 +1,17 @@
+package sarama
+
+import "testing"
+
+var (
+	saslHandshakeRequest = []byte{
+		0, 5, 'P', 'L', 'A', 'I', 'N', // Mechanism
+	}
+)
+
+func TestSaslHandshakeRequest(t *testing.T) {
+	request := &SaslHandshakeRequest{Mechanism: "PLAIN"}
+	testRequest(t, "v0", request, saslHandshakeRequest)
+
+	request = &SaslHandshakeRequest{Version: 1, Mechanism: "PLAIN"}
+	testRequest(t, "v1", request, saslHandshakeRequest)
+}
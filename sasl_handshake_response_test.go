@@ -0,0 +1,27 @@
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	saslHandshakeResponse = []byte{
+		0x00, 0x21, // ErrUnsupportedSASLMechanism
+		0x00, 0x00, 0x00, 0x02, // 2 mechanisms
+		0, 5, 'P', 'L', 'A', 'I', 'N',
+		0, 13, 'S', 'C', 'R', 'A', 'M', '-', 'S', 'H', 'A', '-', '2', '5', '6',
+	}
+)
+
+func TestSaslHandshakeResponse(t *testing.T) {
+	response := new(SaslHandshakeResponse)
+	testDecodable(t, "unsupported mechanism", response, saslHandshakeResponse)
+	if response.Err != ErrUnsupportedSASLMechanism {
+		t.Error("Decoding error failed: ErrUnsupportedSASLMechanism expected but found", response.Err)
+	}
+	if !reflect.DeepEqual(response.EnabledMechanisms, []string{"PLAIN", "SCRAM-SHA-256"}) {
+		t.Error("Decoding produced unexpected mechanisms", response.EnabledMechanisms)
+	}
+	testResponse(t, "unsupported mechanism", response, saslHandshakeResponse)
+}
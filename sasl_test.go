@@ -0,0 +1,89 @@
+package sarama
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func TestPlainSession(t *testing.T) {
+	session := &plainSession{authIdentity: "admin", user: "user", password: "pencil"}
+	token, done, err := session.next(nil)
+	if err != nil || done || string(token) != "admin\x00user\x00pencil" {
+		t.Errorf("Expected a single token of the identity, user and password, got %q %v %v", token, done, err)
+	}
+	if _, done, err := session.next(nil); err != nil || !done {
+		t.Error("Expected the session to be done after one token, got", done, err)
+	}
+}
+
+// the example exchange of RFC 7677
+const (
+	scramClientNonce = "rOprNGfwEbeRWgbNEkqO"
+	scramServerFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	scramClientFinal = "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	scramServerFinal = "v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4="
+)
+
+func newTestSCRAMSession(t *testing.T) saslSession {
+	session, err := newSCRAMSession(sha256.New, "user", "pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.nonce = scramClientNonce
+	return session
+}
+
+func TestSCRAMSession(t *testing.T) {
+	session := newTestSCRAMSession(t)
+	token, done, err := session.next(nil)
+	if err != nil || done || string(token) != "n,,n=user,r="+scramClientNonce {
+		t.Fatalf("Expected the client-first-message, got %q %v %v", token, done, err)
+	}
+	token, done, err = session.next([]byte(scramServerFirst))
+	if err != nil || done || string(token) != scramClientFinal {
+		t.Fatalf("Expected the client-final-message, got %q %v %v", token, done, err)
+	}
+	if _, done, err := session.next([]byte(scramServerFinal)); err != nil || !done {
+		t.Error("Expected the server signature to be verified, got", done, err)
+	}
+}
+
+func TestSCRAMSessionRejectsInvalidChallenges(t *testing.T) {
+	session := newTestSCRAMSession(t)
+	_, _, _ = session.next(nil)
+	if _, _, err := session.next([]byte("r=someoneelse,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")); err != ErrInvalidSASLChallenge {
+		t.Error("Expected a nonce not extending the client's to be rejected, got", err)
+	}
+
+	session = newTestSCRAMSession(t)
+	_, _, _ = session.next(nil)
+	tooMany := strings.Replace(scramServerFirst, "i=4096", "i=1048577", 1)
+	if _, _, err := session.next([]byte(tooMany)); err != ErrInvalidSASLChallenge {
+		t.Error("Expected an iteration count above the cap to be rejected, got", err)
+	}
+
+	session = newTestSCRAMSession(t)
+	_, _, _ = session.next(nil)
+	_, _, _ = session.next([]byte(scramServerFirst))
+	if _, _, err := session.next([]byte("v=bm90IHRoZSBzaWduYXR1cmU=")); err != ErrInvalidSASLChallenge {
+		t.Error("Expected a wrong server signature to be rejected, got", err)
+	}
+
+	session = newTestSCRAMSession(t)
+	_, _, _ = session.next(nil)
+	if _, _, err := session.next([]byte("e=unknown-user")); err != ErrSASLAuthenticationFailed {
+		t.Error("Expected a server error to fail the authentication, got", err)
+	}
+}
+
+func TestSCRAMSessionEscapesUsernames(t *testing.T) {
+	session, err := newSCRAMSession(sha256.New, "a=b,c", "pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.nonce = scramClientNonce
+	if token, _, _ := session.next(nil); string(token) != "n,,n=a=3Db=2Cc,r="+scramClientNonce {
+		t.Errorf("Expected the username to be escaped, got %q", token)
+	}
+}
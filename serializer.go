@@ -0,0 +1,97 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Serializer converts values into the bytes of a message key or value. It is the extension
+// point for schema-aware formats such as those backed by a Confluent-style Schema Registry:
+// an implementation would typically look up or register the schema for the value's type,
+// encode the value with it, and frame the result with AppendSchemaFrame. Sarama does not
+// ship any registry client itself; implementations are expected to live in external packages.
+type Serializer interface {
+	// Serialize encodes v for the given topic. isKey reports whether the result will be used
+	// as the message key, since registries usually keep separate subjects for keys and values.
+	Serialize(topic string, isKey bool, v interface{}) ([]byte, error)
+}
+
+// Deserializer is the consuming counterpart of Serializer, decoding data into v.
+type Deserializer interface {
+	Deserialize(topic string, isKey bool, data []byte, v interface{}) error
+}
+
+// SerializerEncoder adapts a Serializer to the Encoder interface so that its output can be
+// used as the Key or Value of a ProducerMessage. As with JSONEncoder the value is serialized
+// at most once, on the first call to either Length() or Encode().
+type SerializerEncoder struct {
+	serializer Serializer
+	topic      string
+	isKey      bool
+	v          interface{}
+
+	encoded []byte
+	err     error
+}
+
+// NewSerializerEncoder returns an Encoder which serializes v with the given Serializer. The
+// topic and isKey arguments are passed through to Serialize, and should match the topic and
+// field of the ProducerMessage the encoder is used in.
+func NewSerializerEncoder(serializer Serializer, topic string, isKey bool, v interface{}) *SerializerEncoder {
+	return &SerializerEncoder{serializer: serializer, topic: topic, isKey: isKey, v: v}
+}
+
+func (se *SerializerEncoder) ensureEncoded() {
+	if se.encoded == nil && se.err == nil {
+		se.encoded, se.err = se.serializer.Serialize(se.topic, se.isKey, se.v)
+	}
+}
+
+func (se *SerializerEncoder) Encode() ([]byte, error) {
+	se.ensureEncoded()
+	return se.encoded, se.err
+}
+
+func (se *SerializerEncoder) Length() int {
+	se.ensureEncoded()
+	return len(se.encoded)
+}
+
+// DeserializeKey decodes the key of the message into v using the given Deserializer.
+func (m *ConsumerMessage) DeserializeKey(deserializer Deserializer, v interface{}) error {
+	return deserializer.Deserialize(m.Topic, true, m.Key, v)
+}
+
+// DeserializeValue decodes the value of the message into v using the given Deserializer.
+func (m *ConsumerMessage) DeserializeValue(deserializer Deserializer, v interface{}) error {
+	return deserializer.Deserialize(m.Topic, false, m.Value, v)
+}
+
+// schemaFrameMagic is the leading byte of the Schema Registry wire format, which is followed
+// by the schema ID as a big-endian int32 and then the encoded payload.
+const (
+	schemaFrameMagic      byte = 0
+	schemaFrameHeaderSize      = 5
+)
+
+// AppendSchemaFrame appends the Schema Registry wire format framing for the given schema ID,
+// followed by payload, to dst and returns the extended slice.
+func AppendSchemaFrame(dst []byte, schemaID int32, payload []byte) []byte {
+	var header [schemaFrameHeaderSize]byte
+	header[0] = schemaFrameMagic
+	binary.BigEndian.PutUint32(header[1:], uint32(schemaID))
+	dst = append(dst, header[:]...)
+	return append(dst, payload...)
+}
+
+// ParseSchemaFrame splits data framed with the Schema Registry wire format into the schema ID
+// and the encoded payload. The payload aliases data.
+func ParseSchemaFrame(data []byte) (schemaID int32, payload []byte, err error) {
+	if len(data) < schemaFrameHeaderSize {
+		return -1, nil, PacketDecodingError{fmt.Sprintf("schema frame too short (%d bytes)", len(data))}
+	}
+	if data[0] != schemaFrameMagic {
+		return -1, nil, PacketDecodingError{fmt.Sprintf("unknown schema frame magic byte (%d)", data[0])}
+	}
+	return int32(binary.BigEndian.Uint32(data[1:schemaFrameHeaderSize])), data[schemaFrameHeaderSize:], nil
+}
@@ -0,0 +1,72 @@
+package sarama
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// jsonSchemaSerializer frames JSON with a fixed schema ID, standing in for a real registry client
+type jsonSchemaSerializer struct {
+	schemaID int32
+}
+
+func (s jsonSchemaSerializer) Serialize(topic string, isKey bool, v interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return AppendSchemaFrame(nil, s.schemaID, payload), nil
+}
+
+func (s jsonSchemaSerializer) Deserialize(topic string, isKey bool, data []byte, v interface{}) error {
+	_, payload, err := ParseSchemaFrame(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
+func TestSchemaFrame(t *testing.T) {
+	framed := AppendSchemaFrame(nil, 258, []byte("payload"))
+	if !bytes.Equal(framed, []byte{0, 0, 0, 1, 2, 'p', 'a', 'y', 'l', 'o', 'a', 'd'}) {
+		t.Error("Unexpected framing:", framed)
+	}
+
+	schemaID, payload, err := ParseSchemaFrame(framed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schemaID != 258 || string(payload) != "payload" {
+		t.Error("Unexpected parse result:", schemaID, string(payload))
+	}
+
+	if _, _, err := ParseSchemaFrame([]byte{0, 0, 0}); err == nil {
+		t.Error("Expected an error parsing a truncated frame")
+	}
+	if _, _, err := ParseSchemaFrame([]byte{1, 0, 0, 0, 0}); err == nil {
+		t.Error("Expected an error parsing a frame with the wrong magic byte")
+	}
+}
+
+func TestSerializerEncoder(t *testing.T) {
+	serializer := jsonSchemaSerializer{schemaID: 7}
+	encoder := NewSerializerEncoder(serializer, "my_topic", false, jsonTestRecord{Name: "foo", Count: 3})
+
+	encoded, err := encoder.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoder.Length() != len(encoded) {
+		t.Error("Length() does not match the encoded value")
+	}
+
+	var decoded jsonTestRecord
+	msg := &ConsumerMessage{Topic: "my_topic", Value: encoded}
+	if err := msg.DeserializeValue(serializer, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "foo" || decoded.Count != 3 {
+		t.Error("Unexpected decoded value:", decoded)
+	}
+}
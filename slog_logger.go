@@ -0,0 +1,24 @@
+//go:build go1.21
+// +build go1.21
+
+package sarama
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogLogger returns a StructuredLogger writing to the given slog.Logger, for
+// use as StructuredLog or with SetSubsystemLogger. Sarama's log levels map onto
+// the slog levels of the same name.
+func NewSlogLogger(logger *slog.Logger) StructuredLogger {
+	return slogLogger{logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (sl slogLogger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	sl.logger.Log(context.Background(), slog.Level(level), msg, keyvals...)
+}
@@ -0,0 +1,25 @@
+//go:build go1.21
+// +build go1.21
+
+package sarama
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	logger.Log(LogLevelWarn, "broker state change", "subsystem", "producer", "broker", int32(3))
+
+	line := buf.String()
+	for _, want := range []string{"level=WARN", `msg="broker state change"`, "subsystem=producer", "broker=3"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected %q in %q", want, line)
+		}
+	}
+}
@@ -11,12 +11,19 @@ var snappyMagic = []byte{130, 83, 78, 65, 80, 80, 89, 0}
 
 // SnappyEncode encodes binary data
 func snappyEncode(src []byte) []byte {
-	return snappy.Encode(nil, src)
+	// snappy needs room for the worst case, so the block is compressed into a
+	// pooled buffer and copied out at its actual size
+	buf := getEncodeBuffer()
+	encoded := snappy.Encode((*buf)[:cap(*buf)], src)
+	out := make([]byte, len(encoded))
+	copy(out, encoded)
+	putEncodeBuffer(buf, encoded)
+	return out
 }
 
-// SnappyDecode decodes snappy data
-func snappyDecode(src []byte) ([]byte, error) {
-	if bytes.Equal(src[:8], snappyMagic) {
+// SnappyDecode decodes snappy data of at most limit bytes
+func snappyDecode(src []byte, limit int64) ([]byte, error) {
+	if bytes.HasPrefix(src, snappyMagic) {
 		var (
 			pos   = uint32(16)
 			max   = uint32(len(src))
@@ -25,9 +32,18 @@ func snappyDecode(src []byte) ([]byte, error) {
 			err   error
 		)
 		for pos < max {
+			if max-pos < 4 {
+				return nil, snappy.ErrCorrupt
+			}
 			size := binary.BigEndian.Uint32(src[pos : pos+4])
 			pos += 4
+			if max-pos < size {
+				return nil, snappy.ErrCorrupt
+			}
 
+			if err = checkSnappyDecodedLen(src[pos:pos+size], limit-int64(len(dst))); err != nil {
+				return nil, err
+			}
 			chunk, err = snappy.Decode(chunk, src[pos:pos+size])
 			if err != nil {
 				return nil, err
@@ -37,5 +53,21 @@ func snappyDecode(src []byte) ([]byte, error) {
 		}
 		return dst, nil
 	}
+	if err := checkSnappyDecodedLen(src, limit); err != nil {
+		return nil, err
+	}
 	return snappy.Decode(nil, src)
 }
+
+// checkSnappyDecodedLen checks the length recorded in the block header before
+// anything is allocated for it.
+func checkSnappyDecodedLen(block []byte, limit int64) error {
+	n, err := snappy.DecodedLen(block)
+	if err != nil {
+		return err
+	}
+	if int64(n) > limit {
+		return ErrDecompressedSizeExceeded
+	}
+	return nil
+}
@@ -28,7 +28,7 @@ func TestSnappyEncode(t *testing.T) {
 
 func TestSnappyDecode(t *testing.T) {
 	for exp, src := range snappyTestCases {
-		dst, err := snappyDecode(src)
+		dst, err := snappyDecode(src, maxDecompressedBatchBytes())
 		if err != nil {
 			t.Error("Encoding error: ", err)
 		} else if !bytes.Equal(dst, []byte(exp)) {
@@ -39,7 +39,7 @@ func TestSnappyDecode(t *testing.T) {
 
 func TestSnappyDecodeStreams(t *testing.T) {
 	for exp, src := range snappyStreamTestCases {
-		dst, err := snappyDecode(src)
+		dst, err := snappyDecode(src, maxDecompressedBatchBytes())
 		if err != nil {
 			t.Error("Encoding error: ", err)
 		} else if !bytes.Equal(dst, []byte(exp)) {
@@ -0,0 +1,351 @@
+package sarama
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// BrokerSnapshot is the state of a broker known to a client.
+type BrokerSnapshot struct {
+	ID   int32  `json:"id"`
+	Addr string `json:"addr"`
+	// Open is true if the broker is connected or connecting.
+	Open bool `json:"open"`
+	// RequestsInFlight is the number of requests awaiting a response, as recorded
+	// in the requests-in-flight-for-broker-<id> metric.
+	RequestsInFlight int64 `json:"requests_in_flight"`
+}
+
+// CoordinatorSnapshot is the cached coordinator of a consumer group.
+type CoordinatorSnapshot struct {
+	Group    string `json:"group"`
+	BrokerID int32  `json:"broker_id"`
+	Addr     string `json:"addr"`
+}
+
+// ClientSnapshot is the state of a Client.
+type ClientSnapshot struct {
+	Closed       bool                  `json:"closed"`
+	SeedBrokers  []string              `json:"seed_brokers"`
+	Brokers      []BrokerSnapshot      `json:"brokers"`
+	Coordinators []CoordinatorSnapshot `json:"coordinators"`
+	// Topics maps the topics with cached metadata to their number of partitions.
+	Topics map[string]int `json:"topics"`
+}
+
+// TopicPartitionError is a partition with the error that affects it.
+type TopicPartitionError struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Err       string `json:"error"`
+}
+
+// ProducerBrokerSnapshot is the state of the part of a producer sending to one
+// broker.
+type ProducerBrokerSnapshot struct {
+	ID   int32  `json:"id"`
+	Addr string `json:"addr"`
+	// BufferedMessages and BufferedBytes are the size of the batch being built.
+	BufferedMessages int64 `json:"buffered_messages"`
+	BufferedBytes    int64 `json:"buffered_bytes"`
+	// RequestsInFlight is the number of produce requests awaiting a response.
+	RequestsInFlight int `json:"requests_in_flight"`
+	// Closing is the error that made the producer abandon the broker's
+	// connection, in which case all its messages are being retried.
+	Closing string `json:"closing,omitempty"`
+	// Retrying lists the partitions whose messages are being retried, and are
+	// held back until the retries are done.
+	Retrying []TopicPartitionError `json:"retrying"`
+}
+
+// ProducerSnapshot is the state of an AsyncProducer or SyncProducer.
+type ProducerSnapshot struct {
+	// MessagesInFlight is the number of messages accepted by the producer that
+	// have not yet been returned as a success or an error.
+	MessagesInFlight int64                    `json:"messages_in_flight"`
+	Brokers          []ProducerBrokerSnapshot `json:"brokers"`
+}
+
+// PartitionConsumerSnapshot is the state of a PartitionConsumer.
+type PartitionConsumerSnapshot struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	// Offset is the next offset to fetch, as of the last fetch response.
+	Offset        int64 `json:"offset"`
+	HighWaterMark int64 `json:"high_water_mark"`
+	// BufferedMessages is the number of messages waiting to be read from the
+	// Messages channel.
+	BufferedMessages int `json:"buffered_messages"`
+	// Paused is true if fetching from the partition is paused.
+	Paused bool `json:"paused"`
+}
+
+// ConsumerBrokerSnapshot is the state of the part of a consumer fetching from
+// one broker.
+type ConsumerBrokerSnapshot struct {
+	ID   int32  `json:"id"`
+	Addr string `json:"addr"`
+	// Partitions is the number of partitions fetched from the broker.
+	Partitions int `json:"partitions"`
+}
+
+// ConsumerSnapshot is the state of a Consumer: the partitions it is consuming,
+// and the brokers they are fetched from.
+type ConsumerSnapshot struct {
+	Partitions []PartitionConsumerSnapshot `json:"partitions"`
+	Brokers    []ConsumerBrokerSnapshot    `json:"brokers"`
+}
+
+// ConsumerGroupSnapshot is the state of a ConsumerGroup member: its place in
+// the group as of the last rebalance, and the partitions it claimed.
+type ConsumerGroupSnapshot struct {
+	Group string `json:"group"`
+	// MemberID is empty until the member joins the group.
+	MemberID     string `json:"member_id"`
+	GenerationID int32  `json:"generation_id"`
+	// Claims maps the topics to the partitions claimed by the member.
+	Claims map[string][]int32 `json:"claims"`
+	// Coordinator is the cached coordinator of the group, if any.
+	Coordinator *CoordinatorSnapshot `json:"coordinator,omitempty"`
+}
+
+// SnapshotClient returns the state of the given Client, or nil if it was not
+// created by this package.
+//
+// SnapshotClient, SnapshotProducer, SnapshotConsumer and SnapshotConsumerGroup
+// capture internal state
+// as plain structures that can be marshalled to JSON, to be exposed on a debug
+// endpoint while diagnosing an incident. For example, with expvar:
+//
+//	expvar.Publish("kafka_producer", expvar.Func(func() interface{} {
+//		return sarama.SnapshotProducer(producer)
+//	}))
+//
+// Taking a snapshot never blocks on network operations, but its parts are read
+// at slightly different times, so they are not guaranteed to be consistent.
+func SnapshotClient(c Client) *ClientSnapshot {
+	client, ok := c.(*client)
+	if !ok {
+		return nil
+	}
+
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	snapshot := &ClientSnapshot{
+		Closed:       client.Closed(),
+		SeedBrokers:  []string{},
+		Brokers:      []BrokerSnapshot{},
+		Coordinators: []CoordinatorSnapshot{},
+		Topics:       make(map[string]int),
+	}
+	for _, broker := range client.seedBrokers {
+		snapshot.SeedBrokers = append(snapshot.SeedBrokers, broker.Addr())
+	}
+
+	ids := make([]int32, 0, len(client.brokers))
+	for id := range client.brokers {
+		ids = append(ids, id)
+	}
+	sort.Sort(int32Slice(ids))
+	for _, id := range ids {
+		snapshot.Brokers = append(snapshot.Brokers, snapshotBroker(client.brokers[id], client.conf.MetricRegistry))
+	}
+
+	groups := make([]string, 0, len(client.coordinators))
+	for group := range client.coordinators {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		snapshot.Coordinators = append(snapshot.Coordinators, client.snapshotCoordinator(group))
+	}
+
+	for topic, partitions := range client.metadata {
+		snapshot.Topics[topic] = len(partitions)
+	}
+	return snapshot
+}
+
+// snapshotCoordinator must be called with the client's lock held.
+func (client *client) snapshotCoordinator(group string) CoordinatorSnapshot {
+	coordinator := CoordinatorSnapshot{Group: group, BrokerID: client.coordinators[group]}
+	if broker := client.brokers[coordinator.BrokerID]; broker != nil {
+		coordinator.Addr = broker.Addr()
+	}
+	return coordinator
+}
+
+func snapshotBroker(broker *Broker, registry metrics.Registry) BrokerSnapshot {
+	snapshot := BrokerSnapshot{
+		ID:   broker.ID(),
+		Addr: broker.Addr(),
+		Open: atomic.LoadInt32(&broker.opened) == 1,
+	}
+	if counter, ok := registry.Get(getMetricNameForBroker("requests-in-flight", broker)).(metrics.Counter); ok {
+		snapshot.RequestsInFlight = counter.Count()
+	}
+	return snapshot
+}
+
+// SnapshotProducer returns the state of the given AsyncProducer or SyncProducer,
+// or nil if it is neither or was not created by this package.
+func SnapshotProducer(producer interface{}) *ProducerSnapshot {
+	var p *asyncProducer
+	switch producer := producer.(type) {
+	case *asyncProducer:
+		p = producer
+	case *syncProducer:
+		p = producer.producer
+	default:
+		return nil
+	}
+
+	snapshot := &ProducerSnapshot{
+		MessagesInFlight: atomic.LoadInt64(&p.messagesInFlight),
+		Brokers:          []ProducerBrokerSnapshot{},
+	}
+
+	p.brokerLock.Lock()
+	brokerProducers := make([]*brokerProducer, 0, len(p.brokerProducers))
+	for _, bp := range p.brokerProducers {
+		brokerProducers = append(brokerProducers, bp)
+	}
+	p.brokerLock.Unlock()
+
+	for _, bp := range brokerProducers {
+		snapshot.Brokers = append(snapshot.Brokers, bp.snapshot())
+	}
+	sort.Sort(producerBrokerSnapshotSlice(snapshot.Brokers))
+	return snapshot
+}
+
+func (bp *brokerProducer) snapshot() ProducerBrokerSnapshot {
+	snapshot := ProducerBrokerSnapshot{
+		ID:               bp.broker.ID(),
+		Addr:             bp.broker.Addr(),
+		BufferedMessages: atomic.LoadInt64(&bp.bufferedMessages),
+		BufferedBytes:    atomic.LoadInt64(&bp.bufferedBytes),
+		RequestsInFlight: len(bp.window),
+		Retrying:         []TopicPartitionError{},
+	}
+
+	bp.retriesLock.Lock()
+	defer bp.retriesLock.Unlock()
+
+	if bp.closing != nil {
+		snapshot.Closing = bp.closing.Error()
+	}
+	for topic, partitions := range bp.currentRetries {
+		for partition, err := range partitions {
+			if err != nil {
+				snapshot.Retrying = append(snapshot.Retrying, TopicPartitionError{Topic: topic, Partition: partition, Err: err.Error()})
+			}
+		}
+	}
+	sort.Sort(topicPartitionErrorSlice(snapshot.Retrying))
+	return snapshot
+}
+
+// SnapshotConsumer returns the state of the given Consumer, or nil if it was not
+// created by this package.
+func SnapshotConsumer(c Consumer) *ConsumerSnapshot {
+	consumer, ok := c.(*consumer)
+	if !ok {
+		return nil
+	}
+
+	consumer.lock.Lock()
+	defer consumer.lock.Unlock()
+
+	snapshot := &ConsumerSnapshot{
+		Partitions: []PartitionConsumerSnapshot{},
+		Brokers:    []ConsumerBrokerSnapshot{},
+	}
+	for topic, partitions := range consumer.children {
+		for partition, child := range partitions {
+			snapshot.Partitions = append(snapshot.Partitions, PartitionConsumerSnapshot{
+				Topic:            topic,
+				Partition:        partition,
+				Offset:           atomic.LoadInt64(&child.position),
+				HighWaterMark:    child.HighWaterMarkOffset(),
+				BufferedMessages: len(child.messages),
+				Paused:           atomic.LoadInt32(&child.paused) == 1,
+			})
+		}
+	}
+	sort.Sort(partitionConsumerSnapshotSlice(snapshot.Partitions))
+
+	for broker, bc := range consumer.brokerConsumers {
+		snapshot.Brokers = append(snapshot.Brokers, ConsumerBrokerSnapshot{
+			ID:         broker.ID(),
+			Addr:       broker.Addr(),
+			Partitions: bc.refs,
+		})
+	}
+	sort.Sort(consumerBrokerSnapshotSlice(snapshot.Brokers))
+	return snapshot
+}
+
+// SnapshotConsumerGroup returns the state of the given ConsumerGroup, or nil if
+// it was not created by this package.
+func SnapshotConsumerGroup(g ConsumerGroup) *ConsumerGroupSnapshot {
+	cg, ok := g.(*consumerGroup)
+	if !ok {
+		return nil
+	}
+
+	snapshot := &ConsumerGroupSnapshot{Group: cg.groupID, Claims: make(map[string][]int32)}
+	cg.stateLock.Lock()
+	snapshot.MemberID, snapshot.GenerationID = cg.memberID, cg.generationID
+	for _, claim := range cg.claimed {
+		snapshot.Claims[claim.topic] = append(snapshot.Claims[claim.topic], claim.partition)
+	}
+	cg.stateLock.Unlock()
+
+	if client, ok := cg.client.(*client); ok {
+		client.lock.RLock()
+		if _, ok := client.coordinators[cg.groupID]; ok {
+			coordinator := client.snapshotCoordinator(cg.groupID)
+			snapshot.Coordinator = &coordinator
+		}
+		client.lock.RUnlock()
+	}
+	return snapshot
+}
+
+type topicPartitionErrorSlice []TopicPartitionError
+
+func (s topicPartitionErrorSlice) Len() int      { return len(s) }
+func (s topicPartitionErrorSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s topicPartitionErrorSlice) Less(i, j int) bool {
+	if s[i].Topic != s[j].Topic {
+		return s[i].Topic < s[j].Topic
+	}
+	return s[i].Partition < s[j].Partition
+}
+
+type partitionConsumerSnapshotSlice []PartitionConsumerSnapshot
+
+func (s partitionConsumerSnapshotSlice) Len() int      { return len(s) }
+func (s partitionConsumerSnapshotSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s partitionConsumerSnapshotSlice) Less(i, j int) bool {
+	if s[i].Topic != s[j].Topic {
+		return s[i].Topic < s[j].Topic
+	}
+	return s[i].Partition < s[j].Partition
+}
+
+type producerBrokerSnapshotSlice []ProducerBrokerSnapshot
+
+func (s producerBrokerSnapshotSlice) Len() int           { return len(s) }
+func (s producerBrokerSnapshotSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s producerBrokerSnapshotSlice) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+type consumerBrokerSnapshotSlice []ConsumerBrokerSnapshot
+
+func (s consumerBrokerSnapshotSlice) Len() int           { return len(s) }
+func (s consumerBrokerSnapshotSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s consumerBrokerSnapshotSlice) Less(i, j int) bool { return s[i].ID < s[j].ID }
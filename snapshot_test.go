@@ -0,0 +1,187 @@
+package sarama
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotClient(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	coordinator := NewMockBroker(t, 2)
+
+	metadata := new(MetadataResponse)
+	metadata.AddBroker(coordinator.Addr(), coordinator.BrokerID())
+	metadata.AddTopicPartition("my_topic", 0, coordinator.BrokerID(), nil, nil, ErrNoError)
+	metadata.AddTopicPartition("my_topic", 1, coordinator.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadata)
+
+	client, err := NewClient([]string{seedBroker.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seedBroker.Returns(&ConsumerMetadataResponse{
+		CoordinatorID:   coordinator.BrokerID(),
+		CoordinatorHost: "127.0.0.1",
+		CoordinatorPort: coordinator.Port(),
+	})
+	if _, err := client.Coordinator("my_group"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := SnapshotClient(client)
+	if snapshot == nil || snapshot.Closed {
+		t.Fatal("Expected the snapshot of an open client, got", snapshot)
+	}
+	if len(snapshot.SeedBrokers) != 1 || snapshot.SeedBrokers[0] != seedBroker.Addr() {
+		t.Error("Unexpected seed brokers", snapshot.SeedBrokers)
+	}
+	if len(snapshot.Brokers) != 1 || snapshot.Brokers[0].ID != 2 || !snapshot.Brokers[0].Open {
+		t.Error("Expected broker 2 to be open, got", snapshot.Brokers)
+	}
+	if len(snapshot.Coordinators) != 1 || snapshot.Coordinators[0].Group != "my_group" || snapshot.Coordinators[0].Addr != coordinator.Addr() {
+		t.Error("Expected broker 2 to coordinate my_group, got", snapshot.Coordinators)
+	}
+	if snapshot.Topics["my_topic"] != 2 {
+		t.Error("Expected my_topic to have 2 partitions, got", snapshot.Topics)
+	}
+	if _, err := json.Marshal(snapshot); err != nil {
+		t.Error(err)
+	}
+
+	safeClose(t, client)
+	if !SnapshotClient(client).Closed {
+		t.Error("Expected the snapshot of a closed client")
+	}
+
+	coordinator.Close()
+	seedBroker.Close()
+}
+
+func TestSnapshotProducer(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	config := NewConfig()
+	config.Producer.Return.Successes = true
+	producer, err := NewSyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := producer.SendMessage(&ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := SnapshotProducer(producer)
+	if snapshot == nil || snapshot.MessagesInFlight != 0 {
+		t.Fatal("Expected no messages in flight, got", snapshot)
+	}
+	if len(snapshot.Brokers) != 1 || snapshot.Brokers[0].ID != 2 {
+		t.Fatal("Expected a producer for broker 2, got", snapshot.Brokers)
+	}
+	if broker := snapshot.Brokers[0]; broker.Closing != "" || len(broker.Retrying) != 0 {
+		t.Error("Expected broker 2 not to be retrying, got", broker)
+	}
+	if _, err := json.Marshal(snapshot); err != nil {
+		t.Error(err)
+	}
+
+	if SnapshotProducer(struct{}{}) != nil {
+		t.Error("Expected no snapshot of a foreign producer")
+	}
+
+	safeClose(t, producer)
+	leader.Close()
+	seedBroker.Close()
+}
+
+func TestSnapshotConsumer(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 10),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 3, testMsg).
+			SetHighWaterMark("my_topic", 0, 10),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, err := master.ConsumePartition("my_topic", 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-consumer.Messages()
+
+	snapshot := SnapshotConsumer(master)
+	if snapshot == nil || len(snapshot.Partitions) != 1 {
+		t.Fatal("Expected the snapshot of one partition, got", snapshot)
+	}
+	if partition := snapshot.Partitions[0]; partition.Topic != "my_topic" || partition.Offset != 4 || partition.HighWaterMark != 10 {
+		t.Error("Unexpected partition snapshot", partition)
+	}
+	if len(snapshot.Brokers) != 1 || snapshot.Brokers[0].ID != 0 || snapshot.Brokers[0].Partitions != 1 {
+		t.Error("Expected broker 0 to fetch one partition, got", snapshot.Brokers)
+	}
+	if _, err := json.Marshal(snapshot); err != nil {
+		t.Error(err)
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	broker0.Close()
+}
+
+func TestSnapshotConsumerGroup(t *testing.T) {
+	cluster := NewMockCluster(t, 1)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 2)
+
+	cg, err := NewConsumerGroup(cluster.Addrs(), "my_group", []string{"my_topic"}, newConsumerGroupConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := expectConsumerGroupNotification(t, cg, map[string][]int32{"my_topic": {0, 1}})
+	cg.Pause(map[string][]int32{"my_topic": {1}})
+
+	snapshot := SnapshotConsumerGroup(cg)
+	if snapshot == nil || snapshot.Group != "my_group" || snapshot.MemberID != n.MemberID || snapshot.GenerationID != n.GenerationID {
+		t.Fatal("Expected the snapshot of the member of the last rebalance, got", snapshot)
+	}
+	if !reflect.DeepEqual(snapshot.Claims, n.Current) {
+		t.Error("Expected the claimed partitions, got", snapshot.Claims)
+	}
+	if snapshot.Coordinator == nil || snapshot.Coordinator.Group != "my_group" || snapshot.Coordinator.Addr != cluster.Addrs()[0] {
+		t.Error("Expected the coordinator of the group, got", snapshot.Coordinator)
+	}
+	if _, err := json.Marshal(snapshot); err != nil {
+		t.Error(err)
+	}
+
+	consumer := SnapshotConsumer(cg.(*consumerGroup).consumer)
+	if len(consumer.Partitions) != 2 || consumer.Partitions[0].Paused || !consumer.Partitions[1].Paused {
+		t.Error("Expected only partition 1 to be paused, got", consumer.Partitions)
+	}
+
+	safeClose(t, cg)
+	if snapshot := SnapshotConsumerGroup(cg); len(snapshot.Claims) != 0 {
+		t.Error("Expected a closed member to claim nothing, got", snapshot.Claims)
+	}
+}
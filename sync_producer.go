@@ -1,6 +1,9 @@
 package sarama
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // SyncProducer publishes Kafka messages. It routes messages to the correct broker, refreshing metadata as appropriate,
 // and parses responses for errors. You must call Close() on a producer to avoid leaks, it may not be garbage-collected automatically when
@@ -12,6 +15,22 @@ type SyncProducer interface {
 	// of the produced message, or an error if the message failed to produce.
 	SendMessage(msg *ProducerMessage) (partition int32, offset int64, err error)
 
+	// SendMessageContext is like SendMessage, but stops waiting when ctx is done
+	// and returns ctx.Err(). A message handed to the producer before then may
+	// still be produced: it must not be reused until its Metadata is restored,
+	// which happens once it has succeeded or failed.
+	SendMessageContext(ctx context.Context, msg *ProducerMessage) (partition int32, offset int64, err error)
+
+	// BeginTxn, CommitTxn, AbortTxn, AddOffsetsToTxn and AddMessageToTxn
+	// begin and end transactions, and commit offsets in them, as with an
+	// AsyncProducer. A transactional producer must begin a transaction before
+	// sending messages.
+	BeginTxn() error
+	CommitTxn() error
+	AbortTxn() error
+	AddOffsetsToTxn(offsets map[string][]*PartitionOffsetMetadata, groupID string) error
+	AddMessageToTxn(msg *ConsumerMessage, groupID string, metadata *string) error
+
 	// Close shuts down the producer and flushes any messages it may have buffered.
 	// You must call this function before a producer object passes out of scope, as
 	// it may otherwise leak memory. You must call this before calling Close on the
@@ -26,6 +45,9 @@ type syncProducer struct {
 
 // NewSyncProducer creates a new SyncProducer using the given broker addresses and configuration.
 func NewSyncProducer(addrs []string, config *Config) (SyncProducer, error) {
+	if config != nil && config.Producer.Return.ErrorHandler != nil {
+		return nil, ConfigurationError("SyncProducer does not support Producer.Return.ErrorHandler")
+	}
 	p, err := NewAsyncProducer(addrs, config)
 	if err != nil {
 		return nil, err
@@ -36,6 +58,9 @@ func NewSyncProducer(addrs []string, config *Config) (SyncProducer, error) {
 // NewSyncProducerFromClient creates a new SyncProducer using the given client. It is still
 // necessary to call Close() on the underlying client when shutting down this producer.
 func NewSyncProducerFromClient(client Client) (SyncProducer, error) {
+	if client.Config().Producer.Return.ErrorHandler != nil {
+		return nil, ConfigurationError("SyncProducer does not support Producer.Return.ErrorHandler")
+	}
 	p, err := NewAsyncProducerFromClient(client)
 	if err != nil {
 		return nil, err
@@ -56,20 +81,41 @@ func newSyncProducerFromAsyncProducer(p *asyncProducer) *syncProducer {
 }
 
 func (sp *syncProducer) SendMessage(msg *ProducerMessage) (partition int32, offset int64, err error) {
-	oldMetadata := msg.Metadata
-	defer func() {
-		msg.Metadata = oldMetadata
-	}()
+	return sp.SendMessageContext(context.Background(), msg)
+}
 
+func (sp *syncProducer) SendMessageContext(ctx context.Context, msg *ProducerMessage) (partition int32, offset int64, err error) {
+	if err := ctx.Err(); err != nil {
+		return -1, -1, err
+	}
+
+	oldMetadata := msg.Metadata
 	expectation := make(chan error, 1)
 	msg.Metadata = expectation
-	sp.producer.Input() <- msg
 
-	if err := <-expectation; err != nil {
-		return -1, -1, err
+	select {
+	case sp.producer.Input() <- msg:
+	case <-ctx.Done():
+		msg.Metadata = oldMetadata
+		return -1, -1, ctx.Err()
 	}
 
-	return msg.Partition, msg.Offset, nil
+	select {
+	case err := <-expectation:
+		msg.Metadata = oldMetadata
+		if err != nil {
+			return -1, -1, err
+		}
+		return msg.Partition, msg.Offset, nil
+	case <-ctx.Done():
+		// the producer still owns the message, so leave it to restore the
+		// metadata once the message comes back
+		go withRecover(func() {
+			<-expectation
+			msg.Metadata = oldMetadata
+		})
+		return -1, -1, ctx.Err()
+	}
 }
 
 func (sp *syncProducer) handleSuccesses() {
@@ -88,6 +134,26 @@ func (sp *syncProducer) handleErrors() {
 	}
 }
 
+func (sp *syncProducer) BeginTxn() error {
+	return sp.producer.BeginTxn()
+}
+
+func (sp *syncProducer) CommitTxn() error {
+	return sp.producer.CommitTxn()
+}
+
+func (sp *syncProducer) AbortTxn() error {
+	return sp.producer.AbortTxn()
+}
+
+func (sp *syncProducer) AddOffsetsToTxn(offsets map[string][]*PartitionOffsetMetadata, groupID string) error {
+	return sp.producer.AddOffsetsToTxn(offsets, groupID)
+}
+
+func (sp *syncProducer) AddMessageToTxn(msg *ConsumerMessage, groupID string, metadata *string) error {
+	return sp.producer.AddMessageToTxn(msg, groupID, metadata)
+}
+
 func (sp *syncProducer) Close() error {
 	sp.producer.AsyncClose()
 	sp.wg.Wait()
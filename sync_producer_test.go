@@ -1,14 +1,17 @@
 package sarama
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestSyncProducer(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
@@ -54,9 +57,54 @@ func TestSyncProducer(t *testing.T) {
 	seedBroker.Close()
 }
 
+func TestSyncProducerContext(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 2)
+	defer leader.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+	leader.Returns(prodSuccess)
+	leader.SetLatency(200 * time.Millisecond)
+
+	producer, err := NewSyncProducer([]string{seedBroker.Addr()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Metadata: "test"}
+	if _, _, err := producer.SendMessageContext(ctx, msg); err != context.Canceled {
+		t.Error("Expected a done context to stop the send, got", err)
+	}
+	if str, ok := msg.Metadata.(string); !ok || str != "test" {
+		t.Error("Expected the metadata of the unsent message to be restored")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := producer.SendMessageContext(ctx, msg); err != context.DeadlineExceeded {
+		t.Error("Expected the send to give up at the deadline, got", err)
+	}
+
+	if _, offset, err := producer.SendMessageContext(context.Background(), &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}); err != nil || offset != 0 {
+		t.Error("Expected the next message to be produced, got", offset, err)
+	}
+
+	safeClose(t, producer)
+}
+
 func TestConcurrentSyncProducer(t *testing.T) {
-	seedBroker := newMockBroker(t, 1)
-	leader := newMockBroker(t, 2)
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
@@ -98,7 +146,7 @@ func TestConcurrentSyncProducer(t *testing.T) {
 }
 
 func TestSyncProducerToNonExistingTopic(t *testing.T) {
-	broker := newMockBroker(t, 1)
+	broker := NewMockBroker(t, 1)
 
 	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
@@ -127,6 +175,14 @@ func TestSyncProducerToNonExistingTopic(t *testing.T) {
 	broker.Close()
 }
 
+func TestSyncProducerRefusesErrorHandler(t *testing.T) {
+	config := NewConfig()
+	config.Producer.Return.ErrorHandler = func(*ProducerError) {}
+	if _, err := NewSyncProducer([]string{"localhost:9092"}, config); err == nil {
+		t.Error("Expected an ErrorHandler to be refused")
+	}
+}
+
 // This example shows the basic usage pattern of the SyncProducer.
 func ExampleSyncProducer() {
 	producer, err := NewSyncProducer([]string{"localhost:9092"}, nil)
@@ -147,3 +203,36 @@ func ExampleSyncProducer() {
 		log.Printf("> message sent to partition %d at offset %d\n", partition, offset)
 	}
 }
+
+func TestSyncProducerManualPartition(t *testing.T) {
+	cluster := NewMockCluster(t, 2)
+	defer cluster.Close()
+	cluster.CreateTopic("my_topic", 3)
+
+	config := newMockClusterConfig()
+	config.Producer.Partitioner = NewHashPartitioner
+	producer, err := NewSyncProducer(cluster.Addrs(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, producer)
+
+	for i := 0; i < 3; i++ {
+		msg := &ProducerMessage{Topic: "my_topic", Key: StringEncoder(fmt.Sprint("key", i)), Value: StringEncoder(TestMessage), Partition: 2, ManualPartition: true}
+		partition, _, err := producer.SendMessage(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if partition != 2 {
+			t.Error("Expected the message to bypass the partitioner, got partition", partition)
+		}
+	}
+	if messages := cluster.Messages("my_topic", 2); len(messages) != 3 {
+		t.Error("Expected 3 messages on partition 2, got", len(messages))
+	}
+
+	msg := &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage), Partition: 3, ManualPartition: true}
+	if _, _, err := producer.SendMessage(msg); err != ErrInvalidPartition {
+		t.Error("Expected a partition that doesn't exist to be refused, got", err)
+	}
+}
@@ -0,0 +1,444 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type telemetryKind int
+
+const (
+	telemetrySum     telemetryKind = iota // a meter's or counter's count
+	telemetryGauge                        // a gauge's value or a counter's count
+	telemetryLatency                      // a histogram's mean and max, as .avg and .max gauges
+)
+
+type telemetryScope int
+
+const (
+	telemetryAggregate    telemetryScope = iota // the metric aggregated across brokers and topics
+	telemetryPerBroker                          // the -for-broker-<id> metrics, with a node.id attribute
+	telemetryPerPartition                       // the -for-topic-<topic>-partition-<partition> metrics
+)
+
+type telemetryInstrument struct {
+	name  string
+	kind  telemetryKind
+	scope telemetryScope
+}
+
+// telemetryInstruments maps the go-metrics names Sarama registers to the metrics
+// pushed to brokers, named after their equivalents in the JVM clients. Metrics
+// that aren't listed here are not pushed.
+var telemetryInstruments = map[string]telemetryInstrument{
+	"connection-creation-rate":    {"org.apache.kafka.client.connection.creation.total", telemetrySum, telemetryAggregate},
+	"connection-count":            {"org.apache.kafka.client.connection.count", telemetryGauge, telemetryAggregate},
+	"incoming-byte-rate":          {"org.apache.kafka.client.incoming.byte.total", telemetrySum, telemetryAggregate},
+	"outgoing-byte-rate":          {"org.apache.kafka.client.outgoing.byte.total", telemetrySum, telemetryAggregate},
+	"request-latency-in-ms":       {"org.apache.kafka.client.node.request.latency", telemetryLatency, telemetryPerBroker},
+	"record-send-rate":            {"org.apache.kafka.client.producer.record.send.total", telemetrySum, telemetryAggregate},
+	"record-retry-rate":           {"org.apache.kafka.client.producer.record.retry.total", telemetrySum, telemetryAggregate},
+	"record-error-rate":           {"org.apache.kafka.client.producer.record.error.total", telemetrySum, telemetryAggregate},
+	"produce-throttle-time-in-ms": {"org.apache.kafka.client.producer.produce.throttle.time", telemetryLatency, telemetryAggregate},
+	"fetch-latency-in-ms":         {"org.apache.kafka.client.consumer.fetch.latency", telemetryLatency, telemetryAggregate},
+	"fetch-throttle-time-in-ms":   {"org.apache.kafka.client.consumer.fetch.throttle.time", telemetryLatency, telemetryAggregate},
+	"consumer-lag":                {"org.apache.kafka.client.consumer.records.lag", telemetryGauge, telemetryPerPartition},
+}
+
+// telemetryReporter pushes the metrics of a client to the brokers that subscribe
+// to them, following KIP-714: it gets a subscription from a broker, then pushes
+// the requested metrics to that broker at the interval of the subscription until
+// the client is closed, when it pushes them one last time.
+type telemetryReporter struct {
+	client *client
+	conf   *Config
+
+	broker       *Broker
+	instanceID   Uuid
+	subscription *GetTelemetrySubscriptionsResponse
+	stopped      bool // after a fatal error, nothing is pushed anymore
+
+	start, lastPush time.Time
+	previous        map[string]int64 // the last pushed value of each sum, for delta temporality
+
+	random *rand.Rand // from conf.RandSource, for spreading the first push
+
+	closer, closed chan none
+}
+
+func newTelemetryReporter(client *client) *telemetryReporter {
+	return &telemetryReporter{
+		client:   client,
+		conf:     client.conf,
+		random:   rand.New(client.conf.RandSource()),
+		start:    time.Now(),
+		previous: make(map[string]int64),
+		closer:   make(chan none),
+		closed:   make(chan none),
+	}
+}
+
+func (t *telemetryReporter) run() {
+	defer close(t.closed)
+
+	var wait time.Duration
+	for {
+		select {
+		case <-time.After(wait):
+		case <-t.closer:
+			if t.subscription != nil && !t.stopped {
+				t.push(true)
+			}
+			return
+		}
+
+		if t.stopped {
+			<-t.closer
+			return
+		}
+		if t.subscription == nil {
+			wait = t.subscribe()
+		} else {
+			wait = t.push(false)
+		}
+	}
+}
+
+// close pushes the metrics one last time if subscribed, and waits for it.
+func (t *telemetryReporter) close() {
+	close(t.closer)
+	<-t.closed
+}
+
+// subscribe gets the subscription of the client and returns how long to wait
+// before pushing, or before subscribing again if that failed or if no metrics
+// are requested.
+func (t *telemetryReporter) subscribe() time.Duration {
+	if t.broker == nil {
+		if t.broker = t.client.any(); t.broker == nil {
+			LogClient.warn("no broker to get the telemetry subscription from", "err", ErrOutOfBrokers)
+			return t.conf.Telemetry.Retry.Backoff
+		}
+	}
+
+	response, err := t.broker.GetTelemetrySubscriptions(&GetTelemetrySubscriptionsRequest{ClientInstanceId: t.instanceID})
+	if err != nil {
+		LogClient.warn("failed to get the telemetry subscription", "broker", t.broker.ID(), "err", err)
+		t.broker = nil
+		return t.conf.Telemetry.Retry.Backoff
+	}
+	if response.Err != ErrNoError {
+		LogClient.warn("failed to get the telemetry subscription", "broker", t.broker.ID(), "err", response.Err)
+		return t.conf.Telemetry.Retry.Backoff
+	}
+
+	t.instanceID = response.ClientInstanceId
+	if len(response.RequestedMetrics) == 0 {
+		LogClient.debug("no telemetry requested", "broker", t.broker.ID(), "subscription", response.SubscriptionId)
+		return response.PushInterval
+	}
+
+	LogClient.debug("got the telemetry subscription", "broker", t.broker.ID(), "subscription", response.SubscriptionId,
+		"interval", response.PushInterval, "metrics", response.RequestedMetrics)
+	t.subscription = response
+	t.lastPush = time.Now()
+	// spread the first pushes of clients started together, as the JVM clients do
+	return time.Duration(float64(response.PushInterval) * (0.5 + t.random.Float64()))
+}
+
+// push pushes the requested metrics and returns how long to wait before pushing
+// again, or before subscribing again if the subscription is no longer valid.
+func (t *telemetryReporter) push(terminating bool) time.Duration {
+	subscription := t.subscription
+	now := time.Now()
+	raw := t.encode(now)
+
+	codec := t.compressionCodec()
+	payload, err := compress(codec, CompressionLevelDefault, false, raw)
+	if err != nil {
+		LogClient.warn("failed to compress the telemetry payload", "codec", codec, "err", err)
+		codec, payload = CompressionNone, raw
+	}
+	if subscription.TelemetryMaxBytes > 0 && len(payload) > int(subscription.TelemetryMaxBytes) {
+		LogClient.warn("telemetry payload is larger than the broker accepts; not pushing it",
+			"bytes", len(payload), "max", subscription.TelemetryMaxBytes)
+		return subscription.PushInterval
+	}
+
+	response, err := t.broker.PushTelemetry(&PushTelemetryRequest{
+		ClientInstanceId: t.instanceID,
+		SubscriptionId:   subscription.SubscriptionId,
+		Terminating:      terminating,
+		CompressionType:  codec,
+		Metrics:          payload,
+	})
+	if err != nil {
+		LogClient.warn("failed to push telemetry", "broker", t.broker.ID(), "err", err)
+		t.broker = nil
+		t.subscription = nil
+		return t.conf.Telemetry.Retry.Backoff
+	}
+	t.lastPush = now
+
+	switch response.Err {
+	case ErrNoError:
+		return subscription.PushInterval
+	case ErrUnknownSubscriptionId, ErrUnsupportedCompressionType:
+		// the subscription changed, so get the new one right away
+		t.subscription = nil
+		return 0
+	case ErrTelemetryTooLarge:
+		LogClient.warn("telemetry payload is larger than the broker accepts", "broker", t.broker.ID(), "bytes", len(payload))
+		return subscription.PushInterval
+	case ErrInvalidRequest, ErrInvalidRecord:
+		LogClient.error("telemetry rejected by the broker; no longer pushing it", "broker", t.broker.ID(), "err", response.Err)
+		t.stopped = true
+		return 0
+	default:
+		LogClient.warn("failed to push telemetry", "broker", t.broker.ID(), "err", response.Err)
+		t.subscription = nil
+		return t.conf.Telemetry.Retry.Backoff
+	}
+}
+
+// compressionCodec returns the first codec the broker accepts that Sarama can
+// compress with. Snappy is skipped since the JVM clients use a framing of
+// their own for it.
+func (t *telemetryReporter) compressionCodec() CompressionCodec {
+	for _, codec := range t.subscription.AcceptedCompressionTypes {
+		if codec != CompressionSnappy && codec.isBuiltin() {
+			return codec
+		}
+	}
+	return CompressionNone
+}
+
+func (t *telemetryReporter) requested(name string) bool {
+	for _, prefix := range t.subscription.RequestedMetrics {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type telemetryPoint struct {
+	attrs    [][2]string
+	isDouble bool
+	intValue int64
+	value    float64
+}
+
+type telemetryPointSlice []telemetryPoint
+
+func (s telemetryPointSlice) Len() int      { return len(s) }
+func (s telemetryPointSlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s telemetryPointSlice) Less(i, j int) bool {
+	for k := 0; k < len(s[i].attrs) && k < len(s[j].attrs); k++ {
+		if s[i].attrs[k][1] != s[j].attrs[k][1] {
+			return s[i].attrs[k][1] < s[j].attrs[k][1]
+		}
+	}
+	return len(s[i].attrs) < len(s[j].attrs)
+}
+
+// encode returns the requested metrics as an OpenTelemetry MetricsData protobuf
+// message.
+func (t *telemetryReporter) encode(now time.Time) []byte {
+	sums := make(map[string][]telemetryPoint)
+	gauges := make(map[string][]telemetryPoint)
+	delta := t.subscription.DeltaTemporality
+
+	t.conf.MetricRegistry.Each(func(registered string, value interface{}) {
+		base, attrs := parseTelemetryName(registered)
+		instrument, ok := telemetryInstruments[base]
+		if !ok || !instrument.scope.matches(attrs) {
+			return
+		}
+
+		switch instrument.kind {
+		case telemetrySum:
+			if !t.requested(instrument.name) {
+				return
+			}
+			var count int64
+			switch m := value.(type) {
+			case metrics.Meter:
+				count = m.Count()
+			case metrics.Counter:
+				count = m.Count()
+			default:
+				return
+			}
+			if delta {
+				key := instrument.name + "/" + registered
+				count, t.previous[key] = count-t.previous[key], count
+			}
+			sums[instrument.name] = append(sums[instrument.name], telemetryPoint{attrs: attrs, intValue: count})
+		case telemetryGauge:
+			if !t.requested(instrument.name) {
+				return
+			}
+			point := telemetryPoint{attrs: attrs}
+			switch m := value.(type) {
+			case metrics.Gauge:
+				point.intValue = m.Value()
+			case metrics.Counter:
+				point.intValue = m.Count()
+			default:
+				return
+			}
+			gauges[instrument.name] = append(gauges[instrument.name], point)
+		case telemetryLatency:
+			histogram, ok := value.(metrics.Histogram)
+			if !ok {
+				return
+			}
+			snapshot := histogram.Snapshot()
+			if snapshot.Count() == 0 {
+				return
+			}
+			if name := instrument.name + ".avg"; t.requested(name) {
+				gauges[name] = append(gauges[name], telemetryPoint{attrs: attrs, isDouble: true, value: snapshot.Mean()})
+			}
+			if name := instrument.name + ".max"; t.requested(name) {
+				gauges[name] = append(gauges[name], telemetryPoint{attrs: attrs, isDouble: true, value: float64(snapshot.Max())})
+			}
+		}
+	})
+
+	start := t.start
+	temporality := uint64(2) // AGGREGATION_TEMPORALITY_CUMULATIVE
+	if delta {
+		start = t.lastPush
+		temporality = 1 // AGGREGATION_TEMPORALITY_DELTA
+	}
+
+	var scope protoBuffer
+	scope.message(1, new(protoBuffer).string(1, "sarama"))
+	for _, name := range sortedPointNames(sums) {
+		var sum protoBuffer
+		for _, point := range sums[name] {
+			sum.message(1, encodeTelemetryPoint(point, start, now))
+		}
+		sum.varint(2, temporality)
+		sum.varint(3, 1) // is_monotonic
+		scope.message(2, new(protoBuffer).string(1, name).message(7, &sum))
+	}
+	for _, name := range sortedPointNames(gauges) {
+		var gauge protoBuffer
+		for _, point := range gauges[name] {
+			gauge.message(1, encodeTelemetryPoint(point, start, now))
+		}
+		scope.message(2, new(protoBuffer).string(1, name).message(5, &gauge))
+	}
+
+	var resourceMetrics protoBuffer
+	resourceMetrics.message(2, &scope)
+	var metricsData protoBuffer
+	metricsData.message(1, &resourceMetrics)
+	return metricsData
+}
+
+func sortedPointNames(points map[string][]telemetryPoint) []string {
+	names := make([]string, 0, len(points))
+	for name := range points {
+		names = append(names, name)
+		sort.Sort(telemetryPointSlice(points[name]))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func encodeTelemetryPoint(point telemetryPoint, start, now time.Time) *protoBuffer {
+	var buf protoBuffer
+	buf.fixed64(2, uint64(start.UnixNano()))
+	buf.fixed64(3, uint64(now.UnixNano()))
+	if point.isDouble {
+		buf.fixed64(4, math.Float64bits(point.value))
+	} else {
+		buf.fixed64(6, uint64(point.intValue))
+	}
+	for _, attr := range point.attrs {
+		buf.message(7, new(protoBuffer).string(1, attr[0]).message(2, new(protoBuffer).string(1, attr[1])))
+	}
+	return &buf
+}
+
+func (s telemetryScope) matches(attrs [][2]string) bool {
+	switch s {
+	case telemetryPerBroker:
+		return len(attrs) == 1 && attrs[0][0] == "node.id"
+	case telemetryPerPartition:
+		return len(attrs) == 2
+	default:
+		return len(attrs) == 0
+	}
+}
+
+// parseTelemetryName splits a per-broker or per-topic go-metrics name into the
+// metric's base name and attributes.
+func parseTelemetryName(name string) (string, [][2]string) {
+	if i := strings.LastIndex(name, "-for-broker-"); i >= 0 {
+		return name[:i], [][2]string{{"node.id", name[i+len("-for-broker-"):]}}
+	}
+	if i := strings.Index(name, "-for-topic-"); i >= 0 {
+		topic := name[i+len("-for-topic-"):]
+		if j := strings.LastIndex(topic, "-partition-"); j >= 0 {
+			if _, err := strconv.ParseInt(topic[j+len("-partition-"):], 10, 32); err == nil {
+				return name[:i], [][2]string{{"topic", topic[:j]}, {"partition", topic[j+len("-partition-"):]}}
+			}
+		}
+		return name[:i], [][2]string{{"topic", topic}}
+	}
+	return name, nil
+}
+
+// protoBuffer encodes the protobuf messages of the OpenTelemetry metrics data
+// model, which are all that is needed to push telemetry.
+type protoBuffer []byte
+
+func (b *protoBuffer) tag(field int, wireType uint64) {
+	b.putUvarint(uint64(field)<<3 | wireType)
+}
+
+func (b *protoBuffer) putUvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	*b = append(*b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func (b *protoBuffer) varint(field int, v uint64) *protoBuffer {
+	b.tag(field, 0)
+	b.putUvarint(v)
+	return b
+}
+
+func (b *protoBuffer) fixed64(field int, v uint64) *protoBuffer {
+	b.tag(field, 1)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	*b = append(*b, buf[:]...)
+	return b
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) *protoBuffer {
+	b.tag(field, 2)
+	b.putUvarint(uint64(len(v)))
+	*b = append(*b, v...)
+	return b
+}
+
+func (b *protoBuffer) string(field int, v string) *protoBuffer {
+	return b.bytes(field, []byte(v))
+}
+
+func (b *protoBuffer) message(field int, m *protoBuffer) *protoBuffer {
+	return b.bytes(field, *m)
+}
@@ -0,0 +1,218 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+type protoField struct {
+	num   int
+	value uint64 // varint and fixed64 fields
+	bytes []byte // length-delimited fields
+}
+
+func decodeProto(t *testing.T, buf []byte) []protoField {
+	var fields []protoField
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			t.Fatal("Invalid protobuf key")
+		}
+		buf = buf[n:]
+		field := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			field.value, n = binary.Uvarint(buf)
+			buf = buf[n:]
+		case 1:
+			field.value = binary.LittleEndian.Uint64(buf)
+			buf = buf[8:]
+		case 2:
+			length, n := binary.Uvarint(buf)
+			field.bytes = buf[n : n+int(length)]
+			buf = buf[n+int(length):]
+		default:
+			t.Fatal("Unexpected protobuf wire type", key&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// protoField returns the first field numbered num of the given message.
+func protoFieldOf(t *testing.T, buf []byte, num int) protoField {
+	for _, field := range decodeProto(t, buf) {
+		if field.num == num {
+			return field
+		}
+	}
+	t.Fatal("Missing protobuf field", num)
+	return protoField{}
+}
+
+// telemetryMetrics maps the names of the metrics of an encoded MetricsData
+// message to their encoded Metric messages.
+func telemetryMetrics(t *testing.T, payload []byte) map[string][]byte {
+	resourceMetrics := protoFieldOf(t, payload, 1).bytes
+	scopeMetrics := protoFieldOf(t, resourceMetrics, 2).bytes
+	byName := make(map[string][]byte)
+	for _, field := range decodeProto(t, scopeMetrics) {
+		if field.num == 2 {
+			byName[string(protoFieldOf(t, field.bytes, 1).bytes)] = field.bytes
+		}
+	}
+	return byName
+}
+
+func TestTelemetryReporter(t *testing.T) {
+	instanceID := Uuid{1, 2, 3}
+	broker := NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"GetTelemetrySubscriptionsRequest": NewMockWrapper(&GetTelemetrySubscriptionsResponse{
+			ClientInstanceId:         instanceID,
+			SubscriptionId:           3,
+			AcceptedCompressionTypes: []CompressionCodec{CompressionSnappy, CompressionGZIP},
+			PushInterval:             10 * time.Millisecond,
+			TelemetryMaxBytes:        1 << 20,
+			RequestedMetrics:         []string{"org.apache.kafka.client.connection."},
+		}),
+		"PushTelemetryRequest": NewMockWrapper(&PushTelemetryResponse{}),
+	})
+
+	config := NewConfig()
+	config.Version = V3_7_0_0
+	config.Telemetry.Enable = true
+	client, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pushes := func() (pushes []*PushTelemetryRequest) {
+		for _, rr := range broker.History() {
+			if push, ok := rr.Request.(*PushTelemetryRequest); ok {
+				pushes = append(pushes, push)
+			}
+		}
+		return pushes
+	}
+	for i := 0; i < 100 && len(pushes()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(pushes()) == 0 {
+		t.Fatal("Expected the client to push telemetry")
+	}
+
+	safeClose(t, client)
+
+	all := pushes()
+	first, last := all[0], all[len(all)-1]
+	if first.Terminating || !last.Terminating {
+		t.Error("Expected only the last push to be terminating, got", first.Terminating, last.Terminating)
+	}
+	if last.ClientInstanceId != instanceID || last.SubscriptionId != 3 {
+		t.Error("Expected pushes to use the subscription, got", last.ClientInstanceId, last.SubscriptionId)
+	}
+	if last.CompressionType != CompressionGZIP {
+		t.Error("Expected the first accepted codec other than snappy, got", last.CompressionType)
+	}
+
+	payload, err := decompress(last.CompressionType, last.Metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed := telemetryMetrics(t, payload)
+	if _, ok := pushed["org.apache.kafka.client.connection.creation.total"]; !ok {
+		t.Error("Expected the connection creations to be pushed, got", pushed)
+	}
+	if _, ok := pushed["org.apache.kafka.client.outgoing.byte.total"]; ok {
+		t.Error("Expected metrics that weren't requested not to be pushed")
+	}
+
+	broker.Close()
+}
+
+func TestTelemetryReporterStopsOnInvalidRecord(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"GetTelemetrySubscriptionsRequest": NewMockWrapper(&GetTelemetrySubscriptionsResponse{
+			SubscriptionId:   1,
+			PushInterval:     time.Millisecond,
+			RequestedMetrics: []string{""},
+		}),
+		"PushTelemetryRequest": NewMockWrapper(&PushTelemetryResponse{Err: ErrInvalidRecord}),
+	})
+
+	config := NewConfig()
+	config.Version = V3_7_0_0
+	config.Telemetry.Enable = true
+	client, err := NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	safeClose(t, client)
+
+	pushes := 0
+	for _, rr := range broker.History() {
+		if _, ok := rr.Request.(*PushTelemetryRequest); ok {
+			pushes++
+		}
+	}
+	if pushes != 1 {
+		t.Error("Expected a single push before the broker rejected it, got", pushes)
+	}
+
+	broker.Close()
+}
+
+func TestTelemetryEncodeDelta(t *testing.T) {
+	config := NewConfig()
+	meter := metrics.GetOrRegisterMeter("record-send-rate", config.MetricRegistry)
+	metrics.GetOrRegisterMeter("record-send-rate-for-topic-my_topic", config.MetricRegistry).Mark(5)
+	latency := getOrRegisterHistogram("request-latency-in-ms-for-broker-2", config.MetricRegistry)
+	latency.Update(10)
+	latency.Update(30)
+
+	reporter := newTelemetryReporter(&client{conf: config})
+	reporter.subscription = &GetTelemetrySubscriptionsResponse{DeltaTemporality: true, RequestedMetrics: []string{""}}
+
+	sent := func() uint64 {
+		pushed := telemetryMetrics(t, reporter.encode(time.Now()))
+		sum := protoFieldOf(t, pushed["org.apache.kafka.client.producer.record.send.total"], 7).bytes
+		if temporality := protoFieldOf(t, sum, 2).value; temporality != 1 {
+			t.Error("Expected delta temporality, got", temporality)
+		}
+		point := protoFieldOf(t, sum, 1).bytes
+		return protoFieldOf(t, point, 6).value
+	}
+
+	meter.Mark(5)
+	if count := sent(); count != 5 {
+		t.Error("Expected 5 records sent, got", count)
+	}
+	meter.Mark(3)
+	if count := sent(); count != 3 {
+		t.Error("Expected 3 more records sent, got", count)
+	}
+
+	pushed := telemetryMetrics(t, reporter.encode(time.Now()))
+	avg, ok := pushed["org.apache.kafka.client.node.request.latency.avg"]
+	if !ok {
+		t.Fatal("Expected the request latency to be pushed, got", pushed)
+	}
+	point := protoFieldOf(t, protoFieldOf(t, avg, 5).bytes, 1).bytes
+	attr := protoFieldOf(t, point, 7).bytes
+	if key := string(protoFieldOf(t, attr, 1).bytes); key != "node.id" {
+		t.Error("Expected a node.id attribute, got", key)
+	}
+	if value := string(protoFieldOf(t, protoFieldOf(t, attr, 2).bytes, 1).bytes); value != "2" {
+		t.Error("Expected broker 2, got", value)
+	}
+}
@@ -17,13 +17,35 @@ var (
 	brokerList = flag.String("brokers", os.Getenv("KAFKA_PEERS"), "The comma separated list of brokers in the Kafka cluster")
 	topic      = flag.String("topic", "", "REQUIRED: the topic to consume")
 	partitions = flag.String("partitions", "all", "The partitions to consume, can be 'all' or comma-separated numbers")
-	offset     = flag.String("offset", "newest", "The offset to start with. Can be `oldest`, `newest`")
+	offset     = flag.String("offset", "newest", "The offset to start with. Can be `oldest`, `newest`, or an absolute offset")
 	verbose    = flag.Bool("verbose", false, "Whether to turn on sarama logging")
 	bufferSize = flag.Int("buffer-size", 256, "The buffer size of the message channel.")
+	version    = flag.String("version", "0.8.2.0", "The version of Kafka of the brokers, such as 0.11.0.0. The headers of the messages are only fetched from 0.11.0.0")
 
 	logger = log.New(os.Stderr, "", log.LstdFlags)
 )
 
+// kafkaVersions are the values accepted by -version.
+var kafkaVersions = map[string]sarama.KafkaVersion{
+	"0.8.2.0":  sarama.V0_8_2_0,
+	"0.8.2.1":  sarama.V0_8_2_1,
+	"0.8.2.2":  sarama.V0_8_2_2,
+	"0.9.0.0":  sarama.V0_9_0_0,
+	"0.9.0.1":  sarama.V0_9_0_1,
+	"0.10.0.0": sarama.V0_10_0_0,
+	"0.10.0.1": sarama.V0_10_0_1,
+	"0.10.1.0": sarama.V0_10_1_0,
+	"0.10.2.0": sarama.V0_10_2_0,
+	"0.11.0.0": sarama.V0_11_0_0,
+	"1.0.0.0":  sarama.V1_0_0_0,
+	"1.1.0.0":  sarama.V1_1_0_0,
+	"2.0.0.0":  sarama.V2_0_0_0,
+	"2.1.0.0":  sarama.V2_1_0_0,
+	"2.2.0.0":  sarama.V2_2_0_0,
+	"2.3.0.0":  sarama.V2_3_0_0,
+	"3.7.0.0":  sarama.V3_7_0_0,
+}
+
 func main() {
 	flag.Parse()
 
@@ -39,6 +61,12 @@ func main() {
 		sarama.Logger = logger
 	}
 
+	config := sarama.NewConfig()
+	var ok bool
+	if config.Version, ok = kafkaVersions[*version]; !ok {
+		printUsageErrorAndExit(fmt.Sprintf("Kafka version %s not supported.", *version))
+	}
+
 	var initialOffset int64
 	switch *offset {
 	case "oldest":
@@ -46,10 +74,13 @@ func main() {
 	case "newest":
 		initialOffset = sarama.OffsetNewest
 	default:
-		printUsageErrorAndExit("-offset should be `oldest` or `newest`")
+		var err error
+		if initialOffset, err = strconv.ParseInt(*offset, 10, 64); err != nil || initialOffset < 0 {
+			printUsageErrorAndExit("-offset should be `oldest`, `newest`, or an absolute offset")
+		}
 	}
 
-	c, err := sarama.NewConsumer(strings.Split(*brokerList, ","), nil)
+	c, err := sarama.NewConsumer(strings.Split(*brokerList, ","), config)
 	if err != nil {
 		printErrorAndExit(69, "Failed to start consumer: %s", err)
 	}
@@ -93,12 +124,17 @@ func main() {
 		}(pc)
 	}
 
+	printed := make(chan struct{})
 	go func() {
+		defer close(printed)
 		for msg := range messages {
 			fmt.Printf("Partition:\t%d\n", msg.Partition)
 			fmt.Printf("Offset:\t%d\n", msg.Offset)
 			fmt.Printf("Key:\t%s\n", string(msg.Key))
 			fmt.Printf("Value:\t%s\n", string(msg.Value))
+			for _, header := range msg.Headers {
+				fmt.Printf("Header:\t%s=%s\n", string(header.Key), string(header.Value))
+			}
 			fmt.Println()
 		}
 	}()
@@ -106,6 +142,7 @@ func main() {
 	wg.Wait()
 	logger.Println("Done consuming topic", *topic)
 	close(messages)
+	<-printed
 
 	if err := c.Close(); err != nil {
 		logger.Println("Failed to close consumer: ", err)
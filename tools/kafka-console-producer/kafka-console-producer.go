@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -20,10 +21,35 @@ var (
 	partition   = flag.Int("partition", -1, "The partition to produce to.")
 	verbose     = flag.Bool("verbose", false, "Turn on sarama logging to stderr")
 	silent      = flag.Bool("silent", false, "Turn off printing the message's topic, partition, and offset to stdout")
+	lines       = flag.Bool("lines", false, "Produce every line read from stdin as a separate message, instead of stdin as a whole")
+	keySep      = flag.String("key-separator", "", "With -lines, the separator between the key and the value of each line. By default lines have no key.")
+	headers     = flag.String("headers", "", "The headers of the messages to produce, as comma separated key=value pairs. Requires -version 0.11.0.0 or later.")
+	version     = flag.String("version", "0.8.2.0", "The version of Kafka of the brokers, such as 0.11.0.0")
 
 	logger = log.New(os.Stderr, "", log.LstdFlags)
 )
 
+// kafkaVersions are the values accepted by -version.
+var kafkaVersions = map[string]sarama.KafkaVersion{
+	"0.8.2.0":  sarama.V0_8_2_0,
+	"0.8.2.1":  sarama.V0_8_2_1,
+	"0.8.2.2":  sarama.V0_8_2_2,
+	"0.9.0.0":  sarama.V0_9_0_0,
+	"0.9.0.1":  sarama.V0_9_0_1,
+	"0.10.0.0": sarama.V0_10_0_0,
+	"0.10.0.1": sarama.V0_10_0_1,
+	"0.10.1.0": sarama.V0_10_1_0,
+	"0.10.2.0": sarama.V0_10_2_0,
+	"0.11.0.0": sarama.V0_11_0_0,
+	"1.0.0.0":  sarama.V1_0_0_0,
+	"1.1.0.0":  sarama.V1_1_0_0,
+	"2.0.0.0":  sarama.V2_0_0_0,
+	"2.1.0.0":  sarama.V2_1_0_0,
+	"2.2.0.0":  sarama.V2_2_0_0,
+	"2.3.0.0":  sarama.V2_3_0_0,
+	"3.7.0.0":  sarama.V3_7_0_0,
+}
+
 func main() {
 	flag.Parse()
 
@@ -41,6 +67,18 @@ func main() {
 
 	config := sarama.NewConfig()
 	config.Producer.RequiredAcks = sarama.WaitForAll
+	var ok bool
+	if config.Version, ok = kafkaVersions[*version]; !ok {
+		printUsageErrorAndExit(fmt.Sprintf("Kafka version %s not supported.", *version))
+	}
+
+	messageHeaders, err := parseHeaders(*headers)
+	if err != nil {
+		printUsageErrorAndExit(err.Error())
+	}
+	if len(messageHeaders) > 0 && !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		printUsageErrorAndExit("-headers requires -version 0.11.0.0 or later")
+	}
 
 	switch *partitioner {
 	case "":
@@ -62,34 +100,84 @@ func main() {
 		printUsageErrorAndExit(fmt.Sprintf("Partitioner %s not supported.", *partitioner))
 	}
 
-	message := &sarama.ProducerMessage{Topic: *topic, Partition: int32(*partition)}
+	if *lines {
+		if *value != "" || !stdinAvailable() {
+			printUsageErrorAndExit("-lines reads the messages from stdin, -value can't be used")
+		}
+	} else if *value == "" && !stdinAvailable() {
+		printUsageErrorAndExit("-value is required, or you have to provide the value on stdin")
+	}
 
-	if *key != "" {
-		message.Key = sarama.StringEncoder(*key)
+	producer, err := sarama.NewSyncProducer(strings.Split(*brokerList, ","), config)
+	if err != nil {
+		printErrorAndExit(69, "Failed to open Kafka producer: %s", err)
 	}
+	defer func() {
+		if err := producer.Close(); err != nil {
+			logger.Println("Failed to close Kafka producer cleanly:", err)
+		}
+	}()
 
+	if *lines {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			message := newMessage(messageHeaders)
+			line := scanner.Text()
+			if *keySep != "" {
+				if i := strings.Index(line, *keySep); i >= 0 {
+					message.Key = sarama.StringEncoder(line[:i])
+					line = line[i+len(*keySep):]
+				}
+			}
+			message.Value = sarama.StringEncoder(line)
+			sendMessage(producer, message)
+		}
+		if err := scanner.Err(); err != nil {
+			printErrorAndExit(66, "Failed to read data from the standard input: %s", err)
+		}
+		return
+	}
+
+	message := newMessage(messageHeaders)
 	if *value != "" {
 		message.Value = sarama.StringEncoder(*value)
-	} else if stdinAvailable() {
+	} else {
 		bytes, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
 			printErrorAndExit(66, "Failed to read data from the standard input: %s", err)
 		}
 		message.Value = sarama.ByteEncoder(bytes)
-	} else {
-		printUsageErrorAndExit("-value is required, or you have to provide the value on stdin")
 	}
+	sendMessage(producer, message)
+}
 
-	producer, err := sarama.NewSyncProducer(strings.Split(*brokerList, ","), config)
-	if err != nil {
-		printErrorAndExit(69, "Failed to open Kafka producer: %s", err)
+// newMessage returns a message to the -topic and -partition, with the -key if any
+// and the given headers.
+func newMessage(headers []sarama.RecordHeader) *sarama.ProducerMessage {
+	message := &sarama.ProducerMessage{Topic: *topic, Partition: int32(*partition), Headers: headers}
+	if *key != "" {
+		message.Key = sarama.StringEncoder(*key)
 	}
-	defer func() {
-		if err := producer.Close(); err != nil {
-			logger.Println("Failed to close Kafka producer cleanly:", err)
+	return message
+}
+
+// parseHeaders parses the comma separated key=value pairs of -headers.
+func parseHeaders(headers string) ([]sarama.RecordHeader, error) {
+	if headers == "" {
+		return nil, nil
+	}
+	var parsed []sarama.RecordHeader
+	for _, header := range strings.Split(headers, ",") {
+		i := strings.Index(header, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("-headers should be comma separated key=value pairs, got %q", header)
 		}
-	}()
+		parsed = append(parsed, sarama.RecordHeader{Key: []byte(header[:i]), Value: []byte(header[i+1:])})
+	}
+	return parsed, nil
+}
 
+func sendMessage(producer sarama.SyncProducer, message *sarama.ProducerMessage) {
 	partition, offset, err := producer.SendMessage(message)
 	if err != nil {
 		printErrorAndExit(69, "Failed to produce message: %s", err)
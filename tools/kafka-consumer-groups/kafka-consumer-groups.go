@@ -0,0 +1,323 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	brokerList = flag.String("brokers", os.Getenv("KAFKA_PEERS"), "The comma separated list of brokers in the Kafka cluster. You can also set the KAFKA_PEERS environment variable")
+	list       = flag.Bool("list", false, "List the consumer groups of the cluster")
+	describe   = flag.Bool("describe", false, "Describe the -group: its members, their assignments, and its lag on every partition")
+	reset      = flag.Bool("reset-offsets", false, "Reset the committed offsets of the -group on the partitions of the -topic to -to. The group must have no members")
+	group      = flag.String("group", "", "The consumer group to describe or reset")
+	topic      = flag.String("topic", "", "The topic to reset offsets on, or with -describe a topic to report the group's lag on besides the assigned ones")
+	to         = flag.String("to", "", "With -reset-offsets, the offset to reset to. Can be `oldest`, `newest`, or an absolute offset")
+	execute    = flag.Bool("execute", false, "With -reset-offsets, commit the offsets; otherwise they are only printed")
+	verbose    = flag.Bool("verbose", false, "Turn on sarama logging to stderr")
+
+	logger = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+func main() {
+	flag.Parse()
+
+	if *brokerList == "" {
+		printUsageErrorAndExit("no -brokers specified. Alternatively, set the KAFKA_PEERS environment variable")
+	}
+
+	if *verbose {
+		sarama.Logger = logger
+	}
+
+	client, err := sarama.NewClient(strings.Split(*brokerList, ","), nil)
+	if err != nil {
+		printErrorAndExit(69, "Failed to connect to Kafka: %s", err)
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			logger.Println("Failed to close the client cleanly:", err)
+		}
+	}()
+
+	switch {
+	case *list:
+		listGroups(client)
+	case *describe:
+		if *group == "" {
+			printUsageErrorAndExit("-describe requires a -group")
+		}
+		describeGroup(client)
+	case *reset:
+		if *group == "" || *topic == "" || *to == "" {
+			printUsageErrorAndExit("-reset-offsets requires a -group, a -topic and a -to offset")
+		}
+		resetOffsets(client)
+	default:
+		printUsageErrorAndExit("one of -list, -describe or -reset-offsets is required")
+	}
+}
+
+// listGroups lists the groups coordinated by every broker of the cluster, since
+// each broker only knows the groups it coordinates.
+func listGroups(client sarama.Client) {
+	var groups []string
+	for _, broker := range clusterBrokers(client) {
+		response, err := broker.ListGroups(new(sarama.ListGroupsRequest))
+		if err != nil {
+			printErrorAndExit(69, "Failed to list the groups of broker %s: %s", broker.Addr(), err)
+		}
+		if response.Err != sarama.ErrNoError {
+			printErrorAndExit(69, "Failed to list the groups of broker %s: %s", broker.Addr(), response.Err)
+		}
+		for group, protocolType := range response.Groups {
+			groups = append(groups, group+"\t"+protocolType)
+		}
+	}
+
+	sort.Strings(groups)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tPROTOCOL TYPE")
+	for _, group := range groups {
+		fmt.Fprintln(w, group)
+	}
+	w.Flush()
+}
+
+func clusterBrokers(client sarama.Client) []*sarama.Broker {
+	var metadata *sarama.MetadataResponse
+	for _, addr := range strings.Split(*brokerList, ",") {
+		seed := sarama.NewBroker(addr)
+		if err := seed.Open(client.Config()); err != nil {
+			continue
+		}
+		response, err := seed.GetMetadata(new(sarama.MetadataRequest))
+		_ = seed.Close()
+		if err == nil {
+			metadata = response
+			break
+		}
+	}
+	if metadata == nil {
+		printErrorAndExit(69, "Failed to fetch the brokers of the cluster: %s", sarama.ErrOutOfBrokers)
+	}
+
+	for _, broker := range metadata.Brokers {
+		if err := broker.Open(client.Config()); err != nil && err != sarama.ErrAlreadyConnected {
+			printErrorAndExit(69, "Failed to connect to broker %s: %s", broker.Addr(), err)
+		}
+	}
+	return metadata.Brokers
+}
+
+func describeGroup(client sarama.Client) {
+	description := fetchGroupDescription(client)
+	fmt.Printf("Group %s is %s, with protocol %q of type %q\n\n", description.GroupId, description.State, description.Protocol, description.ProtocolType)
+
+	memberIDs := make([]string, 0, len(description.Members))
+	for id := range description.Members {
+		memberIDs = append(memberIDs, id)
+	}
+	sort.Strings(memberIDs)
+
+	owners := make(map[string]map[int32]string)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MEMBER\tCLIENT ID\tHOST\tASSIGNMENT")
+	for _, id := range memberIDs {
+		member := description.Members[id]
+		var assigned []string
+		if description.ProtocolType == "consumer" && len(member.MemberAssignment) > 0 {
+			assignment, err := member.GetMemberAssignment()
+			if err != nil {
+				printErrorAndExit(65, "Failed to decode the assignment of member %s: %s", id, err)
+			}
+			for topic, partitions := range assignment.Topics {
+				if owners[topic] == nil {
+					owners[topic] = make(map[int32]string)
+				}
+				for _, partition := range partitions {
+					owners[topic][partition] = id
+				}
+				assigned = append(assigned, fmt.Sprintf("%s%v", topic, partitions))
+			}
+			sort.Strings(assigned)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", id, member.ClientId, member.ClientHost, strings.Join(assigned, " "))
+	}
+	w.Flush()
+
+	topics := make([]string, 0, len(owners)+1)
+	for topic := range owners {
+		topics = append(topics, topic)
+	}
+	if *topic != "" && owners[*topic] == nil {
+		topics = append(topics, *topic)
+	}
+	if len(topics) == 0 {
+		fmt.Println("\nNo partitions are assigned; use -topic to report the lag on a topic.")
+		return
+	}
+	sort.Strings(topics)
+
+	committed := fetchCommittedOffsets(client, topics)
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TOPIC\tPARTITION\tCURRENT OFFSET\tLOG END OFFSET\tLAG\tMEMBER")
+	for _, topic := range topics {
+		for _, partition := range partitions(client, topic) {
+			logEnd, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				printErrorAndExit(69, "Failed to fetch the log end offset of %s/%d: %s", topic, partition, err)
+			}
+			current, lag := "-", "-"
+			if offset := committed[topic][partition]; offset >= 0 {
+				current = strconv.FormatInt(offset, 10)
+				lag = strconv.FormatInt(logEnd-offset, 10)
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s\t%s\n", topic, partition, current, logEnd, lag, owners[topic][partition])
+		}
+	}
+	w.Flush()
+}
+
+func resetOffsets(client sarama.Client) {
+	if description := fetchGroupDescription(client); len(description.Members) > 0 {
+		printErrorAndExit(69, "Group %s is %s with %d members; its offsets can only be reset when it has none", *group, description.State, len(description.Members))
+	}
+
+	request := &sarama.OffsetCommitRequest{
+		Version:                 1,
+		ConsumerGroup:           *group,
+		ConsumerGroupGeneration: -1,
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TOPIC\tPARTITION\tNEW OFFSET")
+	for _, partition := range partitions(client, *topic) {
+		offset := targetOffset(client, partition)
+		request.AddBlock(*topic, partition, offset, sarama.ReceiveTime, "")
+		fmt.Fprintf(w, "%s\t%d\t%d\n", *topic, partition, offset)
+	}
+	w.Flush()
+
+	if !*execute {
+		fmt.Println("\nDry run: use -execute to commit these offsets.")
+		return
+	}
+
+	coordinator, err := client.Coordinator(*group)
+	if err != nil {
+		printErrorAndExit(69, "Failed to find the coordinator of group %s: %s", *group, err)
+	}
+	response, err := coordinator.CommitOffset(request)
+	if err != nil {
+		printErrorAndExit(69, "Failed to commit the offsets: %s", err)
+	}
+	for topic, errors := range response.Errors {
+		for partition, kerr := range errors {
+			if kerr != sarama.ErrNoError {
+				printErrorAndExit(69, "Failed to commit the offset of %s/%d: %s", topic, partition, kerr)
+			}
+		}
+	}
+}
+
+func targetOffset(client sarama.Client, partition int32) int64 {
+	switch *to {
+	case "oldest", "newest":
+		time := sarama.OffsetOldest
+		if *to == "newest" {
+			time = sarama.OffsetNewest
+		}
+		offset, err := client.GetOffset(*topic, partition, time)
+		if err != nil {
+			printErrorAndExit(69, "Failed to fetch the %s offset of %s/%d: %s", *to, *topic, partition, err)
+		}
+		return offset
+	default:
+		offset, err := strconv.ParseInt(*to, 10, 64)
+		if err != nil || offset < 0 {
+			printUsageErrorAndExit("-to should be `oldest`, `newest`, or an absolute offset")
+		}
+		return offset
+	}
+}
+
+func fetchGroupDescription(client sarama.Client) *sarama.GroupDescription {
+	coordinator, err := client.Coordinator(*group)
+	if err != nil {
+		printErrorAndExit(69, "Failed to find the coordinator of group %s: %s", *group, err)
+	}
+	response, err := coordinator.DescribeGroups(&sarama.DescribeGroupsRequest{Groups: []string{*group}})
+	if err != nil {
+		printErrorAndExit(69, "Failed to describe group %s: %s", *group, err)
+	}
+	if len(response.Groups) != 1 {
+		printErrorAndExit(69, "Failed to describe group %s: %s", *group, sarama.ErrIncompleteResponse)
+	}
+	if description := response.Groups[0]; description.Err != sarama.ErrNoError {
+		printErrorAndExit(69, "Failed to describe group %s: %s", *group, description.Err)
+	}
+	return response.Groups[0]
+}
+
+// fetchCommittedOffsets returns the committed offset of the group on every
+// partition of the topics, or -1 where it has none.
+func fetchCommittedOffsets(client sarama.Client, topics []string) map[string]map[int32]int64 {
+	request := &sarama.OffsetFetchRequest{Version: 1, ConsumerGroup: *group}
+	for _, topic := range topics {
+		for _, partition := range partitions(client, topic) {
+			request.AddPartition(topic, partition)
+		}
+	}
+
+	coordinator, err := client.Coordinator(*group)
+	if err != nil {
+		printErrorAndExit(69, "Failed to find the coordinator of group %s: %s", *group, err)
+	}
+	response, err := coordinator.FetchOffset(request)
+	if err != nil {
+		printErrorAndExit(69, "Failed to fetch the offsets of group %s: %s", *group, err)
+	}
+
+	offsets := make(map[string]map[int32]int64)
+	for _, topic := range topics {
+		offsets[topic] = make(map[int32]int64)
+		for _, partition := range partitions(client, topic) {
+			offsets[topic][partition] = -1
+			if block := response.GetBlock(topic, partition); block != nil && block.Err == sarama.ErrNoError {
+				offsets[topic][partition] = block.Offset
+			}
+		}
+	}
+	return offsets
+}
+
+func partitions(client sarama.Client, topic string) []int32 {
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		printErrorAndExit(69, "Failed to get the partitions of topic %s: %s", topic, err)
+	}
+	return partitions
+}
+
+func printErrorAndExit(code int, format string, values ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ERROR: %s\n", fmt.Sprintf(format, values...))
+	fmt.Fprintln(os.Stderr)
+	os.Exit(code)
+}
+
+func printUsageErrorAndExit(message string) {
+	fmt.Fprintln(os.Stderr, "ERROR:", message)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Available command line options:")
+	flag.PrintDefaults()
+	os.Exit(64)
+}
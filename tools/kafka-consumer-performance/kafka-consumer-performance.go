@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+var (
+	brokerList        = flag.String("brokers", os.Getenv("KAFKA_PEERS"), "The comma separated list of brokers in the Kafka cluster. You can also set the KAFKA_PEERS environment variable")
+	topic             = flag.String("topic", "", "REQUIRED: the topic to consume")
+	partitions        = flag.String("partitions", "all", "The partitions to consume, can be 'all' or comma-separated numbers")
+	offset            = flag.String("offset", "oldest", "The offset to start with. Can be `oldest` or `newest`")
+	messageLoad       = flag.Int("message-load", 0, "REQUIRED: the number of messages to consume")
+	fetchMin          = flag.Int("fetch-min", 1, "The minimum number of bytes the brokers answer a fetch request with")
+	fetchDefault      = flag.Int("fetch-default", 32768, "The number of bytes fetched per partition and request")
+	maxWaitTime       = flag.Duration("max-wait-time", 250*time.Millisecond, "How long the brokers may wait for -fetch-min bytes")
+	channelBufferSize = flag.Int("channel-buffer-size", 256, "The buffer size of the consumer's channels")
+	verbose           = flag.Bool("verbose", false, "Turn on sarama logging to stderr")
+
+	logger = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+func main() {
+	flag.Parse()
+
+	if *brokerList == "" {
+		printUsageErrorAndExit("no -brokers specified. Alternatively, set the KAFKA_PEERS environment variable")
+	}
+	if *topic == "" {
+		printUsageErrorAndExit("no -topic specified")
+	}
+	if *messageLoad <= 0 {
+		printUsageErrorAndExit("-message-load must be greater than 0")
+	}
+
+	if *verbose {
+		sarama.Logger = logger
+	}
+
+	var initialOffset int64
+	switch *offset {
+	case "oldest":
+		initialOffset = sarama.OffsetOldest
+	case "newest":
+		initialOffset = sarama.OffsetNewest
+	default:
+		printUsageErrorAndExit("-offset should be `oldest` or `newest`")
+	}
+
+	config := sarama.NewConfig()
+	config.Consumer.Fetch.Min = int32(*fetchMin)
+	config.Consumer.Fetch.Default = int32(*fetchDefault)
+	config.Consumer.MaxWaitTime = *maxWaitTime
+	config.Consumer.Return.Errors = true
+	config.ChannelBufferSize = *channelBufferSize
+	if err := config.Validate(); err != nil {
+		printErrorAndExit(64, "Invalid configuration: %s", err)
+	}
+
+	consumer, err := sarama.NewConsumer(strings.Split(*brokerList, ","), config)
+	if err != nil {
+		printErrorAndExit(69, "Failed to start consumer: %s", err)
+	}
+
+	partitionList, err := getPartitions(consumer)
+	if err != nil {
+		printErrorAndExit(69, "Failed to get the list of partitions: %s", err)
+	}
+
+	var (
+		messages = make(chan *sarama.ConsumerMessage, *channelBufferSize)
+		errors   = make(chan *sarama.ConsumerError, *channelBufferSize)
+		pcs      []sarama.PartitionConsumer
+	)
+	for _, partition := range partitionList {
+		pc, err := consumer.ConsumePartition(*topic, partition, initialOffset)
+		if err != nil {
+			printErrorAndExit(69, "Failed to start consumer for partition %d: %s", partition, err)
+		}
+		pcs = append(pcs, pc)
+
+		go func(pc sarama.PartitionConsumer) {
+			for message := range pc.Messages() {
+				messages <- message
+			}
+		}(pc)
+		go func(pc sarama.PartitionConsumer) {
+			for err := range pc.Errors() {
+				errors <- err
+			}
+		}(pc)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	var (
+		start         = time.Now()
+		ticker        = time.NewTicker(time.Second)
+		consumed      int
+		bytes         int
+		lastTick      = start
+		sinceLastTick int
+		bytesLastTick int
+		interrupted   bool
+	)
+	defer ticker.Stop()
+
+	for consumed < *messageLoad && !interrupted {
+		select {
+		case msg := <-messages:
+			size := len(msg.Key) + len(msg.Value)
+			consumed++
+			bytes += size
+			sinceLastTick++
+			bytesLastTick += size
+		case err := <-errors:
+			logger.Println("Failed to consume:", err)
+		case now := <-ticker.C:
+			printRates(sinceLastTick, bytesLastTick, now.Sub(lastTick))
+			lastTick, sinceLastTick, bytesLastTick = now, 0, 0
+		case <-signals:
+			interrupted = true
+		}
+	}
+	elapsed := time.Since(start)
+
+	for _, pc := range pcs {
+		pc.AsyncClose()
+	}
+	go func() {
+		for {
+			select {
+			case <-messages:
+			case <-errors:
+			}
+		}
+	}()
+	if err := consumer.Close(); err != nil {
+		logger.Println("Failed to close consumer cleanly:", err)
+	}
+
+	fmt.Println()
+	fmt.Printf("%d messages consumed in %s\n", consumed, elapsed)
+	printRates(consumed, bytes, elapsed)
+	if interrupted {
+		os.Exit(130)
+	}
+}
+
+// printRates prints the throughput of the messages, and their keys and values,
+// consumed in the duration.
+func printRates(messages, bytes int, duration time.Duration) {
+	seconds := duration.Seconds()
+	fmt.Printf("%.1f records/sec, %.2f MB/sec\n", float64(messages)/seconds, float64(bytes)/seconds/1024/1024)
+}
+
+func getPartitions(c sarama.Consumer) ([]int32, error) {
+	if *partitions == "all" {
+		return c.Partitions(*topic)
+	}
+
+	tmp := strings.Split(*partitions, ",")
+	var pList []int32
+	for i := range tmp {
+		val, err := strconv.ParseInt(tmp[i], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		pList = append(pList, int32(val))
+	}
+
+	return pList, nil
+}
+
+func printErrorAndExit(code int, format string, values ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ERROR: %s\n", fmt.Sprintf(format, values...))
+	fmt.Fprintln(os.Stderr)
+	os.Exit(code)
+}
+
+func printUsageErrorAndExit(message string) {
+	fmt.Fprintln(os.Stderr, "ERROR:", message)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Available command line options:")
+	flag.PrintDefaults()
+	os.Exit(64)
+}
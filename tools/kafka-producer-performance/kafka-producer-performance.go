@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rcrowley/go-metrics"
+)
+
+var (
+	brokerList        = flag.String("brokers", os.Getenv("KAFKA_PEERS"), "The comma separated list of brokers in the Kafka cluster. You can also set the KAFKA_PEERS environment variable")
+	topic             = flag.String("topic", "", "REQUIRED: the topic to produce to")
+	messageLoad       = flag.Int("message-load", 0, "REQUIRED: the number of messages to produce")
+	messageSize       = flag.Int("message-size", 100, "The size of the value of each message, in bytes. Values are random, so they barely compress")
+	requiredAcks      = flag.Int("required-acks", 1, "The acknowledgements the brokers must send: 0 for none, 1 for the leader's, -1 for all in-sync replicas'")
+	timeout           = flag.Duration("timeout", 10*time.Second, "How long the brokers may wait for the required acknowledgements")
+	partitioner       = flag.String("partitioner", "roundrobin", "The partitioning scheme to use. Can be `roundrobin`, `random`, `hash` (messages have no key) or `manual`")
+	partition         = flag.Int("partition", -1, "The partition to produce to, with the manual partitioner")
+	compression       = flag.String("compression", "none", "The compression codec to use. Can be `none`, `gzip`, `snappy`, `lz4` or `zstd`")
+	flushFrequency    = flag.Duration("flush-frequency", 0, "The best-effort frequency of flushes, 0 to disable")
+	flushBytes        = flag.Int("flush-bytes", 0, "The best-effort number of bytes that triggers a flush, 0 to use the default")
+	flushMessages     = flag.Int("flush-messages", 0, "The best-effort number of messages that triggers a flush, 0 to disable")
+	maxOpenRequests   = flag.Int("max-open-requests", 5, "The maximum number of unacknowledged requests the producer sends to each broker")
+	channelBufferSize = flag.Int("channel-buffer-size", 256, "The buffer size of the producer's channels")
+	verbose           = flag.Bool("verbose", false, "Turn on sarama logging to stderr")
+
+	logger = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+func main() {
+	flag.Parse()
+
+	if *brokerList == "" {
+		printUsageErrorAndExit("no -brokers specified. Alternatively, set the KAFKA_PEERS environment variable")
+	}
+	if *topic == "" {
+		printUsageErrorAndExit("no -topic specified")
+	}
+	if *messageLoad <= 0 {
+		printUsageErrorAndExit("-message-load must be greater than 0")
+	}
+	if *messageSize <= 0 {
+		printUsageErrorAndExit("-message-size must be greater than 0")
+	}
+
+	if *verbose {
+		sarama.Logger = logger
+	}
+
+	config := sarama.NewConfig()
+	config.Producer.RequiredAcks = sarama.RequiredAcks(*requiredAcks)
+	config.Producer.Timeout = *timeout
+	config.Producer.Return.Successes = true
+	config.Producer.Flush.Frequency = *flushFrequency
+	config.Producer.Flush.Messages = *flushMessages
+	if *flushBytes > 0 {
+		config.Producer.Flush.Bytes = *flushBytes
+	}
+	config.Net.MaxOpenRequests = *maxOpenRequests
+	config.ChannelBufferSize = *channelBufferSize
+
+	switch *partitioner {
+	case "roundrobin":
+		config.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	case "random":
+		config.Producer.Partitioner = sarama.NewRandomPartitioner
+	case "hash":
+		config.Producer.Partitioner = sarama.NewHashPartitioner
+	case "manual":
+		config.Producer.Partitioner = sarama.NewManualPartitioner
+		if *partition < 0 {
+			printUsageErrorAndExit("-partition is required when partitioning manually")
+		}
+	default:
+		printUsageErrorAndExit(fmt.Sprintf("Partitioner %s not supported.", *partitioner))
+	}
+
+	switch *compression {
+	case "none":
+		config.Producer.Compression = sarama.CompressionNone
+	case "gzip":
+		config.Producer.Compression = sarama.CompressionGZIP
+	case "snappy":
+		config.Producer.Compression = sarama.CompressionSnappy
+	case "lz4":
+		config.Producer.Compression = sarama.CompressionLZ4
+	case "zstd":
+		config.Producer.Compression = sarama.CompressionZSTD
+	default:
+		printUsageErrorAndExit(fmt.Sprintf("Compression codec %s not supported.", *compression))
+	}
+
+	if err := config.Validate(); err != nil {
+		printErrorAndExit(64, "Invalid configuration: %s", err)
+	}
+
+	producer, err := sarama.NewAsyncProducer(strings.Split(*brokerList, ","), config)
+	if err != nil {
+		printErrorAndExit(69, "Failed to open Kafka producer: %s", err)
+	}
+
+	value := make([]byte, *messageSize)
+	if _, err := rand.Read(value); err != nil {
+		printErrorAndExit(70, "Failed to generate the message value: %s", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; i < *messageLoad; i++ {
+			msg := &sarama.ProducerMessage{
+				Topic:     *topic,
+				Partition: int32(*partition),
+				Value:     sarama.ByteEncoder(value),
+				Metadata:  time.Now(),
+			}
+			select {
+			case producer.Input() <- msg:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var (
+		latency       = metrics.NewHistogram(metrics.NewUniformSample(1e5))
+		tickLatency   = metrics.NewHistogram(metrics.NewUniformSample(1e5))
+		start         = time.Now()
+		ticker        = time.NewTicker(time.Second)
+		succeeded     int
+		failed        int
+		lastTick      = start
+		sinceLastTick int
+		interrupted   bool
+	)
+	defer ticker.Stop()
+
+	for succeeded+failed < *messageLoad && !interrupted {
+		select {
+		case msg := <-producer.Successes():
+			succeeded++
+			sinceLastTick++
+			d := int64(time.Since(msg.Metadata.(time.Time)))
+			latency.Update(d)
+			tickLatency.Update(d)
+		case err := <-producer.Errors():
+			failed++
+			if failed <= 10 {
+				logger.Println("Failed to produce message:", err)
+			}
+		case now := <-ticker.C:
+			printRates(sinceLastTick, now.Sub(lastTick), tickLatency)
+			lastTick, sinceLastTick = now, 0
+			tickLatency.Clear()
+		case <-signals:
+			interrupted = true
+		}
+	}
+	elapsed := time.Since(start)
+	close(stop)
+	<-stopped
+
+	if err := producer.Close(); err != nil {
+		logger.Println("Failed to close Kafka producer cleanly:", err)
+	}
+
+	fmt.Println()
+	fmt.Printf("%d messages produced, %d failed, in %s\n", succeeded, failed, elapsed)
+	printRates(succeeded, elapsed, latency)
+	if interrupted {
+		os.Exit(130)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// printRates prints the throughput of the messages produced in the duration, and
+// the latency between handing messages to the producer and their acknowledgement.
+func printRates(messages int, duration time.Duration, latency metrics.Histogram) {
+	seconds := duration.Seconds()
+	snapshot := latency.Snapshot()
+	ps := snapshot.Percentiles([]float64{0.5, 0.95, 0.99, 0.999})
+	fmt.Printf("%.1f records/sec, %.2f MB/sec, latency avg %s, p50 %s, p95 %s, p99 %s, p99.9 %s, max %s\n",
+		float64(messages)/seconds,
+		float64(messages*(*messageSize))/seconds/1024/1024,
+		time.Duration(snapshot.Mean()), time.Duration(ps[0]), time.Duration(ps[1]), time.Duration(ps[2]), time.Duration(ps[3]), time.Duration(snapshot.Max()),
+	)
+}
+
+func printErrorAndExit(code int, format string, values ...interface{}) {
+	fmt.Fprintf(os.Stderr, "ERROR: %s\n", fmt.Sprintf(format, values...))
+	fmt.Fprintln(os.Stderr)
+	os.Exit(code)
+}
+
+func printUsageErrorAndExit(message string) {
+	fmt.Fprintln(os.Stderr, "ERROR:", message)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Available command line options:")
+	flag.PrintDefaults()
+	os.Exit(64)
+}
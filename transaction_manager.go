@@ -0,0 +1,524 @@
+package sarama
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// txnState is the state of the transaction of a transactional producer.
+type txnState int
+
+const (
+	txnReady         txnState = iota // no transaction is ongoing
+	txnInTransaction                 // a transaction was begun
+	txnAbortable                     // something failed in the ongoing transaction, which can only be aborted
+	txnFatal                         // the producer was fenced, or lost its producer ID; it can only be closed
+)
+
+// transactionManager holds the producer ID and epoch of an idempotent producer,
+// and the sequence number of the next message of each partition it produces to.
+// Brokers only accept the messages of a partition in the order of their
+// sequence numbers, and discard the ones they have already written.
+//
+// For a transactional producer, it also tracks the ongoing transaction, adding
+// partitions and offsets to it with its coordinator before they are written.
+type transactionManager struct {
+	client          Client
+	conf            *Config
+	transactionalID string // empty unless the producer is transactional
+
+	producerID    int64
+	producerEpoch int16
+	generation    int // counts the producer IDs and epochs acquired
+
+	sequences map[string]map[int32]int32
+	lock      sync.Mutex // protects the producer ID and epoch, and sequences
+
+	// the state of the ongoing transaction, protected by txnLock, which is held
+	// through the requests to the coordinators
+	state      txnState
+	err        error // why the transaction is abortable, or the producer fatally failed
+	partitions map[string]map[int32]bool
+	offsets    bool // whether offsets were added to the transaction
+	txnLock    sync.Mutex
+
+	// messages counts the messages of the ongoing transaction that haven't
+	// succeeded or failed yet. Once the dispatcher takes the marker of
+	// endTxn, ending is set until the transaction ended, and the messages sent
+	// after it are refused.
+	messages int
+	ending   bool
+	ended    *sync.Cond // signalled, with txnLock, as messages are done
+	endLock  sync.Mutex // serializes ending transactions
+}
+
+func newTransactionManager(client Client) (*transactionManager, error) {
+	t := &transactionManager{
+		client:          client,
+		conf:            client.Config(),
+		transactionalID: client.Config().Producer.Transaction.ID,
+	}
+	t.ended = sync.NewCond(&t.txnLock)
+	if err := t.initProducerID(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// transactional returns whether the producer is transactional, rather than
+// only idempotent.
+func (t *transactionManager) transactional() bool {
+	return t != nil && t.transactionalID != ""
+}
+
+// initProducerID acquires a producer ID and epoch, and starts numbering the
+// messages of every partition from 0 again. A transactional producer gets the
+// ID of its transactional ID with a new epoch, which aborts any transaction a
+// previous producer with the transactional ID left ongoing, and fences it.
+func (t *transactionManager) initProducerID() error {
+	var response *InitProducerIDResponse
+	if !t.transactional() {
+		var err error
+		if response, err = t.client.InitProducerID(); err != nil {
+			return err
+		}
+		if response.Err != ErrNoError {
+			return response.Err
+		}
+	} else {
+		request := &InitProducerIDRequest{
+			TransactionalID:    &t.transactionalID,
+			TransactionTimeout: t.conf.Producer.Transaction.Timeout,
+		}
+		err := t.retryOnCoordinator(func(coordinator *Broker) error {
+			var err error
+			if response, err = coordinator.InitProducerID(request); err != nil {
+				return err
+			}
+			if response.Err != ErrNoError {
+				return response.Err
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	LogProducer.info("acquired producer ID", "producerID", response.ProducerID, "producerEpoch", response.ProducerEpoch)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.producerID = response.ProducerID
+	t.producerEpoch = response.ProducerEpoch
+	t.generation++
+	t.sequences = make(map[string]map[int32]int32)
+	return nil
+}
+
+// resetProducerID acquires a new producer ID for a non-transactional producer
+// once a message numbered in the given generation failed, unless one was
+// acquired since. The message left a gap in the sequence numbers of its
+// partition, which brokers would fail every following message for with
+// ErrOutOfOrderSequenceNumber, so messages are numbered from 0 again instead.
+func (t *transactionManager) resetProducerID(generation int, err error) {
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+
+	t.lock.Lock()
+	current := t.generation
+	t.lock.Unlock()
+	if generation != current {
+		return
+	}
+
+	LogProducer.warn("message failed, acquiring a new producer ID", "err", err)
+	if err := t.initProducerID(); err != nil {
+		// the next message to fail tries again
+		LogProducer.error("failed to acquire a new producer ID", "err", err)
+	}
+}
+
+// producer returns the producer ID and epoch.
+func (t *transactionManager) producer() (int64, int16) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.producerID, t.producerEpoch
+}
+
+// sequence numbers the message with the next sequence number of its
+// partition, unless it was numbered with the current producer ID and epoch
+// already, as it is when it is retried.
+func (t *transactionManager) sequence(msg *ProducerMessage) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if msg.sequenced && msg.generation == t.generation {
+		return
+	}
+	partitions := t.sequences[msg.Topic]
+	if partitions == nil {
+		partitions = make(map[int32]int32)
+		t.sequences[msg.Topic] = partitions
+	}
+	msg.sequence, msg.generation, msg.sequenced = partitions[msg.Partition], t.generation, true
+	partitions[msg.Partition] = sequenceAfter(msg.sequence, 1)
+}
+
+// peekSequence returns the sequence number and generation sequence would
+// number the message with.
+func (t *transactionManager) peekSequence(msg *ProducerMessage) (int32, int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if msg.sequenced && msg.generation == t.generation {
+		return msg.sequence, msg.generation
+	}
+	return t.sequences[msg.Topic][msg.Partition], t.generation
+}
+
+// sequenceAfter returns the sequence number n messages after the given one;
+// sequence numbers wrap around to 0 after math.MaxInt32, as brokers expect.
+func sequenceAfter(sequence int32, n int) int32 {
+	return int32((int64(sequence) + int64(n)) % (math.MaxInt32 + 1))
+}
+
+// begin begins a transaction.
+func (t *transactionManager) begin() error {
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+
+	switch t.state {
+	case txnReady:
+	case txnFatal:
+		return t.err
+	default:
+		return ErrTransactionNotReady
+	}
+	t.state = txnInTransaction
+	t.partitions = make(map[string]map[int32]bool)
+	t.offsets = false
+	return nil
+}
+
+// canProduce returns why the message can't be produced now, if it can't: it
+// has to be produced in a transaction, before anything failed in it and before
+// it is being ended. Otherwise the message counts as one of the transaction's
+// until messageDone.
+func (t *transactionManager) canProduce(msg *ProducerMessage) error {
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+
+	msg.inTxn = false
+	switch {
+	case t.ending, t.state == txnReady:
+		return ErrTransactionNotReady
+	case t.state != txnInTransaction:
+		return t.err
+	}
+	msg.inTxn = true
+	t.messages++
+	return nil
+}
+
+// messageDone records that a message of the ongoing transaction succeeded or
+// failed.
+func (t *transactionManager) messageDone() {
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+
+	t.messages--
+	if t.messages == 0 {
+		t.ended.Broadcast()
+	}
+}
+
+// stopProducing marks the ongoing transaction as being ended, which refuses
+// the messages sent from now on; the dispatcher calls it as it takes the
+// marker of endTxn, after the messages sent before it.
+func (t *transactionManager) stopProducing() {
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+
+	t.ending = true
+	t.ended.Broadcast()
+}
+
+// waitForMessages waits for the dispatcher to take the marker of endTxn, and
+// then for the messages of the transaction to succeed or fail.
+func (t *transactionManager) waitForMessages() {
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+
+	for !t.ending || t.messages > 0 {
+		t.ended.Wait()
+	}
+}
+
+// fail records that something failed in the ongoing transaction, which can
+// then only be aborted, or that the producer was fenced by an other. It must
+// be called with txnLock held.
+func (t *transactionManager) fail(err error) {
+	switch {
+	case err == ErrInvalidProducerEpoch || err == ErrTransactionalIDAuthorizationFailed || err == ErrInvalidProducerIDMapping:
+		LogProducer.error("transactional producer failed", "transactionalID", t.transactionalID, "err", err)
+		t.state, t.err = txnFatal, err
+	case t.state == txnInTransaction:
+		LogProducer.warn("transaction must be aborted", "transactionalID", t.transactionalID, "err", err)
+		t.state, t.err = txnAbortable, err
+	}
+}
+
+// messageFailed records that a message of the ongoing transaction failed. A
+// non-transactional producer acquires a new producer ID instead if the message
+// was numbered.
+func (t *transactionManager) messageFailed(msg *ProducerMessage, err error) {
+	if t == nil {
+		return
+	}
+	if !t.transactional() {
+		if msg.sequenced {
+			t.resetProducerID(msg.generation, err)
+		}
+		return
+	}
+	if !msg.inTxn {
+		// refused before it was part of the transaction
+		return
+	}
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+	t.fail(err)
+}
+
+// addPartitions adds the partitions of the set that weren't yet to the ongoing
+// transaction, which has to be done before they are produced to.
+func (t *transactionManager) addPartitions(set *produceSet) error {
+	if !t.transactional() {
+		return nil
+	}
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+
+	switch t.state {
+	case txnInTransaction:
+	case txnReady:
+		return ErrTransactionNotReady
+	default:
+		return t.err
+	}
+
+	added := make(map[string][]int32)
+	set.eachPartition(func(topic string, partition int32, _ []*ProducerMessage) {
+		if !t.partitions[topic][partition] {
+			added[topic] = append(added[topic], partition)
+		}
+	})
+	if len(added) == 0 {
+		return nil
+	}
+
+	producerID, producerEpoch := t.producer()
+	request := &AddPartitionsToTxnRequest{
+		TransactionalID: t.transactionalID,
+		ProducerID:      producerID,
+		ProducerEpoch:   producerEpoch,
+		TopicPartitions: added,
+	}
+	err := t.retryOnCoordinator(func(coordinator *Broker) error {
+		response, err := coordinator.AddPartitionsToTxn(request)
+		if err != nil {
+			return err
+		}
+		for _, partitions := range response.Errors {
+			for _, partition := range partitions {
+				if partition.Err != ErrNoError {
+					return partition.Err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.fail(err)
+		return err
+	}
+
+	for topic, partitions := range added {
+		if t.partitions[topic] == nil {
+			t.partitions[topic] = make(map[int32]bool)
+		}
+		for _, partition := range partitions {
+			t.partitions[topic][partition] = true
+		}
+	}
+	return nil
+}
+
+// addOffsets adds offsets of the group to the ongoing transaction, and commits
+// them in it.
+func (t *transactionManager) addOffsets(offsets map[string][]*PartitionOffsetMetadata, groupID string) error {
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+
+	switch t.state {
+	case txnInTransaction:
+	case txnReady:
+		return ErrTransactionNotReady
+	default:
+		return t.err
+	}
+
+	producerID, producerEpoch := t.producer()
+	err := t.retryOnCoordinator(func(coordinator *Broker) error {
+		response, err := coordinator.AddOffsetsToTxn(&AddOffsetsToTxnRequest{
+			TransactionalID: t.transactionalID,
+			ProducerID:      producerID,
+			ProducerEpoch:   producerEpoch,
+			GroupID:         groupID,
+		})
+		if err != nil {
+			return err
+		}
+		if response.Err != ErrNoError {
+			return response.Err
+		}
+		return nil
+	})
+	if err != nil {
+		t.fail(err)
+		return err
+	}
+	t.offsets = true
+
+	request := &TxnOffsetCommitRequest{
+		TransactionalID: t.transactionalID,
+		GroupID:         groupID,
+		ProducerID:      producerID,
+		ProducerEpoch:   producerEpoch,
+		Topics:          offsets,
+	}
+	coordinator := func() (*Broker, error) { return t.client.Coordinator(groupID) }
+	refresh := func() error { return t.client.RefreshCoordinator(groupID) }
+	err = t.retry(coordinator, refresh, func(coordinator *Broker) error {
+		response, err := coordinator.TxnOffsetCommit(request)
+		if err != nil {
+			return err
+		}
+		for _, partitions := range response.Topics {
+			for _, partition := range partitions {
+				if partition.Err != ErrNoError {
+					return partition.Err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.fail(err)
+		return err
+	}
+	return nil
+}
+
+// end commits or aborts the ongoing transaction, once its messages have all
+// succeeded or failed, and then takes messages again.
+func (t *transactionManager) end(commit bool) error {
+	t.txnLock.Lock()
+	defer t.txnLock.Unlock()
+	defer func() { t.ending = false }()
+
+	switch t.state {
+	case txnReady:
+		return ErrTransactionNotReady
+	case txnFatal:
+		return t.err
+	case txnAbortable:
+		if commit {
+			return t.err
+		}
+		// messages that failed may have left gaps in the sequence numbers of
+		// their partitions, so the transaction is aborted by acquiring a new
+		// epoch instead, which numbers messages from 0 again
+		if err := t.initProducerID(); err != nil {
+			t.fail(err)
+			return err
+		}
+		t.state, t.err = txnReady, nil
+		return nil
+	}
+
+	// an empty transaction is not known to the coordinator
+	if len(t.partitions) > 0 || t.offsets {
+		producerID, producerEpoch := t.producer()
+		request := &EndTxnRequest{
+			TransactionalID: t.transactionalID,
+			ProducerID:      producerID,
+			ProducerEpoch:   producerEpoch,
+			Committed:       commit,
+		}
+		err := t.retryOnCoordinator(func(coordinator *Broker) error {
+			response, err := coordinator.EndTxn(request)
+			if err != nil {
+				return err
+			}
+			if response.Err != ErrNoError {
+				return response.Err
+			}
+			return nil
+		})
+		if err != nil {
+			t.fail(err)
+			return err
+		}
+	}
+	t.state = txnReady
+	return nil
+}
+
+// retryOnCoordinator calls fn with the coordinator of the transactional ID, as
+// retry does.
+func (t *transactionManager) retryOnCoordinator(fn func(coordinator *Broker) error) error {
+	coordinator := func() (*Broker, error) { return t.client.TransactionCoordinator(t.transactionalID) }
+	refresh := func() error { return t.client.RefreshTransactionCoordinator(t.transactionalID) }
+	return t.retry(coordinator, refresh, fn)
+}
+
+// retry calls fn with a coordinator, up to Producer.Retry.Max more times while
+// it fails with an error worth retrying: a network error, or the coordinator
+// being unavailable, loading, busy with another operation on the transaction,
+// or no longer the coordinator, in which case it is refreshed first.
+func (t *transactionManager) retry(coordinator func() (*Broker, error), refresh func() error, fn func(coordinator *Broker) error) error {
+	for attempt := 0; ; attempt++ {
+		broker, err := coordinator()
+		if err == nil {
+			if err = fn(broker); err != nil {
+				if _, ok := err.(KError); !ok {
+					_ = broker.Close()
+				}
+			}
+		}
+
+		switch err {
+		case nil:
+			return nil
+		case ErrConsumerCoordinatorNotAvailable, ErrNotCoordinatorForConsumer, ErrOffsetsLoadInProgress, ErrConcurrentTransactions:
+		default:
+			if _, ok := err.(KError); ok {
+				return err
+			}
+		}
+		if attempt >= t.conf.Producer.Retry.Max {
+			return err
+		}
+
+		LogProducer.warn("retrying transaction request", "transactionalID", t.transactionalID, "err", err)
+		time.Sleep(t.conf.Producer.Retry.Backoff)
+		if err != ErrConcurrentTransactions && err != ErrOffsetsLoadInProgress {
+			if err := refresh(); err != nil {
+				LogProducer.warn("failed to refresh coordinator", "transactionalID", t.transactionalID, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package sarama
+
+// PartitionOffsetMetadata is the offset to commit for a partition, with its
+// metadata, which may be nil.
+type PartitionOffsetMetadata struct {
+	Partition int32
+	Offset    int64
+	Metadata  *string
+}
+
+func (m *PartitionOffsetMetadata) encode(pe packetEncoder) error {
+	pe.putInt32(m.Partition)
+	pe.putInt64(m.Offset)
+	return putNullableString(pe, m.Metadata)
+}
+
+func (m *PartitionOffsetMetadata) decode(pd packetDecoder) (err error) {
+	if m.Partition, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if m.Offset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	m.Metadata, err = getNullableString(pd)
+	return err
+}
+
+// TxnOffsetCommitRequest commits offsets of a consumer group in the ongoing
+// transaction of a transactional producer, once they were added to it with an
+// AddOffsetsToTxnRequest; they only take effect if the transaction is
+// committed. It has to be sent to the coordinator of the group, and requires
+// Kafka 0.11.
+type TxnOffsetCommitRequest struct {
+	TransactionalID string
+	GroupID         string
+	ProducerID      int64
+	ProducerEpoch   int16
+	Topics          map[string][]*PartitionOffsetMetadata
+}
+
+func (r *TxnOffsetCommitRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.TransactionalID); err != nil {
+		return err
+	}
+	if err := pe.putString(r.GroupID); err != nil {
+		return err
+	}
+	pe.putInt64(r.ProducerID)
+	pe.putInt16(r.ProducerEpoch)
+
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range r.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(partitions)); err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			if err := partition.encode(pe); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *TxnOffsetCommitRequest) decode(pd packetDecoder) (err error) {
+	if r.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.GroupID, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if r.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	r.Topics = make(map[string][]*PartitionOffsetMetadata, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		partitions := make([]*PartitionOffsetMetadata, m)
+		for j := range partitions {
+			partitions[j] = new(PartitionOffsetMetadata)
+			if err := partitions[j].decode(pd); err != nil {
+				return err
+			}
+		}
+		r.Topics[topic] = partitions
+	}
+	return nil
+}
+
+func (r *TxnOffsetCommitRequest) key() int16 {
+	return 28
+}
+
+func (r *TxnOffsetCommitRequest) version() int16 {
+	return 0
+}
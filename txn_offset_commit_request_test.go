@@ -0,0 +1,34 @@
+package sarama
+
+import "testing"
+
+var txnOffsetCommitRequest = []byte{
+	0, 3, 't', 'x', 'n',
+	0, 5, 'g', 'r', 'o', 'u', 'p',
+	0, 0, 0, 0, 0, 0, 31, 64, // ProducerID
+	0, 1, // ProducerEpoch
+	0, 0, 0, 1, // 1 topic
+	0, 5, 't', 'o', 'p', 'i', 'c',
+	0, 0, 0, 2, // 2 partitions
+	0, 0, 0, 1,
+	0, 0, 0, 0, 0, 0, 0, 123, // Offset
+	255, 255, // no Metadata
+	0, 0, 0, 2,
+	0, 0, 0, 0, 0, 0, 0, 45,
+	0, 4, 'm', 'e', 't', 'a',
+}
+
+func TestTxnOffsetCommitRequest(t *testing.T) {
+	metadata := "meta"
+	request := &TxnOffsetCommitRequest{
+		TransactionalID: "txn",
+		GroupID:         "group",
+		ProducerID:      8000,
+		ProducerEpoch:   1,
+		Topics: map[string][]*PartitionOffsetMetadata{"topic": {
+			{Partition: 1, Offset: 123},
+			{Partition: 2, Offset: 45, Metadata: &metadata},
+		}},
+	}
+	testRequest(t, "", request, txnOffsetCommitRequest)
+}
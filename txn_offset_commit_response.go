@@ -0,0 +1,24 @@
+package sarama
+
+import "time"
+
+type TxnOffsetCommitResponse struct {
+	ThrottleTime time.Duration
+	Topics       map[string][]*PartitionError
+}
+
+func (r *TxnOffsetCommitResponse) encode(pe packetEncoder) error {
+	pe.putInt32(int32(r.ThrottleTime / time.Millisecond))
+	return encodePartitionErrors(pe, r.Topics)
+}
+
+func (r *TxnOffsetCommitResponse) decode(pd packetDecoder) (err error) {
+	millis, err := pd.getInt32()
+	if err != nil {
+		return err
+	}
+	r.ThrottleTime = time.Duration(millis) * time.Millisecond
+
+	r.Topics, err = decodePartitionErrors(pd)
+	return err
+}
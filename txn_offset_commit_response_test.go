@@ -0,0 +1,23 @@
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var txnOffsetCommitResponse = []byte{
+	0, 0, 0, 100, // ThrottleTime
+	0, 0, 0, 1, // 1 topic
+	0, 5, 't', 'o', 'p', 'i', 'c',
+	0, 0, 0, 1, // 1 partition
+	0, 0, 0, 2,
+	0, 0, // ErrNoError
+}
+
+func TestTxnOffsetCommitResponse(t *testing.T) {
+	response := &TxnOffsetCommitResponse{
+		ThrottleTime: 100 * time.Millisecond,
+		Topics:       map[string][]*PartitionError{"topic": {{Partition: 2, Err: ErrNoError}}},
+	}
+	testResponse(t, "", response, txnOffsetCommitResponse)
+}
@@ -0,0 +1,140 @@
+//go:build go1.18
+// +build go1.18
+
+package sarama
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TypedEncoder converts values of type T into the bytes of a message key or value.
+// An encoder returning a nil slice produces a null key or value.
+type TypedEncoder[T any] interface {
+	Encode(v T) ([]byte, error)
+}
+
+// TypedDecoder converts the bytes of a consumed message key or value back into a T.
+type TypedDecoder[T any] interface {
+	Decode(data []byte) (T, error)
+}
+
+// StringCodec is a TypedEncoder and TypedDecoder for Go strings.
+type StringCodec struct{}
+
+func (StringCodec) Encode(v string) ([]byte, error) {
+	return []byte(v), nil
+}
+
+func (StringCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// BytesCodec is a TypedEncoder and TypedDecoder for byte slices, which are passed
+// through unchanged. Nil slices are sent as null.
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(v []byte) ([]byte, error) {
+	return v, nil
+}
+
+func (BytesCodec) Decode(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+// JSONCodec is a TypedEncoder and TypedDecoder which marshals values of type T with
+// encoding/json.
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	if data == nil {
+		return v, ErrNullValue
+	}
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// TypedProducer wraps a SyncProducer so that keys and values are passed as values of
+// type K and V, encoded with the given encoders, instead of as Encoders.
+type TypedProducer[K, V any] struct {
+	producer SyncProducer
+	keys     TypedEncoder[K]
+	values   TypedEncoder[V]
+}
+
+// NewTypedProducer returns a TypedProducer sending through producer. Closing the
+// underlying producer remains the caller's responsibility.
+func NewTypedProducer[K, V any](producer SyncProducer, keys TypedEncoder[K], values TypedEncoder[V]) *TypedProducer[K, V] {
+	return &TypedProducer[K, V]{producer: producer, keys: keys, values: values}
+}
+
+// SendMessage encodes key and value and produces them to the given topic, returning
+// as SyncProducer.SendMessage does. Encoding errors are returned without anything
+// being sent.
+func (tp *TypedProducer[K, V]) SendMessage(topic string, key K, value V) (partition int32, offset int64, err error) {
+	return tp.SendMessageContext(context.Background(), topic, key, value)
+}
+
+// SendMessageContext is like SendMessage, but returns as
+// SyncProducer.SendMessageContext does.
+func (tp *TypedProducer[K, V]) SendMessageContext(ctx context.Context, topic string, key K, value V) (partition int32, offset int64, err error) {
+	encodedKey, err := tp.keys.Encode(key)
+	if err != nil {
+		return -1, -1, err
+	}
+	encodedValue, err := tp.values.Encode(value)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	msg := &ProducerMessage{Topic: topic, Key: ByteEncoder(encodedKey), Value: ByteEncoder(encodedValue)}
+	return tp.producer.SendMessageContext(ctx, msg)
+}
+
+// TypedConsumerMessage is a ConsumerMessage whose key and value have been decoded.
+type TypedConsumerMessage[K, V any] struct {
+	Key       K
+	Value     V
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+// TypedPartitionConsumer wraps a PartitionConsumer with decoders for its keys and
+// values. Messages are still read from Messages(), and decoded with Decode, so that
+// decoding errors can be handled per message without an extra channel to drain.
+type TypedPartitionConsumer[K, V any] struct {
+	PartitionConsumer
+	keys   TypedDecoder[K]
+	values TypedDecoder[V]
+}
+
+// NewTypedPartitionConsumer returns a TypedPartitionConsumer wrapping pc.
+func NewTypedPartitionConsumer[K, V any](pc PartitionConsumer, keys TypedDecoder[K], values TypedDecoder[V]) *TypedPartitionConsumer[K, V] {
+	return &TypedPartitionConsumer[K, V]{PartitionConsumer: pc, keys: keys, values: values}
+}
+
+// Decode decodes the key and value of a message received from Messages().
+func (tpc *TypedPartitionConsumer[K, V]) Decode(msg *ConsumerMessage) (*TypedConsumerMessage[K, V], error) {
+	key, err := tpc.keys.Decode(msg.Key)
+	if err != nil {
+		return nil, err
+	}
+	value, err := tpc.values.Decode(msg.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TypedConsumerMessage[K, V]{
+		Key:       key,
+		Value:     value,
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	}, nil
+}
@@ -0,0 +1,91 @@
+//go:build go1.18
+// +build go1.18
+
+package sarama
+
+import (
+	"context"
+	"testing"
+)
+
+type recordingSyncProducer struct {
+	SyncProducer // unused, for the transactional methods
+	sent         []*ProducerMessage
+}
+
+func (p *recordingSyncProducer) SendMessage(msg *ProducerMessage) (int32, int64, error) {
+	return p.SendMessageContext(context.Background(), msg)
+}
+
+func (p *recordingSyncProducer) SendMessageContext(ctx context.Context, msg *ProducerMessage) (int32, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, -1, err
+	}
+	p.sent = append(p.sent, msg)
+	return 0, int64(len(p.sent) - 1), nil
+}
+
+func (p *recordingSyncProducer) Close() error {
+	return nil
+}
+
+func TestTypedProducer(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	typed := NewTypedProducer[string, jsonTestRecord](producer, StringCodec{}, JSONCodec[jsonTestRecord]{})
+
+	if _, offset, err := typed.SendMessage("my_topic", "key", jsonTestRecord{Name: "foo", Count: 3}); err != nil || offset != 0 {
+		t.Fatal(offset, err)
+	}
+
+	msg := producer.sent[0]
+	key, _ := msg.Key.Encode()
+	value, _ := msg.Value.Encode()
+	if msg.Topic != "my_topic" || string(key) != "key" || string(value) != `{"name":"foo","count":3}` {
+		t.Error("Unexpected message produced:", msg.Topic, string(key), string(value))
+	}
+}
+
+func TestTypedProducerContext(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	typed := NewTypedProducer[string, string](producer, StringCodec{}, StringCodec{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := typed.SendMessageContext(ctx, "my_topic", "key", "value"); err != context.Canceled || len(producer.sent) != 0 {
+		t.Error("Expected a done context to stop the send, got", err)
+	}
+}
+
+func TestTypedProducerEncodingError(t *testing.T) {
+	producer := &recordingSyncProducer{}
+	typed := NewTypedProducer[[]byte, chan int](producer, BytesCodec{}, JSONCodec[chan int]{})
+
+	if _, _, err := typed.SendMessage("my_topic", nil, make(chan int)); err == nil {
+		t.Error("Expected an encoding error")
+	}
+	if len(producer.sent) != 0 {
+		t.Error("Message was sent despite failing to encode")
+	}
+}
+
+func TestTypedPartitionConsumerDecode(t *testing.T) {
+	consumer := NewTypedPartitionConsumer[string, jsonTestRecord](nil, StringCodec{}, JSONCodec[jsonTestRecord]{})
+
+	msg, err := consumer.Decode(&ConsumerMessage{
+		Key:       []byte("key"),
+		Value:     []byte(`{"name":"foo","count":3}`),
+		Topic:     "my_topic",
+		Partition: 2,
+		Offset:    7,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Key != "key" || msg.Value.Name != "foo" || msg.Value.Count != 3 || msg.Topic != "my_topic" || msg.Partition != 2 || msg.Offset != 7 {
+		t.Error("Unexpected decoded message:", msg)
+	}
+
+	if _, err := consumer.Decode(&ConsumerMessage{Key: []byte("key")}); err != ErrNullValue {
+		t.Error("Expected ErrNullValue decoding a null JSON value, got", err)
+	}
+}
@@ -51,7 +51,7 @@ func safeAsyncClose(b *Broker) {
 	go withRecover(func() {
 		if connected, _ := tmp.Connected(); connected {
 			if err := tmp.Close(); err != nil {
-				Logger.Println("Error closing broker", tmp.ID(), ":", err)
+				LogBroker.error("error closing broker", "broker", tmp.ID(), "err", err)
 			}
 		}
 	})
@@ -60,6 +60,11 @@ func safeAsyncClose(b *Broker) {
 // Encoder is a simple interface for any type that can be encoded as an array of bytes
 // in order to be sent as the key or value of a Kafka message. Length() is provided as an
 // optimization, and must return the same as len() on the result of Encode().
+//
+// Kafka distinguishes between a null key or value and an empty one. A nil Encoder, or an
+// Encoder whose Encode() returns a nil slice, is sent as null; a non-nil slice of length
+// zero is sent as an empty key or value. ByteEncoder(nil) is therefore null, while
+// StringEncoder("") and ByteEncoder([]byte{}) are empty.
 type Encoder interface {
 	Encode() ([]byte, error)
 	Length() int
@@ -81,7 +86,7 @@ func (s StringEncoder) Length() int {
 }
 
 // ByteEncoder implements the Encoder interface for Go byte slices so that they can be used
-// as the Key or Value in a ProducerMessage.
+// as the Key or Value in a ProducerMessage. A nil ByteEncoder encodes as null.
 type ByteEncoder []byte
 
 func (b ByteEncoder) Encode() ([]byte, error) {
@@ -106,6 +111,63 @@ func newBufConn(conn net.Conn) *bufConn {
 	}
 }
 
+// writeBuffers writes the buffers to the underlying connection, which lets plain
+// TCP connections use vectored I/O; only reads go through the buffer.
+func (bc *bufConn) writeBuffers(buffers net.Buffers) (int64, error) {
+	return buffers.WriteTo(bc.Conn)
+}
+
 func (bc *bufConn) Read(b []byte) (n int, err error) {
 	return bc.buf.Read(b)
 }
+
+// KafkaVersion instances represent versions of the upstream Kafka broker.
+type KafkaVersion struct {
+	// it's a struct rather than just typing the array directly to make it opaque and stop people
+	// generating their own arbitrary versions
+	version [4]uint
+}
+
+func newKafkaVersion(major, minor, veryMinor, patch uint) KafkaVersion {
+	return KafkaVersion{
+		version: [4]uint{major, minor, veryMinor, patch},
+	}
+}
+
+// IsAtLeast return true if and only if the version it is called on is
+// greater than or equal to the version passed in:
+//
+//	V1.IsAtLeast(V2) // false
+//	V2.IsAtLeast(V1) // true
+func (v KafkaVersion) IsAtLeast(other KafkaVersion) bool {
+	for i := range v.version {
+		if v.version[i] > other.version[i] {
+			return true
+		} else if v.version[i] < other.version[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Effective constants defining the supported kafka versions.
+var (
+	V0_8_2_0   = newKafkaVersion(0, 8, 2, 0)
+	V0_8_2_1   = newKafkaVersion(0, 8, 2, 1)
+	V0_8_2_2   = newKafkaVersion(0, 8, 2, 2)
+	V0_9_0_0   = newKafkaVersion(0, 9, 0, 0)
+	V0_9_0_1   = newKafkaVersion(0, 9, 0, 1)
+	V0_10_0_0  = newKafkaVersion(0, 10, 0, 0)
+	V0_10_0_1  = newKafkaVersion(0, 10, 0, 1)
+	V0_10_1_0  = newKafkaVersion(0, 10, 1, 0)
+	V0_10_2_0  = newKafkaVersion(0, 10, 2, 0)
+	V0_11_0_0  = newKafkaVersion(0, 11, 0, 0)
+	V1_0_0_0   = newKafkaVersion(1, 0, 0, 0)
+	V1_1_0_0   = newKafkaVersion(1, 1, 0, 0)
+	V2_0_0_0   = newKafkaVersion(2, 0, 0, 0)
+	V2_1_0_0   = newKafkaVersion(2, 1, 0, 0)
+	V2_2_0_0   = newKafkaVersion(2, 2, 0, 0)
+	V2_3_0_0   = newKafkaVersion(2, 3, 0, 0)
+	V3_7_0_0   = newKafkaVersion(3, 7, 0, 0)
+	minVersion = V0_8_2_0
+)
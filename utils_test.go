@@ -0,0 +1,41 @@
+package sarama
+
+import "testing"
+
+func TestVersionCompare(t *testing.T) {
+	if V0_8_2_0.IsAtLeast(V0_8_2_1) {
+		t.Error("0.8.2.0 >= 0.8.2.1")
+	}
+	if !V0_8_2_1.IsAtLeast(V0_8_2_0) {
+		t.Error("! 0.8.2.1 >= 0.8.2.0")
+	}
+	if !V0_8_2_0.IsAtLeast(V0_8_2_0) {
+		t.Error("! 0.8.2.0 >= 0.8.2.0")
+	}
+	if !V0_9_0_0.IsAtLeast(V0_8_2_1) {
+		t.Error("! 0.9.0.0 >= 0.8.2.1")
+	}
+	if V0_8_2_1.IsAtLeast(V0_10_0_0) {
+		t.Error("0.8.2.1 >= 0.10.0.0")
+	}
+}
+
+func TestEncoderLengthMatchesEncode(t *testing.T) {
+	encoders := []Encoder{
+		StringEncoder(""),
+		StringEncoder("foo"),
+		ByteEncoder(nil),
+		ByteEncoder([]byte{0x00, 0x01}),
+		NewJSONEncoder(map[string]int{"a": 1}),
+	}
+
+	for i, encoder := range encoders {
+		encoded, err := encoder.Encode()
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if encoder.Length() != len(encoded) {
+			t.Errorf("Encoder #%d reports Length() %d but encodes to %d bytes", i, encoder.Length(), len(encoded))
+		}
+	}
+}
@@ -17,6 +17,8 @@ while ! nc -q 1 localhost 21805 </dev/null; do echo "Waiting"; sleep 1; done
 # Launch and wait for Kafka
 for i in 1 2 3 4 5; do
     KAFKA_PORT=`expr $i + 9090`
-    cd ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT} && bin/kafka-server-start.sh -daemon config/server.properties
+    cd ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT} && \
+        KAFKA_OPTS=-Djava.security.auth.login.config=config/kafka_server_jaas.conf \
+        bin/kafka-server-start.sh -daemon config/server.properties
 done
 while ! nc -q 1 localhost 29095 </dev/null; do echo "Waiting"; sleep 1; done
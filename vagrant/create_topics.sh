@@ -6,3 +6,12 @@ cd ${KAFKA_INSTALL_ROOT}/kafka-9092
 bin/kafka-topics.sh --create --partitions 1 --replication-factor 3 --topic test.1 --zookeeper localhost:2181
 bin/kafka-topics.sh --create --partitions 4 --replication-factor 3 --topic test.4 --zookeeper localhost:2181
 bin/kafka-topics.sh --create --partitions 64 --replication-factor 3 --topic test.64  --zookeeper localhost:2181
+
+# the SCRAM credentials of the user of the SASL functional tests
+case ${KAFKA_VERSION} in
+    0.8.*|0.9.*|0.10.0.*|0.10.1.*)
+        ;;
+    *)
+        bin/kafka-configs.sh --alter --add-config 'SCRAM-SHA-256=[password=sarama-secret]' --entity-type users --entity-name sarama --zookeeper localhost:2181
+        ;;
+esac
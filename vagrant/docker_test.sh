@@ -0,0 +1,45 @@
+#!/bin/sh
+
+# Runs the functional tests against a dockerized cluster for each of the Kafka
+# versions given as arguments (by default the ones tested on Travis, and later
+# ones for the tests of SASL, consumer groups and the admin APIs), recreating
+# the cluster for each version. Extra `go test` flags can be set in TESTFLAGS.
+
+set -e
+
+cd `dirname $0`/..
+
+VERSIONS="$*"
+if [ -z "${VERSIONS}" ]; then
+    VERSIONS="0.8.1.1 0.8.2.2 0.9.0.0 0.10.2.0 2.1.0"
+fi
+CONTAINER=sarama-functional
+
+cleanup() {
+    docker rm -f ${CONTAINER} >/dev/null 2>&1 || true
+}
+trap cleanup EXIT
+
+for KAFKA_VERSION in ${VERSIONS}; do
+    cleanup
+    docker build --build-arg KAFKA_VERSION=${KAFKA_VERSION} -t sarama-functional:${KAFKA_VERSION} -f vagrant/Dockerfile .
+    docker run -d --name ${CONTAINER} \
+        -p 8474:8474 -p 2181-2185:2181-2185 -p 9091-9095:9091-9095 -p 29191-29195:29191-29195 \
+        sarama-functional:${KAFKA_VERSION}
+
+    echo "Waiting for Kafka ${KAFKA_VERSION}"
+    until docker exec ${CONTAINER} test -f /tmp/cluster_ready; do
+        if [ -z "`docker ps -q -f name=${CONTAINER}`" ]; then
+            docker logs ${CONTAINER}
+            exit 1
+        fi
+        sleep 1
+    done
+
+    CI=true \
+    KAFKA_VERSION=${KAFKA_VERSION} \
+    KAFKA_PEERS=localhost:9091,localhost:9092,localhost:9093,localhost:9094,localhost:9095 \
+    KAFKA_SASL_PEERS=localhost:29191,localhost:29192,localhost:29193,localhost:29194,localhost:29195 \
+    TOXIPROXY_ADDR=http://localhost:8474 \
+        go test ${TESTFLAGS} ./...
+done
@@ -2,14 +2,36 @@
 
 set -ex
 
-TOXIPROXY_VERSION=1.0.3
+TOXIPROXY_VERSION=2.1.4
+
+# Kafka stopped being built for Scala 2.10 in 0.11. The brokers listen for SASL
+# authentication with the mechanisms their version supports, on their own
+# ports that are not proxied.
+case ${KAFKA_VERSION} in
+    0.8.*|0.9.*)
+        SCALA_VERSION=2.10
+        SASL_MECHANISMS=
+        ;;
+    0.10.0.*|0.10.1.*)
+        SCALA_VERSION=2.10
+        SASL_MECHANISMS=PLAIN
+        ;;
+    0.10.*)
+        SCALA_VERSION=2.10
+        SASL_MECHANISMS=PLAIN,SCRAM-SHA-256
+        ;;
+    *)
+        SCALA_VERSION=2.12
+        SASL_MECHANISMS=PLAIN,SCRAM-SHA-256
+        ;;
+esac
 
 mkdir -p ${KAFKA_INSTALL_ROOT}
 if [ ! -f ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_VERSION}.tgz ]; then
-    wget --quiet http://apache.mirror.gtcomm.net/kafka/${KAFKA_VERSION}/kafka_2.10-${KAFKA_VERSION}.tgz -O ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_VERSION}.tgz
+    wget --quiet https://archive.apache.org/dist/kafka/${KAFKA_VERSION}/kafka_${SCALA_VERSION}-${KAFKA_VERSION}.tgz -O ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_VERSION}.tgz
 fi
 if [ ! -f ${KAFKA_INSTALL_ROOT}/toxiproxy-${TOXIPROXY_VERSION} ]; then
-    wget --quiet https://github.com/Shopify/toxiproxy/releases/download/v${TOXIPROXY_VERSION}/toxiproxy-linux-amd64 -O ${KAFKA_INSTALL_ROOT}/toxiproxy-${TOXIPROXY_VERSION}
+    wget --quiet https://github.com/Shopify/toxiproxy/releases/download/v${TOXIPROXY_VERSION}/toxiproxy-server-linux-amd64 -O ${KAFKA_INSTALL_ROOT}/toxiproxy-${TOXIPROXY_VERSION}
     chmod +x ${KAFKA_INSTALL_ROOT}/toxiproxy-${TOXIPROXY_VERSION}
 fi
 rm -f ${KAFKA_INSTALL_ROOT}/toxiproxy
@@ -20,6 +42,7 @@ for i in 1 2 3 4 5; do
     ZK_PORT_REAL=`expr $i + 21800`
     KAFKA_PORT=`expr $i + 9090`
     KAFKA_PORT_REAL=`expr $i + 29090`
+    SASL_PORT=`expr $i + 29190`
 
     # unpack kafka
     mkdir -p ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT}
@@ -31,6 +54,15 @@ for i in 1 2 3 4 5; do
     sed -i s/KAFKAPORT/${KAFKA_PORT_REAL}/g ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT}/config/server.properties
     sed -i s/KAFKA_HOSTNAME/${KAFKA_HOSTNAME}/g ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT}/config/server.properties
     sed -i s/ZK_PORT/${ZK_PORT}/g ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT}/config/server.properties
+    if [ -n "${SASL_MECHANISMS}" ]; then
+        cat >> ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT}/config/server.properties <<EOF
+
+listeners=PLAINTEXT://:${KAFKA_PORT_REAL},SASL_PLAINTEXT://:${SASL_PORT}
+advertised.listeners=PLAINTEXT://${KAFKA_HOSTNAME}:${KAFKA_PORT},SASL_PLAINTEXT://${KAFKA_HOSTNAME}:${SASL_PORT}
+sasl.enabled.mechanisms=${SASL_MECHANISMS}
+EOF
+    fi
+    cp ${REPOSITORY_ROOT}/vagrant/kafka_server_jaas.conf ${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT}/config/
 
     KAFKA_DATADIR="${KAFKA_INSTALL_ROOT}/kafka-${KAFKA_PORT}/data"
     mkdir -p ${KAFKA_DATADIR}
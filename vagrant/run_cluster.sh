@@ -0,0 +1,13 @@
+#!/bin/sh
+
+set -ex
+
+# Boot the cluster installed by install_cluster.sh, create the test topics and
+# keep running; docker_test.sh waits for /tmp/cluster_ready.
+
+rm -f /tmp/cluster_ready
+${REPOSITORY_ROOT}/vagrant/boot_cluster.sh
+${REPOSITORY_ROOT}/vagrant/create_topics.sh
+touch /tmp/cluster_ready
+
+while true; do sleep 3600; done
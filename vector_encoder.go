@@ -0,0 +1,191 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// byte slices at least this large are referenced by a vectorEncoder rather than copied
+const vectorSegmentThreshold = 32 * 1024
+
+type vectorSegment struct {
+	at   int // position in raw that the segment logically follows
+	data []byte
+}
+
+type vectorPush struct {
+	field   pushEncoder
+	at      int // position of the field in raw
+	logical int // logical offset of the field in the packet
+}
+
+// vectorEncoder is a packetEncoder which copies small fields into raw as usual but
+// leaves large byte slices (typically message values and compressed message sets)
+// where they are, so that the packet can be written with vectored I/O without ever
+// being assembled in one piece. It understands the lengthField and crc32Field push
+// encoders, which are computed over the logical packet.
+type vectorEncoder struct {
+	raw      []byte
+	logical  int
+	segments []vectorSegment
+	stack    []vectorPush
+}
+
+func (ve *vectorEncoder) grow(n int) []byte {
+	off := len(ve.raw)
+	ve.raw = append(ve.raw, make([]byte, n)...)
+	ve.logical += n
+	return ve.raw[off:]
+}
+
+// primitives
+
+func (ve *vectorEncoder) putInt8(in int8) {
+	ve.grow(1)[0] = byte(in)
+}
+
+func (ve *vectorEncoder) putInt16(in int16) {
+	binary.BigEndian.PutUint16(ve.grow(2), uint16(in))
+}
+
+func (ve *vectorEncoder) putInt32(in int32) {
+	binary.BigEndian.PutUint32(ve.grow(4), uint32(in))
+}
+
+func (ve *vectorEncoder) putInt64(in int64) {
+	binary.BigEndian.PutUint64(ve.grow(8), uint64(in))
+}
+
+func (ve *vectorEncoder) putUVarint(in uint64) {
+	binary.PutUvarint(ve.grow(uvarintSize(in)), in)
+}
+
+func (ve *vectorEncoder) putArrayLength(in int) error {
+	ve.putInt32(int32(in))
+	return nil
+}
+
+// collection
+
+func (ve *vectorEncoder) putRawBytes(in []byte) error {
+	if len(in) >= vectorSegmentThreshold {
+		ve.segments = append(ve.segments, vectorSegment{at: len(ve.raw), data: in})
+		ve.logical += len(in)
+		return nil
+	}
+	copy(ve.grow(len(in)), in)
+	return nil
+}
+
+func (ve *vectorEncoder) putBytes(in []byte) error {
+	if in == nil {
+		ve.putInt32(-1)
+		return nil
+	}
+	ve.putInt32(int32(len(in)))
+	return ve.putRawBytes(in)
+}
+
+func (ve *vectorEncoder) putString(in string) error {
+	ve.putInt16(int16(len(in)))
+	copy(ve.grow(len(in)), in)
+	return nil
+}
+
+func (ve *vectorEncoder) putStringArray(in []string) error {
+	err := ve.putArrayLength(len(in))
+	if err != nil {
+		return err
+	}
+
+	for _, val := range in {
+		if err := ve.putString(val); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ve *vectorEncoder) putInt32Array(in []int32) error {
+	err := ve.putArrayLength(len(in))
+	if err != nil {
+		return err
+	}
+	for _, val := range in {
+		ve.putInt32(val)
+	}
+	return nil
+}
+
+func (ve *vectorEncoder) putInt64Array(in []int64) error {
+	err := ve.putArrayLength(len(in))
+	if err != nil {
+		return err
+	}
+	for _, val := range in {
+		ve.putInt64(val)
+	}
+	return nil
+}
+
+// stacks
+
+func (ve *vectorEncoder) push(in pushEncoder) {
+	in.saveOffset(len(ve.raw))
+	ve.stack = append(ve.stack, vectorPush{field: in, at: len(ve.raw), logical: ve.logical})
+	ve.grow(in.reserveLength())
+}
+
+func (ve *vectorEncoder) pop() error {
+	// this is go's ugly pop pattern (the inverse of append)
+	in := ve.stack[len(ve.stack)-1]
+	ve.stack = ve.stack[:len(ve.stack)-1]
+
+	switch field := in.field.(type) {
+	case *lengthField:
+		binary.BigEndian.PutUint32(ve.raw[in.at:], uint32(ve.logical-in.logical-4))
+	case *crc32Field:
+		binary.BigEndian.PutUint32(ve.raw[in.at:], ve.checksum(field, in.at+4))
+	default:
+		// nothing after the field has been referenced, so it can run as usual
+		if ve.logical-in.logical != len(ve.raw)-in.at {
+			return PacketEncodingError{"unsupported push encoder around a vectored segment"}
+		}
+		return field.run(len(ve.raw), ve.raw)
+	}
+	return nil
+}
+
+// checksum computes the CRC32 of the logical packet from position start in raw
+// to the current end, including any segments in between.
+func (ve *vectorEncoder) checksum(field *crc32Field, start int) uint32 {
+	var crc uint32
+	pos := start
+	for _, segment := range ve.segments {
+		if segment.at < start {
+			continue
+		}
+		crc = field.update(crc, ve.raw[pos:segment.at])
+		crc = field.update(crc, segment.data)
+		pos = segment.at
+	}
+	return field.update(crc, ve.raw[pos:])
+}
+
+// buffers returns the encoded packet as a sequence of buffers to be written in order.
+func (ve *vectorEncoder) buffers() net.Buffers {
+	buffers := make(net.Buffers, 0, 2*len(ve.segments)+1)
+	pos := 0
+	for _, segment := range ve.segments {
+		if segment.at > pos {
+			buffers = append(buffers, ve.raw[pos:segment.at])
+		}
+		buffers = append(buffers, segment.data)
+		pos = segment.at
+	}
+	if pos < len(ve.raw) {
+		buffers = append(buffers, ve.raw[pos:])
+	}
+	return buffers
+}
@@ -0,0 +1,47 @@
+package sarama
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVectorEncoderMatchesRealEncoder(t *testing.T) {
+	large := bytes.Repeat([]byte{0xAB}, 3*vectorSegmentThreshold)
+
+	// a single partition, so that map ordering doesn't affect the comparison
+	produce := new(ProduceRequest)
+	produce.AddMessage("foo", 0, &Message{Key: []byte("small"), Value: []byte("small")})
+	produce.AddMessage("foo", 0, &Message{Key: []byte("large"), Value: large})
+	produce.AddMessage("foo", 0, &Message{Value: large})
+	produce.AddMessage("foo", 0, &Message{Value: nil})
+	req := &request{correlationID: 123, clientID: "foo", body: produce}
+
+	expected, err := encode(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffers, raw, err := encodeVectored(req, nil, MaxRequestSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) >= vectorSegmentThreshold {
+		t.Error("Large values were copied into the inline buffer:", len(raw), "bytes")
+	}
+
+	var joined bytes.Buffer
+	if _, err := buffers.WriteTo(&joined); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(joined.Bytes(), expected) {
+		t.Error("Vectored encoding does not match the contiguous encoding")
+	}
+
+	decoded, err := decodeRequest(bytes.NewReader(joined.Bytes()))
+	if err != nil {
+		t.Fatal("Failed to decode the vectored request:", err)
+	}
+	if _, ok := decoded.body.(*ProduceRequest); !ok {
+		t.Error("Decoded request is not a ProduceRequest")
+	}
+}
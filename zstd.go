@@ -0,0 +1,114 @@
+package sarama
+
+import (
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstd encoders and decoders are comparatively expensive to construct, so rather
+// than building one per message set we keep one encoder per compression level and
+// a decoder for the current decompressed size limit. EncodeAll and DecodeAll are
+// safe for concurrent use.
+var (
+	zstdLock     sync.Mutex
+	zstdEncoders = make(map[int]*zstd.Encoder)
+	zstdDecoder  *sharedZstdDecoder
+)
+
+// sharedZstdDecoder is the decoder for a limit. Decoders own goroutines until
+// they are closed, so once the limit changes the decoder of the previous one is
+// retired, and closed as soon as the decodes still using it are done.
+type sharedZstdDecoder struct {
+	*zstd.Decoder
+	limit   int64
+	users   int
+	retired bool
+}
+
+func getZstdEncoder(level int) (*zstd.Encoder, error) {
+	zstdLock.Lock()
+	defer zstdLock.Unlock()
+
+	if enc := zstdEncoders[level]; enc != nil {
+		return enc, nil
+	}
+
+	encoderLevel := zstd.SpeedDefault
+	if level != CompressionLevelDefault && level != 0 {
+		encoderLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel), zstd.WithZeroFrames(true))
+	if err != nil {
+		return nil, err
+	}
+	zstdEncoders[level] = enc
+	return enc, nil
+}
+
+// acquireZstdDecoder returns the decoder for limit, to release with
+// releaseZstdDecoder once done with it.
+func acquireZstdDecoder(limit int64) (*sharedZstdDecoder, error) {
+	zstdLock.Lock()
+	defer zstdLock.Unlock()
+
+	if dec := zstdDecoder; dec != nil && dec.limit == limit {
+		dec.users++
+		return dec, nil
+	}
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	if old := zstdDecoder; old != nil {
+		old.retired = true
+		if old.users == 0 {
+			old.Close()
+		}
+	}
+	zstdDecoder = &sharedZstdDecoder{Decoder: dec, limit: limit, users: 1}
+	return zstdDecoder, nil
+}
+
+func releaseZstdDecoder(dec *sharedZstdDecoder) {
+	zstdLock.Lock()
+	defer zstdLock.Unlock()
+
+	dec.users--
+	if dec.retired && dec.users == 0 {
+		dec.Close()
+	}
+}
+
+// zstdEncode compresses src as a single zstd frame at the given level.
+func zstdEncode(src []byte, level int) ([]byte, error) {
+	enc, err := getZstdEncoder(level)
+	if err != nil {
+		return nil, err
+	}
+	buf := getEncodeBuffer()
+	encoded := enc.EncodeAll(src, (*buf)[:0])
+	out := make([]byte, len(encoded))
+	copy(out, encoded)
+	putEncodeBuffer(buf, encoded)
+	return out, nil
+}
+
+// zstdDecode decompresses zstd data made up of one or more frames, totalling at
+// most limit bytes.
+func zstdDecode(src []byte, limit int64) ([]byte, error) {
+	dec, err := acquireZstdDecoder(limit)
+	if err != nil {
+		return nil, err
+	}
+	out, err := dec.DecodeAll(src, nil)
+	releaseZstdDecoder(dec)
+	// the window of a frame is capped to the limit too, so frames which
+	// declare a larger one are rejected before any decoding happens
+	if err == zstd.ErrDecoderSizeExceeded || err == zstd.ErrWindowSizeExceeded {
+		return nil, ErrDecompressedSizeExceeded
+	}
+	return out, err
+}
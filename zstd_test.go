@@ -0,0 +1,69 @@
+package sarama
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestZstdRoundTrip(t *testing.T) {
+	for _, level := range []int{CompressionLevelDefault, 1, 9} {
+		for _, src := range []string{"", "REALLY SHORT", "REPEATREPEATREPEATREPEATREPEATREPEAT"} {
+			encoded, err := zstdEncode([]byte(src), level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := zstdDecode(encoded, maxDecompressedBatchBytes())
+			if err != nil {
+				t.Errorf("Decoding %q (level %d) failed: %s", src, level, err)
+			} else if !bytes.Equal(decoded, []byte(src)) {
+				t.Errorf("Expected %q (level %d), got %q", src, level, decoded)
+			}
+		}
+	}
+}
+
+func TestZstdEncoderReuse(t *testing.T) {
+	first, err := getZstdEncoder(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := getZstdEncoder(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("Expected the encoder for a given level to be reused")
+	}
+}
+
+func TestZstdDecoderReplacedWhenTheLimitChanges(t *testing.T) {
+	encoded, err := zstdEncode(bytes.Repeat([]byte("REPEAT"), 100), CompressionLevelDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := acquireZstdDecoder(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for limit := int64(1 << 10); limit < 1<<15; limit <<= 1 {
+		if _, err := zstdDecode(encoded, limit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := zstdDecode(encoded, 100); err != ErrDecompressedSizeExceeded {
+		t.Error("Expected decoding more than the limit to fail, got", err)
+	}
+	if zstdDecoder.limit != 100 || !first.retired {
+		t.Error("Expected only the decoder of the latest limit to be kept")
+	}
+
+	// the decoder in use isn't closed until it is released
+	if _, err := first.DecodeAll(encoded, nil); err != nil {
+		t.Error("Expected a retired decoder to work until released, got", err)
+	}
+	releaseZstdDecoder(first)
+	if first.users != 0 {
+		t.Error("Expected the retired decoder to have no users left, got", first.users)
+	}
+}